@@ -0,0 +1,247 @@
+// Package jwtkeys 管理 JWT 的签名/验签密钥：加载 PEM、按 kid 选 key、发布 JWKS。
+// lesson10 和 micro-auth-demo 的 auth-service 共用这一份实现
+package jwtkeys
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var ErrNoSigningKey = errors.New("jwt signing key is not configured (set JWT_SIGNING_KEY_FILE)")
+
+// ValidMethods 只接受这两种签名算法，防止 alg=none 或拿公钥当 HMAC 密钥
+var ValidMethods = []string{jwt.SigningMethodRS256.Alg(), jwt.SigningMethodEdDSA.Alg()}
+
+// Key 一把签名/验签密钥，kid 取公钥的 RFC 7638 thumbprint，换文件名不影响已签发的 token
+type Key struct {
+	ID      string
+	Method  jwt.SigningMethod
+	Private crypto.Signer
+	Public  crypto.PublicKey
+}
+
+// KeySet 只用 signing 签发，verify 里的所有 key 都能验签，轮换时把旧 key 留在 verify 里直到旧 token 过期
+type KeySet struct {
+	signing *Key
+	verify  map[string]*Key
+	order   []string
+}
+
+// LoadKeySet 读取当前签名私钥和若干额外的验签密钥（私钥或公钥 PEM 都可以）
+func LoadKeySet(signingFile string, verifyFiles []string) (*KeySet, error) {
+	if strings.TrimSpace(signingFile) == "" {
+		return nil, ErrNoSigningKey
+	}
+
+	signing, err := loadKeyFile(signingFile)
+	if err != nil {
+		return nil, err
+	}
+	if signing.Private == nil {
+		return nil, fmt.Errorf("%s: signing key must be a private key", signingFile)
+	}
+
+	set := &KeySet{signing: signing, verify: map[string]*Key{}}
+	set.add(signing)
+
+	for _, file := range verifyFiles {
+		file = strings.TrimSpace(file)
+		if file == "" {
+			continue
+		}
+
+		key, err := loadKeyFile(file)
+		if err != nil {
+			return nil, err
+		}
+		set.add(key)
+	}
+
+	return set, nil
+}
+
+func (s *KeySet) add(key *Key) {
+	if _, ok := s.verify[key.ID]; ok {
+		return
+	}
+	s.verify[key.ID] = key
+	s.order = append(s.order, key.ID)
+}
+
+func (s *KeySet) Sign(claims jwt.Claims) (string, error) {
+	tokenValue := jwt.NewWithClaims(s.signing.Method, claims)
+	tokenValue.Header["kid"] = s.signing.ID
+	return tokenValue.SignedString(s.signing.Private)
+}
+
+// Parse 校验签名、算法和有效期，解析到 claims 里
+func (s *KeySet) Parse(rawToken string, claims jwt.Claims) error {
+	tokenValue, err := jwt.ParseWithClaims(rawToken, claims, s.Keyfunc, jwt.WithValidMethods(ValidMethods))
+	if err != nil {
+		return err
+	}
+	if !tokenValue.Valid {
+		return jwt.ErrTokenInvalidClaims
+	}
+
+	return nil
+}
+
+// Keyfunc 按 header 里的 kid 找验签公钥，并要求 alg 与密钥类型一致
+func (s *KeySet) Keyfunc(tokenValue *jwt.Token) (interface{}, error) {
+	kid, _ := tokenValue.Header["kid"].(string)
+	key, ok := s.verify[kid]
+	if !ok {
+		return nil, fmt.Errorf("unknown kid %q", kid)
+	}
+	if tokenValue.Method.Alg() != key.Method.Alg() {
+		return nil, fmt.Errorf("alg %s does not match key %s", tokenValue.Method.Alg(), kid)
+	}
+
+	return key.Public, nil
+}
+
+type JWK struct {
+	Kty string `json:"kty"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	Kid string `json:"kid"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS 公开所有验签公钥，当前签名 key 排第一个
+func (s *KeySet) JWKS() JWKS {
+	set := JWKS{Keys: make([]JWK, 0, len(s.order))}
+	for _, kid := range s.order {
+		key := s.verify[kid]
+		jwk := publicJWK(key.Public)
+		jwk.Use = "sig"
+		jwk.Alg = key.Method.Alg()
+		jwk.Kid = key.ID
+		set.Keys = append(set.Keys, jwk)
+	}
+
+	return set
+}
+
+func loadKeyFile(path string) (*Key, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := ParseKeyPEM(raw)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	return key, nil
+}
+
+// ParseKeyPEM 支持 PKCS#8 / PKCS#1 私钥和 PKIX 公钥，只接受 RSA（RS256）与 Ed25519（EdDSA）
+func ParseKeyPEM(raw []byte) (*Key, error) {
+	block, _ := pem.Decode(raw)
+	if block == nil {
+		return nil, errors.New("no PEM block found")
+	}
+
+	var parsed any
+	var err error
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		parsed, err = x509.ParsePKIXPublicKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("unsupported PEM block %q", block.Type)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	key := &Key{}
+	switch k := parsed.(type) {
+	case *rsa.PrivateKey:
+		key.Private, key.Public = k, &k.PublicKey
+	case *rsa.PublicKey:
+		key.Public = k
+	case ed25519.PrivateKey:
+		key.Private, key.Public = k, k.Public()
+	case ed25519.PublicKey:
+		key.Public = k
+	default:
+		return nil, fmt.Errorf("unsupported key type %T", parsed)
+	}
+
+	switch pub := key.Public.(type) {
+	case *rsa.PublicKey:
+		if pub.N.BitLen() < 2048 {
+			return nil, errors.New("rsa key must be at least 2048 bits")
+		}
+		key.Method = jwt.SigningMethodRS256
+	case ed25519.PublicKey:
+		key.Method = jwt.SigningMethodEdDSA
+	}
+	key.ID = thumbprint(key.Public)
+
+	return key, nil
+}
+
+func publicJWK(pub crypto.PublicKey) JWK {
+	switch k := pub.(type) {
+	case *rsa.PublicKey:
+		return JWK{
+			Kty: "RSA",
+			N:   base64.RawURLEncoding.EncodeToString(k.N.Bytes()),
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(k.E)).Bytes()),
+		}
+	case ed25519.PublicKey:
+		return JWK{
+			Kty: "OKP",
+			Crv: "Ed25519",
+			X:   base64.RawURLEncoding.EncodeToString(k),
+		}
+	}
+
+	return JWK{}
+}
+
+// thumbprint 按 RFC 7638 只取必需字段、字典序序列化后做 SHA-256
+func thumbprint(pub crypto.PublicKey) string {
+	jwk := publicJWK(pub)
+
+	var members map[string]string
+	switch jwk.Kty {
+	case "RSA":
+		members = map[string]string{"e": jwk.E, "kty": jwk.Kty, "n": jwk.N}
+	default:
+		members = map[string]string{"crv": jwk.Crv, "kty": jwk.Kty, "x": jwk.X}
+	}
+
+	// encoding/json 对 map 的 key 排序输出，刚好满足 RFC 7638 的要求
+	raw, _ := json.Marshal(members)
+	sum := sha256.Sum256(raw)
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
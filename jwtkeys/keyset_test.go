@@ -0,0 +1,148 @@
+package jwtkeys
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func writeKey(t *testing.T, private any) string {
+	t.Helper()
+
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "key.pem")
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func newEd25519(t *testing.T) string {
+	t.Helper()
+
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return writeKey(t, key)
+}
+
+func claims() *jwt.RegisteredClaims {
+	return &jwt.RegisteredClaims{Subject: "1", ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Minute))}
+}
+
+func TestSignAndParse(t *testing.T) {
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, file := range map[string]string{"EdDSA": newEd25519(t), "RS256": writeKey(t, rsaKey)} {
+		t.Run(name, func(t *testing.T) {
+			keys, err := LoadKeySet(file, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			raw, err := keys.Sign(claims())
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var got jwt.RegisteredClaims
+			if err := keys.Parse(raw, &got); err != nil {
+				t.Fatal(err)
+			}
+			if got.Subject != "1" {
+				t.Fatalf("subject = %q", got.Subject)
+			}
+
+			jwks := keys.JWKS()
+			if len(jwks.Keys) != 1 || jwks.Keys[0].Alg != name || jwks.Keys[0].Kid == "" {
+				t.Fatalf("unexpected jwks: %+v", jwks)
+			}
+		})
+	}
+}
+
+func TestRotation(t *testing.T) {
+	oldFile, newFile := newEd25519(t), newEd25519(t)
+
+	oldKeys, err := LoadKeySet(oldFile, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, err := oldKeys.Sign(claims())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 新 key 签发，旧 key 留在验签列表里，旧 token 继续有效
+	rotated, err := LoadKeySet(newFile, []string{oldFile})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := rotated.Parse(raw, &jwt.RegisteredClaims{}); err != nil {
+		t.Fatalf("old token rejected during rotation: %v", err)
+	}
+	if jwks := rotated.JWKS(); len(jwks.Keys) != 2 {
+		t.Fatalf("jwks has %d keys, want 2", len(jwks.Keys))
+	}
+
+	// 旧 key 移出之后就不认了
+	dropped, err := LoadKeySet(newFile, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := dropped.Parse(raw, &jwt.RegisteredClaims{}); err == nil {
+		t.Fatal("token signed by a removed key was accepted")
+	}
+}
+
+func TestParseRejectsHMAC(t *testing.T) {
+	keys, err := LoadKeySet(newEd25519(t), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	raw, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims()).SignedString([]byte("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := keys.Parse(raw, &jwt.RegisteredClaims{}); err == nil {
+		t.Fatal("HS256 token was accepted")
+	}
+}
+
+func TestLoadKeySetRequiresPrivateSigningKey(t *testing.T) {
+	if _, err := LoadKeySet("", nil); err != ErrNoSigningKey {
+		t.Fatalf("err = %v, want ErrNoSigningKey", err)
+	}
+
+	pub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "pub.pem")
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadKeySet(path, nil); err == nil {
+		t.Fatal("public key accepted as signing key")
+	}
+}
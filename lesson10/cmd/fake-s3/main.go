@@ -0,0 +1,28 @@
+package main
+
+import (
+	"lesson10/internal/pkg/storage/fakes3"
+	"lesson10/internal/pkg/utils"
+	"log"
+	"net/http"
+	"strings"
+)
+
+// 本地联调用的 S3 兼容服务（数据只在内存里）：
+//
+//	go run ./cmd/fake-s3
+//	STORAGE_DRIVER=s3 S3_ENDPOINT=http://localhost:9000 S3_BUCKET=lesson10 S3_ACCESS_KEY=lesson10 S3_SECRET_KEY=lesson10-secret go run ./cmd/server
+func main() {
+	addr := utils.EnvString("FAKE_S3_ADDR", ":9000")
+
+	srv := fakes3.New(fakes3.Config{
+		Region:     utils.EnvString("FAKE_S3_REGION", "us-east-1"),
+		AccessKey:  utils.EnvString("FAKE_S3_ACCESS_KEY", "lesson10"),
+		SecretKey:  utils.EnvString("FAKE_S3_SECRET_KEY", "lesson10-secret"),
+		Buckets:    strings.Split(utils.EnvString("FAKE_S3_BUCKETS", "lesson10"), ","),
+		PublicRead: utils.EnvString("FAKE_S3_PUBLIC_READ", "true") == "true",
+	})
+
+	log.Printf("fake s3 listening on %s", addr)
+	log.Fatal(http.ListenAndServe(addr, srv.Handler()))
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"lesson10/internal/config"
+	"lesson10/internal/repository"
+	"lesson10/internal/service"
+	"log"
+	"os"
+
+	"github.com/joho/godotenv"
+)
+
+// 手动清理过期会话和 refresh token，保留时间等配置与服务端相同：
+//
+//	go run ./cmd/janitor -dry-run   # 只统计会删除多少行
+//	go run ./cmd/janitor
+func main() {
+	dryRun := flag.Bool("dry-run", false, "only count rows that would be removed")
+	flag.Parse()
+
+	_ = godotenv.Overload(".env.local")
+	_ = godotenv.Load(".env")
+
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatal("load config: ", err)
+	}
+	if err := cfg.DB.Validate(); err != nil {
+		log.Fatal(err)
+	}
+	db, err := config.OpenDB(cfg.DB)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	janitor := service.NewJanitorService(repository.NewJanitorRepo(db), cfg.Janitor, cfg.RefreshRetention())
+	report := janitor.RunOnce(context.Background(), *dryRun)
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	_ = enc.Encode(report)
+
+	if report.Error != "" {
+		log.Fatal("janitor failed: ", report.Error)
+	}
+}
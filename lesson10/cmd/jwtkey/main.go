@@ -0,0 +1,69 @@
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"jwtkeys"
+	"log"
+	"os"
+	"path/filepath"
+)
+
+// 生成 JWT 签名私钥：
+//
+//	go run ./cmd/jwtkey data/keys/jwt-2026-01.pem          # Ed25519
+//	go run ./cmd/jwtkey data/keys/jwt-2026-01.pem rsa      # RSA 2048，签 RS256
+//
+// 轮换：生成新 key 指给 JWT_SIGNING_KEY_FILE，旧 key 放进 JWT_VERIFY_KEY_FILES，
+// 等旧 access token 全部过期后再从 JWT_VERIFY_KEY_FILES 移除
+func main() {
+	if len(os.Args) < 2 {
+		log.Fatal("usage: jwtkey <out.pem> [ed25519|rsa]")
+	}
+	out := os.Args[1]
+	alg := "ed25519"
+	if len(os.Args) > 2 {
+		alg = os.Args[2]
+	}
+
+	var private any
+	switch alg {
+	case "ed25519":
+		_, key, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			log.Fatal(err)
+		}
+		private = key
+	case "rsa":
+		key, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			log.Fatal(err)
+		}
+		private = key
+	default:
+		log.Fatalf("unsupported key type %q", alg)
+	}
+
+	der, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		log.Fatal(err)
+	}
+	raw := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+
+	key, err := jwtkeys.ParseKeyPEM(raw)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(out), 0o700); err != nil {
+		log.Fatal(err)
+	}
+	if err := os.WriteFile(out, raw, 0o600); err != nil {
+		log.Fatal(err)
+	}
+
+	log.Printf("wrote %s (alg=%s kid=%s)", out, key.Method.Alg(), key.ID)
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"lesson10/internal/config"
+	"lesson10/internal/pkg/migrate"
+	"lesson10/migrations"
+	"log"
+	"os"
+
+	"github.com/joho/godotenv"
+)
+
+// 执行 migrations 目录下的版本化 SQL 脚本，多个实例同时执行时只有一个在迁移，其余等锁：
+//
+//	go run ./cmd/migrate up              # 执行全部未执行的脚本；空库直接用 baseline 建表
+//	go run ./cmd/migrate up -to 18       # 只执行到 018
+//	go run ./cmd/migrate down            # 回滚最新的一个版本
+//	go run ./cmd/migrate down -steps 2
+//	go run ./cmd/migrate status
+//	go run ./cmd/migrate baseline -version 19   # 之前靠 AutoMigrate 建的库，先把已有结构记为已执行
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+	command, args := os.Args[1], os.Args[2:]
+
+	flags := flag.NewFlagSet(command, flag.ExitOnError)
+	to := flags.Int("to", 0, "up: stop after this version (0 = latest)")
+	steps := flags.Int("steps", 1, "down: number of versions to revert")
+	version := flags.Int("version", 0, "baseline: mark migrations up to this version as applied (0 = latest)")
+	_ = flags.Parse(args)
+
+	_ = godotenv.Overload(".env.local")
+	_ = godotenv.Load(".env")
+
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatal("load config: ", err)
+	}
+	if err := cfg.DB.Validate(); err != nil {
+		log.Fatal(err)
+	}
+	db, err := config.OpenDB(cfg.DB)
+	if err != nil {
+		log.Fatal(err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		log.Fatal("get sql db: ", err)
+	}
+
+	runner, err := migrate.New(sqlDB, migrations.Files)
+	if err != nil {
+		log.Fatal("load migrations: ", err)
+	}
+	ctx := context.Background()
+
+	switch command {
+	case "up":
+		done, err := runner.Up(ctx, *to)
+		if err != nil {
+			log.Fatal("migrate up: ", err)
+		}
+		fmt.Printf("applied %d migration(s)\n", len(done))
+	case "down":
+		done, err := runner.Down(ctx, *steps)
+		if err != nil {
+			log.Fatal("migrate down: ", err)
+		}
+		fmt.Printf("reverted %d migration(s)\n", len(done))
+	case "status":
+		statuses, err := runner.Status(ctx)
+		if err != nil {
+			log.Fatal("migrate status: ", err)
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		_ = enc.Encode(statuses)
+	case "baseline":
+		target := *version
+		if target == 0 {
+			all := runner.Migrations()
+			if len(all) > 0 {
+				target = all[len(all)-1].Version
+			}
+		}
+		if err := runner.Baseline(ctx, target); err != nil {
+			log.Fatal("migrate baseline: ", err)
+		}
+		fmt.Printf("marked migrations up to %03d as applied\n", target)
+	default:
+		usage()
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: migrate up [-to N] | down [-steps N] | status | baseline [-version N]")
+	os.Exit(2)
+}
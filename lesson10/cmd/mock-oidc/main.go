@@ -0,0 +1,28 @@
+package main
+
+import (
+	"lesson10/internal/pkg/oidc/mockoidc"
+	"lesson10/internal/pkg/utils"
+	"log"
+	"net/http"
+)
+
+// 本地联调用的 OIDC provider：
+//
+//	go run ./cmd/mock-oidc
+//	OIDC_PROVIDERS=mock OIDC_MOCK_ISSUER=http://localhost:9090 OIDC_MOCK_CLIENT_ID=lesson10 OIDC_MOCK_CLIENT_SECRET=lesson10-secret go run ./cmd/server
+func main() {
+	addr := utils.EnvString("MOCK_OIDC_ADDR", ":9090")
+
+	srv, err := mockoidc.New(mockoidc.Config{
+		Issuer:       utils.EnvString("MOCK_OIDC_ISSUER", "http://localhost:9090"),
+		ClientID:     utils.EnvString("MOCK_OIDC_CLIENT_ID", "lesson10"),
+		ClientSecret: utils.EnvString("MOCK_OIDC_CLIENT_SECRET", "lesson10-secret"),
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	log.Printf("mock oidc provider listening on %s", addr)
+	log.Fatal(http.ListenAndServe(addr, srv.Handler()))
+}
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"encoding/json"
+	"lesson10/internal/router"
+	"log"
+	"os"
+)
+
+// 不启动服务，直接输出 OpenAPI 文档：
+//
+//	go run ./cmd/openapi openapi.json
+//	go run ./cmd/openapi                  # 输出到标准输出
+func main() {
+	out := os.Stdout
+	if len(os.Args) > 1 {
+		f, err := os.Create(os.Args[1])
+		if err != nil {
+			log.Fatal(err)
+		}
+		defer f.Close()
+		out = f
+	}
+
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(router.OpenAPI()); err != nil {
+		log.Fatal(err)
+	}
+}
@@ -1,64 +1,100 @@
 package main
 
 import (
-	"fmt"
+	"context"
 	"lesson10/internal/config"
 	"lesson10/internal/model"
+	"lesson10/internal/pkg/cache"
+	"lesson10/internal/pkg/dblock"
+	"lesson10/internal/pkg/geo"
+	"lesson10/internal/pkg/logger"
+	"lesson10/internal/pkg/mailer"
+	"lesson10/internal/pkg/migrate"
+	"lesson10/internal/pkg/oidc"
+	"lesson10/internal/pkg/ratelimit"
+	"lesson10/internal/pkg/storage"
+	"lesson10/internal/pkg/token"
+	"lesson10/internal/pkg/tracing"
 	"lesson10/internal/repository"
 	"lesson10/internal/router"
 	"lesson10/internal/service"
+	"lesson10/migrations"
 	"log"
+	"log/slog"
+	"net/http"
 	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
 
 	"github.com/joho/godotenv"
+	"gorm.io/gorm"
 )
 
-func loadEnv() {
+// loadConfig .env.local / .env 里的值作为环境变量覆盖 configs 下的 yaml，配置有问题时列出全部错误后退出
+func loadConfig() *config.Config {
 
 	_ = godotenv.Overload(".env.local")
 
 	_ = godotenv.Load(".env")
 
-	if os.Getenv("DB_HOST") == "" {
-		log.Fatal("DB_HOST is empty (check .env.local/.env)")
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatal("load config: ", err)
+	}
+	if err := cfg.Validate(); err != nil {
+		log.Fatalf("invalid config (APP_ENV=%s):\n%v", cfg.Env, err)
 	}
+
+	return cfg
 }
 
 func main() {
 
-	loadEnv()
+	cfg := loadConfig()
+	if err := logger.Init(cfg.Log); err != nil {
+		log.Fatal("init logger: ", err)
+	}
 
-	_ = godotenv.Load(".env.local")
-	config.InitDB()
+	// exporter 为 none 时是 no-op，span 不会采集也不会发送
+	shutdownTracing, err := tracing.Init(context.Background(), cfg.Tracing, cfg.Env)
+	if err != nil {
+		log.Fatal("init tracing: ", err)
+	}
 
-	db := config.DB
-	fmt.Println("开始迁移数据库...")
-	err := db.AutoMigrate(
-		&model.User{},
-		&model.Post{},
-		&model.Comment{},
-		&model.PostImage{},
-		&model.UserFollow{},
-		&model.QuestionFollow{},
-		&model.Reaction{},
-		&model.Favorite{},
-		&model.Activity{},
-		&model.Notification{},
-		&model.Conversation{},
-		&model.ConversationMember{},
-		&model.Message{},
-		&model.Session{},
-		&model.RefreshToken{},
-		&model.SecurityEvent{},
-	)
+	tokens, err := token.New(cfg.JWT)
+	if err != nil {
+		log.Fatal("load jwt keys failed: ", err)
+	}
 
+	db, err := config.OpenDB(cfg.DB)
 	if err != nil {
-		log.Fatal("迁移失败: ", err)
+		log.Fatal(err)
 	}
-	fmt.Println("迁移完成")
 
-	if err := config.CleanupPolymorphicTargetConstraints(); err != nil {
-		log.Fatal("cleanup invalid polymorphic constraints failed: ", err)
+	// 后台任务在 HTTP 服务排空之后才取消，搜索索引这类任务退出前还要落盘
+	workerCtx, stopWorkers := context.WithCancel(context.Background())
+	var workers sync.WaitGroup
+	runWorker := func(run func(ctx context.Context)) {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			run(workerCtx)
+		}()
+	}
+
+	prepareSchema(db, cfg.DB)
+
+	sqlDB, err := db.DB()
+	if err != nil {
+		log.Fatal("get sql db: ", err)
+	}
+	// 全局只需要跑一份的任务：多个实例里只有抢到对应锁的那个在跑，它退出后别的实例接手
+	runSingleton := func(name string, run func(ctx context.Context)) {
+		runWorker(func(ctx context.Context) {
+			dblock.Run(ctx, sqlDB, "lesson10.worker."+name, run)
+		})
 	}
 
 	userRepo := repository.NewUserRepo(db)
@@ -71,17 +107,219 @@ func main() {
 	sessionRepo := repository.NewSessionRepo(db)
 	refreshTokenRepo := repository.NewRefreshTokenRepo(db)
 	securityEventRepo := repository.NewSecurityEventRepo(db)
+	statsRepo := repository.NewStatsRepo(db)
+	accountRepo := repository.NewAccountRepo(db)
+	emailTokenRepo := repository.NewEmailTokenRepo(db)
+	twoFactorRepo := repository.NewTwoFactorRepo(db)
+	identityRepo := repository.NewIdentityRepo(db)
+	loginThrottleRepo := repository.NewLoginThrottleRepo(db)
+
+	redisClient := config.NewRedis(cfg.Redis)
+	hotCache := cache.NewFromConfig(cfg.Cache.Config, redisClient)
 
 	userService := service.NewUserService(userRepo, followRepo, postRepo, db)
-	authService := service.NewAuthService(userRepo, sessionRepo, refreshTokenRepo, securityEventRepo, db)
+	userService.SetCache(hotCache, cfg.Cache.TTL)
+	authService := service.NewAuthService(userRepo, sessionRepo, refreshTokenRepo, securityEventRepo, db, tokens, cfg.Session, cfg.Login)
 	userService.SetAuthService(authService)
+	authService.SetLoginThrottleRepo(loginThrottleRepo)
+	authService.SetStatsRepo(statsRepo)
+
+	geoLocator, err := geo.New(cfg.Geo)
+	if err != nil {
+		log.Fatal("load geo database failed: ", err)
+	}
+	authService.SetGeoLocator(geoLocator)
+
 	postService := service.NewPostService(userRepo, postRepo, favoriteRepo)
+	postService.SetCache(hotCache, cfg.Cache.TTL)
+	runSingleton("render", postService.RenderStalePosts)
 	commentService := service.NewCommentService(userRepo, postRepo, commentRepo, notificationRepo, reactionRepo)
 	reactionService := service.NewReactionService(reactionRepo, postRepo, commentRepo, notificationRepo, db)
+	reactionService.SetCache(hotCache)
 	followService := service.NewFollowService(followRepo, userRepo)
+	followService.SetCache(hotCache)
 	favoriteService := service.NewFavoriteService(favoriteRepo, postRepo)
 	notificationService := service.NewNotificationService(notificationRepo, userRepo)
+	adminService := service.NewAdminService(statsRepo)
+	accountService := service.NewAccountService(accountRepo, userRepo, sessionRepo, notificationRepo, authService, db, cfg.Account)
+	accountService.SetCache(hotCache)
+	runWorker(accountService.RunWorker)
+	emailService := service.NewEmailService(userRepo, emailTokenRepo, securityEventRepo, authService, mailer.New(cfg.Mail.Config), cfg.Server.BaseURL, db, cfg.Mail)
+	userService.SetEmailService(emailService)
+	authService.SetEmailService(emailService)
+	twoFactorService := service.NewTwoFactorService(twoFactorRepo, securityEventRepo, authService, db, cfg.TwoFactor)
+	authService.SetTwoFactorService(twoFactorService)
+	oauthService := service.NewOAuthService(oidc.LoadProviders(cfg.OAuth.Providers, cfg.OAuth.Clients, cfg.Server.BaseURL), identityRepo, userRepo, securityEventRepo, authService, db)
+	securityService := service.NewSecurityService(securityEventRepo, notificationRepo, authService, cfg.Security)
+	securityService.SetGeoLocator(geoLocator)
+	runSingleton("security", securityService.RunWorker)
+	janitorService := service.NewJanitorService(repository.NewJanitorRepo(db), cfg.Janitor, cfg.RefreshRetention())
+	runSingleton("janitor", janitorService.RunWorker)
+	store, err := storage.New(cfg.Storage)
+	if err != nil {
+		log.Fatal("init storage: ", err)
+	}
+	uploadService := service.NewUploadService(repository.NewImageRepo(db), userRepo, store, db, cfg.Upload)
+	uploadService.SetCache(hotCache)
+	postService.SetUploadService(uploadService)
+	runSingleton("upload_cleanup", uploadService.RunWorker)
+	searchIndex, err := repository.NewSearchIndex(db, cfg.Search.Engine, cfg.Search.IndexPath)
+	if err != nil {
+		log.Fatal("init search index: ", err)
+	}
+	searchService := service.NewSearchService(searchIndex, repository.NewSearchRepo(db), userRepo, cfg.Search)
+	postService.SetSearchService(searchService)
+	commentService.SetSearchService(searchService)
+	userService.SetSearchService(searchService)
+	accountService.SetSearchService(searchService)
+	runWorker(searchService.RunWorker)
+
+	limiter, err := ratelimit.New(cfg.RateLimit, redisClient)
+	if err != nil {
+		log.Fatal("rate limiter: ", err)
+	}
+	limits, err := ratelimit.LoadPolicies(cfg.RateLimit)
+	if err != nil {
+		log.Fatal("load rate limits: ", err)
+	}
+
+	healthService := service.NewHealthService(db)
+	if cfg.Cache.Backend == "redis" || cfg.RateLimit.Backend == "redis" {
+		healthService.SetRedis(redisClient)
+	}
+
+	engine := router.NewRouter(cfg.Server, cfg.Metrics, healthService, authService, userService, postService, commentService, reactionService, followService, favoriteService, notificationService, adminService, accountService, emailService, twoFactorService, oauthService, securityService, janitorService, uploadService, searchService, limiter, limits)
+	serve(router.NewServer(cfg.Server, engine), cfg.Server, healthService)
+
+	stopWorkers()
+	done := make(chan struct{})
+	go func() {
+		workers.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(cfg.Server.ShutdownTimeout):
+		slog.Warn("background workers did not stop in time")
+	}
+
+	flushCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := shutdownTracing(flushCtx); err != nil {
+		slog.Warn("flush traces failed", "error", err)
+	}
+	slog.Info("server stopped")
+}
+
+// serve 一直监听到收到 SIGINT/SIGTERM：先把 /readyz 置为 503，等 drain_delay 让负载均衡摘掉流量，
+// 再停止接收新连接，最多等 shutdown_timeout 让进行中的请求处理完
+func serve(srv *http.Server, cfg config.ServerConfig, health *service.HealthService) {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- srv.ListenAndServe()
+	}()
+	slog.Info("listening", "addr", srv.Addr)
+
+	select {
+	case err := <-errCh:
+		log.Fatal("listen: ", err)
+	case <-ctx.Done():
+	}
+	// 之后再按一次 Ctrl+C 直接退出
+	stop()
+
+	health.SetDraining()
+	if cfg.DrainDelay > 0 {
+		slog.Info("draining", "delay", cfg.DrainDelay.String())
+		time.Sleep(cfg.DrainDelay)
+	}
+
+	slog.Info("shutting down, waiting for in-flight requests", "timeout", cfg.ShutdownTimeout.String())
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		slog.Error("graceful shutdown failed", "error", err)
+	}
+}
+
+// prepareSchema 默认只检查 migrations 是否都执行过，没执行完拒绝启动；
+// migrate_on_start 打开时启动时自己执行（多个实例同时启动只有一个在迁移）。
+// auto_migrate 仍走 GORM AutoMigrate，只给本地开发随手改模型用，production 下不允许打开
+func prepareSchema(db *gorm.DB, cfg config.DBConfig) {
+	if cfg.AutoMigrate {
+		autoMigrate(db)
+		return
+	}
+
+	sqlDB, err := db.DB()
+	if err != nil {
+		log.Fatal("get sql db: ", err)
+	}
+	runner, err := migrate.New(sqlDB, migrations.Files)
+	if err != nil {
+		log.Fatal("load migrations: ", err)
+	}
+	ctx := context.Background()
+
+	if cfg.MigrateOnStart {
+		if _, err := runner.Up(ctx, 0); err != nil {
+			log.Fatal("migrate up: ", err)
+		}
+		return
+	}
+
+	pending, err := runner.Pending(ctx)
+	if err != nil {
+		log.Fatal("check migrations: ", err, " (see `go run ./cmd/migrate status`)")
+	}
+	if len(pending) > 0 {
+		log.Fatalf("%d migration(s) pending, starting from %03d_%s: run `go run ./cmd/migrate up` or set MIGRATE_ON_START=true",
+			len(pending), pending[0].Version, pending[0].Name)
+	}
+}
 
-	router.InitRouter(authService, userService, postService, commentService, reactionService, followService, favoriteService, notificationService)
+func autoMigrate(db *gorm.DB) {
+	slog.Info("auto migrating database")
+	err := db.AutoMigrate(
+		&model.User{},
+		&model.Post{},
+		&model.Comment{},
+		&model.PostImage{},
+		&model.PostImageRef{},
+		&model.ImageBlob{},
+		&model.UserFollow{},
+		&model.QuestionFollow{},
+		&model.Reaction{},
+		&model.Favorite{},
+		&model.Activity{},
+		&model.Notification{},
+		&model.Conversation{},
+		&model.ConversationMember{},
+		&model.Message{},
+		&model.Session{},
+		&model.DailyActiveUser{},
+		&model.RefreshToken{},
+		&model.SecurityEvent{},
+		&model.DataExport{},
+		&model.AccountDeletion{},
+		&model.EmailToken{},
+		&model.UserTOTP{},
+		&model.TOTPRecoveryCode{},
+		&model.LoginChallenge{},
+		&model.UserIdentity{},
+		&model.OAuthState{},
+		&model.LoginThrottle{},
+	)
+
+	if err != nil {
+		log.Fatal("auto migrate: ", err)
+	}
+	slog.Info("auto migrate done")
 
+	if err := config.CleanupPolymorphicTargetConstraints(db); err != nil {
+		log.Fatal("cleanup invalid polymorphic constraints failed: ", err)
+	}
 }
@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"io/fs"
+	"lesson10/internal/config"
+	"lesson10/internal/pkg/storage"
+	"lesson10/internal/repository"
+	"log"
+	"mime"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/joho/godotenv"
+)
+
+type report struct {
+	From        string `json:"from"`
+	To          string `json:"to"`
+	DryRun      bool   `json:"dry_run"`
+	Files       int    `json:"files"`
+	Copied      int    `json:"copied"`
+	Skipped     int    `json:"skipped"`
+	Deleted     int    `json:"deleted"`
+	RowsUpdated int64  `json:"rows_updated"`
+}
+
+// 把本地 static/uploads 下已有的文件搬到 storage.driver（STORAGE_DRIVER）指定的后端，并把库里存的地址改成新前缀：
+//
+//	STORAGE_DRIVER=s3 S3_ENDPOINT=... go run ./cmd/storage-migrate -dry-run   # 只统计
+//	STORAGE_DRIVER=s3 S3_ENDPOINT=... go run ./cmd/storage-migrate            # 复制 + 改地址
+//	STORAGE_DRIVER=s3 S3_ENDPOINT=... go run ./cmd/storage-migrate -delete    # 确认无误后再删本地文件
+//
+// 目标里已存在的 key 会跳过，中断后可以直接重跑
+func main() {
+	src := flag.String("src", "", "local upload root (default storage.local.root)")
+	dryRun := flag.Bool("dry-run", false, "only list files that would be copied")
+	deleteLocal := flag.Bool("delete", false, "delete local files after they are copied")
+	rewrite := flag.Bool("rewrite", true, "rewrite stored urls to the new storage prefix")
+	flag.Parse()
+
+	_ = godotenv.Overload(".env.local")
+	_ = godotenv.Load(".env")
+
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatal("load config: ", err)
+	}
+
+	localCfg := cfg.Storage.Local
+	if *src != "" {
+		localCfg.Root = *src
+	}
+	source, err := storage.NewLocal(localCfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	target, err := storage.New(cfg.Storage)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if local, ok := target.(*storage.Local); ok && filepath.Clean(local.Root()) == filepath.Clean(source.Root()) {
+		log.Fatal("target storage is the local upload root, set STORAGE_DRIVER=s3")
+	}
+
+	ctx := context.Background()
+	rep := report{From: source.URL(""), To: target.URL(""), DryRun: *dryRun}
+
+	var keys []string
+	err = filepath.WalkDir(source.Root(), func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		// 跳过 .upload-* 临时文件之类的隐藏文件
+		if strings.HasPrefix(d.Name(), ".") {
+			if d.IsDir() && name != source.Root() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+
+		rel, err := filepath.Rel(source.Root(), name)
+		if err != nil {
+			return err
+		}
+		keys = append(keys, filepath.ToSlash(rel))
+		return nil
+	})
+	if err != nil {
+		log.Fatal("walk upload root: ", err)
+	}
+	rep.Files = len(keys)
+
+	for _, key := range keys {
+		exists, err := target.Exists(ctx, key)
+		if err != nil {
+			log.Fatalf("check %s: %v", key, err)
+		}
+		if exists {
+			rep.Skipped++
+			continue
+		}
+		if *dryRun {
+			rep.Copied++
+			continue
+		}
+
+		data, err := os.ReadFile(filepath.Join(source.Root(), filepath.FromSlash(key)))
+		if err != nil {
+			log.Fatalf("read %s: %v", key, err)
+		}
+		if err := target.Put(ctx, key, data, mime.TypeByExtension(path.Ext(key))); err != nil {
+			log.Fatalf("put %s: %v", key, err)
+		}
+		rep.Copied++
+	}
+
+	if !*dryRun && *rewrite {
+		if err := cfg.DB.Validate(); err != nil {
+			log.Fatal(err)
+		}
+		db, err := config.OpenDB(cfg.DB)
+		if err != nil {
+			log.Fatal(err)
+		}
+		rep.RowsUpdated, err = repository.NewImageRepo(db).RewriteURLPrefix(ctx, rep.From, rep.To, keys)
+		if err != nil {
+			log.Fatal("rewrite urls: ", err)
+		}
+	}
+
+	if !*dryRun && *deleteLocal {
+		for _, key := range keys {
+			if err := source.Delete(ctx, key); err != nil {
+				log.Fatalf("delete %s: %v", key, err)
+			}
+			rep.Deleted++
+		}
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	_ = enc.Encode(rep)
+}
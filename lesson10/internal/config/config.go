@@ -0,0 +1,404 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"lesson10/internal/pkg/cache"
+	"lesson10/internal/pkg/geo"
+	"lesson10/internal/pkg/logger"
+	"lesson10/internal/pkg/mailer"
+	"lesson10/internal/pkg/metrics"
+	"lesson10/internal/pkg/oidc"
+	"lesson10/internal/pkg/ratelimit"
+	"lesson10/internal/pkg/storage"
+	"lesson10/internal/pkg/token"
+	"lesson10/internal/pkg/tracing"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config 启动时读一次，之后作为参数传给各个组件，不再到处读环境变量。
+// 读取顺序：代码里的默认值 → configs/config.yaml → configs/config.<APP_ENV>.yaml → 环境变量，后面的覆盖前面的；
+// 密码、密钥这类值不要写进 yaml，放在 .env / 部署平台的环境变量里
+type Config struct {
+	Env       string           `yaml:"-"`
+	Server    ServerConfig     `yaml:"server"`
+	DB        DBConfig         `yaml:"db"`
+	Redis     RedisConfig      `yaml:"redis"`
+	JWT       token.Config     `yaml:"jwt"`
+	Session   SessionConfig    `yaml:"session"`
+	Login     LoginConfig      `yaml:"login"`
+	TwoFactor TwoFactorConfig  `yaml:"two_factor"`
+	OAuth     OAuthConfig      `yaml:"oauth"`
+	Mail      MailConfig       `yaml:"mail"`
+	Account   AccountConfig    `yaml:"account"`
+	Security  SecurityConfig   `yaml:"security"`
+	Janitor   JanitorConfig    `yaml:"janitor"`
+	Geo       geo.Config       `yaml:"geo"`
+	Storage   storage.Config   `yaml:"storage"`
+	Upload    UploadConfig     `yaml:"upload"`
+	RateLimit ratelimit.Config `yaml:"rate_limit"`
+	Cache     CacheConfig      `yaml:"cache"`
+	Search    SearchConfig     `yaml:"search"`
+	Log       logger.Config    `yaml:"log"`
+	Metrics   metrics.Config   `yaml:"metrics"`
+	Tracing   tracing.Config   `yaml:"tracing"`
+}
+
+type ServerConfig struct {
+	Port int `yaml:"port" env:"APP_PORT"`
+	// BaseURL 前端地址，邮件里的链接和 OAuth 回调地址都拼在它后面
+	BaseURL     string   `yaml:"base_url" env:"APP_BASE_URL"`
+	StaticDir   string   `yaml:"static_dir" env:"STATIC_DIR"`
+	CORSOrigins []string `yaml:"cors_origins" env:"CORS_ALLOW_ORIGINS"`
+	// ShutdownTimeout 退出时等进行中请求的最长时间；DrainDelay 在这之前先让 /readyz 返回 503 多久
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout" env:"SHUTDOWN_TIMEOUT_SECONDS" unit:"s"`
+	DrainDelay      time.Duration `yaml:"drain_delay" env:"SHUTDOWN_DRAIN_SECONDS" unit:"s"`
+}
+
+func (c ServerConfig) Addr() string {
+	return ":" + strconv.Itoa(c.Port)
+}
+
+type DBConfig struct {
+	Host     string `yaml:"host" env:"DB_HOST"`
+	Port     int    `yaml:"port" env:"DB_PORT"`
+	User     string `yaml:"user" env:"DB_USER"`
+	Password string `yaml:"password" env:"DB_PASS"`
+	Name     string `yaml:"name" env:"DB_NAME"`
+	// AutoMigrate 用 GORM AutoMigrate 建表，只给本地开发用；MigrateOnStart 启动时执行 migrations
+	AutoMigrate    bool `yaml:"auto_migrate" env:"DB_AUTO_MIGRATE"`
+	MigrateOnStart bool `yaml:"migrate_on_start" env:"MIGRATE_ON_START"`
+}
+
+func (c DBConfig) DSN() string {
+	return fmt.Sprintf("%s:%s@tcp(%s:%d)/%s?charset=utf8mb4&parseTime=true&loc=Local",
+		c.User, c.Password, c.Host, c.Port, c.Name,
+	)
+}
+
+type RedisConfig struct {
+	Addr     string `yaml:"addr" env:"REDIS_ADDR"`
+	Password string `yaml:"password" env:"REDIS_PASSWORD"`
+	DB       int    `yaml:"db" env:"REDIS_DB"`
+}
+
+// SessionConfig 会话有效期，勾选“记住我”的会话用 Remember 开头的一组；
+// IdleTimeout 不配置时和 refresh token 有效期一致
+type SessionConfig struct {
+	IdleTimeout         time.Duration `yaml:"idle_timeout" env:"SESSION_IDLE_TIMEOUT_HOURS" unit:"h"`
+	MaxLifetime         time.Duration `yaml:"max_lifetime" env:"SESSION_MAX_LIFETIME_HOURS" unit:"h"`
+	RememberIdleTimeout time.Duration `yaml:"remember_idle_timeout" env:"SESSION_REMEMBER_IDLE_TIMEOUT_HOURS" unit:"h"`
+	RememberMaxLifetime time.Duration `yaml:"remember_max_lifetime" env:"SESSION_REMEMBER_MAX_LIFETIME_HOURS" unit:"h"`
+
+	// MaxActive 一个用户同时在线的会话数；MaxActivePerDeviceType 形如 mobile=1，没列出的设备类型只受总数限制。
+	// 超出时 LimitMode=evict 下线最早登录的会话，reject 拒绝这次登录
+	MaxActive              int      `yaml:"max_active" env:"SESSION_MAX_ACTIVE"`
+	MaxActivePerDeviceType []string `yaml:"max_active_per_device_type" env:"SESSION_MAX_ACTIVE_PER_DEVICE_TYPE"`
+	LimitMode              string   `yaml:"limit_mode" env:"SESSION_LIMIT_MODE"`
+
+	// History 会话列表里附带多久以内下线的会话
+	History time.Duration `yaml:"history" env:"SESSION_HISTORY_DAYS" unit:"d"`
+}
+
+// DeviceTypeLimits 解析 MaxActivePerDeviceType，设备类型统一小写
+func (c SessionConfig) DeviceTypeLimits() (map[string]int, error) {
+	limits := make(map[string]int, len(c.MaxActivePerDeviceType))
+	for _, item := range c.MaxActivePerDeviceType {
+		deviceType, raw, ok := strings.Cut(item, "=")
+		deviceType = strings.ToLower(strings.TrimSpace(deviceType))
+		limit, err := strconv.Atoi(strings.TrimSpace(raw))
+		if !ok || deviceType == "" || err != nil || limit <= 0 {
+			return nil, fmt.Errorf("session.max_active_per_device_type (SESSION_MAX_ACTIVE_PER_DEVICE_TYPE): %q, want <type>=<positive limit>", item)
+		}
+		limits[deviceType] = limit
+	}
+
+	return limits, nil
+}
+
+// LoginConfig 登录失败的退避和锁定：前 FreeAttempts 次失败不限制，之后每次等待时间翻倍（封顶 MaxBackoff）；
+// 账号连续失败 LockThreshold 次锁定 LockDuration，超过 FailureWindow 没有新的失败计数清零
+type LoginConfig struct {
+	AccountFreeAttempts int           `yaml:"account_free_attempts" env:"LOGIN_ACCOUNT_FREE_ATTEMPTS"`
+	IPFreeAttempts      int           `yaml:"ip_free_attempts" env:"LOGIN_IP_FREE_ATTEMPTS"`
+	MaxBackoff          time.Duration `yaml:"max_backoff" env:"LOGIN_MAX_BACKOFF_SECONDS" unit:"s"`
+	LockThreshold       int           `yaml:"lock_threshold" env:"LOGIN_ACCOUNT_LOCK_THRESHOLD"`
+	LockDuration        time.Duration `yaml:"lock_duration" env:"LOGIN_ACCOUNT_LOCK_MINUTES" unit:"m"`
+	FailureWindow       time.Duration `yaml:"failure_window" env:"LOGIN_FAILURE_WINDOW_HOURS" unit:"h"`
+}
+
+type TwoFactorConfig struct {
+	// Issuer 验证器 App 里显示的签发者名称
+	Issuer string `yaml:"issuer" env:"TOTP_ISSUER"`
+}
+
+// OAuthConfig Providers 列出启用的第三方登录，各自的参数按名字写在 Clients 下；
+// 环境变量是 OIDC_<NAME>_ISSUER 这样带 provider 名字的前缀，见 applyEnv
+type OAuthConfig struct {
+	Providers []string               `yaml:"providers" env:"OIDC_PROVIDERS"`
+	Clients   map[string]oidc.Config `yaml:"clients"`
+}
+
+// MailConfig 发信方式和邮件里链接的有效期
+type MailConfig struct {
+	mailer.Config  `yaml:",inline"`
+	VerifyTokenTTL time.Duration `yaml:"verify_token_ttl" env:"EMAIL_VERIFY_TOKEN_HOURS" unit:"h"`
+	ResetTokenTTL  time.Duration `yaml:"reset_token_ttl" env:"PASSWORD_RESET_TOKEN_HOURS" unit:"h"`
+}
+
+// AccountConfig 数据导出和账号注销；DeletionPolicy=anonymize 保留内容但作者匿名化，remove 连同内容一起删除
+type AccountConfig struct {
+	DeletionGrace  time.Duration `yaml:"deletion_grace" env:"ACCOUNT_DELETION_GRACE_HOURS" unit:"h"`
+	DeletionPolicy string        `yaml:"deletion_policy" env:"ACCOUNT_DELETION_POLICY"`
+	ExportLinkTTL  time.Duration `yaml:"export_link_ttl" env:"DATA_EXPORT_LINK_HOURS" unit:"h"`
+	ExportDir      string        `yaml:"export_dir" env:"DATA_EXPORT_DIR"`
+}
+
+// SecurityConfig Rules 按规则名覆盖异常检测规则，值为 <次数>/<窗口> 或 off；
+// 环境变量 SECURITY_RULE_<NAME> 优先
+type SecurityConfig struct {
+	Rules map[string]string `yaml:"rules"`
+}
+
+// SecurityRule 解析后的一条覆盖，Off 表示关闭该规则
+type SecurityRule struct {
+	Off       bool
+	Threshold int
+	Window    time.Duration
+}
+
+// RuleOverrides 解析 Rules，规则名统一小写
+func (c SecurityConfig) RuleOverrides() (map[string]SecurityRule, error) {
+	rules := make(map[string]SecurityRule, len(c.Rules))
+	var errs []error
+	for name, raw := range c.Rules {
+		name = strings.ToLower(strings.TrimSpace(name))
+		raw = strings.TrimSpace(raw)
+		if strings.EqualFold(raw, "off") {
+			rules[name] = SecurityRule{Off: true}
+			continue
+		}
+
+		count, window, ok := strings.Cut(raw, "/")
+		n, err := strconv.Atoi(strings.TrimSpace(count))
+		d, derr := time.ParseDuration(strings.TrimSpace(window))
+		if !ok || err != nil || n <= 0 || derr != nil || d <= 0 {
+			errs = append(errs, fmt.Errorf("security.rules.%s (SECURITY_RULE_%s): %q, want <threshold>/<window> or off", name, strings.ToUpper(name), raw))
+			continue
+		}
+		rules[name] = SecurityRule{Threshold: n, Window: d}
+	}
+
+	return rules, errors.Join(errs...)
+}
+
+// JanitorConfig 后台清理：每 Interval 跑一次，每批删 BatchSize 行，批次之间暂停 BatchPause；
+// ArchiveDir 非空时删除前先按天追加写入 jsonl
+type JanitorConfig struct {
+	Interval time.Duration `yaml:"interval" env:"JANITOR_INTERVAL_MINUTES" unit:"m"`
+	// SessionRetention 要比 session.history 和“记住我”的空闲时间长，否则还在线的会话会被当成过期删掉
+	SessionRetention time.Duration `yaml:"session_retention" env:"JANITOR_SESSION_RETENTION_DAYS" unit:"d"`
+	// RefreshRetention 用过/吊销的 refresh token 保留多久，0 表示按会话策略推算，见 Config.RefreshRetention
+	RefreshRetention time.Duration `yaml:"refresh_retention" env:"JANITOR_REFRESH_RETENTION_DAYS" unit:"d"`
+	BatchSize        int           `yaml:"batch_size" env:"JANITOR_BATCH_SIZE"`
+	BatchPause       time.Duration `yaml:"batch_pause" env:"JANITOR_BATCH_PAUSE_MS" unit:"ms"`
+	ArchiveDir       string        `yaml:"archive_dir" env:"JANITOR_ARCHIVE_DIR"`
+}
+
+// RefreshRetention 用过/吊销的 refresh token 要留到所属会话一定已经失效，这段时间内旧 token 被重放才能识别出来。
+// 会话最长能活到空闲时间和最长登录时间里较大的那个，所以取会话策略和 refresh token 有效期里的最大值
+func (c Config) RefreshRetention() time.Duration {
+	if c.Janitor.RefreshRetention > 0 {
+		return c.Janitor.RefreshRetention
+	}
+
+	retention := c.JWT.RefreshTTL
+	for _, d := range []time.Duration{c.Session.IdleTimeout, c.Session.MaxLifetime, c.Session.RememberIdleTimeout, c.Session.RememberMaxLifetime} {
+		retention = max(retention, d)
+	}
+	return retention
+}
+
+// UploadConfig 配额按 MB；OrphanGrace 上传后多久还没被引用就算孤儿，要给写草稿的人留够时间
+type UploadConfig struct {
+	QuotaMB         int           `yaml:"quota_mb" env:"UPLOAD_QUOTA_MB"`
+	VIPQuotaMB      int           `yaml:"vip_quota_mb" env:"UPLOAD_QUOTA_VIP_MB"`
+	OrphanGrace     time.Duration `yaml:"orphan_grace" env:"UPLOAD_ORPHAN_GRACE_HOURS" unit:"h"`
+	CleanupInterval time.Duration `yaml:"cleanup_interval" env:"UPLOAD_CLEANUP_INTERVAL_MINUTES" unit:"m"`
+	SignedURLTTL    time.Duration `yaml:"signed_url_ttl" env:"STORAGE_SIGNED_URL_TTL_MINUTES" unit:"m"`
+}
+
+type CacheConfig struct {
+	cache.Config `yaml:",inline"`
+	TTL          CacheTTL `yaml:"ttl"`
+}
+
+// CacheTTL 实际过期时间会在 ±10% 内随机
+type CacheTTL struct {
+	Post time.Duration `yaml:"post" env:"CACHE_POST_TTL_SECONDS" unit:"s"`
+	User time.Duration `yaml:"user" env:"CACHE_USER_TTL_SECONDS" unit:"s"`
+	List time.Duration `yaml:"list" env:"CACHE_LIST_TTL_SECONDS" unit:"s"`
+}
+
+type SearchConfig struct {
+	Engine         string        `yaml:"engine" env:"SEARCH_ENGINE"`
+	IndexPath      string        `yaml:"index_path" env:"SEARCH_INDEX_PATH"`
+	RebuildOnStart bool          `yaml:"rebuild_on_start" env:"SEARCH_REBUILD_ON_START"`
+	FlushInterval  time.Duration `yaml:"flush_interval" env:"SEARCH_FLUSH_INTERVAL_SECONDS" unit:"s"`
+}
+
+func Default() Config {
+	return Config{
+		Env: "development",
+		Server: ServerConfig{
+			Port:            8080,
+			BaseURL:         "http://localhost:3000",
+			StaticDir:       "static",
+			CORSOrigins:     []string{"http://localhost:3000"},
+			ShutdownTimeout: 15 * time.Second,
+		},
+		DB: DBConfig{Port: 3306},
+		Redis: RedisConfig{
+			Addr: "127.0.0.1:6379",
+		},
+		JWT: token.Config{
+			AccessTTL:  time.Hour,
+			RefreshTTL: 7 * 24 * time.Hour,
+		},
+		Session: SessionConfig{
+			MaxLifetime:         30 * 24 * time.Hour,
+			RememberIdleTimeout: 30 * 24 * time.Hour,
+			RememberMaxLifetime: 90 * 24 * time.Hour,
+			MaxActive:           10,
+			LimitMode:           "evict",
+			History:             30 * 24 * time.Hour,
+		},
+		Login: LoginConfig{
+			AccountFreeAttempts: 3,
+			IPFreeAttempts:      20,
+			MaxBackoff:          15 * time.Minute,
+			LockThreshold:       10,
+			LockDuration:        30 * time.Minute,
+			FailureWindow:       time.Hour,
+		},
+		TwoFactor: TwoFactorConfig{Issuer: "lesson10"},
+		Mail: MailConfig{
+			Config: mailer.Config{
+				Driver: "file",
+				From:   "no-reply@lesson10.local",
+				Dir:    "data/mailbox",
+				SMTP:   mailer.SMTPConfig{Host: "127.0.0.1", Port: 25},
+			},
+			VerifyTokenTTL: 24 * time.Hour,
+			ResetTokenTTL:  time.Hour,
+		},
+		Account: AccountConfig{
+			DeletionGrace:  7 * 24 * time.Hour,
+			DeletionPolicy: "anonymize",
+			ExportLinkTTL:  24 * time.Hour,
+			ExportDir:      "data/exports",
+		},
+		Janitor: JanitorConfig{
+			Interval:         time.Hour,
+			SessionRetention: 90 * 24 * time.Hour,
+			BatchSize:        500,
+			BatchPause:       100 * time.Millisecond,
+		},
+		Storage: storage.Config{
+			Driver: "local",
+			Local: storage.LocalConfig{
+				Root:      "static/uploads",
+				URLPrefix: "/static/uploads",
+			},
+			S3: storage.S3Config{Region: "us-east-1"},
+		},
+		Upload: UploadConfig{
+			QuotaMB:         100,
+			VIPQuotaMB:      1024,
+			OrphanGrace:     24 * time.Hour,
+			CleanupInterval: time.Hour,
+			SignedURLTTL:    15 * time.Minute,
+		},
+		RateLimit: ratelimit.Config{
+			Backend:       "memory",
+			Default:       "300/1m",
+			Login:         "10/1m",
+			TwoFactor:     "5/5m",
+			OAuthCallback: "20/1m",
+			Email:         "5/10m",
+			Register:      "5/1h",
+			Post:          "10/10m",
+			Comment:       "30/10m",
+			Upload:        "20/10m",
+		},
+		Cache: CacheConfig{
+			Config: cache.Config{Backend: "memory", MemoryMaxEntries: 10000},
+			TTL:    CacheTTL{Post: 5 * time.Minute, User: 5 * time.Minute, List: time.Minute},
+		},
+		Search: SearchConfig{
+			Engine:        "mysql",
+			IndexPath:     "data/search/index.gob",
+			FlushInterval: 30 * time.Second,
+		},
+		Log:     logger.Config{Format: "json", Level: "info"},
+		Metrics: metrics.Config{Enabled: true},
+		Tracing: tracing.Config{Exporter: "none", SampleRatio: 1, ServiceName: "lesson10"},
+	}
+}
+
+// Load 按 APP_ENV（默认 development）选 profile，配置目录由 CONFIG_DIR 指定，默认 configs；
+// 配置文件不存在时跳过，只用默认值和环境变量。Load 只负责读，检查交给 Validate
+func Load() (*Config, error) {
+	cfg := Default()
+	if env := strings.TrimSpace(os.Getenv("APP_ENV")); env != "" {
+		cfg.Env = env
+	}
+
+	dir := strings.TrimSpace(os.Getenv("CONFIG_DIR"))
+	if dir == "" {
+		dir = "configs"
+	}
+
+	for _, name := range []string{"config.yaml", "config." + cfg.Env + ".yaml"} {
+		if err := loadFile(filepath.Join(dir, name), &cfg); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := applyEnv(&cfg); err != nil {
+		return nil, err
+	}
+	if cfg.Session.IdleTimeout == 0 {
+		cfg.Session.IdleTimeout = cfg.JWT.RefreshTTL
+	}
+
+	return &cfg, nil
+}
+
+// loadFile 未知字段直接报错，拼错的配置项不会被悄悄忽略
+func loadFile(path string, cfg *Config) error {
+	raw, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	dec := yaml.NewDecoder(bytes.NewReader(raw))
+	dec.KnownFields(true)
+	if err := dec.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,136 @@
+package config
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// setRequiredEnv 数据库这些必填项不写在 yaml 里
+func setRequiredEnv(t *testing.T) {
+	t.Helper()
+
+	t.Setenv("CONFIG_DIR", "../../configs")
+	t.Setenv("DB_HOST", "127.0.0.1")
+	t.Setenv("DB_USER", "lesson10_user")
+	t.Setenv("DB_NAME", "lesson10")
+	t.Setenv("JWT_SIGNING_KEY_FILE", "data/keys/jwt.pem")
+}
+
+func TestProfilesLoadAndValidate(t *testing.T) {
+	for _, env := range []string{"development", "production"} {
+		t.Run(env, func(t *testing.T) {
+			setRequiredEnv(t)
+			t.Setenv("APP_ENV", env)
+			t.Setenv("METRICS_TOKEN", "secret")
+
+			cfg, err := Load()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := cfg.Validate(); err != nil {
+				t.Fatal(err)
+			}
+			if cfg.Janitor.BatchPause != 100*time.Millisecond || cfg.Session.History != 30*24*time.Hour {
+				t.Fatalf("janitor = %+v, session history = %v", cfg.Janitor, cfg.Session.History)
+			}
+		})
+	}
+}
+
+func TestEnvOverrides(t *testing.T) {
+	setRequiredEnv(t)
+	t.Setenv("SESSION_HISTORY_DAYS", "7")
+	t.Setenv("JANITOR_BATCH_PAUSE_MS", "250")
+	t.Setenv("LOGIN_MAX_BACKOFF_SECONDS", "60")
+	t.Setenv("SMTP_PORT", "587")
+	t.Setenv("OIDC_PROVIDERS", "Mock")
+	t.Setenv("OIDC_MOCK_ISSUER", "http://localhost:9090")
+	t.Setenv("OIDC_MOCK_CLIENT_ID", "lesson10")
+	t.Setenv("OIDC_MOCK_SCOPES", "openid,email")
+	t.Setenv("SECURITY_RULE_IP_CHANGED_BURST", "5/30m")
+	t.Setenv("SECURITY_RULE_LOGIN_FAILED_BURST", "off")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	if cfg.Session.History != 7*24*time.Hour || cfg.Janitor.BatchPause != 250*time.Millisecond || cfg.Login.MaxBackoff != time.Minute {
+		t.Fatalf("history = %v, batch pause = %v, max backoff = %v", cfg.Session.History, cfg.Janitor.BatchPause, cfg.Login.MaxBackoff)
+	}
+	if cfg.Mail.SMTP.Port != 587 {
+		t.Fatalf("smtp port = %d", cfg.Mail.SMTP.Port)
+	}
+
+	client := cfg.OAuth.Clients["mock"]
+	if !reflect.DeepEqual(cfg.OAuth.Providers, []string{"mock"}) || client.Issuer != "http://localhost:9090" || client.ClientID != "lesson10" ||
+		!reflect.DeepEqual(client.Scopes, []string{"openid", "email"}) {
+		t.Fatalf("oauth = %+v", cfg.OAuth)
+	}
+
+	rules, err := cfg.Security.RuleOverrides()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]SecurityRule{
+		"ip_changed_burst":   {Threshold: 5, Window: 30 * time.Minute},
+		"login_failed_burst": {Off: true},
+	}
+	if !reflect.DeepEqual(rules, want) {
+		t.Fatalf("rules = %+v", rules)
+	}
+}
+
+func TestValidateReportsEveryProblem(t *testing.T) {
+	setRequiredEnv(t)
+	t.Setenv("OIDC_PROVIDERS", "github")
+	t.Setenv("MAIL_DRIVER", "pigeon")
+	t.Setenv("ACCOUNT_DELETION_POLICY", "shred")
+	t.Setenv("SECURITY_RULE_IP_CHANGED_BURST", "ten/1h")
+	t.Setenv("JANITOR_SESSION_RETENTION_DAYS", "7")
+	t.Setenv("JANITOR_REFRESH_RETENTION_DAYS", "7")
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = cfg.Validate()
+	if err == nil {
+		t.Fatal("Validate accepted bad config")
+	}
+	for _, want := range []string{
+		"OIDC_GITHUB_ISSUER",
+		"OIDC_GITHUB_CLIENT_ID",
+		"MAIL_DRIVER",
+		"ACCOUNT_DELETION_POLICY",
+		"SECURITY_RULE_IP_CHANGED_BURST",
+		"janitor.session_retention must not be shorter than session.history",
+		"janitor.session_retention must not be shorter than session.remember_idle_timeout",
+		"janitor.refresh_retention must not be shorter than session.remember_idle_timeout",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error does not mention %s:\n%v", want, err)
+		}
+	}
+}
+
+func TestEnvFormatErrors(t *testing.T) {
+	setRequiredEnv(t)
+	t.Setenv("LOGIN_ACCOUNT_LOCK_THRESHOLD", "ten")
+	t.Setenv("JANITOR_INTERVAL_MINUTES", "soon")
+
+	_, err := Load()
+	if err == nil {
+		t.Fatal("Load accepted bad env values")
+	}
+	for _, want := range []string{"LOGIN_ACCOUNT_LOCK_THRESHOLD", "JANITOR_INTERVAL_MINUTES"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error does not mention %s:\n%v", want, err)
+		}
+	}
+}
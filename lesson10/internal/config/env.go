@@ -0,0 +1,153 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"lesson10/internal/pkg/oidc"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// applyEnv 用带 env 标签的字段对应的环境变量覆盖配置，变量名沿用以前直接读环境变量时的名字。
+// 时长字段填纯数字时按 unit 标签（d / h / m / s / ms）换算，也可以写 90m 这种完整格式。
+// 名字里带 provider、规则名的几组变量（OIDC_<NAME>_*、SECURITY_RULE_<NAME>）单独处理
+func applyEnv(cfg *Config) error {
+	errs := applyEnvStruct(reflect.ValueOf(cfg).Elem(), "")
+	errs = append(errs, applyOAuthEnv(&cfg.OAuth)...)
+	applySecurityRuleEnv(&cfg.Security)
+	return errors.Join(errs...)
+}
+
+// applyOAuthEnv OIDC_PROVIDERS 里列出的每个 provider 读一组 OIDC_<NAME>_ 开头的变量，如 OIDC_MOCK_CLIENT_ID
+func applyOAuthEnv(cfg *OAuthConfig) []error {
+	var errs []error
+	for i, name := range cfg.Providers {
+		name = strings.ToLower(strings.TrimSpace(name))
+		cfg.Providers[i] = name
+
+		client := cfg.Clients[name]
+		errs = append(errs, applyEnvStruct(reflect.ValueOf(&client).Elem(), "OIDC_"+strings.ToUpper(name)+"_")...)
+		if cfg.Clients == nil {
+			cfg.Clients = make(map[string]oidc.Config)
+		}
+		cfg.Clients[name] = client
+	}
+
+	return errs
+}
+
+// applySecurityRuleEnv SECURITY_RULE_<NAME>=<次数>/<窗口> 或 off，格式由 Validate 检查
+func applySecurityRuleEnv(cfg *SecurityConfig) {
+	for _, item := range os.Environ() {
+		key, raw, _ := strings.Cut(item, "=")
+		name, ok := strings.CutPrefix(key, "SECURITY_RULE_")
+		if !ok || name == "" || strings.TrimSpace(raw) == "" {
+			continue
+		}
+		if cfg.Rules == nil {
+			cfg.Rules = make(map[string]string)
+		}
+		cfg.Rules[strings.ToLower(name)] = strings.TrimSpace(raw)
+	}
+}
+
+func applyEnvStruct(v reflect.Value, prefix string) []error {
+	var errs []error
+	for i := 0; i < v.NumField(); i++ {
+		field, info := v.Field(i), v.Type().Field(i)
+		if !info.IsExported() {
+			continue
+		}
+
+		key := info.Tag.Get("env")
+		if key == "" {
+			if field.Kind() == reflect.Struct && field.Type() != durationType {
+				errs = append(errs, applyEnvStruct(field, prefix)...)
+			}
+			continue
+		}
+
+		key = prefix + key
+		raw := strings.TrimSpace(os.Getenv(key))
+		if raw == "" {
+			continue
+		}
+		if err := setField(field, raw, info.Tag.Get("unit")); err != nil {
+			errs = append(errs, fmt.Errorf("%s=%q: %w", key, raw, err))
+		}
+	}
+
+	return errs
+}
+
+func setField(field reflect.Value, raw string, unit string) error {
+	switch {
+	case field.Type() == durationType:
+		d, err := parseDuration(raw, unit)
+		if err != nil {
+			return err
+		}
+		field.SetInt(int64(d))
+	case field.Kind() == reflect.String:
+		field.SetString(raw)
+	case field.Kind() == reflect.Int:
+		n, err := strconv.Atoi(raw)
+		if err != nil {
+			return errors.New("not an integer")
+		}
+		field.SetInt(int64(n))
+	case field.Kind() == reflect.Float64:
+		f, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return errors.New("not a number")
+		}
+		field.SetFloat(f)
+	case field.Kind() == reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return errors.New("not a boolean")
+		}
+		field.SetBool(b)
+	case field.Kind() == reflect.Slice && field.Type().Elem().Kind() == reflect.String:
+		items := make([]string, 0)
+		for _, item := range strings.Split(raw, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		field.Set(reflect.ValueOf(items))
+	default:
+		return fmt.Errorf("unsupported field type %s", field.Type())
+	}
+
+	return nil
+}
+
+func parseDuration(raw string, unit string) (time.Duration, error) {
+	if n, err := strconv.Atoi(raw); err == nil {
+		switch unit {
+		case "d":
+			return time.Duration(n) * 24 * time.Hour, nil
+		case "h":
+			return time.Duration(n) * time.Hour, nil
+		case "m":
+			return time.Duration(n) * time.Minute, nil
+		case "s":
+			return time.Duration(n) * time.Second, nil
+		case "ms":
+			return time.Duration(n) * time.Millisecond, nil
+		}
+	}
+
+	d, err := time.ParseDuration(raw)
+	if err != nil {
+		return 0, errors.New("not a duration")
+	}
+
+	return d, nil
+}
@@ -2,37 +2,28 @@ package config
 
 import (
 	"fmt"
-	"log"
-	"os"
+	"lesson10/internal/pkg/dbtrace"
 
 	"gorm.io/driver/mysql"
 	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
 )
 
-var DB *gorm.DB
-
-func InitDB() {
-	host := os.Getenv("DB_HOST")
-	port := os.Getenv("DB_PORT")
-	user := os.Getenv("DB_USER")
-	pass := os.Getenv("DB_PASS")
-	name := os.Getenv("DB_NAME")
-
-	dsn := fmt.Sprintf("%s:%s@tcp(%s:%s)/%s?charset=utf8mb4&parseTime=true&loc=Local",
-		user, pass, host, port, name,
-	)
-
-	db, err := gorm.Open(mysql.Open(dsn), &gorm.Config{})
-
+// OpenDB 连接 MySQL，返回的 *gorm.DB 由 main 传给各个 repository
+func OpenDB(cfg DBConfig) (*gorm.DB, error) {
+	// 错误和慢查询由 dbtrace 按结构化日志输出，GORM 自带的彩色文本日志关掉
+	db, err := gorm.Open(mysql.Open(cfg.DSN()), &gorm.Config{Logger: logger.Discard})
 	if err != nil {
-		log.Fatal("can not connect mysql", err)
+		return nil, fmt.Errorf("connect mysql: %w", err)
+	}
+	if err := db.Use(dbtrace.Plugin{}); err != nil {
+		return nil, fmt.Errorf("register dbtrace plugin: %w", err)
 	}
 
-	DB = db
-
+	return db, nil
 }
 
-func CleanupPolymorphicTargetConstraints() error {
+func CleanupPolymorphicTargetConstraints(db *gorm.DB) error {
 	constraints := []struct {
 		table string
 		name  string
@@ -44,7 +35,7 @@ func CleanupPolymorphicTargetConstraints() error {
 	}
 
 	for _, constraint := range constraints {
-		if err := dropForeignKeyIfExists(DB, constraint.table, constraint.name); err != nil {
+		if err := dropForeignKeyIfExists(db, constraint.table, constraint.name); err != nil {
 			return err
 		}
 	}
@@ -0,0 +1,11 @@
+package config
+
+import "github.com/redis/go-redis/v9"
+
+func NewRedis(cfg RedisConfig) *redis.Client {
+	return redis.NewClient(&redis.Options{
+		Addr:     cfg.Addr,
+		Password: cfg.Password,
+		DB:       cfg.DB,
+	})
+}
@@ -0,0 +1,275 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"lesson10/internal/pkg/logger"
+	"lesson10/internal/pkg/ratelimit"
+	"net/url"
+	"strings"
+)
+
+// Validate 一次列出所有问题，服务启动前调用，有错就拒绝启动
+func (c *Config) Validate() error {
+	errs := []error{c.DB.Validate()}
+	add := func(format string, args ...any) {
+		errs = append(errs, fmt.Errorf(format, args...))
+	}
+
+	if c.Server.Port <= 0 || c.Server.Port > 65535 {
+		add("server.port (APP_PORT) must be between 1 and 65535")
+	}
+	if !isHTTPURL(c.Server.BaseURL) {
+		add("server.base_url (APP_BASE_URL) must be an http(s) url")
+	}
+	if c.Server.StaticDir == "" {
+		add("server.static_dir (STATIC_DIR) is required")
+	}
+	if len(c.Server.CORSOrigins) == 0 {
+		add("server.cors_origins (CORS_ALLOW_ORIGINS) is required")
+	}
+	for _, origin := range c.Server.CORSOrigins {
+		// 跨域请求要带 cookie，不能用 *
+		if !isHTTPURL(origin) {
+			add("server.cors_origins: %q is not an http(s) origin", origin)
+		}
+	}
+
+	if c.Server.ShutdownTimeout <= 0 {
+		add("server.shutdown_timeout (SHUTDOWN_TIMEOUT_SECONDS) must be positive")
+	}
+	if c.Server.DrainDelay < 0 {
+		add("server.drain_delay (SHUTDOWN_DRAIN_SECONDS) must not be negative")
+	}
+
+	if c.Redis.Addr == "" {
+		add("redis.addr (REDIS_ADDR) is required")
+	}
+
+	if c.JWT.SigningKeyFile == "" {
+		add("jwt.signing_key_file (JWT_SIGNING_KEY_FILE) is required, generate one with `go run ./cmd/jwtkey`")
+	}
+	if c.JWT.AccessTTL <= 0 || c.JWT.RefreshTTL <= 0 {
+		add("jwt.access_ttl and jwt.refresh_ttl must be positive")
+	} else if c.JWT.RefreshTTL < c.JWT.AccessTTL {
+		add("jwt.refresh_ttl must not be shorter than jwt.access_ttl")
+	}
+
+	if c.Session.IdleTimeout <= 0 || c.Session.MaxLifetime <= 0 || c.Session.RememberIdleTimeout <= 0 || c.Session.RememberMaxLifetime <= 0 {
+		add("session.idle_timeout, max_lifetime, remember_idle_timeout and remember_max_lifetime must be positive")
+	}
+	if c.Session.MaxActive <= 0 {
+		add("session.max_active (SESSION_MAX_ACTIVE) must be positive")
+	}
+	if _, err := c.Session.DeviceTypeLimits(); err != nil {
+		errs = append(errs, err)
+	}
+	if c.Session.LimitMode != "evict" && c.Session.LimitMode != "reject" {
+		add("session.limit_mode (SESSION_LIMIT_MODE) must be evict or reject, got %q", c.Session.LimitMode)
+	}
+	if c.Session.History <= 0 {
+		add("session.history (SESSION_HISTORY_DAYS) must be positive")
+	}
+
+	if c.Login.AccountFreeAttempts < 0 || c.Login.IPFreeAttempts < 0 || c.Login.LockThreshold < 0 {
+		add("login.account_free_attempts, login.ip_free_attempts and login.lock_threshold must not be negative")
+	}
+	if c.Login.MaxBackoff <= 0 || c.Login.LockDuration <= 0 || c.Login.FailureWindow <= 0 {
+		add("login.max_backoff, login.lock_duration and login.failure_window must be positive")
+	}
+
+	if c.TwoFactor.Issuer == "" {
+		add("two_factor.issuer (TOTP_ISSUER) is required")
+	}
+
+	for _, name := range c.OAuth.Providers {
+		client := c.OAuth.Clients[name]
+		prefix := "OIDC_" + strings.ToUpper(name) + "_"
+		if !isHTTPURL(client.Issuer) {
+			add("oauth.clients.%s.issuer (%sISSUER) must be an http(s) url", name, prefix)
+		}
+		if client.ClientID == "" {
+			add("oauth.clients.%s.client_id (%sCLIENT_ID) is required", name, prefix)
+		}
+		if client.RedirectURL != "" && !isHTTPURL(client.RedirectURL) {
+			add("oauth.clients.%s.redirect_url (%sREDIRECT_URL) must be an http(s) url", name, prefix)
+		}
+	}
+
+	switch c.Mail.Driver {
+	case "file":
+		if c.Mail.Dir == "" {
+			add("mail.dir (MAIL_DIR) is required for the file driver")
+		}
+	case "smtp":
+		if c.Mail.SMTP.Host == "" {
+			add("mail.smtp.host (SMTP_HOST) is required for the smtp driver")
+		}
+		if c.Mail.SMTP.Port <= 0 || c.Mail.SMTP.Port > 65535 {
+			add("mail.smtp.port (SMTP_PORT) must be between 1 and 65535")
+		}
+	default:
+		add("mail.driver (MAIL_DRIVER) must be file or smtp, got %q", c.Mail.Driver)
+	}
+	if c.Mail.From == "" {
+		add("mail.from (MAIL_FROM) is required")
+	}
+	if c.Mail.VerifyTokenTTL <= 0 || c.Mail.ResetTokenTTL <= 0 {
+		add("mail.verify_token_ttl and mail.reset_token_ttl must be positive")
+	}
+
+	if c.Account.DeletionGrace <= 0 || c.Account.ExportLinkTTL <= 0 {
+		add("account.deletion_grace and account.export_link_ttl must be positive")
+	}
+	if c.Account.DeletionPolicy != "anonymize" && c.Account.DeletionPolicy != "remove" {
+		add("account.deletion_policy (ACCOUNT_DELETION_POLICY) must be anonymize or remove, got %q", c.Account.DeletionPolicy)
+	}
+	if c.Account.ExportDir == "" {
+		add("account.export_dir (DATA_EXPORT_DIR) is required")
+	}
+
+	if _, err := c.Security.RuleOverrides(); err != nil {
+		errs = append(errs, err)
+	}
+
+	if c.Janitor.Interval <= 0 || c.Janitor.SessionRetention <= 0 || c.Janitor.BatchSize <= 0 {
+		add("janitor.interval, janitor.session_retention and janitor.batch_size must be positive")
+	}
+	if c.Janitor.BatchPause < 0 {
+		add("janitor.batch_pause (JANITOR_BATCH_PAUSE_MS) must not be negative")
+	}
+	if c.Janitor.SessionRetention < c.Session.History {
+		add("janitor.session_retention must not be shorter than session.history")
+	}
+	if c.Janitor.SessionRetention < c.Session.RememberIdleTimeout {
+		add("janitor.session_retention must not be shorter than session.remember_idle_timeout")
+	}
+	if c.Janitor.RefreshRetention < 0 {
+		add("janitor.refresh_retention (JANITOR_REFRESH_RETENTION_DAYS) must not be negative")
+	}
+	if c.RefreshRetention() < c.Session.RememberIdleTimeout {
+		add("janitor.refresh_retention must not be shorter than session.remember_idle_timeout")
+	}
+
+	switch c.Storage.Driver {
+	case "local":
+		if c.Storage.Local.Root == "" {
+			add("storage.local.root (STORAGE_LOCAL_ROOT) is required")
+		}
+	case "s3":
+		s3 := c.Storage.S3
+		if s3.Endpoint == "" || s3.Bucket == "" || s3.AccessKey == "" || s3.SecretKey == "" {
+			add("storage.s3 needs endpoint, bucket, access_key and secret_key (S3_ENDPOINT, S3_BUCKET, S3_ACCESS_KEY, S3_SECRET_KEY)")
+		}
+	default:
+		add("storage.driver (STORAGE_DRIVER) must be local or s3, got %q", c.Storage.Driver)
+	}
+
+	if c.Upload.QuotaMB <= 0 || c.Upload.VIPQuotaMB <= 0 {
+		add("upload.quota_mb and upload.vip_quota_mb must be positive")
+	}
+	if c.Upload.OrphanGrace <= 0 || c.Upload.CleanupInterval <= 0 || c.Upload.SignedURLTTL <= 0 {
+		add("upload.orphan_grace, upload.cleanup_interval and upload.signed_url_ttl must be positive")
+	}
+
+	if c.RateLimit.Backend != "memory" && c.RateLimit.Backend != "redis" {
+		add("rate_limit.backend (RATE_LIMIT_BACKEND) must be memory or redis, got %q", c.RateLimit.Backend)
+	}
+	if _, err := ratelimit.LoadPolicies(c.RateLimit); err != nil {
+		errs = append(errs, err)
+	}
+
+	switch c.Cache.Backend {
+	case "memory", "redis", "off":
+	default:
+		add("cache.backend (CACHE_BACKEND) must be memory, redis or off, got %q", c.Cache.Backend)
+	}
+	if c.Cache.MemoryMaxEntries <= 0 {
+		add("cache.memory_max_entries (CACHE_MEMORY_MAX_ENTRIES) must be positive")
+	}
+	if c.Cache.TTL.Post <= 0 || c.Cache.TTL.User <= 0 || c.Cache.TTL.List <= 0 {
+		add("cache.ttl.post, cache.ttl.user and cache.ttl.list must be positive")
+	}
+
+	switch c.Search.Engine {
+	case "mysql":
+	case "embedded":
+		if c.Search.IndexPath == "" {
+			add("search.index_path (SEARCH_INDEX_PATH) is required for the embedded engine")
+		}
+	default:
+		add("search.engine (SEARCH_ENGINE) must be mysql or embedded, got %q", c.Search.Engine)
+	}
+	if c.Search.FlushInterval <= 0 {
+		add("search.flush_interval (SEARCH_FLUSH_INTERVAL_SECONDS) must be positive")
+	}
+
+	switch c.Log.Format {
+	case "json", "text":
+	default:
+		add("log.format (LOG_FORMAT) must be json or text, got %q", c.Log.Format)
+	}
+	if _, err := logger.ParseLevel(c.Log.Level); err != nil {
+		add("log.level (LOG_LEVEL): %v", err)
+	}
+
+	switch c.Tracing.Exporter {
+	case "none", "stdout":
+	case "otlp":
+		if !isHTTPURL(c.Tracing.Endpoint) {
+			add("tracing.endpoint (TRACING_OTLP_ENDPOINT) must be an http(s) url for the otlp exporter")
+		}
+	default:
+		add("tracing.exporter (TRACING_EXPORTER) must be none, stdout or otlp, got %q", c.Tracing.Exporter)
+	}
+	if c.Tracing.SampleRatio < 0 || c.Tracing.SampleRatio > 1 {
+		add("tracing.sample_ratio (TRACING_SAMPLE_RATIO) must be between 0 and 1")
+	}
+	if c.Tracing.ServiceName == "" {
+		add("tracing.service_name (TRACING_SERVICE_NAME) is required")
+	}
+
+	if c.Env == "production" {
+		if c.DB.AutoMigrate {
+			add("db.auto_migrate (DB_AUTO_MIGRATE) must be off in production, use `go run ./cmd/migrate up`")
+		}
+		// 指标里有路由、登录失败次数这些信息，生产环境不对外裸露
+		if c.Metrics.Enabled && c.Metrics.Token == "" {
+			add("metrics.token (METRICS_TOKEN) is required in production when metrics are enabled")
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// Validate 只检查连库需要的配置，迁移、清理这些命令行工具用
+func (c DBConfig) Validate() error {
+	var missing []string
+	for _, item := range []struct {
+		name  string
+		value string
+	}{
+		{"db.host (DB_HOST)", c.Host},
+		{"db.user (DB_USER)", c.User},
+		{"db.name (DB_NAME)", c.Name},
+	} {
+		if item.value == "" {
+			missing = append(missing, item.name)
+		}
+	}
+
+	var errs []error
+	if len(missing) > 0 {
+		errs = append(errs, fmt.Errorf("%s is required (check .env.local/.env)", strings.Join(missing, ", ")))
+	}
+	if c.Port <= 0 || c.Port > 65535 {
+		errs = append(errs, errors.New("db.port (DB_PORT) must be between 1 and 65535"))
+	}
+
+	return errors.Join(errs...)
+}
+
+func isHTTPURL(raw string) bool {
+	u, err := url.Parse(raw)
+	return err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
@@ -2,9 +2,11 @@ package dto
 
 import "lesson10/internal/model"
 
+// 注册、修改密码和重置密码用同一套密码规则：6~72 个字符（bcrypt 只取前 72 字节）
 type RegisterRequest struct {
 	Username string `json:"username" binding:"required"`
-	Password string `json:"password" binding:"required"`
+	Password string `json:"password" binding:"required,min=6,max=72"`
+	Email    string `json:"email" binding:"omitempty,email,max=191"`
 }
 
 type LoginRequest struct {
@@ -12,11 +14,60 @@ type LoginRequest struct {
 	Password   string `json:"password"`
 	DeviceID   string `json:"device_id"`
 	DeviceName string `json:"device_name"`
+	RememberMe bool   `json:"remember_me"`
+}
+
+type TwoFactorLoginRequest struct {
+	ChallengeToken string `json:"challenge_token" binding:"required"`
+	Code           string `json:"code" binding:"required"` // 6 位验证码或恢复码
+}
+
+type TwoFactorSetupRequest struct {
+	Password string `json:"password"`
+}
+
+type TwoFactorCodeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+// TwoFactorDisableRequest 关闭两步验证和重新生成恢复码都用它
+type TwoFactorDisableRequest struct {
+	Password string `json:"password"`
+	Code     string `json:"code" binding:"required"`
+}
+
+type OAuthBeginQuery struct {
+	DeviceID   string `form:"device_id"`
+	DeviceName string `form:"device_name"`
+	RememberMe bool   `form:"remember_me"`
+}
+
+type OAuthCallbackRequest struct {
+	Code  string `json:"code" binding:"required"`
+	State string `json:"state" binding:"required"`
+}
+
+type VerifyEmailRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+type ChangeEmailRequest struct {
+	Email    string `json:"email" binding:"required,email,max=191"`
+	Password string `json:"password"`
+}
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+type ResetPasswordRequest struct {
+	Token       string `json:"token" binding:"required"`
+	NewPassword string `json:"new_password" binding:"required,min=6,max=72"`
 }
 
 type ChangePassRequest struct {
 	OldPass string `json:"old_pass"`
-	NewPass string `json:"new_pass"`
+	NewPass string `json:"new_pass" binding:"required,min=6,max=72"`
 }
 
 type UpdateProfileRequest struct {
@@ -72,10 +123,46 @@ type RefreshRequest struct {
 }
 
 type LogoutAllRequest struct {
-	Password string `json:"password" binding:"required"`
+	Password string `json:"password"`
 }
 
 type RevokeSessionRequest struct {
 	SessionID string `json:"session_id" binding:"required"`
-	Password  string `json:"password" binding:"required"`
+	Password  string `json:"password"`
+}
+
+type RenameSessionRequest struct {
+	Label string `json:"label" binding:"max=64"`
+}
+
+type ListSessionsQuery struct {
+	IncludeRevoked bool `form:"include_revoked"`
+}
+
+type SecurityEventQuery struct {
+	Page     int    `form:"page" binding:"omitempty,min=1"`
+	PageSize int    `form:"size" binding:"omitempty,min=1,max=100"`
+	Type     string `form:"type"`
+	UserID   int64  `form:"user_id"` // 仅管理员接口生效
+	From     string `form:"from"`    // YYYY-MM-DD
+	To       string `form:"to"`      // YYYY-MM-DD，包含当天
+}
+
+type AdminStatsQuery struct {
+	From  string `form:"from"`  // YYYY-MM-DD，默认 30 天前
+	To    string `form:"to"`    // YYYY-MM-DD，包含当天，默认今天
+	Limit int    `form:"limit"` // top authors 数量，默认 10
+	Type  string `form:"type"`  // 导出类型：daily / top_authors / security_events
+}
+
+type DeleteAccountRequest struct {
+	Password string `json:"password"`
+}
+
+type SearchQuery struct {
+	Q    string `form:"q"`
+	Type string `form:"type"` // post / comment / user，不传搜全部
+	Sort string `form:"sort"` // relevance（默认）/ newest
+	Page int    `form:"page" binding:"omitempty,min=1"`
+	Size int    `form:"size" binding:"omitempty,min=1,max=50"`
 }
@@ -2,6 +2,7 @@ package dto
 
 import (
 	"lesson10/internal/model"
+	"lesson10/internal/pkg/search"
 	"time"
 )
 
@@ -56,7 +57,8 @@ type PostDetailResp struct {
 	AuthorAvatarURL string
 	Title           string
 	Content         string `json:"content" binding:"required"`
-	Status          uint8  `json:"status"` // 0=发布 1=草稿
+	ContentHTML     string `json:"content_html"` // 服务端渲染并过滤过的 HTML，Content 保留原始 markdown 供编辑
+	Status          uint8  `json:"status"`       // 0=发布 1=草稿
 	LikeCount       uint
 	CreatedAt       time.Time
 	UpdatedAt       time.Time
@@ -69,6 +71,7 @@ type PostListItem struct {
 	AuthorName      string
 	AuthorAvatarURL string
 	Title           string
+	Excerpt         string    `json:"excerpt"` // 正文纯文本的前 160 个字符
 	CreatedAt       time.Time `json:"created_at"`
 	UpdatedAt       time.Time
 }
@@ -118,15 +121,349 @@ type TokenPair struct {
 	RefreshExpiresAt int64  `thrift:"refresh_expires_at,5" frugal:"5,default,i64" json:"refresh_expires_at"`
 }
 
+type LoginChallenge struct {
+	ChallengeToken string `json:"challenge_token"`
+	ExpiresAt      int64  `json:"expires_at"`
+}
+
+type TwoFactorSetup struct {
+	Secret     string `json:"secret"`
+	OtpauthURI string `json:"otpauth_uri"`
+}
+
+type TwoFactorStatus struct {
+	Enabled           bool  `json:"enabled"`
+	EnabledAt         int64 `json:"enabled_at,omitempty"`
+	RecoveryCodesLeft int64 `json:"recovery_codes_left"`
+}
+
+type OAuthAuthorize struct {
+	AuthorizeURL string `json:"authorize_url"`
+	State        string `json:"state"` // 前端回调时原样带回，并与 URL 中的 state 比对
+	ExpiresAt    int64  `json:"expires_at"`
+}
+
+type IdentityInfo struct {
+	Provider  string `json:"provider"`
+	Subject   string `json:"subject"`
+	Email     string `json:"email,omitempty"`
+	CreatedAt int64  `json:"created_at"`
+}
+
 type SessionInfo struct {
-	SessionID  string `json:"session_id"`
-	DeviceID   string `json:"device_id"`
-	DeviceName string `json:"device_name"`
-	UserAgent  string `json:"user_agent"`
-	LoginIP    string `json:"login_ip"`
-	LastIP     string `json:"last_ip"`
-	Status     string `json:"status"`
-	Current    bool   `json:"current"`
-	CreatedAt  int64  `json:"created_at"`
-	LastSeenAt int64  `json:"last_seen_at"`
+	SessionID      string `json:"session_id"`
+	DeviceID       string `json:"device_id"`
+	DeviceName     string `json:"device_name"`
+	Label          string `json:"label"`
+	UserAgent      string `json:"user_agent"`
+	BrowserName    string `json:"browser_name"`
+	BrowserVersion string `json:"browser_version"`
+	OSName         string `json:"os_name"`
+	DeviceType     string `json:"device_type"`
+	LoginIP        string `json:"login_ip"`
+	LastIP         string `json:"last_ip"`
+	Location       string `json:"location"`
+	Status         string `json:"status"`
+	RememberMe     bool   `json:"remember_me"`
+	RevokeReason   string `json:"revoke_reason,omitempty"`
+	RevokedAt      *int64 `json:"revoked_at,omitempty"`
+	ExpiresAt      *int64 `json:"expires_at,omitempty"`
+	Current        bool   `json:"current"`
+	CreatedAt      int64  `json:"created_at"`
+	LastSeenAt     int64  `json:"last_seen_at"`
+}
+
+type SecurityEventItem struct {
+	ID        int64  `json:"id"`
+	UserID    int64  `json:"user_id"`
+	SessionID string `json:"session_id"`
+	EventType string `json:"event_type"`
+	IP        string `json:"ip"`
+	Location  string `json:"location"`
+	DeviceID  string `json:"device_id"`
+	UserAgent string `json:"user_agent"`
+	Detail    string `json:"detail"`
+	CreatedAt int64  `json:"created_at"`
+}
+
+type SecurityEventList struct {
+	Events []SecurityEventItem `json:"events"`
+	Total  int64               `json:"total"`
+	Page   int                 `json:"page"`
+	Size   int                 `json:"size"`
+}
+
+type DailyCount struct {
+	Day   string `json:"day"`
+	Count int64  `json:"count"`
+}
+
+type DailyStat struct {
+	Day         string `json:"day"`
+	ActiveUsers int64  `json:"active_users"`
+	NewUsers    int64  `json:"new_users"`
+	Posts       int64  `json:"posts"`
+	Comments    int64  `json:"comments"`
+	Reactions   int64  `json:"reactions"`
+}
+
+type TopAuthorItem struct {
+	AuthorID   uint   `json:"author_id"`
+	AuthorName string `json:"author_name"`
+	PostCount  int64  `json:"post_count"`
+	LikeCount  int64  `json:"like_count"`
+}
+
+type SecurityEventCount struct {
+	EventType string `json:"event_type"`
+	Count     int64  `json:"count"`
+	UserCount int64  `json:"user_count"`
+}
+
+type AdminStatsResp struct {
+	From           string               `json:"from"`
+	To             string               `json:"to"`
+	Daily          []DailyStat          `json:"daily"`
+	TopAuthors     []TopAuthorItem      `json:"top_authors"`
+	SecurityEvents []SecurityEventCount `json:"security_events"`
+}
+
+type DataExportInfo struct {
+	ID          int64  `json:"id"`
+	Status      string `json:"status"`
+	FileSize    int64  `json:"file_size"`
+	DownloadURL string `json:"download_url,omitempty"` // 只在创建时返回一次
+	CreatedAt   int64  `json:"created_at"`
+	CompletedAt int64  `json:"completed_at,omitempty"`
+	ExpiresAt   int64  `json:"expires_at"`
+}
+
+type AccountDeletionInfo struct {
+	Status      string `json:"status"`
+	Policy      string `json:"policy"`
+	ScheduledAt int64  `json:"scheduled_at"`
+	CreatedAt   int64  `json:"created_at"`
+}
+
+type ExportProfile struct {
+	ID           uint       `json:"id"`
+	Username     string     `json:"username"`
+	AvatarURL    string     `json:"avatar_url,omitempty"`
+	Profile      string     `json:"profile,omitempty"`
+	Role         model.Role `json:"role"`
+	VIPExpiresAt *time.Time `json:"vip_expires_at,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
+}
+
+type ExportFollow struct {
+	UserID    uint      `json:"user_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type ExportFollows struct {
+	Following []ExportFollow `json:"following"`
+	Followers []ExportFollow `json:"followers"`
+}
+
+type JanitorReport struct {
+	DryRun               bool   `json:"dry_run"`
+	RefreshTokens        int64  `json:"refresh_tokens"`
+	Sessions             int64  `json:"sessions"`
+	SessionRefreshTokens int64  `json:"session_refresh_tokens"`
+	Batches              int    `json:"batches"`
+	StartedAt            int64  `json:"started_at"`
+	DurationMs           int64  `json:"duration_ms"`
+	Error                string `json:"error,omitempty"`
+}
+
+type JanitorStats struct {
+	Runs                 int64          `json:"runs"`
+	Failures             int64          `json:"failures"`
+	RefreshTokensDeleted int64          `json:"refresh_tokens_deleted"`
+	SessionsDeleted      int64          `json:"sessions_deleted"`
+	LastRun              *JanitorReport `json:"last_run,omitempty"`
+}
+
+type UploadedImage struct {
+	ID       uint                 `json:"id"`
+	Kind     string               `json:"kind"`
+	URL      string               `json:"url"`
+	Hash     string               `json:"hash"`
+	Width    int                  `json:"width"`
+	Height   int                  `json:"height"`
+	Variants []model.ImageVariant `json:"variants"`
+}
+
+type UploadQuota struct {
+	Used  int64 `json:"used"`
+	Limit int64 `json:"limit"`
+	IsVIP bool  `json:"is_vip"`
+}
+
+type UploadCleanupReport struct {
+	DryRun     bool   `json:"dry_run"`
+	Images     int64  `json:"images"`
+	Files      int64  `json:"files"`
+	Bytes      int64  `json:"bytes"`
+	Batches    int    `json:"batches"`
+	StartedAt  int64  `json:"started_at"`
+	DurationMs int64  `json:"duration_ms"`
+	Error      string `json:"error,omitempty"`
+}
+
+type SignedImageURL struct {
+	URL       string `json:"url"`
+	ExpiresAt int64  `json:"expires_at"`
+}
+
+// SearchResp Facets 是不按 type 过滤时各类型的命中数，Total 是当前 type 下的命中数
+type SearchResp struct {
+	Hits   []search.Hit     `json:"hits"`
+	Total  int64            `json:"total"`
+	Facets map[string]int64 `json:"facets"`
+	Page   int              `json:"page"`
+	Size   int              `json:"size"`
+}
+
+// Readiness Checks 是每个依赖的检查结果，正常为 ok，否则是错误信息
+type Readiness struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks"`
+}
+
+// Liveness /healthz 的响应，status 固定为 ok
+type Liveness struct {
+	Status string `json:"status"`
+}
+
+// OKResp 只表示操作成功的接口统一返回 {"ok": true}
+type OKResp struct {
+	OK bool `json:"ok"`
+}
+
+type RegisterResp struct {
+	UserID   uint   `json:"user_id"`
+	Username string `json:"username"`
+}
+
+// LoginResp 开启两步验证的账号只返回前三个字段，用 challenge_token 调 /login/2fa 完成登录
+type LoginResp struct {
+	TwoFactorRequired  bool   `json:"two_factor_required,omitempty"`
+	ChallengeToken     string `json:"challenge_token,omitempty"`
+	ChallengeExpiresAt int64  `json:"challenge_expires_at,omitempty"`
+	UserID             uint   `json:"user_id,omitempty"`
+	Username           string `json:"username,omitempty"`
+	Token              string `json:"token,omitempty"`
+	RefreshToken       string `json:"refresh_token,omitempty"`
+	SessionID          string `json:"session_id,omitempty"`
+	DeviceID           string `json:"device_id,omitempty"`
+	AccessExpiresAt    int64  `json:"access_expires_at,omitempty"`
+	RefreshExpiresAt   int64  `json:"refresh_expires_at,omitempty"`
+}
+
+type ChangePassResp struct {
+	OK            bool `json:"ok"`
+	NeedRelogin   bool `json:"need_relogin"`
+	SessionsReset bool `json:"sessions_reset"`
+}
+
+type SessionListResp struct {
+	Sessions []SessionInfo `json:"sessions"`
+}
+
+type AvatarUploadResp struct {
+	AvatarURL string         `json:"avatar_url"` // medium 尺寸的地址，没有时用原图
+	Image     *UploadedImage `json:"image"`
+}
+
+type ArticleImageUploadResp struct {
+	ImageURL string         `json:"image_url"`
+	Image    *UploadedImage `json:"image"`
+}
+
+type CreatePostResp struct {
+	OK   bool        `json:"ok"`
+	Post *model.Post `json:"post"`
+}
+
+type PostListResp struct {
+	List     []PostListItem `json:"list"`
+	Total    int64          `json:"total"`
+	Page     int            `json:"page"`
+	PageSize int            `json:"page_size"`
+}
+
+type UpdatePostResp struct {
+	OK      bool   `json:"ok"`
+	Message string `json:"message"`
+}
+
+type DeletePostResp struct {
+	Message string `json:"message"`
+}
+
+type CreateCommentResp struct {
+	Comment *model.Comment `json:"comment"`
+}
+
+type ReplyListResp struct {
+	Replies []CommentItem `json:"replies"`
+	Total   int64         `json:"total"`
+}
+
+type FollowListResp struct {
+	Users []FollowUserInfo `json:"users"`
+	Total int64            `json:"total"`
+	Page  int              `json:"page"`
+	Size  int              `json:"size"`
+}
+
+// ReactionStatusResp Status 是操作后的点赞状态
+type ReactionStatusResp struct {
+	Status bool `json:"status"`
+}
+
+type FavoriteStatusResp struct {
+	IsFavorited bool `json:"is_favorited"`
+}
+
+type FavoriteListResp struct {
+	Favorites []FavoriteItem `json:"favorites"`
+	Total     int64          `json:"total"`
+	Page      int            `json:"page"`
+	Size      int            `json:"size"`
+}
+
+type DraftListResp struct {
+	Drafts []PostListItem `json:"drafts"`
+	Total  int64          `json:"total"`
+	Page   int            `json:"page"`
+	Size   int            `json:"size"`
+}
+
+type NotificationListResp struct {
+	Notifications []NotificationItem `json:"notifications"`
+	Total         int64              `json:"total"`
+	Page          int                `json:"page"`
+	Size          int                `json:"size"`
+}
+
+type UnreadCountResp struct {
+	Count int64 `json:"count"`
+}
+
+type DataExportListResp struct {
+	Exports []DataExportInfo `json:"exports"`
+}
+
+type RecoveryCodesResp struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+type OAuthProvidersResp struct {
+	Providers []string `json:"providers"`
+}
+
+type IdentityListResp struct {
+	Identities []IdentityInfo `json:"identities"`
 }
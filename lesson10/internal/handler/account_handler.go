@@ -0,0 +1,88 @@
+package handler
+
+import (
+	"fmt"
+	"lesson10/internal/dto"
+	"lesson10/internal/pkg/response"
+	"lesson10/internal/service"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+func RequestDataExportHandler(accountSvc *service.AccountService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		export, err := accountSvc.RequestExport(c.Request.Context(), c.GetUint("user_id"))
+		if err != nil {
+			_ = c.Error(err)
+			return
+		}
+
+		response.JSON(c, http.StatusAccepted, "export scheduled", export)
+	}
+}
+
+func ListDataExportsHandler(accountSvc *service.AccountService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		exports, err := accountSvc.ListExports(c.Request.Context(), c.GetUint("user_id"))
+		if err != nil {
+			_ = c.Error(err)
+			return
+		}
+
+		response.OK(c, dto.DataExportListResp{Exports: exports})
+	}
+}
+
+func DownloadDataExportHandler(accountSvc *service.AccountService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		export, err := accountSvc.OpenExport(c.Request.Context(), c.Query("token"))
+		if err != nil {
+			_ = c.Error(err)
+			return
+		}
+
+		c.FileAttachment(export.FilePath, fmt.Sprintf("account_export_%d.zip", export.ID))
+	}
+}
+
+func RequestAccountDeletionHandler(accountSvc *service.AccountService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req dto.DeleteAccountRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			_ = c.Error(invalidRequest(err))
+			return
+		}
+
+		deletion, err := accountSvc.RequestDeletion(c.Request.Context(), c.GetUint("user_id"), req.Password)
+		if err != nil {
+			_ = c.Error(err)
+			return
+		}
+
+		response.OK(c, deletion)
+	}
+}
+
+func GetAccountDeletionHandler(accountSvc *service.AccountService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		deletion, err := accountSvc.GetDeletion(c.Request.Context(), c.GetUint("user_id"))
+		if err != nil {
+			_ = c.Error(err)
+			return
+		}
+
+		response.OK(c, deletion)
+	}
+}
+
+func CancelAccountDeletionHandler(accountSvc *service.AccountService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if err := accountSvc.CancelDeletion(c.Request.Context(), c.GetUint("user_id")); err != nil {
+			_ = c.Error(err)
+			return
+		}
+
+		response.OK(c, dto.OKResp{OK: true})
+	}
+}
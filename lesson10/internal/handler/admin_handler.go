@@ -0,0 +1,167 @@
+package handler
+
+import (
+	"encoding/csv"
+	"fmt"
+	"lesson10/internal/dto"
+	"lesson10/internal/pkg/errcode"
+	"lesson10/internal/pkg/response"
+	"lesson10/internal/service"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+func AdminStatsHandler(adminSvc *service.AdminService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var q dto.AdminStatsQuery
+		if err := c.ShouldBindQuery(&q); err != nil {
+			_ = c.Error(invalidRequest(err))
+			return
+		}
+
+		resp, err := adminSvc.Overview(c.Request.Context(), q)
+		if err != nil {
+			_ = c.Error(err)
+			return
+		}
+
+		response.OK(c, resp)
+	}
+}
+
+func ExportAdminStatsHandler(adminSvc *service.AdminService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var q dto.AdminStatsQuery
+		if err := c.ShouldBindQuery(&q); err != nil {
+			_ = c.Error(invalidRequest(err))
+			return
+		}
+
+		from, to, err := adminSvc.StatsRange(q)
+		if err != nil {
+			_ = c.Error(err)
+			return
+		}
+
+		ctx := c.Request.Context()
+		var rows [][]string
+		exportType := q.Type
+		if exportType == "" {
+			exportType = "daily"
+		}
+
+		switch exportType {
+		case "daily":
+			daily, err := adminSvc.DailyStats(ctx, from, to)
+			if err != nil {
+				_ = c.Error(err)
+				return
+			}
+			rows = append(rows, []string{"day", "active_users", "new_users", "posts", "comments", "reactions"})
+			for _, d := range daily {
+				rows = append(rows, []string{
+					d.Day,
+					strconv.FormatInt(d.ActiveUsers, 10),
+					strconv.FormatInt(d.NewUsers, 10),
+					strconv.FormatInt(d.Posts, 10),
+					strconv.FormatInt(d.Comments, 10),
+					strconv.FormatInt(d.Reactions, 10),
+				})
+			}
+		case "top_authors":
+			authors, err := adminSvc.TopAuthors(ctx, from, to, q.Limit)
+			if err != nil {
+				_ = c.Error(err)
+				return
+			}
+			rows = append(rows, []string{"author_id", "author_name", "post_count", "like_count"})
+			for _, a := range authors {
+				rows = append(rows, []string{
+					strconv.FormatUint(uint64(a.AuthorID), 10),
+					csvCell(a.AuthorName),
+					strconv.FormatInt(a.PostCount, 10),
+					strconv.FormatInt(a.LikeCount, 10),
+				})
+			}
+		case "security_events":
+			events, err := adminSvc.SecurityEventBreakdown(ctx, from, to)
+			if err != nil {
+				_ = c.Error(err)
+				return
+			}
+			rows = append(rows, []string{"event_type", "count", "user_count"})
+			for _, e := range events {
+				rows = append(rows, []string{
+					csvCell(e.EventType),
+					strconv.FormatInt(e.Count, 10),
+					strconv.FormatInt(e.UserCount, 10),
+				})
+			}
+		default:
+			_ = c.Error(invalidParam("type"))
+			return
+		}
+
+		filename := fmt.Sprintf("%s_%s_%s.csv", exportType, from.Format("20060102"), to.AddDate(0, 0, -1).Format("20060102"))
+		c.Header("Content-Type", "text/csv; charset=utf-8")
+		c.Header("Content-Disposition", "attachment; filename="+filename)
+		c.Status(http.StatusOK)
+
+		w := csv.NewWriter(c.Writer)
+		_ = w.WriteAll(rows)
+	}
+}
+
+// csvCell 用户能控制的文本以 = + - @ 开头时 Excel 会当公式执行，前面加一个单引号让它按文本显示
+func csvCell(v string) string {
+	if v != "" && strings.ContainsRune("=+-@\t\r", rune(v[0])) {
+		return "'" + v
+	}
+	return v
+}
+
+func AdminUnlockUserHandler(authSvc *service.AuthService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+		if err != nil || userID == 0 {
+			_ = c.Error(invalidParam("id"))
+			return
+		}
+
+		err = authSvc.UnlockAccount(
+			c.Request.Context(),
+			c.GetUint("user_id"),
+			uint(userID),
+			c.ClientIP(),
+			c.GetHeader("User-Agent"),
+		)
+		if err != nil {
+			_ = c.Error(err)
+			return
+		}
+
+		response.OK(c, dto.OKResp{OK: true})
+	}
+}
+
+func AdminJanitorStatsHandler(janitorSvc *service.JanitorService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		response.OK(c, janitorSvc.Stats())
+	}
+}
+
+// AdminJanitorRunHandler 手动触发一次清理，dry_run=true 只统计不删除
+func AdminJanitorRunHandler(janitorSvc *service.JanitorService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		report := janitorSvc.RunOnce(c.Request.Context(), c.Query("dry_run") == "true")
+		if report.Error != "" {
+			_ = c.Error(errcode.ErrJanitorFailed.WithData(report))
+			return
+		}
+
+		response.OK(c, report)
+	}
+}
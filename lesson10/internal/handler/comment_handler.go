@@ -4,10 +4,8 @@ import (
 	"lesson10/internal/dto"
 	"lesson10/internal/pkg/response"
 	"lesson10/internal/service"
-	"log"
 	"net/http"
 	"strconv"
-	"strings"
 
 	"github.com/gin-gonic/gin"
 )
@@ -16,7 +14,7 @@ func PostCommentHandler(commentSvc *service.CommentService) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var req dto.PostCommentRequest
 		if err := c.ShouldBindJSON(&req); err != nil {
-			response.Error(c, http.StatusBadRequest, "format error")
+			_ = c.Error(invalidRequest(err))
 			return
 		}
 
@@ -24,23 +22,10 @@ func PostCommentHandler(commentSvc *service.CommentService) gin.HandlerFunc {
 
 		comment, err := commentSvc.PostCommentService(c.Request.Context(), id, &req)
 		if err != nil {
-			errMsg := err.Error()
-
-			switch {
-			case strings.Contains(errMsg, "fail to find comment"):
-				response.Error(c, http.StatusBadRequest, "fail to find comment")
-				return
-
-			case strings.Contains(errMsg, "fail to find post or question"):
-				response.Error(c, http.StatusBadRequest, "fail to find post or question")
-				return
-
-			default:
-				writeErr(c, err)
-				return
-			}
+			_ = c.Error(err)
+			return
 		}
-		response.JSON(c, http.StatusOK, "post success", gin.H{"comment": comment})
+		response.JSON(c, http.StatusOK, "post success", dto.CreateCommentResp{Comment: comment})
 	}
 }
 
@@ -48,13 +33,13 @@ func GetCommentsHandler(commentSvc *service.CommentService) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var req dto.GetCommentsReq
 		if err := c.ShouldBindQuery(&req); err != nil {
-			response.Error(c, http.StatusBadRequest, "format error")
+			_ = c.Error(invalidRequest(err))
 			return
 		}
 
 		resp, err := commentSvc.GetCommentsService(c.Request.Context(), &req)
 		if err != nil {
-			writeErr(c, err)
+			_ = c.Error(err)
 			return
 		}
 
@@ -67,7 +52,7 @@ func GetRepliesHandler(commentSvc *service.CommentService) gin.HandlerFunc {
 		parentIDStr := c.Param("parent_id")
 		parentID, err := strconv.ParseUint(parentIDStr, 10, 64)
 		if err != nil {
-			response.Error(c, http.StatusBadRequest, "invalid parent id")
+			_ = c.Error(invalidParam("parent_id"))
 			return
 		}
 
@@ -75,14 +60,13 @@ func GetRepliesHandler(commentSvc *service.CommentService) gin.HandlerFunc {
 
 		replies, total, err := commentSvc.GetAllReplies(c.Request.Context(), uint(parentID), uid)
 		if err != nil {
-			log.Printf("get replies failed: %v", err)
-			response.Error(c, http.StatusInternalServerError, "internal server error")
+			_ = c.Error(err)
 			return
 		}
 
-		response.OK(c, gin.H{
-			"replies": replies,
-			"total":   total,
+		response.OK(c, dto.ReplyListResp{
+			Replies: replies,
+			Total:   total,
 		})
 	}
 }
@@ -92,7 +76,7 @@ func DeleteCommentHandler(commentSvc *service.CommentService) gin.HandlerFunc {
 		commentIDStr := c.Param("id")
 		commentID, err := strconv.ParseUint(commentIDStr, 10, 64)
 		if err != nil {
-			response.Error(c, http.StatusBadRequest, "id format incorrect")
+			_ = c.Error(invalidParam("id"))
 			return
 		}
 
@@ -101,16 +85,7 @@ func DeleteCommentHandler(commentSvc *service.CommentService) gin.HandlerFunc {
 
 		err = commentSvc.DeleteComment(c.Request.Context(), uint(commentID), uid, role)
 		if err != nil {
-			errMsg := err.Error()
-			switch {
-			case strings.Contains(errMsg, "fail to find comment"):
-				response.Error(c, http.StatusNotFound, "fail to find comment")
-				return
-
-			default:
-				log.Printf("error: %v", err)
-				writeErr(c, err)
-			}
+			_ = c.Error(err)
 			return
 		}
 
@@ -0,0 +1,103 @@
+package handler
+
+import (
+	"lesson10/internal/dto"
+	"lesson10/internal/pkg/response"
+	"lesson10/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+func VerifyEmailHandler(emailSvc *service.EmailService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req dto.VerifyEmailRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			_ = c.Error(invalidRequest(err))
+			return
+		}
+
+		if err := emailSvc.VerifyEmail(c.Request.Context(), req.Token); err != nil {
+			_ = c.Error(err)
+			return
+		}
+
+		response.OK(c, dto.OKResp{OK: true})
+	}
+}
+
+func ResendVerificationHandler(emailSvc *service.EmailService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if err := emailSvc.ResendVerification(c.Request.Context(), c.GetUint("user_id")); err != nil {
+			_ = c.Error(err)
+			return
+		}
+
+		response.OK(c, dto.OKResp{OK: true})
+	}
+}
+
+func ChangeEmailHandler(emailSvc *service.EmailService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req dto.ChangeEmailRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			_ = c.Error(invalidRequest(err))
+			return
+		}
+
+		err := emailSvc.ChangeEmail(
+			c.Request.Context(),
+			c.GetUint("user_id"),
+			req.Email,
+			req.Password,
+			c.ClientIP(),
+			c.GetHeader("User-Agent"),
+		)
+		if err != nil {
+			_ = c.Error(err)
+			return
+		}
+
+		response.OK(c, dto.OKResp{OK: true})
+	}
+}
+
+func ForgotPasswordHandler(emailSvc *service.EmailService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req dto.ForgotPasswordRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			_ = c.Error(invalidRequest(err))
+			return
+		}
+
+		if err := emailSvc.RequestPasswordReset(c.Request.Context(), req.Email); err != nil {
+			_ = c.Error(err)
+			return
+		}
+
+		response.OK(c, dto.OKResp{OK: true})
+	}
+}
+
+func ResetPasswordHandler(emailSvc *service.EmailService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req dto.ResetPasswordRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			_ = c.Error(invalidRequest(err))
+			return
+		}
+
+		err := emailSvc.ResetPassword(
+			c.Request.Context(),
+			req.Token,
+			req.NewPassword,
+			c.ClientIP(),
+			c.GetHeader("User-Agent"),
+		)
+		if err != nil {
+			_ = c.Error(err)
+			return
+		}
+
+		response.OK(c, dto.OKResp{OK: true})
+	}
+}
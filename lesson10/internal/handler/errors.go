@@ -0,0 +1,57 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"lesson10/internal/pkg/errcode"
+	"reflect"
+	"strings"
+
+	"github.com/gin-gonic/gin/binding"
+	"github.com/go-playground/validator/v10"
+)
+
+// 校验错误里的字段名用 json / form 标签，和客户端提交的字段名一致，而不是 Go 的结构体字段名
+func init() {
+	v, ok := binding.Validator.Engine().(*validator.Validate)
+	if !ok {
+		return
+	}
+	v.RegisterTagNameFunc(func(f reflect.StructField) string {
+		for _, tag := range []string{"json", "form"} {
+			name, _, _ := strings.Cut(f.Tag.Get(tag), ",")
+			if name == "-" {
+				return ""
+			}
+			if name != "" {
+				return name
+			}
+		}
+		return f.Name
+	})
+}
+
+// invalidRequest 把 ShouldBind 的错误转成带字段明细的 ErrValidation，
+// 请求体不是合法 JSON 之类拿不到字段的情况返回 ErrBadRequest
+func invalidRequest(err error) error {
+	var fieldErrs validator.ValidationErrors
+	if errors.As(err, &fieldErrs) {
+		details := make([]errcode.FieldError, 0, len(fieldErrs))
+		for _, fe := range fieldErrs {
+			details = append(details, errcode.FieldError{Field: fe.Field(), Rule: fe.Tag(), Param: fe.Param()})
+		}
+		return errcode.ErrValidation.WithDetails(details...).WithCause(err)
+	}
+
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) && typeErr.Field != "" {
+		return errcode.ErrValidation.WithDetails(errcode.FieldError{Field: typeErr.Field, Rule: "format"}).WithCause(err)
+	}
+
+	return errcode.ErrBadRequest.WithCause(err)
+}
+
+// invalidParam 路径或查询参数格式不对，比如 id 不是正整数
+func invalidParam(name string) error {
+	return errcode.ErrValidation.WithDetails(errcode.FieldError{Field: name, Rule: "format"})
+}
@@ -2,9 +2,9 @@ package handler
 
 import (
 	"lesson10/internal/dto"
+	"lesson10/internal/pkg/errcode"
 	"lesson10/internal/pkg/response"
 	"lesson10/internal/service"
-	"net/http"
 
 	"github.com/gin-gonic/gin"
 )
@@ -13,24 +13,24 @@ func ToggleFavoriteHandler(favoriteSvc *service.FavoriteService) gin.HandlerFunc
 	return func(c *gin.Context) {
 		var req dto.FavorRequest
 		if err := c.ShouldBindJSON(&req); err != nil {
-			response.Error(c, http.StatusBadRequest, "format error")
+			_ = c.Error(invalidRequest(err))
 			return
 		}
 
 		uid := c.GetUint("user_id")
 		if uid == 0 {
-			response.Error(c, http.StatusBadRequest, "please login")
+			_ = c.Error(errcode.ErrLoginRequired)
 			return
 		}
 
 		isFavorited, err := favoriteSvc.ToggleFavoriteService(c.Request.Context(), uid, req.TargetType, req.TargetID)
 		if err != nil {
-			writeErr(c, err)
+			_ = c.Error(err)
 			return
 		}
 
-		response.OK(c, gin.H{
-			"is_favorited": *isFavorited,
+		response.OK(c, dto.FavoriteStatusResp{
+			IsFavorited: *isFavorited,
 		})
 	}
 }
@@ -1,12 +1,12 @@
 package handler
 
 import (
+	"lesson10/internal/dto"
+	"lesson10/internal/pkg/errcode"
 	"lesson10/internal/pkg/response"
 	"lesson10/internal/service"
-	"log"
 	"net/http"
 	"strconv"
-	"strings"
 
 	"github.com/gin-gonic/gin"
 )
@@ -16,35 +16,26 @@ func FollowUserHandler(followSvc *service.FollowService) gin.HandlerFunc {
 		followeeIDStr := c.Param("id")
 		followeeID, err := strconv.ParseUint(followeeIDStr, 10, 64)
 		if err != nil || followeeID == 0 {
-			response.Error(c, http.StatusBadRequest, "id format incorrect")
+			_ = c.Error(invalidParam("id"))
 			return
 		}
 
 		followerID := c.GetUint("user_id")
 		if followerID == 0 {
-			response.Error(c, http.StatusUnauthorized, "please log in")
+			_ = c.Error(errcode.ErrLoginRequired)
 			return
 		}
 
 		if followerID == uint(followeeID) {
-			response.Error(c, http.StatusBadRequest, "can not follow yourself")
+			_ = c.Error(errcode.ErrCannotFollowSelf)
 			return
 		}
 
 		err = followSvc.FollowUserService(c.Request.Context(), followerID, uint(followeeID))
 
 		if err != nil {
-			errMsg := err.Error()
-			switch {
-			case strings.Contains(errMsg, "has followed"):
-				response.Error(c, http.StatusBadRequest, "has followed")
-				return
-			default:
-				log.Printf("fail: %v", err)
-				writeErr(c, err)
-				return
-			}
-
+			_ = c.Error(err)
+			return
 		}
 
 		response.JSON(c, http.StatusOK, "success", nil)
@@ -57,27 +48,20 @@ func UnfollowUserHandler(followSvc *service.FollowService) gin.HandlerFunc {
 		followeeID, err := strconv.ParseUint(followeeIDStr, 10, 64)
 
 		if err != nil || followeeID == 0 {
-			response.Error(c, http.StatusBadRequest, "id format incorrect")
+			_ = c.Error(invalidParam("id"))
 			return
 		}
 
 		followerID := c.GetUint("user_id")
 		if followerID == 0 {
-			response.Error(c, http.StatusUnauthorized, "please log in")
+			_ = c.Error(errcode.ErrLoginRequired)
 			return
 		}
 
 		err = followSvc.UnfollowUserService(c.Request.Context(), followerID, uint(followeeID))
 		if err != nil {
-			errMsg := err.Error()
-			if strings.Contains(errMsg, "has not followed") {
-				response.Error(c, http.StatusBadRequest, "has not followed")
-				return
-
-			} else {
-				writeErr(c, err)
-				return
-			}
+			_ = c.Error(err)
+			return
 		}
 
 		response.JSON(c, http.StatusOK, "success", nil)
@@ -101,7 +85,7 @@ func getFollowListInternal(c *gin.Context, followSvc *service.FollowService, lis
 	targetUserIDStr := c.Param("id")
 	targetUserID, err := strconv.ParseUint(targetUserIDStr, 10, 64)
 	if err != nil || targetUserID == 0 {
-		response.Error(c, http.StatusBadRequest, "invalid user id")
+		_ = c.Error(invalidParam("id"))
 		return
 	}
 
@@ -121,15 +105,14 @@ func getFollowListInternal(c *gin.Context, followSvc *service.FollowService, lis
 
 	users, total, err := followSvc.GetFollowListService(c.Request.Context(), uint(targetUserID), listType, currentUserID, page, size)
 	if err != nil {
-		log.Printf("get follow list failed: %v", err)
-		response.Error(c, http.StatusInternalServerError, "internal server error")
+		_ = c.Error(err)
 		return
 	}
 
-	response.OK(c, gin.H{
-		"users": users,
-		"total": total,
-		"page":  page,
-		"size":  size,
+	response.OK(c, dto.FollowListResp{
+		Users: users,
+		Total: total,
+		Page:  page,
+		Size:  size,
 	})
 }
@@ -0,0 +1,31 @@
+package handler
+
+import (
+	"lesson10/internal/dto"
+	"lesson10/internal/service"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// HealthzHandler 存活检查：进程能处理请求就返回 200，不查依赖，免得数据库抖一下所有实例都被重启
+func HealthzHandler() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.JSON(http.StatusOK, dto.Liveness{Status: "ok"})
+	}
+}
+
+// ReadyzHandler 就绪检查：依赖都正常才返回 200，否则 503，负载均衡据此摘流量。
+// 和 JWKS 一样不走统一的 response 包装，探针只看状态码
+func ReadyzHandler(svc *service.HealthService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		resp, ready := svc.Ready(c.Request.Context())
+
+		c.Header("Cache-Control", "no-store")
+		if !ready {
+			c.JSON(http.StatusServiceUnavailable, resp)
+			return
+		}
+		c.JSON(http.StatusOK, resp)
+	}
+}
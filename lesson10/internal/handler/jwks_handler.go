@@ -0,0 +1,16 @@
+package handler
+
+import (
+	"lesson10/internal/service"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// JWKSHandler 按 RFC 7517 格式输出公钥，不走统一的 response 包装，方便其他服务直接拿来验签
+func JWKSHandler(authService *service.AuthService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header("Cache-Control", "public, max-age=300")
+		c.JSON(http.StatusOK, authService.PublicKeys())
+	}
+}
@@ -0,0 +1,25 @@
+package handler
+
+import (
+	"crypto/subtle"
+	"lesson10/internal/pkg/metrics"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// MetricsHandler 给 Prometheus 抓取，输出文本格式，不走统一的 response 包装。
+// token 非空时要求 Authorization: Bearer <token>
+func MetricsHandler(token string) gin.HandlerFunc {
+	h := metrics.Handler()
+	want := []byte("Bearer " + token)
+
+	return func(c *gin.Context) {
+		if token != "" && subtle.ConstantTimeCompare([]byte(c.GetHeader("Authorization")), want) != 1 {
+			c.AbortWithStatus(http.StatusUnauthorized)
+			return
+		}
+
+		h.ServeHTTP(c.Writer, c.Request)
+	}
+}
@@ -0,0 +1,125 @@
+package handler
+
+import (
+	"lesson10/internal/dto"
+	"lesson10/internal/model"
+	"lesson10/internal/pkg/response"
+	"lesson10/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+func ListOAuthProvidersHandler(oauthSvc *service.OAuthService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		response.OK(c, dto.OAuthProvidersResp{Providers: oauthSvc.Providers()})
+	}
+}
+
+func OAuthLoginHandler(oauthSvc *service.OAuthService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var q dto.OAuthBeginQuery
+		if err := c.ShouldBindQuery(&q); err != nil {
+			_ = c.Error(invalidRequest(err))
+			return
+		}
+
+		authorize, err := oauthSvc.Begin(c.Request.Context(), c.Param("provider"), model.OAuthPurposeLogin, 0, q.DeviceID, q.DeviceName, q.RememberMe)
+		if err != nil {
+			_ = c.Error(err)
+			return
+		}
+
+		response.OK(c, authorize)
+	}
+}
+
+func OAuthCallbackHandler(oauthSvc *service.OAuthService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req dto.OAuthCallbackRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			_ = c.Error(invalidRequest(err))
+			return
+		}
+
+		result, err := oauthSvc.LoginCallback(
+			c.Request.Context(),
+			c.Param("provider"),
+			req,
+			c.ClientIP(),
+			c.GetHeader("User-Agent"),
+		)
+		if err != nil {
+			_ = c.Error(err)
+			return
+		}
+
+		writeLoginResult(c, result)
+	}
+}
+
+func OAuthLinkHandler(oauthSvc *service.OAuthService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		authorize, err := oauthSvc.Begin(c.Request.Context(), c.Param("provider"), model.OAuthPurposeLink, c.GetUint("user_id"), "", "", false)
+		if err != nil {
+			_ = c.Error(err)
+			return
+		}
+
+		response.OK(c, authorize)
+	}
+}
+
+func OAuthLinkCallbackHandler(oauthSvc *service.OAuthService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req dto.OAuthCallbackRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			_ = c.Error(invalidRequest(err))
+			return
+		}
+
+		identity, err := oauthSvc.LinkCallback(
+			c.Request.Context(),
+			c.GetUint("user_id"),
+			c.Param("provider"),
+			req,
+			c.ClientIP(),
+			c.GetHeader("User-Agent"),
+		)
+		if err != nil {
+			_ = c.Error(err)
+			return
+		}
+
+		response.OK(c, identity)
+	}
+}
+
+func ListIdentitiesHandler(oauthSvc *service.OAuthService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		identities, err := oauthSvc.ListIdentities(c.Request.Context(), c.GetUint("user_id"))
+		if err != nil {
+			_ = c.Error(err)
+			return
+		}
+
+		response.OK(c, dto.IdentityListResp{Identities: identities})
+	}
+}
+
+func UnlinkIdentityHandler(oauthSvc *service.OAuthService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		err := oauthSvc.Unlink(
+			c.Request.Context(),
+			c.GetUint("user_id"),
+			c.Param("provider"),
+			c.ClientIP(),
+			c.GetHeader("User-Agent"),
+		)
+		if err != nil {
+			_ = c.Error(err)
+			return
+		}
+
+		response.OK(c, dto.OKResp{OK: true})
+	}
+}
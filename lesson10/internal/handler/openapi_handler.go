@@ -0,0 +1,58 @@
+package handler
+
+import (
+	"encoding/json"
+	"html/template"
+	"lesson10/internal/pkg/openapi"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/gin-gonic/gin"
+)
+
+// OpenAPIHandler 文档在启动时生成一次，之后每次直接返回序列化好的内容
+func OpenAPIHandler(doc *openapi.Document) gin.HandlerFunc {
+	body, err := json.Marshal(doc)
+	if err != nil {
+		panic(err)
+	}
+
+	return func(c *gin.Context) {
+		c.Data(http.StatusOK, "application/json; charset=utf-8", body)
+	}
+}
+
+var swaggerUI = template.Must(template.New("swagger").Parse(`<!DOCTYPE html>
+<html lang="zh-CN">
+<head>
+  <meta charset="utf-8">
+  <title>Lesson10 API</title>
+  <link rel="stylesheet" href="{{.AssetsURL}}/swagger-ui.css">
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script src="{{.AssetsURL}}/swagger-ui-bundle.js"></script>
+  <script>
+    window.ui = SwaggerUIBundle({ url: {{.SpecURL}}, dom_id: "#swagger-ui", persistAuthorization: true });
+  </script>
+</body>
+</html>
+`))
+
+// SwaggerUIHandler Swagger UI 的静态资源由 scripts/fetch-swagger-ui.sh 下载到 assetsDir，和页面同源加载，不引用第三方 CDN。
+// 资源还没下载时返回 503 和提示，而不是一个白屏
+func SwaggerUIHandler(specURL, assetsURL, assetsDir string) gin.HandlerFunc {
+	page := struct{ SpecURL, AssetsURL string }{specURL, assetsURL}
+
+	return func(c *gin.Context) {
+		if _, err := os.Stat(filepath.Join(assetsDir, "swagger-ui-bundle.js")); err != nil {
+			c.String(http.StatusServiceUnavailable, "Swagger UI assets are not installed, run scripts/fetch-swagger-ui.sh %s", assetsDir)
+			return
+		}
+
+		c.Header("Content-Type", "text/html; charset=utf-8")
+		c.Status(http.StatusOK)
+		_ = swaggerUI.Execute(c.Writer, page)
+	}
+}
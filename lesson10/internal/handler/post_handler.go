@@ -1,16 +1,11 @@
 package handler
 
 import (
-	"fmt"
 	"lesson10/internal/dto"
+	"lesson10/internal/pkg/errcode"
 	"lesson10/internal/pkg/response"
 	"lesson10/internal/service"
-	"log"
-	"net/http"
-	"os"
-	"path/filepath"
 	"strconv"
-	"time"
 
 	"github.com/gin-gonic/gin"
 )
@@ -19,13 +14,13 @@ func CreatePostHandler(postSvc *service.PostService) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var req dto.CreatePostRequest
 		if err := c.ShouldBindJSON(&req); err != nil {
-			response.Error(c, http.StatusBadRequest, "req format error")
+			_ = c.Error(invalidRequest(err))
 			return
 		}
 
 		authorID := c.GetUint("user_id")
 		if authorID == 0 {
-			response.Error(c, http.StatusUnauthorized, "fail to find user id")
+			_ = c.Error(errcode.ErrLoginRequired)
 			return
 		}
 
@@ -34,13 +29,13 @@ func CreatePostHandler(postSvc *service.PostService) gin.HandlerFunc {
 		}
 		post, err := postSvc.CreatePostService(c.Request.Context(), &req, authorID)
 		if err != nil {
-			writeErr(c, err)
+			_ = c.Error(err)
 			return
 		}
 
-		response.OK(c, gin.H{
-			"ok":   true,
-			"post": post,
+		response.OK(c, dto.CreatePostResp{
+			OK:   true,
+			Post: post,
 		})
 	}
 }
@@ -49,26 +44,26 @@ func ListPostsHandler(postSvc *service.PostService) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		var q dto.ListPostsQuery
 		if err := c.ShouldBindQuery(&q); err != nil {
-			response.Error(c, http.StatusBadRequest, "query format error")
+			_ = c.Error(invalidRequest(err))
 			return
 		}
 
 		list, total, err := postSvc.ListPostsService(c.Request.Context(), q)
 		if err != nil {
-			writeErr(c, err)
+			_ = c.Error(err)
 			return
 		}
 
-		response.OK(c, gin.H{
-			"list":  list,
-			"total": total,
-			"page": func() int {
+		response.OK(c, dto.PostListResp{
+			List:  list,
+			Total: total,
+			Page: func() int {
 				if q.Page == 0 {
 					return 1
 				}
 				return q.Page
 			}(),
-			"page_size": func() int {
+			PageSize: func() int {
 				if q.PageSize == 0 {
 					return 20
 				}
@@ -82,7 +77,7 @@ func GetPostHandler(postSvc *service.PostService) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		postID64, err := strconv.ParseUint(c.Param("id"), 10, 64)
 		if err != nil || postID64 == 0 {
-			response.Error(c, http.StatusBadRequest, "id format error")
+			_ = c.Error(invalidParam("id"))
 			return
 		}
 
@@ -90,7 +85,7 @@ func GetPostHandler(postSvc *service.PostService) gin.HandlerFunc {
 
 		resp, err := postSvc.GetPostService(c.Request.Context(), currentUserID, uint(postID64))
 		if err != nil {
-			writeErr(c, err)
+			_ = c.Error(err)
 			return
 		}
 		response.OK(c, resp)
@@ -102,31 +97,31 @@ func UpdatePostHandler(postSvc *service.PostService) gin.HandlerFunc {
 		PostIDString := c.Param("id")
 		PostID, err := strconv.ParseUint(PostIDString, 10, 64)
 		if err != nil {
-			response.Error(c, http.StatusBadRequest, "id format incorrect")
+			_ = c.Error(invalidParam("id"))
 			return
 		}
 
 		var req dto.UpdatePostRequest
 		if err := c.ShouldBindJSON(&req); err != nil {
-			response.Error(c, http.StatusBadRequest, "format error")
+			_ = c.Error(invalidRequest(err))
 			return
 		}
 
 		id := c.GetUint("user_id")
 		if id == 0 {
-			response.Error(c, http.StatusUnauthorized, "please log in")
+			_ = c.Error(errcode.ErrLoginRequired)
 			return
 		}
 
 		err = postSvc.UpdatePostService(c.Request.Context(), PostID, id, &req)
 
 		if err != nil {
-			writeErr(c, err)
+			_ = c.Error(err)
 			return
 		}
-		response.OK(c, gin.H{
-			"ok":      true,
-			"message": "update success",
+		response.OK(c, dto.UpdatePostResp{
+			OK:      true,
+			Message: "update success",
 		})
 	}
 }
@@ -136,101 +131,33 @@ func DeletePostHandler(postSvc *service.PostService) gin.HandlerFunc {
 		postIDStr := c.Param("id")
 		postID, err := strconv.ParseUint(postIDStr, 10, 64)
 		if err != nil {
-			response.Error(c, http.StatusBadRequest, "id format incorrect")
+			_ = c.Error(invalidParam("id"))
 			return
 		}
 
 		uid := c.GetUint("user_id")
 		if uid == 0 {
-			response.Error(c, http.StatusUnauthorized, "unauthorized")
+			_ = c.Error(errcode.ErrLoginRequired)
 			return
 		}
 
 		role := c.GetUint("role")
 		err = postSvc.DeletePostService(c.Request.Context(), uint(postID), uid, role)
 		if err != nil {
-			writeErr(c, err)
+			_ = c.Error(err)
 			return
 		}
-		response.OK(c, gin.H{
-			"message": "delete success",
+		response.OK(c, dto.DeletePostResp{
+			Message: "delete success",
 		})
 	}
 }
 
-func UploadArticleImageHandler(c *gin.Context) {
-	uid := c.GetUint("user_id")
-	if uid == 0 {
-		response.Error(c, http.StatusUnauthorized, "please log in")
-		return
-	}
-
-	file, err := c.FormFile("image") // 前端 form-data 字段名统一用 "image"
-	if err != nil {
-		response.Error(c, http.StatusBadRequest, "please upload image")
-		return
-	}
-
-	// 1. 大小限制：5MB
-	const maxSize = 10 * 1024 * 1024
-	if file.Size > maxSize {
-		response.Error(c, http.StatusBadRequest, "max 10MB")
-		return
-	}
-
-	// 2. 打开文件读头部，检测真实 MIME 类型（防伪造）
-	f, err := file.Open()
-	if err != nil {
-		response.Error(c, http.StatusInternalServerError, "fail to open file")
-		return
-	}
-	defer f.Close()
-
-	buf := make([]byte, 512)
-	n, _ := f.Read(buf)
-	contentType := http.DetectContentType(buf[:n])
-
-	allowedTypes := map[string]string{
-		"image/jpeg": ".jpg",
-		"image/png":  ".png",
-		"image/webp": ".webp",
-	}
-
-	ext, ok := allowedTypes[contentType]
-	if !ok {
-		response.Error(c, http.StatusBadRequest, "only jpg/png/webp")
-		return
-	}
-
-	// 3. 生成唯一文件名
-	filename := fmt.Sprintf("article_%d_%d%s", uid, time.Now().UnixNano(), ext)
-
-	// 4. 确保目录存在
-	saveDir := "static/uploads/images"
-	if err := os.MkdirAll(saveDir, 0755); err != nil {
-		response.Error(c, http.StatusInternalServerError, "fail to make dir")
-		return
-	}
-
-	savePath := filepath.Join(saveDir, filename)
-
-	// 5. 保存文件（Gin 内置方法会重新打开文件，所以前面读 512 字节不影响）
-	if err := c.SaveUploadedFile(file, savePath); err != nil {
-		response.Error(c, http.StatusInternalServerError, "fail to save file")
-		return
-	}
-
-	// 6. 构造可访问的 URL（相对路径，配合 r.Static 使用）
-	imageURL := "/static/uploads/images/" + filename
-
-	response.OK(c, gin.H{"image_url": imageURL})
-}
-
 func GetFavoritesHandler(postSvc *service.PostService) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		uid := c.GetUint("user_id")
 		if uid == 0 {
-			response.Error(c, http.StatusUnauthorized, "please login first")
+			_ = c.Error(errcode.ErrLoginRequired)
 			return
 		}
 
@@ -246,16 +173,15 @@ func GetFavoritesHandler(postSvc *service.PostService) gin.HandlerFunc {
 
 		favorites, total, err := postSvc.GetFavoritesService(c.Request.Context(), uid, page, size)
 		if err != nil {
-			log.Printf("get favorites failed: %v", err)
-			response.Error(c, http.StatusInternalServerError, "internal server error")
+			_ = c.Error(err)
 			return
 		}
 
-		response.OK(c, gin.H{
-			"favorites": favorites,
-			"total":     total,
-			"page":      page,
-			"size":      size,
+		response.OK(c, dto.FavoriteListResp{
+			Favorites: favorites,
+			Total:     total,
+			Page:      page,
+			Size:      size,
 		})
 	}
 }
@@ -264,7 +190,7 @@ func GetDraftHandler(postSvc *service.PostService) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		uid := c.GetUint("user_id")
 		if uid == 0 {
-			response.Error(c, http.StatusUnauthorized, "please login first")
+			_ = c.Error(errcode.ErrLoginRequired)
 			return
 		}
 
@@ -280,16 +206,15 @@ func GetDraftHandler(postSvc *service.PostService) gin.HandlerFunc {
 
 		drafts, total, err := postSvc.GetDraftService(c.Request.Context(), uid, page, size)
 		if err != nil {
-			log.Printf("get favorites failed: %v", err)
-			response.Error(c, http.StatusInternalServerError, "internal server error")
+			_ = c.Error(err)
 			return
 		}
 
-		response.OK(c, gin.H{
-			"drafts": drafts,
-			"total":  total,
-			"page":   page,
-			"size":   size,
+		response.OK(c, dto.DraftListResp{
+			Drafts: drafts,
+			Total:  total,
+			Page:   page,
+			Size:   size,
 		})
 	}
 }
@@ -0,0 +1,27 @@
+package handler
+
+import (
+	"lesson10/internal/dto"
+	"lesson10/internal/pkg/response"
+	"lesson10/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+func SearchHandler(searchSvc *service.SearchService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var q dto.SearchQuery
+		if err := c.ShouldBindQuery(&q); err != nil {
+			_ = c.Error(invalidRequest(err))
+			return
+		}
+
+		resp, err := searchSvc.Search(c.Request.Context(), q)
+		if err != nil {
+			_ = c.Error(err)
+			return
+		}
+
+		response.OK(c, resp)
+	}
+}
@@ -0,0 +1,45 @@
+package handler
+
+import (
+	"lesson10/internal/dto"
+	"lesson10/internal/pkg/response"
+	"lesson10/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+func ListSecurityEventsHandler(securitySvc *service.SecurityService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var q dto.SecurityEventQuery
+		if err := c.ShouldBindQuery(&q); err != nil {
+			_ = c.Error(invalidRequest(err))
+			return
+		}
+
+		list, err := securitySvc.ListUserEvents(c.Request.Context(), c.GetUint("user_id"), q)
+		if err != nil {
+			_ = c.Error(err)
+			return
+		}
+
+		response.OK(c, list)
+	}
+}
+
+func AdminListSecurityEventsHandler(securitySvc *service.SecurityService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var q dto.SecurityEventQuery
+		if err := c.ShouldBindQuery(&q); err != nil {
+			_ = c.Error(invalidRequest(err))
+			return
+		}
+
+		list, err := securitySvc.ListEvents(c.Request.Context(), q)
+		if err != nil {
+			_ = c.Error(err)
+			return
+		}
+
+		response.OK(c, list)
+	}
+}
@@ -0,0 +1,106 @@
+package handler
+
+import (
+	"lesson10/internal/dto"
+	"lesson10/internal/pkg/response"
+	"lesson10/internal/service"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TwoFactorStatusHandler(twoFactorSvc *service.TwoFactorService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		status, err := twoFactorSvc.Status(c.Request.Context(), c.GetUint("user_id"))
+		if err != nil {
+			_ = c.Error(err)
+			return
+		}
+
+		response.OK(c, status)
+	}
+}
+
+func TwoFactorSetupHandler(twoFactorSvc *service.TwoFactorService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req dto.TwoFactorSetupRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			_ = c.Error(invalidRequest(err))
+			return
+		}
+
+		setup, err := twoFactorSvc.Setup(c.Request.Context(), c.GetUint("user_id"), req.Password)
+		if err != nil {
+			_ = c.Error(err)
+			return
+		}
+
+		response.OK(c, setup)
+	}
+}
+
+func TwoFactorEnableHandler(twoFactorSvc *service.TwoFactorService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req dto.TwoFactorCodeRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			_ = c.Error(invalidRequest(err))
+			return
+		}
+
+		codes, err := twoFactorSvc.Enable(
+			c.Request.Context(),
+			c.GetUint("user_id"),
+			req.Code,
+			c.ClientIP(),
+			c.GetHeader("User-Agent"),
+		)
+		if err != nil {
+			_ = c.Error(err)
+			return
+		}
+
+		response.OK(c, dto.RecoveryCodesResp{RecoveryCodes: codes})
+	}
+}
+
+func TwoFactorDisableHandler(twoFactorSvc *service.TwoFactorService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req dto.TwoFactorDisableRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			_ = c.Error(invalidRequest(err))
+			return
+		}
+
+		err := twoFactorSvc.Disable(
+			c.Request.Context(),
+			c.GetUint("user_id"),
+			req.Password,
+			req.Code,
+			c.ClientIP(),
+			c.GetHeader("User-Agent"),
+		)
+		if err != nil {
+			_ = c.Error(err)
+			return
+		}
+
+		response.OK(c, dto.OKResp{OK: true})
+	}
+}
+
+func RegenerateRecoveryCodesHandler(twoFactorSvc *service.TwoFactorService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req dto.TwoFactorDisableRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			_ = c.Error(invalidRequest(err))
+			return
+		}
+
+		codes, err := twoFactorSvc.RegenerateRecoveryCodes(c.Request.Context(), c.GetUint("user_id"), req.Password, req.Code, c.ClientIP(), c.GetHeader("User-Agent"))
+		if err != nil {
+			_ = c.Error(err)
+			return
+		}
+
+		response.OK(c, dto.RecoveryCodesResp{RecoveryCodes: codes})
+	}
+}
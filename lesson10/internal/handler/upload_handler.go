@@ -0,0 +1,126 @@
+package handler
+
+import (
+	"errors"
+	"io"
+	"lesson10/internal/dto"
+	"lesson10/internal/pkg/errcode"
+	"lesson10/internal/pkg/response"
+	"lesson10/internal/service"
+	"mime/multipart"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+var errUploadTooLarge = errors.New("upload too large")
+
+func UploadAvatarHandler(uploadSvc *service.UploadService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		file, err := c.FormFile("avatar")
+		if err != nil {
+			_ = c.Error(errcode.ErrFileRequired)
+			return
+		}
+
+		raw, err := readUpload(file, 5*1024*1024)
+		if err != nil {
+			_ = c.Error(errcode.ErrFileTooLarge.WithDetails(errcode.FieldError{Field: "avatar", Rule: "max", Param: "5MB"}))
+			return
+		}
+
+		image, err := uploadSvc.UploadAvatar(c.Request.Context(), c.GetUint("user_id"), raw)
+		if err != nil {
+			_ = c.Error(err)
+			return
+		}
+
+		avatarURL := image.URL
+		for _, variant := range image.Variants {
+			if variant.Name == "medium" {
+				avatarURL = variant.URL
+			}
+		}
+
+		response.OK(c, dto.AvatarUploadResp{AvatarURL: avatarURL, Image: image})
+	}
+}
+
+func UploadArticleImageHandler(uploadSvc *service.UploadService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		file, err := c.FormFile("image") // 前端 form-data 字段名统一用 "image"
+		if err != nil {
+			_ = c.Error(errcode.ErrFileRequired)
+			return
+		}
+
+		raw, err := readUpload(file, 10*1024*1024)
+		if err != nil {
+			_ = c.Error(errcode.ErrFileTooLarge.WithDetails(errcode.FieldError{Field: "image", Rule: "max", Param: "10MB"}))
+			return
+		}
+
+		image, err := uploadSvc.UploadArticleImage(c.Request.Context(), c.GetUint("user_id"), raw)
+		if err != nil {
+			_ = c.Error(err)
+			return
+		}
+
+		response.OK(c, dto.ArticleImageUploadResp{ImageURL: image.URL, Image: image})
+	}
+}
+
+// UploadQuotaHandler 当前用户已用空间和配额（字节）
+func UploadQuotaHandler(uploadSvc *service.UploadService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		quota, err := uploadSvc.Quota(c.Request.Context(), c.GetUint("user_id"))
+		if err != nil {
+			_ = c.Error(err)
+			return
+		}
+
+		response.OK(c, quota)
+	}
+}
+
+// SignedImageURLHandler 上传者获取某张图片某个档位的临时地址，?variant=thumb|medium|original
+func SignedImageURLHandler(uploadSvc *service.UploadService) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		imageID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+		if err != nil {
+			_ = c.Error(invalidParam("id"))
+			return
+		}
+
+		signed, err := uploadSvc.SignedURL(c.Request.Context(), c.GetUint("user_id"), uint(imageID), c.Query("variant"))
+		if err != nil {
+			_ = c.Error(err)
+			return
+		}
+
+		response.OK(c, signed)
+	}
+}
+
+// readUpload 读出整个文件交给 service 处理，multipart 头里的 Size 可以伪造，所以读的时候再限制一次
+func readUpload(file *multipart.FileHeader, maxSize int64) ([]byte, error) {
+	if file.Size > maxSize {
+		return nil, errUploadTooLarge
+	}
+
+	f, err := file.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	raw, err := io.ReadAll(io.LimitReader(f, maxSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(raw)) > maxSize {
+		return nil, errUploadTooLarge
+	}
+
+	return raw, nil
+}
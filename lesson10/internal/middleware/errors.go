@@ -0,0 +1,26 @@
+package middleware
+
+import (
+	"lesson10/internal/pkg/errcode"
+	"lesson10/internal/pkg/i18n"
+	"lesson10/internal/pkg/response"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Errors 统一输出错误响应：handler 和前面的中间件只管 c.Error(err) 然后 return，
+// 这里取最后一个错误按业务码输出，文案语言看 Accept-Language。
+// 不是 errcode 的错误一律按 500 返回，原始错误只进访问日志，不暴露给客户端。
+// 挂在 Observe 之后、Recovery 之前
+func Errors() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+
+		if len(c.Errors) == 0 || c.Writer.Written() {
+			return
+		}
+
+		err := errcode.From(c.Errors.Last().Err)
+		response.Fail(c, err, i18n.Match(c.GetHeader("Accept-Language")))
+	}
+}
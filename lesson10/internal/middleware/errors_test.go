@@ -0,0 +1,106 @@
+package middleware
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"lesson10/internal/pkg/errcode"
+	"lesson10/internal/pkg/response"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func serveError(t *testing.T, err error, acceptLanguage string) (*httptest.ResponseRecorder, response.Resp) {
+	t.Helper()
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(RequestID(), Errors())
+	r.GET("/", func(c *gin.Context) {
+		_ = c.Error(err)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(RequestIDHeader, "req-1")
+	if acceptLanguage != "" {
+		req.Header.Set("Accept-Language", acceptLanguage)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	var resp response.Resp
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("body %q: %v", w.Body.String(), err)
+	}
+	return w, resp
+}
+
+func TestErrorsRendersWrappedSentinel(t *testing.T) {
+	w, resp := serveError(t, fmt.Errorf("get post: %w", errcode.ErrNotFound), "zh-CN,en;q=0.8")
+	if w.Code != http.StatusNotFound || resp.Code != errcode.ErrNotFound.Code {
+		t.Fatalf("status = %d, code = %d", w.Code, resp.Code)
+	}
+	if resp.Message != "内容不存在或已被删除" || resp.RequestID != "req-1" {
+		t.Fatalf("resp = %+v", resp)
+	}
+}
+
+func TestErrorsHidesUnknownErrors(t *testing.T) {
+	w, resp := serveError(t, errors.New("Error 1062: Duplicate entry 'alice'"), "")
+	if w.Code != http.StatusInternalServerError || resp.Code != errcode.ErrInternal.Code {
+		t.Fatalf("status = %d, code = %d", w.Code, resp.Code)
+	}
+	if resp.Message != "internal server error" || resp.Data != nil || resp.Details != nil {
+		t.Fatalf("leaked internals: %+v", resp)
+	}
+}
+
+func TestErrorsRendersFieldDetails(t *testing.T) {
+	err := errcode.ErrValidation.WithDetails(
+		errcode.FieldError{Field: "username", Rule: "min", Param: "3"},
+		errcode.FieldError{Field: "email", Rule: "email"},
+		errcode.FieldError{Field: "id", Rule: "uuid4"},
+	)
+
+	cases := map[string][]string{
+		"en": {"username must be at least 3", "email must be a valid email address", "id is invalid"},
+		"zh": {"username 长度或数值不能小于 3", "email 邮箱格式不正确", "id 不合法"},
+	}
+	for lang, want := range cases {
+		w, resp := serveError(t, err, lang)
+		if w.Code != http.StatusBadRequest || resp.Code != errcode.ErrValidation.Code {
+			t.Fatalf("%s: status = %d, code = %d", lang, w.Code, resp.Code)
+		}
+
+		var got []string
+		for _, d := range resp.Details {
+			got = append(got, d.Message)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Fatalf("%s: details = %q, want %q", lang, got, want)
+		}
+		if d := resp.Details[0]; d.Field != "username" || d.Rule != "min" || d.Param != "3" {
+			t.Fatalf("%s: detail = %+v", lang, d)
+		}
+	}
+}
+
+func TestErrorsKeepsWrittenResponse(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(Errors())
+	r.GET("/", func(c *gin.Context) {
+		c.String(http.StatusAccepted, "done")
+		_ = c.Error(errcode.ErrInternal)
+	})
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	if w.Code != http.StatusAccepted || w.Body.String() != "done" {
+		t.Fatalf("status = %d, body = %q", w.Code, w.Body.String())
+	}
+}
@@ -1,38 +1,37 @@
 package middleware
 
 import (
-	"lesson10/internal/pkg/response"
+	"fmt"
+	"lesson10/internal/model"
+	"lesson10/internal/pkg/errcode"
+	"lesson10/internal/pkg/metrics"
+	"lesson10/internal/pkg/ratelimit"
 	"lesson10/internal/service"
-	"net/http"
+	"log/slog"
+	"math"
+	"strconv"
 	"strings"
-	"sync"
-	"time"
 
 	"github.com/gin-gonic/gin"
-	"golang.org/x/time/rate"
 )
 
 func AuthMiddleware(authSvc *service.AuthService) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		accessToken := bearerToken(c.GetHeader("Authorization"))
 		if accessToken == "" {
-			response.Error(c, http.StatusUnauthorized, "missing access token")
+			_ = c.Error(errcode.ErrLoginRequired)
 			c.Abort()
 			return
 		}
 
 		identity, err := authSvc.ValidateAccessToken(c.Request.Context(), accessToken)
 		if err != nil {
-			response.Error(c, http.StatusUnauthorized, err.Error())
+			_ = c.Error(err)
 			c.Abort()
 			return
 		}
 
-		c.Set("user_id", identity.UserID)
-		c.Set("username", identity.Username)
-		c.Set("role", uint(identity.Role))
-		c.Set("session_id", identity.SessionID)
-		c.Set("access_token", accessToken)
+		setIdentity(c, identity.UserID, identity.Username, uint(identity.Role), identity.SessionID, accessToken)
 		c.Next()
 	}
 }
@@ -53,11 +52,20 @@ func OptionalAuthMiddleware(authSvc *service.AuthService) gin.HandlerFunc {
 			return
 		}
 
-		c.Set("user_id", identity.UserID)
-		c.Set("username", identity.Username)
-		c.Set("role", uint(identity.Role))
-		c.Set("session_id", identity.SessionID)
-		c.Set("access_token", accessToken)
+		setIdentity(c, identity.UserID, identity.Username, uint(identity.Role), identity.SessionID, accessToken)
+		c.Next()
+	}
+}
+
+// AdminOnly 必须挂在 AuthMiddleware 之后
+func AdminOnly() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetUint("role") != uint(model.RoleAdmin) {
+			_ = c.Error(errcode.ErrAdminOnly)
+			c.Abort()
+			return
+		}
+
 		c.Next()
 	}
 }
@@ -76,35 +84,36 @@ func bearerToken(header string) string {
 	return ""
 }
 
-var visitors = make(map[string]*rate.Limiter)
-var mu sync.Mutex
-
-func getLimiter(ip string) *rate.Limiter {
-	mu.Lock()
-	defer mu.Unlock()
-
-	limiter, exists := visitors[ip]
-	if !exists {
-		limiter = rate.NewLimiter(5, 10)
-		visitors[ip] = limiter
-
-		time.AfterFunc(60*time.Minute, func() {
-			mu.Lock()
-			delete(visitors, ip)
-			mu.Unlock()
-		})
-	}
+// RateLimit 按策略限流：登录用户按用户 ID 计数，匿名请求按 IP 计数。
+// 必须挂在 AuthMiddleware / OptionalAuthMiddleware 之后才能拿到 user_id。
+// 限流后端出错时放行，只打日志，不因为 Redis 故障拖垮整个站点
+func RateLimit(limiter ratelimit.Limiter, policy ratelimit.Policy) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		subject := "ip:" + c.ClientIP()
+		if userID := c.GetUint("user_id"); userID != 0 {
+			subject = "user:" + strconv.FormatUint(uint64(userID), 10)
+		}
 
-	return limiter
-}
+		result, err := limiter.Allow(c.Request.Context(), policy.Name+":"+subject, policy)
+		if err != nil {
+			slog.ErrorContext(c.Request.Context(), "rate limiter unavailable, request allowed", "policy", policy.Name, "error", err)
+			c.Next()
+			return
+		}
 
-func RateLimit() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		limiter := getLimiter(c.ClientIP())
-		if !limiter.Allow() {
-			response.JSON(c, http.StatusTooManyRequests, "too many requests", gin.H{
-				"retry_after": "1s",
-			})
+		reset := int(math.Ceil(result.Reset.Seconds()))
+		c.Header("RateLimit-Policy", policy.String())
+		c.Header("RateLimit-Limit", strconv.Itoa(result.Limit))
+		c.Header("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+		c.Header("RateLimit-Reset", strconv.Itoa(reset))
+
+		if !result.Allowed {
+			metrics.RateLimitRejections.WithLabelValues(policy.Name).Inc()
+			c.Header("Retry-After", strconv.Itoa(reset))
+			_ = c.Error(errcode.ErrTooManyRequests.WithData(gin.H{
+				"policy":      policy.Name,
+				"retry_after": fmt.Sprintf("%ds", reset),
+			}))
 			c.Abort()
 			return
 		}
@@ -0,0 +1,149 @@
+package middleware
+
+import (
+	"lesson10/internal/pkg/errcode"
+	"lesson10/internal/pkg/logger"
+	"lesson10/internal/pkg/metrics"
+	"lesson10/internal/pkg/tracing"
+	"lesson10/internal/pkg/utils"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const RequestIDHeader = "X-Request-ID"
+
+// RequestID 沿用网关传进来的 X-Request-ID，没有或格式不对就生成一个，响应头里原样带回。
+// 要挂在最前面，后面所有日志都靠它带上 request_id
+func RequestID() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(RequestIDHeader)
+		if !validRequestID(id) {
+			id, _ = utils.NewToken(16)
+		}
+
+		c.Header(RequestIDHeader, id)
+		c.Set("request_id", id)
+		c.Request = c.Request.WithContext(logger.WithRequestID(c.Request.Context(), id))
+		c.Next()
+	}
+}
+
+// validRequestID 外部传进来的值会写进日志，只接受长度有限的常见字符
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 64 {
+		return false
+	}
+	for _, r := range id {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-', r == '_', r == '.', r == ':':
+		default:
+			return false
+		}
+	}
+
+	return true
+}
+
+// quietRoutes 探针和指标抓取太频繁，不打访问日志也不开 span
+var quietRoutes = map[string]bool{
+	"/healthz": true,
+	"/readyz":  true,
+	"/metrics": true,
+}
+
+// Observe 每个请求一个 server span，记录按路由模板统计的耗时和状态码，结束时打一条访问日志。
+// 挂在 RequestID 之后、Errors 和 Recovery 之前，panic 转成的 500 也能记进去
+func Observe() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		if quietRoutes[route] {
+			c.Next()
+			return
+		}
+
+		start := time.Now()
+		ctx := otel.GetTextMapPropagator().Extract(c.Request.Context(), propagation.HeaderCarrier(c.Request.Header))
+		ctx, span := tracing.Start(ctx, c.Request.Method+" "+route,
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				attribute.String("http.request.method", c.Request.Method),
+				attribute.String("http.route", route),
+				attribute.String("url.path", c.Request.URL.Path),
+				attribute.String("client.address", c.ClientIP()),
+			),
+		)
+		defer span.End()
+		c.Request = c.Request.WithContext(ctx)
+
+		c.Next()
+
+		status := c.Writer.Status()
+		latency := time.Since(start)
+		size := max(c.Writer.Size(), 0)
+		metrics.HTTPRequestDuration.WithLabelValues(c.Request.Method, route, strconv.Itoa(status)).Observe(latency.Seconds())
+
+		span.SetAttributes(attribute.Int("http.response.status_code", status))
+		if status >= http.StatusInternalServerError {
+			span.SetStatus(codes.Error, http.StatusText(status))
+		}
+
+		level := slog.LevelInfo
+		switch {
+		case status >= http.StatusInternalServerError:
+			level = slog.LevelError
+		case status >= http.StatusBadRequest:
+			level = slog.LevelWarn
+		}
+		attrs := []any{
+			"method", c.Request.Method,
+			"route", route,
+			"path", c.Request.URL.Path,
+			"status", status,
+			"latency_ms", latency.Milliseconds(),
+			"bytes", size,
+			"client_ip", c.ClientIP(),
+		}
+		if errs := c.Errors.ByType(gin.ErrorTypeAny); len(errs) > 0 {
+			attrs = append(attrs, "error", errs.String())
+		}
+		slog.Log(c.Request.Context(), level, "http request", attrs...)
+	}
+}
+
+// Recovery 代替 gin.Recovery：panic 带着请求上下文和堆栈打一条错误日志，交给 Errors 返回 500
+func Recovery() gin.HandlerFunc {
+	return gin.CustomRecoveryWithWriter(nil, func(c *gin.Context, err any) {
+		slog.ErrorContext(c.Request.Context(), "panic recovered",
+			"panic", err,
+			"stack", string(debug.Stack()),
+		)
+		_ = c.Error(errcode.ErrInternal)
+		c.Abort()
+	})
+}
+
+// setIdentity 鉴权通过后写进 gin.Context，同时记到日志字段和当前 span 上
+func setIdentity(c *gin.Context, userID uint, username string, role uint, sessionID string, accessToken string) {
+	c.Set("user_id", userID)
+	c.Set("username", username)
+	c.Set("role", role)
+	c.Set("session_id", sessionID)
+	c.Set("access_token", accessToken)
+
+	ctx := c.Request.Context()
+	logger.SetUser(ctx, userID, sessionID)
+	trace.SpanFromContext(ctx).SetAttributes(attribute.Int64("enduser.id", int64(userID)))
+}
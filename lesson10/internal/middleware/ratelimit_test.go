@@ -0,0 +1,127 @@
+package middleware
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"lesson10/internal/pkg/errcode"
+	"lesson10/internal/pkg/ratelimit"
+	"lesson10/internal/pkg/response"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// stubLimiter 按顺序返回预设的结果，记下收到的 key
+type stubLimiter struct {
+	results []ratelimit.Result
+	err     error
+	keys    []string
+}
+
+func (l *stubLimiter) Allow(_ context.Context, key string, _ ratelimit.Policy) (ratelimit.Result, error) {
+	l.keys = append(l.keys, key)
+	if l.err != nil {
+		return ratelimit.Result{}, l.err
+	}
+	result := l.results[0]
+	l.results = l.results[1:]
+	return result, nil
+}
+
+func serveRateLimited(t *testing.T, limiter ratelimit.Limiter, userID uint) *httptest.ResponseRecorder {
+	t.Helper()
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(RequestID(), Errors())
+	r.GET("/", func(c *gin.Context) {
+		if userID != 0 {
+			c.Set("user_id", userID)
+		}
+	}, RateLimit(limiter, ratelimit.Policy{Name: "login", Limit: 2, Window: time.Minute}), func(c *gin.Context) {
+		response.OK(c, nil)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = "192.0.2.1:1234"
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestRateLimitHeaders(t *testing.T) {
+	limiter := &stubLimiter{results: []ratelimit.Result{
+		{Allowed: true, Limit: 2, Remaining: 1, Reset: 59 * time.Second},
+		{Allowed: false, Limit: 2, Remaining: 0, Reset: 1500 * time.Millisecond},
+	}}
+
+	w := serveRateLimited(t, limiter, 0)
+	if w.Code != http.StatusOK {
+		t.Fatalf("first status = %d", w.Code)
+	}
+	want := map[string]string{
+		"RateLimit-Policy":    "2;w=60",
+		"RateLimit-Limit":     "2",
+		"RateLimit-Remaining": "1",
+		"RateLimit-Reset":     "59",
+		"Retry-After":         "",
+	}
+	for name, value := range want {
+		if got := w.Header().Get(name); got != value {
+			t.Errorf("%s = %q, want %q", name, got, value)
+		}
+	}
+
+	// 不到一秒的剩余时间向上取整，免得客户端立刻重试又被拒
+	w = serveRateLimited(t, limiter, 0)
+	if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") != "2" || w.Header().Get("RateLimit-Remaining") != "0" {
+		t.Fatalf("status = %d, headers = %v", w.Code, w.Header())
+	}
+
+	var resp struct {
+		Code int `json:"code"`
+		Data struct {
+			Policy     string `json:"policy"`
+			RetryAfter string `json:"retry_after"`
+		} `json:"data"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Code != errcode.ErrTooManyRequests.Code || resp.Data.Policy != "login" || resp.Data.RetryAfter != "2s" {
+		t.Fatalf("body = %s", w.Body.String())
+	}
+
+	if limiter.keys[0] != "login:ip:192.0.2.1" {
+		t.Fatalf("key = %q", limiter.keys[0])
+	}
+}
+
+func TestRateLimitKeysByUser(t *testing.T) {
+	limiter := &stubLimiter{results: []ratelimit.Result{{Allowed: true, Limit: 2, Remaining: 1}}}
+	serveRateLimited(t, limiter, 42)
+	if limiter.keys[0] != "login:user:42" {
+		t.Fatalf("key = %q", limiter.keys[0])
+	}
+}
+
+// TestRateLimitFailsOpen 限流后端出错时放行，也不写限流响应头
+func TestRateLimitFailsOpen(t *testing.T) {
+	w := serveRateLimited(t, &stubLimiter{err: errors.New("redis down")}, 0)
+	if w.Code != http.StatusOK || w.Header().Get("RateLimit-Limit") != "" {
+		t.Fatalf("status = %d, headers = %v", w.Code, w.Header())
+	}
+}
+
+func TestRateLimitWithMemoryLimiter(t *testing.T) {
+	limiter := ratelimit.NewMemoryLimiter()
+	for i, want := range []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests} {
+		if w := serveRateLimited(t, limiter, 0); w.Code != want {
+			t.Fatalf("request %d status = %d, want %d", i, w.Code, want)
+		}
+	}
+}
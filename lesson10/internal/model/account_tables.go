@@ -0,0 +1,37 @@
+package model
+
+import "time"
+
+type DataExport struct {
+	ID          int64      `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID      int64      `gorm:"index;not null" json:"user_id"`
+	Status      string     `gorm:"size:32;index;not null" json:"status"`
+	TokenHash   string     `gorm:"size:64;uniqueIndex;not null" json:"-"`
+	FilePath    string     `gorm:"size:255" json:"-"`
+	FileSize    int64      `json:"file_size"`
+	Error       string     `gorm:"size:255" json:"error,omitempty"`
+	ExpiresAt   time.Time  `json:"expires_at"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+type AccountDeletion struct {
+	ID          int64      `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID      int64      `gorm:"index;not null" json:"user_id"`
+	Status      string     `gorm:"size:32;index;not null" json:"status"`
+	Policy      string     `gorm:"size:32;not null" json:"policy"`
+	ScheduledAt time.Time  `gorm:"index" json:"scheduled_at"`
+	CancelledAt *time.Time `json:"cancelled_at,omitempty"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at"`
+}
+
+func (DataExport) TableName() string {
+	return "data_exports"
+}
+
+func (AccountDeletion) TableName() string {
+	return "account_deletions"
+}
@@ -0,0 +1,24 @@
+package model
+
+import "time"
+
+const (
+	EmailTokenVerify        = "verify_email"
+	EmailTokenPasswordReset = "password_reset"
+)
+
+// EmailToken 邮件里的一次性链接，只保存 token 的哈希
+type EmailToken struct {
+	ID        int64      `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID    int64      `gorm:"index;not null" json:"user_id"`
+	Purpose   string     `gorm:"size:32;index;not null" json:"purpose"`
+	Email     string     `gorm:"size:191;not null" json:"email"`
+	TokenHash string     `gorm:"size:64;uniqueIndex;not null" json:"-"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+func (EmailToken) TableName() string {
+	return "email_tokens"
+}
@@ -0,0 +1,44 @@
+package model
+
+import "time"
+
+const (
+	OAuthPurposeLogin = "login"
+	OAuthPurposeLink  = "link"
+)
+
+// UserIdentity 外部账号（provider + sub）与本地用户的绑定，每个 provider 每个用户最多绑一个
+type UserIdentity struct {
+	ID        int64     `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID    int64     `gorm:"not null;uniqueIndex:idx_user_identities_user_provider,priority:1" json:"user_id"`
+	Provider  string    `gorm:"size:32;not null;uniqueIndex:idx_user_identities_user_provider,priority:2;uniqueIndex:idx_user_identities_provider_subject,priority:1" json:"provider"`
+	Subject   string    `gorm:"size:191;not null;uniqueIndex:idx_user_identities_provider_subject,priority:2" json:"subject"`
+	Email     string    `gorm:"size:191" json:"email"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// OAuthState 一次授权流程的服务端状态：state 只存哈希，PKCE verifier 和 nonce 不下发给浏览器
+type OAuthState struct {
+	ID           int64      `gorm:"primaryKey;autoIncrement" json:"id"`
+	StateHash    string     `gorm:"size:64;uniqueIndex;not null" json:"-"`
+	Provider     string     `gorm:"size:32;not null" json:"provider"`
+	Purpose      string     `gorm:"size:16;not null" json:"purpose"` // login/link
+	UserID       int64      `gorm:"index" json:"user_id"`            // link 时发起绑定的用户
+	Nonce        string     `gorm:"size:64;not null" json:"-"`
+	CodeVerifier string     `gorm:"size:128;not null" json:"-"`
+	DeviceID     string     `gorm:"size:128" json:"device_id"`
+	DeviceName   string     `gorm:"size:128" json:"device_name"`
+	RememberMe   bool       `gorm:"not null;default:false" json:"remember_me"`
+	ExpiresAt    time.Time  `json:"expires_at"`
+	UsedAt       *time.Time `json:"used_at,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
+}
+
+func (UserIdentity) TableName() string {
+	return "user_identities"
+}
+
+func (OAuthState) TableName() string {
+	return "oauth_states"
+}
@@ -0,0 +1,23 @@
+package model
+
+import "time"
+
+const (
+	LoginThrottleAccount = "account"
+	LoginThrottleIP      = "ip"
+)
+
+// LoginThrottle 按账号（小写用户名）或 IP 统计连续失败次数，LockedUntil 之前拒绝登录
+type LoginThrottle struct {
+	ID           int64      `gorm:"primaryKey;autoIncrement" json:"id"`
+	Scope        string     `gorm:"size:16;not null;uniqueIndex:idx_login_throttles_scope_key,priority:1" json:"scope"`
+	Key          string     `gorm:"size:191;not null;uniqueIndex:idx_login_throttles_scope_key,priority:2" json:"key"`
+	Failures     int        `gorm:"not null;default:0" json:"failures"`
+	LastFailedAt time.Time  `json:"last_failed_at"`
+	LockedUntil  *time.Time `gorm:"index" json:"locked_until,omitempty"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+}
+
+func (LoginThrottle) TableName() string {
+	return "login_throttles"
+}
@@ -17,13 +17,15 @@ const (
 type User struct {
 	gorm.Model
 
-	Username     string     `gorm:"size:64;uniqueIndex;not null" json:"username"`
-	PasswordHash string     `gorm:"size:255;not null" json:"-"`
-	TokenVersion int        `gorm:"not null;default:0" json:"-"`
-	AvatarURL    string     `gorm:"size:255" json:"avatar_url,omitempty"`
-	Profile      string     `gorm:"size:255" json:"profile,omitempty"`
-	Role         Role       `gorm:"not null;default:0" json:"role"`
-	VIPExpiresAt *time.Time `gorm:"column:vip_expires_at" json:"vip_expires_at,omitempty"`
+	Username        string     `gorm:"size:64;uniqueIndex;not null" json:"username"`
+	PasswordHash    string     `gorm:"size:255;not null" json:"-"`
+	TokenVersion    int        `gorm:"not null;default:0" json:"-"`
+	Email           *string    `gorm:"size:191;uniqueIndex" json:"-"`
+	EmailVerifiedAt *time.Time `json:"-"`
+	AvatarURL       string     `gorm:"size:255" json:"avatar_url,omitempty"`
+	Profile         string     `gorm:"size:255" json:"profile,omitempty"`
+	Role            Role       `gorm:"not null;default:0" json:"role"`
+	VIPExpiresAt    *time.Time `gorm:"column:vip_expires_at" json:"vip_expires_at,omitempty"`
 
 	Posts         []Post         `gorm:"foreignKey:AuthorID"`
 	Comments      []Comment      `gorm:"foreignKey:AuthorID"`
@@ -52,6 +54,11 @@ type Post struct {
 	IsDeleted uint8    `gorm:"not null;default:0;index" json:"-"`
 	Status    uint8    `gorm:"not null;default:0;index" json:"status"` // 0发布 1草稿
 
+	// ContentHTML 是 Content 渲染并过滤后的缓存，RenderVersion 落后于 markdown.Version 时重新渲染
+	ContentHTML   string `gorm:"type:longtext" json:"-"`
+	Excerpt       string `gorm:"size:512;not null;default:''" json:"excerpt"`
+	RenderVersion int    `gorm:"not null;default:0;index" json:"-"`
+
 	Author    User `gorm:"foreignKey:AuthorID"`
 	LikeCount uint `gorm:"default:0" json:"like_count"`
 }
@@ -64,6 +71,9 @@ const (
 	CommentOnComment  CommentTargetType = 3
 )
 
+// CommentMaxDepth 评论楼中楼最多嵌套的层数，一级评论 Depth 为 1
+const CommentMaxDepth = 7
+
 type Comment struct {
 	gorm.Model
 
@@ -76,12 +86,52 @@ type Comment struct {
 	LikeCount  uint              `gorm:"default:0" json:"like_count"`
 }
 
+const (
+	ImageKindArticle = "article"
+	ImageKindAvatar  = "avatar"
+)
+
+// PostImage 每次上传一条记录（头像和文章图片都记），同样内容的文件按 ContentHash 只存一份；文章图片在关联帖子之前 PostID 为 0
 type PostImage struct {
-	gorm.Model
+	ID        uint           `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time      `gorm:"index:idx_post_images_created_at" json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
+
+	PostID      uint           `gorm:"not null;index" json:"post_id"`
+	UploaderID  uint           `gorm:"not null;index" json:"uploader_id"`
+	Kind        string         `gorm:"size:16;not null;default:article;index:idx_post_images_kind_hash,priority:1" json:"kind"`
+	ContentHash string         `gorm:"size:64;not null;default:'';index:idx_post_images_kind_hash,priority:2" json:"content_hash"`
+	URL         string         `gorm:"size:512;not null" json:"url"`
+	Width       int            `gorm:"not null;default:0" json:"width"`
+	Height      int            `gorm:"not null;default:0" json:"height"`
+	Size        int64          `gorm:"not null;default:0" json:"size"`
+	Variants    []ImageVariant `gorm:"serializer:json;type:text" json:"variants"`
+}
 
-	PostID     uint   `gorm:"not null;index" json:"post_id"`
-	UploaderID uint   `gorm:"not null;index" json:"uploader_id"`
-	URL        string `gorm:"size:512;not null" json:"url"`
+// ImageVariant 一个尺寸档位，WebPURL 为空表示该档位没有更小的 WebP 版本
+type ImageVariant struct {
+	Name    string `json:"name"`
+	URL     string `json:"url"`
+	WebPURL string `json:"webp_url,omitempty"`
+	Width   int    `json:"width"`
+	Height  int    `json:"height"`
+}
+
+// ImageBlob 同一类型、同一内容的一组文件只存一份，每组对应一行。复用已有文件和清理孤儿文件都先锁住这一行，
+// 避免刚复用的文件被清理任务删掉
+type ImageBlob struct {
+	Kind        string    `gorm:"primaryKey;size:16" json:"kind"`
+	ContentHash string    `gorm:"primaryKey;size:64" json:"content_hash"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// PostImageRef 帖子正文里引用了哪些上传的图片，帖子保存时按 markdown 图片链接重建；没有被未删除帖子引用的文章图片过了宽限期会被清理
+type PostImageRef struct {
+	ID        uint      `gorm:"primaryKey"`
+	PostID    uint      `gorm:"not null;uniqueIndex:uk_post_image_ref,priority:1" json:"post_id"`
+	ImageID   uint      `gorm:"not null;uniqueIndex:uk_post_image_ref,priority:2;index" json:"image_id"`
+	CreatedAt time.Time `json:"created_at"`
 }
 
 type UserFollow struct {
@@ -198,8 +248,8 @@ type RefreshToken struct {
 	SessionID         string     `gorm:"size:64;index;not null" json:"session_id"`
 	UserID            int64      `gorm:"index;not null" json:"user_id"`
 	TokenHash         string     `gorm:"size:64;uniqueIndex;not null" json:"token_hash"`
-	Status            string     `gorm:"size:32;index;not null" json:"status"`
-	ExpiresAt         time.Time  `json:"expires_at"`
+	Status            string     `gorm:"size:32;index;index:idx_refresh_tokens_status_updated_at,priority:1;not null" json:"status"`
+	ExpiresAt         time.Time  `gorm:"index" json:"expires_at"`
 	UsedAt            *time.Time `json:"used_at,omitempty"`
 	RevokedAt         *time.Time `json:"revoked_at,omitempty"`
 	RevokeReason      string     `gorm:"size:128" json:"revoke_reason"`
@@ -207,28 +257,29 @@ type RefreshToken struct {
 	LastUsedIP        string     `gorm:"size:64" json:"last_used_ip"`
 	LastUsedUserAgent string     `gorm:"size:512" json:"last_used_user_agent"`
 	CreatedAt         time.Time  `json:"created_at"`
-	UpdatedAt         time.Time  `json:"updated_at"`
+	UpdatedAt         time.Time  `gorm:"index:idx_refresh_tokens_status_updated_at,priority:2" json:"updated_at"`
 }
 
 type SecurityEvent struct {
 	ID        int64     `gorm:"primaryKey;autoIncrement" json:"id"`
-	UserID    int64     `gorm:"index;not null" json:"user_id"`
+	UserID    int64     `gorm:"index;index:idx_security_events_user_type_created,priority:1;not null" json:"user_id"`
 	SessionID string    `gorm:"size:64;index" json:"session_id"`
-	EventType string    `gorm:"size:64;index;not null" json:"event_type"`
+	EventType string    `gorm:"size:64;index;index:idx_security_events_user_type_created,priority:2;not null" json:"event_type"`
 	IP        string    `gorm:"size:64" json:"ip"`
 	DeviceID  string    `gorm:"size:128" json:"device_id"`
 	UserAgent string    `gorm:"size:512" json:"user_agent"`
 	Detail    string    `gorm:"type:text" json:"detail"`
-	CreatedAt time.Time `json:"created_at"`
+	CreatedAt time.Time `gorm:"index;index:idx_security_events_user_type_created,priority:3" json:"created_at"`
 }
 
 type Session struct {
 	ID                   int64      `gorm:"primaryKey;autoIncrement" json:"id"`
 	SessionID            string     `gorm:"size:64;uniqueIndex;not null" json:"session_id"`
 	UserID               int64      `gorm:"index;not null" json:"user_id"`
-	Status               string     `gorm:"size:32;index;not null" json:"status"`
+	Status               string     `gorm:"size:32;index;index:idx_sessions_status_revoked_at,priority:1;not null" json:"status"`
 	DeviceID             string     `gorm:"size:128;index" json:"device_id"`
 	DeviceName           string     `gorm:"size:128" json:"device_name"`
+	Label                string     `gorm:"size:64" json:"label"`
 	UserAgent            string     `gorm:"size:512" json:"user_agent"`
 	BrowserName          string     `gorm:"size:64" json:"browser_name"`
 	BrowserVersion       string     `gorm:"size:64" json:"browser_version"`
@@ -237,11 +288,19 @@ type Session struct {
 	BrowserKey           string     `gorm:"size:191;index" json:"browser_key"`
 	LoginIP              string     `gorm:"size:64" json:"login_ip"`
 	LastIP               string     `gorm:"size:64" json:"last_ip"`
-	LastSeenAt           time.Time  `json:"last_seen_at"`
+	LastSeenAt           time.Time  `gorm:"index" json:"last_seen_at"`
+	RememberMe           bool       `gorm:"not null;default:false" json:"remember_me"`
 	CurrentAccessJTI     string     `gorm:"size:64;index" json:"current_access_jti"`
 	CurrentAccessExpires time.Time  `json:"current_access_expires"`
-	RevokedAt            *time.Time `json:"revoked_at,omitempty"`
+	RevokedAt            *time.Time `gorm:"index:idx_sessions_status_revoked_at,priority:2" json:"revoked_at,omitempty"`
 	RevokeReason         string     `gorm:"size:128" json:"revoke_reason"`
 	CreatedAt            time.Time  `json:"created_at"`
 	UpdatedAt            time.Time  `json:"updated_at"`
 }
+
+// DailyActiveUser 每个用户每天一行，登录、刷新和带 token 的请求都会记上；
+// sessions.last_seen_at 只保留最后一次活跃时间，算不出过去每天的日活
+type DailyActiveUser struct {
+	Day    time.Time `gorm:"type:date;primaryKey;autoIncrement:false" json:"day"`
+	UserID int64     `gorm:"primaryKey;autoIncrement:false" json:"user_id"`
+}
@@ -0,0 +1,50 @@
+package model
+
+import "time"
+
+// UserTOTP EnabledAt 为空表示已生成密钥但还没有用验证码确认
+type UserTOTP struct {
+	ID           int64      `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID       int64      `gorm:"uniqueIndex;not null" json:"user_id"`
+	Secret       string     `gorm:"size:64;not null" json:"-"`
+	EnabledAt    *time.Time `json:"enabled_at,omitempty"`
+	LastUsedStep int64      `gorm:"not null;default:0" json:"-"` // 最近一次通过校验的时间步，防止验证码重放
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+}
+
+type TOTPRecoveryCode struct {
+	ID        int64      `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID    int64      `gorm:"index;not null" json:"user_id"`
+	CodeHash  string     `gorm:"size:64;uniqueIndex;not null" json:"-"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// LoginChallenge 密码校验通过后发给客户端的中间凭证，记录登录时的设备信息，第二步用它换取正式会话
+type LoginChallenge struct {
+	ID         int64      `gorm:"primaryKey;autoIncrement" json:"id"`
+	UserID     int64      `gorm:"index;not null" json:"user_id"`
+	TokenHash  string     `gorm:"size:64;uniqueIndex;not null" json:"-"`
+	DeviceID   string     `gorm:"size:128" json:"device_id"`
+	DeviceName string     `gorm:"size:128" json:"device_name"`
+	RememberMe bool       `gorm:"not null;default:false" json:"remember_me"`
+	IP         string     `gorm:"size:64" json:"ip"`
+	UserAgent  string     `gorm:"size:512" json:"user_agent"`
+	Attempts   int        `gorm:"not null;default:0" json:"attempts"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	UsedAt     *time.Time `json:"used_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+func (UserTOTP) TableName() string {
+	return "user_totps"
+}
+
+func (TOTPRecoveryCode) TableName() string {
+	return "totp_recovery_codes"
+}
+
+func (LoginChallenge) TableName() string {
+	return "login_challenges"
+}
@@ -0,0 +1,185 @@
+// Package cache 读多写少数据的 cache-aside 缓存：值按 JSON 存，过期时间加随机抖动，
+// 同一个 key 同时只回源一次；单机用内存实现，多实例部署用 Redis 实现
+package cache
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"log/slog"
+	mathrand "math/rand/v2"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"golang.org/x/sync/singleflight"
+)
+
+// Store 只管存取字节，miss 时 ok 为 false
+type Store interface {
+	Get(ctx context.Context, key string) (value []byte, ok bool, err error)
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	// SetNX key 不存在时才写入，返回是否写入
+	SetNX(ctx context.Context, key string, value []byte, ttl time.Duration) (bool, error)
+	Delete(ctx context.Context, keys ...string) error
+}
+
+const (
+	// jitterRatio 过期时间在 ±10% 内随机，避免同一批 key 同时过期一起回源
+	jitterRatio = 0.1
+	// versionTTL 版本号的过期时间；版本号过期只会让下一次读取多回源一次
+	versionTTL = 24 * time.Hour
+)
+
+// Cache 每个 key 有一个存在 Store 里的随机版本号，值实际存在 key#版本号 下面。
+// Delete 换一个新版本号：删除之前开始的回源（不管在哪个实例上）写回的是旧版本，没有人再读，
+// 旧数据不会盖住删除
+type Cache struct {
+	store Store
+	group singleflight.Group
+}
+
+func New(store Store) *Cache {
+	return &Cache{store: store}
+}
+
+type Config struct {
+	Backend          string `yaml:"backend" env:"CACHE_BACKEND"`
+	MemoryMaxEntries int    `yaml:"memory_max_entries" env:"CACHE_MEMORY_MAX_ENTRIES"`
+}
+
+// NewFromConfig 根据 Backend 选择实现：memory（默认）、redis，或 off 关闭缓存（返回 nil，所有读取直接回源）
+func NewFromConfig(cfg Config, client *redis.Client) *Cache {
+	switch cfg.Backend {
+	case "off":
+		return nil
+	case "redis":
+		if client != nil {
+			return New(NewRedisStore(client, "cache:"))
+		}
+	}
+
+	return New(NewMemoryStore(cfg.MemoryMaxEntries))
+}
+
+// Fetch 先查缓存，miss 时调用 load 并把结果写回；c 为 nil 时直接 load。
+// 缓存读写出错只记日志，不影响正常返回；load 返回的错误不缓存
+func Fetch[T any](ctx context.Context, c *Cache, key string, ttl time.Duration, load func(ctx context.Context) (T, error)) (T, error) {
+	if c == nil {
+		return load(ctx)
+	}
+
+	version, err := c.version(ctx, key)
+	if err != nil {
+		slog.WarnContext(ctx, "cache get version failed", "key", key, "error", err)
+		return load(ctx)
+	}
+	if version == "" {
+		// 版本号刚建好就被淘汰了，这次不缓存
+		return load(ctx)
+	}
+	dataKey := key + "#" + version
+
+	var value T
+	if raw, ok, err := c.store.Get(ctx, dataKey); err != nil {
+		slog.WarnContext(ctx, "cache get failed", "key", key, "error", err)
+	} else if ok {
+		if err := json.Unmarshal(raw, &value); err == nil {
+			return value, nil
+		}
+		slog.WarnContext(ctx, "cache decode failed", "key", key, "error", err)
+	}
+
+	// 回源不跟着单个请求取消，否则第一个请求断开会让同时等待的其它请求一起失败；
+	// 按版本合并，删除之后的请求不会等到删除之前开始的回源
+	loadCtx := context.WithoutCancel(ctx)
+	result, err, _ := c.group.Do(dataKey, func() (any, error) {
+		loaded, err := load(loadCtx)
+		if err != nil {
+			return nil, err
+		}
+
+		raw, err := json.Marshal(loaded)
+		if err != nil {
+			slog.WarnContext(ctx, "cache encode failed", "key", key, "error", err)
+			return loaded, nil
+		}
+		if err := c.store.Set(loadCtx, dataKey, raw, jitter(ttl)); err != nil {
+			slog.WarnContext(ctx, "cache set failed", "key", key, "error", err)
+		}
+
+		return loaded, nil
+	})
+	if err != nil {
+		return value, err
+	}
+
+	return result.(T), nil
+}
+
+// Delete 写路径在数据库提交之后调用：给每个 key 换新版本号，再删掉旧版本的值
+func (c *Cache) Delete(ctx context.Context, keys ...string) {
+	if c == nil || len(keys) == 0 {
+		return
+	}
+
+	old := make([]string, 0, len(keys))
+	for _, key := range keys {
+		raw, ok, err := c.store.Get(ctx, versionKey(key))
+		if err != nil {
+			slog.WarnContext(ctx, "cache get version failed", "key", key, "error", err)
+		}
+		if err := c.store.Set(ctx, versionKey(key), []byte(newVersion()), versionTTL); err != nil {
+			slog.WarnContext(ctx, "cache bump version failed", "key", key, "error", err)
+			continue
+		}
+		if ok {
+			old = append(old, key+"#"+string(raw))
+		}
+	}
+
+	// 旧版本已经没人读了，删不掉也只是等它过期
+	if len(old) == 0 {
+		return
+	}
+	if err := c.store.Delete(ctx, old...); err != nil {
+		slog.WarnContext(ctx, "cache delete failed", "keys", keys, "error", err)
+	}
+}
+
+// version key 当前的版本号，还没有时建一个；别的请求同时建好了就用它的
+func (c *Cache) version(ctx context.Context, key string) (string, error) {
+	raw, ok, err := c.store.Get(ctx, versionKey(key))
+	if err != nil || ok {
+		return string(raw), err
+	}
+
+	version := newVersion()
+	created, err := c.store.SetNX(ctx, versionKey(key), []byte(version), versionTTL)
+	if err != nil || created {
+		return version, err
+	}
+
+	raw, _, err = c.store.Get(ctx, versionKey(key))
+	return string(raw), err
+}
+
+func versionKey(key string) string {
+	return "ver:" + key
+}
+
+// newVersion 随机生成，不用自增计数：版本号过期或者被淘汰后重新建，也不会撞上还没过期的旧值
+func newVersion() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+func jitter(ttl time.Duration) time.Duration {
+	spread := time.Duration(float64(ttl) * jitterRatio)
+	if spread <= 0 {
+		return ttl
+	}
+
+	return ttl - spread + mathrand.N(2*spread+1)
+}
@@ -0,0 +1,235 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+type fakeClock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *fakeClock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+func newTestStore(maxEntries int) (*MemoryStore, *fakeClock) {
+	clock := &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	store := NewMemoryStore(maxEntries)
+	store.now = clock.Now
+	return store, clock
+}
+
+// counter load 返回调用次数，用来看有没有回源
+func counter(calls *atomic.Int32) func(context.Context) (int, error) {
+	return func(context.Context) (int, error) {
+		return int(calls.Add(1)), nil
+	}
+}
+
+func TestFetchCachesUntilDelete(t *testing.T) {
+	store, clock := newTestStore(100)
+	c := New(store)
+	ctx := context.Background()
+	var calls atomic.Int32
+
+	for i := 0; i < 3; i++ {
+		if got, err := Fetch(ctx, c, "post:1", time.Minute, counter(&calls)); err != nil || got != 1 {
+			t.Fatalf("fetch %d = %d, %v", i, got, err)
+		}
+	}
+
+	c.Delete(ctx, "post:1")
+	if got, _ := Fetch(ctx, c, "post:1", time.Minute, counter(&calls)); got != 2 {
+		t.Fatalf("after delete = %d, want reload", got)
+	}
+
+	// 过期时间带 ±10% 抖动，过了 1.1 倍一定过期
+	clock.Advance(66 * time.Second)
+	if got, _ := Fetch(ctx, c, "post:1", time.Minute, counter(&calls)); got != 3 {
+		t.Fatalf("after expiry = %d, want reload", got)
+	}
+
+	// 删除时旧版本的值一起清掉，只剩版本号和新值
+	c.Delete(ctx, "post:1")
+	if len(store.entries) != 1 {
+		t.Fatalf("entries after delete = %v", store.entries)
+	}
+}
+
+func TestFetchNilCache(t *testing.T) {
+	var calls atomic.Int32
+	for i := 1; i <= 2; i++ {
+		if got, _ := Fetch(context.Background(), nil, "k", time.Minute, counter(&calls)); got != i {
+			t.Fatalf("fetch = %d, want %d", got, i)
+		}
+	}
+	var c *Cache
+	c.Delete(context.Background(), "k")
+}
+
+func TestFetchDoesNotCacheErrors(t *testing.T) {
+	store, _ := newTestStore(100)
+	c := New(store)
+	ctx := context.Background()
+	errLoad := errors.New("db down")
+
+	_, err := Fetch(ctx, c, "k", time.Minute, func(context.Context) (string, error) { return "", errLoad })
+	if !errors.Is(err, errLoad) {
+		t.Fatalf("err = %v", err)
+	}
+	got, err := Fetch(ctx, c, "k", time.Minute, func(context.Context) (string, error) { return "ok", nil })
+	if err != nil || got != "ok" {
+		t.Fatalf("fetch after error = %q, %v", got, err)
+	}
+}
+
+func TestFetchLoadsOncePerKey(t *testing.T) {
+	store, _ := newTestStore(100)
+	c := New(store)
+	ctx := context.Background()
+
+	var calls atomic.Int32
+	release := make(chan struct{})
+	load := func(context.Context) (int, error) {
+		calls.Add(1)
+		<-release
+		return 7, nil
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if got, err := Fetch(ctx, c, "k", time.Minute, load); err != nil || got != 7 {
+				t.Errorf("fetch = %d, %v", got, err)
+			}
+		}()
+	}
+	time.Sleep(20 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if n := calls.Load(); n != 1 {
+		t.Fatalf("load called %d times", n)
+	}
+}
+
+// TestDeleteDuringLoadOnAnotherInstance 两个实例共用一个 Store：A 回源读到旧数据还没写回时，B 提交写入并删除缓存，
+// A 写回的旧值不能被之后的读取看到
+func TestDeleteDuringLoadOnAnotherInstance(t *testing.T) {
+	store, _ := newTestStore(100)
+	a, b := New(store), New(store)
+	ctx := context.Background()
+
+	loading := make(chan struct{})
+	release := make(chan struct{})
+	done := make(chan string)
+	go func() {
+		got, _ := Fetch(ctx, a, "post:1", time.Minute, func(context.Context) (string, error) {
+			close(loading)
+			<-release
+			return "old", nil
+		})
+		done <- got
+	}()
+
+	<-loading
+	b.Delete(ctx, "post:1")
+	close(release)
+	if got := <-done; got != "old" {
+		t.Fatalf("in-flight fetch = %q", got)
+	}
+
+	for _, c := range []*Cache{a, b} {
+		got, _ := Fetch(ctx, c, "post:1", time.Minute, func(context.Context) (string, error) { return "new", nil })
+		if got != "new" {
+			t.Fatalf("fetch after delete = %q, want new", got)
+		}
+	}
+}
+
+// TestEvictedVersionDoesNotResurrectOldValue 版本号被淘汰或过期后重新建的是新的随机值，旧值不会再被读到
+func TestEvictedVersionDoesNotResurrectOldValue(t *testing.T) {
+	store, _ := newTestStore(100)
+	c := New(store)
+	ctx := context.Background()
+
+	if _, err := Fetch(ctx, c, "k", time.Hour, func(context.Context) (string, error) { return "old", nil }); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Delete(ctx, versionKey("k")); err != nil {
+		t.Fatal(err)
+	}
+
+	got, _ := Fetch(ctx, c, "k", time.Hour, func(context.Context) (string, error) { return "new", nil })
+	if got != "new" {
+		t.Fatalf("fetch = %q, want new", got)
+	}
+}
+
+func TestMemoryStore(t *testing.T) {
+	store, clock := newTestStore(3)
+	ctx := context.Background()
+
+	if err := store.Set(ctx, "a", []byte("1"), time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if ok, _ := store.SetNX(ctx, "a", []byte("2"), time.Second); ok {
+		t.Fatal("SetNX overwrote a live key")
+	}
+	if v, ok, _ := store.Get(ctx, "a"); !ok || string(v) != "1" {
+		t.Fatalf("get = %q, %v", v, ok)
+	}
+
+	clock.Advance(time.Second)
+	if _, ok, _ := store.Get(ctx, "a"); ok {
+		t.Fatal("expired key still readable")
+	}
+	if ok, _ := store.SetNX(ctx, "a", []byte("3"), time.Minute); !ok {
+		t.Fatal("SetNX refused an expired key")
+	}
+
+	// 超过上限时先清过期的，再随便淘汰
+	store.Set(ctx, "short", []byte("x"), time.Second)
+	store.Set(ctx, "b", []byte("x"), time.Minute)
+	clock.Advance(2 * time.Second)
+	store.Set(ctx, "c", []byte("x"), time.Minute)
+	if _, ok, _ := store.Get(ctx, "short"); ok || len(store.entries) != 3 {
+		t.Fatalf("entries = %v", store.entries)
+	}
+	store.Set(ctx, "d", []byte("x"), time.Minute)
+	if len(store.entries) != 3 {
+		t.Fatalf("entries = %d, want 3", len(store.entries))
+	}
+
+	store.Delete(ctx, "a", "b", "c", "d")
+	if len(store.entries) != 0 {
+		t.Fatalf("entries after delete = %v", store.entries)
+	}
+}
+
+func TestJitter(t *testing.T) {
+	for i := 0; i < 1000; i++ {
+		if got := jitter(time.Minute); got < 54*time.Second || got > 66*time.Second {
+			t.Fatalf("jitter = %v", got)
+		}
+	}
+	if got := jitter(5); got != 5 {
+		t.Fatalf("tiny ttl jitter = %v", got)
+	}
+}
@@ -0,0 +1,107 @@
+package cache
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+const memorySweepInterval = time.Minute
+
+type memoryEntry struct {
+	value   []byte
+	expires time.Time
+}
+
+// MemoryStore 进程内缓存，只适合单实例部署；条目超过 maxEntries 时先清过期的，还不够再随便淘汰一些
+type MemoryStore struct {
+	mu         sync.Mutex
+	entries    map[string]memoryEntry
+	maxEntries int
+	lastSweep  time.Time
+	now        func() time.Time
+}
+
+func NewMemoryStore(maxEntries int) *MemoryStore {
+	return &MemoryStore{
+		entries:    map[string]memoryEntry{},
+		maxEntries: maxEntries,
+		now:        time.Now,
+	}
+}
+
+func (s *MemoryStore) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	entry, ok := s.entries[key]
+	if !ok {
+		return nil, false, nil
+	}
+	if !s.now().Before(entry.expires) {
+		delete(s.entries, key)
+		return nil, false, nil
+	}
+
+	return entry.value, true, nil
+}
+
+func (s *MemoryStore) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.set(key, value, ttl)
+	return nil
+}
+
+func (s *MemoryStore) SetNX(ctx context.Context, key string, value []byte, ttl time.Duration) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if entry, ok := s.entries[key]; ok && s.now().Before(entry.expires) {
+		return false, nil
+	}
+	s.set(key, value, ttl)
+	return true, nil
+}
+
+// set 调用方持有锁
+func (s *MemoryStore) set(key string, value []byte, ttl time.Duration) {
+	now := s.now()
+	s.sweep(now, false)
+	if _, exists := s.entries[key]; !exists && s.maxEntries > 0 && len(s.entries) >= s.maxEntries {
+		s.sweep(now, true)
+		for other := range s.entries {
+			if len(s.entries) < s.maxEntries {
+				break
+			}
+			delete(s.entries, other)
+		}
+	}
+
+	s.entries[key] = memoryEntry{value: value, expires: now.Add(ttl)}
+}
+
+func (s *MemoryStore) Delete(ctx context.Context, keys ...string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, key := range keys {
+		delete(s.entries, key)
+	}
+	return nil
+}
+
+// sweep 定期清掉过期条目，force 时不看间隔
+func (s *MemoryStore) sweep(now time.Time, force bool) {
+	if !force && now.Sub(s.lastSweep) < memorySweepInterval {
+		return
+	}
+	s.lastSweep = now
+
+	for key, entry := range s.entries {
+		if !now.Before(entry.expires) {
+			delete(s.entries, key)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+// Package dblock 用 MySQL 的命名锁（GET_LOCK）保证多个实例里同一个后台任务只有一个在跑
+package dblock
+
+import (
+	"context"
+	"database/sql"
+	"log/slog"
+	"time"
+)
+
+const (
+	// retryInterval 没抢到锁的实例隔多久再试一次，持有锁的实例挂掉后最多这么久就有别的实例接手
+	retryInterval = 30 * time.Second
+	// pingInterval 持有锁期间检查连接的间隔；连接断开锁就没了，要马上停下
+	pingInterval = 15 * time.Second
+)
+
+// Run 抢到名为 name 的锁后执行 fn，锁一直持有到 fn 返回；没抢到时等一会儿再抢，直到 ctx 取消。
+// fn 自己返回就算完成，Run 也返回；锁中途丢了会取消 fn 的 ctx，等它退出后重新去抢。
+// GET_LOCK 属于连接，所以整个过程占用一个专门的连接
+func Run(ctx context.Context, db *sql.DB, name string, fn func(ctx context.Context)) {
+	for {
+		lost, ran := hold(ctx, db, name, fn)
+		if ran && !lost {
+			return
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(retryInterval):
+		}
+	}
+}
+
+// hold ran 表示抢到了锁并执行过 fn，lost 表示 fn 是因为锁丢了才被取消的
+func hold(ctx context.Context, db *sql.DB, name string, fn func(ctx context.Context)) (lost bool, ran bool) {
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		if ctx.Err() == nil {
+			slog.WarnContext(ctx, "worker lock: get connection failed", "lock", name, "error", err)
+		}
+		return false, false
+	}
+	defer conn.Close()
+
+	var got sql.NullInt64
+	if err := conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, 0)", name).Scan(&got); err != nil {
+		if ctx.Err() == nil {
+			slog.WarnContext(ctx, "worker lock: acquire failed", "lock", name, "error", err)
+		}
+		return false, false
+	}
+	if !got.Valid || got.Int64 != 1 {
+		return false, false
+	}
+	defer func() {
+		if _, err := conn.ExecContext(context.WithoutCancel(ctx), "SELECT RELEASE_LOCK(?)", name); err != nil {
+			slog.WarnContext(ctx, "worker lock: release failed", "lock", name, "error", err)
+		}
+	}()
+	slog.InfoContext(ctx, "worker lock acquired", "lock", name)
+
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		fn(runCtx)
+	}()
+
+	ticker := time.NewTicker(pingInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return false, true
+		case <-ticker.C:
+			if err := conn.PingContext(ctx); err != nil && ctx.Err() == nil {
+				slog.WarnContext(ctx, "worker lock lost, stopping", "lock", name, "error", err)
+				cancel()
+				<-done
+				return true, true
+			}
+		}
+	}
+}
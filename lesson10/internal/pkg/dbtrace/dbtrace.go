@@ -0,0 +1,125 @@
+package dbtrace
+
+import (
+	"errors"
+	"lesson10/internal/pkg/metrics"
+	"lesson10/internal/pkg/tracing"
+	"log/slog"
+	"strings"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"gorm.io/gorm"
+)
+
+const (
+	startKey = "dbtrace:start"
+	spanKey  = "dbtrace:span"
+
+	slowThreshold = 200 * time.Millisecond
+)
+
+// Plugin 挂在 GORM 回调上：每条语句记耗时指标；请求里发出的语句再开一个子 span；
+// 出错或超过 200ms 时带着请求的 request_id / trace_id 打日志，service 层把错误换成 ErrInternal 之后也能查到原因。
+// 查不到记录、唯一键冲突这两种调用方自己会处理，不算错误。日志里只有带占位符的 SQL，不记参数值
+type Plugin struct{}
+
+func (Plugin) Name() string {
+	return "dbtrace"
+}
+
+func (Plugin) Initialize(db *gorm.DB) error {
+	cb := db.Callback()
+	return errors.Join(
+		cb.Create().Before("gorm:create").Register("dbtrace:before_create", before("create")),
+		cb.Create().After("gorm:create").Register("dbtrace:after_create", after("create")),
+		cb.Query().Before("gorm:query").Register("dbtrace:before_query", before("select")),
+		cb.Query().After("gorm:query").Register("dbtrace:after_query", after("select")),
+		cb.Update().Before("gorm:update").Register("dbtrace:before_update", before("update")),
+		cb.Update().After("gorm:update").Register("dbtrace:after_update", after("update")),
+		cb.Delete().Before("gorm:delete").Register("dbtrace:before_delete", before("delete")),
+		cb.Delete().After("gorm:delete").Register("dbtrace:after_delete", after("delete")),
+		cb.Row().Before("gorm:row").Register("dbtrace:before_row", before("row")),
+		cb.Row().After("gorm:row").Register("dbtrace:after_row", after("row")),
+		cb.Raw().Before("gorm:raw").Register("dbtrace:before_raw", before("raw")),
+		cb.Raw().After("gorm:raw").Register("dbtrace:after_raw", after("raw")),
+	)
+}
+
+func before(operation string) func(*gorm.DB) {
+	return func(db *gorm.DB) {
+		db.InstanceSet(startKey, time.Now())
+
+		// 后台任务没有上层 span，不给每条语句单独开一条 trace
+		ctx := db.Statement.Context
+		if !trace.SpanContextFromContext(ctx).IsValid() {
+			return
+		}
+		_, span := tracing.Start(ctx, "db."+operation,
+			trace.WithSpanKind(trace.SpanKindClient),
+			trace.WithAttributes(
+				attribute.String("db.system.name", "mysql"),
+				attribute.String("db.operation.name", operation),
+			),
+		)
+		db.InstanceSet(spanKey, span)
+	}
+}
+
+func after(operation string) func(*gorm.DB) {
+	return func(db *gorm.DB) {
+		table := db.Statement.Table
+		if table == "" {
+			table = "unknown"
+		}
+
+		var elapsed time.Duration
+		if v, ok := db.InstanceGet(startKey); ok {
+			if start, ok := v.(time.Time); ok {
+				elapsed = time.Since(start)
+				metrics.DBQueryDuration.WithLabelValues(operation, table).Observe(elapsed.Seconds())
+			}
+		}
+
+		failed := db.Error != nil && !expected(db.Error)
+
+		if v, ok := db.InstanceGet(spanKey); ok {
+			if span, ok := v.(trace.Span); ok {
+				span.SetName("db." + operation + " " + table)
+				span.SetAttributes(
+					attribute.String("db.collection.name", table),
+					attribute.String("db.query.text", db.Statement.SQL.String()),
+					attribute.Int64("db.response.returned_rows", db.RowsAffected),
+				)
+				if failed {
+					tracing.Fail(span, db.Error)
+				}
+				span.End()
+			}
+		}
+
+		switch {
+		case failed:
+			slog.ErrorContext(db.Statement.Context, "db statement failed",
+				"operation", operation,
+				"table", table,
+				"sql", db.Statement.SQL.String(),
+				"error", db.Error,
+			)
+		case elapsed > slowThreshold:
+			slog.WarnContext(db.Statement.Context, "slow db statement",
+				"operation", operation,
+				"table", table,
+				"sql", db.Statement.SQL.String(),
+				"duration_ms", elapsed.Milliseconds(),
+			)
+		}
+	}
+}
+
+func expected(err error) bool {
+	return errors.Is(err, gorm.ErrRecordNotFound) ||
+		errors.Is(err, gorm.ErrDuplicatedKey) ||
+		strings.Contains(err.Error(), "Duplicate entry")
+}
@@ -0,0 +1,108 @@
+package errcode
+
+import (
+	"errors"
+	"fmt"
+	"lesson10/internal/pkg/i18n"
+	"sort"
+)
+
+// Error 业务错误。Code 是对外稳定的业务码，客户端按它判断，不要按 message 文本判断；
+// Status 是对应的 HTTP 状态码，Key 是文案的 key，返回给客户端的 message 按 Accept-Language 翻译。
+// 包里定义的都是哨兵值，service 直接返回，handler 用 c.Error 交给中间件统一输出。
+// 要附带字段明细、原始错误时用 WithDetails / WithCause 复制一份，errors.Is 按 Code 比较，复制出来的也能匹配
+type Error struct {
+	Code   int
+	Status int
+	Key    string
+	// Details 参数校验失败时每个字段的问题
+	Details []FieldError
+	// Data 原样放进响应的 data，比如限流时告诉客户端多久后重试
+	Data  any
+	cause error
+}
+
+// FieldError Rule 用 binding 标签里的规则名（required / min / max / email / oneof），路径参数格式不对时为 format
+type FieldError struct {
+	Field string `json:"field"`
+	Rule  string `json:"rule"`
+	Param string `json:"param,omitempty"`
+}
+
+var catalog = map[int]*Error{}
+
+// define 只在包初始化时调用：业务码重复、文案缺少中英文任何一种都直接 panic，启动不了
+func define(code int, status int, key string) *Error {
+	if _, ok := catalog[code]; ok {
+		panic(fmt.Sprintf("errcode: duplicate code %d", code))
+	}
+	for _, lang := range []i18n.Lang{i18n.English, i18n.Chinese} {
+		if !i18n.Has(lang, key) {
+			panic(fmt.Sprintf("errcode: message %q has no %s translation", key, lang))
+		}
+	}
+
+	e := &Error{Code: code, Status: status, Key: key}
+	catalog[code] = e
+	return e
+}
+
+// Catalog 按业务码排序的全部错误，生成文档用
+func Catalog() []*Error {
+	list := make([]*Error, 0, len(catalog))
+	for _, e := range catalog {
+		list = append(list, e)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Code < list[j].Code })
+	return list
+}
+
+// Error 英文文案，带着原始错误，只用于日志；返回给客户端的文案用 Message
+func (e *Error) Error() string {
+	msg := i18n.Message(i18n.English, e.Key, "")
+	if e.cause != nil {
+		return msg + ": " + e.cause.Error()
+	}
+	return msg
+}
+
+func (e *Error) Message(lang i18n.Lang) string {
+	return i18n.Message(lang, e.Key, "")
+}
+
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Code == e.Code
+}
+
+func (e *Error) Unwrap() error {
+	return e.cause
+}
+
+// WithCause 记下原始错误，只进日志不返回给客户端
+func (e *Error) WithCause(err error) *Error {
+	c := *e
+	c.cause = err
+	return &c
+}
+
+func (e *Error) WithDetails(details ...FieldError) *Error {
+	c := *e
+	c.Details = append(append([]FieldError(nil), e.Details...), details...)
+	return &c
+}
+
+func (e *Error) WithData(data any) *Error {
+	c := *e
+	c.Data = data
+	return &c
+}
+
+// From 把任意错误转成 *Error，不认识的错误一律当作 ErrInternal，原始错误留作 cause
+func From(err error) *Error {
+	var e *Error
+	if errors.As(err, &e) {
+		return e
+	}
+	return ErrInternal.WithCause(err)
+}
@@ -0,0 +1,69 @@
+package errcode
+
+import (
+	"errors"
+	"fmt"
+	"lesson10/internal/pkg/i18n"
+	"net/http"
+	"testing"
+)
+
+func TestIsMatchesCopiesAndWrappedSentinels(t *testing.T) {
+	cause := errors.New("duplicate key")
+	err := fmt.Errorf("create user: %w", ErrConflict.WithCause(cause).WithData("x"))
+
+	if !errors.Is(err, ErrConflict) {
+		t.Fatal("wrapped copy does not match its sentinel")
+	}
+	if errors.Is(err, ErrNotFound) {
+		t.Fatal("matched a different code")
+	}
+	if !errors.Is(err, cause) {
+		t.Fatal("cause not reachable through Unwrap")
+	}
+
+	// 复制不改动哨兵本身
+	if ErrConflict.cause != nil || ErrConflict.Data != nil {
+		t.Fatalf("sentinel modified: %+v", ErrConflict)
+	}
+	detailed := ErrValidation.WithDetails(FieldError{Field: "username", Rule: "min", Param: "3"})
+	if len(ErrValidation.Details) != 0 || len(detailed.Details) != 1 {
+		t.Fatalf("details = %v / %v", ErrValidation.Details, detailed.Details)
+	}
+}
+
+func TestFrom(t *testing.T) {
+	wrapped := fmt.Errorf("load post: %w", ErrNotFound)
+	if got := From(wrapped); got != ErrNotFound {
+		t.Fatalf("From(wrapped) = %+v, want the sentinel", got)
+	}
+
+	unknown := errors.New("dial tcp: connection refused")
+	got := From(unknown)
+	if got.Code != ErrInternal.Code || got.Status != http.StatusInternalServerError {
+		t.Fatalf("From(unknown) = %d/%d", got.Code, got.Status)
+	}
+	if !errors.Is(got, unknown) {
+		t.Fatal("unknown error not kept as cause")
+	}
+	// 原始错误只进日志，不出现在返回给客户端的文案里
+	if msg := got.Message(i18n.English); msg != "internal server error" {
+		t.Fatalf("message = %q", msg)
+	}
+	if got.Error() != "internal server error: dial tcp: connection refused" {
+		t.Fatalf("Error() = %q", got.Error())
+	}
+}
+
+// TestCatalogRanges 业务码发布后不能再改，新加的码要落在所属模块的分段里
+func TestCatalogRanges(t *testing.T) {
+	ranges := map[int]bool{10: true, 20: true, 21: true, 22: true, 23: true, 30: true, 50: true}
+	for _, e := range Catalog() {
+		if !ranges[e.Code/1000] {
+			t.Errorf("code %d (%s) is outside the documented ranges", e.Code, e.Key)
+		}
+		if e.Status < 400 || e.Status > 599 {
+			t.Errorf("code %d has status %d", e.Code, e.Status)
+		}
+	}
+}
@@ -1,21 +1,52 @@
 package errcode
 
-import "errors"
+import "net/http"
 
+// 业务码按模块分段：10xxx 通用，20xxx 登录与会话，21xxx 两步验证，22xxx 第三方登录，
+// 23xxx 邮件链接，30xxx 关注，50xxx 上传。通用段再按类别分：100xx 参数，101xx 未登录，102xx 限流，
+// 103xx 权限，104xx 不存在，105xx 服务端，109xx 冲突。已经发布的业务码不能改也不能复用，API.md 里有完整列表
 var (
-	ErrBadRequest   = errors.New("bad request")
-	ErrUnauthorized = errors.New("unauthorized")
-	ErrForbidden    = errors.New("forbidden")
-	ErrConflict     = errors.New("conflict")
-	ErrNotFound     = errors.New("not found")
-	ErrInternal     = errors.New("internal server error")
-
-	ErrUsernameIncorrect = errors.New("username incorrect")
-	ErrPasswordIncorrect = errors.New("password incorrect")
-	ErrSessionRevoked    = errors.New("session revoked")
-	ErrRefreshReuse      = errors.New("refresh token reuse detected")
-	ErrDeviceMismatch    = errors.New("device or browser mismatch")
-	ErrHasFollowed       = errors.New("has followed")
-	ErrHasNotFollowed    = errors.New("has not followed")
-	ErrInvalidListType   = errors.New("invalid list type")
+	ErrBadRequest      = define(10000, http.StatusBadRequest, "common.bad_request")
+	ErrValidation      = define(10001, http.StatusBadRequest, "common.validation_failed")
+	ErrUnauthorized    = define(10100, http.StatusUnauthorized, "common.unauthorized")
+	ErrLoginRequired   = define(10101, http.StatusUnauthorized, "common.login_required")
+	ErrForbidden       = define(10300, http.StatusForbidden, "common.forbidden")
+	ErrAdminOnly       = define(10301, http.StatusForbidden, "common.admin_only")
+	ErrNotFound        = define(10400, http.StatusNotFound, "common.not_found")
+	ErrConflict        = define(10900, http.StatusConflict, "common.conflict")
+	ErrTooManyRequests = define(10200, http.StatusTooManyRequests, "common.too_many_requests")
+	ErrInternal        = define(10500, http.StatusInternalServerError, "common.internal")
+	ErrJanitorFailed   = define(10501, http.StatusInternalServerError, "admin.janitor_failed")
+
+	// ErrInvalidCredentials 登录时不区分用户名错误还是密码错误
+	ErrInvalidCredentials  = define(20001, http.StatusUnauthorized, "auth.invalid_credentials")
+	ErrLoginLocked         = define(20002, http.StatusTooManyRequests, "auth.login_locked")
+	ErrPasswordIncorrect   = define(20003, http.StatusUnauthorized, "auth.password_incorrect")
+	ErrSessionRevoked      = define(20004, http.StatusUnauthorized, "auth.session_revoked")
+	ErrSessionExpired      = define(20005, http.StatusUnauthorized, "auth.session_expired")
+	ErrSessionLimit        = define(20006, http.StatusConflict, "auth.session_limit")
+	ErrRefreshReuse        = define(20007, http.StatusForbidden, "auth.refresh_reuse")
+	ErrDeviceMismatch      = define(20008, http.StatusUnauthorized, "auth.device_mismatch")
+	ErrInvalidRefreshToken = define(20009, http.StatusBadRequest, "auth.invalid_refresh_token")
+
+	ErrTwoFactorRequired      = define(21001, http.StatusUnauthorized, "two_factor.required")
+	ErrTwoFactorCodeIncorrect = define(21002, http.StatusUnauthorized, "two_factor.code_incorrect")
+	ErrChallengeExpired       = define(21003, http.StatusUnauthorized, "two_factor.challenge_expired")
+
+	ErrProviderUnavailable = define(22001, http.StatusBadGateway, "oauth.provider_unavailable")
+	ErrOAuthStateInvalid   = define(22002, http.StatusBadRequest, "oauth.state_invalid")
+	ErrIdentityNotLinked   = define(22003, http.StatusConflict, "oauth.identity_not_linked")
+
+	ErrLinkExpired = define(23001, http.StatusBadRequest, "email.link_expired")
+
+	ErrHasFollowed      = define(30001, http.StatusBadRequest, "follow.already_following")
+	ErrHasNotFollowed   = define(30002, http.StatusBadRequest, "follow.not_following")
+	ErrCannotFollowSelf = define(30003, http.StatusBadRequest, "follow.self")
+	ErrInvalidListType  = define(30004, http.StatusBadRequest, "follow.invalid_list_type")
+
+	ErrUnsupportedImage = define(50001, http.StatusBadRequest, "upload.unsupported_image")
+	ErrImageDimensions  = define(50002, http.StatusBadRequest, "upload.image_dimensions")
+	ErrUploadQuota      = define(50003, http.StatusRequestEntityTooLarge, "upload.quota_exceeded")
+	ErrFileRequired     = define(50004, http.StatusBadRequest, "upload.file_required")
+	ErrFileTooLarge     = define(50005, http.StatusRequestEntityTooLarge, "upload.file_too_large")
 )
@@ -0,0 +1,190 @@
+package geo
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"github.com/lionsoul2014/ip2region/binding/golang/xdb"
+)
+
+type Location struct {
+	Country  string `json:"country,omitempty"`
+	Province string `json:"province,omitempty"`
+	City     string `json:"city,omitempty"`
+	ISP      string `json:"isp,omitempty"`
+}
+
+// Label 拼成“中国 广东省 深圳市”这样的展示文本，省市相同（直辖市）只保留一个
+func (l Location) Label() string {
+	parts := make([]string, 0, 3)
+	for _, part := range []string{l.Country, l.Province, l.City} {
+		if part == "" || (len(parts) > 0 && parts[len(parts)-1] == part) {
+			continue
+		}
+		parts = append(parts, part)
+	}
+
+	return strings.Join(parts, " ")
+}
+
+// Locator 离线 IP 库的抽象，查不到返回 false
+type Locator interface {
+	Lookup(ip string) (Location, bool)
+}
+
+// Config ip2region xdb 文件路径，IPv4 和 IPv6 各一个
+type Config struct {
+	DBPath   string `yaml:"db_path" env:"GEO_DB_PATH"`
+	DBPathV6 string `yaml:"db_path_v6" env:"GEO_DB_PATH_V6"`
+}
+
+// New 加载配置的 xdb 文件，都没配置时返回 Noop
+func New(cfg Config) (Locator, error) {
+	if cfg.DBPath == "" && cfg.DBPathV6 == "" {
+		return Noop{}, nil
+	}
+
+	return NewXDBLocator(cfg.DBPath, cfg.DBPathV6)
+}
+
+type Noop struct{}
+
+func (Noop) Lookup(string) (Location, bool) {
+	return Location{}, false
+}
+
+// Describe 内网和本机地址不查库，直接给出说明
+func Describe(locator Locator, ip string) string {
+	parsed := net.ParseIP(strings.TrimSpace(ip))
+	if parsed == nil {
+		return ""
+	}
+	if parsed.IsLoopback() {
+		return "本机"
+	}
+	if parsed.IsPrivate() || parsed.IsLinkLocalUnicast() {
+		return "局域网"
+	}
+
+	location, ok := locator.Lookup(parsed.String())
+	if !ok {
+		return ""
+	}
+
+	return location.Label()
+}
+
+type xdbSearcher struct {
+	searcher *xdb.Searcher
+	legacy   bool
+}
+
+// XDBLocator 把整个 xdb 文件读进内存，基于内存的 searcher 可以并发查询
+type XDBLocator struct {
+	v4 *xdbSearcher
+	v6 *xdbSearcher
+}
+
+func NewXDBLocator(v4Path, v6Path string) (*XDBLocator, error) {
+	locator := &XDBLocator{}
+
+	var err error
+	if v4Path != "" {
+		if locator.v4, err = loadSearcher(v4Path, xdb.IPv4VersionNo); err != nil {
+			return nil, err
+		}
+	}
+	if v6Path != "" {
+		if locator.v6, err = loadSearcher(v6Path, xdb.IPv6VersionNo); err != nil {
+			return nil, err
+		}
+	}
+
+	return locator, nil
+}
+
+func loadSearcher(path string, ipVersion int) (*xdbSearcher, error) {
+	content, err := xdb.LoadContentFromFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("load %s: %w", path, err)
+	}
+
+	searcher, err := newSearcher(content, ipVersion)
+	if err != nil {
+		return nil, fmt.Errorf("load %s: %w", path, err)
+	}
+
+	return searcher, nil
+}
+
+// newSearcher 解析 xdb 文件头，ipVersion 是这个文件应该覆盖的 IP 版本，配错路径时启动就报错
+func newSearcher(content []byte, ipVersion int) (*xdbSearcher, error) {
+	// 文件头加向量索引是固定长度，不够说明文件不完整，xdb 包自己不检查会直接 panic
+	if len(content) < xdb.HeaderInfoLength+xdb.VectorIndexRows*xdb.VectorIndexCols*xdb.VectorIndexSize {
+		return nil, fmt.Errorf("xdb file truncated: %d bytes", len(content))
+	}
+
+	header, err := xdb.LoadHeaderFromBuff(content)
+	if err != nil {
+		return nil, fmt.Errorf("header: %w", err)
+	}
+
+	version, err := xdb.VersionFromHeader(header)
+	if err != nil {
+		return nil, err
+	}
+	if version.Id != ipVersion {
+		return nil, fmt.Errorf("xdb file is %s, want IPv%d", version.Name, ipVersion)
+	}
+
+	searcher, err := xdb.NewWithBuffer(version, content)
+	if err != nil {
+		return nil, err
+	}
+
+	return &xdbSearcher{searcher: searcher, legacy: header.Version == xdb.Structure20}, nil
+}
+
+func (l *XDBLocator) Lookup(ip string) (Location, bool) {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return Location{}, false
+	}
+
+	target := l.v6
+	if parsed.To4() != nil {
+		target = l.v4
+	}
+	if target == nil {
+		return Location{}, false
+	}
+
+	region, err := target.searcher.Search(ip)
+	if err != nil || region == "" {
+		return Location{}, false
+	}
+
+	// 2.0 结构：国家|区域|省份|城市|ISP；3.0 结构：国家|省份|城市|ISP|ISO
+	parts := strings.Split(region, "|")
+	var location Location
+	if target.legacy {
+		location = Location{Country: field(parts, 0), Province: field(parts, 2), City: field(parts, 3), ISP: field(parts, 4)}
+	} else {
+		location = Location{Country: field(parts, 0), Province: field(parts, 1), City: field(parts, 2), ISP: field(parts, 3)}
+	}
+	if location.Country == "" {
+		return Location{}, false
+	}
+
+	return location, true
+}
+
+// field ip2region 用 "0" 表示未知
+func field(parts []string, idx int) string {
+	if idx >= len(parts) || parts[idx] == "0" {
+		return ""
+	}
+
+	return strings.TrimSpace(parts[idx])
+}
@@ -0,0 +1,216 @@
+package geo
+
+import (
+	"encoding/binary"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/lionsoul2014/ip2region/binding/golang/xdb"
+)
+
+type xdbSegment struct {
+	start, end string
+	region     string
+}
+
+// buildXDB 按 ip2region 的格式拼一个 xdb：256 字节文件头、256×256 向量索引、region 数据、段索引。
+// segments 要按地址升序，每段不能跨越前两个字节相同的向量格子
+func buildXDB(t *testing.T, structure uint16, ipVersion int, segments []xdbSegment) []byte {
+	t.Helper()
+
+	ipBytes, segIndexSize := 4, xdb.IPv4.SegmentIndexSize
+	if ipVersion == xdb.IPv6VersionNo {
+		ipBytes, segIndexSize = 16, xdb.IPv6.SegmentIndexSize
+	}
+	parse := func(s string) []byte {
+		ip := net.ParseIP(s)
+		if ipBytes == 4 {
+			ip = ip.To4()
+		}
+		if len(ip) != ipBytes {
+			t.Fatalf("bad fixture ip %q", s)
+		}
+		return ip
+	}
+
+	buf := make([]byte, xdb.HeaderInfoLength+xdb.VectorIndexRows*xdb.VectorIndexCols*xdb.VectorIndexSize)
+	dataPtr := make([]uint32, len(segments))
+	for i, seg := range segments {
+		dataPtr[i] = uint32(len(buf))
+		buf = append(buf, seg.region...)
+	}
+
+	indexStart := uint32(len(buf))
+	for i, seg := range segments {
+		start, end := parse(seg.start), parse(seg.end)
+		if start[0] != end[0] || start[1] != end[1] {
+			t.Fatalf("fixture segment %s-%s spans vector cells", seg.start, seg.end)
+		}
+
+		ptr := uint32(len(buf))
+		entry := make([]byte, segIndexSize)
+		if ipBytes == 4 {
+			// IPv4 段索引里的地址是小端
+			binary.LittleEndian.PutUint32(entry, binary.BigEndian.Uint32(start))
+			binary.LittleEndian.PutUint32(entry[4:], binary.BigEndian.Uint32(end))
+		} else {
+			copy(entry, start)
+			copy(entry[16:], end)
+		}
+		binary.LittleEndian.PutUint16(entry[2*ipBytes:], uint16(len(seg.region)))
+		binary.LittleEndian.PutUint32(entry[2*ipBytes+2:], dataPtr[i])
+		buf = append(buf, entry...)
+
+		// 向量索引记录格子里第一个和最后一个段的位置
+		cell := xdb.HeaderInfoLength + (int(start[0])*xdb.VectorIndexCols+int(start[1]))*xdb.VectorIndexSize
+		if binary.LittleEndian.Uint32(buf[cell:]) == 0 {
+			binary.LittleEndian.PutUint32(buf[cell:], ptr)
+		}
+		binary.LittleEndian.PutUint32(buf[cell+4:], ptr)
+	}
+
+	binary.LittleEndian.PutUint16(buf[0:], structure)
+	binary.LittleEndian.PutUint16(buf[2:], uint16(xdb.VectorIndexPolicy))
+	binary.LittleEndian.PutUint32(buf[8:], indexStart)
+	binary.LittleEndian.PutUint32(buf[12:], uint32(len(buf))-uint32(segIndexSize))
+	if structure == xdb.Structure30 {
+		binary.LittleEndian.PutUint16(buf[16:], uint16(ipVersion))
+		binary.LittleEndian.PutUint16(buf[18:], 4)
+	}
+
+	return buf
+}
+
+func mustSearcher(t *testing.T, content []byte, ipVersion int) *xdbSearcher {
+	t.Helper()
+
+	searcher, err := newSearcher(content, ipVersion)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return searcher
+}
+
+func TestXDBLocatorLookup(t *testing.T) {
+	v2 := buildXDB(t, xdb.Structure20, xdb.IPv4VersionNo, []xdbSegment{
+		{"1.2.0.0", "1.2.127.255", "中国|0|广东省|深圳市|电信"},
+		{"1.2.128.0", "1.2.255.255", "0|0|0|0|0"},
+	})
+	v3 := buildXDB(t, xdb.Structure30, xdb.IPv4VersionNo, []xdbSegment{
+		{"36.112.0.0", "36.112.255.255", "中国|北京市|北京市|联通|CN"},
+	})
+	v6 := buildXDB(t, xdb.Structure30, xdb.IPv6VersionNo, []xdbSegment{
+		{"2400::", "2400:ff:ffff:ffff:ffff:ffff:ffff:ffff", "美国|加利福尼亚|0|0|US"},
+	})
+
+	tests := []struct {
+		name    string
+		locator *XDBLocator
+		ip      string
+		want    Location
+		label   string
+		ok      bool
+	}{
+		{
+			name: "v2 country|region|province|city|isp", locator: &XDBLocator{v4: mustSearcher(t, v2, xdb.IPv4VersionNo)}, ip: "1.2.3.4",
+			want: Location{Country: "中国", Province: "广东省", City: "深圳市", ISP: "电信"}, label: "中国 广东省 深圳市", ok: true,
+		},
+		{name: "v2 unknown country", locator: &XDBLocator{v4: mustSearcher(t, v2, xdb.IPv4VersionNo)}, ip: "1.2.200.1"},
+		{name: "v2 no segment", locator: &XDBLocator{v4: mustSearcher(t, v2, xdb.IPv4VersionNo)}, ip: "8.8.8.8"},
+		{
+			name: "v3 country|province|city|isp|iso", locator: &XDBLocator{v4: mustSearcher(t, v3, xdb.IPv4VersionNo)}, ip: "36.112.1.1",
+			want: Location{Country: "中国", Province: "北京市", City: "北京市", ISP: "联通"}, label: "中国 北京市", ok: true,
+		},
+		{
+			name: "v3 ipv6", locator: &XDBLocator{v6: mustSearcher(t, v6, xdb.IPv6VersionNo)}, ip: "2400:1::1",
+			want: Location{Country: "美国", Province: "加利福尼亚"}, label: "美国 加利福尼亚", ok: true,
+		},
+		{name: "ipv6 without v6 file", locator: &XDBLocator{v4: mustSearcher(t, v3, xdb.IPv4VersionNo)}, ip: "2400:1::1"},
+		{name: "ipv4 without v4 file", locator: &XDBLocator{v6: mustSearcher(t, v6, xdb.IPv6VersionNo)}, ip: "36.112.1.1"},
+		{name: "invalid ip", locator: &XDBLocator{v4: mustSearcher(t, v3, xdb.IPv4VersionNo)}, ip: "not-an-ip"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := tt.locator.Lookup(tt.ip)
+			if ok != tt.ok || got != tt.want {
+				t.Fatalf("Lookup(%q) = %+v, %v, want %+v, %v", tt.ip, got, ok, tt.want, tt.ok)
+			}
+			if label := got.Label(); label != tt.label {
+				t.Fatalf("Label() = %q, want %q", label, tt.label)
+			}
+		})
+	}
+}
+
+func TestNewSearcherRejectsBadFiles(t *testing.T) {
+	v4 := buildXDB(t, xdb.Structure30, xdb.IPv4VersionNo, []xdbSegment{{"1.2.0.0", "1.2.255.255", "中国|0|0|0|CN"}})
+	v6 := buildXDB(t, xdb.Structure30, xdb.IPv6VersionNo, []xdbSegment{{"2400::", "2400::ffff", "中国|0|0|0|CN"}})
+	badVersion := buildXDB(t, 9, xdb.IPv4VersionNo, nil)
+
+	tests := []struct {
+		name      string
+		content   []byte
+		ipVersion int
+		want      string
+	}{
+		{name: "truncated", content: v4[:1024], ipVersion: xdb.IPv4VersionNo, want: "truncated"},
+		{name: "v6 file as v4", content: v6, ipVersion: xdb.IPv4VersionNo, want: "want IPv4"},
+		{name: "v4 file as v6", content: v4, ipVersion: xdb.IPv6VersionNo, want: "want IPv6"},
+		{name: "unknown structure", content: badVersion, ipVersion: xdb.IPv4VersionNo, want: "invalid version"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := newSearcher(tt.content, tt.ipVersion)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("err = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
+
+type mapLocator map[string]Location
+
+func (m mapLocator) Lookup(ip string) (Location, bool) {
+	location, ok := m[ip]
+	return location, ok
+}
+
+func TestDescribe(t *testing.T) {
+	locator := mapLocator{
+		"1.2.3.4":     {Country: "中国", Province: "上海市", City: "上海市"},
+		"2400:1::1":   {Country: "日本"},
+		"10.0.0.1":    {Country: "不该查到"},
+		"203.0.113.9": {},
+	}
+
+	tests := []struct {
+		ip   string
+		want string
+	}{
+		{"", ""},
+		{"not-an-ip", ""},
+		{"127.0.0.1", "本机"},
+		{"::1", "本机"},
+		{"10.0.0.1", "局域网"},
+		{"192.168.1.20", "局域网"},
+		{"fd00::1", "局域网"},
+		{"fe80::1", "局域网"},
+		{" 1.2.3.4 ", "中国 上海市"},
+		{"2400:0001:0:0::1", "日本"},
+		{"203.0.113.9", ""},
+		{"8.8.8.8", ""},
+	}
+
+	for _, tt := range tests {
+		if got := Describe(locator, tt.ip); got != tt.want {
+			t.Errorf("Describe(%q) = %q, want %q", tt.ip, got, tt.want)
+		}
+	}
+
+	if got := Describe(Noop{}, "8.8.8.8"); got != "" {
+		t.Errorf("Describe with Noop = %q", got)
+	}
+}
@@ -0,0 +1,86 @@
+package i18n
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type Lang string
+
+const (
+	English Lang = "en"
+	Chinese Lang = "zh"
+)
+
+// Default 请求没带 Accept-Language 或者都不支持时用英文，和以前的接口返回保持一致
+const Default = English
+
+// Match 按 Accept-Language 的 q 值从高到低挑第一个支持的语言，zh-CN、zh-Hans 这类都算中文
+func Match(acceptLanguage string) Lang {
+	type candidate struct {
+		lang string
+		q    float64
+	}
+
+	var candidates []candidate
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if tag == "" {
+			continue
+		}
+
+		q, ok := quality(params)
+		if ok && q > 0 {
+			candidates = append(candidates, candidate{lang: strings.ToLower(tag), q: q})
+		}
+	}
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].q > candidates[j].q })
+
+	for _, c := range candidates {
+		base, _, _ := strings.Cut(c.lang, "-")
+		switch base {
+		case "zh":
+			return Chinese
+		case "en":
+			return English
+		}
+	}
+
+	return Default
+}
+
+// quality 取参数里的 q 值，没写时是 1；格式不对返回 false，这一项忽略
+func quality(params string) (float64, bool) {
+	for _, param := range strings.Split(params, ";") {
+		v, ok := strings.CutPrefix(strings.TrimSpace(param), "q=")
+		if !ok {
+			continue
+		}
+		q, err := strconv.ParseFloat(v, 64)
+		return q, err == nil
+	}
+
+	return 1, true
+}
+
+// Message 取 key 对应的文案，{param} 替换成 param；缺少这个语言时退回英文，key 不存在时原样返回 key
+func Message(lang Lang, key string, param string) string {
+	texts, ok := messages[key]
+	if !ok {
+		return key
+	}
+
+	text, ok := texts[lang]
+	if !ok {
+		text = texts[Default]
+	}
+
+	return strings.ReplaceAll(text, "{param}", param)
+}
+
+// Has 定义错误码时用来检查文案是否齐全
+func Has(lang Lang, key string) bool {
+	_, ok := messages[key][lang]
+	return ok
+}
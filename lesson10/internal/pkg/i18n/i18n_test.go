@@ -0,0 +1,43 @@
+package i18n
+
+import "testing"
+
+func TestMatch(t *testing.T) {
+	cases := map[string]Lang{
+		"":                               Default,
+		"zh-CN":                          Chinese,
+		"zh-Hans-CN,zh;q=0.9":            Chinese,
+		"EN-us":                          English,
+		"fr-FR,fr;q=0.9":                 Default,
+		"fr-FR,zh;q=0.5,en;q=0.8":        English,
+		"en;q=0.3, zh-TW;q=0.7":          Chinese,
+		"zh;q=0,en;q=0.1":                English,
+		"zh;q=abc,en;q=0.2":              English,
+		"de, zh;q=0.6, en-GB;q=0.6":      Chinese, // q 值相同时保持原顺序
+		"*;q=0.5":                        Default,
+		"ja, , en-US ; q=0.4":            English,
+		"fr;q=1,en;level=1;q=0.4":        English,
+		"en-US,en;q=0.9,zh-CN;q=0.95":    English,
+		"fr;q=0.9,zh-cn;q=0.91,en;q=0.9": Chinese,
+	}
+	for header, want := range cases {
+		if got := Match(header); got != want {
+			t.Errorf("Match(%q) = %s, want %s", header, got, want)
+		}
+	}
+}
+
+func TestMessage(t *testing.T) {
+	if got := Message(Chinese, "validation.min", "3"); got != "长度或数值不能小于 3" {
+		t.Fatalf("zh = %q", got)
+	}
+	if got := Message(English, "validation.min", "3"); got != "must be at least 3" {
+		t.Fatalf("en = %q", got)
+	}
+	if got := Message(Lang("fr"), "common.not_found", ""); got != "not found" {
+		t.Fatalf("unsupported lang = %q, want English fallback", got)
+	}
+	if got := Message(Chinese, "no.such.key", ""); got != "no.such.key" {
+		t.Fatalf("missing key = %q", got)
+	}
+}
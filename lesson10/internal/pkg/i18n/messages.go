@@ -0,0 +1,57 @@
+package i18n
+
+// messages 错误码和参数校验的文案。英文沿用以前接口直接返回的文本，已有的客户端按文本判断也不受影响；
+// 新增错误码时两种语言都要补上，否则 errcode 定义时会 panic
+var messages = map[string]map[Lang]string{
+	"common.bad_request":       {English: "bad request", Chinese: "请求参数错误"},
+	"common.validation_failed": {English: "request validation failed", Chinese: "请求参数校验失败"},
+	"common.unauthorized":      {English: "unauthorized", Chinese: "未授权"},
+	"common.login_required":    {English: "please login first", Chinese: "请先登录"},
+	"common.forbidden":         {English: "forbidden", Chinese: "没有权限"},
+	"common.admin_only":        {English: "admin only", Chinese: "仅管理员可以操作"},
+	"common.not_found":         {English: "not found", Chinese: "内容不存在或已被删除"},
+	"common.conflict":          {English: "conflict", Chinese: "数据冲突，请刷新后重试"},
+	"common.too_many_requests": {English: "too many requests", Chinese: "操作过于频繁，请稍后再试"},
+	"common.internal":          {English: "internal server error", Chinese: "服务器内部错误，请稍后再试"},
+
+	"auth.invalid_credentials":   {English: "invalid username or password", Chinese: "用户名或密码错误"},
+	"auth.login_locked":          {English: "too many failed login attempts, try again later", Chinese: "登录失败次数过多，请稍后再试"},
+	"auth.password_incorrect":    {English: "password incorrect", Chinese: "密码错误"},
+	"auth.session_revoked":       {English: "session revoked", Chinese: "登录状态已失效，请重新登录"},
+	"auth.session_expired":       {English: "session expired, please login again", Chinese: "登录已过期，请重新登录"},
+	"auth.session_limit":         {English: "too many active sessions, sign out another device first", Chinese: "登录的设备太多，请先退出其他设备"},
+	"auth.refresh_reuse":         {English: "refresh token reuse detected", Chinese: "检测到登录凭证被重复使用，请重新登录"},
+	"auth.device_mismatch":       {English: "device or browser mismatch", Chinese: "设备或浏览器不匹配，请重新登录"},
+	"auth.invalid_refresh_token": {English: "invalid refresh token", Chinese: "登录凭证无效，请重新登录"},
+
+	"two_factor.required":          {English: "two-factor authentication required", Chinese: "需要两步验证"},
+	"two_factor.code_incorrect":    {English: "two-factor code incorrect", Chinese: "验证码错误"},
+	"two_factor.challenge_expired": {English: "login challenge expired", Chinese: "登录验证已过期，请重新登录"},
+
+	"oauth.provider_unavailable": {English: "identity provider unavailable", Chinese: "第三方登录服务暂时不可用"},
+	"oauth.state_invalid":        {English: "oauth state invalid or expired", Chinese: "第三方登录已过期，请重新发起"},
+	"oauth.identity_not_linked":  {English: "email already registered, login and link the provider first", Chinese: "该邮箱已注册，请先登录后再绑定第三方账号"},
+
+	"email.link_expired": {English: "link expired or already used", Chinese: "链接已过期或已被使用"},
+
+	"follow.already_following": {English: "has followed", Chinese: "已经关注过了"},
+	"follow.not_following":     {English: "has not followed", Chinese: "还没有关注"},
+	"follow.self":              {English: "can not follow yourself", Chinese: "不能关注自己"},
+	"follow.invalid_list_type": {English: "invalid list type", Chinese: "列表类型无效"},
+
+	"upload.unsupported_image": {English: "only jpg/png/webp allowed", Chinese: "只支持 jpg/png/webp 格式的图片"},
+	"upload.image_dimensions":  {English: "image dimensions out of range", Chinese: "图片尺寸超出范围"},
+	"upload.quota_exceeded":    {English: "storage quota exceeded, delete unused images or upgrade to VIP", Chinese: "存储空间已用完，请删除不用的图片或升级 VIP"},
+	"upload.file_required":     {English: "please upload an image", Chinese: "请选择要上传的图片"},
+	"upload.file_too_large":    {English: "file too large", Chinese: "文件太大"},
+
+	"admin.janitor_failed": {English: "janitor run failed", Chinese: "清理任务执行失败"},
+
+	"validation.required": {English: "is required", Chinese: "不能为空"},
+	"validation.email":    {English: "must be a valid email address", Chinese: "邮箱格式不正确"},
+	"validation.min":      {English: "must be at least {param}", Chinese: "长度或数值不能小于 {param}"},
+	"validation.max":      {English: "must be at most {param}", Chinese: "长度或数值不能大于 {param}"},
+	"validation.oneof":    {English: "must be one of: {param}", Chinese: "只能是以下值之一：{param}"},
+	"validation.format":   {English: "has an invalid format", Chinese: "格式不正确"},
+	"validation.invalid":  {English: "is invalid", Chinese: "不合法"},
+}
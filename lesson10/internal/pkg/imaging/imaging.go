@@ -0,0 +1,161 @@
+package imaging
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"lesson10/internal/pkg/errcode"
+
+	"github.com/HugoSmits86/nativewebp"
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+const jpegQuality = 85
+
+var allowedFormats = map[string]bool{"jpeg": true, "png": true, "webp": true}
+
+// VariantSpec MaxSide 是输出长边上限，原图更小时不放大；Square 时先居中裁成正方形（头像）
+type VariantSpec struct {
+	Name    string
+	MaxSide int
+	Square  bool
+}
+
+// Spec 输入图片的尺寸限制和要生成的尺寸档位。先只读文件头拿到宽高，超限的不解码，防止解压炸弹
+type Spec struct {
+	MinSide   int
+	MaxSide   int
+	MaxPixels int
+	Variants  []VariantSpec
+}
+
+type File struct {
+	Ext         string
+	ContentType string
+	Data        []byte
+}
+
+// Variant Primary 不透明时是 JPEG、有透明通道时是 PNG；WebP 是无损编码，体积不比 Primary 小时为 nil
+type Variant struct {
+	Name    string
+	Width   int
+	Height  int
+	Primary File
+	WebP    *File
+}
+
+type Result struct {
+	Hash     string
+	Format   string
+	Width    int
+	Height   int
+	Variants []Variant
+}
+
+// Size 所有档位输出文件的总字节数
+func (r *Result) Size() int64 {
+	var total int64
+	for _, variant := range r.Variants {
+		total += int64(len(variant.Primary.Data))
+		if variant.WebP != nil {
+			total += int64(len(variant.WebP.Data))
+		}
+	}
+
+	return total
+}
+
+// Hash 原始上传内容的 sha256，用于去重
+func Hash(raw []byte) string {
+	sum := sha256.Sum256(raw)
+	return hex.EncodeToString(sum[:])
+}
+
+// Process 解码、校验尺寸、按 EXIF 方向摆正后重新编码各个档位；重新编码不会带上 EXIF/ICC 等元数据。
+// 格式不支持返回 errcode.ErrUnsupportedImage，尺寸超限返回 errcode.ErrImageDimensions，service 可以直接透传
+func Process(raw []byte, spec Spec) (*Result, error) {
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(raw))
+	if err != nil || !allowedFormats[format] {
+		return nil, errcode.ErrUnsupportedImage
+	}
+	if cfg.Width < spec.MinSide || cfg.Height < spec.MinSide ||
+		cfg.Width > spec.MaxSide || cfg.Height > spec.MaxSide ||
+		cfg.Width*cfg.Height > spec.MaxPixels {
+		return nil, errcode.ErrImageDimensions
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(raw))
+	if err != nil {
+		return nil, errcode.ErrUnsupportedImage
+	}
+	if format == "jpeg" {
+		img = orient(img, jpegOrientation(raw))
+	}
+
+	bounds := img.Bounds()
+	result := &Result{
+		Hash:     Hash(raw),
+		Format:   format,
+		Width:    bounds.Dx(),
+		Height:   bounds.Dy(),
+		Variants: make([]Variant, 0, len(spec.Variants)),
+	}
+
+	for _, vs := range spec.Variants {
+		variant, err := render(img, vs)
+		if err != nil {
+			return nil, err
+		}
+		result.Variants = append(result.Variants, *variant)
+	}
+
+	return result, nil
+}
+
+func render(src image.Image, spec VariantSpec) (*Variant, error) {
+	rect := src.Bounds()
+	if spec.Square {
+		side := min(rect.Dx(), rect.Dy())
+		x := rect.Min.X + (rect.Dx()-side)/2
+		y := rect.Min.Y + (rect.Dy()-side)/2
+		rect = image.Rect(x, y, x+side, y+side)
+	}
+
+	w, h := rect.Dx(), rect.Dy()
+	if long := max(w, h); long > spec.MaxSide {
+		w = max(1, w*spec.MaxSide/long)
+		h = max(1, h*spec.MaxSide/long)
+	}
+
+	dst := image.NewNRGBA(image.Rect(0, 0, w, h))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), src, rect, draw.Src, nil)
+
+	variant := &Variant{Name: spec.Name, Width: w, Height: h}
+
+	var buf bytes.Buffer
+	if dst.Opaque() {
+		if err := jpeg.Encode(&buf, dst, &jpeg.Options{Quality: jpegQuality}); err != nil {
+			return nil, err
+		}
+		variant.Primary = File{Ext: ".jpg", ContentType: "image/jpeg", Data: buf.Bytes()}
+	} else {
+		if err := png.Encode(&buf, dst); err != nil {
+			return nil, err
+		}
+		variant.Primary = File{Ext: ".png", ContentType: "image/png", Data: buf.Bytes()}
+	}
+
+	var webpBuf bytes.Buffer
+	if err := nativewebp.Encode(&webpBuf, dst, nil); err != nil {
+		return nil, err
+	}
+	if webpBuf.Len() < len(variant.Primary.Data) {
+		variant.WebP = &File{Ext: ".webp", ContentType: "image/webp", Data: webpBuf.Bytes()}
+	}
+
+	return variant, nil
+}
@@ -0,0 +1,230 @@
+package imaging
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"lesson10/internal/pkg/errcode"
+	"testing"
+)
+
+var testSpec = Spec{
+	MinSide:   16,
+	MaxSide:   4000,
+	MaxPixels: 4_000_000,
+	Variants: []VariantSpec{
+		{Name: "thumb", MaxSide: 100},
+		{Name: "original", MaxSide: 1000},
+		{Name: "avatar", MaxSide: 50, Square: true},
+	},
+}
+
+// halves 左半边红、右半边蓝
+func halves(w, h int, alpha uint8) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			c := color.NRGBA{R: 255, A: alpha}
+			if x >= w/2 {
+				c = color.NRGBA{B: 255, A: alpha}
+			}
+			img.SetNRGBA(x, y, c)
+		}
+	}
+	return img
+}
+
+func encodeJPEG(t *testing.T, img image.Image) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 95}); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// withEXIF 在 SOI 后面插一个 APP1 段，带 Orientation 和一段 ImageDescription 文本
+func withEXIF(raw []byte, orientation uint16, description string) []byte {
+	tiff := []byte("MM\x00\x2a\x00\x00\x00\x08")
+	ifd := make([]byte, 2+2*12+4)
+	binary.BigEndian.PutUint16(ifd, 2)
+	// 0x010E ImageDescription，ASCII，值放在 IFD 后面
+	binary.BigEndian.PutUint16(ifd[2:], 0x010e)
+	binary.BigEndian.PutUint16(ifd[4:], 2)
+	binary.BigEndian.PutUint32(ifd[6:], uint32(len(description)+1))
+	binary.BigEndian.PutUint32(ifd[10:], uint32(len(tiff)+len(ifd)))
+	// 0x0112 Orientation，SHORT
+	binary.BigEndian.PutUint16(ifd[14:], 0x0112)
+	binary.BigEndian.PutUint16(ifd[16:], 3)
+	binary.BigEndian.PutUint32(ifd[18:], 1)
+	binary.BigEndian.PutUint16(ifd[22:], orientation)
+
+	payload := append([]byte("Exif\x00\x00"), tiff...)
+	payload = append(payload, ifd...)
+	payload = append(payload, description...)
+	payload = append(payload, 0)
+
+	segment := []byte{0xff, 0xe1, 0, 0}
+	binary.BigEndian.PutUint16(segment[2:], uint16(2+len(payload)))
+	segment = append(segment, payload...)
+
+	out := append([]byte{}, raw[:2]...)
+	out = append(out, segment...)
+	return append(out, raw[2:]...)
+}
+
+func decode(t *testing.T, f File) image.Image {
+	t.Helper()
+
+	img, _, err := image.Decode(bytes.NewReader(f.Data))
+	if err != nil {
+		t.Fatalf("decode %s: %v", f.ContentType, err)
+	}
+	return img
+}
+
+func TestProcessResizesVariants(t *testing.T) {
+	result, err := Process(encodeJPEG(t, halves(400, 200, 255)), testSpec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Format != "jpeg" || result.Width != 400 || result.Height != 200 {
+		t.Fatalf("result = %s %dx%d", result.Format, result.Width, result.Height)
+	}
+
+	// 按长边缩小、保持比例；原图比上限小时不放大；Square 先居中裁成正方形
+	want := map[string][2]int{"thumb": {100, 50}, "original": {400, 200}, "avatar": {50, 50}}
+	if len(result.Variants) != len(want) {
+		t.Fatalf("got %d variants", len(result.Variants))
+	}
+	for _, v := range result.Variants {
+		size := want[v.Name]
+		if v.Width != size[0] || v.Height != size[1] {
+			t.Errorf("%s: %dx%d, want %dx%d", v.Name, v.Width, v.Height, size[0], size[1])
+		}
+		if v.Primary.ContentType != "image/jpeg" {
+			t.Errorf("%s: opaque image encoded as %s", v.Name, v.Primary.ContentType)
+		}
+		if b := decode(t, v.Primary).Bounds(); b.Dx() != v.Width || b.Dy() != v.Height {
+			t.Errorf("%s: encoded %dx%d, recorded %dx%d", v.Name, b.Dx(), b.Dy(), v.Width, v.Height)
+		}
+		if v.WebP != nil && len(v.WebP.Data) >= len(v.Primary.Data) {
+			t.Errorf("%s: webp kept although it is not smaller", v.Name)
+		}
+	}
+}
+
+func TestProcessKeepsTransparencyAsPNG(t *testing.T) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, halves(64, 64, 128)); err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := Process(buf.Bytes(), testSpec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, v := range result.Variants {
+		if v.Primary.ContentType != "image/png" {
+			t.Errorf("%s: transparent image encoded as %s", v.Name, v.Primary.ContentType)
+		}
+	}
+}
+
+func TestProcessStripsMetadata(t *testing.T) {
+	const secret = "GPS 31.2304N 121.4737E"
+	raw := withEXIF(encodeJPEG(t, halves(64, 64, 255)), 1, secret)
+	if jpegOrientation(raw) != 1 || !bytes.Contains(raw, []byte(secret)) {
+		t.Fatal("test image has no EXIF")
+	}
+
+	result, err := Process(raw, testSpec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, v := range result.Variants {
+		files := []File{v.Primary}
+		if v.WebP != nil {
+			files = append(files, *v.WebP)
+		}
+		for _, f := range files {
+			if bytes.Contains(f.Data, []byte("Exif")) || bytes.Contains(f.Data, []byte(secret)) {
+				t.Errorf("%s %s still carries EXIF", v.Name, f.ContentType)
+			}
+		}
+	}
+}
+
+func TestProcessAppliesEXIFOrientation(t *testing.T) {
+	// Orientation 6：显示时要顺时针转 90°，左边的红色转到上面
+	raw := withEXIF(encodeJPEG(t, halves(80, 40, 255)), 6, "")
+
+	result, err := Process(raw, testSpec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Width != 40 || result.Height != 80 {
+		t.Fatalf("oriented size = %dx%d, want 40x80", result.Width, result.Height)
+	}
+
+	var original *Variant
+	for i := range result.Variants {
+		if result.Variants[i].Name == "original" {
+			original = &result.Variants[i]
+		}
+	}
+	img := decode(t, original.Primary)
+	top, _, _, _ := img.At(20, 20).RGBA()
+	_, _, bottom, _ := img.At(20, 60).RGBA()
+	if top>>8 < 200 || bottom>>8 < 200 {
+		t.Fatalf("top red = %d, bottom blue = %d: image not rotated", top>>8, bottom>>8)
+	}
+}
+
+func TestJPEGOrientation(t *testing.T) {
+	raw := encodeJPEG(t, halves(16, 16, 255))
+	if got := jpegOrientation(raw); got != 1 {
+		t.Fatalf("no EXIF: orientation = %d, want 1", got)
+	}
+	for _, want := range []uint16{1, 3, 6, 8} {
+		if got := jpegOrientation(withEXIF(raw, want, "x")); got != int(want) {
+			t.Errorf("orientation = %d, want %d", got, want)
+		}
+	}
+	// 超出 1~8 的值当作没有
+	if got := jpegOrientation(withEXIF(raw, 9, "x")); got != 1 {
+		t.Errorf("invalid orientation = %d, want 1", got)
+	}
+}
+
+func TestProcessRejects(t *testing.T) {
+	var gifBuf bytes.Buffer
+	if err := gif.Encode(&gifBuf, halves(64, 64, 255), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name string
+		raw  []byte
+		want error
+	}{
+		{"not an image", []byte("hello"), errcode.ErrUnsupportedImage},
+		{"gif", gifBuf.Bytes(), errcode.ErrUnsupportedImage},
+		{"too small", encodeJPEG(t, halves(8, 64, 255)), errcode.ErrImageDimensions},
+		{"too large", encodeJPEG(t, halves(4001, 16, 255)), errcode.ErrImageDimensions},
+		{"too many pixels", encodeJPEG(t, halves(2001, 2001, 255)), errcode.ErrImageDimensions},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := Process(tc.raw, testSpec); !errors.Is(err, tc.want) {
+				t.Fatalf("err = %v, want %v", err, tc.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,141 @@
+package logger
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+type Config struct {
+	// Format json 或 text，text 只给本地看
+	Format string `yaml:"format" env:"LOG_FORMAT"`
+	// Level debug / info / warn / error
+	Level string `yaml:"level" env:"LOG_LEVEL"`
+}
+
+// Init 把 slog 默认 logger 换成结构化输出，标准库 log.Printf 也会经过它，按 info 级别输出
+func Init(cfg Config) error {
+	l, err := New(cfg, os.Stdout)
+	if err != nil {
+		return err
+	}
+
+	slog.SetDefault(l)
+	log.SetFlags(0)
+	return nil
+}
+
+// New 输出的每条日志都会带上 ctx 里的 request_id / user_id / session_id / trace_id，
+// 所以打日志时尽量用 slog.InfoContext 这类带 ctx 的函数
+func New(cfg Config, w io.Writer) (*slog.Logger, error) {
+	level, err := ParseLevel(cfg.Level)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := &slog.HandlerOptions{Level: level}
+	var h slog.Handler
+	switch cfg.Format {
+	case "", "json":
+		h = slog.NewJSONHandler(w, opts)
+	case "text":
+		h = slog.NewTextHandler(w, opts)
+	default:
+		return nil, fmt.Errorf("unknown log format %q, want json or text", cfg.Format)
+	}
+
+	return slog.New(contextHandler{Handler: h}), nil
+}
+
+func ParseLevel(raw string) (slog.Level, error) {
+	var level slog.Level
+	if raw == "" {
+		return slog.LevelInfo, nil
+	}
+	if err := level.UnmarshalText([]byte(strings.ToUpper(raw))); err != nil {
+		return 0, fmt.Errorf("unknown log level %q, want debug, info, warn or error", raw)
+	}
+
+	return level, nil
+}
+
+type ctxKey struct{}
+
+// fields 请求开始时放进 ctx，鉴权中间件之后才知道用户，所以存指针，后面的中间件直接改
+type fields struct {
+	mu        sync.Mutex
+	requestID string
+	userID    uint
+	sessionID string
+}
+
+// WithRequestID 每个请求调用一次，之后派生出来的 ctx 打日志都带这个 request_id
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, ctxKey{}, &fields{requestID: requestID})
+}
+
+func RequestID(ctx context.Context) string {
+	f, ok := ctx.Value(ctxKey{}).(*fields)
+	if !ok {
+		return ""
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.requestID
+}
+
+// SetUser 鉴权通过后记下用户，ctx 里没有 WithRequestID 放进去的字段时什么都不做
+func SetUser(ctx context.Context, userID uint, sessionID string) {
+	f, ok := ctx.Value(ctxKey{}).(*fields)
+	if !ok {
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.userID = userID
+	f.sessionID = sessionID
+}
+
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if f, ok := ctx.Value(ctxKey{}).(*fields); ok {
+		f.mu.Lock()
+		r.AddAttrs(slog.String("request_id", f.requestID))
+		if f.userID != 0 {
+			r.AddAttrs(slog.Uint64("user_id", uint64(f.userID)))
+		}
+		if f.sessionID != "" {
+			r.AddAttrs(slog.String("session_id", f.sessionID))
+		}
+		f.mu.Unlock()
+	}
+
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		r.AddAttrs(
+			slog.String("trace_id", sc.TraceID().String()),
+			slog.String("span_id", sc.SpanID().String()),
+		)
+	}
+
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{Handler: h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{Handler: h.Handler.WithGroup(name)}
+}
@@ -0,0 +1,145 @@
+// Package markdown 把帖子正文（markdown）渲染成可以直接插入页面的 HTML，并生成列表页用的纯文本摘要
+package markdown
+
+import (
+	"bytes"
+	"html"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/parser"
+	goldmarkhtml "github.com/yuin/goldmark/renderer/html"
+)
+
+// Version 渲染规则或白名单有改动时加一，数据库里旧版本的缓存会被重新渲染
+const Version = 2
+
+// HeadingIDPrefix 标题锚点统一加这个前缀，正文里的 id 不会和页面上的元素、window/document 上的属性重名（DOM clobbering）
+const HeadingIDPrefix = "user-content-"
+
+const ExcerptLength = 160
+
+var (
+	renderer = goldmark.New(
+		goldmark.WithExtensions(
+			extension.Linkify,
+			extension.Strikethrough,
+			extension.TaskList,
+			extension.NewTable(extension.WithTableCellAlignMethod(extension.TableCellAlignAttribute)),
+		),
+		goldmark.WithParserOptions(parser.WithAutoHeadingID()),
+		// 原始 HTML 先原样输出，统一交给下面的白名单过滤
+		goldmark.WithRendererOptions(goldmarkhtml.WithUnsafe()),
+	)
+
+	policy = newPolicy()
+	strip  = bluemonday.StrictPolicy()
+
+	spaces = regexp.MustCompile(`\s+`)
+)
+
+// newPolicy 白名单：只放行 markdown 能产生的排版标签；链接和图片只允许 http/https/mailto 和站内相对地址，
+// 外链统一加 rel="nofollow noopener" 并在新窗口打开
+func newPolicy() *bluemonday.Policy {
+	p := bluemonday.NewPolicy()
+
+	p.AllowElements("p", "br", "hr", "blockquote", "pre", "em", "strong", "del", "s", "sub", "sup",
+		"ul", "li", "dl", "dt", "dd", "table", "thead", "tbody", "tr", "details", "summary")
+	p.AllowAttrs("id").Matching(regexp.MustCompile(`^`+HeadingIDPrefix+`[\p{L}\p{N}_-]+$`)).OnElements("h1", "h2", "h3", "h4", "h5", "h6")
+	p.AllowElements("h1", "h2", "h3", "h4", "h5", "h6")
+	p.AllowAttrs("start").Matching(bluemonday.Integer).OnElements("ol")
+	p.AllowElements("ol")
+	p.AllowAttrs("class").Matching(regexp.MustCompile(`^language-[\w+#-]+$`)).OnElements("code")
+	p.AllowElements("code")
+	p.AllowAttrs("align").Matching(regexp.MustCompile(`^(left|center|right)$`)).OnElements("th", "td")
+	p.AllowElements("th", "td")
+
+	// 任务列表的勾选框
+	p.AllowAttrs("type").Matching(regexp.MustCompile(`^checkbox$`)).OnElements("input")
+	p.AllowAttrs("checked", "disabled").Matching(regexp.MustCompile(`^(|checked|disabled)$`)).OnElements("input")
+
+	p.AllowStandardURLs()
+	p.AllowURLSchemes("http", "https", "mailto")
+	p.AllowAttrs("href").OnElements("a")
+	p.AllowAttrs("title").OnElements("a", "img")
+	p.AllowAttrs("src", "alt").OnElements("img")
+	p.AllowAttrs("width", "height").Matching(bluemonday.Integer).OnElements("img")
+	p.RequireNoFollowOnLinks(true)
+	p.AddTargetBlankToFullyQualifiedLinks(true)
+
+	return p
+}
+
+// Render markdown 转 HTML 后按白名单过滤，结果可以直接 dangerouslySetInnerHTML
+func Render(src string) (string, error) {
+	var buf bytes.Buffer
+	ctx := parser.NewContext(parser.WithIDs(&headingIDs{used: map[string]bool{}}))
+	if err := renderer.Convert([]byte(src), &buf, parser.WithContext(ctx)); err != nil {
+		return "", err
+	}
+
+	return policy.Sanitize(buf.String()), nil
+}
+
+// headingIDs 按标题文字生成锚点：字母数字保留并转小写，空白和连字符变成 -，其它字符去掉；重名的依次加 -1、-2
+type headingIDs struct {
+	used map[string]bool
+}
+
+func (s *headingIDs) Generate(value []byte, _ ast.NodeKind) []byte {
+	var b strings.Builder
+	dash := false
+	for _, r := range string(value) {
+		switch {
+		case unicode.IsLetter(r) || unicode.IsNumber(r) || r == '_':
+			if dash && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			dash = false
+			b.WriteRune(unicode.ToLower(r))
+		case unicode.IsSpace(r) || r == '-':
+			dash = true
+		}
+	}
+
+	slug := b.String()
+	if slug == "" {
+		slug = "heading"
+	}
+	id := HeadingIDPrefix + slug
+	for i := 1; s.used[id]; i++ {
+		id = HeadingIDPrefix + slug + "-" + strconv.Itoa(i)
+	}
+	s.used[id] = true
+
+	return []byte(id)
+}
+
+func (s *headingIDs) Put(value []byte) {
+	s.used[string(value)] = true
+}
+
+// PlainText 从渲染好的 HTML 里取纯文本并折叠空白，搜索索引和摘要都用它
+func PlainText(renderedHTML string) string {
+	text := html.UnescapeString(strip.Sanitize(strings.ReplaceAll(renderedHTML, "<", " <")))
+	return strings.TrimSpace(spaces.ReplaceAllString(text, " "))
+}
+
+// Excerpt 纯文本截到 n 个字符
+func Excerpt(renderedHTML string, n int) string {
+	text := PlainText(renderedHTML)
+
+	if utf8.RuneCountInString(text) <= n {
+		return text
+	}
+
+	runes := []rune(text)
+	return strings.TrimSpace(string(runes[:n])) + "…"
+}
@@ -0,0 +1,116 @@
+package markdown
+
+import (
+	"strings"
+	"testing"
+)
+
+func render(t *testing.T, src string) string {
+	t.Helper()
+
+	out, err := Render(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return out
+}
+
+func TestRenderStripsXSS(t *testing.T) {
+	cases := []struct {
+		name   string
+		src    string
+		banned []string
+	}{
+		{"script tag", "hi <script>alert(1)</script>", []string{"<script", "alert(1)"}},
+		{"script block", "<script>\nalert(1)\n</script>", []string{"<script", "alert(1)"}},
+		{"style and iframe", "<style>body{}</style><iframe src=\"https://evil.example\"></iframe>", []string{"<style", "<iframe"}},
+		{"javascript link", "[x](javascript:alert(1))", []string{"javascript:"}},
+		{"mixed case javascript link", "[x](JaVaScRiPt:alert(1))", []string{"javascript:", "JaVaScRiPt:"}},
+		{"entity encoded javascript link", "<a href=\"jav&#x09;ascript:alert(1)\">x</a>", []string{"href"}},
+		{"reference javascript link", "[x][1]\n\n[1]: javascript:alert(1)", []string{"javascript:"}},
+		{"html javascript link", `<a href="javascript:alert(1)">x</a>`, []string{"javascript:"}},
+		{"data url", `<a href="data:text/html;base64,PHNjcmlwdD4=">x</a>`, []string{"data:"}},
+		{"vbscript link", "[x](vbscript:msgbox(1))", []string{"vbscript:"}},
+		{"javascript image", "![x](javascript:alert(1))", []string{"javascript:"}},
+		{"img onerror", `<img src=x onerror=alert(1)>`, []string{"onerror", "alert(1)"}},
+		{"img onerror quoted", `<img src="/a.png" onerror="alert(1)">`, []string{"onerror"}},
+		{"on attribute", `<p onclick="alert(1)">hi</p>`, []string{"onclick"}},
+		{"on attribute on heading", `<h2 onmouseover="alert(1)">hi</h2>`, []string{"onmouseover"}},
+		{"svg onload", `<svg onload=alert(1)><circle/></svg>`, []string{"<svg", "onload"}},
+		{"checkbox autofocus", `<input type="checkbox" autofocus onfocus="alert(1)">`, []string{"autofocus", "onfocus"}},
+		{"form", `<form action="https://evil.example"><button>go</button></form>`, []string{"<form", "<button"}},
+		{"style attribute", `<p style="background:url(javascript:alert(1))">x</p>`, []string{"style=", "javascript:"}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			out := render(t, tc.src)
+			for _, banned := range tc.banned {
+				if strings.Contains(strings.ToLower(out), strings.ToLower(banned)) {
+					t.Fatalf("output contains %q:\n%s", banned, out)
+				}
+			}
+		})
+	}
+}
+
+func TestRenderKeepsSafeMarkup(t *testing.T) {
+	src := "**bold** [link](https://example.com) [local](/posts/1)\n\n" +
+		"![img](/static/uploads/images/ab/x/medium.jpg \"t\")\n\n" +
+		"```go\nfmt.Println(1)\n```\n\n" +
+		"- [x] done\n"
+	out := render(t, src)
+
+	for _, want := range []string{
+		"<strong>bold</strong>",
+		`<a href="https://example.com" rel="nofollow noopener" target="_blank">link</a>`,
+		`<a href="/posts/1" rel="nofollow">local</a>`,
+		`<img src="/static/uploads/images/ab/x/medium.jpg" alt="img" title="t">`,
+		`<code class="language-go">`,
+		`<input checked="" disabled="" type="checkbox">`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %s:\n%s", want, out)
+		}
+	}
+}
+
+// TestHeadingIDs 锚点都带前缀，正文里写 id 也不能覆盖 document.cookie、window.location 之类的全局名字
+func TestHeadingIDs(t *testing.T) {
+	out := render(t, "# Cookie\n\n## location\n\n## location\n\n### 中文 标题!\n\n#### ???")
+	for _, want := range []string{
+		`<h1 id="user-content-cookie">`,
+		`<h2 id="user-content-location">`,
+		`<h2 id="user-content-location-1">`,
+		`<h3 id="user-content-中文-标题">`,
+		`<h4 id="user-content-heading">`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %s:\n%s", want, out)
+		}
+	}
+
+	for _, src := range []string{
+		`<h1 id="location">x</h1>`,
+		`<h1 id="__proto__">x</h1>`,
+		`<h1 id="user-content-x" name="cookie">x</h1>`,
+		`<img name="getElementById" id="body" src="/a.png">`,
+		`<a id="defaultView" name="cookie" href="/x">x</a>`,
+	} {
+		out := render(t, src)
+		for _, banned := range []string{`id="location"`, `id="__proto__"`, `name=`, `id="body"`, `id="defaultView"`} {
+			if strings.Contains(out, banned) {
+				t.Errorf("%s rendered %s", src, out)
+			}
+		}
+	}
+}
+
+func TestExcerpt(t *testing.T) {
+	out := render(t, "# Title\n\nSome *text* & <b>more</b> text")
+	if got := Excerpt(out, 100); got != "Title Some text & more text" {
+		t.Fatalf("excerpt = %q", got)
+	}
+	if got := Excerpt(out, 5); got != "Title…" {
+		t.Fatalf("truncated excerpt = %q", got)
+	}
+}
@@ -0,0 +1,85 @@
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+type Config struct {
+	Enabled bool `yaml:"enabled" env:"METRICS_ENABLED"`
+	// Token 非空时抓取 /metrics 要带 Authorization: Bearer <token>
+	Token string `yaml:"token" env:"METRICS_TOKEN"`
+}
+
+// 标签只用路由模板、表名、策略名这类有限取值，不要放用户 ID、原始路径，否则序列数会无限增长
+var (
+	HTTPRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_request_duration_seconds",
+		Help:    "HTTP request latency by route template and status code.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	DBQueryDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "db_query_duration_seconds",
+		Help:    "Database statement latency by operation and table.",
+		Buckets: []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+	}, []string{"operation", "table"})
+
+	RateLimitRejections = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "rate_limit_rejections_total",
+		Help: "Requests rejected by the rate limiter, by policy.",
+	}, []string{"policy"})
+
+	LoginFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "login_failures_total",
+		Help: "Failed login attempts by reason.",
+	}, []string{"reason"})
+
+	JanitorRuns = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "janitor_runs_total",
+		Help: "Janitor runs by result.",
+	}, []string{"result"})
+
+	JanitorRowsDeleted = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "janitor_rows_deleted_total",
+		Help: "Rows removed by the janitor, by table.",
+	}, []string{"table"})
+)
+
+// 登录失败原因，LoginFailures 的 reason 标签只用这几个值
+const (
+	LoginUnknownUser   = "unknown_user"
+	LoginBadPassword   = "bad_password"
+	LoginLocked        = "locked"
+	LoginBadTwoFactor  = "bad_2fa_code"
+	LoginChallengeGone = "challenge_expired"
+)
+
+// JanitorRuns 的 result 标签
+const (
+	JanitorOK     = "ok"
+	JanitorFailed = "failed"
+)
+
+var registry = prometheus.NewRegistry()
+
+func init() {
+	registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		HTTPRequestDuration,
+		DBQueryDuration,
+		RateLimitRejections,
+		LoginFailures,
+		JanitorRuns,
+		JanitorRowsDeleted,
+	)
+}
+
+// Handler 输出 Prometheus 文本格式
+func Handler() http.Handler {
+	return promhttp.HandlerFor(registry, promhttp.HandlerOpts{})
+}
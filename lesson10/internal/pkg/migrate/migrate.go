@@ -0,0 +1,232 @@
+// Package migrate 按版本号顺序执行 SQL 迁移脚本，执行记录和脚本校验和存在 schema_migrations 表里
+package migrate
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/fs"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+var (
+	ErrNoHistory        = errors.New("database has tables but no migration history, run `migrate baseline` first")
+	ErrChecksumMismatch = errors.New("applied migration was modified")
+	ErrIrreversible     = errors.New("migration has no down script")
+	ErrLocked           = errors.New("another instance is migrating")
+)
+
+type Migration struct {
+	Version  int
+	Name     string
+	Up       string
+	Down     string // 为空表示不能回滚
+	Checksum string // Up 的校验和，执行后再改脚本会被发现
+}
+
+// Baseline 截至 Version 的完整表结构，空库时代替 1~Version 的脚本
+type Baseline struct {
+	Version int
+	SQL     string
+}
+
+var (
+	migrationFile = regexp.MustCompile(`^(\d+)_([a-z0-9_]+)\.(up|down)\.sql$`)
+	versionedFile = regexp.MustCompile(`^\d+_.*\.sql$`)
+	baselineFile  = regexp.MustCompile(`^baseline_(\d+)\.sql$`)
+)
+
+// Load 读取 fsys 根目录下的脚本，按版本号排序；同一版本出现两个 up 或者只有 down 都算错。
+// 带版本号的脚本必须是 NNN_name.up.sql / NNN_name.down.sql，名字不对的直接报错，不会被悄悄跳过
+func Load(fsys fs.FS) ([]Migration, *Baseline, error) {
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, nil, err
+	}
+
+	byVersion := map[int]*Migration{}
+	var baseline *Baseline
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		name := entry.Name()
+
+		if m := baselineFile.FindStringSubmatch(name); m != nil {
+			raw, err := fs.ReadFile(fsys, name)
+			if err != nil {
+				return nil, nil, err
+			}
+			version, _ := strconv.Atoi(m[1])
+			if baseline != nil && baseline.Version >= version {
+				continue
+			}
+			baseline = &Baseline{Version: version, SQL: string(raw)}
+			continue
+		}
+
+		m := migrationFile.FindStringSubmatch(name)
+		if m == nil {
+			if versionedFile.MatchString(name) {
+				return nil, nil, fmt.Errorf("migration file %s: want NNN_name.up.sql or NNN_name.down.sql", name)
+			}
+			continue
+		}
+		raw, err := fs.ReadFile(fsys, name)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		version, _ := strconv.Atoi(m[1])
+		mig := byVersion[version]
+		if mig == nil {
+			mig = &Migration{Version: version, Name: m[2]}
+			byVersion[version] = mig
+		}
+		if mig.Name != m[2] {
+			return nil, nil, fmt.Errorf("migration %03d has two names: %s and %s", version, mig.Name, m[2])
+		}
+
+		if m[3] == "down" {
+			mig.Down = string(raw)
+			continue
+		}
+		if mig.Up != "" {
+			return nil, nil, fmt.Errorf("migration %03d has more than one up script", version)
+		}
+		mig.Up = string(raw)
+		mig.Checksum = Checksum(mig.Up)
+	}
+
+	migrations := make([]Migration, 0, len(byVersion))
+	for _, mig := range byVersion {
+		if mig.Up == "" {
+			return nil, nil, fmt.Errorf("migration %03d_%s has a down script but no up script", mig.Version, mig.Name)
+		}
+		migrations = append(migrations, *mig)
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+
+	return migrations, baseline, nil
+}
+
+// Checksum 忽略换行符风格和行尾空白，只在脚本内容真的变了时才不一样
+func Checksum(script string) string {
+	lines := strings.Split(strings.ReplaceAll(script, "\r\n", "\n"), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " \t")
+	}
+
+	sum := sha256.Sum256([]byte(strings.TrimSpace(strings.Join(lines, "\n"))))
+	return hex.EncodeToString(sum[:])
+}
+
+// Split 按分号拆成单条语句，跳过引号和注释里的分号；只有注释的片段丢掉。
+// 和 mysql 客户端一样认语句开头那一行的 DELIMITER 命令，建触发器、存储过程时可以临时换成 $$ 之类的结束符
+func Split(script string) []string {
+	var (
+		statements []string
+		current    strings.Builder
+		hasCode    bool
+		lineStart  = true // 当前行到这里为止只有空白
+	)
+	delimiter := []rune(";")
+	flush := func() {
+		if hasCode {
+			statements = append(statements, strings.TrimSpace(current.String()))
+		}
+		current.Reset()
+		hasCode = false
+	}
+
+	runes := []rune(script)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+		switch {
+		case lineStart && !hasCode && isDelimiterCommand(runes[i:]):
+			end := i
+			for end < len(runes) && runes[end] != '\n' {
+				end++
+			}
+			if fields := strings.Fields(string(runes[i:end])); len(fields) > 1 {
+				flush()
+				delimiter = []rune(fields[1])
+			}
+			i = end
+		case r == '-' && i+2 < len(runes) && runes[i+1] == '-' && unicode.IsSpace(runes[i+2]), r == '#':
+			for i < len(runes) && runes[i] != '\n' {
+				current.WriteRune(runes[i])
+				i++
+			}
+			if i < len(runes) {
+				current.WriteRune(runes[i])
+			}
+		case r == '/' && i+1 < len(runes) && runes[i+1] == '*':
+			j := i + 2
+			for j < len(runes) && !(runes[j] == '*' && j+1 < len(runes) && runes[j+1] == '/') {
+				j++
+			}
+			j = min(j+2, len(runes))
+			current.WriteString(string(runes[i:j]))
+			i = j - 1
+		case r == '\'' || r == '"' || r == '`':
+			hasCode = true
+			current.WriteRune(r)
+			for i++; i < len(runes); i++ {
+				current.WriteRune(runes[i])
+				if runes[i] == '\\' && r != '`' && i+1 < len(runes) {
+					i++
+					current.WriteRune(runes[i])
+					continue
+				}
+				if runes[i] == r {
+					break
+				}
+			}
+		case hasPrefix(runes[i:], delimiter):
+			flush()
+			i += len(delimiter) - 1
+		default:
+			if !unicode.IsSpace(r) {
+				hasCode = true
+			}
+			current.WriteRune(r)
+		}
+
+		if i < len(runes) {
+			if runes[i] == '\n' {
+				lineStart = true
+			} else if !unicode.IsSpace(runes[i]) {
+				lineStart = false
+			}
+		}
+	}
+	flush()
+
+	return statements
+}
+
+func isDelimiterCommand(runes []rune) bool {
+	const command = "delimiter"
+	if len(runes) <= len(command) || !unicode.IsSpace(runes[len(command)]) {
+		return false
+	}
+	return strings.EqualFold(string(runes[:len(command)]), command)
+}
+
+func hasPrefix(runes []rune, prefix []rune) bool {
+	if len(runes) < len(prefix) {
+		return false
+	}
+	for i, r := range prefix {
+		if runes[i] != r {
+			return false
+		}
+	}
+	return true
+}
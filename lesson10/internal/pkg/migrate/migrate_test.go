@@ -0,0 +1,138 @@
+package migrate
+
+import (
+	"lesson10/migrations"
+	"reflect"
+	"testing"
+	"testing/fstest"
+)
+
+func TestSplit(t *testing.T) {
+	cases := []struct {
+		name   string
+		script string
+		want   []string
+	}{
+		{
+			"plain",
+			"CREATE TABLE a (id INT);\nCREATE TABLE b (id INT);\n",
+			[]string{"CREATE TABLE a (id INT)", "CREATE TABLE b (id INT)"},
+		},
+		{
+			"no trailing semicolon",
+			"SELECT 1;\nSELECT 2",
+			[]string{"SELECT 1", "SELECT 2"},
+		},
+		{
+			"semicolons in quotes",
+			`INSERT INTO t VALUES ('a;b', "c;d", 'it''s;', 'x\';y');` + "\nSELECT `odd;name` FROM t;",
+			[]string{`INSERT INTO t VALUES ('a;b', "c;d", 'it''s;', 'x\';y')`, "SELECT `odd;name` FROM t"},
+		},
+		{
+			"semicolons in comments",
+			"-- first; comment\nSELECT 1; # trailing; comment\n/* block;\ncomment */ SELECT 2;",
+			[]string{"-- first; comment\nSELECT 1", "# trailing; comment\n/* block;\ncomment */ SELECT 2"},
+		},
+		{
+			"comment only fragments dropped",
+			"SELECT 1;\n-- nothing here;\n/* or here */;\n",
+			[]string{"SELECT 1"},
+		},
+		{
+			"double dash without space is not a comment",
+			"SELECT 1--1;\nSELECT 2;",
+			[]string{"SELECT 1--1", "SELECT 2"},
+		},
+		{
+			"delimiter",
+			"CREATE TABLE t (id INT);\n" +
+				"DELIMITER $$\n" +
+				"CREATE TRIGGER t_bi BEFORE INSERT ON t FOR EACH ROW\nBEGIN\n  SET NEW.id = NEW.id + 1;\nEND$$\n" +
+				"delimiter ;\n" +
+				"SELECT 1;",
+			[]string{
+				"CREATE TABLE t (id INT)",
+				"CREATE TRIGGER t_bi BEFORE INSERT ON t FOR EACH ROW\nBEGIN\n  SET NEW.id = NEW.id + 1;\nEND",
+				"SELECT 1",
+			},
+		},
+		{
+			"delimiter word inside a statement",
+			"CREATE TABLE t (\n  id INT,\ndelimiter VARCHAR(8)\n);",
+			[]string{"CREATE TABLE t (\n  id INT,\ndelimiter VARCHAR(8)\n)"},
+		},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := Split(tc.script); !reflect.DeepEqual(got, tc.want) {
+				t.Fatalf("Split =\n%q\nwant\n%q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestChecksumIgnoresLineEndings(t *testing.T) {
+	a := Checksum("CREATE TABLE t (id INT);\nSELECT 1;\n")
+	if b := Checksum("CREATE TABLE t (id INT);  \r\nSELECT 1;\r\n\r\n"); a != b {
+		t.Fatal("checksum changed with line endings and trailing whitespace")
+	}
+	if c := Checksum("CREATE TABLE t (id BIGINT);\nSELECT 1;\n"); a == c {
+		t.Fatal("checksum ignored a real change")
+	}
+}
+
+func TestLoad(t *testing.T) {
+	migrations, baseline, err := Load(fstest.MapFS{
+		"002_add_b.up.sql":   {Data: []byte("CREATE TABLE b (id INT);")},
+		"002_add_b.down.sql": {Data: []byte("DROP TABLE b;")},
+		"001_init.up.sql":    {Data: []byte("CREATE TABLE a (id INT);")},
+		"baseline_001.sql":   {Data: []byte("old")},
+		"baseline_002.sql":   {Data: []byte("new")},
+		"README.md":          {Data: []byte("ignored")},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(migrations) != 2 || migrations[0].Name != "init" || migrations[1].Down != "DROP TABLE b;" || migrations[0].Down != "" {
+		t.Fatalf("migrations = %+v", migrations)
+	}
+	if baseline == nil || baseline.Version != 2 || baseline.SQL != "new" {
+		t.Fatalf("baseline = %+v", baseline)
+	}
+
+	sql := &fstest.MapFile{Data: []byte("SELECT 1;")}
+	bad := map[string]fstest.MapFS{
+		"two names":  {"001_a.up.sql": sql, "001_b.up.sql": sql},
+		"no suffix":  {"001_a.sql": sql},
+		"upper case": {"001_A.up.sql": sql},
+		"down only":  {"001_a.down.sql": sql},
+		"empty up":   {"001_a.up.sql": {}, "001_a.down.sql": sql},
+	}
+	for name, fsys := range bad {
+		if _, _, err := Load(fsys); err == nil {
+			t.Errorf("%s: Load succeeded", name)
+		}
+	}
+}
+
+// TestEmbeddedMigrations 仓库里的脚本版本号连续、都能加载；已经发布的脚本不能再改
+func TestEmbeddedMigrations(t *testing.T) {
+	loaded, baseline, err := Load(migrations.Files)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, m := range loaded {
+		if m.Version != i+1 {
+			t.Fatalf("migration %03d_%s at position %d, versions must be contiguous", m.Version, m.Name, i+1)
+		}
+	}
+	if baseline == nil || baseline.Version > len(loaded) {
+		t.Fatalf("baseline = %+v", baseline)
+	}
+
+	// 007 第一次提交时的内容，改了会让执行过它的库启动失败
+	const adminStatsIndexes = "0d016193bc3531f8a0164869666fd90e361ffa33571eee8399df997556b95678"
+	if got := loaded[6].Checksum; got != adminStatsIndexes {
+		t.Fatalf("007_%s checksum = %s, applied databases expect %s", loaded[6].Name, got, adminStatsIndexes)
+	}
+}
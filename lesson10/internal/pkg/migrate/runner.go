@@ -0,0 +1,433 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log"
+	"sort"
+	"strings"
+	"time"
+)
+
+const createTableSQL = `CREATE TABLE IF NOT EXISTS schema_migrations (
+    version BIGINT NOT NULL,
+    name VARCHAR(191) NOT NULL,
+    checksum CHAR(64) NOT NULL,
+    baseline TINYINT(1) NOT NULL DEFAULT 0,   -- 1 表示没有真正执行，是建库或 baseline 命令记上的
+    execution_ms BIGINT NOT NULL DEFAULT 0,
+    applied_at DATETIME(3) NOT NULL,
+
+    PRIMARY KEY (version)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4`
+
+const lockName = "lesson10.schema_migrations"
+
+// Applied schema_migrations 里的一行
+type Applied struct {
+	Version     int
+	Name        string
+	Checksum    string
+	Baseline    bool
+	ExecutionMs int64
+	AppliedAt   time.Time
+}
+
+// Status State 取值：applied 已执行；pending 未执行；modified 执行后脚本被改过；missing 执行过但脚本已经不在了
+type Status struct {
+	Version    int        `json:"version"`
+	Name       string     `json:"name"`
+	State      string     `json:"state"`
+	Baseline   bool       `json:"baseline,omitempty"`
+	Reversible bool       `json:"reversible"`
+	AppliedAt  *time.Time `json:"applied_at,omitempty"`
+}
+
+// dialect 建表、锁和空库判断依赖数据库，默认是 MySQL 的实现
+type dialect struct {
+	createTable string
+	hasTable    func(ctx context.Context, conn *sql.Conn) (bool, error)
+	lock        func(ctx context.Context, conn *sql.Conn, timeout time.Duration) error
+	unlock      func(ctx context.Context, conn *sql.Conn) error
+	userTables  func(ctx context.Context, conn *sql.Conn) (int, error)
+}
+
+var mysqlDialect = dialect{
+	createTable: createTableSQL,
+	hasTable: func(ctx context.Context, conn *sql.Conn) (bool, error) {
+		var n int
+		err := conn.QueryRowContext(ctx, `
+			SELECT COUNT(*) FROM information_schema.TABLES
+			WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'schema_migrations'`).Scan(&n)
+		return n > 0, err
+	},
+	lock: func(ctx context.Context, conn *sql.Conn, timeout time.Duration) error {
+		var got sql.NullInt64
+		if err := conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, ?)", lockName, int(timeout.Seconds())).Scan(&got); err != nil {
+			return err
+		}
+		if !got.Valid || got.Int64 != 1 {
+			return ErrLocked
+		}
+		return nil
+	},
+	unlock: func(ctx context.Context, conn *sql.Conn) error {
+		_, err := conn.ExecContext(ctx, "SELECT RELEASE_LOCK(?)", lockName)
+		return err
+	},
+	userTables: func(ctx context.Context, conn *sql.Conn) (int, error) {
+		var n int
+		err := conn.QueryRowContext(ctx, `
+			SELECT COUNT(*) FROM information_schema.TABLES
+			WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME <> 'schema_migrations'`).Scan(&n)
+		return n, err
+	},
+}
+
+type Runner struct {
+	db         *sql.DB
+	migrations []Migration
+	baseline   *Baseline
+	dialect    dialect
+
+	// LockTimeout 等其它实例迁移完成的最长时间
+	LockTimeout time.Duration
+	Logf        func(format string, args ...any)
+}
+
+func New(db *sql.DB, fsys fs.FS) (*Runner, error) {
+	migrations, baseline, err := Load(fsys)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Runner{
+		db:          db,
+		migrations:  migrations,
+		baseline:    baseline,
+		dialect:     mysqlDialect,
+		LockTimeout: time.Minute,
+		Logf:        log.Printf,
+	}, nil
+}
+
+func (r *Runner) Migrations() []Migration {
+	return r.migrations
+}
+
+// Up 依次执行未执行的脚本，target > 0 时执行到这个版本为止。
+// 空库且有 baseline 时先用 baseline 建表；库里已经有表但没有执行记录时返回 ErrNoHistory。
+// MySQL 的 DDL 会隐式提交，脚本中途失败时前面的语句不会回滚，错误里带着失败的是第几条语句，修好后重跑
+func (r *Runner) Up(ctx context.Context, target int) ([]Migration, error) {
+	var done []Migration
+	err := r.withLock(ctx, func(conn *sql.Conn) error {
+		applied, err := r.applied(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		if len(applied) == 0 && r.baseline != nil {
+			tables, err := r.dialect.userTables(ctx, conn)
+			if err != nil {
+				return err
+			}
+			if tables > 0 {
+				return ErrNoHistory
+			}
+			if err := r.applyBaseline(ctx, conn); err != nil {
+				return err
+			}
+			if applied, err = r.applied(ctx, conn); err != nil {
+				return err
+			}
+		}
+
+		if err := r.verify(applied); err != nil {
+			return err
+		}
+
+		for _, m := range r.migrations {
+			if _, ok := applied[m.Version]; ok {
+				continue
+			}
+			if target > 0 && m.Version > target {
+				break
+			}
+			if err := r.apply(ctx, conn, m); err != nil {
+				return err
+			}
+			done = append(done, m)
+		}
+
+		return nil
+	})
+
+	return done, err
+}
+
+// Down 从最新的开始回滚 steps 个版本；没有 down 脚本或者是 baseline 记上的版本不能回滚
+func (r *Runner) Down(ctx context.Context, steps int) ([]Migration, error) {
+	var done []Migration
+	err := r.withLock(ctx, func(conn *sql.Conn) error {
+		applied, err := r.applied(ctx, conn)
+		if err != nil {
+			return err
+		}
+		if err := r.verify(applied); err != nil {
+			return err
+		}
+
+		versions := make([]int, 0, len(applied))
+		for version := range applied {
+			versions = append(versions, version)
+		}
+		sort.Sort(sort.Reverse(sort.IntSlice(versions)))
+
+		byVersion := r.byVersion()
+		for _, version := range versions[:min(steps, len(versions))] {
+			m, ok := byVersion[version]
+			if !ok || m.Down == "" || applied[version].Baseline {
+				return fmt.Errorf("%03d_%s: %w", version, applied[version].Name, ErrIrreversible)
+			}
+
+			if err := r.exec(ctx, conn, m, m.Down); err != nil {
+				return err
+			}
+			if _, err := conn.ExecContext(ctx, "DELETE FROM schema_migrations WHERE version = ?", version); err != nil {
+				return err
+			}
+			r.Logf("reverted %03d_%s", m.Version, m.Name)
+			done = append(done, m)
+		}
+
+		return nil
+	})
+
+	return done, err
+}
+
+// Baseline 给已有数据、但从没用过迁移工具的库（之前靠 AutoMigrate 加手工执行脚本维护）记上 1~version，不执行任何脚本
+func (r *Runner) Baseline(ctx context.Context, version int) error {
+	return r.withLock(ctx, func(conn *sql.Conn) error {
+		applied, err := r.applied(ctx, conn)
+		if err != nil {
+			return err
+		}
+		if len(applied) > 0 {
+			return errors.New("schema_migrations is not empty")
+		}
+
+		return r.record(ctx, conn, version)
+	})
+}
+
+// Status 只读，不会建 schema_migrations；还没迁移过的库所有版本都是 pending
+func (r *Runner) Status(ctx context.Context) ([]Status, error) {
+	conn, err := r.db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	applied, err := r.applied(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]Status, 0, len(r.migrations))
+	for _, m := range r.migrations {
+		status := Status{Version: m.Version, Name: m.Name, State: "pending", Reversible: m.Down != ""}
+		if row, ok := applied[m.Version]; ok {
+			status.State = "applied"
+			if row.Checksum != m.Checksum {
+				status.State = "modified"
+			}
+			status.Baseline = row.Baseline
+			status.Reversible = status.Reversible && !row.Baseline
+			status.AppliedAt = &row.AppliedAt
+		}
+		statuses = append(statuses, status)
+	}
+
+	byVersion := r.byVersion()
+	for version, row := range applied {
+		if _, ok := byVersion[version]; !ok {
+			statuses = append(statuses, Status{Version: version, Name: row.Name, State: "missing", Baseline: row.Baseline, AppliedAt: &row.AppliedAt})
+		}
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Version < statuses[j].Version })
+
+	return statuses, nil
+}
+
+// Pending 启动时检查用，和 Status 一样只读：还没执行的脚本；有脚本被改过时返回 ErrChecksumMismatch，有表没记录时返回 ErrNoHistory
+func (r *Runner) Pending(ctx context.Context) ([]Migration, error) {
+	conn, err := r.db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	applied, err := r.applied(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+	if len(applied) == 0 {
+		tables, err := r.dialect.userTables(ctx, conn)
+		if err != nil {
+			return nil, err
+		}
+		if tables > 0 {
+			return nil, ErrNoHistory
+		}
+	}
+	if err := r.verify(applied); err != nil {
+		return nil, err
+	}
+
+	var pending []Migration
+	for _, m := range r.migrations {
+		if _, ok := applied[m.Version]; !ok {
+			pending = append(pending, m)
+		}
+	}
+
+	return pending, nil
+}
+
+// withLock 要改库的命令（up/down/baseline）拿到锁之后才建 schema_migrations
+func (r *Runner) withLock(ctx context.Context, fn func(conn *sql.Conn) error) error {
+	// 锁和脚本都在同一个连接上：MySQL 的 GET_LOCK 属于连接
+	conn, err := r.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if err := r.dialect.lock(ctx, conn, r.LockTimeout); err != nil {
+		return err
+	}
+	defer func() {
+		if err := r.dialect.unlock(context.WithoutCancel(ctx), conn); err != nil {
+			r.Logf("release migration lock: %v", err)
+		}
+	}()
+
+	if _, err := conn.ExecContext(ctx, r.dialect.createTable); err != nil {
+		return err
+	}
+
+	return fn(conn)
+}
+
+// applied schema_migrations 还不存在时当作没有执行记录
+func (r *Runner) applied(ctx context.Context, conn *sql.Conn) (map[int]Applied, error) {
+	applied := map[int]Applied{}
+	exists, err := r.dialect.hasTable(ctx, conn)
+	if err != nil || !exists {
+		return applied, err
+	}
+
+	rows, err := conn.QueryContext(ctx, "SELECT version, name, checksum, baseline, execution_ms, applied_at FROM schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var row Applied
+		if err := rows.Scan(&row.Version, &row.Name, &row.Checksum, &row.Baseline, &row.ExecutionMs, &row.AppliedAt); err != nil {
+			return nil, err
+		}
+		applied[row.Version] = row
+	}
+
+	return applied, rows.Err()
+}
+
+// verify 已执行的脚本不允许再改，要改结构请加新版本
+func (r *Runner) verify(applied map[int]Applied) error {
+	var modified []string
+	for _, m := range r.migrations {
+		if row, ok := applied[m.Version]; ok && row.Checksum != m.Checksum {
+			modified = append(modified, fmt.Sprintf("%03d_%s", m.Version, m.Name))
+		}
+	}
+	if len(modified) > 0 {
+		return fmt.Errorf("%w: %s", ErrChecksumMismatch, strings.Join(modified, ", "))
+	}
+
+	return nil
+}
+
+func (r *Runner) applyBaseline(ctx context.Context, conn *sql.Conn) error {
+	started := time.Now()
+	for i, stmt := range Split(r.baseline.SQL) {
+		if _, err := conn.ExecContext(ctx, stmt); err != nil {
+			return fmt.Errorf("baseline_%03d statement %d: %w", r.baseline.Version, i+1, err)
+		}
+	}
+	if err := r.record(ctx, conn, r.baseline.Version); err != nil {
+		return err
+	}
+
+	r.Logf("created schema from baseline_%03d in %s", r.baseline.Version, time.Since(started).Round(time.Millisecond))
+	return nil
+}
+
+// record 把 version 及以前的脚本记为 baseline
+func (r *Runner) record(ctx context.Context, conn *sql.Conn, version int) error {
+	now := time.Now()
+	for _, m := range r.migrations {
+		if m.Version > version {
+			break
+		}
+		_, err := conn.ExecContext(ctx,
+			"INSERT INTO schema_migrations (version, name, checksum, baseline, execution_ms, applied_at) VALUES (?, ?, ?, 1, 0, ?)",
+			m.Version, m.Name, m.Checksum, now)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (r *Runner) apply(ctx context.Context, conn *sql.Conn, m Migration) error {
+	started := time.Now()
+	if err := r.exec(ctx, conn, m, m.Up); err != nil {
+		return err
+	}
+
+	elapsed := time.Since(started)
+	_, err := conn.ExecContext(ctx,
+		"INSERT INTO schema_migrations (version, name, checksum, baseline, execution_ms, applied_at) VALUES (?, ?, ?, 0, ?, ?)",
+		m.Version, m.Name, m.Checksum, elapsed.Milliseconds(), time.Now())
+	if err != nil {
+		return err
+	}
+
+	r.Logf("applied %03d_%s in %s", m.Version, m.Name, elapsed.Round(time.Millisecond))
+	return nil
+}
+
+func (r *Runner) exec(ctx context.Context, conn *sql.Conn, m Migration, script string) error {
+	for i, stmt := range Split(script) {
+		if _, err := conn.ExecContext(ctx, stmt); err != nil {
+			return fmt.Errorf("%03d_%s statement %d: %w", m.Version, m.Name, i+1, err)
+		}
+	}
+
+	return nil
+}
+
+func (r *Runner) byVersion() map[int]Migration {
+	byVersion := make(map[int]Migration, len(r.migrations))
+	for _, m := range r.migrations {
+		byVersion[m.Version] = m
+	}
+
+	return byVersion
+}
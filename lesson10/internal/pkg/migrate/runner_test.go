@@ -0,0 +1,262 @@
+package migrate
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// sqliteDialect 测试用：单连接的 SQLite 不需要锁
+var sqliteDialect = dialect{
+	createTable: `CREATE TABLE IF NOT EXISTS schema_migrations (
+		version INTEGER NOT NULL PRIMARY KEY,
+		name TEXT NOT NULL,
+		checksum TEXT NOT NULL,
+		baseline INTEGER NOT NULL DEFAULT 0,
+		execution_ms INTEGER NOT NULL DEFAULT 0,
+		applied_at DATETIME NOT NULL
+	)`,
+	hasTable: func(ctx context.Context, conn *sql.Conn) (bool, error) {
+		var n int
+		err := conn.QueryRowContext(ctx, "SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = 'schema_migrations'").Scan(&n)
+		return n > 0, err
+	},
+	lock:   func(context.Context, *sql.Conn, time.Duration) error { return nil },
+	unlock: func(context.Context, *sql.Conn) error { return nil },
+	userTables: func(ctx context.Context, conn *sql.Conn) (int, error) {
+		var n int
+		err := conn.QueryRowContext(ctx, "SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name <> 'schema_migrations'").Scan(&n)
+		return n, err
+	},
+}
+
+var testMigrations = fstest.MapFS{
+	"baseline_002.sql":      {Data: []byte("CREATE TABLE a (id INTEGER);\nCREATE TABLE b (id INTEGER);")},
+	"001_create_a.up.sql":   {Data: []byte("CREATE TABLE a (id INTEGER);")},
+	"001_create_a.down.sql": {Data: []byte("DROP TABLE a;")},
+	"002_create_b.up.sql":   {Data: []byte("CREATE TABLE b (id INTEGER);")},
+	"003_create_c.up.sql":   {Data: []byte("CREATE TABLE c (id INTEGER);\n-- 第二条\nCREATE INDEX idx_c_id ON c (id);")},
+	"003_create_c.down.sql": {Data: []byte("DROP TABLE c;")},
+}
+
+func newTestRunner(t *testing.T, fsys fstest.MapFS) (*Runner, *sql.DB) {
+	t.Helper()
+
+	gdb, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := gdb.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	r, err := New(db, fsys)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.dialect = sqliteDialect
+	r.Logf = t.Logf
+	return r, db
+}
+
+func tableExists(t *testing.T, db *sql.DB, name string) bool {
+	t.Helper()
+
+	var n int
+	if err := db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type = 'table' AND name = ?", name).Scan(&n); err != nil {
+		t.Fatal(err)
+	}
+	return n > 0
+}
+
+func states(statuses []Status) string {
+	parts := make([]string, 0, len(statuses))
+	for _, s := range statuses {
+		state := s.State
+		if s.Baseline {
+			state += "(baseline)"
+		}
+		parts = append(parts, state)
+	}
+	return strings.Join(parts, " ")
+}
+
+func TestStatusAndPendingAreReadOnly(t *testing.T) {
+	r, db := newTestRunner(t, testMigrations)
+	ctx := context.Background()
+
+	statuses, err := r.Status(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := states(statuses); got != "pending pending pending" {
+		t.Fatalf("states = %s", got)
+	}
+	pending, err := r.Pending(ctx)
+	if err != nil || len(pending) != 3 {
+		t.Fatalf("pending = %d, %v", len(pending), err)
+	}
+	if tableExists(t, db, "schema_migrations") {
+		t.Fatal("status created schema_migrations")
+	}
+}
+
+func TestUpFromBaseline(t *testing.T) {
+	r, db := newTestRunner(t, testMigrations)
+	ctx := context.Background()
+
+	done, err := r.Up(ctx, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(done) != 1 || done[0].Version != 3 {
+		t.Fatalf("done = %+v", done)
+	}
+	for _, table := range []string{"a", "b", "c"} {
+		if !tableExists(t, db, table) {
+			t.Fatalf("table %s missing", table)
+		}
+	}
+
+	statuses, err := r.Status(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := states(statuses); got != "applied(baseline) applied(baseline) applied" {
+		t.Fatalf("states = %s", got)
+	}
+	if statuses[0].Reversible || !statuses[2].Reversible {
+		t.Fatalf("reversible = %v %v", statuses[0].Reversible, statuses[2].Reversible)
+	}
+
+	// 已经是最新
+	if done, err := r.Up(ctx, 0); err != nil || len(done) != 0 {
+		t.Fatalf("second up = %d, %v", len(done), err)
+	}
+}
+
+func TestUpWithoutBaselineStopsAtTarget(t *testing.T) {
+	fsys := fstest.MapFS{}
+	for name, file := range testMigrations {
+		if !strings.HasPrefix(name, "baseline") {
+			fsys[name] = file
+		}
+	}
+	r, db := newTestRunner(t, fsys)
+	ctx := context.Background()
+
+	done, err := r.Up(ctx, 2)
+	if err != nil || len(done) != 2 {
+		t.Fatalf("up to 2 = %d, %v", len(done), err)
+	}
+	if tableExists(t, db, "c") {
+		t.Fatal("applied past target")
+	}
+	pending, err := r.Pending(ctx)
+	if err != nil || len(pending) != 1 || pending[0].Version != 3 {
+		t.Fatalf("pending = %+v, %v", pending, err)
+	}
+}
+
+// TestExistingDatabaseNeedsBaseline 有表但没有执行记录的库（之前靠 AutoMigrate 或 initdb 建的）要先 baseline
+func TestExistingDatabaseNeedsBaseline(t *testing.T) {
+	r, db := newTestRunner(t, testMigrations)
+	ctx := context.Background()
+
+	for _, stmt := range []string{"CREATE TABLE a (id INTEGER)", "CREATE TABLE b (id INTEGER)"} {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if _, err := r.Pending(ctx); !errors.Is(err, ErrNoHistory) {
+		t.Fatalf("pending err = %v, want ErrNoHistory", err)
+	}
+	if _, err := r.Up(ctx, 0); !errors.Is(err, ErrNoHistory) {
+		t.Fatalf("up err = %v, want ErrNoHistory", err)
+	}
+
+	if err := r.Baseline(ctx, 2); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Baseline(ctx, 2); err == nil {
+		t.Fatal("second baseline succeeded")
+	}
+	done, err := r.Up(ctx, 0)
+	if err != nil || len(done) != 1 || done[0].Version != 3 {
+		t.Fatalf("up after baseline = %+v, %v", done, err)
+	}
+}
+
+func TestModifiedMigration(t *testing.T) {
+	r, _ := newTestRunner(t, testMigrations)
+	ctx := context.Background()
+
+	if _, err := r.Up(ctx, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	r.migrations[2].Checksum = Checksum("CREATE TABLE c (id BIGINT);")
+	if _, err := r.Pending(ctx); !errors.Is(err, ErrChecksumMismatch) || !strings.Contains(err.Error(), "003_create_c") {
+		t.Fatalf("pending err = %v", err)
+	}
+	if _, err := r.Up(ctx, 0); !errors.Is(err, ErrChecksumMismatch) {
+		t.Fatalf("up err = %v", err)
+	}
+	statuses, err := r.Status(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if statuses[2].State != "modified" {
+		t.Fatalf("state = %s", statuses[2].State)
+	}
+}
+
+func TestDown(t *testing.T) {
+	r, db := newTestRunner(t, testMigrations)
+	ctx := context.Background()
+
+	if _, err := r.Up(ctx, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	done, err := r.Down(ctx, 1)
+	if err != nil || len(done) != 1 || done[0].Version != 3 {
+		t.Fatalf("down = %+v, %v", done, err)
+	}
+	if tableExists(t, db, "c") {
+		t.Fatal("table c still exists")
+	}
+
+	// 002 是 baseline 记上的，没法回滚
+	if _, err := r.Down(ctx, 1); !errors.Is(err, ErrIrreversible) {
+		t.Fatalf("down baseline err = %v, want ErrIrreversible", err)
+	}
+}
+
+func TestFailedStatementIsReported(t *testing.T) {
+	fsys := fstest.MapFS{
+		"001_ok.up.sql":     {Data: []byte("CREATE TABLE a (id INTEGER);")},
+		"002_broken.up.sql": {Data: []byte("CREATE TABLE b (id INTEGER);\nCREATE TABLE a (id INTEGER);")},
+	}
+	r, _ := newTestRunner(t, fsys)
+
+	done, err := r.Up(context.Background(), 0)
+	if err == nil || !strings.Contains(err.Error(), "002_broken statement 2") {
+		t.Fatalf("err = %v", err)
+	}
+	if len(done) != 1 {
+		t.Fatalf("done = %+v", done)
+	}
+}
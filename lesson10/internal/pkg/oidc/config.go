@@ -0,0 +1,31 @@
+package oidc
+
+import (
+	"strings"
+)
+
+// LoadProviders 按配置创建 enabled 里列出的 provider，没配回调地址时用 baseURL/oauth/<name>/callback。
+// 配置已经由 config.Validate 检查过，例如：
+//
+//	OIDC_PROVIDERS=mock
+//	OIDC_MOCK_ISSUER=http://localhost:9090
+//	OIDC_MOCK_CLIENT_ID=lesson10
+//	OIDC_MOCK_CLIENT_SECRET=lesson10-secret
+//	OIDC_MOCK_REDIRECT_URL=http://localhost:3000/oauth/mock/callback
+func LoadProviders(enabled []string, clients map[string]Config, baseURL string) map[string]*Provider {
+	providers := map[string]*Provider{}
+
+	for _, name := range enabled {
+		cfg := clients[name]
+		cfg.Name = name
+		if cfg.RedirectURL == "" {
+			cfg.RedirectURL = strings.TrimRight(baseURL, "/") + "/oauth/" + name + "/callback"
+		}
+		// 以前的写法允许空格分隔
+		cfg.Scopes = strings.Fields(strings.Join(cfg.Scopes, " "))
+
+		providers[name] = NewProvider(cfg)
+	}
+
+	return providers
+}
@@ -0,0 +1,135 @@
+package oidc_test
+
+import (
+	"context"
+	"errors"
+	"lesson10/internal/pkg/oidc"
+	"lesson10/internal/pkg/oidc/mockoidc"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"golang.org/x/oauth2"
+)
+
+const redirectURL = "http://localhost:3000/oauth/mock/callback"
+
+func newMock(t *testing.T) (*oidc.Provider, *httptest.Server) {
+	t.Helper()
+
+	srv := httptest.NewUnstartedServer(nil)
+	mock, err := mockoidc.New(mockoidc.Config{Issuer: "http://" + srv.Listener.Addr().String(), ClientID: "lesson10", ClientSecret: "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.Config.Handler = mock.Handler()
+	srv.Start()
+	t.Cleanup(srv.Close)
+
+	provider := oidc.NewProvider(oidc.Config{
+		Name:         "mock",
+		Issuer:       srv.URL,
+		ClientID:     "lesson10",
+		ClientSecret: "secret",
+		RedirectURL:  redirectURL,
+	})
+	return provider, srv
+}
+
+// authorize 模拟用户在 provider 页面点了授权，返回回调地址上的 code 和 state
+func authorize(t *testing.T, authURL string, user string) (code string, state string) {
+	t.Helper()
+
+	u, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := u.Query()
+	q.Set("user", user)
+	q.Set("email", user+"@example.com")
+	u.RawQuery = q.Encode()
+
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	resp, err := client.Get(u.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusFound {
+		t.Fatalf("authorize: status %d", resp.StatusCode)
+	}
+
+	callback, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return callback.Query().Get("code"), callback.Query().Get("state")
+}
+
+func TestAuthCodeFlowWithPKCE(t *testing.T) {
+	provider, _ := newMock(t)
+	ctx := context.Background()
+	verifier := oauth2.GenerateVerifier()
+
+	authURL, err := provider.AuthCodeURL(ctx, "state-1", "nonce-1", verifier)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q, _ := url.Parse(authURL)
+	if q.Query().Get("code_challenge_method") != "S256" || q.Query().Get("code_challenge") == "" {
+		t.Fatalf("authorize url has no S256 challenge: %s", authURL)
+	}
+	if q.Query().Get("code_challenge") == verifier {
+		t.Fatal("verifier sent in the clear")
+	}
+
+	code, state := authorize(t, authURL, "alice")
+	if state != "state-1" {
+		t.Fatalf("state = %q, want state-1", state)
+	}
+
+	claims, err := provider.Exchange(ctx, code, verifier, "nonce-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims.Subject != "alice" || claims.Email != "alice@example.com" || !claims.EmailVerified {
+		t.Fatalf("unexpected claims: %+v", claims)
+	}
+
+	// 授权码只能用一次
+	if _, err := provider.Exchange(ctx, code, verifier, "nonce-1"); err == nil {
+		t.Fatal("code reused successfully")
+	}
+}
+
+func TestExchangeRejectsWrongVerifier(t *testing.T) {
+	provider, _ := newMock(t)
+	ctx := context.Background()
+
+	authURL, err := provider.AuthCodeURL(ctx, "state", "nonce", oauth2.GenerateVerifier())
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, _ := authorize(t, authURL, "alice")
+
+	if _, err := provider.Exchange(ctx, code, oauth2.GenerateVerifier(), "nonce"); err == nil {
+		t.Fatal("exchange succeeded with another verifier")
+	}
+}
+
+func TestExchangeRejectsWrongNonce(t *testing.T) {
+	provider, _ := newMock(t)
+	ctx := context.Background()
+	verifier := oauth2.GenerateVerifier()
+
+	authURL, err := provider.AuthCodeURL(ctx, "state", "nonce", verifier)
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, _ := authorize(t, authURL, "alice")
+
+	if _, err := provider.Exchange(ctx, code, verifier, "other-nonce"); !errors.Is(err, oidc.ErrNonceMismatch) {
+		t.Fatalf("err = %v, want ErrNonceMismatch", err)
+	}
+}
@@ -0,0 +1,327 @@
+package openapi
+
+import (
+	"fmt"
+	"lesson10/internal/pkg/errcode"
+	"lesson10/internal/pkg/response"
+	"net/http"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// Auth 接口的登录要求，对应 router 里挂的鉴权中间件
+type Auth int
+
+const (
+	AuthNone Auth = iota
+	// AuthOptional 挂 OptionalAuthMiddleware，带不带 token 都能访问，带了返回的内容会不同
+	AuthOptional
+	AuthRequired
+	// AuthAdmin AuthMiddleware + AdminOnly
+	AuthAdmin
+)
+
+// Route 一个接口的描述。Path 用 gin 的写法（:id、*key），Params / Query / Body 传结构体的零值，
+// 按 uri / form / json 标签和 binding 规则生成参数和 schema；Response 是统一响应里 data 的类型，nil 表示没有 data。
+// Raw 不为空时接口不走统一响应包装，Raw 是响应的 Content-Type，Response 为 nil 时按二进制处理；
+// 这类接口出错时一般还是走统一的错误响应，探针、指标这种只返回状态码的设置 PlainErrors
+type Route struct {
+	Method      string
+	Path        string
+	ID          string // operationId，全局唯一
+	Tag         string
+	Summary     string
+	Description string
+	Auth        Auth
+	Params      any
+	Query       any
+	Body        any
+	// Upload multipart/form-data 里文件字段的名字
+	Upload   string
+	Response any
+	// Status 成功时的状态码，默认 200
+	Status      int
+	Raw         string
+	PlainErrors bool
+}
+
+type Document struct {
+	OpenAPI    string              `json:"openapi"`
+	Info       Info                `json:"info"`
+	Tags       []Tag               `json:"tags,omitempty"`
+	Paths      map[string]PathItem `json:"paths"`
+	Components Components          `json:"components"`
+}
+
+type Info struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+type Tag struct {
+	Name string `json:"name"`
+}
+
+// PathItem key 是小写的 HTTP 方法
+type PathItem map[string]*Operation
+
+type Operation struct {
+	Tags        []string              `json:"tags,omitempty"`
+	Summary     string                `json:"summary,omitempty"`
+	Description string                `json:"description,omitempty"`
+	OperationID string                `json:"operationId"`
+	Parameters  []Parameter           `json:"parameters,omitempty"`
+	RequestBody *RequestBody          `json:"requestBody,omitempty"`
+	Responses   map[string]*Response  `json:"responses"`
+	Security    []map[string][]string `json:"security,omitempty"`
+}
+
+type Parameter struct {
+	Name     string  `json:"name"`
+	In       string  `json:"in"`
+	Required bool    `json:"required,omitempty"`
+	Schema   *Schema `json:"schema"`
+}
+
+type RequestBody struct {
+	Required bool                 `json:"required"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+type Response struct {
+	Ref         string               `json:"$ref,omitempty"`
+	Description string               `json:"description,omitempty"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+type Components struct {
+	Schemas         map[string]*Schema        `json:"schemas"`
+	Responses       map[string]*Response      `json:"responses"`
+	SecuritySchemes map[string]SecurityScheme `json:"securitySchemes"`
+}
+
+type SecurityScheme struct {
+	Type         string `json:"type"`
+	Scheme       string `json:"scheme"`
+	BearerFormat string `json:"bearerFormat,omitempty"`
+}
+
+const (
+	bearerAuth = "bearerAuth"
+	errorRef   = "#/components/responses/Error"
+)
+
+// Build 生成文档。路由重复、operationId 重复、方法不认识这类写错路由表的问题直接 panic，
+// 和 errcode 定义错误码一样，启动或者跑测试时就能发现。
+// overrides 给实现了 json.Marshaler、反射看不出实际格式的类型指定 schema
+func Build(info Info, routes []Route, overrides map[reflect.Type]*Schema) *Document {
+	g := newGenerator(overrides)
+	doc := &Document{
+		OpenAPI: "3.0.3",
+		Info:    info,
+		Paths:   map[string]PathItem{},
+		Components: Components{
+			Schemas: g.schemas,
+			Responses: map[string]*Response{
+				"Error": {
+					Description: "失败时的统一响应，code 为业务码",
+					Content:     jsonContent(g.errorSchema()),
+				},
+			},
+			SecuritySchemes: map[string]SecurityScheme{
+				bearerAuth: {Type: "http", Scheme: "bearer", BearerFormat: "JWT"},
+			},
+		},
+	}
+
+	ids := map[string]bool{}
+	tags := map[string]bool{}
+	for _, r := range routes {
+		method := strings.ToLower(r.Method)
+		switch r.Method {
+		case http.MethodGet, http.MethodPost, http.MethodPut, http.MethodDelete:
+		default:
+			panic(fmt.Sprintf("openapi: unsupported method %q for %s", r.Method, r.Path))
+		}
+		if r.ID == "" || ids[r.ID] {
+			panic(fmt.Sprintf("openapi: missing or duplicate operation id %q for %s %s", r.ID, r.Method, r.Path))
+		}
+		ids[r.ID] = true
+
+		path := PathOf(r.Path)
+		item, ok := doc.Paths[path]
+		if !ok {
+			item = PathItem{}
+			doc.Paths[path] = item
+		}
+		if _, ok := item[method]; ok {
+			panic(fmt.Sprintf("openapi: duplicate route %s %s", r.Method, r.Path))
+		}
+		item[method] = g.operation(r)
+
+		if r.Tag != "" && !tags[r.Tag] {
+			tags[r.Tag] = true
+			doc.Tags = append(doc.Tags, Tag{Name: r.Tag})
+		}
+	}
+
+	return doc
+}
+
+// PathOf 把 gin 的路由写法换成 OpenAPI 的：/posts/:id -> /posts/{id}，/files/*key -> /files/{key}
+func PathOf(ginPath string) string {
+	parts := strings.Split(ginPath, "/")
+	for i, p := range parts {
+		if strings.HasPrefix(p, ":") || strings.HasPrefix(p, "*") {
+			parts[i] = "{" + p[1:] + "}"
+		}
+	}
+	return strings.Join(parts, "/")
+}
+
+func (g *generator) operation(r Route) *Operation {
+	op := &Operation{
+		Summary:     r.Summary,
+		Description: r.Description,
+		OperationID: r.ID,
+		Parameters:  g.pathParams(r.Path, r.Params),
+		Responses:   map[string]*Response{},
+	}
+	if r.Tag != "" {
+		op.Tags = []string{r.Tag}
+	}
+	if r.Query != nil {
+		op.Parameters = append(op.Parameters, g.params(reflect.TypeOf(r.Query), "query", "form")...)
+	}
+
+	switch {
+	case r.Body != nil:
+		op.RequestBody = &RequestBody{Required: true, Content: jsonContent(g.schemaOf(reflect.TypeOf(r.Body)))}
+	case r.Upload != "":
+		op.RequestBody = &RequestBody{Required: true, Content: map[string]MediaType{
+			"multipart/form-data": {Schema: &Schema{
+				Type:       "object",
+				Properties: map[string]*Schema{r.Upload: {Type: "string", Format: "binary"}},
+				Required:   []string{r.Upload},
+			}},
+		}}
+	}
+
+	switch r.Auth {
+	case AuthRequired, AuthAdmin:
+		op.Security = []map[string][]string{{bearerAuth: {}}}
+	case AuthOptional:
+		op.Security = []map[string][]string{{bearerAuth: {}}, {}}
+	}
+
+	status := r.Status
+	if status == 0 {
+		status = http.StatusOK
+	}
+	op.Responses[fmt.Sprint(status)] = g.success(r)
+
+	if !r.PlainErrors {
+		if len(op.Parameters) > 0 || op.RequestBody != nil {
+			op.Responses["400"] = &Response{Ref: errorRef}
+		}
+		if r.Auth == AuthRequired || r.Auth == AuthAdmin {
+			op.Responses["401"] = &Response{Ref: errorRef}
+		}
+		if r.Auth == AuthAdmin {
+			op.Responses["403"] = &Response{Ref: errorRef}
+		}
+		op.Responses["default"] = &Response{Ref: errorRef}
+	}
+
+	return op
+}
+
+func (g *generator) success(r Route) *Response {
+	if r.Raw != "" {
+		schema := &Schema{Type: "string", Format: "binary"}
+		if r.Response != nil {
+			schema = g.schemaOf(reflect.TypeOf(r.Response))
+		}
+		return &Response{Description: "OK", Content: map[string]MediaType{r.Raw: {Schema: schema}}}
+	}
+
+	envelope := &Schema{
+		Type: "object",
+		Properties: map[string]*Schema{
+			"code":    {Type: "integer", Enum: []any{0}},
+			"message": {Type: "string"},
+		},
+		Required: []string{"code", "message"},
+	}
+	if r.Response != nil {
+		envelope.Properties["data"] = g.schemaOf(reflect.TypeOf(r.Response))
+		envelope.Required = append(envelope.Required, "data")
+	}
+
+	return &Response{Description: "OK", Content: jsonContent(envelope)}
+}
+
+// errorSchema response.Resp 的结构，code 列出全部业务码
+func (g *generator) errorSchema() *Schema {
+	schema := g.object(reflect.TypeOf(response.Resp{}))
+
+	code := &Schema{Type: "integer"}
+	var lines []string
+	for _, e := range errcode.Catalog() {
+		code.Enum = append(code.Enum, e.Code)
+		lines = append(lines, fmt.Sprintf("- %d (HTTP %d): %s", e.Code, e.Status, e.Error()))
+	}
+	code.Description = "业务码：\n" + strings.Join(lines, "\n")
+	schema.Properties["code"] = code
+	schema.Properties["data"] = &Schema{Description: "个别错误附带的数据，如限流时的 policy / retry_after"}
+	schema.Required = []string{"code", "message"}
+
+	return schema
+}
+
+// pathParams 路径里的参数都是必填的；params 结构体里按 uri 标签找到的用对应类型，找不到的当作字符串
+func (g *generator) pathParams(ginPath string, params any) []Parameter {
+	typed := map[string]Parameter{}
+	if params != nil {
+		for _, p := range g.params(reflect.TypeOf(params), "path", "uri") {
+			typed[p.Name] = p
+		}
+	}
+
+	var list []Parameter
+	for _, part := range strings.Split(ginPath, "/") {
+		if !strings.HasPrefix(part, ":") && !strings.HasPrefix(part, "*") {
+			continue
+		}
+		name := part[1:]
+		p, ok := typed[name]
+		if !ok {
+			p = Parameter{Name: name, In: "path", Schema: &Schema{Type: "string"}}
+		}
+		p.Required = true
+		list = append(list, p)
+	}
+
+	return list
+}
+
+func (g *generator) params(t reflect.Type, in string, tagName string) []Parameter {
+	var list []Parameter
+	for _, f := range fields(t, tagName) {
+		schema := g.schemaOf(f.Type)
+		required := applyBinding(schema, f.StructField)
+		list = append(list, Parameter{Name: f.name, In: in, Required: required, Schema: schema})
+	}
+	sort.SliceStable(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+func jsonContent(schema *Schema) map[string]MediaType {
+	return map[string]MediaType{"application/json": {Schema: schema}}
+}
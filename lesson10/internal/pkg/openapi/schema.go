@@ -0,0 +1,255 @@
+package openapi
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type Schema struct {
+	Ref                  string             `json:"$ref,omitempty"`
+	Type                 string             `json:"type,omitempty"`
+	Format               string             `json:"format,omitempty"`
+	Description          string             `json:"description,omitempty"`
+	Nullable             bool               `json:"nullable,omitempty"`
+	Enum                 []any              `json:"enum,omitempty"`
+	Default              any                `json:"default,omitempty"`
+	Minimum              *float64           `json:"minimum,omitempty"`
+	Maximum              *float64           `json:"maximum,omitempty"`
+	MinLength            *int               `json:"minLength,omitempty"`
+	MaxLength            *int               `json:"maxLength,omitempty"`
+	Items                *Schema            `json:"items,omitempty"`
+	Properties           map[string]*Schema `json:"properties,omitempty"`
+	Required             []string           `json:"required,omitempty"`
+	AdditionalProperties *Schema            `json:"additionalProperties,omitempty"`
+	AllOf                []*Schema          `json:"allOf,omitempty"`
+}
+
+var (
+	timeType          = reflect.TypeFor[time.Time]()
+	jsonMarshalerType = reflect.TypeFor[json.Marshaler]()
+	textMarshalerType = reflect.TypeFor[encoding.TextMarshaler]()
+)
+
+// generator 有名字的结构体放进 components.schemas 用 $ref 引用，匿名结构体就地展开。
+// 字段名、omitempty、嵌入结构体的展开都按 encoding/json 的规则，和实际输出一致
+type generator struct {
+	schemas   map[string]*Schema
+	names     map[reflect.Type]string
+	overrides map[reflect.Type]*Schema
+}
+
+func newGenerator(overrides map[reflect.Type]*Schema) *generator {
+	return &generator{
+		schemas:   map[string]*Schema{},
+		names:     map[reflect.Type]string{},
+		overrides: overrides,
+	}
+}
+
+func (g *generator) schemaOf(t reflect.Type) *Schema {
+	if s, ok := g.overrides[t]; ok {
+		c := *s
+		return &c
+	}
+	if t == timeType {
+		return &Schema{Type: "string", Format: "date-time"}
+	}
+
+	if t.Kind() == reflect.Pointer {
+		s := g.schemaOf(t.Elem())
+		if s.Ref != "" {
+			return &Schema{AllOf: []*Schema{s}, Nullable: true}
+		}
+		s.Nullable = true
+		return s
+	}
+
+	// 自己实现了序列化的类型看不出格式，没在 overrides 里指定的只能不限制
+	if t.Implements(jsonMarshalerType) || reflect.PointerTo(t).Implements(jsonMarshalerType) {
+		return &Schema{}
+	}
+	if t.Implements(textMarshalerType) || reflect.PointerTo(t).Implements(textMarshalerType) {
+		return &Schema{Type: "string"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return &Schema{Type: "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32:
+		return &Schema{Type: "integer", Format: "int32"}
+	case reflect.Int64:
+		return &Schema{Type: "integer", Format: "int64"}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return &Schema{Type: "integer", Minimum: ptr(0.0)}
+	case reflect.Float32, reflect.Float64:
+		return &Schema{Type: "number"}
+	case reflect.String:
+		return &Schema{Type: "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return &Schema{Type: "string", Format: "byte"}
+		}
+		return &Schema{Type: "array", Items: g.schemaOf(t.Elem())}
+	case reflect.Map:
+		return &Schema{Type: "object", AdditionalProperties: g.schemaOf(t.Elem())}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return g.object(t)
+		}
+		return &Schema{Ref: "#/components/schemas/" + g.component(t)}
+	default:
+		return &Schema{}
+	}
+}
+
+// component 先占住名字再生成字段，结构体引用自己（比如 User.Posts[].Author）时不会死循环
+func (g *generator) component(t reflect.Type) string {
+	if name, ok := g.names[t]; ok {
+		return name
+	}
+
+	name := t.Name()
+	if _, taken := g.schemas[name]; taken {
+		panic(fmt.Sprintf("openapi: schema name %q used by both %s and another type", name, t.PkgPath()))
+	}
+	g.names[t] = name
+	g.schemas[name] = nil
+	g.schemas[name] = g.object(t)
+
+	return name
+}
+
+func (g *generator) object(t reflect.Type) *Schema {
+	s := &Schema{Type: "object", Properties: map[string]*Schema{}}
+	for _, f := range fields(t, "json") {
+		fs := g.schemaOf(f.Type)
+		if f.asString {
+			fs = &Schema{Type: "string"}
+		}
+		if applyBinding(fs, f.StructField) {
+			s.Required = append(s.Required, f.name)
+		}
+		s.Properties[f.name] = fs
+	}
+
+	return s
+}
+
+type field struct {
+	reflect.StructField
+	name     string
+	asString bool
+}
+
+// fields 按标签取字段名，没有名字的嵌入结构体展开成外层字段，标签为 "-" 的跳过
+func fields(t reflect.Type, tagName string) []field {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	var list []field
+	for i := range t.NumField() {
+		f := t.Field(i)
+		name, opts, _ := strings.Cut(f.Tag.Get(tagName), ",")
+		if name == "-" {
+			continue
+		}
+
+		if f.Anonymous && name == "" {
+			ft := f.Type
+			if ft.Kind() == reflect.Pointer {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				list = append(list, fields(ft, tagName)...)
+				continue
+			}
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+
+		list = append(list, field{StructField: f, name: name, asString: tagName == "json" && strings.Contains(opts, "string")})
+	}
+
+	return list
+}
+
+// applyBinding 把 binding 标签里的规则写进 schema，返回是否必填。
+// 引用类型的 schema 不能再加约束，只看 required
+func applyBinding(s *Schema, f reflect.StructField) bool {
+	if v, ok := f.Tag.Lookup("default"); ok && s.Ref == "" {
+		s.Default = parseValue(s.Type, v)
+	}
+
+	required := false
+	for _, rule := range strings.Split(f.Tag.Get("binding"), ",") {
+		name, param, _ := strings.Cut(rule, "=")
+		if name == "required" {
+			required = true
+		}
+		if s.Ref != "" || s.AllOf != nil {
+			continue
+		}
+
+		switch name {
+		case "email":
+			s.Format = "email"
+		case "oneof":
+			for _, v := range strings.Fields(param) {
+				s.Enum = append(s.Enum, parseValue(s.Type, v))
+			}
+		case "min", "max":
+			n, err := strconv.Atoi(param)
+			if err != nil {
+				continue
+			}
+			switch s.Type {
+			case "string":
+				if name == "min" {
+					s.MinLength = &n
+				} else {
+					s.MaxLength = &n
+				}
+			case "integer", "number":
+				if name == "min" {
+					s.Minimum = ptr(float64(n))
+				} else {
+					s.Maximum = ptr(float64(n))
+				}
+			}
+		}
+	}
+
+	return required
+}
+
+func parseValue(typ string, v string) any {
+	switch typ {
+	case "integer":
+		if n, err := strconv.ParseInt(v, 10, 64); err == nil {
+			return n
+		}
+	case "number":
+		if n, err := strconv.ParseFloat(v, 64); err == nil {
+			return n
+		}
+	case "boolean":
+		if b, err := strconv.ParseBool(v); err == nil {
+			return b
+		}
+	}
+	return v
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
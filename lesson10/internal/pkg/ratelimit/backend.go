@@ -0,0 +1,24 @@
+package ratelimit
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// New 根据 Backend 选择实现：memory（默认）或 redis。
+// 配了 redis 却没有客户端时直接报错：退回内存实现的话多实例部署下每个实例各算各的，实际额度翻了好几倍还没人发现
+func New(cfg Config, client *redis.Client) (Limiter, error) {
+	switch cfg.Backend {
+	case "", "memory":
+		return NewMemoryLimiter(), nil
+	case "redis":
+		if client == nil {
+			return nil, errors.New("rate limit backend is redis but no redis client is configured")
+		}
+		return NewRedisLimiter(client, "ratelimit:"), nil
+	default:
+		return nil, fmt.Errorf("unknown rate limit backend %q", cfg.Backend)
+	}
+}
@@ -0,0 +1,74 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+const memorySweepInterval = time.Minute
+
+// MemoryLimiter 进程内滑动窗口日志，只适合单实例部署
+type MemoryLimiter struct {
+	mu        sync.Mutex
+	hits      map[string][]time.Time
+	windows   map[string]time.Duration
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+func NewMemoryLimiter() *MemoryLimiter {
+	return &MemoryLimiter{
+		hits:    map[string][]time.Time{},
+		windows: map[string]time.Duration{},
+		now:     time.Now,
+	}
+}
+
+func (l *MemoryLimiter) Allow(ctx context.Context, key string, policy Policy) (Result, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.sweep(now)
+
+	hits := trim(l.hits[key], now.Add(-policy.Window))
+	result := Result{Limit: policy.Limit}
+
+	if len(hits) < policy.Limit {
+		hits = append(hits, now)
+		result.Allowed = true
+	}
+
+	result.Remaining = policy.Limit - len(hits)
+	if len(hits) > 0 {
+		result.Reset = hits[0].Add(policy.Window).Sub(now)
+	}
+
+	l.hits[key] = hits
+	l.windows[key] = policy.Window
+	return result, nil
+}
+
+// sweep 定期清掉窗口内已经没有请求的 key，避免 map 无限增长
+func (l *MemoryLimiter) sweep(now time.Time) {
+	if now.Sub(l.lastSweep) < memorySweepInterval {
+		return
+	}
+	l.lastSweep = now
+
+	for key, hits := range l.hits {
+		if len(trim(hits, now.Add(-l.windows[key]))) == 0 {
+			delete(l.hits, key)
+			delete(l.windows, key)
+		}
+	}
+}
+
+func trim(hits []time.Time, cutoff time.Time) []time.Time {
+	i := 0
+	for i < len(hits) && !hits[i].After(cutoff) {
+		i++
+	}
+	return hits[i:]
+}
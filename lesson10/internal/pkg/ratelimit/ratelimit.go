@@ -0,0 +1,112 @@
+// Package ratelimit 提供滑动窗口限流：单机用内存实现，多实例部署用 Redis 实现
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Policy 在任意 Window 长度的滑动窗口内最多允许 Limit 次请求
+type Policy struct {
+	Name   string
+	Limit  int
+	Window time.Duration
+}
+
+func (p Policy) String() string {
+	return fmt.Sprintf("%d;w=%d", p.Limit, int(p.Window.Seconds()))
+}
+
+type Result struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// Reset 窗口内最早一次请求滑出窗口还要多久，也就是至少恢复一个名额的时间
+	Reset time.Duration
+}
+
+type Limiter interface {
+	Allow(ctx context.Context, key string, policy Policy) (Result, error)
+}
+
+// Policies 登录、两步验证、第三方回调、邮件/密码找回各用一条策略，计数互不影响：
+// 输错密码不会占掉两步验证的次数，刷找回密码邮件也不会把正常登录挡住
+type Policies struct {
+	Default       Policy
+	Login         Policy
+	TwoFactor     Policy
+	OAuthCallback Policy
+	Email         Policy
+	Register      Policy
+	Post          Policy
+	Comment       Policy
+	Upload        Policy
+}
+
+// Config 每条策略写成 次数/窗口，例如 10/1m；Backend 为 memory 或 redis
+type Config struct {
+	Backend       string `yaml:"backend" env:"RATE_LIMIT_BACKEND"`
+	Default       string `yaml:"default" env:"RATE_LIMIT_DEFAULT"`
+	Login         string `yaml:"login" env:"RATE_LIMIT_LOGIN"`
+	TwoFactor     string `yaml:"two_factor" env:"RATE_LIMIT_TWO_FACTOR"`
+	OAuthCallback string `yaml:"oauth_callback" env:"RATE_LIMIT_OAUTH_CALLBACK"`
+	Email         string `yaml:"email" env:"RATE_LIMIT_EMAIL"`
+	Register      string `yaml:"register" env:"RATE_LIMIT_REGISTER"`
+	Post          string `yaml:"post" env:"RATE_LIMIT_POST"`
+	Comment       string `yaml:"comment" env:"RATE_LIMIT_COMMENT"`
+	Upload        string `yaml:"upload" env:"RATE_LIMIT_UPLOAD"`
+}
+
+// LoadPolicies 解析每条策略，写错了返回错误，不再悄悄退回默认值
+func LoadPolicies(cfg Config) (Policies, error) {
+	var (
+		policies Policies
+		errs     []error
+	)
+	for _, item := range []struct {
+		name   string
+		raw    string
+		policy *Policy
+	}{
+		{"default", cfg.Default, &policies.Default},
+		{"login", cfg.Login, &policies.Login},
+		{"two_factor", cfg.TwoFactor, &policies.TwoFactor},
+		{"oauth_callback", cfg.OAuthCallback, &policies.OAuthCallback},
+		{"email", cfg.Email, &policies.Email},
+		{"register", cfg.Register, &policies.Register},
+		{"post", cfg.Post, &policies.Post},
+		{"comment", cfg.Comment, &policies.Comment},
+		{"upload", cfg.Upload, &policies.Upload},
+	} {
+		policy, err := ParsePolicy(item.name, item.raw)
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		*item.policy = policy
+	}
+
+	return policies, errors.Join(errs...)
+}
+
+func ParsePolicy(name string, raw string) (Policy, error) {
+	parts := strings.SplitN(raw, "/", 2)
+	if len(parts) != 2 {
+		return Policy{}, fmt.Errorf("rate limit %s=%q: want <limit>/<window>", name, raw)
+	}
+
+	n, err := strconv.Atoi(strings.TrimSpace(parts[0]))
+	if err != nil || n <= 0 {
+		return Policy{}, fmt.Errorf("rate limit %s=%q: bad limit", name, raw)
+	}
+	d, err := time.ParseDuration(strings.TrimSpace(parts[1]))
+	if err != nil || d <= 0 {
+		return Policy{}, fmt.Errorf("rate limit %s=%q: bad window", name, raw)
+	}
+
+	return Policy{Name: name, Limit: n, Window: d}, nil
+}
@@ -0,0 +1,167 @@
+package ratelimit
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+type fakeClock struct {
+	now time.Time
+}
+
+func (c *fakeClock) Now() time.Time { return c.now }
+
+func (c *fakeClock) Advance(d time.Duration) { c.now = c.now.Add(d) }
+
+func newClock() *fakeClock {
+	return &fakeClock{now: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+}
+
+func newTestRedisLimiter(t *testing.T, clock *fakeClock) *RedisLimiter {
+	t.Helper()
+
+	mr := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { client.Close() })
+
+	l := NewRedisLimiter(client, "ratelimit:")
+	l.now = clock.Now
+	return l
+}
+
+func allow(t *testing.T, l Limiter, key string, policy Policy) Result {
+	t.Helper()
+
+	result, err := l.Allow(context.Background(), key, policy)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return result
+}
+
+// testSlidingWindow 两个实现共用：3 次/分钟，窗口跟着最早一次请求滑动，而不是按整分钟重置
+func testSlidingWindow(t *testing.T, l Limiter, clock *fakeClock) {
+	policy := Policy{Name: "test", Limit: 3, Window: time.Minute}
+
+	for i := 0; i < 3; i++ {
+		result := allow(t, l, "k", policy)
+		if !result.Allowed || result.Remaining != 2-i || result.Limit != 3 {
+			t.Fatalf("request %d = %+v", i, result)
+		}
+		clock.Advance(10 * time.Second)
+	}
+
+	// 第一次请求在 30 秒前，还要 30 秒才滑出窗口
+	result := allow(t, l, "k", policy)
+	if result.Allowed || result.Remaining != 0 || result.Reset != 30*time.Second {
+		t.Fatalf("over limit = %+v", result)
+	}
+
+	// 别的 key 不受影响
+	if result := allow(t, l, "other", policy); !result.Allowed {
+		t.Fatalf("other key = %+v", result)
+	}
+
+	// 第一次请求滑出后只空出一个名额
+	clock.Advance(31 * time.Second)
+	if result := allow(t, l, "k", policy); !result.Allowed || result.Remaining != 0 {
+		t.Fatalf("after first slid out = %+v", result)
+	}
+	if result := allow(t, l, "k", policy); result.Allowed {
+		t.Fatalf("second slot reopened early = %+v", result)
+	}
+
+	clock.Advance(time.Minute)
+	if result := allow(t, l, "k", policy); !result.Allowed || result.Remaining != 2 {
+		t.Fatalf("after full window = %+v", result)
+	}
+}
+
+func TestMemorySlidingWindow(t *testing.T) {
+	clock := newClock()
+	l := NewMemoryLimiter()
+	l.now = clock.Now
+
+	testSlidingWindow(t, l, clock)
+}
+
+func TestRedisSlidingWindow(t *testing.T) {
+	clock := newClock()
+	testSlidingWindow(t, newTestRedisLimiter(t, clock), clock)
+}
+
+// TestRedisLimitersShareCounts 多个实例连同一个 Redis，计数是共享的
+func TestRedisLimitersShareCounts(t *testing.T) {
+	clock := newClock()
+	mr := miniredis.RunT(t)
+	policy := Policy{Name: "test", Limit: 2, Window: time.Minute}
+
+	var limiters []*RedisLimiter
+	for i := 0; i < 2; i++ {
+		client := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+		t.Cleanup(func() { client.Close() })
+		l := NewRedisLimiter(client, "ratelimit:")
+		l.now = clock.Now
+		limiters = append(limiters, l)
+	}
+
+	allow(t, limiters[0], "k", policy)
+	allow(t, limiters[1], "k", policy)
+	if result := allow(t, limiters[0], "k", policy); result.Allowed {
+		t.Fatalf("third request across instances = %+v", result)
+	}
+	if ttl := mr.TTL("ratelimit:k"); ttl <= 0 || ttl > time.Minute {
+		t.Fatalf("key ttl = %v", ttl)
+	}
+}
+
+func TestMemorySweep(t *testing.T) {
+	clock := newClock()
+	l := NewMemoryLimiter()
+	l.now = clock.Now
+
+	allow(t, l, "short", Policy{Name: "short", Limit: 5, Window: 10 * time.Second})
+	allow(t, l, "long", Policy{Name: "long", Limit: 5, Window: time.Hour})
+
+	// 没到清理间隔时不扫
+	clock.Advance(30 * time.Second)
+	allow(t, l, "other", Policy{Name: "other", Limit: 5, Window: time.Second})
+	if len(l.hits) != 3 {
+		t.Fatalf("swept too early: %v", l.hits)
+	}
+
+	clock.Advance(memorySweepInterval)
+	allow(t, l, "long", Policy{Name: "long", Limit: 5, Window: time.Hour})
+	if _, ok := l.hits["short"]; ok || len(l.hits) != 1 || len(l.windows) != 1 {
+		t.Fatalf("after sweep hits = %v, windows = %v", l.hits, l.windows)
+	}
+}
+
+func TestNew(t *testing.T) {
+	if l, err := New(Config{Backend: "memory"}, nil); err != nil || l == nil {
+		t.Fatalf("memory = %v, %v", l, err)
+	}
+	if _, err := New(Config{Backend: "redis"}, nil); err == nil || !strings.Contains(err.Error(), "no redis client") {
+		t.Fatalf("redis without client err = %v", err)
+	}
+	if _, err := New(Config{Backend: "memcached"}, nil); err == nil {
+		t.Fatal("unknown backend accepted")
+	}
+}
+
+func TestParsePolicy(t *testing.T) {
+	policy, err := ParsePolicy("login", " 10 / 1m ")
+	if err != nil || policy != (Policy{Name: "login", Limit: 10, Window: time.Minute}) || policy.String() != "10;w=60" {
+		t.Fatalf("policy = %+v (%s), %v", policy, policy, err)
+	}
+	for _, raw := range []string{"", "10", "0/1m", "ten/1m", "10/0s", "10/soon"} {
+		if _, err := ParsePolicy("login", raw); err == nil {
+			t.Errorf("ParsePolicy(%q) succeeded", raw)
+		}
+	}
+}
@@ -0,0 +1,73 @@
+package ratelimit
+
+import (
+	"context"
+	"math/rand/v2"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// slidingWindowScript 用 ZSET 记录窗口内每次请求的时间（毫秒），所有实例共享同一个计数
+//
+//	KEYS[1] = key
+//	ARGV[1] = now(ms)  ARGV[2] = window(ms)  ARGV[3] = limit  ARGV[4] = member
+//
+// 返回 {allowed, count, oldest(ms)}
+var slidingWindowScript = redis.NewScript(`
+local key = KEYS[1]
+local now = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local limit = tonumber(ARGV[3])
+
+redis.call('ZREMRANGEBYSCORE', key, '-inf', now - window)
+local count = redis.call('ZCARD', key)
+local allowed = 0
+if count < limit then
+  redis.call('ZADD', key, now, ARGV[4])
+  count = count + 1
+  allowed = 1
+end
+redis.call('PEXPIRE', key, window)
+
+local oldest = now
+local first = redis.call('ZRANGE', key, 0, 0, 'WITHSCORES')
+if first[2] then
+  oldest = tonumber(first[2])
+end
+return {allowed, count, oldest}
+`)
+
+type RedisLimiter struct {
+	client redis.Scripter
+	prefix string
+	now    func() time.Time
+}
+
+func NewRedisLimiter(client redis.Scripter, prefix string) *RedisLimiter {
+	return &RedisLimiter{client: client, prefix: prefix, now: time.Now}
+}
+
+func (l *RedisLimiter) Allow(ctx context.Context, key string, policy Policy) (Result, error) {
+	now := l.now()
+	nowMs := now.UnixMilli()
+	// 同一毫秒内可能有多个请求，member 必须唯一
+	member := strconv.FormatInt(now.UnixNano(), 36) + "-" + strconv.FormatUint(uint64(rand.Uint32()), 36)
+
+	values, err := slidingWindowScript.Run(ctx, l.client,
+		[]string{l.prefix + key},
+		nowMs, policy.Window.Milliseconds(), policy.Limit, member,
+	).Int64Slice()
+	if err != nil {
+		return Result{}, err
+	}
+
+	count := int(values[1])
+	return Result{
+		Allowed:   values[0] == 1,
+		Limit:     policy.Limit,
+		Remaining: max(policy.Limit-count, 0),
+		Reset:     time.Duration(values[2]+policy.Window.Milliseconds()-nowMs) * time.Millisecond,
+	}, nil
+}
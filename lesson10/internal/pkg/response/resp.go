@@ -1,24 +1,36 @@
 package response
 
 import (
+	"lesson10/internal/pkg/errcode"
+	"lesson10/internal/pkg/i18n"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
 )
 
 // Resp 统一响应结构体
-// message: 提示信息
-// code: HTTP 状态码
+// code: 业务码，成功为 0，失败时见 errcode，客户端按它判断错误类型
+// message: 提示信息，失败时按 Accept-Language 返回中文或英文
+// details: 参数校验失败时每个字段的问题
+// request_id: 失败时带上，方便对照日志排查
 type Resp struct {
-	Code    int         `json:"code"`
-	Message string      `json:"message"`
-	Data    interface{} `json:"data,omitempty"`
+	Code      int           `json:"code"`
+	Message   string        `json:"message"`
+	Data      interface{}   `json:"data,omitempty"`
+	Details   []FieldDetail `json:"details,omitempty"`
+	RequestID string        `json:"request_id,omitempty"`
+}
+
+// FieldDetail 单个字段的校验错误，message 已经翻译好
+type FieldDetail struct {
+	errcode.FieldError
+	Message string `json:"message"`
 }
 
 // JSON 统一响应输出
-func JSON(c *gin.Context, code int, message string, data interface{}) {
-	c.JSON(code, Resp{
-		Code:    code,
+func JSON(c *gin.Context, status int, message string, data interface{}) {
+	c.JSON(status, Resp{
+		Code:    0,
 		Message: message,
 		Data:    data,
 	})
@@ -29,7 +41,24 @@ func OK(c *gin.Context, data interface{}) {
 	JSON(c, http.StatusOK, "success", data)
 }
 
-// Error 快捷返回错误
-func Error(c *gin.Context, code int, message string) {
-	JSON(c, code, message, nil)
+// Fail 按错误码输出失败响应，一般不直接调用，handler 用 c.Error 交给 middleware.Errors
+func Fail(c *gin.Context, err *errcode.Error, lang i18n.Lang) {
+	resp := Resp{
+		Code:      err.Code,
+		Message:   err.Message(lang),
+		Data:      err.Data,
+		RequestID: c.GetString("request_id"),
+	}
+	for _, d := range err.Details {
+		key := "validation." + d.Rule
+		if !i18n.Has(lang, key) {
+			key = "validation.invalid"
+		}
+		resp.Details = append(resp.Details, FieldDetail{
+			FieldError: d,
+			Message:    d.Field + " " + i18n.Message(lang, key, d.Param),
+		})
+	}
+
+	c.JSON(err.Status, resp)
 }
@@ -0,0 +1,419 @@
+package search
+
+import (
+	"context"
+	"encoding/gob"
+	"errors"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+// snapshotVersion 分词或存储格式改动时加一，旧快照直接丢弃、由上层重建
+const snapshotVersion = 1
+
+const (
+	titleBoost = 2.0
+	bm25K1     = 1.2
+	bm25B      = 0.75
+
+	// 查询词的扩展：前缀匹配（边输边搜）和编辑距离内的近似词（拼写错误），权重低于原词
+	prefixWeight = 0.8
+	fuzzyWeight  = 0.6
+	maxExpansion = 20
+)
+
+type docKey struct {
+	Type string
+	ID   uint
+}
+
+type posting struct {
+	title int
+	body  int
+}
+
+type storedDoc struct {
+	Doc
+	length float64
+	terms  []string
+}
+
+// Engine 内存里的倒排索引，BM25 打分、标题加权；Flush 时把文档写成 gob 快照，Open 时读回来重新建索引
+type Engine struct {
+	path string
+
+	mu       sync.RWMutex
+	docs     map[docKey]*storedDoc
+	postings map[string]map[docKey]posting
+	latin    []string // postings 里的英文/数字词，排好序，查询扩展时按前缀二分查找
+	totalLen float64
+	dirty    bool
+}
+
+type snapshot struct {
+	Version int
+	Docs    []Doc
+}
+
+// Open path 为空时只在内存里；快照不存在或版本不对时返回空索引
+func Open(path string) (*Engine, error) {
+	e := &Engine{
+		path:     path,
+		docs:     map[docKey]*storedDoc{},
+		postings: map[string]map[docKey]posting{},
+	}
+	if path == "" {
+		return e, nil
+	}
+
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return e, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var snap snapshot
+	if err := gob.NewDecoder(f).Decode(&snap); err != nil || snap.Version != snapshotVersion {
+		return e, nil
+	}
+	for _, doc := range snap.Docs {
+		e.latin = append(e.latin, e.add(doc)...)
+	}
+	slices.Sort(e.latin)
+
+	return e, nil
+}
+
+func (e *Engine) Len() int {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	return len(e.docs)
+}
+
+// Index 同一 Type+ID 重复写入时覆盖
+func (e *Engine) Index(_ context.Context, doc Doc) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.forgetTerms(e.remove(docKey{doc.Type, doc.ID}))
+	for _, term := range e.add(doc) {
+		if i, found := slices.BinarySearch(e.latin, term); !found {
+			e.latin = slices.Insert(e.latin, i, term)
+		}
+	}
+	e.dirty = true
+	return nil
+}
+
+func (e *Engine) Delete(_ context.Context, docType string, id uint) error {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if _, ok := e.docs[docKey{docType, id}]; ok {
+		e.forgetTerms(e.remove(docKey{docType, id}))
+		e.dirty = true
+	}
+	return nil
+}
+
+// forgetTerms 从 latin 里去掉已经没有文档的词
+func (e *Engine) forgetTerms(terms []string) {
+	for _, term := range terms {
+		if i, found := slices.BinarySearch(e.latin, term); found {
+			e.latin = slices.Delete(e.latin, i, i+1)
+		}
+	}
+}
+
+// Flush 有改动时写快照：先写临时文件再 rename
+func (e *Engine) Flush() error {
+	if e.path == "" {
+		return nil
+	}
+
+	e.mu.Lock()
+	if !e.dirty {
+		e.mu.Unlock()
+		return nil
+	}
+	snap := snapshot{Version: snapshotVersion, Docs: make([]Doc, 0, len(e.docs))}
+	for _, doc := range e.docs {
+		snap.Docs = append(snap.Docs, doc.Doc)
+	}
+	e.dirty = false
+	e.mu.Unlock()
+
+	if err := e.writeSnapshot(snap); err != nil {
+		e.mu.Lock()
+		e.dirty = true
+		e.mu.Unlock()
+		return err
+	}
+
+	return nil
+}
+
+func (e *Engine) writeSnapshot(snap snapshot) error {
+	dir := filepath.Dir(e.path)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(dir, ".search-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := gob.NewEncoder(tmp).Encode(snap); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), e.path)
+}
+
+func (e *Engine) Search(_ context.Context, q Query) (*Result, error) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+
+	result := NewResult()
+	if len(e.docs) == 0 {
+		return result, nil
+	}
+
+	weights := e.expand(queryTerms(q.Text))
+	avgLen := e.totalLen / float64(len(e.docs))
+	n := float64(len(e.docs))
+
+	scores := map[docKey]float64{}
+	for term, weight := range weights {
+		docs := e.postings[term]
+		df := float64(len(docs))
+		idf := math.Log(1 + (n-df+0.5)/(df+0.5))
+
+		for key, p := range docs {
+			tf := titleBoost*float64(p.title) + float64(p.body)
+			norm := bm25K1 * (1 - bm25B + bm25B*e.docs[key].length/avgLen)
+			scores[key] += weight * idf * tf * (bm25K1 + 1) / (tf + norm)
+		}
+	}
+
+	wanted := map[string]bool{}
+	for _, t := range q.Types {
+		wanted[t] = true
+	}
+
+	matched := make([]*storedDoc, 0, len(scores))
+	for key := range scores {
+		result.Facets[key.Type]++
+		if len(wanted) == 0 || wanted[key.Type] {
+			matched = append(matched, e.docs[key])
+		}
+	}
+	result.Total = int64(len(matched))
+
+	sort.Slice(matched, func(i, j int) bool {
+		a, b := matched[i], matched[j]
+		sa, sb := scores[docKey{a.Type, a.ID}], scores[docKey{b.Type, b.ID}]
+		if q.Sort == SortNewest || sa == sb {
+			if !a.CreatedAt.Equal(b.CreatedAt) {
+				return a.CreatedAt.After(b.CreatedAt)
+			}
+			return a.ID > b.ID
+		}
+		return sa > sb
+	})
+
+	if q.Offset >= len(matched) {
+		return result, nil
+	}
+	matched = matched[q.Offset:min(len(matched), q.Offset+q.Limit)]
+
+	highlightTerms := map[string]bool{}
+	for term := range weights {
+		highlightTerms[term] = true
+	}
+	for _, doc := range matched {
+		result.Hits = append(result.Hits, Hit{
+			Type:       doc.Type,
+			ID:         doc.ID,
+			Score:      math.Round(scores[docKey{doc.Type, doc.ID}]*1000) / 1000,
+			Title:      highlight(doc.Title, highlightTerms, 0),
+			Snippet:    highlight(doc.Body, highlightTerms, DefaultSnippetRunes),
+			AuthorID:   doc.AuthorID,
+			TargetType: doc.TargetType,
+			TargetID:   doc.TargetID,
+			CreatedAt:  doc.CreatedAt,
+		})
+	}
+
+	return result, nil
+}
+
+// expand 原词权重 1；英文词再加上以它开头的词和编辑距离内的近似词（4~7 个字母允许错 1 个，8 个以上允许错 2 个）。
+// 两种都在排好序的 latin 里二分找范围：前缀词是以查询词开头的一段，近似词只在首字母相同的一段里找，
+// 不用扫整个词表；首字母打错的词找不回来
+func (e *Engine) expand(terms []string) map[string]float64 {
+	weights := map[string]float64{}
+	set := func(term string, weight float64) {
+		if weight > weights[term] {
+			weights[term] = weight
+		}
+	}
+
+	for _, term := range terms {
+		set(term, 1)
+		if !isLatin(term) {
+			continue
+		}
+
+		length := utf8.RuneCountInString(term)
+		maxEdits := 0
+		switch {
+		case length >= 8:
+			maxEdits = 2
+		case length >= 4:
+			maxEdits = 1
+		}
+		if length < 3 {
+			continue
+		}
+
+		type candidate struct {
+			term   string
+			weight float64
+			df     int
+		}
+		target := []rune(term)
+		candidates := make([]candidate, 0)
+		for _, other := range e.termsWithPrefix(term) {
+			if other != term {
+				candidates = append(candidates, candidate{other, prefixWeight, len(e.postings[other])})
+			}
+		}
+		if maxEdits > 0 {
+			for _, other := range e.termsWithPrefix(string(target[0])) {
+				if strings.HasPrefix(other, term) || abs(utf8.RuneCountInString(other)-length) > maxEdits {
+					continue
+				}
+				if editDistance(target, []rune(other), maxEdits) <= maxEdits {
+					candidates = append(candidates, candidate{other, fuzzyWeight, len(e.postings[other])})
+				}
+			}
+		}
+
+		// 候选太多时只留权重高、出现得多的，结果不受 map 遍历顺序影响
+		sort.Slice(candidates, func(i, j int) bool {
+			a, b := candidates[i], candidates[j]
+			if a.weight != b.weight {
+				return a.weight > b.weight
+			}
+			if a.df != b.df {
+				return a.df > b.df
+			}
+			return a.term < b.term
+		})
+		for _, c := range candidates[:min(len(candidates), maxExpansion)] {
+			set(c.term, c.weight)
+		}
+	}
+
+	return weights
+}
+
+// termsWithPrefix latin 里以 prefix 开头的一段
+func (e *Engine) termsWithPrefix(prefix string) []string {
+	start, _ := slices.BinarySearch(e.latin, prefix)
+	end := start
+	for end < len(e.latin) && strings.HasPrefix(e.latin[end], prefix) {
+		end++
+	}
+	return e.latin[start:end]
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// add 返回第一次出现的英文/数字词，由调用方放进 latin
+func (e *Engine) add(doc Doc) []string {
+	key := docKey{doc.Type, doc.ID}
+	counts := map[string]posting{}
+
+	titleTokens := tokenize(doc.Title, false)
+	for _, t := range titleTokens {
+		p := counts[t.term]
+		p.title++
+		counts[t.term] = p
+	}
+	bodyTokens := tokenize(doc.Body, false)
+	for _, t := range bodyTokens {
+		p := counts[t.term]
+		p.body++
+		counts[t.term] = p
+	}
+
+	stored := &storedDoc{
+		Doc:    doc,
+		length: titleBoost*float64(len(titleTokens)) + float64(len(bodyTokens)),
+		terms:  make([]string, 0, len(counts)),
+	}
+	var newTerms []string
+	for term, p := range counts {
+		docs := e.postings[term]
+		if docs == nil {
+			docs = map[docKey]posting{}
+			e.postings[term] = docs
+			if isLatin(term) {
+				newTerms = append(newTerms, term)
+			}
+		}
+		docs[key] = p
+		stored.terms = append(stored.terms, term)
+	}
+
+	e.docs[key] = stored
+	e.totalLen += stored.length
+
+	return newTerms
+}
+
+// remove 返回因为这篇文档删掉而不再出现的英文/数字词
+func (e *Engine) remove(key docKey) []string {
+	doc, ok := e.docs[key]
+	if !ok {
+		return nil
+	}
+
+	var goneTerms []string
+	for _, term := range doc.terms {
+		docs := e.postings[term]
+		delete(docs, key)
+		if len(docs) == 0 {
+			delete(e.postings, term)
+			if isLatin(term) {
+				goneTerms = append(goneTerms, term)
+			}
+		}
+	}
+	delete(e.docs, key)
+	e.totalLen -= doc.length
+
+	return goneTerms
+}
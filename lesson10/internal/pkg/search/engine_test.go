@@ -0,0 +1,228 @@
+package search
+
+import (
+	"context"
+	"math"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testTime = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func newTestEngine(t *testing.T, docs ...Doc) *Engine {
+	t.Helper()
+
+	e, err := Open("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, doc := range docs {
+		if err := e.Index(context.Background(), doc); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return e
+}
+
+func post(id uint, title string, body string) Doc {
+	return Doc{Type: TypePost, ID: id, Title: title, Body: body, CreatedAt: testTime.Add(time.Duration(id) * time.Hour)}
+}
+
+func search(t *testing.T, e *Engine, q Query) *Result {
+	t.Helper()
+
+	if q.Limit == 0 {
+		q.Limit = 20
+	}
+	result, err := e.Search(context.Background(), q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return result
+}
+
+func hitIDs(result *Result) []uint {
+	ids := make([]uint, 0, len(result.Hits))
+	for _, hit := range result.Hits {
+		ids = append(ids, hit.ID)
+	}
+	return ids
+}
+
+// TestBM25Score 按公式手算一遍：idf = ln(1 + (N-df+0.5)/(df+0.5))，tf 里标题计两次
+func TestBM25Score(t *testing.T) {
+	e := newTestEngine(t,
+		post(1, "golang", "golang tips"),
+		post(2, "", "rust tips and tricks"),
+		post(3, "", "python"),
+	)
+
+	result := search(t, e, Query{Text: "golang"})
+	if len(result.Hits) != 1 {
+		t.Fatalf("hits = %+v", result.Hits)
+	}
+
+	n, df := 3.0, 1.0
+	idf := math.Log(1 + (n-df+0.5)/(df+0.5))
+	tf := titleBoost*1 + 1
+	avgLen := (titleBoost*1 + 2 + 4 + 1) / n
+	norm := bm25K1 * (1 - bm25B + bm25B*(titleBoost*1+2)/avgLen)
+	want := math.Round(idf*tf*(bm25K1+1)/(tf+norm)*1000) / 1000
+	if got := result.Hits[0].Score; got != want {
+		t.Fatalf("score = %v, want %v", got, want)
+	}
+}
+
+func TestBM25Ranking(t *testing.T) {
+	e := newTestEngine(t,
+		post(1, "", "search engine notes"),
+		post(2, "search", "notes"),
+		post(3, "", "search search engine notes"),
+		post(4, "", "search"+strings.Repeat(" filler", 40)),
+		post(5, "", "unrelated"),
+	)
+
+	// 2 和 3 词频相同（标题里的算两次），2 更短；1 只出现一次，4 同样一次但长得多
+	if got := hitIDs(search(t, e, Query{Text: "search"})); !reflect.DeepEqual(got, []uint{2, 3, 1, 4}) {
+		t.Fatalf("relevance order = %v", got)
+	}
+	if got := hitIDs(search(t, e, Query{Text: "search", Sort: SortNewest})); !reflect.DeepEqual(got, []uint{4, 3, 2, 1}) {
+		t.Fatalf("newest order = %v", got)
+	}
+
+	// 两个词都命中的排在只命中一个的前面
+	if got := hitIDs(search(t, e, Query{Text: "search engine"})); got[0] != 3 || got[1] != 1 {
+		t.Fatalf("multi term order = %v", got)
+	}
+}
+
+func TestSearchFacetsAndPaging(t *testing.T) {
+	e := newTestEngine(t,
+		post(1, "", "golang"),
+		post(2, "", "golang"),
+		post(3, "", "golang"),
+		Doc{Type: TypeComment, ID: 1, Body: "golang", CreatedAt: testTime},
+		Doc{Type: TypeUser, ID: 1, Title: "gopher", Body: "golang", CreatedAt: testTime},
+	)
+
+	result := search(t, e, Query{Text: "golang", Types: []string{TypePost}, Sort: SortNewest, Offset: 1, Limit: 1})
+	if result.Total != 3 || !reflect.DeepEqual(hitIDs(result), []uint{2}) {
+		t.Fatalf("total = %d, hits = %v", result.Total, hitIDs(result))
+	}
+	want := map[string]int64{TypePost: 3, TypeComment: 1, TypeUser: 1}
+	if !reflect.DeepEqual(result.Facets, want) {
+		t.Fatalf("facets = %v, want %v", result.Facets, want)
+	}
+
+	if result := search(t, e, Query{Text: "golang", Offset: 10}); len(result.Hits) != 0 || result.Total != 5 {
+		t.Fatalf("past the end: total = %d, hits = %v", result.Total, hitIDs(result))
+	}
+}
+
+func TestSearchChinese(t *testing.T) {
+	e := newTestEngine(t,
+		post(1, "全文搜索", "倒排索引的实现"),
+		post(2, "", "搜狗输入法"),
+	)
+
+	if got := hitIDs(search(t, e, Query{Text: "搜索"})); !reflect.DeepEqual(got, []uint{1}) {
+		t.Fatalf("bigram query = %v", got)
+	}
+	// 单字查询命中索引时收的单字
+	if got := hitIDs(search(t, e, Query{Text: "搜"})); len(got) != 2 {
+		t.Fatalf("single rune query = %v", got)
+	}
+	result := search(t, e, Query{Text: "索引"})
+	if result.Hits[0].Snippet != "倒排<mark>索引</mark>的实现" {
+		t.Fatalf("snippet = %q", result.Hits[0].Snippet)
+	}
+}
+
+func TestExpandPrefixAndFuzzy(t *testing.T) {
+	e := newTestEngine(t,
+		post(1, "", "kubernetes"),
+		post(2, "", "kubectl"),
+		post(3, "", "elasticsearch"),
+		post(4, "", "search"),
+		post(5, "", "serach"),
+	)
+
+	weights := e.expand([]string{"kube"})
+	if weights["kube"] != 1 || weights["kubernetes"] != prefixWeight || weights["kubectl"] != prefixWeight {
+		t.Fatalf("prefix weights = %v", weights)
+	}
+
+	// 6 个字母允许错 1 个；serach 要错 2 个，首字母不同的 elasticsearch 也不算
+	weights = e.expand([]string{"saerch"})
+	if _, ok := weights["serach"]; ok {
+		t.Fatalf("fuzzy weights = %v", weights)
+	}
+	weights = e.expand([]string{"searh"})
+	if weights["search"] != fuzzyWeight || weights["serach"] != 0 || weights["elasticsearch"] != 0 {
+		t.Fatalf("fuzzy weights = %v", weights)
+	}
+
+	// 两个字母的词不扩展
+	if weights := e.expand([]string{"ku"}); len(weights) != 1 {
+		t.Fatalf("short term weights = %v", weights)
+	}
+
+	if got := hitIDs(search(t, e, Query{Text: "kube"})); len(got) != 2 {
+		t.Fatalf("prefix hits = %v", got)
+	}
+}
+
+func TestIndexKeepsTermListSorted(t *testing.T) {
+	e := newTestEngine(t, post(1, "", "delta alpha"), post(2, "", "charlie alpha"))
+	if !reflect.DeepEqual(e.latin, []string{"alpha", "charlie", "delta"}) {
+		t.Fatalf("terms = %q", e.latin)
+	}
+
+	// 覆盖写入时旧文档独有的词要去掉
+	if err := e.Index(context.Background(), post(1, "", "bravo")); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(e.latin, []string{"alpha", "bravo", "charlie"}) {
+		t.Fatalf("terms after reindex = %q", e.latin)
+	}
+
+	if err := e.Delete(context.Background(), TypePost, 2); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(e.latin, []string{"bravo"}) || len(e.postings) != 1 {
+		t.Fatalf("terms after delete = %q, postings = %v", e.latin, e.postings)
+	}
+	if got := hitIDs(search(t, e, Query{Text: "alpha"})); len(got) != 0 {
+		t.Fatalf("deleted doc still found: %v", got)
+	}
+}
+
+func TestFlushAndOpen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "search.gob")
+	e, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, doc := range []Doc{post(1, "golang", "tips"), post(2, "", "zebra kubernetes")} {
+		if err := e.Index(context.Background(), doc); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := e.Flush(); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened, err := Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reopened.Len() != 2 || !reflect.DeepEqual(reopened.latin, []string{"golang", "kubernetes", "tips", "zebra"}) {
+		t.Fatalf("len = %d, terms = %q", reopened.Len(), reopened.latin)
+	}
+	if got := hitIDs(search(t, reopened, Query{Text: "kube"})); !reflect.DeepEqual(got, []uint{2}) {
+		t.Fatalf("hits after reopen = %v", got)
+	}
+}
@@ -0,0 +1,75 @@
+package search
+
+import (
+	"html"
+	"sort"
+	"strings"
+)
+
+const DefaultSnippetRunes = 160
+
+// Highlight 给 MySQL 之类自己不做高亮的实现用：按查询分词后在 text 里标出命中的词
+func Highlight(text string, query string, maxRunes int) string {
+	terms := map[string]bool{}
+	for _, term := range queryTerms(query) {
+		terms[term] = true
+	}
+
+	return highlight(text, terms, maxRunes)
+}
+
+// highlight 先转义再把命中的词包进 <mark>；maxRunes > 0 且文本更长时只截取第一个命中附近的一段
+func highlight(text string, terms map[string]bool, maxRunes int) string {
+	runes := []rune(text)
+
+	type span struct{ start, end int }
+	spans := make([]span, 0)
+	for _, t := range tokenize(text, false) {
+		if terms[t.term] {
+			spans = append(spans, span{t.start, t.end})
+		}
+	}
+	sort.Slice(spans, func(i, j int) bool { return spans[i].start < spans[j].start })
+
+	// 中文二元词会互相重叠，合并成连续的一段
+	merged := make([]span, 0, len(spans))
+	for _, s := range spans {
+		if n := len(merged); n > 0 && s.start <= merged[n-1].end {
+			merged[n-1].end = max(merged[n-1].end, s.end)
+			continue
+		}
+		merged = append(merged, s)
+	}
+
+	from, to := 0, len(runes)
+	if maxRunes > 0 && len(runes) > maxRunes {
+		if len(merged) > 0 {
+			from = max(0, merged[0].start-maxRunes/4)
+		}
+		to = min(len(runes), from+maxRunes)
+		from = max(0, to-maxRunes)
+	}
+
+	var b strings.Builder
+	if from > 0 {
+		b.WriteString("…")
+	}
+	pos := from
+	for _, s := range merged {
+		if s.end <= from || s.start >= to {
+			continue
+		}
+		start, end := max(s.start, from), min(s.end, to)
+		b.WriteString(html.EscapeString(string(runes[pos:start])))
+		b.WriteString("<mark>")
+		b.WriteString(html.EscapeString(string(runes[start:end])))
+		b.WriteString("</mark>")
+		pos = end
+	}
+	b.WriteString(html.EscapeString(string(runes[pos:to])))
+	if to < len(runes) {
+		b.WriteString("…")
+	}
+
+	return b.String()
+}
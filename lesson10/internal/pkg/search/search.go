@@ -0,0 +1,80 @@
+// Package search 全文搜索用到的公共类型、分词和高亮，以及一个内嵌的倒排索引实现（Engine）
+package search
+
+import "time"
+
+const (
+	TypePost    = "post"
+	TypeComment = "comment"
+	TypeUser    = "user"
+)
+
+var Types = []string{TypePost, TypeComment, TypeUser}
+
+const (
+	SortRelevance = "relevance"
+	SortNewest    = "newest"
+)
+
+// Doc 一条可搜索的记录；帖子 Title/Body 是标题和正文纯文本，评论只有 Body，用户 Title 是用户名、Body 是简介。
+// 评论用 TargetType/TargetID 指向它所在的帖子或父评论
+type Doc struct {
+	Type       string
+	ID         uint
+	Title      string
+	Body       string
+	AuthorID   uint
+	TargetType uint8
+	TargetID   uint
+	CreatedAt  time.Time
+}
+
+type Query struct {
+	Text   string
+	Types  []string
+	Sort   string
+	Offset int
+	Limit  int
+}
+
+// Hit Title/Snippet 已经做过 HTML 转义，命中的词用 <mark> 包起来；AuthorName 由上层补
+type Hit struct {
+	Type       string    `json:"type"`
+	ID         uint      `json:"id"`
+	Score      float64   `json:"score"`
+	Title      string    `json:"title,omitempty"`
+	Snippet    string    `json:"snippet,omitempty"`
+	AuthorID   uint      `json:"author_id,omitempty"`
+	AuthorName string    `json:"author_name,omitempty"`
+	TargetType uint8     `json:"target_type,omitempty"`
+	TargetID   uint      `json:"target_id,omitempty"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// Result Facets 是不按类型过滤时各类型的命中数，Total 是过滤后的命中数
+type Result struct {
+	Hits   []Hit
+	Total  int64
+	Facets map[string]int64
+}
+
+// NewResult 空结果，Facets 里每种类型都有，没命中的是 0
+func NewResult() *Result {
+	result := &Result{Hits: []Hit{}, Facets: map[string]int64{}}
+	for _, t := range Types {
+		result.Facets[t] = 0
+	}
+
+	return result
+}
+
+// ValidType 只认 Types 里的几种
+func ValidType(docType string) bool {
+	for _, t := range Types {
+		if t == docType {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,120 @@
+package search
+
+import (
+	"strings"
+	"unicode"
+)
+
+const maxTokenRunes = 64
+
+type token struct {
+	term  string
+	start int // rune 下标，含
+	end   int // rune 下标，不含
+}
+
+// tokenize 英文/数字按连续字母切词并转小写；中文和 MySQL ngram 一样按二元切分，索引时额外收单字，
+// 这样单字查询也能命中。forQuery 时两个字以上的中文只用二元，避免单字把结果冲得太散
+func tokenize(text string, forQuery bool) []token {
+	runes := []rune(text)
+	tokens := make([]token, 0, len(runes)/2)
+
+	for i := 0; i < len(runes); {
+		r := runes[i]
+		switch {
+		case isCJK(r):
+			j := i
+			for j < len(runes) && isCJK(runes[j]) {
+				j++
+			}
+			tokens = appendCJK(tokens, runes, i, j, forQuery)
+			i = j
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			j := i
+			for j < len(runes) && !isCJK(runes[j]) && (unicode.IsLetter(runes[j]) || unicode.IsDigit(runes[j])) {
+				j++
+			}
+			if j-i <= maxTokenRunes {
+				tokens = append(tokens, token{term: strings.ToLower(string(runes[i:j])), start: i, end: j})
+			}
+			i = j
+		default:
+			i++
+		}
+	}
+
+	return tokens
+}
+
+func appendCJK(tokens []token, runes []rune, start, end int, forQuery bool) []token {
+	if end-start == 1 || !forQuery {
+		for k := start; k < end; k++ {
+			tokens = append(tokens, token{term: string(runes[k]), start: k, end: k + 1})
+		}
+	}
+	for k := start; k+1 < end; k++ {
+		tokens = append(tokens, token{term: string(runes[k : k+2]), start: k, end: k + 2})
+	}
+
+	return tokens
+}
+
+func isCJK(r rune) bool {
+	return unicode.Is(unicode.Han, r) || unicode.Is(unicode.Hiragana, r) || unicode.Is(unicode.Katakana, r) || unicode.Is(unicode.Hangul, r)
+}
+
+// queryTerms 查询里去重后的词
+func queryTerms(text string) []string {
+	seen := map[string]bool{}
+	terms := make([]string, 0)
+	for _, t := range tokenize(text, true) {
+		if !seen[t.term] {
+			seen[t.term] = true
+			terms = append(terms, t.term)
+		}
+	}
+
+	return terms
+}
+
+func isLatin(term string) bool {
+	for _, r := range term {
+		if isCJK(r) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// editDistance 不超过 max 时返回真实距离，否则返回 max+1；只在同长度量级的词之间算，成本可控
+func editDistance(a, b []rune, max int) int {
+	if d := len(a) - len(b); d > max || -d > max {
+		return max + 1
+	}
+
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		rowMin := cur[0]
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+			rowMin = min(rowMin, cur[j])
+		}
+		if rowMin > max {
+			return max + 1
+		}
+		prev, cur = cur, prev
+	}
+
+	return prev[len(b)]
+}
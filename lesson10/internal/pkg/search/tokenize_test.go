@@ -0,0 +1,82 @@
+package search
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func terms(tokens []token) []string {
+	out := make([]string, 0, len(tokens))
+	for _, t := range tokens {
+		out = append(out, t.term)
+	}
+	return out
+}
+
+func TestTokenize(t *testing.T) {
+	cases := []struct {
+		name     string
+		text     string
+		forQuery bool
+		want     []string
+	}{
+		{"latin lowercased", "Hello, GoLang 1.22!", false, []string{"hello", "golang", "1", "22"}},
+		{"cjk unigrams and bigrams", "搜索引擎", false, []string{"搜", "索", "引", "擎", "搜索", "索引", "引擎"}},
+		{"cjk query bigrams only", "搜索引擎", true, []string{"搜索", "索引", "引擎"}},
+		{"single cjk query", "搜", true, []string{"搜"}},
+		{"mixed", "Go语言 tips", true, []string{"go", "语言", "tips"}},
+		{"kana and hangul", "カタ 한글", true, []string{"カタ", "한글"}},
+		{"punctuation only", "!!! ，。", false, []string{}},
+		{"overlong token dropped", strings.Repeat("a", maxTokenRunes+1) + " ok", false, []string{"ok"}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := terms(tokenize(tc.text, tc.forQuery)); !reflect.DeepEqual(got, tc.want) {
+				t.Fatalf("tokenize(%q) = %q, want %q", tc.text, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestTokenizePositions(t *testing.T) {
+	tokens := tokenize("ab 中文", false)
+	want := []token{{"ab", 0, 2}, {"中", 3, 4}, {"文", 4, 5}, {"中文", 3, 5}}
+	if !reflect.DeepEqual(tokens, want) {
+		t.Fatalf("tokens = %+v, want %+v", tokens, want)
+	}
+}
+
+func TestQueryTermsDedup(t *testing.T) {
+	if got := queryTerms("Go go GO 中文中文"); !reflect.DeepEqual(got, []string{"go", "中文", "文中"}) {
+		t.Fatalf("queryTerms = %q", got)
+	}
+}
+
+func TestEditDistance(t *testing.T) {
+	cases := []struct {
+		a, b string
+		max  int
+		want int
+	}{
+		{"search", "search", 1, 0},
+		{"search", "serach", 2, 2},
+		{"search", "seach", 1, 1},
+		{"search", "searches", 1, 2},
+		{"kitten", "sitting", 3, 3},
+		{"kitten", "sitting", 1, 2},
+	}
+	for _, tc := range cases {
+		if got := editDistance([]rune(tc.a), []rune(tc.b), tc.max); got != tc.want {
+			t.Errorf("editDistance(%q, %q, %d) = %d, want %d", tc.a, tc.b, tc.max, got, tc.want)
+		}
+	}
+}
+
+func TestHighlight(t *testing.T) {
+	got := Highlight("<b>Go</b> 语言搜索", "go 搜索", 0)
+	want := "&lt;b&gt;<mark>Go</mark>&lt;/b&gt; 语言<mark>搜索</mark>"
+	if got != want {
+		t.Fatalf("highlight = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,181 @@
+// Package fakes3 是本地开发和联调用的 S3 兼容服务，不要部署到线上：
+// 只支持 path-style 的单对象 PUT/GET/HEAD/DELETE，数据放在内存里，重启即丢
+package fakes3
+
+import (
+	"encoding/xml"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"lesson10/internal/pkg/storage/sigv4"
+)
+
+type Config struct {
+	Region    string
+	AccessKey string
+	SecretKey string
+	Buckets   []string
+	// PublicRead 为 true 时匿名 GET/HEAD 也放行，相当于给 bucket 配了公开读策略
+	PublicRead bool
+}
+
+type object struct {
+	data         []byte
+	contentType  string
+	lastModified time.Time
+}
+
+type Server struct {
+	cfg Config
+
+	mu      sync.RWMutex
+	buckets map[string]map[string]object
+}
+
+func New(cfg Config) *Server {
+	if cfg.Region == "" {
+		cfg.Region = "us-east-1"
+	}
+
+	buckets := make(map[string]map[string]object, len(cfg.Buckets))
+	for _, name := range cfg.Buckets {
+		buckets[name] = map[string]object{}
+	}
+
+	return &Server{cfg: cfg, buckets: buckets}
+}
+
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("PUT /{bucket}/{key...}", s.put)
+	mux.HandleFunc("GET /{bucket}/{key...}", s.get)
+	mux.HandleFunc("HEAD /{bucket}/{key...}", s.get)
+	mux.HandleFunc("DELETE /{bucket}/{key...}", s.delete)
+	return mux
+}
+
+func (s *Server) put(w http.ResponseWriter, r *http.Request) {
+	if !s.authorize(w, r) {
+		return
+	}
+
+	body, err := readBody(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "IncompleteBody", err.Error())
+		return
+	}
+
+	// Authorization 头签名时要核对 body 哈希，否则签名只覆盖了头
+	if hash := r.Header.Get("X-Amz-Content-Sha256"); hash != "" && hash != sigv4.UnsignedPayload && hash != sigv4.PayloadHash(body) {
+		writeError(w, http.StatusBadRequest, "XAmzContentSHA256Mismatch", "payload hash does not match")
+		return
+	}
+
+	bucket, key := r.PathValue("bucket"), r.PathValue("key")
+	s.mu.Lock()
+	objects, ok := s.buckets[bucket]
+	if ok {
+		objects[key] = object{data: body, contentType: r.Header.Get("Content-Type"), lastModified: time.Now().UTC()}
+	}
+	s.mu.Unlock()
+	if !ok {
+		writeError(w, http.StatusNotFound, "NoSuchBucket", "bucket does not exist")
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func (s *Server) get(w http.ResponseWriter, r *http.Request) {
+	if !(s.cfg.PublicRead && r.Header.Get("Authorization") == "" && r.URL.Query().Get("X-Amz-Signature") == "") && !s.authorize(w, r) {
+		return
+	}
+
+	bucket, key := r.PathValue("bucket"), r.PathValue("key")
+	s.mu.RLock()
+	objects, ok := s.buckets[bucket]
+	obj, found := objects[key]
+	s.mu.RUnlock()
+	if !ok {
+		writeError(w, http.StatusNotFound, "NoSuchBucket", "bucket does not exist")
+		return
+	}
+	if !found {
+		writeError(w, http.StatusNotFound, "NoSuchKey", "object does not exist")
+		return
+	}
+
+	if obj.contentType != "" {
+		w.Header().Set("Content-Type", obj.contentType)
+	}
+	w.Header().Set("Content-Length", strconv.Itoa(len(obj.data)))
+	w.Header().Set("Last-Modified", obj.lastModified.Format(http.TimeFormat))
+	w.WriteHeader(http.StatusOK)
+	if r.Method != http.MethodHead {
+		_, _ = w.Write(obj.data)
+	}
+}
+
+func (s *Server) delete(w http.ResponseWriter, r *http.Request) {
+	if !s.authorize(w, r) {
+		return
+	}
+
+	bucket, key := r.PathValue("bucket"), r.PathValue("key")
+	s.mu.Lock()
+	objects, ok := s.buckets[bucket]
+	if ok {
+		delete(objects, key)
+	}
+	s.mu.Unlock()
+	if !ok {
+		writeError(w, http.StatusNotFound, "NoSuchBucket", "bucket does not exist")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) authorize(w http.ResponseWriter, r *http.Request) bool {
+	lookup := func(accessKey string) (string, bool) {
+		return s.cfg.SecretKey, accessKey == s.cfg.AccessKey
+	}
+
+	err := sigv4.Verify(r, lookup, s.cfg.Region, time.Now())
+	switch {
+	case err == nil:
+		return true
+	case errors.Is(err, sigv4.ErrUnknownAccessKey):
+		writeError(w, http.StatusForbidden, "InvalidAccessKeyId", err.Error())
+	case errors.Is(err, sigv4.ErrSignatureMismatch):
+		writeError(w, http.StatusForbidden, "SignatureDoesNotMatch", err.Error())
+	default:
+		writeError(w, http.StatusForbidden, "AccessDenied", err.Error())
+	}
+
+	return false
+}
+
+const maxObjectSize = 64 << 20
+
+func readBody(r *http.Request) ([]byte, error) {
+	defer r.Body.Close()
+	return io.ReadAll(http.MaxBytesReader(nil, r.Body, maxObjectSize))
+}
+
+type errorBody struct {
+	XMLName xml.Name `xml:"Error"`
+	Code    string   `xml:"Code"`
+	Message string   `xml:"Message"`
+}
+
+func writeError(w http.ResponseWriter, status int, code string, message string) {
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	_, _ = w.Write([]byte(xml.Header))
+	_ = xml.NewEncoder(w).Encode(errorBody{Code: code, Message: message})
+}
@@ -0,0 +1,133 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// LocalConfig URLPrefix 是 static 目录对外的公开前缀，Root 下的文件都经它公开访问
+type LocalConfig struct {
+	Root      string `yaml:"root" env:"STORAGE_LOCAL_ROOT"`
+	URLPrefix string `yaml:"url_prefix" env:"STORAGE_LOCAL_URL_PREFIX"`
+}
+
+type Local struct {
+	cfg LocalConfig
+}
+
+func NewLocal(cfg LocalConfig) (*Local, error) {
+	if cfg.Root == "" {
+		return nil, errors.New("storage: local root is empty")
+	}
+	cfg.URLPrefix = strings.TrimRight(cfg.URLPrefix, "/")
+
+	return &Local{cfg: cfg}, nil
+}
+
+func (s *Local) Root() string {
+	return s.cfg.Root
+}
+
+// Put 先写临时文件再 rename，同一内容并发上传时不会读到写了一半的文件
+func (s *Local) Put(_ context.Context, key string, data []byte, _ string) error {
+	name, err := s.path(key)
+	if err != nil {
+		return err
+	}
+
+	dir := filepath.Dir(name)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(dir, ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0o644); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), name)
+}
+
+func (s *Local) Get(_ context.Context, key string) (io.ReadCloser, error) {
+	name, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.Open(name)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+
+	return f, err
+}
+
+func (s *Local) Exists(_ context.Context, key string) (bool, error) {
+	name, err := s.path(key)
+	if err != nil {
+		return false, err
+	}
+
+	info, err := os.Stat(name)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return info.Mode().IsRegular(), nil
+}
+
+func (s *Local) Delete(_ context.Context, key string) error {
+	name, err := s.path(key)
+	if err != nil {
+		return err
+	}
+
+	if err := os.Remove(name); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	return nil
+}
+
+func (s *Local) URL(key string) string {
+	return s.cfg.URLPrefix + "/" + escapeKey(key)
+}
+
+// SignedURL 本地文件本来就经 /static 公开访问，签名防不住什么，直接返回公开地址
+func (s *Local) SignedURL(_ context.Context, key string, _ time.Duration) (string, error) {
+	key, err := cleanKey(key)
+	if err != nil {
+		return "", err
+	}
+
+	return s.URL(key), nil
+}
+
+func (s *Local) path(key string) (string, error) {
+	key, err := cleanKey(key)
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(s.cfg.Root, filepath.FromSlash(key)), nil
+}
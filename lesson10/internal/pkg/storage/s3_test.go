@@ -0,0 +1,141 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+	"lesson10/internal/pkg/storage/fakes3"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestS3(t *testing.T, publicRead bool) *S3 {
+	t.Helper()
+
+	srv := httptest.NewServer(fakes3.New(fakes3.Config{
+		AccessKey:  "test-access",
+		SecretKey:  "test-secret",
+		Buckets:    []string{"uploads"},
+		PublicRead: publicRead,
+	}).Handler())
+	t.Cleanup(srv.Close)
+
+	store, err := NewS3(S3Config{Endpoint: srv.URL, Bucket: "uploads", AccessKey: "test-access", SecretKey: "test-secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return store
+}
+
+func fetch(t *testing.T, rawURL string) (int, string) {
+	t.Helper()
+
+	resp, err := http.Get(rawURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp.StatusCode, string(body)
+}
+
+func TestS3RoundTrip(t *testing.T) {
+	store := newTestS3(t, false)
+	ctx := context.Background()
+	key := "images/ab/abcdef/medium.jpg"
+
+	if ok, err := store.Exists(ctx, key); err != nil || ok {
+		t.Fatalf("exists before put = %v, %v", ok, err)
+	}
+	if _, err := store.Get(ctx, key); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("get before put: err = %v, want ErrNotFound", err)
+	}
+
+	if err := store.Put(ctx, key, []byte("jpeg bytes"), "image/jpeg"); err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := store.Exists(ctx, key); err != nil || !ok {
+		t.Fatalf("exists after put = %v, %v", ok, err)
+	}
+
+	body, err := store.Get(ctx, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := io.ReadAll(body)
+	body.Close()
+	if string(data) != "jpeg bytes" {
+		t.Fatalf("get = %q", data)
+	}
+
+	if err := store.Delete(ctx, key); err != nil {
+		t.Fatal(err)
+	}
+	if ok, err := store.Exists(ctx, key); err != nil || ok {
+		t.Fatalf("exists after delete = %v, %v", ok, err)
+	}
+	// 删除不存在的对象不报错
+	if err := store.Delete(ctx, key); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestS3SignedURL(t *testing.T) {
+	store := newTestS3(t, false)
+	ctx := context.Background()
+	key := "images/ab/abcdef/original.png"
+
+	if err := store.Put(ctx, key, []byte("png bytes"), "image/png"); err != nil {
+		t.Fatal(err)
+	}
+
+	// bucket 不公开读时匿名访问公开地址被拒绝，只能用预签名地址
+	if status, _ := fetch(t, store.URL(key)); status != http.StatusForbidden {
+		t.Fatalf("anonymous get: status = %d, want 403", status)
+	}
+
+	signed, err := store.SignedURL(ctx, key, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status, body := fetch(t, signed); status != http.StatusOK || body != "png bytes" {
+		t.Fatalf("signed get: status = %d, body = %q", status, body)
+	}
+
+	tampered := strings.Replace(signed, "original.png", "medium.png", 1)
+	if status, _ := fetch(t, tampered); status != http.StatusForbidden {
+		t.Fatalf("tampered get: status = %d, want 403", status)
+	}
+
+	if _, err := store.SignedURL(ctx, key, 8*24*time.Hour); err == nil {
+		t.Fatal("signed url longer than 7 days was accepted")
+	}
+}
+
+func TestS3RejectsWrongCredentials(t *testing.T) {
+	store := newTestS3(t, true)
+	store.cred.SecretKey = "wrong"
+
+	if err := store.Put(context.Background(), "images/x.jpg", []byte("x"), "image/jpeg"); err == nil || !strings.Contains(err.Error(), "SignatureDoesNotMatch") {
+		t.Fatalf("err = %v, want SignatureDoesNotMatch", err)
+	}
+}
+
+func TestS3PublicRead(t *testing.T) {
+	store := newTestS3(t, true)
+	key := "avatars/ab/abcdef/thumb.jpg"
+
+	if err := store.Put(context.Background(), key, []byte("avatar"), "image/jpeg"); err != nil {
+		t.Fatal(err)
+	}
+	if status, body := fetch(t, store.URL(key)); status != http.StatusOK || body != "avatar" {
+		t.Fatalf("public get: status = %d, body = %q", status, body)
+	}
+}
@@ -0,0 +1,71 @@
+// Package storage 上传文件的存放后端：本地磁盘或 S3 兼容的对象存储
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+)
+
+var (
+	ErrNotFound   = errors.New("storage: object not found")
+	ErrInvalidKey = errors.New("storage: invalid object key")
+)
+
+// Storage key 用 / 分隔，例如 images/ab/<hash>/medium.jpg；URL 返回公开地址，
+// SignedURL 返回带过期时间的临时地址，后端本身就公开可读（本地磁盘）时和 URL 一样
+type Storage interface {
+	Put(ctx context.Context, key string, data []byte, contentType string) error
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	Exists(ctx context.Context, key string) (bool, error)
+	Delete(ctx context.Context, key string) error
+	URL(key string) string
+	SignedURL(ctx context.Context, key string, ttl time.Duration) (string, error)
+}
+
+// Config Driver 选 local 或 s3，只用到对应的那一组配置
+type Config struct {
+	Driver string      `yaml:"driver" env:"STORAGE_DRIVER"`
+	Local  LocalConfig `yaml:"local"`
+	S3     S3Config    `yaml:"s3"`
+}
+
+func New(cfg Config) (Storage, error) {
+	switch driver := strings.ToLower(cfg.Driver); driver {
+	case "local":
+		return NewLocal(cfg.Local)
+	case "s3":
+		return NewS3(cfg.S3)
+	default:
+		return nil, fmt.Errorf("unknown storage driver %q", driver)
+	}
+}
+
+// cleanKey 拒绝绝对路径和 ..，避免本地存储写到根目录外面
+func cleanKey(key string) (string, error) {
+	if key == "" || strings.HasPrefix(key, "/") || strings.Contains(key, "\\") {
+		return "", ErrInvalidKey
+	}
+
+	cleaned := path.Clean(key)
+	if cleaned != key || cleaned == "." || strings.HasPrefix(cleaned, "../") || cleaned == ".." {
+		return "", ErrInvalidKey
+	}
+
+	return cleaned, nil
+}
+
+// escapeKey 按段转义，/ 保留
+func escapeKey(key string) string {
+	parts := strings.Split(key, "/")
+	for i, part := range parts {
+		parts[i] = url.PathEscape(part)
+	}
+
+	return strings.Join(parts, "/")
+}
@@ -1,11 +1,10 @@
 package token
 
 import (
+	"jwtkeys"
 	"lesson10/internal/model"
 	"lesson10/internal/pkg/utils"
-	"os"
 	"strconv"
-	"strings"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
@@ -20,46 +19,65 @@ type AccessClaims struct {
 	jwt.RegisteredClaims
 }
 
-func jwtSecret() []byte {
-	return []byte(strings.TrimSpace(os.Getenv("JWT_SECRET")))
+// Config 签名密钥和两种 token 的有效期，由 config 包从配置文件和环境变量读出
+type Config struct {
+	SigningKeyFile string        `yaml:"signing_key_file" env:"JWT_SIGNING_KEY_FILE"`
+	VerifyKeyFiles []string      `yaml:"verify_key_files" env:"JWT_VERIFY_KEY_FILES"`
+	AccessTTL      time.Duration `yaml:"access_ttl" env:"JWT_EXPIRE_HOURS" unit:"h"`
+	RefreshTTL     time.Duration `yaml:"refresh_ttl" env:"REFRESH_TOKEN_EXPIRE_HOURS" unit:"h"`
 }
 
-func AccessTTL() time.Duration {
-	raw := strings.TrimSpace(os.Getenv("JWT_EXPIRE_HOURS"))
-	if raw == "" {
-		return time.Hour
-	}
+// ErrNoSigningKey 没有配置签名私钥，服务应拒绝启动
+var ErrNoSigningKey = jwtkeys.ErrNoSigningKey
 
-	hours, err := strconv.Atoi(raw)
-	if err != nil || hours <= 0 {
-		return time.Hour
-	}
+// JWKS 对外发布的验签公钥
+type JWKS = jwtkeys.JWKS
 
-	return time.Duration(hours) * time.Hour
+// Manager 持有签名密钥和两种 token 的有效期，启动时用 New 创建一次，注入给需要签发或校验 token 的组件
+type Manager struct {
+	keys       *jwtkeys.KeySet
+	accessTTL  time.Duration
+	refreshTTL time.Duration
 }
 
-func RefreshTTL() time.Duration {
-	raw := strings.TrimSpace(os.Getenv("REFRESH_TOKEN_EXPIRE_HOURS"))
-	if raw == "" {
-		return 7 * 24 * time.Hour
+// New 加载签名密钥和只用于校验的旧密钥，未配置签名密钥时返回错误，服务应拒绝启动
+func New(cfg Config) (*Manager, error) {
+	set, err := jwtkeys.LoadKeySet(cfg.SigningKeyFile, cfg.VerifyKeyFiles)
+	if err != nil {
+		return nil, err
 	}
 
-	hours, err := strconv.Atoi(raw)
-	if err != nil || hours <= 0 {
-		return 7 * 24 * time.Hour
+	m := &Manager{keys: set, accessTTL: time.Hour, refreshTTL: 7 * 24 * time.Hour}
+	if cfg.AccessTTL > 0 {
+		m.accessTTL = cfg.AccessTTL
 	}
+	if cfg.RefreshTTL > 0 {
+		m.refreshTTL = cfg.RefreshTTL
+	}
+	return m, nil
+}
 
-	return time.Duration(hours) * time.Hour
+// PublicKeys 返回对外发布的 JWKS
+func (m *Manager) PublicKeys() JWKS {
+	return m.keys.JWKS()
 }
 
-func GenerateToken(username string, userID uint, role model.Role, sessionID string) (string, string, time.Time, error) {
+func (m *Manager) AccessTTL() time.Duration {
+	return m.accessTTL
+}
+
+func (m *Manager) RefreshTTL() time.Duration {
+	return m.refreshTTL
+}
+
+func (m *Manager) GenerateToken(username string, userID uint, role model.Role, sessionID string) (string, string, time.Time, error) {
 	tokenID, err := utils.NewToken(16)
 	if err != nil {
 		return "", "", time.Time{}, err
 	}
 
 	now := time.Now()
-	expiresAt := now.Add(AccessTTL())
+	expiresAt := now.Add(m.accessTTL)
 	claims := AccessClaims{
 		UserID:    userID,
 		Username:  username,
@@ -74,8 +92,7 @@ func GenerateToken(username string, userID uint, role model.Role, sessionID stri
 		},
 	}
 
-	signedToken := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenValue, err := signedToken.SignedString(jwtSecret())
+	tokenValue, err := m.keys.Sign(claims)
 	if err != nil {
 		return "", "", time.Time{}, err
 	}
@@ -87,18 +104,11 @@ func GenerateRefreshToken() (string, error) {
 	return utils.NewToken(32)
 }
 
-func ValidateToken(rawToken string) (*AccessClaims, error) {
-	tokenValue, err := jwt.ParseWithClaims(rawToken, &AccessClaims{}, func(token *jwt.Token) (interface{}, error) {
-		return jwtSecret(), nil
-	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}))
-	if err != nil {
+func (m *Manager) ValidateToken(rawToken string) (*AccessClaims, error) {
+	claims := &AccessClaims{}
+	if err := m.keys.Parse(rawToken, claims); err != nil {
 		return nil, err
 	}
 
-	claims, ok := tokenValue.Claims.(*AccessClaims)
-	if !ok || !tokenValue.Valid {
-		return nil, jwt.ErrTokenInvalidClaims
-	}
-
 	return claims, nil
 }
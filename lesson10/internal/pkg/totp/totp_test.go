@@ -0,0 +1,78 @@
+package totp
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// rfc6238Secret RFC 6238 附录 B 的 SHA1 密钥 "12345678901234567890"
+const rfc6238Secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+// TestCodeRFC6238 附录 B 的 SHA1 测试向量，原文是 8 位，6 位取后六位
+func TestCodeRFC6238(t *testing.T) {
+	cases := []struct {
+		unix int64
+		want string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+	for _, tc := range cases {
+		got, err := Code(rfc6238Secret, Step(time.Unix(tc.unix, 0)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tc.want {
+			t.Errorf("T=%d: code = %s, want %s", tc.unix, got, tc.want)
+		}
+	}
+
+	// 小写和首尾空白的密钥也能用
+	if got, _ := Code(" "+strings.ToLower(rfc6238Secret)+" ", 1); got != "287082" {
+		t.Fatalf("lower case secret = %s", got)
+	}
+	if _, err := Code("not base32!", 1); err == nil {
+		t.Fatal("bad secret accepted")
+	}
+}
+
+func TestValidateSkew(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	current := Step(now)
+
+	for offset := int64(-2); offset <= 2; offset++ {
+		code, err := Code(rfc6238Secret, current+offset)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		step, ok := Validate(rfc6238Secret, " "+code+" ", now, 1)
+		inWindow := offset >= -1 && offset <= 1
+		if ok != inWindow {
+			t.Errorf("offset %d: ok = %v, want %v", offset, ok, inWindow)
+		}
+		if ok && step != current+offset {
+			t.Errorf("offset %d: step = %d, want %d", offset, step, current+offset)
+		}
+	}
+
+	if _, ok := Validate(rfc6238Secret, "50471", now, 1); ok {
+		t.Fatal("short code accepted")
+	}
+	if _, ok := Validate(rfc6238Secret, "0050471", now, 1); ok {
+		t.Fatal("long code accepted")
+	}
+}
+
+func TestURI(t *testing.T) {
+	got := URI("lesson10", "alice bob", "ABC")
+	want := "otpauth://totp/lesson10:alice%20bob?algorithm=SHA1&digits=6&issuer=lesson10&period=30&secret=ABC"
+	if got != want {
+		t.Fatalf("URI = %s", got)
+	}
+}
@@ -0,0 +1,16 @@
+package utils
+
+import (
+	"os"
+	"strings"
+)
+
+// EnvString 只给 cmd 下的 mock-oidc、fake-s3 这类开发工具用，服务本身的配置统一走 internal/config
+func EnvString(key, fallback string) string {
+	raw := strings.TrimSpace(os.Getenv(key))
+	if raw == "" {
+		return fallback
+	}
+
+	return raw
+}
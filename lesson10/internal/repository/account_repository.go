@@ -0,0 +1,298 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"lesson10/internal/model"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type AccountRepository interface {
+	WithTx(tx *gorm.DB) AccountRepository
+
+	CreateExport(ctx context.Context, export *model.DataExport) error
+	UpdateExport(ctx context.Context, export *model.DataExport) error
+	GetExportByTokenHash(ctx context.Context, tokenHash string) (*model.DataExport, error)
+	ListExportsByUserID(ctx context.Context, userID int64, limit int) ([]model.DataExport, error)
+	ListExportsByStatus(ctx context.Context, status string, limit int) ([]model.DataExport, error)
+	ListExpiredExports(ctx context.Context, now time.Time, limit int) ([]model.DataExport, error)
+	ListStaleExports(ctx context.Context, before time.Time, limit int) ([]model.DataExport, error)
+	TransitionExport(ctx context.Context, id int64, from, to string) (bool, error)
+
+	CreateDeletion(ctx context.Context, deletion *model.AccountDeletion) error
+	UpdateDeletion(ctx context.Context, deletion *model.AccountDeletion) error
+	GetPendingDeletionByUserID(ctx context.Context, userID int64) (*model.AccountDeletion, error)
+	GetDeletionForUpdate(ctx context.Context, id int64) (*model.AccountDeletion, error)
+	ListDueDeletions(ctx context.Context, now time.Time, limit int) ([]model.AccountDeletion, error)
+
+	ListAllPostsByAuthor(ctx context.Context, userID uint) ([]model.Post, error)
+	ListAllCommentsByAuthor(ctx context.Context, userID uint) ([]model.Comment, error)
+	ListAllFavoritesByUser(ctx context.Context, userID uint) ([]model.Favorite, error)
+	ListAllFollowsByUser(ctx context.Context, userID uint) ([]model.UserFollow, error)
+	ListAllNotificationsByUser(ctx context.Context, userID uint) ([]model.Notification, error)
+
+	AnonymizeUser(ctx context.Context, userID uint) error
+	DeleteDraftsByAuthor(ctx context.Context, userID uint) error
+	DeletePostsByAuthor(ctx context.Context, userID uint) ([]uint, error)
+	DeleteCommentsByAuthor(ctx context.Context, userID uint) ([]uint, error)
+	DeleteRelationsByUser(ctx context.Context, userID uint) error
+}
+
+type accountRepo struct {
+	db *gorm.DB
+}
+
+func NewAccountRepo(db *gorm.DB) AccountRepository {
+	return &accountRepo{db: db}
+}
+
+func (r *accountRepo) WithTx(tx *gorm.DB) AccountRepository {
+	return &accountRepo{db: tx}
+}
+
+func (r *accountRepo) CreateExport(ctx context.Context, export *model.DataExport) error {
+	return r.db.WithContext(ctx).Create(export).Error
+}
+
+func (r *accountRepo) UpdateExport(ctx context.Context, export *model.DataExport) error {
+	return r.db.WithContext(ctx).Save(export).Error
+}
+
+func (r *accountRepo) GetExportByTokenHash(ctx context.Context, tokenHash string) (*model.DataExport, error) {
+	var export model.DataExport
+	if err := r.db.WithContext(ctx).Where("token_hash = ?", tokenHash).First(&export).Error; err != nil {
+		return nil, err
+	}
+
+	return &export, nil
+}
+
+func (r *accountRepo) ListExportsByUserID(ctx context.Context, userID int64, limit int) ([]model.DataExport, error) {
+	var exports []model.DataExport
+	err := r.db.WithContext(ctx).
+		Where("user_id = ?", userID).
+		Order("created_at DESC").
+		Limit(limit).
+		Find(&exports).Error
+	return exports, err
+}
+
+func (r *accountRepo) ListExportsByStatus(ctx context.Context, status string, limit int) ([]model.DataExport, error) {
+	var exports []model.DataExport
+	err := r.db.WithContext(ctx).
+		Where("status = ?", status).
+		Order("id ASC").
+		Limit(limit).
+		Find(&exports).Error
+	return exports, err
+}
+
+func (r *accountRepo) ListExpiredExports(ctx context.Context, now time.Time, limit int) ([]model.DataExport, error) {
+	var exports []model.DataExport
+	err := r.db.WithContext(ctx).
+		Where("status = ? AND expires_at < ?", "ready", now).
+		Order("id ASC").
+		Limit(limit).
+		Find(&exports).Error
+	return exports, err
+}
+
+// ListStaleExports 处理中但超过 before 没有更新的任务，一般是处理它的实例中途退出了
+func (r *accountRepo) ListStaleExports(ctx context.Context, before time.Time, limit int) ([]model.DataExport, error) {
+	var exports []model.DataExport
+	err := r.db.WithContext(ctx).
+		Where("status = ? AND updated_at < ?", "processing", before).
+		Order("id ASC").
+		Limit(limit).
+		Find(&exports).Error
+	return exports, err
+}
+
+// TransitionExport 状态还是 from 时才改成 to，多个实例同时处理时只有一个会返回 true
+func (r *accountRepo) TransitionExport(ctx context.Context, id int64, from, to string) (bool, error) {
+	result := r.db.WithContext(ctx).
+		Model(&model.DataExport{}).
+		Where("id = ? AND status = ?", id, from).
+		Update("status", to)
+	return result.RowsAffected == 1, result.Error
+}
+
+func (r *accountRepo) CreateDeletion(ctx context.Context, deletion *model.AccountDeletion) error {
+	return r.db.WithContext(ctx).Create(deletion).Error
+}
+
+func (r *accountRepo) UpdateDeletion(ctx context.Context, deletion *model.AccountDeletion) error {
+	return r.db.WithContext(ctx).Save(deletion).Error
+}
+
+func (r *accountRepo) GetPendingDeletionByUserID(ctx context.Context, userID int64) (*model.AccountDeletion, error) {
+	var deletion model.AccountDeletion
+	if err := r.db.WithContext(ctx).
+		Where("user_id = ? AND status = ?", userID, "pending").
+		Order("id DESC").
+		First(&deletion).Error; err != nil {
+		return nil, err
+	}
+
+	return &deletion, nil
+}
+
+func (r *accountRepo) GetDeletionForUpdate(ctx context.Context, id int64) (*model.AccountDeletion, error) {
+	var deletion model.AccountDeletion
+	if err := r.db.WithContext(ctx).
+		Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("id = ?", id).
+		First(&deletion).Error; err != nil {
+		return nil, err
+	}
+
+	return &deletion, nil
+}
+
+func (r *accountRepo) ListDueDeletions(ctx context.Context, now time.Time, limit int) ([]model.AccountDeletion, error) {
+	var deletions []model.AccountDeletion
+	err := r.db.WithContext(ctx).
+		Where("status = ? AND scheduled_at <= ?", "pending", now).
+		Order("scheduled_at ASC").
+		Limit(limit).
+		Find(&deletions).Error
+	return deletions, err
+}
+
+func (r *accountRepo) ListAllPostsByAuthor(ctx context.Context, userID uint) ([]model.Post, error) {
+	var posts []model.Post
+	err := r.db.WithContext(ctx).
+		Where("author_id = ? AND is_deleted = 0", userID).
+		Order("created_at ASC").
+		Find(&posts).Error
+	return posts, err
+}
+
+func (r *accountRepo) ListAllCommentsByAuthor(ctx context.Context, userID uint) ([]model.Comment, error) {
+	var comments []model.Comment
+	err := r.db.WithContext(ctx).
+		Where("author_id = ? AND is_deleted = 0", userID).
+		Order("created_at ASC").
+		Find(&comments).Error
+	return comments, err
+}
+
+func (r *accountRepo) ListAllFavoritesByUser(ctx context.Context, userID uint) ([]model.Favorite, error) {
+	var favorites []model.Favorite
+	err := r.db.WithContext(ctx).
+		Where("user_id = ?", userID).
+		Order("created_at ASC").
+		Find(&favorites).Error
+	return favorites, err
+}
+
+func (r *accountRepo) ListAllFollowsByUser(ctx context.Context, userID uint) ([]model.UserFollow, error) {
+	var follows []model.UserFollow
+	err := r.db.WithContext(ctx).
+		Where("follower_id = ? OR followee_id = ?", userID, userID).
+		Order("created_at ASC").
+		Find(&follows).Error
+	return follows, err
+}
+
+func (r *accountRepo) ListAllNotificationsByUser(ctx context.Context, userID uint) ([]model.Notification, error) {
+	var notifications []model.Notification
+	err := r.db.WithContext(ctx).
+		Where("user_id = ?", userID).
+		Order("created_at ASC").
+		Find(&notifications).Error
+	return notifications, err
+}
+
+// AnonymizeUser 清空个人资料并改名，释放原用户名，最后软删除
+func (r *accountRepo) AnonymizeUser(ctx context.Context, userID uint) error {
+	err := r.db.WithContext(ctx).Model(&model.User{}).
+		Where("id = ?", userID).
+		Updates(map[string]any{
+			"username":          fmt.Sprintf("deleted_user_%d", userID),
+			"password_hash":     "",
+			"email":             nil,
+			"email_verified_at": nil,
+			"avatar_url":        "",
+			"profile":           "",
+			"vip_expires_at":    nil,
+			"token_version":     gorm.Expr("token_version + 1"),
+		}).Error
+	if err != nil {
+		return err
+	}
+
+	return r.db.WithContext(ctx).Delete(&model.User{}, userID).Error
+}
+
+func (r *accountRepo) DeleteDraftsByAuthor(ctx context.Context, userID uint) error {
+	return r.db.WithContext(ctx).Model(&model.Post{}).
+		Where("author_id = ? AND status = 1", userID).
+		Update("is_deleted", 1).Error
+}
+
+// DeletePostsByAuthor 返回删掉的已发布帖子 id，提交后要从搜索索引里拿掉
+func (r *accountRepo) DeletePostsByAuthor(ctx context.Context, userID uint) ([]uint, error) {
+	var ids []uint
+	err := r.db.WithContext(ctx).Model(&model.Post{}).
+		Where("author_id = ? AND is_deleted = 0", userID).
+		Pluck("id", &ids).Error
+	if err != nil || len(ids) == 0 {
+		return nil, err
+	}
+
+	err = r.db.WithContext(ctx).Model(&model.Post{}).
+		Where("id IN ?", ids).
+		Update("is_deleted", 1).Error
+	return ids, err
+}
+
+// DeleteCommentsByAuthor 和删除单条评论一样，下面的回复一起删；返回删掉的全部评论 id
+func (r *accountRepo) DeleteCommentsByAuthor(ctx context.Context, userID uint) ([]uint, error) {
+	var ids []uint
+	err := r.db.WithContext(ctx).Model(&model.Comment{}).
+		Where("author_id = ? AND is_deleted = 0", userID).
+		Pluck("id", &ids).Error
+	if err != nil || len(ids) == 0 {
+		return nil, err
+	}
+
+	err = r.db.WithContext(ctx).Model(&model.Comment{}).
+		Where("id IN ?", ids).
+		Update("is_deleted", 1).Error
+	if err != nil {
+		return nil, err
+	}
+
+	replies, err := deleteReplies(ctx, r.db, ids)
+	return append(ids, replies...), err
+}
+
+// DeleteRelationsByUser 删除收藏、关注关系、通知、两步验证和第三方账号绑定，点赞保留以免影响 like_count
+func (r *accountRepo) DeleteRelationsByUser(ctx context.Context, userID uint) error {
+	db := r.db.WithContext(ctx)
+
+	if err := db.Where("user_id = ?", userID).Delete(&model.Favorite{}).Error; err != nil {
+		return err
+	}
+	if err := db.Where("follower_id = ? OR followee_id = ?", userID, userID).Delete(&model.UserFollow{}).Error; err != nil {
+		return err
+	}
+	if err := db.Where("user_id = ?", userID).Delete(&model.QuestionFollow{}).Error; err != nil {
+		return err
+	}
+	if err := db.Where("user_id = ?", userID).Delete(&model.UserTOTP{}).Error; err != nil {
+		return err
+	}
+	if err := db.Where("user_id = ?", userID).Delete(&model.TOTPRecoveryCode{}).Error; err != nil {
+		return err
+	}
+	if err := db.Where("user_id = ?", userID).Delete(&model.UserIdentity{}).Error; err != nil {
+		return err
+	}
+
+	return db.Where("user_id = ?", userID).Delete(&model.Notification{}).Error
+}
@@ -16,7 +16,11 @@ type SessionRepository interface {
 	GetBySessionID(ctx context.Context, sessionID string) (*model.Session, error)
 	GetBySessionIDForUpdate(ctx context.Context, sessionID string) (*model.Session, error)
 	ListByUserID(ctx context.Context, userID int64) ([]model.Session, error)
+	ListVisibleByUserID(ctx context.Context, userID int64, revokedSince time.Time) ([]model.Session, error)
+	ListActiveByUserIDForUpdate(ctx context.Context, userID int64) ([]model.Session, error)
+	UpdateLabel(ctx context.Context, userID int64, sessionID string, label string) (int64, error)
 	RevokeActiveByUserID(ctx context.Context, userID int64, reason string, revokedAt time.Time) error
+	HasKnownDevice(ctx context.Context, userID int64, deviceID string, browserKey string) (bool, error)
 }
 
 type RefreshTokenRepository interface {
@@ -31,6 +35,24 @@ type RefreshTokenRepository interface {
 type SecurityEventRepository interface {
 	WithTx(tx *gorm.DB) SecurityEventRepository
 	Create(ctx context.Context, event *model.SecurityEvent) error
+	List(ctx context.Context, filter SecurityEventFilter, offset int, limit int) ([]model.SecurityEvent, int64, error)
+	Count(ctx context.Context, filter SecurityEventFilter) (int64, error)
+	ListAfter(ctx context.Context, after SecurityEventCursor, before time.Time, eventTypes []string, limit int) ([]model.SecurityEvent, error)
+}
+
+// SecurityEventCursor 按 (created_at, id) 递增遍历事件的位置
+type SecurityEventCursor struct {
+	CreatedAt time.Time
+	ID        int64
+}
+
+// SecurityEventFilter 零值字段不参与过滤，时间区间为 [From, To)
+type SecurityEventFilter struct {
+	UserID    int64
+	EventType string
+	Detail    string
+	From      time.Time
+	To        time.Time
 }
 
 type sessionRepo struct {
@@ -86,6 +108,42 @@ func (r *sessionRepo) ListByUserID(ctx context.Context, userID int64) ([]model.S
 	return sessions, nil
 }
 
+// ListVisibleByUserID 在线会话加上 revokedSince 之后下线的会话
+func (r *sessionRepo) ListVisibleByUserID(ctx context.Context, userID int64, revokedSince time.Time) ([]model.Session, error) {
+	var sessions []model.Session
+	if err := r.db.WithContext(ctx).
+		Where("user_id = ?", userID).
+		Where("status = ? OR revoked_at >= ?", "active", revokedSince).
+		Order("last_seen_at desc, created_at desc").
+		Find(&sessions).Error; err != nil {
+		return nil, err
+	}
+
+	return sessions, nil
+}
+
+// ListActiveByUserIDForUpdate 锁住用户的在线会话，按登录时间从早到晚排列，用于检查并发会话上限
+func (r *sessionRepo) ListActiveByUserIDForUpdate(ctx context.Context, userID int64) ([]model.Session, error) {
+	var sessions []model.Session
+	if err := r.db.WithContext(ctx).
+		Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("user_id = ? AND status = ?", userID, "active").
+		Order("created_at asc, id asc").
+		Find(&sessions).Error; err != nil {
+		return nil, err
+	}
+
+	return sessions, nil
+}
+
+func (r *sessionRepo) UpdateLabel(ctx context.Context, userID int64, sessionID string, label string) (int64, error) {
+	result := r.db.WithContext(ctx).
+		Model(&model.Session{}).
+		Where("session_id = ? AND user_id = ?", sessionID, userID).
+		Update("label", label)
+	return result.RowsAffected, result.Error
+}
+
 func (r *sessionRepo) RevokeActiveByUserID(ctx context.Context, userID int64, reason string, revokedAt time.Time) error {
 	return r.db.WithContext(ctx).
 		Model(&model.Session{}).
@@ -97,6 +155,29 @@ func (r *sessionRepo) RevokeActiveByUserID(ctx context.Context, userID int64, re
 		}).Error
 }
 
+// HasKnownDevice 判断用户以前是否用同一设备（或同一浏览器指纹）登录过
+func (r *sessionRepo) HasKnownDevice(ctx context.Context, userID int64, deviceID string, browserKey string) (bool, error) {
+	query := r.db.WithContext(ctx).Model(&model.Session{}).Where("user_id = ?", userID)
+
+	switch {
+	case deviceID != "" && browserKey != "":
+		query = query.Where("device_id = ? OR browser_key = ?", deviceID, browserKey)
+	case deviceID != "":
+		query = query.Where("device_id = ?", deviceID)
+	case browserKey != "":
+		query = query.Where("browser_key = ?", browserKey)
+	default:
+		return false, nil
+	}
+
+	var count int64
+	if err := query.Count(&count).Error; err != nil {
+		return false, err
+	}
+
+	return count > 0, nil
+}
+
 type refreshTokenRepo struct {
 	db *gorm.DB
 }
@@ -166,3 +247,60 @@ func (r *securityEventRepo) WithTx(tx *gorm.DB) SecurityEventRepository {
 func (r *securityEventRepo) Create(ctx context.Context, event *model.SecurityEvent) error {
 	return r.db.WithContext(ctx).Create(event).Error
 }
+
+func (r *securityEventRepo) filter(ctx context.Context, filter SecurityEventFilter) *gorm.DB {
+	query := r.db.WithContext(ctx).Model(&model.SecurityEvent{})
+	if filter.UserID > 0 {
+		query = query.Where("user_id = ?", filter.UserID)
+	}
+	if filter.EventType != "" {
+		query = query.Where("event_type = ?", filter.EventType)
+	}
+	if filter.Detail != "" {
+		query = query.Where("detail = ?", filter.Detail)
+	}
+	if !filter.From.IsZero() {
+		query = query.Where("created_at >= ?", filter.From)
+	}
+	if !filter.To.IsZero() {
+		query = query.Where("created_at < ?", filter.To)
+	}
+
+	return query
+}
+
+func (r *securityEventRepo) List(ctx context.Context, filter SecurityEventFilter, offset int, limit int) ([]model.SecurityEvent, int64, error) {
+	var total int64
+	if err := r.filter(ctx, filter).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var events []model.SecurityEvent
+	if err := r.filter(ctx, filter).
+		Order("id desc").
+		Offset(offset).
+		Limit(limit).
+		Find(&events).Error; err != nil {
+		return nil, 0, err
+	}
+
+	return events, total, nil
+}
+
+func (r *securityEventRepo) Count(ctx context.Context, filter SecurityEventFilter) (int64, error) {
+	var count int64
+	err := r.filter(ctx, filter).Count(&count).Error
+	return count, err
+}
+
+// ListAfter 取游标之后、创建时间早于 before 的事件，按 (created_at, id) 升序
+func (r *securityEventRepo) ListAfter(ctx context.Context, after SecurityEventCursor, before time.Time, eventTypes []string, limit int) ([]model.SecurityEvent, error) {
+	var events []model.SecurityEvent
+	err := r.db.WithContext(ctx).
+		Where("(created_at > ? OR (created_at = ? AND id > ?)) AND created_at < ?", after.CreatedAt, after.CreatedAt, after.ID, before).
+		Where("event_type IN ?", eventTypes).
+		Order("created_at asc, id asc").
+		Limit(limit).
+		Find(&events).Error
+	return events, err
+}
@@ -13,14 +13,14 @@ type CommentRepository interface {
 	ExistsByID(ctx context.Context, id uint) (bool, error)
 	GetAndScanAuthorID(ctx context.Context, id uint) (uint, error)
 	FindParentID(ctx context.Context, parent *model.Comment, req *dto.PostCommentRequest) error
-	CreateComment(ctx context.Context, comment model.Comment) error
+	CreateComment(ctx context.Context, comment *model.Comment) error
 	GetAuthorID(ctx context.Context, req *dto.PostCommentRequest, AuthorID *uint)
 	GetAuthorIDByComment(ctx context.Context, targetID uint, comment *model.Comment) error
 	CountRootComments(ctx context.Context, targetType uint8, targetID uint) (int64, error)
 	ListRootComments(ctx context.Context, req *dto.GetCommentsReq) ([]model.Comment, error)
 	FindTargetComment(ctx context.Context, subs *[]model.Comment, parent uint) error
 	DeleteComment(ctx context.Context, comment model.Comment) error
-	DeleteSubComments(ctx context.Context, parentID uint)
+	DeleteSubComments(ctx context.Context, parentID uint) ([]uint, error)
 }
 type commentRepo struct {
 	db *gorm.DB
@@ -61,7 +61,7 @@ func (r *commentRepo) FindParentID(ctx context.Context, parent *model.Comment, r
 	return err
 }
 
-func (r *commentRepo) CreateComment(ctx context.Context, comment model.Comment) error {
+func (r *commentRepo) CreateComment(ctx context.Context, comment *model.Comment) error {
 	err := r.db.WithContext(ctx).Create(&comment).Error
 	return err
 }
@@ -122,23 +122,33 @@ func (r *commentRepo) DeleteComment(ctx context.Context, comment model.Comment)
 	return err
 }
 
-func (r *commentRepo) DeleteSubComments(ctx context.Context, parentID uint) {
-	var subIDs []uint
-	r.db.WithContext(ctx).Model(&model.Comment{}).
-		Where("target_type = 3 AND target_id = ? AND is_deleted = 0", parentID).
-		Pluck("id", &subIDs)
+// DeleteSubComments 逐层删掉 parentID 下面所有的回复，返回删掉的评论 id
+func (r *commentRepo) DeleteSubComments(ctx context.Context, parentID uint) ([]uint, error) {
+	return deleteReplies(ctx, r.db, []uint{parentID})
+}
 
-	if len(subIDs) == 0 {
-		return
+// deleteReplies 逐层删掉 parents 下面所有的回复，注销时删除用户的评论也要连带删掉别人的回复
+func deleteReplies(ctx context.Context, db *gorm.DB, parents []uint) ([]uint, error) {
+	var deleted []uint
+	for len(parents) > 0 {
+		var subIDs []uint
+		err := db.WithContext(ctx).Model(&model.Comment{}).
+			Where("target_type = 3 AND target_id IN ? AND is_deleted = 0", parents).
+			Pluck("id", &subIDs).Error
+		if err != nil || len(subIDs) == 0 {
+			return deleted, err
+		}
+
+		// 删当前层，再接着删下一层
+		err = db.WithContext(ctx).Model(&model.Comment{}).
+			Where("id IN ?", subIDs).
+			Update("is_deleted", 1).Error
+		if err != nil {
+			return deleted, err
+		}
+		deleted = append(deleted, subIDs...)
+		parents = subIDs
 	}
 
-	// 删当前层
-	r.db.WithContext(ctx).Model(&model.Comment{}).
-		Where("id IN ?", subIDs).
-		Update("is_deleted", 1)
-
-	// 递归下一层
-	for _, id := range subIDs {
-		r.DeleteSubComments(ctx, id)
-	}
+	return deleted, nil
 }
@@ -0,0 +1,58 @@
+package repository
+
+import (
+	"context"
+	"lesson10/internal/model"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type EmailTokenRepository interface {
+	WithTx(tx *gorm.DB) EmailTokenRepository
+	Create(ctx context.Context, token *model.EmailToken) error
+	Update(ctx context.Context, token *model.EmailToken) error
+	GetByTokenHashForUpdate(ctx context.Context, tokenHash string) (*model.EmailToken, error)
+	InvalidateActive(ctx context.Context, userID int64, purpose string, usedAt time.Time) error
+}
+
+type emailTokenRepo struct {
+	db *gorm.DB
+}
+
+func NewEmailTokenRepo(db *gorm.DB) EmailTokenRepository {
+	return &emailTokenRepo{db: db}
+}
+
+func (r *emailTokenRepo) WithTx(tx *gorm.DB) EmailTokenRepository {
+	return &emailTokenRepo{db: tx}
+}
+
+func (r *emailTokenRepo) Create(ctx context.Context, token *model.EmailToken) error {
+	return r.db.WithContext(ctx).Create(token).Error
+}
+
+func (r *emailTokenRepo) Update(ctx context.Context, token *model.EmailToken) error {
+	return r.db.WithContext(ctx).Save(token).Error
+}
+
+func (r *emailTokenRepo) GetByTokenHashForUpdate(ctx context.Context, tokenHash string) (*model.EmailToken, error) {
+	var token model.EmailToken
+	if err := r.db.WithContext(ctx).
+		Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("token_hash = ?", tokenHash).
+		First(&token).Error; err != nil {
+		return nil, err
+	}
+
+	return &token, nil
+}
+
+// InvalidateActive 让同一用户同一用途的旧链接全部失效
+func (r *emailTokenRepo) InvalidateActive(ctx context.Context, userID int64, purpose string, usedAt time.Time) error {
+	return r.db.WithContext(ctx).
+		Model(&model.EmailToken{}).
+		Where("user_id = ? AND purpose = ? AND used_at IS NULL", userID, purpose).
+		Update("used_at", usedAt).Error
+}
@@ -0,0 +1,94 @@
+package repository
+
+import (
+	"context"
+	"lesson10/internal/model"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type IdentityRepository interface {
+	WithTx(tx *gorm.DB) IdentityRepository
+
+	Create(ctx context.Context, identity *model.UserIdentity) error
+	Update(ctx context.Context, identity *model.UserIdentity) error
+	GetByProviderSubject(ctx context.Context, provider string, subject string) (*model.UserIdentity, error)
+	ListByUserID(ctx context.Context, userID int64) ([]model.UserIdentity, error)
+	DeleteByUserProvider(ctx context.Context, userID int64, provider string) (bool, error)
+
+	CreateState(ctx context.Context, state *model.OAuthState) error
+	UpdateState(ctx context.Context, state *model.OAuthState) error
+	GetStateByHashForUpdate(ctx context.Context, stateHash string) (*model.OAuthState, error)
+}
+
+type identityRepo struct {
+	db *gorm.DB
+}
+
+func NewIdentityRepo(db *gorm.DB) IdentityRepository {
+	return &identityRepo{db: db}
+}
+
+func (r *identityRepo) WithTx(tx *gorm.DB) IdentityRepository {
+	return &identityRepo{db: tx}
+}
+
+func (r *identityRepo) Create(ctx context.Context, identity *model.UserIdentity) error {
+	return r.db.WithContext(ctx).Create(identity).Error
+}
+
+func (r *identityRepo) Update(ctx context.Context, identity *model.UserIdentity) error {
+	return r.db.WithContext(ctx).Save(identity).Error
+}
+
+func (r *identityRepo) GetByProviderSubject(ctx context.Context, provider string, subject string) (*model.UserIdentity, error) {
+	var identity model.UserIdentity
+	if err := r.db.WithContext(ctx).
+		Where("provider = ? AND subject = ?", provider, subject).
+		First(&identity).Error; err != nil {
+		return nil, err
+	}
+
+	return &identity, nil
+}
+
+func (r *identityRepo) ListByUserID(ctx context.Context, userID int64) ([]model.UserIdentity, error) {
+	var identities []model.UserIdentity
+	err := r.db.WithContext(ctx).
+		Where("user_id = ?", userID).
+		Order("id ASC").
+		Find(&identities).Error
+	return identities, err
+}
+
+func (r *identityRepo) DeleteByUserProvider(ctx context.Context, userID int64, provider string) (bool, error) {
+	res := r.db.WithContext(ctx).
+		Where("user_id = ? AND provider = ?", userID, provider).
+		Delete(&model.UserIdentity{})
+	if res.Error != nil {
+		return false, res.Error
+	}
+
+	return res.RowsAffected > 0, nil
+}
+
+func (r *identityRepo) CreateState(ctx context.Context, state *model.OAuthState) error {
+	return r.db.WithContext(ctx).Create(state).Error
+}
+
+func (r *identityRepo) UpdateState(ctx context.Context, state *model.OAuthState) error {
+	return r.db.WithContext(ctx).Save(state).Error
+}
+
+func (r *identityRepo) GetStateByHashForUpdate(ctx context.Context, stateHash string) (*model.OAuthState, error) {
+	var state model.OAuthState
+	if err := r.db.WithContext(ctx).
+		Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("state_hash = ?", stateHash).
+		First(&state).Error; err != nil {
+		return nil, err
+	}
+
+	return &state, nil
+}
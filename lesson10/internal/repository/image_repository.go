@@ -0,0 +1,258 @@
+package repository
+
+import (
+	"context"
+	"lesson10/internal/model"
+	"net/url"
+	"regexp"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type ImageRepository interface {
+	WithTx(tx *gorm.DB) ImageRepository
+	LockHash(ctx context.Context, kind string, hash string) error
+	Create(ctx context.Context, image *model.PostImage) error
+	FindByID(ctx context.Context, id uint) (*model.PostImage, error)
+	FindByHash(ctx context.Context, kind string, hash string) ([]model.PostImage, error)
+	RewriteURLPrefix(ctx context.Context, from string, to string, keys []string) (int64, error)
+	SumSizeByUploader(ctx context.Context, uploaderID uint) (int64, error)
+	ReplacePostRefs(ctx context.Context, postID uint, hashes []string) error
+	RestoreRefs(ctx context.Context, image *model.PostImage) (int64, error)
+	ListOrphans(ctx context.Context, cutoff time.Time, afterID uint, limit int) ([]model.PostImage, error)
+	Delete(ctx context.Context, id uint) error
+	CountByHash(ctx context.Context, kind string, hash string) (int64, error)
+}
+
+type imageRepo struct {
+	db *gorm.DB
+}
+
+func NewImageRepo(db *gorm.DB) ImageRepository {
+	return &imageRepo{db: db}
+}
+
+func (r *imageRepo) WithTx(tx *gorm.DB) ImageRepository {
+	return &imageRepo{db: tx}
+}
+
+// LockHash 在事务里锁住这组文件对应的 image_blobs 行，事务结束前别的上传和清理都要等；
+// 第一次用到时先 INSERT IGNORE，并发插入同一行也不会报错
+func (r *imageRepo) LockHash(ctx context.Context, kind string, hash string) error {
+	db := r.db.WithContext(ctx)
+
+	if err := db.Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&model.ImageBlob{Kind: kind, ContentHash: hash}).Error; err != nil {
+		return err
+	}
+
+	var blob model.ImageBlob
+	return db.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("kind = ? AND content_hash = ?", kind, hash).
+		First(&blob).Error
+}
+
+func (r *imageRepo) Create(ctx context.Context, image *model.PostImage) error {
+	return r.db.WithContext(ctx).Create(image).Error
+}
+
+func (r *imageRepo) FindByID(ctx context.Context, id uint) (*model.PostImage, error) {
+	var image model.PostImage
+	if err := r.db.WithContext(ctx).First(&image, id).Error; err != nil {
+		return nil, err
+	}
+	return &image, nil
+}
+
+// FindByHash 同一类型下内容相同的上传记录，最新的在前
+func (r *imageRepo) FindByHash(ctx context.Context, kind string, hash string) ([]model.PostImage, error) {
+	var images []model.PostImage
+	err := r.db.WithContext(ctx).
+		Where("kind = ? AND content_hash = ?", kind, hash).
+		Order("id desc").
+		Find(&images).Error
+	return images, err
+}
+
+// RewriteURLPrefix 迁移存储后端时把库里存的旧地址换成新的：图片记录的 url/variants、用户头像、文章正文和渲染缓存里的图片链接。
+// 只改 from 后面紧跟着 keys 里某个 key 的地址，而且 from 前面不能是域名或路径的一部分，
+// 正文里别的站点的链接、恰好包含同样前缀的文字都不会被动到
+func (r *imageRepo) RewriteURLPrefix(ctx context.Context, from string, to string, keys []string) (int64, error) {
+	migrated := make(map[string]bool, len(keys))
+	for _, key := range keys {
+		migrated[key] = true
+	}
+	pattern := regexp.MustCompile(`(^|[^\w.:/-])` + regexp.QuoteMeta(from) + `([\w.~%/-]+)`)
+	rewrite := func(text string) string {
+		return pattern.ReplaceAllStringFunc(text, func(match string) string {
+			m := pattern.FindStringSubmatch(match)
+			key, err := url.PathUnescape(m[2])
+			if err != nil || !migrated[key] {
+				return match
+			}
+			return m[1] + to + m[2]
+		})
+	}
+
+	var total int64
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		updates := []struct {
+			table  string
+			column string
+		}{
+			{"post_images", "url"},
+			{"post_images", "variants"},
+			{"users", "avatar_url"},
+			{"posts", "content"},
+			{"posts", "content_html"},
+		}
+
+		for _, u := range updates {
+			n, err := rewriteColumn(tx, u.table, u.column, from, rewrite)
+			if err != nil {
+				return err
+			}
+			total += n
+		}
+		return nil
+	})
+	return total, err
+}
+
+// rewriteColumn 按 id 分批扫描含 from 的行，在 Go 里替换后逐行写回；软删除的行也要改，恢复后地址才是对的，
+// 直接按表名更新，不动 updated_at
+func rewriteColumn(tx *gorm.DB, table string, column string, from string, rewrite func(string) string) (int64, error) {
+	const batchSize = 500
+
+	var (
+		total  int64
+		lastID uint
+	)
+	for {
+		var rows []struct {
+			ID    uint
+			Value string
+		}
+		err := tx.Table(table).
+			Select("id, COALESCE("+column+", '') AS value").
+			Where("id > ? AND "+column+" LIKE ?", lastID, "%"+from+"%").
+			Order("id asc").
+			Limit(batchSize).
+			Scan(&rows).Error
+		if err != nil || len(rows) == 0 {
+			return total, err
+		}
+
+		for _, row := range rows {
+			lastID = row.ID
+			if value := rewrite(row.Value); value != row.Value {
+				if err := tx.Table(table).Where("id = ?", row.ID).UpdateColumn(column, value).Error; err != nil {
+					return total, err
+				}
+				total++
+			}
+		}
+	}
+}
+
+// SumSizeByUploader 用户已用的存储空间，去重复用的文件也算在每个上传者头上
+func (r *imageRepo) SumSizeByUploader(ctx context.Context, uploaderID uint) (int64, error) {
+	var total int64
+	err := r.db.WithContext(ctx).Model(&model.PostImage{}).
+		Where("uploader_id = ?", uploaderID).
+		Select("COALESCE(SUM(size), 0)").
+		Scan(&total).Error
+	return total, err
+}
+
+// ReplacePostRefs 用帖子当前正文里出现的图片哈希重建引用；同一哈希的所有文章图片记录都算被引用
+func (r *imageRepo) ReplacePostRefs(ctx context.Context, postID uint, hashes []string) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("post_id = ?", postID).Delete(&model.PostImageRef{}).Error; err != nil {
+			return err
+		}
+		if len(hashes) == 0 {
+			return nil
+		}
+
+		var imageIDs []uint
+		err := tx.Model(&model.PostImage{}).
+			Where("kind = ? AND content_hash IN ?", model.ImageKindArticle, hashes).
+			Pluck("id", &imageIDs).Error
+		if err != nil || len(imageIDs) == 0 {
+			return err
+		}
+
+		refs := make([]model.PostImageRef, 0, len(imageIDs))
+		for _, id := range imageIDs {
+			refs = append(refs, model.PostImageRef{PostID: postID, ImageID: id})
+		}
+		return tx.Create(&refs).Error
+	})
+}
+
+// RestoreRefs 清理前的最后一道检查：正文里还出现这张图片的未删除帖子补上引用，返回补了几条。
+// 帖子保存后同步引用失败、引用表上线前的老帖子都靠这里兜底，只对清理候选执行，不用定期全量重建
+func (r *imageRepo) RestoreRefs(ctx context.Context, image *model.PostImage) (int64, error) {
+	if len(image.ContentHash) < 2 {
+		return 0, nil
+	}
+
+	db := r.db.WithContext(ctx)
+	var postIDs []uint
+	err := db.Model(&model.Post{}).
+		Where("is_deleted = 0 AND content LIKE ?", "%images/"+image.ContentHash[:2]+"/"+image.ContentHash+"/%").
+		Pluck("id", &postIDs).Error
+	if err != nil || len(postIDs) == 0 {
+		return 0, err
+	}
+
+	refs := make([]model.PostImageRef, 0, len(postIDs))
+	for _, postID := range postIDs {
+		refs = append(refs, model.PostImageRef{PostID: postID, ImageID: image.ID})
+	}
+	res := db.Clauses(clause.OnConflict{DoNothing: true}).Create(&refs)
+	return int64(len(refs)), res.Error
+}
+
+// ListOrphans 上传早于 cutoff、已经没人用的图片：文章图片没有被未删除的帖子引用，头像不是上传者当前的头像。
+// 039 之前的老记录没有 content_hash，文件位置不确定，不处理
+func (r *imageRepo) ListOrphans(ctx context.Context, cutoff time.Time, afterID uint, limit int) ([]model.PostImage, error) {
+	var images []model.PostImage
+	err := r.db.WithContext(ctx).
+		Where("id > ? AND created_at < ? AND content_hash <> ''", afterID, cutoff).
+		Where(r.db.
+			Where("kind = ? AND NOT EXISTS (?)", model.ImageKindArticle,
+				r.db.Table("post_image_refs AS r").
+					Select("1").
+					Joins("JOIN posts p ON p.id = r.post_id").
+					Where("r.image_id = post_images.id AND p.is_deleted = 0 AND p.deleted_at IS NULL")).
+			Or("kind = ? AND NOT EXISTS (?)", model.ImageKindAvatar,
+				r.db.Table("users AS u").
+					Select("1").
+					Where("u.id = post_images.uploader_id AND u.deleted_at IS NULL AND INSTR(u.avatar_url, post_images.content_hash) > 0"))).
+		Order("id asc").
+		Limit(limit).
+		Find(&images).Error
+	return images, err
+}
+
+// Delete 物理删除记录和它的引用，释放配额
+func (r *imageRepo) Delete(ctx context.Context, id uint) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("image_id = ?", id).Delete(&model.PostImageRef{}).Error; err != nil {
+			return err
+		}
+		return tx.Unscoped().Delete(&model.PostImage{}, id).Error
+	})
+}
+
+func (r *imageRepo) CountByHash(ctx context.Context, kind string, hash string) (int64, error) {
+	var count int64
+	err := r.db.WithContext(ctx).Model(&model.PostImage{}).
+		Where("kind = ? AND content_hash = ?", kind, hash).
+		Count(&count).Error
+	return count, err
+}
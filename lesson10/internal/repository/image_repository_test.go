@@ -0,0 +1,109 @@
+package repository
+
+import (
+	"context"
+	"lesson10/internal/model"
+	"path/filepath"
+	"testing"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func newTestDB(t *testing.T, models ...any) *gorm.DB {
+	t.Helper()
+
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "test.db")), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { sqlDB.Close() })
+
+	if err := db.AutoMigrate(models...); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func TestRewriteURLPrefixOnlyTouchesMigratedKeys(t *testing.T) {
+	db := newTestDB(t, &model.User{}, &model.Post{}, &model.PostImage{})
+	ctx := context.Background()
+
+	const (
+		from     = "/static/uploads/"
+		to       = "https://cdn.example.com/"
+		migrated = "images/ab/abcdef/medium.jpg"
+		avatar   = "avatars/cd/cdef01/thumb.jpg"
+	)
+
+	user := model.User{Username: "alice", PasswordHash: "x", AvatarURL: from + avatar}
+	if err := db.Create(&user).Error; err != nil {
+		t.Fatal(err)
+	}
+	image := model.PostImage{
+		UploaderID: user.ID,
+		URL:        from + migrated,
+		Variants:   []model.ImageVariant{{Name: "medium", URL: from + migrated}, {Name: "thumb", URL: from + "images/ab/abcdef/thumb.jpg"}},
+	}
+	if err := db.Create(&image).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	content := "![a](" + from + migrated + ")\n" +
+		"别的站点：https://other.example.com" + from + migrated + "\n" +
+		"没迁移的文件：" + from + "images/ff/ffffff/original.jpg\n" +
+		"说明文字里的 " + from + " 前缀"
+	post := model.Post{AuthorID: user.ID, Title: "t", Content: content, ContentHTML: `<img src="` + from + migrated + `">`}
+	if err := db.Create(&post).Error; err != nil {
+		t.Fatal(err)
+	}
+	// 软删除的帖子也要改
+	if err := db.Delete(&post).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := NewImageRepo(db).RewriteURLPrefix(ctx, from, to, []string{migrated, avatar})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// post_images.url、post_images.variants、users.avatar_url、posts.content、posts.content_html 各一行
+	if n != 5 {
+		t.Fatalf("rows updated = %d, want 5", n)
+	}
+
+	var gotImage model.PostImage
+	if err := db.First(&gotImage, image.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if gotImage.URL != to+migrated || gotImage.Variants[0].URL != to+migrated || gotImage.Variants[1].URL != from+"images/ab/abcdef/thumb.jpg" {
+		t.Fatalf("image = %s %+v", gotImage.URL, gotImage.Variants)
+	}
+
+	var gotUser model.User
+	if err := db.First(&gotUser, user.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	if gotUser.AvatarURL != to+avatar {
+		t.Fatalf("avatar = %s", gotUser.AvatarURL)
+	}
+
+	var gotPost model.Post
+	if err := db.Unscoped().First(&gotPost, post.ID).Error; err != nil {
+		t.Fatal(err)
+	}
+	wantContent := "![a](" + to + migrated + ")\n" +
+		"别的站点：https://other.example.com" + from + migrated + "\n" +
+		"没迁移的文件：" + from + "images/ff/ffffff/original.jpg\n" +
+		"说明文字里的 " + from + " 前缀"
+	if gotPost.Content != wantContent {
+		t.Fatalf("content =\n%s\nwant\n%s", gotPost.Content, wantContent)
+	}
+	if gotPost.ContentHTML != `<img src="`+to+migrated+`">` {
+		t.Fatalf("content_html = %s", gotPost.ContentHTML)
+	}
+}
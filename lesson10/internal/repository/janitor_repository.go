@@ -0,0 +1,90 @@
+package repository
+
+import (
+	"context"
+	"lesson10/internal/model"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// JanitorRepository 按主键小批量清理过期的会话和 refresh token，避免长事务和大范围锁
+type JanitorRepository interface {
+	ListStaleRefreshTokens(ctx context.Context, cutoff time.Time, afterID int64, limit int) ([]model.RefreshToken, error)
+	ListStaleSessions(ctx context.Context, cutoff time.Time, afterID int64, limit int) ([]model.Session, error)
+	DeleteRefreshTokens(ctx context.Context, ids []int64) (int64, error)
+	DeleteSessions(ctx context.Context, sessions []model.Session) (int64, int64, error)
+}
+
+type janitorRepo struct {
+	db *gorm.DB
+}
+
+func NewJanitorRepo(db *gorm.DB) JanitorRepository {
+	return &janitorRepo{db: db}
+}
+
+// ListStaleRefreshTokens 已用过/已吊销且 cutoff 之前不再变化的，以及 cutoff 之前就过期的
+func (r *janitorRepo) ListStaleRefreshTokens(ctx context.Context, cutoff time.Time, afterID int64, limit int) ([]model.RefreshToken, error) {
+	var tokens []model.RefreshToken
+	err := r.db.WithContext(ctx).
+		Where("id > ?", afterID).
+		Where("(status <> ? AND updated_at < ?) OR expires_at < ?", "active", cutoff, cutoff).
+		Order("id asc").
+		Limit(limit).
+		Find(&tokens).Error
+	return tokens, err
+}
+
+// ListStaleSessions cutoff 之前被吊销的，以及 cutoff 之后再没出现过的在线会话（refresh token 早已过期）
+func (r *janitorRepo) ListStaleSessions(ctx context.Context, cutoff time.Time, afterID int64, limit int) ([]model.Session, error) {
+	var sessions []model.Session
+	err := r.db.WithContext(ctx).
+		Where("id > ?", afterID).
+		Where("(status = ? AND revoked_at < ?) OR (status = ? AND last_seen_at < ?)", "revoked", cutoff, "active", cutoff).
+		Order("id asc").
+		Limit(limit).
+		Find(&sessions).Error
+	return sessions, err
+}
+
+func (r *janitorRepo) DeleteRefreshTokens(ctx context.Context, ids []int64) (int64, error) {
+	if len(ids) == 0 {
+		return 0, nil
+	}
+
+	result := r.db.WithContext(ctx).Where("id IN ?", ids).Delete(&model.RefreshToken{})
+	return result.RowsAffected, result.Error
+}
+
+// DeleteSessions 先删会话下剩余的 refresh token，再删会话，返回 (会话数, token 数)
+func (r *janitorRepo) DeleteSessions(ctx context.Context, sessions []model.Session) (int64, int64, error) {
+	if len(sessions) == 0 {
+		return 0, 0, nil
+	}
+
+	ids := make([]int64, 0, len(sessions))
+	sessionIDs := make([]string, 0, len(sessions))
+	for _, session := range sessions {
+		ids = append(ids, session.ID)
+		sessionIDs = append(sessionIDs, session.SessionID)
+	}
+
+	var deletedSessions, deletedTokens int64
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("session_id IN ?", sessionIDs).Delete(&model.RefreshToken{})
+		if result.Error != nil {
+			return result.Error
+		}
+		deletedTokens = result.RowsAffected
+
+		result = tx.Where("id IN ?", ids).Delete(&model.Session{})
+		if result.Error != nil {
+			return result.Error
+		}
+		deletedSessions = result.RowsAffected
+		return nil
+	})
+
+	return deletedSessions, deletedTokens, err
+}
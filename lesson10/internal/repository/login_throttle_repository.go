@@ -0,0 +1,70 @@
+package repository
+
+import (
+	"context"
+	"lesson10/internal/model"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type LoginThrottleRepository interface {
+	WithTx(tx *gorm.DB) LoginThrottleRepository
+	Get(ctx context.Context, scope string, key string) (*model.LoginThrottle, error)
+	GetOrCreateForUpdate(ctx context.Context, scope string, key string, now time.Time) (*model.LoginThrottle, error)
+	Save(ctx context.Context, throttle *model.LoginThrottle) error
+	Delete(ctx context.Context, scope string, key string) error
+}
+
+type loginThrottleRepo struct {
+	db *gorm.DB
+}
+
+func NewLoginThrottleRepo(db *gorm.DB) LoginThrottleRepository {
+	return &loginThrottleRepo{db: db}
+}
+
+func (r *loginThrottleRepo) WithTx(tx *gorm.DB) LoginThrottleRepository {
+	return &loginThrottleRepo{db: tx}
+}
+
+func (r *loginThrottleRepo) Get(ctx context.Context, scope string, key string) (*model.LoginThrottle, error) {
+	var throttle model.LoginThrottle
+	if err := r.db.WithContext(ctx).
+		Where("scope = ? AND `key` = ?", scope, key).
+		First(&throttle).Error; err != nil {
+		return nil, err
+	}
+
+	return &throttle, nil
+}
+
+// GetOrCreateForUpdate 并发下第一次失败可能同时插入，先 INSERT IGNORE 再加锁读
+func (r *loginThrottleRepo) GetOrCreateForUpdate(ctx context.Context, scope string, key string, now time.Time) (*model.LoginThrottle, error) {
+	db := r.db.WithContext(ctx)
+
+	if err := db.Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&model.LoginThrottle{Scope: scope, Key: key, LastFailedAt: now}).Error; err != nil {
+		return nil, err
+	}
+
+	var throttle model.LoginThrottle
+	if err := db.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("scope = ? AND `key` = ?", scope, key).
+		First(&throttle).Error; err != nil {
+		return nil, err
+	}
+
+	return &throttle, nil
+}
+
+func (r *loginThrottleRepo) Save(ctx context.Context, throttle *model.LoginThrottle) error {
+	return r.db.WithContext(ctx).Save(throttle).Error
+}
+
+func (r *loginThrottleRepo) Delete(ctx context.Context, scope string, key string) error {
+	return r.db.WithContext(ctx).
+		Where("scope = ? AND `key` = ?", scope, key).
+		Delete(&model.LoginThrottle{}).Error
+}
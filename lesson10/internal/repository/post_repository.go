@@ -25,6 +25,8 @@ type PostRepository interface {
 	ListUserDraftPosts(ctx context.Context, userID uint, offset, limit int, posts *[]model.Post) error
 	CountUserDraftPosts(ctx context.Context, uid uint, total *int64) error
 	ListPosts(ctx context.Context, q dto.ListPostsQuery) ([]dto.PostListItem, int64, error)
+	SaveRendered(ctx context.Context, postID uint, contentHTML string, excerpt string, version int) error
+	ListStaleRendered(ctx context.Context, version int, afterID uint, limit int) ([]model.Post, error)
 }
 
 type postRepo struct {
@@ -204,6 +206,7 @@ func (r *postRepo) ListPosts(ctx context.Context, q dto.ListPostsQuery) ([]dto.P
 				u.username AS author_name,
 				u.avatar_url AS author_avatar_url,
 				p.title,
+				p.excerpt,
 				p.created_at,
 				p.updated_at,
 				MATCH(p.title, p.content) AGAINST(? IN NATURAL LANGUAGE MODE) AS score
@@ -224,6 +227,7 @@ func (r *postRepo) ListPosts(ctx context.Context, q dto.ListPostsQuery) ([]dto.P
 				u.username AS author_name,
 				u.avatar_url AS author_avatar_url,
 				p.title,
+				p.excerpt,
 				p.created_at,
 				p.updated_at
 			`).
@@ -238,3 +242,26 @@ func (r *postRepo) ListPosts(ctx context.Context, q dto.ListPostsQuery) ([]dto.P
 
 	return items, total, nil
 }
+
+// SaveRendered 只写渲染缓存，不动 updated_at
+func (r *postRepo) SaveRendered(ctx context.Context, postID uint, contentHTML string, excerpt string, version int) error {
+	return r.db.WithContext(ctx).Model(&model.Post{}).
+		Where("id = ?", postID).
+		UpdateColumns(map[string]interface{}{
+			"content_html":   contentHTML,
+			"excerpt":        excerpt,
+			"render_version": version,
+		}).Error
+}
+
+// ListStaleRendered 渲染缓存版本不是当前版本的帖子，按 id 分批
+func (r *postRepo) ListStaleRendered(ctx context.Context, version int, afterID uint, limit int) ([]model.Post, error) {
+	var posts []model.Post
+	err := r.db.WithContext(ctx).
+		Select("id", "content").
+		Where("id > ? AND render_version <> ?", afterID, version).
+		Order("id asc").
+		Limit(limit).
+		Find(&posts).Error
+	return posts, err
+}
@@ -0,0 +1,311 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"lesson10/internal/model"
+	"lesson10/internal/pkg/markdown"
+	"lesson10/internal/pkg/search"
+	"sort"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// SearchIndex 全文搜索的实现：mysql 直接查 FULLTEXT 索引，embedded 是进程内的倒排索引，需要帖子/评论/用户写入时同步
+type SearchIndex interface {
+	Index(ctx context.Context, doc search.Doc) error
+	Delete(ctx context.Context, docType string, id uint) error
+	Search(ctx context.Context, q search.Query) (*search.Result, error)
+}
+
+// LocalSearchIndex 自己保存数据的索引：空的时候要从数据库重建，定期落盘
+type LocalSearchIndex interface {
+	SearchIndex
+	Len() int
+	Flush() error
+}
+
+// NewSearchIndex engine 为 mysql 或 embedded，indexPath 是内嵌索引的快照文件
+func NewSearchIndex(db *gorm.DB, engine string, indexPath string) (SearchIndex, error) {
+	switch engine {
+	case "mysql":
+		return &mysqlSearchIndex{db: db}, nil
+	case "embedded":
+		index, err := search.Open(indexPath)
+		if err != nil {
+			return nil, err
+		}
+		return index, nil
+	default:
+		return nil, fmt.Errorf("unknown search engine %q", engine)
+	}
+}
+
+type mysqlSearchIndex struct {
+	db *gorm.DB
+}
+
+// Index 数据本身就在表里，不用同步
+func (r *mysqlSearchIndex) Index(ctx context.Context, doc search.Doc) error {
+	return nil
+}
+
+func (r *mysqlSearchIndex) Delete(ctx context.Context, docType string, id uint) error {
+	return nil
+}
+
+type searchRow struct {
+	Type       string `gorm:"-"`
+	ID         uint
+	Title      string
+	Body       string
+	AuthorID   uint
+	TargetType uint8
+	TargetID   uint
+	CreatedAt  time.Time
+	Score      float64
+}
+
+var searchTables = map[string]string{
+	search.TypePost:    "posts",
+	search.TypeComment: "comments",
+	search.TypeUser:    "users",
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+// scope 某种类型的命中条件；帖子和评论走 ngram FULLTEXT，用户名和简介很短，直接 LIKE
+func (r *mysqlSearchIndex) scope(ctx context.Context, docType string, text string) *gorm.DB {
+	db := r.db.WithContext(ctx)
+	switch docType {
+	case search.TypePost:
+		return db.Model(&model.Post{}).
+			Where("is_deleted = 0 AND status = 0").
+			Where("MATCH(title, content) AGAINST(? IN NATURAL LANGUAGE MODE)", text)
+	case search.TypeComment:
+		return visibleComments(db.Model(&model.Comment{})).
+			Where("MATCH(comments.content) AGAINST(? IN NATURAL LANGUAGE MODE)", text)
+	default:
+		like := "%" + likeEscaper.Replace(text) + "%"
+		return db.Model(&model.User{}).
+			Where("username LIKE ? OR profile LIKE ?", like, like)
+	}
+}
+
+func (r *mysqlSearchIndex) find(ctx context.Context, docType string, text string, sortBy string, limit int) ([]searchRow, error) {
+	db := r.scope(ctx, docType, text)
+	switch docType {
+	case search.TypePost:
+		db = db.Select(`
+			id,
+			title,
+			COALESCE(NULLIF(content_html, ''), content) AS body,
+			author_id,
+			created_at,
+			MATCH(title, content) AGAINST(? IN NATURAL LANGUAGE MODE) AS score
+		`, text)
+	case search.TypeComment:
+		db = db.Select(`
+			comments.id,
+			comments.content AS body,
+			comments.author_id,
+			comments.target_type,
+			comments.target_id,
+			comments.created_at,
+			MATCH(comments.content) AGAINST(? IN NATURAL LANGUAGE MODE) AS score
+		`, text)
+	default:
+		escaped := likeEscaper.Replace(text)
+		db = db.Select(`
+			id,
+			username AS title,
+			profile AS body,
+			id AS author_id,
+			created_at,
+			CASE WHEN username = ? THEN 3 WHEN username LIKE ? THEN 2 WHEN username LIKE ? THEN 1.5 ELSE 1 END AS score
+		`, text, escaped+"%", "%"+escaped+"%")
+	}
+
+	// 评论连了帖子和父评论，排序字段要带表名
+	table := searchTables[docType]
+	if sortBy == search.SortNewest {
+		db = db.Order(table + ".created_at DESC, " + table + ".id DESC")
+	} else {
+		db = db.Order("score DESC, " + table + ".created_at DESC, " + table + ".id DESC")
+	}
+
+	var rows []searchRow
+	if err := db.Limit(limit).Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+	for i := range rows {
+		rows[i].Type = docType
+		if docType == search.TypePost {
+			rows[i].Body = markdown.PlainText(rows[i].Body)
+		}
+	}
+
+	return rows, nil
+}
+
+// Search 每种类型各取前 offset+limit 条再合并排序；不同类型的分数来源不同，只保证同类型内的顺序。
+// 没有拼写容错，高亮按同样的分词规则在结果里补
+func (r *mysqlSearchIndex) Search(ctx context.Context, q search.Query) (*search.Result, error) {
+	result := search.NewResult()
+	text := strings.TrimSpace(q.Text)
+	if text == "" {
+		return result, nil
+	}
+
+	wanted := map[string]bool{}
+	for _, t := range q.Types {
+		wanted[t] = true
+	}
+
+	rows := make([]searchRow, 0)
+	for _, docType := range search.Types {
+		var count int64
+		if err := r.scope(ctx, docType, text).Count(&count).Error; err != nil {
+			return nil, err
+		}
+		result.Facets[docType] = count
+		if count == 0 || (len(wanted) > 0 && !wanted[docType]) {
+			continue
+		}
+		result.Total += count
+
+		found, err := r.find(ctx, docType, text, q.Sort, q.Offset+q.Limit)
+		if err != nil {
+			return nil, err
+		}
+		rows = append(rows, found...)
+	}
+
+	sort.SliceStable(rows, func(i, j int) bool {
+		a, b := rows[i], rows[j]
+		if q.Sort == search.SortNewest || a.Score == b.Score {
+			if !a.CreatedAt.Equal(b.CreatedAt) {
+				return a.CreatedAt.After(b.CreatedAt)
+			}
+			return a.ID > b.ID
+		}
+		return a.Score > b.Score
+	})
+
+	if q.Offset >= len(rows) {
+		return result, nil
+	}
+	for _, row := range rows[q.Offset:min(len(rows), q.Offset+q.Limit)] {
+		result.Hits = append(result.Hits, search.Hit{
+			Type:       row.Type,
+			ID:         row.ID,
+			Score:      row.Score,
+			Title:      search.Highlight(row.Title, text, 0),
+			Snippet:    search.Highlight(row.Body, text, search.DefaultSnippetRunes),
+			AuthorID:   row.AuthorID,
+			TargetType: row.TargetType,
+			TargetID:   row.TargetID,
+			CreatedAt:  row.CreatedAt,
+		})
+	}
+
+	return result, nil
+}
+
+// SearchRepository 重建索引时按 id 分批读数据，以及搜索结果回表确认还能看
+type SearchRepository interface {
+	ListPosts(ctx context.Context, afterID uint, limit int) ([]model.Post, error)
+	ListComments(ctx context.Context, afterID uint, limit int) ([]model.Comment, error)
+	ListUsers(ctx context.Context, afterID uint, limit int) ([]model.User, error)
+	VisibleIDs(ctx context.Context, docType string, ids []uint) (map[uint]bool, error)
+}
+
+type searchRepo struct {
+	db *gorm.DB
+}
+
+func NewSearchRepo(db *gorm.DB) SearchRepository {
+	return &searchRepo{db: db}
+}
+
+func (r *searchRepo) ListPosts(ctx context.Context, afterID uint, limit int) ([]model.Post, error) {
+	var posts []model.Post
+	err := r.db.WithContext(ctx).
+		Select("id", "author_id", "title", "content", "content_html", "render_version", "status", "is_deleted", "created_at").
+		Where("id > ? AND is_deleted = 0 AND status = 0", afterID).
+		Order("id asc").
+		Limit(limit).
+		Find(&posts).Error
+	return posts, err
+}
+
+// visibleComments 限定为能被搜到的评论：自己和每一级父评论都没删，最上面挂的帖子已发布未删除。
+// 评论最多 model.CommentMaxDepth 层，父评论链按层数展开成固定的几次自连接，链断在哪一层，CASE 都得到 NULL、连不上帖子
+func visibleComments(db *gorm.DB) *gorm.DB {
+	root := "CASE WHEN comments.target_type <> 3 THEN comments.target_id"
+	child := "comments"
+	for depth := 1; depth < model.CommentMaxDepth; depth++ {
+		parent := fmt.Sprintf("parent%d", depth)
+		db = db.Joins(fmt.Sprintf("LEFT JOIN comments %[1]s ON %[2]s.target_type = 3 AND %[1]s.id = %[2]s.target_id AND %[1]s.is_deleted = 0 AND %[1]s.deleted_at IS NULL", parent, child))
+		root += fmt.Sprintf(" WHEN %[1]s.target_type <> 3 THEN %[1]s.target_id", parent)
+		child = parent
+	}
+	root += " END"
+
+	return db.
+		Joins("JOIN posts ON posts.id = " + root + " AND posts.is_deleted = 0 AND posts.status = 0 AND posts.deleted_at IS NULL").
+		Where("comments.is_deleted = 0")
+}
+
+func (r *searchRepo) ListComments(ctx context.Context, afterID uint, limit int) ([]model.Comment, error) {
+	var comments []model.Comment
+	err := visibleComments(r.db.WithContext(ctx).Model(&model.Comment{})).
+		Where("comments.id > ?", afterID).
+		Order("comments.id asc").
+		Limit(limit).
+		Find(&comments).Error
+	return comments, err
+}
+
+func (r *searchRepo) ListUsers(ctx context.Context, afterID uint, limit int) ([]model.User, error) {
+	var users []model.User
+	err := r.db.WithContext(ctx).
+		Select("id", "username", "profile", "created_at").
+		Where("id > ?", afterID).
+		Order("id asc").
+		Limit(limit).
+		Find(&users).Error
+	return users, err
+}
+
+// VisibleIDs ids 里现在还能被搜到的：帖子要已发布未删除，评论见 visibleComments，用户未注销
+func (r *searchRepo) VisibleIDs(ctx context.Context, docType string, ids []uint) (map[uint]bool, error) {
+	visible := make(map[uint]bool, len(ids))
+	if len(ids) == 0 {
+		return visible, nil
+	}
+
+	db := r.db.WithContext(ctx)
+	switch docType {
+	case search.TypePost:
+		db = db.Model(&model.Post{}).Where("id IN ? AND is_deleted = 0 AND status = 0", ids)
+	case search.TypeComment:
+		db = visibleComments(db.Model(&model.Comment{})).Where("comments.id IN ?", ids)
+	case search.TypeUser:
+		db = db.Model(&model.User{}).Where("id IN ?", ids)
+	default:
+		return visible, nil
+	}
+
+	var found []uint
+	if err := db.Pluck(searchTables[docType]+".id", &found).Error; err != nil {
+		return nil, err
+	}
+	for _, id := range found {
+		visible[id] = true
+	}
+
+	return visible, nil
+}
@@ -0,0 +1,112 @@
+package repository
+
+import (
+	"context"
+	"lesson10/internal/model"
+	"lesson10/internal/pkg/search"
+	"reflect"
+	"testing"
+
+	"gorm.io/gorm"
+)
+
+func createComment(t *testing.T, db *gorm.DB, targetType model.CommentTargetType, targetID uint, depth uint8) uint {
+	t.Helper()
+
+	comment := model.Comment{TargetType: targetType, TargetID: targetID, AuthorID: 1, Content: "c", Depth: depth}
+	if err := db.Create(&comment).Error; err != nil {
+		t.Fatal(err)
+	}
+	return comment.ID
+}
+
+// TestSearchCommentVisibility 评论挂在已删除、草稿帖子下，或者某一级父评论被删掉，都不能再被搜到
+func TestSearchCommentVisibility(t *testing.T) {
+	db := newTestDB(t, &model.User{}, &model.Post{}, &model.Comment{})
+	ctx := context.Background()
+
+	posts := map[string]*model.Post{
+		"published": {AuthorID: 1, Title: "p", Content: "p"},
+		"draft":     {AuthorID: 1, Title: "d", Content: "d", Status: 1},
+		"deleted":   {AuthorID: 1, Title: "x", Content: "x", IsDeleted: 1},
+	}
+	for _, p := range posts {
+		if err := db.Create(p).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	root := createComment(t, db, model.CommentOnPost, posts["published"].ID, 1)
+	question := createComment(t, db, model.CommentOnQuestion, posts["published"].ID, 1)
+	onDraft := createComment(t, db, model.CommentOnPost, posts["draft"].ID, 1)
+	onDeleted := createComment(t, db, model.CommentOnPost, posts["deleted"].ID, 1)
+	replyOnDeleted := createComment(t, db, model.CommentOnComment, onDeleted, 2)
+
+	// 最深的一条回复沿着父评论链一直找到帖子
+	chain := []uint{root}
+	for depth := uint8(2); depth <= model.CommentMaxDepth; depth++ {
+		chain = append(chain, createComment(t, db, model.CommentOnComment, chain[len(chain)-1], depth))
+	}
+	deepest := chain[len(chain)-1]
+
+	// 中间一级被删了，下面的回复即使 is_deleted 还是 0 也不可见（级联删除之前留下的老数据）
+	brokenParent := createComment(t, db, model.CommentOnComment, root, 2)
+	orphan := createComment(t, db, model.CommentOnComment, brokenParent, 3)
+	if err := db.Model(&model.Comment{}).Where("id = ?", brokenParent).Update("is_deleted", 1).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	repo := NewSearchRepo(db)
+	all := []uint{root, question, onDraft, onDeleted, replyOnDeleted, deepest, brokenParent, orphan}
+	visible, err := repo.VisibleIDs(ctx, search.TypeComment, all)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[uint]bool{root: true, question: true, deepest: true}
+	if !reflect.DeepEqual(visible, want) {
+		t.Fatalf("visible = %v, want %v", visible, want)
+	}
+
+	comments, err := repo.ListComments(ctx, 0, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	listed := make([]uint, 0, len(comments))
+	for _, c := range comments {
+		if c.Content != "c" || c.AuthorID != 1 {
+			t.Fatalf("comment not fully loaded: %+v", c)
+		}
+		listed = append(listed, c.ID)
+	}
+	wantListed := append([]uint{root, question}, chain[1:]...)
+	if !reflect.DeepEqual(listed, wantListed) {
+		t.Fatalf("listed = %v, want %v", listed, wantListed)
+	}
+}
+
+func TestDeleteSubCommentsCascades(t *testing.T) {
+	db := newTestDB(t, &model.Comment{})
+	ctx := context.Background()
+
+	root := createComment(t, db, model.CommentOnPost, 1, 1)
+	reply := createComment(t, db, model.CommentOnComment, root, 2)
+	nested := createComment(t, db, model.CommentOnComment, reply, 3)
+	sibling := createComment(t, db, model.CommentOnComment, root, 2)
+
+	// 删二级评论时它下面的回复一起删，兄弟评论不受影响
+	deleted, err := NewCommentRepo(db).DeleteSubComments(ctx, reply)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(deleted, []uint{nested}) {
+		t.Fatalf("deleted = %v", deleted)
+	}
+
+	var remaining []uint
+	if err := db.Model(&model.Comment{}).Where("is_deleted = 0").Order("id").Pluck("id", &remaining).Error; err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(remaining, []uint{root, reply, sibling}) {
+		t.Fatalf("remaining = %v", remaining)
+	}
+}
@@ -0,0 +1,108 @@
+package repository
+
+import (
+	"context"
+	"lesson10/internal/dto"
+	"lesson10/internal/model"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type StatsRepository interface {
+	DailyActiveUsers(ctx context.Context, from, to time.Time) ([]dto.DailyCount, error)
+	DailyRegistrations(ctx context.Context, from, to time.Time) ([]dto.DailyCount, error)
+	DailyPosts(ctx context.Context, from, to time.Time) ([]dto.DailyCount, error)
+	DailyComments(ctx context.Context, from, to time.Time) ([]dto.DailyCount, error)
+	DailyReactions(ctx context.Context, from, to time.Time) ([]dto.DailyCount, error)
+	TopAuthors(ctx context.Context, from, to time.Time, limit int) ([]dto.TopAuthorItem, error)
+	SecurityEventBreakdown(ctx context.Context, from, to time.Time) ([]dto.SecurityEventCount, error)
+	MarkActive(ctx context.Context, userID int64, day time.Time) error
+}
+
+type statsRepo struct {
+	db *gorm.DB
+}
+
+func NewStatsRepo(db *gorm.DB) StatsRepository {
+	return &statsRepo{db: db}
+}
+
+// dailyCount 按天聚合，column 为时间列，distinct 非空时统计去重后的数量
+func (r *statsRepo) dailyCount(ctx context.Context, table, column, distinct, extraWhere string, from, to time.Time) ([]dto.DailyCount, error) {
+	countExpr := "COUNT(*)"
+	if distinct != "" {
+		countExpr = "COUNT(DISTINCT " + distinct + ")"
+	}
+
+	query := r.db.WithContext(ctx).
+		Table(table).
+		Select("DATE_FORMAT("+column+", '%Y-%m-%d') AS day, "+countExpr+" AS count").
+		Where(column+" >= ? AND "+column+" < ?", from, to)
+	if extraWhere != "" {
+		query = query.Where(extraWhere)
+	}
+
+	var rows []dto.DailyCount
+	err := query.Group("day").Order("day ASC").Scan(&rows).Error
+	return rows, err
+}
+
+func (r *statsRepo) DailyActiveUsers(ctx context.Context, from, to time.Time) ([]dto.DailyCount, error) {
+	return r.dailyCount(ctx, "daily_active_users", "day", "", "", from, to)
+}
+
+// MarkActive 同一天重复记录会被主键去重
+func (r *statsRepo) MarkActive(ctx context.Context, userID int64, day time.Time) error {
+	return r.db.WithContext(ctx).
+		Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&model.DailyActiveUser{Day: day, UserID: userID}).Error
+}
+
+func (r *statsRepo) DailyRegistrations(ctx context.Context, from, to time.Time) ([]dto.DailyCount, error) {
+	return r.dailyCount(ctx, "users", "created_at", "", "deleted_at IS NULL", from, to)
+}
+
+func (r *statsRepo) DailyPosts(ctx context.Context, from, to time.Time) ([]dto.DailyCount, error) {
+	return r.dailyCount(ctx, "posts", "created_at", "", "is_deleted = 0 AND status = 0", from, to)
+}
+
+func (r *statsRepo) DailyComments(ctx context.Context, from, to time.Time) ([]dto.DailyCount, error) {
+	return r.dailyCount(ctx, "comments", "created_at", "", "is_deleted = 0", from, to)
+}
+
+func (r *statsRepo) DailyReactions(ctx context.Context, from, to time.Time) ([]dto.DailyCount, error) {
+	return r.dailyCount(ctx, "reactions", "created_at", "", "", from, to)
+}
+
+func (r *statsRepo) TopAuthors(ctx context.Context, from, to time.Time, limit int) ([]dto.TopAuthorItem, error) {
+	var rows []dto.TopAuthorItem
+	err := r.db.WithContext(ctx).
+		Table("posts p").
+		Select(`
+			p.author_id,
+			u.username AS author_name,
+			COUNT(*) AS post_count,
+			COALESCE(SUM(p.like_count), 0) AS like_count
+		`).
+		Joins("LEFT JOIN users u ON p.author_id = u.id").
+		Where("p.is_deleted = 0 AND p.status = 0 AND p.created_at >= ? AND p.created_at < ?", from, to).
+		Group("p.author_id, u.username").
+		Order("post_count DESC, like_count DESC, p.author_id ASC").
+		Limit(limit).
+		Scan(&rows).Error
+	return rows, err
+}
+
+func (r *statsRepo) SecurityEventBreakdown(ctx context.Context, from, to time.Time) ([]dto.SecurityEventCount, error) {
+	var rows []dto.SecurityEventCount
+	err := r.db.WithContext(ctx).
+		Table("security_events").
+		Select("event_type, COUNT(*) AS count, COUNT(DISTINCT user_id) AS user_count").
+		Where("created_at >= ? AND created_at < ?", from, to).
+		Group("event_type").
+		Order("count DESC").
+		Scan(&rows).Error
+	return rows, err
+}
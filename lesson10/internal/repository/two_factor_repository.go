@@ -0,0 +1,131 @@
+package repository
+
+import (
+	"context"
+	"lesson10/internal/model"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type TwoFactorRepository interface {
+	WithTx(tx *gorm.DB) TwoFactorRepository
+
+	GetTOTPByUserID(ctx context.Context, userID int64) (*model.UserTOTP, error)
+	GetTOTPByUserIDForUpdate(ctx context.Context, userID int64) (*model.UserTOTP, error)
+	SaveTOTP(ctx context.Context, totp *model.UserTOTP) error
+	DeleteTOTP(ctx context.Context, userID int64) error
+
+	ReplaceRecoveryCodes(ctx context.Context, userID int64, codes []model.TOTPRecoveryCode) error
+	GetRecoveryCodeForUpdate(ctx context.Context, userID int64, codeHash string) (*model.TOTPRecoveryCode, error)
+	MarkRecoveryCodeUsed(ctx context.Context, id int64, usedAt time.Time) error
+	CountUnusedRecoveryCodes(ctx context.Context, userID int64) (int64, error)
+	DeleteRecoveryCodes(ctx context.Context, userID int64) error
+
+	CreateChallenge(ctx context.Context, challenge *model.LoginChallenge) error
+	UpdateChallenge(ctx context.Context, challenge *model.LoginChallenge) error
+	GetChallengeByTokenHashForUpdate(ctx context.Context, tokenHash string) (*model.LoginChallenge, error)
+}
+
+type twoFactorRepo struct {
+	db *gorm.DB
+}
+
+func NewTwoFactorRepo(db *gorm.DB) TwoFactorRepository {
+	return &twoFactorRepo{db: db}
+}
+
+func (r *twoFactorRepo) WithTx(tx *gorm.DB) TwoFactorRepository {
+	return &twoFactorRepo{db: tx}
+}
+
+func (r *twoFactorRepo) GetTOTPByUserID(ctx context.Context, userID int64) (*model.UserTOTP, error) {
+	var totp model.UserTOTP
+	if err := r.db.WithContext(ctx).Where("user_id = ?", userID).First(&totp).Error; err != nil {
+		return nil, err
+	}
+
+	return &totp, nil
+}
+
+func (r *twoFactorRepo) GetTOTPByUserIDForUpdate(ctx context.Context, userID int64) (*model.UserTOTP, error) {
+	var totp model.UserTOTP
+	if err := r.db.WithContext(ctx).
+		Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("user_id = ?", userID).
+		First(&totp).Error; err != nil {
+		return nil, err
+	}
+
+	return &totp, nil
+}
+
+func (r *twoFactorRepo) SaveTOTP(ctx context.Context, totp *model.UserTOTP) error {
+	return r.db.WithContext(ctx).Save(totp).Error
+}
+
+func (r *twoFactorRepo) DeleteTOTP(ctx context.Context, userID int64) error {
+	return r.db.WithContext(ctx).Where("user_id = ?", userID).Delete(&model.UserTOTP{}).Error
+}
+
+// ReplaceRecoveryCodes 重新生成恢复码时旧的一批全部作废
+func (r *twoFactorRepo) ReplaceRecoveryCodes(ctx context.Context, userID int64, codes []model.TOTPRecoveryCode) error {
+	if err := r.DeleteRecoveryCodes(ctx, userID); err != nil {
+		return err
+	}
+
+	return r.db.WithContext(ctx).Create(&codes).Error
+}
+
+func (r *twoFactorRepo) GetRecoveryCodeForUpdate(ctx context.Context, userID int64, codeHash string) (*model.TOTPRecoveryCode, error) {
+	var code model.TOTPRecoveryCode
+	if err := r.db.WithContext(ctx).
+		Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("user_id = ? AND code_hash = ?", userID, codeHash).
+		First(&code).Error; err != nil {
+		return nil, err
+	}
+
+	return &code, nil
+}
+
+func (r *twoFactorRepo) MarkRecoveryCodeUsed(ctx context.Context, id int64, usedAt time.Time) error {
+	return r.db.WithContext(ctx).
+		Model(&model.TOTPRecoveryCode{}).
+		Where("id = ?", id).
+		Update("used_at", usedAt).Error
+}
+
+func (r *twoFactorRepo) CountUnusedRecoveryCodes(ctx context.Context, userID int64) (int64, error) {
+	var count int64
+	err := r.db.WithContext(ctx).
+		Model(&model.TOTPRecoveryCode{}).
+		Where("user_id = ? AND used_at IS NULL", userID).
+		Count(&count).Error
+	return count, err
+}
+
+func (r *twoFactorRepo) DeleteRecoveryCodes(ctx context.Context, userID int64) error {
+	return r.db.WithContext(ctx).Where("user_id = ?", userID).Delete(&model.TOTPRecoveryCode{}).Error
+}
+
+func (r *twoFactorRepo) CreateChallenge(ctx context.Context, challenge *model.LoginChallenge) error {
+	return r.db.WithContext(ctx).Create(challenge).Error
+}
+
+func (r *twoFactorRepo) UpdateChallenge(ctx context.Context, challenge *model.LoginChallenge) error {
+	return r.db.WithContext(ctx).Save(challenge).Error
+}
+
+func (r *twoFactorRepo) GetChallengeByTokenHashForUpdate(ctx context.Context, tokenHash string) (*model.LoginChallenge, error) {
+	var challenge model.LoginChallenge
+	if err := r.db.WithContext(ctx).
+		Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("token_hash = ?", tokenHash).
+		First(&challenge).Error; err != nil {
+		return nil, err
+	}
+
+	return &challenge, nil
+}
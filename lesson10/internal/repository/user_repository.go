@@ -4,6 +4,7 @@ import (
 	"context"
 	"lesson10/internal/dto"
 	"lesson10/internal/model"
+	"time"
 
 	"gorm.io/gorm"
 )
@@ -24,6 +25,12 @@ type UserRepository interface {
 	FindUserByUsername(ctx context.Context, username string) (*model.User, error)
 	FindUserForTokenTx(ctx context.Context, tx *gorm.DB, userID uint) (*model.User, error)
 	RefreshTokenVersionTx(ctx context.Context, tx *gorm.DB, userID uint, currentVersion int) (bool, error)
+	ExistsByEmail(ctx context.Context, email string) (bool, error)
+	FindUserByEmail(ctx context.Context, email string) (*model.User, error)
+	UpdateEmail(ctx context.Context, userID uint, email string) error
+	MarkEmailVerifiedTx(ctx context.Context, tx *gorm.DB, userID uint, email string, verifiedAt time.Time) (bool, error)
+	ChangePassWordTx(ctx context.Context, tx *gorm.DB, id uint, newHash string) error
+	CreateUserTx(ctx context.Context, tx *gorm.DB, user *model.User) error
 }
 
 type userRepo struct {
@@ -227,3 +234,59 @@ func (r *userRepo) RefreshTokenVersionTx(ctx context.Context, tx *gorm.DB, userI
 
 	return res.RowsAffected > 0, nil
 }
+
+func (r *userRepo) ExistsByEmail(ctx context.Context, email string) (bool, error) {
+	var count int64
+	err := r.db.WithContext(ctx).
+		Model(&model.User{}).
+		Where("email = ?", email).
+		Count(&count).Error
+	return count > 0, err
+}
+
+func (r *userRepo) FindUserByEmail(ctx context.Context, email string) (*model.User, error) {
+	var user model.User
+	err := r.db.WithContext(ctx).
+		Where("email = ?", email).
+		First(&user).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+// UpdateEmail 换绑邮箱后需要重新验证
+func (r *userRepo) UpdateEmail(ctx context.Context, userID uint, email string) error {
+	return r.db.WithContext(ctx).Model(&model.User{}).
+		Where("id = ?", userID).
+		Updates(map[string]any{
+			"email":             email,
+			"email_verified_at": nil,
+		}).Error
+}
+
+// MarkEmailVerifiedTx 只有邮箱没被换掉时才标记已验证
+func (r *userRepo) MarkEmailVerifiedTx(ctx context.Context, tx *gorm.DB, userID uint, email string, verifiedAt time.Time) (bool, error) {
+	res := tx.WithContext(ctx).Model(&model.User{}).
+		Where("id = ? AND email = ?", userID, email).
+		Update("email_verified_at", verifiedAt)
+	if res.Error != nil {
+		return false, res.Error
+	}
+
+	return res.RowsAffected > 0, nil
+}
+
+func (r *userRepo) ChangePassWordTx(ctx context.Context, tx *gorm.DB, id uint, newHash string) error {
+	return tx.WithContext(ctx).Model(&model.User{}).
+		Where("id = ?", id).
+		Updates(map[string]any{
+			"password_hash": newHash,
+			"token_version": gorm.Expr("token_version + 1"),
+		}).Error
+}
+
+func (r *userRepo) CreateUserTx(ctx context.Context, tx *gorm.DB, user *model.User) error {
+	return tx.WithContext(ctx).Create(user).Error
+}
@@ -0,0 +1,239 @@
+package router
+
+import (
+	"lesson10/internal/dto"
+	"lesson10/internal/pkg/openapi"
+	"lesson10/internal/pkg/token"
+	"net/http"
+	"reflect"
+
+	"gorm.io/gorm"
+)
+
+// 这里的路由表要和 NewRouter 注册的路由一一对应，openapi_test 会对比两边，
+// 新增或删除接口时两处一起改。响应和 handler 用同一个 dto 结构体，字段改了文档跟着变
+
+type idParam struct {
+	ID uint `uri:"id"`
+}
+
+type parentIDParam struct {
+	ParentID uint `uri:"parent_id"`
+}
+
+type pageQuery = struct {
+	Page int `form:"page" default:"1"`
+	Size int `form:"size" default:"20" binding:"max=50"`
+}
+
+var apiRoutes = []openapi.Route{
+	// 健康检查与监控
+	{Method: http.MethodGet, Path: "/healthz", ID: "healthz", Tag: "健康检查与监控", Summary: "存活检查",
+		Raw: "application/json", Response: dto.Liveness{}, PlainErrors: true},
+	{Method: http.MethodGet, Path: "/readyz", ID: "readyz", Tag: "健康检查与监控", Summary: "就绪检查",
+		Description: "依赖不可用或正在停机时返回 503，响应结构相同",
+		Raw:         "application/json", Response: dto.Readiness{}, PlainErrors: true},
+	{Method: http.MethodGet, Path: "/metrics", ID: "metrics", Tag: "健康检查与监控", Summary: "监控指标",
+		Description: "Prometheus 文本格式，只在 METRICS_ENABLED 时注册；配置了 METRICS_TOKEN 时需要 Authorization: Bearer <token>",
+		Raw:         "text/plain", PlainErrors: true},
+
+	// 文档、公钥和文件
+	{Method: http.MethodGet, Path: "/openapi.json", ID: "openapiSpec", Tag: "其他", Summary: "OpenAPI 文档",
+		Raw: "application/json", Response: map[string]any{}, PlainErrors: true},
+	{Method: http.MethodGet, Path: "/docs", ID: "swaggerUI", Tag: "其他", Summary: "Swagger UI",
+		Raw: "text/html", PlainErrors: true},
+	{Method: http.MethodGet, Path: "/.well-known/jwks.json", ID: "jwks", Tag: "其他", Summary: "JWT 验签公钥",
+		Raw: "application/json", Response: token.JWKS{}, PlainErrors: true},
+	{Method: http.MethodGet, Path: "/static/*filepath", ID: "staticFile", Tag: "其他", Summary: "静态资源",
+		Description: "本地存储时上传的公开图片也从这里访问",
+		Raw:         "application/octet-stream", PlainErrors: true},
+
+	// 认证与用户
+	{Method: http.MethodPost, Path: "/register", ID: "register", Tag: "认证与用户", Summary: "注册",
+		Body: dto.RegisterRequest{}, Response: dto.RegisterResp{}},
+	{Method: http.MethodPost, Path: "/login", ID: "login", Tag: "认证与用户", Summary: "登录",
+		Body: dto.LoginRequest{}, Response: dto.LoginResp{}},
+	{Method: http.MethodPost, Path: "/login/2fa", ID: "loginTwoFactor", Tag: "认证与用户", Summary: "两步验证登录",
+		Body: dto.TwoFactorLoginRequest{}, Response: dto.LoginResp{}},
+	{Method: http.MethodPost, Path: "/refresh", ID: "refresh", Tag: "认证与用户", Summary: "刷新令牌",
+		Auth: openapi.AuthOptional, Body: dto.RefreshRequest{}, Response: dto.TokenPair{}},
+	{Method: http.MethodPost, Path: "/logout", ID: "logout", Tag: "认证与用户", Summary: "退出登录",
+		Auth: openapi.AuthRequired, Response: dto.OKResp{}},
+	{Method: http.MethodPost, Path: "/logout-all", ID: "logoutAll", Tag: "认证与用户", Summary: "退出全部设备",
+		Auth: openapi.AuthRequired, Body: dto.LogoutAllRequest{}, Response: dto.OKResp{}},
+	{Method: http.MethodPut, Path: "/change_pass", ID: "changePassword", Tag: "认证与用户", Summary: "修改密码",
+		Auth: openapi.AuthRequired, Body: dto.ChangePassRequest{}, Response: dto.ChangePassResp{}},
+	{Method: http.MethodPut, Path: "/profile", ID: "updateProfile", Tag: "认证与用户", Summary: "更新个人简介",
+		Auth: openapi.AuthRequired, Body: dto.UpdateProfileRequest{}, Response: dto.OKResp{}},
+	{Method: http.MethodPost, Path: "/avatar", ID: "uploadAvatar", Tag: "认证与用户", Summary: "上传头像",
+		Auth: openapi.AuthRequired, Upload: "avatar", Response: dto.AvatarUploadResp{}},
+
+	// 帖子
+	{Method: http.MethodPost, Path: "/posts", ID: "createPost", Tag: "帖子", Summary: "发布帖子",
+		Auth: openapi.AuthRequired, Body: dto.CreatePostRequest{}, Response: dto.CreatePostResp{}},
+	{Method: http.MethodGet, Path: "/posts", ID: "listPosts", Tag: "帖子", Summary: "帖子列表",
+		Query: dto.ListPostsQuery{}, Response: dto.PostListResp{}},
+	{Method: http.MethodGet, Path: "/posts/:id", ID: "getPost", Tag: "帖子", Summary: "帖子详情",
+		Auth: openapi.AuthOptional, Params: idParam{}, Response: dto.PostDetailResp{}},
+	{Method: http.MethodPut, Path: "/posts/:id", ID: "updatePost", Tag: "帖子", Summary: "更新帖子",
+		Auth: openapi.AuthRequired, Params: idParam{}, Body: dto.UpdatePostRequest{}, Response: dto.UpdatePostResp{}},
+	{Method: http.MethodDelete, Path: "/posts/:id", ID: "deletePost", Tag: "帖子", Summary: "删除帖子",
+		Auth: openapi.AuthRequired, Params: idParam{}, Response: dto.DeletePostResp{}},
+
+	// 搜索
+	{Method: http.MethodGet, Path: "/search", ID: "search", Tag: "搜索", Summary: "全文搜索",
+		Query: dto.SearchQuery{}, Response: dto.SearchResp{}},
+
+	// 评论
+	{Method: http.MethodPost, Path: "/comments", ID: "createComment", Tag: "评论", Summary: "发表评论",
+		Auth: openapi.AuthRequired, Body: dto.PostCommentRequest{}, Response: dto.CreateCommentResp{}},
+	{Method: http.MethodGet, Path: "/posts/comments", ID: "listComments", Tag: "评论", Summary: "获取一级评论",
+		Query: dto.GetCommentsReq{}, Response: dto.GetCommentsResp{}},
+	{Method: http.MethodGet, Path: "/comments/:parent_id/replies", ID: "listReplies", Tag: "评论", Summary: "获取评论回复",
+		Params: parentIDParam{}, Response: dto.ReplyListResp{}},
+	{Method: http.MethodDelete, Path: "/comments/:id", ID: "deleteComment", Tag: "评论", Summary: "删除评论",
+		Auth: openapi.AuthRequired, Params: idParam{}},
+
+	// 关注
+	{Method: http.MethodPost, Path: "/follow/:id", ID: "follow", Tag: "关注", Summary: "关注用户",
+		Auth: openapi.AuthRequired, Params: idParam{}},
+	{Method: http.MethodDelete, Path: "/follow/:id", ID: "unfollow", Tag: "关注", Summary: "取消关注",
+		Auth: openapi.AuthRequired, Params: idParam{}},
+	{Method: http.MethodGet, Path: "/users/followers/:id", ID: "listFollowers", Tag: "关注", Summary: "粉丝列表",
+		Params: idParam{}, Query: pageQuery{}, Response: dto.FollowListResp{}},
+	{Method: http.MethodGet, Path: "/users/following/:id", ID: "listFollowing", Tag: "关注", Summary: "关注列表",
+		Params: idParam{}, Query: pageQuery{}, Response: dto.FollowListResp{}},
+
+	// 用户资料
+	{Method: http.MethodGet, Path: "/user/:id", ID: "getUser", Tag: "用户资料", Summary: "获取用户公开信息",
+		Auth: openapi.AuthOptional, Params: idParam{}, Query: struct {
+			Page int `form:"page" default:"1"`
+		}{}, Response: dto.UserPublicInfo{}},
+
+	// 上传
+	{Method: http.MethodPost, Path: "/upload/article-image", ID: "uploadArticleImage", Tag: "上传", Summary: "上传文章图片",
+		Auth: openapi.AuthRequired, Upload: "image", Response: dto.ArticleImageUploadResp{}},
+	{Method: http.MethodGet, Path: "/upload/quota", ID: "getUploadQuota", Tag: "上传", Summary: "查询存储配额",
+		Auth: openapi.AuthRequired, Response: dto.UploadQuota{}},
+	{Method: http.MethodGet, Path: "/images/:id/signed-url", ID: "getSignedImageURL", Tag: "上传", Summary: "获取图片临时地址",
+		Auth: openapi.AuthRequired, Params: idParam{}, Query: struct {
+			Variant string `form:"variant"`
+		}{}, Response: dto.SignedImageURL{}},
+
+	// 点赞 / 收藏
+	{Method: http.MethodPost, Path: "/reactions", ID: "toggleReaction", Tag: "点赞 / 收藏", Summary: "点赞 / 取消点赞",
+		Auth: openapi.AuthRequired, Body: dto.LikeRequest{}, Response: dto.ReactionStatusResp{}},
+	{Method: http.MethodPost, Path: "/favorites", ID: "toggleFavorite", Tag: "点赞 / 收藏", Summary: "收藏 / 取消收藏",
+		Auth: openapi.AuthRequired, Body: dto.FavorRequest{}, Response: dto.FavoriteStatusResp{}},
+	{Method: http.MethodGet, Path: "/favorites", ID: "listFavorites", Tag: "点赞 / 收藏", Summary: "收藏列表",
+		Auth: openapi.AuthRequired, Query: pageQuery{}, Response: dto.FavoriteListResp{}},
+	{Method: http.MethodGet, Path: "/draft", ID: "listDrafts", Tag: "点赞 / 收藏", Summary: "草稿列表",
+		Auth: openapi.AuthRequired, Query: pageQuery{}, Response: dto.DraftListResp{}},
+
+	// 通知
+	{Method: http.MethodGet, Path: "/notifications", ID: "listNotifications", Tag: "通知", Summary: "通知列表",
+		Auth: openapi.AuthRequired, Query: struct {
+			pageQuery
+			UnreadOnly string `form:"unread_only" binding:"oneof=0 1" default:"0"`
+		}{}, Response: dto.NotificationListResp{}},
+	{Method: http.MethodGet, Path: "/notifications/count", ID: "countUnreadNotifications", Tag: "通知", Summary: "未读数",
+		Auth: openapi.AuthRequired, Response: dto.UnreadCountResp{}},
+	{Method: http.MethodPost, Path: "/notifications/read-all", ID: "markAllNotificationsRead", Tag: "通知", Summary: "全部标为已读",
+		Auth: openapi.AuthRequired},
+
+	// 账号数据与注销
+	{Method: http.MethodPost, Path: "/account/exports", ID: "requestDataExport", Tag: "账号数据与注销", Summary: "申请数据导出",
+		Auth: openapi.AuthRequired, Status: http.StatusAccepted, Response: dto.DataExportInfo{}},
+	{Method: http.MethodGet, Path: "/account/exports", ID: "listDataExports", Tag: "账号数据与注销", Summary: "导出记录",
+		Auth: openapi.AuthRequired, Response: dto.DataExportListResp{}},
+	{Method: http.MethodGet, Path: "/account/exports/download", ID: "downloadDataExport", Tag: "账号数据与注销", Summary: "下载导出文件",
+		Query: struct {
+			Token string `form:"token" binding:"required"`
+		}{}, Raw: "application/zip"},
+	{Method: http.MethodPost, Path: "/account/deletion", ID: "requestAccountDeletion", Tag: "账号数据与注销", Summary: "申请注销账号",
+		Auth: openapi.AuthRequired, Body: dto.DeleteAccountRequest{}, Response: dto.AccountDeletionInfo{}},
+	{Method: http.MethodGet, Path: "/account/deletion", ID: "getAccountDeletion", Tag: "账号数据与注销", Summary: "查询注销状态",
+		Auth: openapi.AuthRequired, Response: dto.AccountDeletionInfo{}},
+	{Method: http.MethodDelete, Path: "/account/deletion", ID: "cancelAccountDeletion", Tag: "账号数据与注销", Summary: "撤销注销",
+		Auth: openapi.AuthRequired, Response: dto.OKResp{}},
+
+	// 邮箱与找回密码
+	{Method: http.MethodPost, Path: "/email/verify", ID: "verifyEmail", Tag: "邮箱与找回密码", Summary: "验证邮箱",
+		Body: dto.VerifyEmailRequest{}, Response: dto.OKResp{}},
+	{Method: http.MethodPost, Path: "/email/resend", ID: "resendVerificationEmail", Tag: "邮箱与找回密码", Summary: "重发验证邮件",
+		Auth: openapi.AuthRequired, Response: dto.OKResp{}},
+	{Method: http.MethodPut, Path: "/email", ID: "changeEmail", Tag: "邮箱与找回密码", Summary: "换绑邮箱",
+		Auth: openapi.AuthRequired, Body: dto.ChangeEmailRequest{}, Response: dto.OKResp{}},
+	{Method: http.MethodPost, Path: "/password/forgot", ID: "forgotPassword", Tag: "邮箱与找回密码", Summary: "忘记密码",
+		Body: dto.ForgotPasswordRequest{}, Response: dto.OKResp{}},
+	{Method: http.MethodPost, Path: "/password/reset", ID: "resetPassword", Tag: "邮箱与找回密码", Summary: "重置密码",
+		Body: dto.ResetPasswordRequest{}, Response: dto.OKResp{}},
+
+	// 两步验证
+	{Method: http.MethodGet, Path: "/2fa", ID: "getTwoFactorStatus", Tag: "两步验证（TOTP）", Summary: "查询状态",
+		Auth: openapi.AuthRequired, Response: dto.TwoFactorStatus{}},
+	{Method: http.MethodPost, Path: "/2fa/setup", ID: "setupTwoFactor", Tag: "两步验证（TOTP）", Summary: "生成密钥",
+		Auth: openapi.AuthRequired, Body: dto.TwoFactorSetupRequest{}, Response: dto.TwoFactorSetup{}},
+	{Method: http.MethodPost, Path: "/2fa/enable", ID: "enableTwoFactor", Tag: "两步验证（TOTP）", Summary: "确认开启",
+		Auth: openapi.AuthRequired, Body: dto.TwoFactorCodeRequest{}, Response: dto.RecoveryCodesResp{}},
+	{Method: http.MethodPost, Path: "/2fa/disable", ID: "disableTwoFactor", Tag: "两步验证（TOTP）", Summary: "关闭",
+		Auth: openapi.AuthRequired, Body: dto.TwoFactorDisableRequest{}, Response: dto.OKResp{}},
+	{Method: http.MethodPost, Path: "/2fa/recovery-codes", ID: "regenerateRecoveryCodes", Tag: "两步验证（TOTP）", Summary: "重新生成恢复码",
+		Auth: openapi.AuthRequired, Body: dto.TwoFactorDisableRequest{}, Response: dto.RecoveryCodesResp{}},
+
+	// 第三方登录
+	{Method: http.MethodGet, Path: "/oauth/providers", ID: "listOAuthProviders", Tag: "第三方登录（OIDC）", Summary: "provider 列表",
+		Response: dto.OAuthProvidersResp{}},
+	{Method: http.MethodGet, Path: "/oauth/:provider/login", ID: "beginOAuthLogin", Tag: "第三方登录（OIDC）", Summary: "发起登录",
+		Query: dto.OAuthBeginQuery{}, Response: dto.OAuthAuthorize{}},
+	{Method: http.MethodPost, Path: "/oauth/:provider/callback", ID: "oauthLoginCallback", Tag: "第三方登录（OIDC）", Summary: "登录回调",
+		Body: dto.OAuthCallbackRequest{}, Response: dto.LoginResp{}},
+	{Method: http.MethodGet, Path: "/oauth/:provider/link", ID: "beginOAuthLink", Tag: "第三方登录（OIDC）", Summary: "绑定外部账号",
+		Auth: openapi.AuthRequired, Response: dto.OAuthAuthorize{}},
+	{Method: http.MethodPost, Path: "/oauth/:provider/link/callback", ID: "oauthLinkCallback", Tag: "第三方登录（OIDC）", Summary: "绑定回调",
+		Auth: openapi.AuthRequired, Body: dto.OAuthCallbackRequest{}, Response: dto.IdentityInfo{}},
+	{Method: http.MethodGet, Path: "/oauth/identities", ID: "listIdentities", Tag: "第三方登录（OIDC）", Summary: "已绑定列表",
+		Auth: openapi.AuthRequired, Response: dto.IdentityListResp{}},
+	{Method: http.MethodDelete, Path: "/oauth/identities/:provider", ID: "unlinkIdentity", Tag: "第三方登录（OIDC）", Summary: "解绑",
+		Auth: openapi.AuthRequired, Response: dto.OKResp{}},
+
+	// 登录设备
+	{Method: http.MethodGet, Path: "/sessions", ID: "listSessions", Tag: "登录设备", Summary: "会话列表",
+		Auth: openapi.AuthRequired, Query: dto.ListSessionsQuery{}, Response: dto.SessionListResp{}},
+	{Method: http.MethodPut, Path: "/sessions/:session_id", ID: "renameSession", Tag: "登录设备", Summary: "重命名会话",
+		Auth: openapi.AuthRequired, Body: dto.RenameSessionRequest{}, Response: dto.OKResp{}},
+	{Method: http.MethodPost, Path: "/sessions/revoke", ID: "revokeSession", Tag: "登录设备", Summary: "下线会话",
+		Auth: openapi.AuthRequired, Body: dto.RevokeSessionRequest{}, Response: dto.OKResp{}},
+
+	// 安全事件
+	{Method: http.MethodGet, Path: "/security/events", ID: "listMySecurityEvents", Tag: "安全事件", Summary: "我的安全事件",
+		Auth: openapi.AuthRequired, Query: dto.SecurityEventQuery{}, Response: dto.SecurityEventList{}},
+
+	// 管理后台
+	{Method: http.MethodGet, Path: "/admin/stats", ID: "adminStats", Tag: "管理后台", Summary: "站点统计",
+		Auth: openapi.AuthAdmin, Query: dto.AdminStatsQuery{}, Response: dto.AdminStatsResp{}},
+	{Method: http.MethodGet, Path: "/admin/stats/export", ID: "exportAdminStats", Tag: "管理后台", Summary: "导出统计 CSV",
+		Auth: openapi.AuthAdmin, Query: dto.AdminStatsQuery{}, Raw: "text/csv"},
+	{Method: http.MethodPost, Path: "/admin/users/:id/unlock", ID: "adminUnlockUser", Tag: "管理后台", Summary: "解除账号锁定",
+		Auth: openapi.AuthAdmin, Params: idParam{}, Response: dto.OKResp{}},
+	{Method: http.MethodGet, Path: "/admin/security/events", ID: "adminListSecurityEvents", Tag: "管理后台", Summary: "安全事件查询",
+		Auth: openapi.AuthAdmin, Query: dto.SecurityEventQuery{}, Response: dto.SecurityEventList{}},
+	{Method: http.MethodGet, Path: "/admin/janitor", ID: "adminJanitorStats", Tag: "管理后台", Summary: "过期会话清理统计",
+		Auth: openapi.AuthAdmin, Response: dto.JanitorStats{}},
+	{Method: http.MethodPost, Path: "/admin/janitor/run", ID: "adminRunJanitor", Tag: "管理后台", Summary: "手动触发清理",
+		Description: "执行失败时返回 500 / 10501，data 里是这次的执行报告",
+		Auth:        openapi.AuthAdmin, Query: struct {
+			DryRun bool `form:"dry_run"`
+		}{}, Response: dto.JanitorReport{}},
+}
+
+// OpenAPI 按路由表生成文档，/openapi.json 和 cmd/openapi 共用
+func OpenAPI() *openapi.Document {
+	return openapi.Build(openapi.Info{
+		Title:       "Lesson10 论坛 API",
+		Version:     "1.0.0",
+		Description: "成功时 code 为 0、data 为业务数据；失败时 code 为业务码，message 按 Accept-Language 返回中文或英文。",
+	}, apiRoutes, map[reflect.Type]*openapi.Schema{
+		reflect.TypeFor[gorm.DeletedAt](): {Type: "string", Format: "date-time", Nullable: true},
+	})
+}
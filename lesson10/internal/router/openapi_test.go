@@ -0,0 +1,121 @@
+package router
+
+import (
+	"encoding/json"
+	"lesson10/internal/config"
+	"lesson10/internal/pkg/metrics"
+	"lesson10/internal/pkg/openapi"
+	"lesson10/internal/pkg/ratelimit"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// newTestRouter 只为了拿到注册的路由表，service 都传 nil，不会真的处理请求
+func newTestRouter(t *testing.T) *gin.Engine {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	return NewRouter(
+		config.ServerConfig{CORSOrigins: []string{"http://localhost:3000"}, StaticDir: t.TempDir()},
+		metrics.Config{Enabled: true},
+		nil, nil, nil, nil, nil, nil, nil, nil, nil, nil,
+		nil, nil, nil, nil, nil, nil, nil, nil,
+		nil, ratelimit.Policies{},
+	)
+}
+
+func TestOpenAPIMatchesRoutes(t *testing.T) {
+	r := newTestRouter(t)
+	doc := OpenAPI()
+
+	registered := map[string]bool{}
+	for _, route := range r.Routes() {
+		// r.Static 顺带注册的 HEAD
+		if route.Method == http.MethodHead {
+			continue
+		}
+
+		path := openapi.PathOf(route.Path)
+		registered[route.Method+" "+path] = true
+		if _, ok := doc.Paths[path][strings.ToLower(route.Method)]; !ok {
+			t.Errorf("%s %s is registered in NewRouter but missing from apiRoutes", route.Method, route.Path)
+		}
+	}
+
+	for path, item := range doc.Paths {
+		for method := range item {
+			if !registered[strings.ToUpper(method)+" "+path] {
+				t.Errorf("%s %s is documented in apiRoutes but not registered in NewRouter", strings.ToUpper(method), path)
+			}
+		}
+	}
+}
+
+// TestOpenAPIResponsesAreNamed 响应要用 handler 里实际返回的 dto 类型，匿名结构体写的文档和代码对不上也发现不了
+func TestOpenAPIResponsesAreNamed(t *testing.T) {
+	for _, route := range apiRoutes {
+		if route.Response == nil {
+			continue
+		}
+		if typ := reflect.TypeOf(route.Response); typ.Kind() == reflect.Struct && typ.Name() == "" {
+			t.Errorf("%s %s: response is an anonymous struct, define it in dto", route.Method, route.Path)
+		}
+	}
+}
+
+func TestOpenAPIRefsResolve(t *testing.T) {
+	w := httptest.NewRecorder()
+	newTestRouter(t).ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/openapi.json", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("GET /openapi.json: status %d", w.Code)
+	}
+
+	var spec map[string]any
+	if err := json.Unmarshal(w.Body.Bytes(), &spec); err != nil {
+		t.Fatalf("GET /openapi.json: %v", err)
+	}
+
+	var walk func(node any)
+	walk = func(node any) {
+		switch v := node.(type) {
+		case map[string]any:
+			if ref, ok := v["$ref"].(string); ok {
+				if resolve(spec, ref) == nil {
+					t.Errorf("unresolved $ref %q", ref)
+				}
+			}
+			for _, child := range v {
+				walk(child)
+			}
+		case []any:
+			for _, child := range v {
+				walk(child)
+			}
+		}
+	}
+	walk(spec)
+}
+
+// resolve 只处理文档内部的引用：#/components/schemas/Xxx
+func resolve(spec map[string]any, ref string) any {
+	path, ok := strings.CutPrefix(ref, "#/")
+	if !ok {
+		return nil
+	}
+
+	var node any = spec
+	for _, key := range strings.Split(path, "/") {
+		m, ok := node.(map[string]any)
+		if !ok {
+			return nil
+		}
+		node = m[key]
+	}
+
+	return node
+}
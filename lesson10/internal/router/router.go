@@ -1,16 +1,34 @@
 package router
 
 import (
+	"lesson10/internal/config"
 	"lesson10/internal/handler"
 	"lesson10/internal/middleware"
+	"lesson10/internal/pkg/metrics"
+	"lesson10/internal/pkg/ratelimit"
 	"lesson10/internal/service"
+	"net/http"
+	"path/filepath"
 	"time"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 )
 
-func InitRouter(
+// NewServer 只构建不监听，由调用方 ListenAndServe，退出时 Shutdown 等进行中的请求处理完
+func NewServer(cfg config.ServerConfig, handler http.Handler) *http.Server {
+	return &http.Server{
+		Addr:              cfg.Addr(),
+		Handler:           handler,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+}
+
+// NewRouter 注册全部路由，不监听端口，测试里可以直接挂到 httptest 上
+func NewRouter(
+	cfg config.ServerConfig,
+	metricsCfg metrics.Config,
+	healthService *service.HealthService,
 	authService *service.AuthService,
 	userService *service.UserService,
 	postService *service.PostService,
@@ -18,58 +36,101 @@ func InitRouter(
 	reactionService *service.ReactionService,
 	followService *service.FollowService,
 	favoriteService *service.FavoriteService,
-	notification *service.NotificationService) {
-	r := gin.Default()
+	notification *service.NotificationService,
+	adminService *service.AdminService,
+	accountService *service.AccountService,
+	emailService *service.EmailService,
+	twoFactorService *service.TwoFactorService,
+	oauthService *service.OAuthService,
+	securityService *service.SecurityService,
+	janitorService *service.JanitorService,
+	uploadService *service.UploadService,
+	searchService *service.SearchService,
+	limiter ratelimit.Limiter,
+	limits ratelimit.Policies) *gin.Engine {
+	r := gin.New()
+	// 顺序不能换：先生成 request_id，再开 span 记指标，panic 由最里层的 Recovery 转成 500
+	r.Use(middleware.RequestID(), middleware.Observe(), middleware.Errors(), middleware.Recovery())
 	r.Use(cors.New(cors.Config{
-		AllowOrigins:     []string{"http://localhost:3000"}, // 前端端口
+		AllowOrigins:     cfg.CORSOrigins, // 前端地址
 		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowHeaders:     []string{"Origin", "Content-Type", "Authorization", "Accept"},
-		ExposeHeaders:    []string{"Content-Length"},
+		AllowHeaders:     []string{"Origin", "Content-Type", "Authorization", "Accept", middleware.RequestIDHeader},
+		ExposeHeaders:    []string{"Content-Length", middleware.RequestIDHeader, "RateLimit-Policy", "RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset", "Retry-After"},
 		AllowCredentials: true,
 		MaxAge:           12 * time.Hour, // 预检缓存时间
 	}))
 
-	r.Static("/static", "./static")
+	// 探针和指标不限流、不走登录鉴权
+	r.GET("/healthz", handler.HealthzHandler())
+	r.GET("/readyz", handler.ReadyzHandler(healthService))
+	if metricsCfg.Enabled {
+		r.GET("/metrics", handler.MetricsHandler(metricsCfg.Token))
+	}
+
+	r.Static("/static", cfg.StaticDir)
+	r.GET("/.well-known/jwks.json", handler.JWKSHandler(authService))
+	r.GET("/openapi.json", handler.OpenAPIHandler(OpenAPI()))
+	r.GET("/docs", handler.SwaggerUIHandler("/openapi.json", "/static/swagger-ui", filepath.Join(cfg.StaticDir, "swagger-ui")))
+
+	limit := func(policy ratelimit.Policy) gin.HandlerFunc {
+		return middleware.RateLimit(limiter, policy)
+	}
 
 	public := r.Group("/")
-	public.Use(middleware.RateLimit())
+	public.Use(limit(limits.Default))
 	{
-		public.POST("/register", handler.RegisterHandler(userService))
-		public.POST("/login", handler.LoginHandler(authService))
+		public.POST("/register", limit(limits.Register), handler.RegisterHandler(userService))
+		public.POST("/login", limit(limits.Login), handler.LoginHandler(authService))
+		public.POST("/login/2fa", limit(limits.TwoFactor), handler.TwoFactorLoginHandler(authService))
+
+		public.GET("/oauth/providers", handler.ListOAuthProvidersHandler(oauthService))
+		public.GET("/oauth/:provider/login", handler.OAuthLoginHandler(oauthService))
+		public.POST("/oauth/:provider/callback", limit(limits.OAuthCallback), handler.OAuthCallbackHandler(oauthService))
 
 		public.GET("posts", handler.ListPostsHandler(postService))
+		public.GET("/search", handler.SearchHandler(searchService))
 		public.GET("/posts/comments", handler.GetCommentsHandler(commentService))
 		public.GET("/comments/:parent_id/replies", handler.GetRepliesHandler(commentService))
 
 		public.GET("/users/followers/:id", handler.GetFollowersHandler(followService)) // 某用户的粉丝列表
 		public.GET("/users/following/:id", handler.GetFollowingHandler(followService)) // 某用户关注的人列表
 
+		public.GET("/account/exports/download", handler.DownloadDataExportHandler(accountService))
+
+		public.POST("/email/verify", limit(limits.Email), handler.VerifyEmailHandler(emailService))
+		public.POST("/password/forgot", limit(limits.Email), handler.ForgotPasswordHandler(emailService))
+		public.POST("/password/reset", limit(limits.Email), handler.ResetPasswordHandler(emailService))
+
 	}
 
 	private := r.Group("/")
 	private.Use(middleware.AuthMiddleware(authService))
-	private.Use(middleware.RateLimit())
+	private.Use(limit(limits.Default))
 	{
 		private.POST("/logout", handler.LogoutHandler(authService))
 		private.POST("/logout-all", handler.LogoutAllHandler(authService))
 		private.GET("/sessions", handler.ListSessionsHandler(authService))
+		private.PUT("/sessions/:session_id", handler.RenameSessionHandler(authService))
 		private.POST("/sessions/revoke", handler.RevokeSessionHandler(authService))
+		private.GET("/security/events", handler.ListSecurityEventsHandler(securityService))
 
 		private.PUT("/change_pass", handler.ChangePassHandler(userService))
 		private.PUT("/profile", handler.UpdateProfileHandler(userService))
-		private.POST("/avatar", handler.UploadAvatarHandler(userService))
+		private.POST("/avatar", limit(limits.Upload), handler.UploadAvatarHandler(uploadService))
 
-		private.POST("/posts", handler.CreatePostHandler(postService))
-		private.PUT("/posts/:id", handler.UpdatePostHandler(postService))
+		private.POST("/posts", limit(limits.Post), handler.CreatePostHandler(postService))
+		private.PUT("/posts/:id", limit(limits.Post), handler.UpdatePostHandler(postService))
 		private.DELETE("posts/:id", handler.DeletePostHandler(postService))
 
-		private.POST("/comments", handler.PostCommentHandler(commentService))
+		private.POST("/comments", limit(limits.Comment), handler.PostCommentHandler(commentService))
 		private.DELETE("/comments/:id", handler.DeleteCommentHandler(commentService))
 
 		private.POST("follow/:id", handler.FollowUserHandler(followService))
 		private.DELETE("/follow/:id", handler.UnfollowUserHandler(followService))
 
-		private.POST("/upload/article-image", handler.UploadArticleImageHandler)
+		private.POST("/upload/article-image", limit(limits.Upload), handler.UploadArticleImageHandler(uploadService))
+		private.GET("/upload/quota", handler.UploadQuotaHandler(uploadService))
+		private.GET("/images/:id/signed-url", handler.SignedImageURLHandler(uploadService))
 
 		private.POST("/reactions", handler.ToggleReactionHandler(reactionService)) //点赞
 		private.POST("/favorites", handler.ToggleFavoriteHandler(favoriteService)) //收藏
@@ -81,15 +142,50 @@ func InitRouter(
 		private.GET("/notifications/count", handler.GetUnreadCountHandler(notification))
 
 		private.POST("/notifications/read-all", handler.MarkAllNotificationsReadHandler(notification))
+
+		private.POST("/account/exports", handler.RequestDataExportHandler(accountService))
+		private.GET("/account/exports", handler.ListDataExportsHandler(accountService))
+		private.POST("/account/deletion", handler.RequestAccountDeletionHandler(accountService))
+		private.GET("/account/deletion", handler.GetAccountDeletionHandler(accountService))
+		private.DELETE("/account/deletion", handler.CancelAccountDeletionHandler(accountService))
+
+		private.POST("/email/resend", handler.ResendVerificationHandler(emailService))
+		private.PUT("/email", handler.ChangeEmailHandler(emailService))
+
+		private.GET("/2fa", handler.TwoFactorStatusHandler(twoFactorService))
+		private.POST("/2fa/setup", handler.TwoFactorSetupHandler(twoFactorService))
+		private.POST("/2fa/enable", handler.TwoFactorEnableHandler(twoFactorService))
+		private.POST("/2fa/disable", handler.TwoFactorDisableHandler(twoFactorService))
+		private.POST("/2fa/recovery-codes", handler.RegenerateRecoveryCodesHandler(twoFactorService))
+
+		private.GET("/oauth/identities", handler.ListIdentitiesHandler(oauthService))
+		private.DELETE("/oauth/identities/:provider", handler.UnlinkIdentityHandler(oauthService))
+		private.GET("/oauth/:provider/link", handler.OAuthLinkHandler(oauthService))
+		private.POST("/oauth/:provider/link/callback", limit(limits.OAuthCallback), handler.OAuthLinkCallbackHandler(oauthService))
 	}
 
 	option := r.Group("/")
 	option.Use(middleware.OptionalAuthMiddleware(authService))
-	option.Use(middleware.RateLimit())
+	option.Use(limit(limits.Default))
 	{
 		option.GET("/posts/:id", handler.GetPostHandler(postService))
 		option.POST("/refresh", handler.RefreshHandler(authService))
 		option.GET("/user/:id", handler.GetUserInfoHandler(userService))
 	}
-	r.Run(":8080")
+
+	admin := r.Group("/admin")
+	admin.Use(middleware.AuthMiddleware(authService))
+	admin.Use(middleware.AdminOnly())
+	admin.Use(limit(limits.Default))
+	{
+		admin.GET("/stats", handler.AdminStatsHandler(adminService))
+		admin.GET("/stats/export", handler.ExportAdminStatsHandler(adminService))
+
+		admin.POST("/users/:id/unlock", handler.AdminUnlockUserHandler(authService))
+		admin.GET("/security/events", handler.AdminListSecurityEventsHandler(securityService))
+		admin.GET("/janitor", handler.AdminJanitorStatsHandler(janitorService))
+		admin.POST("/janitor/run", handler.AdminJanitorRunHandler(janitorService))
+	}
+
+	return r
 }
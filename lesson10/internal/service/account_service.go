@@ -0,0 +1,662 @@
+package service
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"lesson10/internal/config"
+	"lesson10/internal/dto"
+	"lesson10/internal/model"
+	"lesson10/internal/pkg/cache"
+	"lesson10/internal/pkg/errcode"
+	"lesson10/internal/pkg/utils"
+	"lesson10/internal/repository"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	exportStatusPending    = "pending"
+	exportStatusProcessing = "processing"
+	exportStatusReady      = "ready"
+	exportStatusFailed     = "failed"
+	exportStatusExpired    = "expired"
+
+	deletionStatusPending   = "pending"
+	deletionStatusCancelled = "cancelled"
+	deletionStatusCompleted = "completed"
+
+	// 注销后内容处理策略：anonymize 保留内容但作者匿名化，remove 连同内容一起删除
+	deletionPolicyAnonymize = "anonymize"
+	deletionPolicyRemove    = "remove"
+
+	accountWorkerInterval = time.Minute
+	accountWorkerBatch    = 20
+	// exportStaleAfter 处理中的导出超过这么久没有完成，认为处理它的实例已经退出，放回队列
+	exportStaleAfter = 30 * time.Minute
+)
+
+type AccountService struct {
+	accountRepo      repository.AccountRepository
+	userRepo         repository.UserRepository
+	sessionRepo      repository.SessionRepository
+	notificationRepo repository.NotificationRepository
+	authSvc          *AuthService
+	db               *gorm.DB
+	kick             chan struct{}
+	cache            *cache.Cache
+	searchSvc        *SearchService
+	cfg              config.AccountConfig
+}
+
+func NewAccountService(
+	accountRepo repository.AccountRepository,
+	userRepo repository.UserRepository,
+	sessionRepo repository.SessionRepository,
+	notificationRepo repository.NotificationRepository,
+	authSvc *AuthService,
+	db *gorm.DB,
+	cfg config.AccountConfig,
+) *AccountService {
+	return &AccountService{
+		accountRepo:      accountRepo,
+		userRepo:         userRepo,
+		sessionRepo:      sessionRepo,
+		notificationRepo: notificationRepo,
+		authSvc:          authSvc,
+		db:               db,
+		kick:             make(chan struct{}, 1),
+		cfg:              cfg,
+	}
+}
+
+func (s *AccountService) SetCache(c *cache.Cache) {
+	s.cache = c
+}
+
+func (s *AccountService) SetSearchService(searchSvc *SearchService) {
+	s.searchSvc = searchSvc
+}
+
+func (s *AccountService) RequestExport(ctx context.Context, userID uint) (*dto.DataExportInfo, error) {
+	exports, err := s.accountRepo.ListExportsByUserID(ctx, int64(userID), 5)
+	if err != nil {
+		return nil, errcode.ErrInternal
+	}
+	for _, export := range exports {
+		if export.Status == exportStatusPending || export.Status == exportStatusProcessing {
+			return nil, errcode.ErrConflict
+		}
+	}
+
+	downloadToken, err := utils.NewToken(32)
+	if err != nil {
+		return nil, errcode.ErrInternal
+	}
+
+	export := &model.DataExport{
+		UserID:    int64(userID),
+		Status:    exportStatusPending,
+		TokenHash: utils.HashToken(downloadToken),
+		ExpiresAt: time.Now().Add(s.cfg.ExportLinkTTL),
+	}
+	if err := s.accountRepo.CreateExport(ctx, export); err != nil {
+		return nil, errcode.ErrInternal
+	}
+
+	s.wake()
+
+	info := toDataExportInfo(export)
+	info.DownloadURL = "/account/exports/download?token=" + downloadToken
+	return &info, nil
+}
+
+func (s *AccountService) ListExports(ctx context.Context, userID uint) ([]dto.DataExportInfo, error) {
+	exports, err := s.accountRepo.ListExportsByUserID(ctx, int64(userID), 20)
+	if err != nil {
+		return nil, errcode.ErrInternal
+	}
+
+	result := make([]dto.DataExportInfo, 0, len(exports))
+	for i := range exports {
+		result = append(result, toDataExportInfo(&exports[i]))
+	}
+
+	return result, nil
+}
+
+// OpenExport 根据下载链接中的 token 找到已生成的导出文件
+func (s *AccountService) OpenExport(ctx context.Context, downloadToken string) (*model.DataExport, error) {
+	if downloadToken == "" {
+		return nil, errcode.ErrNotFound
+	}
+
+	export, err := s.accountRepo.GetExportByTokenHash(ctx, utils.HashToken(downloadToken))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errcode.ErrNotFound
+		}
+		return nil, errcode.ErrInternal
+	}
+
+	if export.Status != exportStatusReady || time.Now().After(export.ExpiresAt) {
+		return nil, errcode.ErrNotFound
+	}
+
+	return export, nil
+}
+
+func (s *AccountService) RequestDeletion(ctx context.Context, userID uint, password string) (*dto.AccountDeletionInfo, error) {
+	var user model.User
+	if err := s.userRepo.FindUserByID(ctx, userID, &user); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errcode.ErrNotFound
+		}
+		return nil, errcode.ErrInternal
+	}
+
+	if !checkPassword(&user, password) {
+		return nil, errcode.ErrPasswordIncorrect
+	}
+
+	if _, err := s.accountRepo.GetPendingDeletionByUserID(ctx, int64(userID)); err == nil {
+		return nil, errcode.ErrConflict
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, errcode.ErrInternal
+	}
+
+	deletion := &model.AccountDeletion{
+		UserID:      int64(userID),
+		Status:      deletionStatusPending,
+		Policy:      s.cfg.DeletionPolicy,
+		ScheduledAt: time.Now().Add(s.cfg.DeletionGrace),
+	}
+	if err := s.accountRepo.CreateDeletion(ctx, deletion); err != nil {
+		return nil, errcode.ErrInternal
+	}
+
+	info := toAccountDeletionInfo(deletion)
+	return &info, nil
+}
+
+func (s *AccountService) GetDeletion(ctx context.Context, userID uint) (*dto.AccountDeletionInfo, error) {
+	deletion, err := s.accountRepo.GetPendingDeletionByUserID(ctx, int64(userID))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errcode.ErrNotFound
+		}
+		return nil, errcode.ErrInternal
+	}
+
+	info := toAccountDeletionInfo(deletion)
+	return &info, nil
+}
+
+func (s *AccountService) CancelDeletion(ctx context.Context, userID uint) error {
+	deletion, err := s.accountRepo.GetPendingDeletionByUserID(ctx, int64(userID))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return errcode.ErrNotFound
+		}
+		return errcode.ErrInternal
+	}
+
+	now := time.Now()
+	deletion.Status = deletionStatusCancelled
+	deletion.CancelledAt = &now
+	if err := s.accountRepo.UpdateDeletion(ctx, deletion); err != nil {
+		return errcode.ErrInternal
+	}
+
+	return nil
+}
+
+// RunWorker 后台处理导出任务和到期的注销请求，ctx 取消后退出。
+// 每个实例都会跑：导出任务先用状态从 pending 改成 processing 认领，注销在事务里锁住记录再处理，同一个任务不会被处理两次
+func (s *AccountService) RunWorker(ctx context.Context) {
+	ticker := time.NewTicker(accountWorkerInterval)
+	defer ticker.Stop()
+
+	for {
+		s.resetStaleExports(ctx)
+		s.processExports(ctx)
+		s.expireExports(ctx)
+		s.processDeletions(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-s.kick:
+		}
+	}
+}
+
+func (s *AccountService) wake() {
+	select {
+	case s.kick <- struct{}{}:
+	default:
+	}
+}
+
+// resetStaleExports 处理它的实例中途退出的任务放回队列；不能在启动时全部重置，别的实例可能正在处理
+func (s *AccountService) resetStaleExports(ctx context.Context) {
+	exports, err := s.accountRepo.ListStaleExports(ctx, time.Now().Add(-exportStaleAfter), accountWorkerBatch)
+	if err != nil {
+		slog.ErrorContext(ctx, "list stale exports failed", "error", err)
+		return
+	}
+
+	for _, export := range exports {
+		if _, err := s.accountRepo.TransitionExport(ctx, export.ID, exportStatusProcessing, exportStatusPending); err != nil {
+			slog.ErrorContext(ctx, "reset export failed", "export_id", export.ID, "error", err)
+		}
+	}
+}
+
+func (s *AccountService) processExports(ctx context.Context) {
+	exports, err := s.accountRepo.ListExportsByStatus(ctx, exportStatusPending, accountWorkerBatch)
+	if err != nil {
+		slog.ErrorContext(ctx, "list pending exports failed", "error", err)
+		return
+	}
+
+	for i := range exports {
+		export := &exports[i]
+		claimed, err := s.accountRepo.TransitionExport(ctx, export.ID, exportStatusPending, exportStatusProcessing)
+		if err != nil {
+			slog.ErrorContext(ctx, "claim export failed", "export_id", export.ID, "error", err)
+			continue
+		}
+		if !claimed {
+			continue
+		}
+		export.Status = exportStatusProcessing
+
+		path, size, err := s.buildExport(ctx, uint(export.UserID), export.ID)
+		now := time.Now()
+		if err != nil {
+			slog.ErrorContext(ctx, "build export failed", "export_id", export.ID, "error", err)
+			export.Status = exportStatusFailed
+			export.Error = "export failed"
+		} else {
+			export.Status = exportStatusReady
+			export.FilePath = path
+			export.FileSize = size
+			export.CompletedAt = &now
+			export.ExpiresAt = now.Add(s.cfg.ExportLinkTTL)
+		}
+
+		if err := s.accountRepo.UpdateExport(ctx, export); err != nil {
+			slog.ErrorContext(ctx, "update export failed", "export_id", export.ID, "error", err)
+			continue
+		}
+
+		if export.Status == exportStatusReady {
+			s.notify(ctx, uint(export.UserID), "你的数据导出已完成，请在有效期内下载")
+		}
+	}
+}
+
+func (s *AccountService) expireExports(ctx context.Context) {
+	exports, err := s.accountRepo.ListExpiredExports(ctx, time.Now(), accountWorkerBatch)
+	if err != nil {
+		slog.ErrorContext(ctx, "list expired exports failed", "error", err)
+		return
+	}
+
+	for i := range exports {
+		expired, err := s.accountRepo.TransitionExport(ctx, exports[i].ID, exportStatusReady, exportStatusExpired)
+		if err != nil {
+			slog.ErrorContext(ctx, "expire export failed", "export_id", exports[i].ID, "error", err)
+			continue
+		}
+		if expired {
+			s.removeExportFile(&exports[i])
+		}
+	}
+}
+
+func (s *AccountService) removeExportFile(export *model.DataExport) {
+	if export.FilePath == "" {
+		return
+	}
+	if err := os.Remove(export.FilePath); err != nil && !os.IsNotExist(err) {
+		slog.Error("remove export file failed", "path", export.FilePath, "error", err)
+	}
+	export.FilePath = ""
+}
+
+// buildExport 把用户数据打包成 zip，每类数据一个 json 文件
+func (s *AccountService) buildExport(ctx context.Context, userID uint, exportID int64) (string, int64, error) {
+	var user model.User
+	if err := s.userRepo.FindUserByID(ctx, userID, &user); err != nil {
+		return "", 0, err
+	}
+
+	posts, err := s.accountRepo.ListAllPostsByAuthor(ctx, userID)
+	if err != nil {
+		return "", 0, err
+	}
+	published := make([]model.Post, 0, len(posts))
+	drafts := make([]model.Post, 0)
+	for _, p := range posts {
+		if p.Status == 1 {
+			drafts = append(drafts, p)
+		} else {
+			published = append(published, p)
+		}
+	}
+
+	comments, err := s.accountRepo.ListAllCommentsByAuthor(ctx, userID)
+	if err != nil {
+		return "", 0, err
+	}
+
+	favorites, err := s.accountRepo.ListAllFavoritesByUser(ctx, userID)
+	if err != nil {
+		return "", 0, err
+	}
+
+	follows, err := s.accountRepo.ListAllFollowsByUser(ctx, userID)
+	if err != nil {
+		return "", 0, err
+	}
+	following := make([]dto.ExportFollow, 0)
+	followers := make([]dto.ExportFollow, 0)
+	for _, f := range follows {
+		if f.FollowerID == userID {
+			following = append(following, dto.ExportFollow{UserID: f.FolloweeID, CreatedAt: f.CreatedAt})
+		} else {
+			followers = append(followers, dto.ExportFollow{UserID: f.FollowerID, CreatedAt: f.CreatedAt})
+		}
+	}
+
+	notifications, err := s.accountRepo.ListAllNotificationsByUser(ctx, userID)
+	if err != nil {
+		return "", 0, err
+	}
+
+	sessions, err := s.sessionRepo.ListByUserID(ctx, int64(userID))
+	if err != nil {
+		return "", 0, err
+	}
+	sessionInfos := make([]dto.SessionInfo, 0, len(sessions))
+	for _, session := range sessions {
+		sessionInfos = append(sessionInfos, dto.SessionInfo{
+			SessionID:  session.SessionID,
+			DeviceID:   session.DeviceID,
+			DeviceName: session.DeviceName,
+			UserAgent:  session.UserAgent,
+			LoginIP:    session.LoginIP,
+			LastIP:     session.LastIP,
+			Status:     session.Status,
+			CreatedAt:  session.CreatedAt.Unix(),
+			LastSeenAt: session.LastSeenAt.Unix(),
+		})
+	}
+
+	files := []struct {
+		name string
+		data any
+	}{
+		{"profile.json", dto.ExportProfile{
+			ID:           user.ID,
+			Username:     user.Username,
+			AvatarURL:    user.AvatarURL,
+			Profile:      user.Profile,
+			Role:         user.Role,
+			VIPExpiresAt: user.VIPExpiresAt,
+			CreatedAt:    user.CreatedAt,
+		}},
+		{"posts.json", published},
+		{"drafts.json", drafts},
+		{"comments.json", comments},
+		{"favorites.json", favorites},
+		{"follows.json", dto.ExportFollows{Following: following, Followers: followers}},
+		{"notifications.json", notifications},
+		{"sessions.json", sessionInfos},
+	}
+
+	dir := s.cfg.ExportDir
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return "", 0, err
+	}
+
+	path := filepath.Join(dir, fmt.Sprintf("export_u%d_%d_%d.zip", userID, exportID, time.Now().UnixNano()))
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o600)
+	if err != nil {
+		return "", 0, err
+	}
+
+	zw := zip.NewWriter(f)
+	for _, file := range files {
+		w, err := zw.Create(file.name)
+		if err != nil {
+			_ = f.Close()
+			_ = os.Remove(path)
+			return "", 0, err
+		}
+
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(file.data); err != nil {
+			_ = f.Close()
+			_ = os.Remove(path)
+			return "", 0, err
+		}
+	}
+
+	if err := zw.Close(); err != nil {
+		_ = f.Close()
+		_ = os.Remove(path)
+		return "", 0, err
+	}
+	if err := f.Close(); err != nil {
+		_ = os.Remove(path)
+		return "", 0, err
+	}
+
+	stat, err := os.Stat(path)
+	if err != nil {
+		return "", 0, err
+	}
+
+	return path, stat.Size(), nil
+}
+
+func (s *AccountService) processDeletions(ctx context.Context) {
+	deletions, err := s.accountRepo.ListDueDeletions(ctx, time.Now(), accountWorkerBatch)
+	if err != nil {
+		slog.ErrorContext(ctx, "list due account deletions failed", "error", err)
+		return
+	}
+
+	for _, deletion := range deletions {
+		if err := s.completeDeletion(ctx, deletion.ID); err != nil {
+			slog.ErrorContext(ctx, "complete account deletion failed", "deletion_id", deletion.ID, "error", err)
+		}
+	}
+}
+
+// completeDeletion 宽限期结束后执行注销：吊销会话、按策略处理内容、匿名化账号并释放用户名
+func (s *AccountService) completeDeletion(ctx context.Context, deletionID int64) error {
+	var (
+		userID          uint
+		cacheKeys       []string
+		removedPosts    []uint
+		removedComments []uint
+	)
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		accountRepo := s.accountRepo.WithTx(tx)
+		sessionRepo := s.authSvc.sessionRepo.WithTx(tx)
+		refreshRepo := s.authSvc.refreshRepo.WithTx(tx)
+		eventRepo := s.authSvc.eventRepo.WithTx(tx)
+
+		deletion, err := accountRepo.GetDeletionForUpdate(ctx, deletionID)
+		if err != nil {
+			return err
+		}
+		if deletion.Status != deletionStatusPending {
+			return nil
+		}
+
+		userID = uint(deletion.UserID)
+		now := time.Now()
+
+		// 不管哪种策略，帖子详情里的作者都变了；关注过的人的关注数也会变
+		if cacheKeys, err = s.deletionCacheKeys(ctx, accountRepo, userID); err != nil {
+			return err
+		}
+
+		if err := s.authSvc.revokeAllSessionsWithRepo(ctx, sessionRepo, refreshRepo, deletion.UserID, "account_deleted", now); err != nil {
+			return err
+		}
+
+		if err := accountRepo.DeleteDraftsByAuthor(ctx, userID); err != nil {
+			return err
+		}
+		if deletion.Policy == deletionPolicyRemove {
+			if removedPosts, err = accountRepo.DeletePostsByAuthor(ctx, userID); err != nil {
+				return err
+			}
+			if removedComments, err = accountRepo.DeleteCommentsByAuthor(ctx, userID); err != nil {
+				return err
+			}
+		}
+
+		if err := accountRepo.DeleteRelationsByUser(ctx, userID); err != nil {
+			return err
+		}
+		if err := accountRepo.AnonymizeUser(ctx, userID); err != nil {
+			return err
+		}
+
+		if err := s.authSvc.recordEventWithRepo(ctx, eventRepo, deletion.UserID, "", "account_deleted", "", "", "", "account deleted with policy "+deletion.Policy); err != nil {
+			return err
+		}
+
+		deletion.Status = deletionStatusCompleted
+		deletion.CompletedAt = &now
+		return accountRepo.UpdateDeletion(ctx, deletion)
+	})
+	if err != nil {
+		return err
+	}
+
+	if userID != 0 {
+		s.removeUserExports(ctx, userID)
+		s.cache.Delete(ctx, cacheKeys...)
+		s.removeFromSearch(ctx, userID, removedPosts, removedComments)
+	}
+	return nil
+}
+
+// removeFromSearch 索引不在事务里，提交后再删；漏删的搜索时回表也会过滤掉
+func (s *AccountService) removeFromSearch(ctx context.Context, userID uint, postIDs []uint, commentIDs []uint) {
+	if s.searchSvc == nil {
+		return
+	}
+
+	s.searchSvc.RemoveUser(ctx, userID)
+	for _, id := range postIDs {
+		s.searchSvc.RemovePost(ctx, id)
+	}
+	for _, id := range commentIDs {
+		s.searchSvc.RemoveComment(ctx, id)
+	}
+}
+
+// deletionCacheKeys 注销后要失效的缓存：主页资料、帖子和关注数，首页列表，每篇帖子的详情，以及关注关系另一头的关注数
+func (s *AccountService) deletionCacheKeys(ctx context.Context, accountRepo repository.AccountRepository, userID uint) ([]string, error) {
+	keys := []string{
+		userInfoKey(userID),
+		userPostsKey(userID),
+		followCountsKey(userID),
+		postListKey(0),
+		postListKey(uint8(model.PostArticle)),
+		postListKey(uint8(model.PostQuestion)),
+	}
+
+	posts, err := accountRepo.ListAllPostsByAuthor(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	for _, p := range posts {
+		keys = append(keys, postDetailKey(p.ID))
+	}
+
+	follows, err := accountRepo.ListAllFollowsByUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	for _, f := range follows {
+		other := f.FolloweeID
+		if other == userID {
+			other = f.FollowerID
+		}
+		keys = append(keys, followCountsKey(other))
+	}
+
+	return keys, nil
+}
+
+func (s *AccountService) removeUserExports(ctx context.Context, userID uint) {
+	exports, err := s.accountRepo.ListExportsByUserID(ctx, int64(userID), 100)
+	if err != nil {
+		slog.ErrorContext(ctx, "list user exports failed", "target_user_id", userID, "error", err)
+		return
+	}
+
+	for i := range exports {
+		if exports[i].Status == exportStatusExpired || exports[i].Status == exportStatusFailed {
+			continue
+		}
+		s.removeExportFile(&exports[i])
+		exports[i].Status = exportStatusExpired
+		if err := s.accountRepo.UpdateExport(ctx, &exports[i]); err != nil {
+			slog.ErrorContext(ctx, "expire export failed", "export_id", exports[i].ID, "error", err)
+		}
+	}
+}
+
+func (s *AccountService) notify(ctx context.Context, userID uint, content string) {
+	notification := &model.Notification{
+		UserID:  userID,
+		Type:    3, // 系统通知
+		Content: content,
+	}
+	if err := s.notificationRepo.CreateNotification(ctx, notification); err != nil {
+		slog.ErrorContext(ctx, "create notification failed", "error", err)
+	}
+}
+
+func toDataExportInfo(export *model.DataExport) dto.DataExportInfo {
+	info := dto.DataExportInfo{
+		ID:        export.ID,
+		Status:    export.Status,
+		FileSize:  export.FileSize,
+		CreatedAt: export.CreatedAt.Unix(),
+		ExpiresAt: export.ExpiresAt.Unix(),
+	}
+	if export.CompletedAt != nil {
+		info.CompletedAt = export.CompletedAt.Unix()
+	}
+
+	return info
+}
+
+func toAccountDeletionInfo(deletion *model.AccountDeletion) dto.AccountDeletionInfo {
+	return dto.AccountDeletionInfo{
+		Status:      deletion.Status,
+		Policy:      deletion.Policy,
+		ScheduledAt: deletion.ScheduledAt.Unix(),
+		CreatedAt:   deletion.CreatedAt.Unix(),
+	}
+}
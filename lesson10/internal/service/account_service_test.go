@@ -0,0 +1,89 @@
+package service
+
+import (
+	"context"
+	"lesson10/internal/config"
+	"lesson10/internal/model"
+	"lesson10/internal/pkg/search"
+	"lesson10/internal/repository"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// TestRemovePolicyDeletesRepliesAndSearchDocs remove 策略下用户的帖子、评论以及评论下别人的回复一起删，
+// 搜索索引里对应的文档和用户本身也要拿掉
+func TestRemovePolicyDeletesRepliesAndSearchDocs(t *testing.T) {
+	db := newTestDB(t)
+	authSvc := newTestAuthService(t, db)
+	searchSvc, engine := newTestSearchService(t, db)
+	accountSvc := NewAccountService(repository.NewAccountRepo(db), repository.NewUserRepo(db), repository.NewSessionRepo(db), repository.NewNotificationRepo(db), authSvc, db, config.AccountConfig{DeletionPolicy: deletionPolicyRemove})
+	accountSvc.SetSearchService(searchSvc)
+	ctx := context.Background()
+
+	leaving := newTestUser(t, authSvc, "rita")
+	staying := newTestUser(t, authSvc, "sam")
+	searchSvc.IndexUser(ctx, leaving)
+
+	create := func(v any) {
+		t.Helper()
+		if err := db.Create(v).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	leavingPost := &model.Post{AuthorID: leaving.ID, Title: "leaving", Content: "leaving post"}
+	stayingPost := &model.Post{AuthorID: staying.ID, Title: "staying", Content: "staying post"}
+	create(leavingPost)
+	create(stayingPost)
+	searchSvc.IndexPost(ctx, leavingPost)
+	searchSvc.IndexPost(ctx, stayingPost)
+
+	leavingComment := &model.Comment{TargetType: model.CommentOnPost, TargetID: stayingPost.ID, AuthorID: leaving.ID, Content: "leaving comment", Depth: 1}
+	create(leavingComment)
+	reply := &model.Comment{TargetType: model.CommentOnComment, TargetID: leavingComment.ID, AuthorID: staying.ID, Content: "staying reply", Depth: 2}
+	create(reply)
+	nested := &model.Comment{TargetType: model.CommentOnComment, TargetID: reply.ID, AuthorID: staying.ID, Content: "staying nested", Depth: 3}
+	create(nested)
+	stayingComment := &model.Comment{TargetType: model.CommentOnPost, TargetID: stayingPost.ID, AuthorID: staying.ID, Content: "staying comment", Depth: 1}
+	create(stayingComment)
+	for _, c := range []*model.Comment{leavingComment, reply, nested, stayingComment} {
+		searchSvc.IndexComment(ctx, c)
+	}
+
+	deletion := &model.AccountDeletion{UserID: int64(leaving.ID), Status: deletionStatusPending, Policy: deletionPolicyRemove, ScheduledAt: time.Now().Add(-time.Minute)}
+	create(deletion)
+
+	if err := accountSvc.completeDeletion(ctx, deletion.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	var deletedComments []uint
+	if err := db.Model(&model.Comment{}).Where("is_deleted = 1").Order("id").Pluck("id", &deletedComments).Error; err != nil {
+		t.Fatal(err)
+	}
+	if want := []uint{leavingComment.ID, reply.ID, nested.ID}; !reflect.DeepEqual(deletedComments, want) {
+		t.Fatalf("deleted comments = %v, want %v", deletedComments, want)
+	}
+	total, err := repository.NewCommentRepo(db).CountRootComments(ctx, uint8(model.CommentOnPost), stayingPost.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if total != 1 {
+		t.Fatalf("root comments on staying post = %d, want 1", total)
+	}
+
+	// 索引里只剩留下的帖子和评论
+	if n := engine.Len(); n != 2 {
+		t.Fatalf("indexed documents = %d, want 2", n)
+	}
+	for _, text := range []string{"leaving", "rita"} {
+		result, err := engine.Search(ctx, search.Query{Text: text, Limit: 10})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if result.Total != 0 {
+			t.Fatalf("search %q still finds %+v", text, result.Hits)
+		}
+	}
+}
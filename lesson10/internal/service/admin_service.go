@@ -0,0 +1,167 @@
+package service
+
+import (
+	"context"
+	"lesson10/internal/dto"
+	"lesson10/internal/pkg/errcode"
+	"lesson10/internal/repository"
+	"strings"
+	"time"
+)
+
+const (
+	statsDayLayout      = "2006-01-02"
+	statsDefaultDays    = 30
+	statsMaxDays        = 366
+	statsDefaultTopSize = 10
+	statsMaxTopSize     = 100
+)
+
+type AdminService struct {
+	statsRepo repository.StatsRepository
+}
+
+func NewAdminService(statsRepo repository.StatsRepository) *AdminService {
+	return &AdminService{
+		statsRepo: statsRepo,
+	}
+}
+
+// StatsRange 解析日期范围，返回 [from, to) 区间，to 为结束日期的第二天零点
+func (s *AdminService) StatsRange(q dto.AdminStatsQuery) (time.Time, time.Time, error) {
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+
+	to := today
+	if raw := strings.TrimSpace(q.To); raw != "" {
+		parsed, err := time.ParseInLocation(statsDayLayout, raw, time.Local)
+		if err != nil {
+			return time.Time{}, time.Time{}, errcode.ErrBadRequest
+		}
+		to = parsed
+	}
+
+	from := to.AddDate(0, 0, -(statsDefaultDays - 1))
+	if raw := strings.TrimSpace(q.From); raw != "" {
+		parsed, err := time.ParseInLocation(statsDayLayout, raw, time.Local)
+		if err != nil {
+			return time.Time{}, time.Time{}, errcode.ErrBadRequest
+		}
+		from = parsed
+	}
+
+	to = to.AddDate(0, 0, 1)
+	if !from.Before(to) || to.Sub(from) > statsMaxDays*24*time.Hour {
+		return time.Time{}, time.Time{}, errcode.ErrBadRequest
+	}
+
+	return from, to, nil
+}
+
+func (s *AdminService) DailyStats(ctx context.Context, from, to time.Time) ([]dto.DailyStat, error) {
+	activeUsers, err := s.statsRepo.DailyActiveUsers(ctx, from, to)
+	if err != nil {
+		return nil, errcode.ErrInternal
+	}
+	newUsers, err := s.statsRepo.DailyRegistrations(ctx, from, to)
+	if err != nil {
+		return nil, errcode.ErrInternal
+	}
+	posts, err := s.statsRepo.DailyPosts(ctx, from, to)
+	if err != nil {
+		return nil, errcode.ErrInternal
+	}
+	comments, err := s.statsRepo.DailyComments(ctx, from, to)
+	if err != nil {
+		return nil, errcode.ErrInternal
+	}
+	reactions, err := s.statsRepo.DailyReactions(ctx, from, to)
+	if err != nil {
+		return nil, errcode.ErrInternal
+	}
+
+	// 先按天铺满区间，没有数据的日期补 0
+	result := make([]dto.DailyStat, 0, int(to.Sub(from).Hours()/24))
+	index := make(map[string]int)
+	for day := from; day.Before(to); day = day.AddDate(0, 0, 1) {
+		key := day.Format(statsDayLayout)
+		index[key] = len(result)
+		result = append(result, dto.DailyStat{Day: key})
+	}
+
+	fill := func(rows []dto.DailyCount, set func(stat *dto.DailyStat, count int64)) {
+		for _, row := range rows {
+			if i, ok := index[row.Day]; ok {
+				set(&result[i], row.Count)
+			}
+		}
+	}
+	fill(activeUsers, func(stat *dto.DailyStat, count int64) { stat.ActiveUsers = count })
+	fill(newUsers, func(stat *dto.DailyStat, count int64) { stat.NewUsers = count })
+	fill(posts, func(stat *dto.DailyStat, count int64) { stat.Posts = count })
+	fill(comments, func(stat *dto.DailyStat, count int64) { stat.Comments = count })
+	fill(reactions, func(stat *dto.DailyStat, count int64) { stat.Reactions = count })
+
+	return result, nil
+}
+
+func (s *AdminService) TopAuthors(ctx context.Context, from, to time.Time, limit int) ([]dto.TopAuthorItem, error) {
+	if limit <= 0 {
+		limit = statsDefaultTopSize
+	}
+	if limit > statsMaxTopSize {
+		limit = statsMaxTopSize
+	}
+
+	items, err := s.statsRepo.TopAuthors(ctx, from, to, limit)
+	if err != nil {
+		return nil, errcode.ErrInternal
+	}
+	if items == nil {
+		items = []dto.TopAuthorItem{}
+	}
+
+	return items, nil
+}
+
+func (s *AdminService) SecurityEventBreakdown(ctx context.Context, from, to time.Time) ([]dto.SecurityEventCount, error) {
+	items, err := s.statsRepo.SecurityEventBreakdown(ctx, from, to)
+	if err != nil {
+		return nil, errcode.ErrInternal
+	}
+	if items == nil {
+		items = []dto.SecurityEventCount{}
+	}
+
+	return items, nil
+}
+
+func (s *AdminService) Overview(ctx context.Context, q dto.AdminStatsQuery) (*dto.AdminStatsResp, error) {
+	from, to, err := s.StatsRange(q)
+	if err != nil {
+		return nil, err
+	}
+
+	daily, err := s.DailyStats(ctx, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	topAuthors, err := s.TopAuthors(ctx, from, to, q.Limit)
+	if err != nil {
+		return nil, err
+	}
+
+	events, err := s.SecurityEventBreakdown(ctx, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	return &dto.AdminStatsResp{
+		From:           from.Format(statsDayLayout),
+		To:             to.AddDate(0, 0, -1).Format(statsDayLayout),
+		Daily:          daily,
+		TopAuthors:     topAuthors,
+		SecurityEvents: events,
+	}, nil
+}
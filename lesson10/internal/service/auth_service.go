@@ -3,11 +3,15 @@ package service
 import (
 	"context"
 	"errors"
+	"lesson10/internal/config"
 	"lesson10/internal/dto"
 	"lesson10/internal/model"
 	"lesson10/internal/pkg/browser"
 	"lesson10/internal/pkg/errcode"
+	"lesson10/internal/pkg/geo"
+	"lesson10/internal/pkg/metrics"
 	"lesson10/internal/pkg/token"
+	"lesson10/internal/pkg/tracing"
 	"lesson10/internal/pkg/utils"
 	"lesson10/internal/repository"
 	"strings"
@@ -24,6 +28,9 @@ const (
 	refreshStatusActive  = "active"
 	refreshStatusUsed    = "used"
 	refreshStatusRevoked = "revoked"
+
+	loginChallengeTTL         = 5 * time.Minute
+	loginChallengeMaxAttempts = 5
 )
 
 type AuthIdentity struct {
@@ -34,12 +41,32 @@ type AuthIdentity struct {
 	TokenID   string
 }
 
+// LoginResult 二选一：Pair 非空表示已登录，Challenge 非空表示还需要两步验证
+type LoginResult struct {
+	Pair      *dto.TokenPair
+	User      *model.User
+	DeviceID  string
+	Challenge *dto.LoginChallenge
+}
+
 type AuthService struct {
-	userRepo    repository.UserRepository
-	sessionRepo repository.SessionRepository
-	refreshRepo repository.RefreshTokenRepository
-	eventRepo   repository.SecurityEventRepository
-	db          *gorm.DB
+	userRepo     repository.UserRepository
+	sessionRepo  repository.SessionRepository
+	refreshRepo  repository.RefreshTokenRepository
+	eventRepo    repository.SecurityEventRepository
+	db           *gorm.DB
+	emailSvc     *EmailService
+	twoFactorSvc *TwoFactorService
+	throttleRepo repository.LoginThrottleRepository
+	geoLocator   geo.Locator
+	activity     *dailyActivity
+	tokens       *token.Manager
+	sessions     sessionPolicies
+	sessionLimit sessionLimitPolicy
+	// sessionHistory 会话列表里附带多久以内下线的会话
+	sessionHistory time.Duration
+	accountLogin   loginPolicy
+	ipLogin        loginPolicy
 }
 
 func NewAuthService(
@@ -48,57 +75,247 @@ func NewAuthService(
 	refreshRepo repository.RefreshTokenRepository,
 	eventRepo repository.SecurityEventRepository,
 	db *gorm.DB,
+	tokens *token.Manager,
+	sessionCfg config.SessionConfig,
+	loginCfg config.LoginConfig,
 ) *AuthService {
 	return &AuthService{
-		userRepo:    userRepo,
-		sessionRepo: sessionRepo,
-		refreshRepo: refreshRepo,
-		eventRepo:   eventRepo,
-		db:          db,
+		userRepo:       userRepo,
+		sessionRepo:    sessionRepo,
+		refreshRepo:    refreshRepo,
+		eventRepo:      eventRepo,
+		db:             db,
+		geoLocator:     geo.Noop{},
+		tokens:         tokens,
+		sessions:       newSessionPolicies(sessionCfg),
+		sessionLimit:   newSessionLimitPolicy(sessionCfg),
+		sessionHistory: sessionCfg.History,
+		accountLogin:   accountLoginPolicy(loginCfg),
+		ipLogin:        ipLoginPolicy(loginCfg),
 	}
 }
 
-func (s *AuthService) Login(ctx context.Context, req dto.LoginRequest, ip string, userAgent string) (*dto.TokenPair, *model.User, string, error) {
+func (s *AuthService) SetGeoLocator(locator geo.Locator) {
+	s.geoLocator = locator
+}
+
+func (s *AuthService) SetEmailService(emailSvc *EmailService) {
+	s.emailSvc = emailSvc
+}
+
+func (s *AuthService) SetLoginThrottleRepo(throttleRepo repository.LoginThrottleRepository) {
+	s.throttleRepo = throttleRepo
+}
+
+// SetStatsRepo 打开日活记录
+func (s *AuthService) SetStatsRepo(statsRepo repository.StatsRepository) {
+	s.activity = newDailyActivity(statsRepo)
+}
+
+func (s *AuthService) SetTwoFactorService(twoFactorSvc *TwoFactorService) {
+	s.twoFactorSvc = twoFactorSvc
+}
+
+// PublicKeys 对外发布的验签公钥
+func (s *AuthService) PublicKeys() token.JWKS {
+	return s.tokens.PublicKeys()
+}
+
+// Login 校验密码，失败按账号和 IP 计数退避；开启了两步验证的账号只返回 Challenge，需要再调用 CompleteTwoFactorLogin
+func (s *AuthService) Login(ctx context.Context, req dto.LoginRequest, ip string, userAgent string) (*LoginResult, error) {
+	ctx, span := tracing.Start(ctx, "AuthService.Login")
+	defer span.End()
+
+	now := time.Now()
+	accountKey := loginAccountKey(req.Username)
+	if err := s.guardLogin(ctx, accountKey, ip, now); err != nil {
+		return nil, err
+	}
+
 	user, err := s.userRepo.FindUserByUsername(ctx, strings.TrimSpace(req.Username))
 	if err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, nil, "", errcode.ErrUsernameIncorrect
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errcode.ErrInternal
 		}
-		return nil, nil, "", errcode.ErrInternal
+
+		_ = bcrypt.CompareHashAndPassword(dummyPasswordHash, []byte(req.Password))
+		metrics.LoginFailures.WithLabelValues(metrics.LoginUnknownUser).Inc()
+		if err := s.recordLoginFailure(ctx, accountKey, nil, "login_failed", ip, userAgent, now); err != nil {
+			return nil, errcode.ErrInternal
+		}
+		return nil, errcode.ErrInvalidCredentials
 	}
 
 	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(req.Password)); err != nil {
-		return nil, nil, "", errcode.ErrPasswordIncorrect
+		metrics.LoginFailures.WithLabelValues(metrics.LoginBadPassword).Inc()
+		if err := s.recordLoginFailure(ctx, accountKey, user, "login_failed", ip, userAgent, now); err != nil {
+			return nil, errcode.ErrInternal
+		}
+		return nil, errcode.ErrInvalidCredentials
+	}
+
+	s.resetLoginFailures(ctx, accountKey)
+
+	return s.completeLogin(ctx, user, req.DeviceID, req.DeviceName, req.RememberMe, ip, userAgent)
+}
+
+// completeLogin 身份已确认（密码或第三方登录），开启了两步验证的发 challenge，否则直接建会话
+func (s *AuthService) completeLogin(ctx context.Context, user *model.User, deviceID string, deviceName string, rememberMe bool, ip string, userAgent string) (*LoginResult, error) {
+	if s.twoFactorSvc != nil {
+		enabled, err := s.twoFactorSvc.IsEnabled(ctx, user.ID)
+		if err != nil {
+			return nil, errcode.ErrInternal
+		}
+		if enabled {
+			challenge, err := s.createLoginChallenge(ctx, user, deviceID, deviceName, rememberMe, ip, userAgent)
+			if err != nil {
+				return nil, err
+			}
+			return &LoginResult{User: user, Challenge: challenge}, nil
+		}
+	}
+
+	return s.issueSession(ctx, user, deviceID, deviceName, rememberMe, ip, userAgent)
+}
+
+// CompleteTwoFactorLogin 第二步：校验 challenge 和验证码（或恢复码），通过后按 challenge 中记录的设备建会话
+func (s *AuthService) CompleteTwoFactorLogin(ctx context.Context, req dto.TwoFactorLoginRequest, ip string, userAgent string) (*LoginResult, error) {
+	if s.twoFactorSvc == nil {
+		return nil, errcode.ErrChallengeExpired
+	}
+
+	ctx, span := tracing.Start(ctx, "AuthService.CompleteTwoFactorLogin")
+	defer span.End()
+
+	now := time.Now()
+	var (
+		challenge  *model.LoginChallenge
+		codeFailed bool
+		method     string
+	)
+
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		repo := s.twoFactorSvc.twoFactorRepo.WithTx(tx)
+		eventRepo := s.eventRepo.WithTx(tx)
+
+		var err error
+		challenge, err = repo.GetChallengeByTokenHashForUpdate(ctx, utils.HashToken(strings.TrimSpace(req.ChallengeToken)))
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errcode.ErrChallengeExpired
+			}
+			return errcode.ErrInternal
+		}
+
+		if challenge.UsedAt != nil || now.After(challenge.ExpiresAt) || challenge.Attempts >= loginChallengeMaxAttempts {
+			return errcode.ErrChallengeExpired
+		}
+
+		method, err = s.twoFactorSvc.verifyWithRepo(ctx, repo, challenge.UserID, req.Code, true, now)
+		if errors.Is(err, errcode.ErrTwoFactorCodeIncorrect) {
+			// 失败次数要提交，不能随事务回滚
+			codeFailed = true
+			challenge.Attempts++
+			if err := repo.UpdateChallenge(ctx, challenge); err != nil {
+				return errcode.ErrInternal
+			}
+			return s.recordEventWithRepo(ctx, eventRepo, challenge.UserID, "", "2fa_failed", ip, challenge.DeviceID, userAgent, "")
+		}
+		if err != nil {
+			return err
+		}
+
+		challenge.UsedAt = &now
+		if err := repo.UpdateChallenge(ctx, challenge); err != nil {
+			return errcode.ErrInternal
+		}
+
+		if method == twoFactorMethodRecovery {
+			return s.recordEventWithRepo(ctx, eventRepo, challenge.UserID, "", "2fa_recovery_code_used", ip, challenge.DeviceID, userAgent, "")
+		}
+
+		return nil
+	})
+	if errors.Is(err, errcode.ErrChallengeExpired) {
+		metrics.LoginFailures.WithLabelValues(metrics.LoginChallengeGone).Inc()
+	}
+	if err != nil {
+		return nil, err
+	}
+	if codeFailed {
+		metrics.LoginFailures.WithLabelValues(metrics.LoginBadTwoFactor).Inc()
+		return nil, errcode.ErrTwoFactorCodeIncorrect
 	}
 
+	user, err := s.findUser(ctx, uint(challenge.UserID))
+	if err != nil {
+		return nil, err
+	}
+
+	return s.issueSession(ctx, user, challenge.DeviceID, challenge.DeviceName, challenge.RememberMe, ip, userAgent)
+}
+
+func (s *AuthService) createLoginChallenge(ctx context.Context, user *model.User, deviceID string, deviceName string, rememberMe bool, ip string, userAgent string) (*dto.LoginChallenge, error) {
+	rawToken, err := utils.NewToken(32)
+	if err != nil {
+		return nil, errcode.ErrInternal
+	}
+
+	challenge := &model.LoginChallenge{
+		UserID:     int64(user.ID),
+		TokenHash:  utils.HashToken(rawToken),
+		DeviceID:   strings.TrimSpace(deviceID),
+		DeviceName: strings.TrimSpace(deviceName),
+		RememberMe: rememberMe,
+		IP:         ip,
+		UserAgent:  userAgent,
+		ExpiresAt:  time.Now().Add(loginChallengeTTL),
+	}
+	if err := s.twoFactorSvc.twoFactorRepo.CreateChallenge(ctx, challenge); err != nil {
+		return nil, errcode.ErrInternal
+	}
+
+	return &dto.LoginChallenge{
+		ChallengeToken: rawToken,
+		ExpiresAt:      challenge.ExpiresAt.Unix(),
+	}, nil
+}
+
+// issueSession 创建会话和 refresh token，签发 access token；rememberMe 决定会话用哪组空闲超时和绝对寿命
+func (s *AuthService) issueSession(ctx context.Context, user *model.User, deviceID string, deviceName string, rememberMe bool, ip string, userAgent string) (*LoginResult, error) {
 	sessionID, err := utils.NewSID()
 	if err != nil {
-		return nil, nil, "", errcode.ErrInternal
+		return nil, errcode.ErrInternal
 	}
 
 	refreshTokenValue, err := token.GenerateRefreshToken()
 	if err != nil {
-		return nil, nil, "", errcode.ErrInternal
+		return nil, errcode.ErrInternal
 	}
 
-	accessToken, accessJTI, accessExpiresAt, err := token.GenerateToken(user.Username, user.ID, user.Role, sessionID)
+	accessToken, accessJTI, accessExpiresAt, err := s.tokens.GenerateToken(user.Username, user.ID, user.Role, sessionID)
 	if err != nil {
-		return nil, nil, "", errcode.ErrInternal
+		return nil, errcode.ErrInternal
 	}
 
 	now := time.Now()
-	refreshExpiresAt := now.Add(token.RefreshTTL())
-	deviceID := strings.TrimSpace(req.DeviceID)
+	clientDeviceID := strings.TrimSpace(deviceID)
+	deviceID = clientDeviceID
 	if deviceID == "" {
 		deviceID = sessionID
 	}
 
-	deviceName := strings.TrimSpace(req.DeviceName)
+	deviceName = strings.TrimSpace(deviceName)
 	if deviceName == "" {
 		deviceName = "web-client"
 	}
 
 	browserInfo := browser.Parse(userAgent)
+	newDevice, err := s.isNewDevice(ctx, int64(user.ID), clientDeviceID, browserInfo.Key)
+	if err != nil {
+		return nil, errcode.ErrInternal
+	}
+
 	session := &model.Session{
 		SessionID:            sessionID,
 		UserID:               int64(user.ID),
@@ -114,9 +331,12 @@ func (s *AuthService) Login(ctx context.Context, req dto.LoginRequest, ip string
 		LoginIP:              ip,
 		LastIP:               ip,
 		LastSeenAt:           now,
+		RememberMe:           rememberMe,
 		CurrentAccessJTI:     accessJTI,
 		CurrentAccessExpires: accessExpiresAt,
+		CreatedAt:            now,
 	}
+	refreshExpiresAt := s.sessions.of(rememberMe).expiresAt(session)
 
 	refreshRecord := &model.RefreshToken{
 		SessionID: sessionID,
@@ -129,6 +349,11 @@ func (s *AuthService) Login(ctx context.Context, req dto.LoginRequest, ip string
 	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
 		sessionRepo := s.sessionRepo.WithTx(tx)
 		refreshRepo := s.refreshRepo.WithTx(tx)
+		eventRepo := s.eventRepo.WithTx(tx)
+
+		if err := s.enforceSessionLimitWithRepo(ctx, sessionRepo, refreshRepo, eventRepo, session, clientDeviceID, ip, userAgent, now); err != nil {
+			return err
+		}
 
 		if err := sessionRepo.Create(ctx, session); err != nil {
 			return err
@@ -138,23 +363,42 @@ func (s *AuthService) Login(ctx context.Context, req dto.LoginRequest, ip string
 			return err
 		}
 
+		if newDevice {
+			detail := browserInfo.BrowserName + " on " + browserInfo.OSName
+			if err := s.recordEventWithRepo(ctx, eventRepo, int64(user.ID), sessionID, "new_device_login", ip, deviceID, userAgent, detail); err != nil {
+				return err
+			}
+		}
+
 		return nil
 	})
+	if errors.Is(err, errcode.ErrSessionLimit) {
+		return nil, err
+	}
 	if err != nil {
-		return nil, nil, "", errcode.ErrInternal
+		return nil, errcode.ErrInternal
+	}
+
+	s.activity.mark(ctx, int64(user.ID), now)
+	if newDevice && s.emailSvc != nil {
+		s.emailSvc.NotifyNewDeviceLogin(user, session)
 	}
 
-	return &dto.TokenPair{
-		AccessToken:      accessToken,
-		RefreshToken:     refreshTokenValue,
-		SessionId:        sessionID,
-		AccessExpiresAt:  accessExpiresAt.Unix(),
-		RefreshExpiresAt: refreshExpiresAt.Unix(),
-	}, user, deviceID, nil
+	return &LoginResult{
+		Pair: &dto.TokenPair{
+			AccessToken:      accessToken,
+			RefreshToken:     refreshTokenValue,
+			SessionId:        sessionID,
+			AccessExpiresAt:  accessExpiresAt.Unix(),
+			RefreshExpiresAt: refreshExpiresAt.Unix(),
+		},
+		User:     user,
+		DeviceID: session.DeviceID,
+	}, nil
 }
 
 func (s *AuthService) ValidateAccessToken(ctx context.Context, accessToken string) (*AuthIdentity, error) {
-	claims, err := token.ValidateToken(strings.TrimSpace(accessToken))
+	claims, err := s.tokens.ValidateToken(strings.TrimSpace(accessToken))
 	if err != nil {
 		return nil, errcode.ErrUnauthorized
 	}
@@ -174,6 +418,20 @@ func (s *AuthService) ValidateAccessToken(ctx context.Context, accessToken strin
 		return nil, errcode.ErrUnauthorized
 	}
 
+	now := time.Now()
+	if s.sessions.of(session.RememberMe).expiredReason(session, now) != "" {
+		err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			_, err := s.expireSessionWithRepo(ctx, s.sessionRepo.WithTx(tx), s.refreshRepo.WithTx(tx), s.eventRepo.WithTx(tx), session, "", "", now)
+			return err
+		})
+		if err != nil {
+			return nil, errcode.ErrInternal
+		}
+		return nil, errcode.ErrSessionExpired
+	}
+
+	s.activity.mark(ctx, session.UserID, now)
+
 	return &AuthIdentity{
 		UserID:    claims.UserID,
 		Username:  claims.Username,
@@ -184,6 +442,9 @@ func (s *AuthService) ValidateAccessToken(ctx context.Context, accessToken strin
 }
 
 func (s *AuthService) Refresh(ctx context.Context, req dto.RefreshRequest, ip string, userAgent string) (*dto.TokenPair, error) {
+	ctx, span := tracing.Start(ctx, "AuthService.Refresh")
+	defer span.End()
+
 	refreshTokenValue := strings.TrimSpace(req.RefreshToken)
 	if refreshTokenValue == "" {
 		return nil, errcode.ErrUnauthorized
@@ -196,6 +457,7 @@ func (s *AuthService) Refresh(ctx context.Context, req dto.RefreshRequest, ip st
 
 	var (
 		pair     *dto.TokenPair
+		userID   int64
 		finalErr error
 	)
 
@@ -222,6 +484,12 @@ func (s *AuthService) Refresh(ctx context.Context, req dto.RefreshRequest, ip st
 			return err
 		}
 
+		// 会话按策略过期时 refresh token 被一并吊销，客户端随后拿它来刷新是正常行为，不算重放
+		if record.Status == refreshStatusRevoked && (record.RevokeReason == sessionRevokeIdleTimeout || record.RevokeReason == sessionRevokeMaxLifetime) {
+			finalErr = errcode.ErrSessionExpired
+			return nil
+		}
+
 		if record.Status != refreshStatusActive {
 			if err := s.recordEventWithRepo(ctx, eventRepo, record.UserID, record.SessionID, "refresh_token_reuse", ip, deviceID, userAgent, "used or revoked refresh token was presented again"); err != nil {
 				return err
@@ -233,6 +501,17 @@ func (s *AuthService) Refresh(ctx context.Context, req dto.RefreshRequest, ip st
 			return nil
 		}
 
+		if session.Status == sessionStatusActive && session.RevokedAt == nil {
+			expired, err := s.expireSessionWithRepo(ctx, sessionRepo, refreshRepo, eventRepo, session, ip, userAgent, now)
+			if err != nil {
+				return err
+			}
+			if expired {
+				finalErr = errcode.ErrSessionExpired
+				return nil
+			}
+		}
+
 		if now.After(record.ExpiresAt) {
 			record.Status = refreshStatusRevoked
 			record.RevokedAt = &now
@@ -294,7 +573,7 @@ func (s *AuthService) Refresh(ctx context.Context, req dto.RefreshRequest, ip st
 			return err
 		}
 
-		newAccessToken, newAccessJTI, accessExpiresAt, err := token.GenerateToken(user.Username, user.ID, user.Role, session.SessionID)
+		newAccessToken, newAccessJTI, accessExpiresAt, err := s.tokens.GenerateToken(user.Username, user.ID, user.Role, session.SessionID)
 		if err != nil {
 			return err
 		}
@@ -308,7 +587,8 @@ func (s *AuthService) Refresh(ctx context.Context, req dto.RefreshRequest, ip st
 			return err
 		}
 
-		refreshExpiresAt := now.Add(token.RefreshTTL())
+		session.LastSeenAt = now
+		refreshExpiresAt := s.sessions.of(session.RememberMe).expiresAt(session)
 		if err := refreshRepo.Create(ctx, &model.RefreshToken{
 			SessionID: session.SessionID,
 			UserID:    session.UserID,
@@ -319,7 +599,6 @@ func (s *AuthService) Refresh(ctx context.Context, req dto.RefreshRequest, ip st
 			return err
 		}
 
-		session.LastSeenAt = now
 		session.LastIP = ip
 		session.UserAgent = userAgent
 		if currentBrowser.BrowserName != "" {
@@ -343,6 +622,7 @@ func (s *AuthService) Refresh(ctx context.Context, req dto.RefreshRequest, ip st
 			return err
 		}
 
+		userID = session.UserID
 		pair = &dto.TokenPair{
 			AccessToken:      newAccessToken,
 			RefreshToken:     newRefreshToken,
@@ -360,6 +640,7 @@ func (s *AuthService) Refresh(ctx context.Context, req dto.RefreshRequest, ip st
 		return nil, finalErr
 	}
 
+	s.activity.mark(ctx, userID, now)
 	return pair, nil
 }
 
@@ -386,49 +667,91 @@ func (s *AuthService) LogoutAll(ctx context.Context, userID uint, password strin
 		return err
 	}
 
-	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)); err != nil {
+	if !checkPassword(user, password) {
 		return errcode.ErrPasswordIncorrect
 	}
 
 	return s.RevokeAllUserSessions(ctx, userID, "logout_all")
 }
 
-func (s *AuthService) ListSessions(ctx context.Context, userID uint, currentSessionID string) ([]dto.SessionInfo, error) {
-	sessions, err := s.sessionRepo.ListByUserID(ctx, int64(userID))
+// ListSessions includeRevoked 时附带 session.history 以内下线的会话
+func (s *AuthService) ListSessions(ctx context.Context, userID uint, currentSessionID string, includeRevoked bool) ([]dto.SessionInfo, error) {
+	revokedSince := time.Now()
+	if includeRevoked {
+		revokedSince = revokedSince.Add(-s.sessionHistory)
+	}
+
+	sessions, err := s.sessionRepo.ListVisibleByUserID(ctx, int64(userID), revokedSince)
 	if err != nil {
 		return nil, errcode.ErrInternal
 	}
 
+	locations := make(map[string]string)
 	result := make([]dto.SessionInfo, 0, len(sessions))
 	for _, session := range sessions {
-		if session.Status != sessionStatusActive {
-			continue
-		}
-
-		result = append(result, dto.SessionInfo{
-			SessionID:  session.SessionID,
-			DeviceID:   session.DeviceID,
-			DeviceName: session.DeviceName,
-			UserAgent:  session.UserAgent,
-			LoginIP:    session.LoginIP,
-			LastIP:     session.LastIP,
-			Status:     session.Status,
-			Current:    session.SessionID == currentSessionID,
-			CreatedAt:  session.CreatedAt.Unix(),
-			LastSeenAt: session.LastSeenAt.Unix(),
-		})
+		location, ok := locations[session.LastIP]
+		if !ok {
+			location = geo.Describe(s.geoLocator, session.LastIP)
+			locations[session.LastIP] = location
+		}
+
+		info := dto.SessionInfo{
+			SessionID:      session.SessionID,
+			DeviceID:       session.DeviceID,
+			DeviceName:     session.DeviceName,
+			Label:          session.Label,
+			UserAgent:      session.UserAgent,
+			BrowserName:    session.BrowserName,
+			BrowserVersion: session.BrowserVersion,
+			OSName:         session.OSName,
+			DeviceType:     session.DeviceType,
+			LoginIP:        session.LoginIP,
+			LastIP:         session.LastIP,
+			Location:       location,
+			Status:         session.Status,
+			RememberMe:     session.RememberMe,
+			RevokeReason:   session.RevokeReason,
+			Current:        session.SessionID == currentSessionID,
+			CreatedAt:      session.CreatedAt.Unix(),
+			LastSeenAt:     session.LastSeenAt.Unix(),
+		}
+		if session.RevokedAt != nil {
+			revokedAt := session.RevokedAt.Unix()
+			info.RevokedAt = &revokedAt
+		} else {
+			expiresAt := s.sessions.of(session.RememberMe).expiresAt(&session).Unix()
+			info.ExpiresAt = &expiresAt
+		}
+
+		result = append(result, info)
 	}
 
 	return result, nil
 }
 
+// RenameSession 给会话起个好认的名字，传空字符串恢复默认显示
+func (s *AuthService) RenameSession(ctx context.Context, userID uint, sessionID string, label string) error {
+	affected, err := s.sessionRepo.UpdateLabel(ctx, int64(userID), strings.TrimSpace(sessionID), strings.TrimSpace(label))
+	if err != nil {
+		return errcode.ErrInternal
+	}
+	if affected == 0 {
+		exists, err := s.sessionRepo.GetBySessionID(ctx, strings.TrimSpace(sessionID))
+		if err != nil || exists.UserID != int64(userID) {
+			return errcode.ErrNotFound
+		}
+	}
+
+	return nil
+}
+
 func (s *AuthService) RevokeSession(ctx context.Context, userID uint, sessionID string, password string) error {
 	user, err := s.findUser(ctx, userID)
 	if err != nil {
 		return err
 	}
 
-	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)); err != nil {
+	if !checkPassword(user, password) {
 		return errcode.ErrPasswordIncorrect
 	}
 
@@ -462,6 +785,21 @@ func (s *AuthService) RevokeAllUserSessions(ctx context.Context, userID uint, re
 	return nil
 }
 
+// isNewDevice 第一次登录不算新设备，之后设备 ID 和浏览器指纹都没见过才提醒
+func (s *AuthService) isNewDevice(ctx context.Context, userID int64, deviceID string, browserKey string) (bool, error) {
+	known, err := s.sessionRepo.HasKnownDevice(ctx, userID, deviceID, browserKey)
+	if err != nil || known {
+		return false, err
+	}
+
+	sessions, err := s.sessionRepo.ListByUserID(ctx, userID)
+	if err != nil {
+		return false, err
+	}
+
+	return len(sessions) > 0, nil
+}
+
 func (s *AuthService) findUser(ctx context.Context, userID uint) (*model.User, error) {
 	var user model.User
 	if err := s.userRepo.FindUserByID(ctx, userID, &user); err != nil {
@@ -474,6 +812,15 @@ func (s *AuthService) findUser(ctx context.Context, userID uint) (*model.User, e
 	return &user, nil
 }
 
+// checkPassword 敏感操作前确认密码。OIDC 自动注册的账号没有密码，没有可确认的东西，
+// 持有有效会话即可；通过找回密码或修改密码设置过密码后就和普通账号一样要求
+func checkPassword(user *model.User, password string) bool {
+	if user.PasswordHash == "" {
+		return true
+	}
+	return bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)) == nil
+}
+
 func (s *AuthService) revokeSession(ctx context.Context, session *model.Session, reason string) error {
 	now := time.Now()
 
@@ -0,0 +1,77 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"lesson10/internal/dto"
+	"lesson10/internal/model"
+	"lesson10/internal/pkg/cache"
+	"time"
+)
+
+// 缓存的都是和当前用户无关的部分：草稿权限、是否已关注这类按请求的人现算。
+// 写路径提交后主动删除；作者改头像这类牵连较广的改动不逐条删，靠过期时间兜底
+const (
+	listCachePageSize = 20 // 只缓存首页默认每页条数的列表，前端首页就是这么请求的
+)
+
+func postDetailKey(postID uint) string {
+	return fmt.Sprintf("post:%d", postID)
+}
+
+// postListKey type 为 0 表示不过滤类型
+func postListKey(postType uint8) string {
+	return fmt.Sprintf("posts:first:%d", postType)
+}
+
+func userInfoKey(userID uint) string {
+	return fmt.Sprintf("user:%d:info", userID)
+}
+
+func userPostsKey(userID uint) string {
+	return fmt.Sprintf("user:%d:posts", userID)
+}
+
+func followCountsKey(userID uint) string {
+	return fmt.Sprintf("user:%d:follow_counts", userID)
+}
+
+type cachedPostList struct {
+	Items []dto.PostListItem
+	Total int64
+}
+
+// cachedUserInfo 用户主页里不随访问者变化的资料
+type cachedUserInfo struct {
+	ID           uint
+	Username     string
+	Profile      string
+	AvatarURL    string
+	Role         model.Role
+	VIPExpiresAt *time.Time
+}
+
+type cachedUserPosts struct {
+	Posts []dto.PostSummary
+	Total int64
+}
+
+type cachedFollowCounts struct {
+	Following int64
+	Followers int64
+}
+
+// invalidatePost 帖子详情、首页列表和作者主页的帖子列表
+func invalidatePost(ctx context.Context, c *cache.Cache, postID uint, authorID uint) {
+	c.Delete(ctx,
+		postDetailKey(postID),
+		postListKey(0),
+		postListKey(uint8(model.PostArticle)),
+		postListKey(uint8(model.PostQuestion)),
+		userPostsKey(authorID),
+	)
+}
+
+func invalidateUser(ctx context.Context, c *cache.Cache, userID uint) {
+	c.Delete(ctx, userInfoKey(userID))
+}
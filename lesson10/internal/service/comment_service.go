@@ -6,8 +6,9 @@ import (
 	"lesson10/internal/dto"
 	"lesson10/internal/model"
 	"lesson10/internal/pkg/errcode"
+	"lesson10/internal/pkg/tracing"
 	"lesson10/internal/repository"
-	"log"
+	"log/slog"
 
 	"gorm.io/gorm"
 )
@@ -18,6 +19,7 @@ type CommentService struct {
 	commentRepo      repository.CommentRepository
 	notificationRepo repository.NotificationRepository
 	reactionRepo     repository.ReactionRepository
+	searchSvc        *SearchService
 }
 
 func NewCommentService(userRepo repository.UserRepository, postRepo repository.PostRepository, commentRepo repository.CommentRepository, notificationRepo repository.NotificationRepository, reactionRepo repository.ReactionRepository) *CommentService {
@@ -30,7 +32,14 @@ func NewCommentService(userRepo repository.UserRepository, postRepo repository.P
 	}
 }
 
+func (r *CommentService) SetSearchService(searchSvc *SearchService) {
+	r.searchSvc = searchSvc
+}
+
 func (r *CommentService) PostCommentService(ctx context.Context, id uint, req *dto.PostCommentRequest) (*model.Comment, error) {
+	ctx, span := tracing.Start(ctx, "CommentService.PostComment")
+	defer span.End()
+
 	var pDepth uint8 = 0
 	if req.TargetType == 3 {
 		var parent model.Comment
@@ -45,7 +54,7 @@ func (r *CommentService) PostCommentService(ctx context.Context, id uint, req *d
 
 		pDepth = parent.Depth
 
-		if pDepth >= 7 {
+		if pDepth >= model.CommentMaxDepth {
 			return nil, err
 		}
 
@@ -67,9 +76,12 @@ func (r *CommentService) PostCommentService(ctx context.Context, id uint, req *d
 		Depth:      pDepth + 1,
 	}
 
-	if err := r.commentRepo.CreateComment(ctx, comment); err != nil {
+	if err := r.commentRepo.CreateComment(ctx, &comment); err != nil {
 		return nil, errcode.ErrInternal
 	}
+	if r.searchSvc != nil {
+		r.searchSvc.IndexComment(ctx, &comment)
+	}
 
 	//通知
 	var receiverID uint
@@ -115,6 +127,9 @@ func (r *CommentService) PostCommentService(ctx context.Context, id uint, req *d
 }
 
 func (r *CommentService) GetCommentsService(ctx context.Context, req *dto.GetCommentsReq) (*dto.GetCommentsResp, error) {
+	ctx, span := tracing.Start(ctx, "CommentService.GetComments")
+	defer span.End()
+
 	var comments []model.Comment
 
 	offset := (req.Page - 1) * req.Size
@@ -128,14 +143,14 @@ func (r *CommentService) GetCommentsService(ctx context.Context, req *dto.GetCom
 	// 只查一级评论
 	total, err := r.commentRepo.CountRootComments(ctx, req.TargetType, req.TargetID)
 	if err != nil {
-		log.Printf("查询评论总数失败: %v", err)
+		slog.ErrorContext(ctx, "count comments failed", "error", err)
 		return nil, errcode.ErrInternal
 	}
 
 	// 分页 排序
 	comments, err = r.commentRepo.ListRootComments(ctx, req)
 	if err != nil {
-		log.Printf("查询评论列表失败: %v", err)
+		slog.ErrorContext(ctx, "list comments failed", "error", err)
 		return nil, errcode.ErrInternal
 	}
 
@@ -156,7 +171,7 @@ func (r *CommentService) GetCommentsService(ctx context.Context, req *dto.GetCom
 
 	authorMap, err := r.userRepo.BatchGetUserBasicInfo(ctx, authorIDs)
 	if err != nil {
-		log.Printf("批量查询作者失败: %v", err)
+		slog.ErrorContext(ctx, "batch get comment authors failed", "error", err)
 	}
 
 	// 组装 DTO
@@ -216,7 +231,7 @@ func (r *CommentService) GetAllReplies(ctx context.Context, parentID uint, curre
 
 	authorMap, err := r.userRepo.BatchGetUserBasicInfo(ctx, authorIDs)
 	if err != nil {
-		log.Printf("批量查询作者失败: %v", err)
+		slog.ErrorContext(ctx, "batch get comment authors failed", "error", err)
 	}
 
 	// 批量查 is_liked（当前用户是否点赞这些评论）
@@ -266,12 +281,21 @@ func (r *CommentService) DeleteComment(ctx context.Context, commentID, uid uint,
 		return errcode.ErrUnauthorized
 	}
 
-	// 如果评论是一级评论，递归删除所有子评论
-	if comment.TargetType != model.CommentOnComment {
-		r.commentRepo.DeleteSubComments(ctx, comment.ID)
-
+	// 不管是几级评论，先删掉下面所有的回复，再删除自己
+	subIDs, err := r.commentRepo.DeleteSubComments(ctx, comment.ID)
+	if err != nil {
+		slog.ErrorContext(ctx, "delete sub comments failed", "comment_id", comment.ID, "error", err)
+		return errcode.ErrInternal
+	}
+	if err := r.commentRepo.DeleteComment(ctx, comment); err != nil {
+		return err
+	}
+	if r.searchSvc != nil {
+		r.searchSvc.RemoveComment(ctx, comment.ID)
+		for _, id := range subIDs {
+			r.searchSvc.RemoveComment(ctx, id)
+		}
 	}
 
-	// 如果不是一级评论，删除自己。如果是一级评论，在递归删掉子评论后删除自己
-	return r.commentRepo.DeleteComment(ctx, comment)
+	return nil
 }
@@ -0,0 +1,52 @@
+package service
+
+import (
+	"context"
+	"lesson10/internal/repository"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// dailyActivity 记录日活。每个请求都会经过，进程里记住当天已经写过的用户，每人每天每个实例最多写一次库
+type dailyActivity struct {
+	repo repository.StatsRepository
+
+	mu   sync.Mutex
+	day  time.Time
+	seen map[int64]struct{}
+}
+
+func newDailyActivity(repo repository.StatsRepository) *dailyActivity {
+	return &dailyActivity{repo: repo, seen: map[int64]struct{}{}}
+}
+
+// mark 写库失败只打日志，不影响请求本身；失败的下次请求再试
+func (a *dailyActivity) mark(ctx context.Context, userID int64, now time.Time) {
+	if a == nil || userID == 0 {
+		return
+	}
+	day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+
+	a.mu.Lock()
+	if !a.day.Equal(day) {
+		a.day = day
+		a.seen = map[int64]struct{}{}
+	}
+	_, done := a.seen[userID]
+	a.mu.Unlock()
+	if done {
+		return
+	}
+
+	if err := a.repo.MarkActive(ctx, userID, day); err != nil {
+		slog.WarnContext(ctx, "record daily active user failed", "user_id", userID, "error", err)
+		return
+	}
+
+	a.mu.Lock()
+	if a.day.Equal(day) {
+		a.seen[userID] = struct{}{}
+	}
+	a.mu.Unlock()
+}
@@ -0,0 +1,339 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"lesson10/internal/config"
+	"lesson10/internal/model"
+	"lesson10/internal/pkg/errcode"
+	"lesson10/internal/pkg/mailer"
+	"lesson10/internal/pkg/utils"
+	"lesson10/internal/repository"
+	"log/slog"
+	"net/url"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+const mailSendTimeout = 10 * time.Second
+
+func NormalizeEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+type EmailService struct {
+	userRepo  repository.UserRepository
+	tokenRepo repository.EmailTokenRepository
+	eventRepo repository.SecurityEventRepository
+	authSvc   *AuthService
+	mail      mailer.Mailer
+	baseURL   string // 邮件里的链接指向前端
+	db        *gorm.DB
+	// verifyTTL / resetTTL 邮箱验证和重置密码链接的有效期
+	verifyTTL time.Duration
+	resetTTL  time.Duration
+}
+
+func NewEmailService(
+	userRepo repository.UserRepository,
+	tokenRepo repository.EmailTokenRepository,
+	eventRepo repository.SecurityEventRepository,
+	authSvc *AuthService,
+	mail mailer.Mailer,
+	baseURL string,
+	db *gorm.DB,
+	cfg config.MailConfig,
+) *EmailService {
+	return &EmailService{
+		userRepo:  userRepo,
+		tokenRepo: tokenRepo,
+		eventRepo: eventRepo,
+		authSvc:   authSvc,
+		mail:      mail,
+		baseURL:   strings.TrimRight(baseURL, "/"),
+		db:        db,
+		verifyTTL: cfg.VerifyTokenTTL,
+		resetTTL:  cfg.ResetTokenTTL,
+	}
+}
+
+// SendVerification 生成新的验证链接，旧链接同时作废
+func (s *EmailService) SendVerification(ctx context.Context, user *model.User) error {
+	if user.Email == nil || *user.Email == "" {
+		return errcode.ErrBadRequest
+	}
+
+	rawToken, err := s.issueToken(ctx, int64(user.ID), model.EmailTokenVerify, *user.Email, s.verifyTTL)
+	if err != nil {
+		return errcode.ErrInternal
+	}
+
+	body := fmt.Sprintf(
+		"你好 %s：\n\n请打开下面的链接完成邮箱验证（%d 小时内有效）：\n%s/verify-email?token=%s\n\n如果不是你本人操作，请忽略这封邮件。\n",
+		user.Username, int(s.verifyTTL.Hours()), s.baseURL, url.QueryEscape(rawToken),
+	)
+	if err := s.send(ctx, *user.Email, "验证你的邮箱", body); err != nil {
+		return errcode.ErrInternal
+	}
+
+	return nil
+}
+
+func (s *EmailService) ResendVerification(ctx context.Context, userID uint) error {
+	user, err := s.findUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	if user.Email == nil || *user.Email == "" {
+		return errcode.ErrBadRequest
+	}
+	if user.EmailVerifiedAt != nil {
+		return errcode.ErrConflict
+	}
+
+	return s.SendVerification(ctx, user)
+}
+
+func (s *EmailService) VerifyEmail(ctx context.Context, rawToken string) error {
+	now := time.Now()
+
+	return s.consumeToken(ctx, rawToken, model.EmailTokenVerify, now, func(tx *gorm.DB, record *model.EmailToken) error {
+		ok, err := s.userRepo.MarkEmailVerifiedTx(ctx, tx, uint(record.UserID), record.Email, now)
+		if err != nil {
+			return errcode.ErrInternal
+		}
+		// 发出链接之后又换绑了邮箱
+		if !ok {
+			return errcode.ErrLinkExpired
+		}
+
+		return nil
+	})
+}
+
+// ChangeEmail 换绑邮箱需要密码，换绑后回到未验证状态
+func (s *EmailService) ChangeEmail(ctx context.Context, userID uint, email string, password string, ip string, userAgent string) error {
+	user, err := s.findUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	if !checkPassword(user, password) {
+		return errcode.ErrForbidden
+	}
+
+	email = NormalizeEmail(email)
+	if user.Email != nil && *user.Email == email {
+		return errcode.ErrConflict
+	}
+
+	exists, err := s.userRepo.ExistsByEmail(ctx, email)
+	if err != nil {
+		return errcode.ErrInternal
+	}
+	if exists {
+		return errcode.ErrConflict
+	}
+
+	if err := s.userRepo.UpdateEmail(ctx, userID, email); err != nil {
+		return errcode.ErrInternal
+	}
+
+	oldEmail := ""
+	if user.Email != nil {
+		oldEmail = *user.Email
+	}
+	_ = s.authSvc.recordEventWithRepo(ctx, s.eventRepo, int64(userID), "", "email_changed", ip, "", userAgent, "from="+oldEmail+" to="+email)
+
+	user.Email = &email
+	user.EmailVerifiedAt = nil
+	return s.SendVerification(ctx, user)
+}
+
+// RequestPasswordReset 不管邮箱是否存在都返回成功，避免被用来探测注册邮箱
+func (s *EmailService) RequestPasswordReset(ctx context.Context, email string) error {
+	email = NormalizeEmail(email)
+	if email == "" {
+		return nil
+	}
+
+	user, err := s.userRepo.FindUserByEmail(ctx, email)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return errcode.ErrInternal
+	}
+
+	// 未验证的邮箱不一定属于本人，不能用来重置密码
+	if user.EmailVerifiedAt == nil {
+		return nil
+	}
+
+	rawToken, err := s.issueToken(ctx, int64(user.ID), model.EmailTokenPasswordReset, email, s.resetTTL)
+	if err != nil {
+		return errcode.ErrInternal
+	}
+
+	body := fmt.Sprintf(
+		"你好 %s：\n\n我们收到了重置密码的请求，请在 %d 分钟内打开下面的链接设置新密码，链接只能使用一次：\n%s/reset-password?token=%s\n\n如果不是你本人操作，请忽略这封邮件，你的密码不会改变。\n",
+		user.Username, int(s.resetTTL.Minutes()), s.baseURL, url.QueryEscape(rawToken),
+	)
+	if err := s.send(ctx, email, "重置密码", body); err != nil {
+		return errcode.ErrInternal
+	}
+
+	return nil
+}
+
+// ResetPassword 消费重置链接，修改密码并踢掉所有会话
+func (s *EmailService) ResetPassword(ctx context.Context, rawToken string, newPassword string, ip string, userAgent string) error {
+	if newPassword == "" {
+		return errcode.ErrBadRequest
+	}
+
+	hashed, err := bcrypt.GenerateFromPassword([]byte(newPassword), bcrypt.DefaultCost)
+	if err != nil {
+		return errcode.ErrInternal
+	}
+
+	now := time.Now()
+	return s.consumeToken(ctx, rawToken, model.EmailTokenPasswordReset, now, func(tx *gorm.DB, record *model.EmailToken) error {
+		if err := s.userRepo.ChangePassWordTx(ctx, tx, uint(record.UserID), string(hashed)); err != nil {
+			return errcode.ErrInternal
+		}
+
+		if err := s.tokenRepo.WithTx(tx).InvalidateActive(ctx, record.UserID, model.EmailTokenPasswordReset, now); err != nil {
+			return errcode.ErrInternal
+		}
+
+		sessionRepo := s.authSvc.sessionRepo.WithTx(tx)
+		refreshRepo := s.authSvc.refreshRepo.WithTx(tx)
+		if err := s.authSvc.revokeAllSessionsWithRepo(ctx, sessionRepo, refreshRepo, record.UserID, "password_reset", now); err != nil {
+			return errcode.ErrInternal
+		}
+
+		if err := s.authSvc.recordEventWithRepo(ctx, s.eventRepo.WithTx(tx), record.UserID, "", "password_reset", ip, "", userAgent, "password reset via email"); err != nil {
+			return errcode.ErrInternal
+		}
+
+		return nil
+	})
+}
+
+// NotifyNewDeviceLogin 异步发送新设备登录提醒，只发给已验证的邮箱
+func (s *EmailService) NotifyNewDeviceLogin(user *model.User, session *model.Session) {
+	if user.Email == nil || user.EmailVerifiedAt == nil {
+		return
+	}
+
+	to := *user.Email
+	body := fmt.Sprintf(
+		"你好 %s：\n\n你的账号刚刚在新设备上登录：\n\n时间：%s\n设备：%s\n浏览器：%s %s\n系统：%s\nIP：%s\n\n如果不是你本人操作，请立即修改密码并在会话管理中下线该设备。\n",
+		user.Username,
+		session.CreatedAt.Format("2006-01-02 15:04:05"),
+		session.DeviceName,
+		session.BrowserName, session.BrowserVersion,
+		session.OSName,
+		session.LoginIP,
+	)
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), mailSendTimeout)
+		defer cancel()
+
+		if err := s.send(ctx, to, "新设备登录提醒", body); err != nil {
+			slog.ErrorContext(ctx, "send new device alert failed", "target_user_id", user.ID, "error", err)
+		}
+	}()
+}
+
+func (s *EmailService) issueToken(ctx context.Context, userID int64, purpose string, email string, ttl time.Duration) (string, error) {
+	rawToken, err := utils.NewToken(32)
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		tokenRepo := s.tokenRepo.WithTx(tx)
+		if err := tokenRepo.InvalidateActive(ctx, userID, purpose, now); err != nil {
+			return err
+		}
+
+		return tokenRepo.Create(ctx, &model.EmailToken{
+			UserID:    userID,
+			Purpose:   purpose,
+			Email:     email,
+			TokenHash: utils.HashToken(rawToken),
+			ExpiresAt: now.Add(ttl),
+		})
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return rawToken, nil
+}
+
+// consumeToken 锁住 token 行，校验用途、是否用过、是否过期，通过后在同一事务里执行 apply
+func (s *EmailService) consumeToken(
+	ctx context.Context,
+	rawToken string,
+	purpose string,
+	now time.Time,
+	apply func(tx *gorm.DB, record *model.EmailToken) error,
+) error {
+	rawToken = strings.TrimSpace(rawToken)
+	if rawToken == "" {
+		return errcode.ErrBadRequest
+	}
+
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		tokenRepo := s.tokenRepo.WithTx(tx)
+
+		record, err := tokenRepo.GetByTokenHashForUpdate(ctx, utils.HashToken(rawToken))
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errcode.ErrLinkExpired
+			}
+			return errcode.ErrInternal
+		}
+
+		if record.Purpose != purpose || record.UsedAt != nil || now.After(record.ExpiresAt) {
+			return errcode.ErrLinkExpired
+		}
+
+		if err := apply(tx, record); err != nil {
+			return err
+		}
+
+		record.UsedAt = &now
+		if err := tokenRepo.Update(ctx, record); err != nil {
+			return errcode.ErrInternal
+		}
+
+		return nil
+	})
+}
+
+func (s *EmailService) send(ctx context.Context, to string, subject string, body string) error {
+	return s.mail.Send(ctx, mailer.Message{To: to, Subject: subject, Body: body})
+}
+
+func (s *EmailService) findUser(ctx context.Context, userID uint) (*model.User, error) {
+	var user model.User
+	if err := s.userRepo.FindUserByID(ctx, userID, &user); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errcode.ErrNotFound
+		}
+		return nil, errcode.ErrInternal
+	}
+
+	return &user, nil
+}
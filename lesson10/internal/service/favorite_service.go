@@ -6,7 +6,7 @@ import (
 	"lesson10/internal/model"
 	"lesson10/internal/pkg/errcode"
 	"lesson10/internal/repository"
-	"log"
+	"log/slog"
 	"time"
 
 	"gorm.io/gorm"
@@ -36,16 +36,16 @@ func (r *FavoriteService) ToggleFavoriteService(ctx context.Context, uid uint, t
 			// 已收藏 → 取消
 			result := r.favoriteRepo.DeleteFav(ctx, uid, targetType, targetID)
 			if result.Error != nil {
-				log.Printf("delete favorite failed (attempt %d): %v", attempt, result.Error)
+				slog.ErrorContext(ctx, "delete favorite failed", "attempt", attempt, "error", result.Error)
 				time.Sleep(50 * time.Millisecond)
 				continue
 			}
-			log.Printf("delete favorite rows affected: %d", result.RowsAffected)
+			slog.DebugContext(ctx, "favorite deleted", "rows_affected", result.RowsAffected)
 			return &[]bool{false}[0], nil
 		}
 
 		if !errors.Is(err, gorm.ErrRecordNotFound) {
-			log.Printf("first favorite failed (attempt %d): %v", attempt, err)
+			slog.ErrorContext(ctx, "find favorite failed", "attempt", attempt, "error", err)
 			time.Sleep(50 * time.Millisecond)
 			continue
 		}
@@ -58,11 +58,11 @@ func (r *FavoriteService) ToggleFavoriteService(ctx context.Context, uid uint, t
 		}
 		if err = r.favoriteRepo.CreateFav(ctx, newFav); err != nil {
 			if errors.Is(err, gorm.ErrDuplicatedKey) {
-				log.Printf("duplicate key on create favorite (attempt %d), retrying...", attempt)
+				slog.WarnContext(ctx, "duplicate key on create favorite, retrying", "attempt", attempt)
 				time.Sleep(50 * time.Millisecond)
 				continue
 			}
-			log.Printf("create favorite failed (attempt %d): %v", attempt, err)
+			slog.ErrorContext(ctx, "create favorite failed", "attempt", attempt, "error", err)
 			return nil, errcode.ErrInternal
 		}
 
@@ -4,15 +4,17 @@ import (
 	"context"
 	"lesson10/internal/dto"
 	"lesson10/internal/model"
+	"lesson10/internal/pkg/cache"
 	"lesson10/internal/pkg/errcode"
 	"lesson10/internal/repository"
-	"log"
+	"log/slog"
 	"strings"
 )
 
 type FollowService struct {
 	userRepo   repository.UserRepository
 	followRepo repository.FollowRepository
+	cache      *cache.Cache
 }
 
 func NewFollowService(followRepo repository.FollowRepository, userRepo repository.UserRepository) *FollowService {
@@ -22,6 +24,10 @@ func NewFollowService(followRepo repository.FollowRepository, userRepo repositor
 	}
 }
 
+func (r *FollowService) SetCache(c *cache.Cache) {
+	r.cache = c
+}
+
 func (r *FollowService) FollowUserService(ctx context.Context, followerID, followeeID uint) error {
 	// 1. 校验被关注者存在
 	exists, err := r.userRepo.ExistsByUserID(ctx, followeeID)
@@ -40,6 +46,7 @@ func (r *FollowService) FollowUserService(ctx context.Context, followerID, follo
 
 	createErr := r.followRepo.CreateFollow(ctx, follow)
 	if createErr == nil {
+		r.cache.Delete(ctx, followCountsKey(followerID), followCountsKey(followeeID))
 		return nil
 	}
 
@@ -49,7 +56,7 @@ func (r *FollowService) FollowUserService(ctx context.Context, followerID, follo
 	}
 
 	// 其他错误
-	log.Printf("create follow failed: %v", createErr)
+	slog.ErrorContext(ctx, "create follow failed", "error", createErr)
 	return errcode.ErrInternal
 }
 
@@ -63,6 +70,7 @@ func (r *FollowService) UnfollowUserService(ctx context.Context, followerID, fol
 	if result.RowsAffected == 0 {
 		return errcode.ErrHasNotFollowed
 	}
+	r.cache.Delete(ctx, followCountsKey(followerID), followCountsKey(followeeID))
 
 	return nil
 }
@@ -100,7 +108,7 @@ func (r *FollowService) GetFollowListService(ctx context.Context, targetUserID u
 	// 3. 批量查用户信息（用户名、头像、简介）
 	userMap, err := r.userRepo.BatchGetUserBasicInfo(ctx, followIDs)
 	if err != nil {
-		log.Printf("批量查用户失败: %v", err)
+		slog.ErrorContext(ctx, "batch get users failed", "error", err)
 		// 降级：继续返回空用户名
 	}
 
@@ -109,7 +117,7 @@ func (r *FollowService) GetFollowListService(ctx context.Context, targetUserID u
 	if currentUserID > 0 {
 		isFollowedMap, err = r.followRepo.BatchIsFollowing(ctx, currentUserID, followIDs)
 		if err != nil {
-			log.Printf("批量查关注状态失败: %v", err)
+			slog.ErrorContext(ctx, "batch get follow status failed", "error", err)
 			// 降级：全部设为 false
 		}
 	}
@@ -0,0 +1,91 @@
+package service
+
+import (
+	"context"
+	"lesson10/internal/dto"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+)
+
+const readinessCheckTimeout = 2 * time.Second
+
+// HealthService /readyz 的依赖检查：MySQL 必查，Redis 只在缓存或限流配置成 redis 时检查。
+// 收到退出信号后先标记 draining，负载均衡摘掉这个实例，再等进行中的请求处理完
+type HealthService struct {
+	db       *gorm.DB
+	redis    *redis.Client
+	draining atomic.Bool
+}
+
+func NewHealthService(db *gorm.DB) *HealthService {
+	return &HealthService{db: db}
+}
+
+func (s *HealthService) SetRedis(client *redis.Client) {
+	s.redis = client
+}
+
+func (s *HealthService) SetDraining() {
+	s.draining.Store(true)
+}
+
+// Ready 各项检查并发执行，每项最多等 2 秒
+func (s *HealthService) Ready(ctx context.Context) (*dto.Readiness, bool) {
+	checks := map[string]func(ctx context.Context) error{
+		"mysql": func(ctx context.Context) error {
+			sqlDB, err := s.db.DB()
+			if err != nil {
+				return err
+			}
+			return sqlDB.PingContext(ctx)
+		},
+	}
+	if s.redis != nil {
+		checks["redis"] = func(ctx context.Context) error {
+			return s.redis.Ping(ctx).Err()
+		}
+	}
+
+	resp := &dto.Readiness{Status: "ok", Checks: make(map[string]string, len(checks))}
+	var (
+		mu sync.Mutex
+		wg sync.WaitGroup
+	)
+	for name, check := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			checkCtx, cancel := context.WithTimeout(ctx, readinessCheckTimeout)
+			defer cancel()
+
+			result := "ok"
+			if err := check(checkCtx); err != nil {
+				result = err.Error()
+			}
+			mu.Lock()
+			resp.Checks[name] = result
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	ready := true
+	for _, result := range resp.Checks {
+		if result != "ok" {
+			ready = false
+		}
+	}
+	if s.draining.Load() {
+		resp.Checks["server"] = "shutting down"
+		ready = false
+	}
+	if !ready {
+		resp.Status = "unavailable"
+	}
+
+	return resp, ready
+}
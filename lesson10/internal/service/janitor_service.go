@@ -0,0 +1,224 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"lesson10/internal/config"
+	"lesson10/internal/dto"
+	"lesson10/internal/pkg/metrics"
+	"lesson10/internal/repository"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// JanitorService 定期清理过期的 refresh token 和会话；配置了 janitor.archive_dir 时删除前先按天追加写入 jsonl
+type JanitorService struct {
+	janitorRepo repository.JanitorRepository
+	cfg         config.JanitorConfig
+	// refreshRetention 用过/吊销的 refresh token 保留到所属会话一定已经失效，
+	// 这段时间内旧 token 被重放还能识别出来并吊销整个会话
+	refreshRetention time.Duration
+
+	mu    sync.Mutex
+	stats dto.JanitorStats
+}
+
+func NewJanitorService(janitorRepo repository.JanitorRepository, cfg config.JanitorConfig, refreshRetention time.Duration) *JanitorService {
+	return &JanitorService{
+		janitorRepo:      janitorRepo,
+		cfg:              cfg,
+		refreshRetention: refreshRetention,
+	}
+}
+
+func (s *JanitorService) RunWorker(ctx context.Context) {
+	ticker := time.NewTicker(s.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		report := s.RunOnce(ctx, false)
+		if report.Error != "" {
+			slog.ErrorContext(ctx, "janitor run failed", "error", report.Error)
+		} else if report.RefreshTokens+report.SessionRefreshTokens+report.Sessions > 0 {
+			slog.InfoContext(ctx, "janitor run done", "refresh_tokens", report.RefreshTokens+report.SessionRefreshTokens, "sessions", report.Sessions, "duration_ms", report.DurationMs)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// RunOnce dryRun 时只统计会被清理的行数，不做任何修改
+func (s *JanitorService) RunOnce(ctx context.Context, dryRun bool) dto.JanitorReport {
+	started := time.Now()
+	report := dto.JanitorReport{DryRun: dryRun, StartedAt: started.Unix()}
+
+	err := s.purgeRefreshTokens(ctx, started.Add(-s.refreshRetention), dryRun, &report)
+	if err == nil {
+		err = s.purgeSessions(ctx, started.Add(-s.cfg.SessionRetention), dryRun, &report)
+	}
+	if err != nil {
+		report.Error = err.Error()
+	}
+	report.DurationMs = time.Since(started).Milliseconds()
+
+	if !dryRun {
+		s.record(report)
+	}
+
+	return report
+}
+
+func (s *JanitorService) Stats() dto.JanitorStats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stats := s.stats
+	if stats.LastRun != nil {
+		last := *stats.LastRun
+		stats.LastRun = &last
+	}
+
+	return stats
+}
+
+func (s *JanitorService) record(report dto.JanitorReport) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.stats.Runs++
+	if report.Error != "" {
+		s.stats.Failures++
+	}
+	s.stats.RefreshTokensDeleted += report.RefreshTokens + report.SessionRefreshTokens
+	s.stats.SessionsDeleted += report.Sessions
+	s.stats.LastRun = &report
+
+	result := metrics.JanitorOK
+	if report.Error != "" {
+		result = metrics.JanitorFailed
+	}
+	metrics.JanitorRuns.WithLabelValues(result).Inc()
+	metrics.JanitorRowsDeleted.WithLabelValues("refresh_tokens").Add(float64(report.RefreshTokens + report.SessionRefreshTokens))
+	metrics.JanitorRowsDeleted.WithLabelValues("sessions").Add(float64(report.Sessions))
+}
+
+func (s *JanitorService) purgeRefreshTokens(ctx context.Context, cutoff time.Time, dryRun bool, report *dto.JanitorReport) error {
+	var afterID int64
+	for {
+		tokens, err := s.janitorRepo.ListStaleRefreshTokens(ctx, cutoff, afterID, s.cfg.BatchSize)
+		if err != nil || len(tokens) == 0 {
+			return err
+		}
+		afterID = tokens[len(tokens)-1].ID
+		report.Batches++
+
+		if dryRun {
+			report.RefreshTokens += int64(len(tokens))
+		} else {
+			if err := archiveRows(s.cfg.ArchiveDir, "refresh_tokens", tokens); err != nil {
+				return err
+			}
+
+			ids := make([]int64, 0, len(tokens))
+			for _, token := range tokens {
+				ids = append(ids, token.ID)
+			}
+			deleted, err := s.janitorRepo.DeleteRefreshTokens(ctx, ids)
+			if err != nil {
+				return err
+			}
+			report.RefreshTokens += deleted
+		}
+
+		if len(tokens) < s.cfg.BatchSize {
+			return nil
+		}
+		if err := pause(ctx, s.cfg.BatchPause); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *JanitorService) purgeSessions(ctx context.Context, cutoff time.Time, dryRun bool, report *dto.JanitorReport) error {
+	var afterID int64
+	for {
+		sessions, err := s.janitorRepo.ListStaleSessions(ctx, cutoff, afterID, s.cfg.BatchSize)
+		if err != nil || len(sessions) == 0 {
+			return err
+		}
+		afterID = sessions[len(sessions)-1].ID
+		report.Batches++
+
+		if dryRun {
+			report.Sessions += int64(len(sessions))
+		} else {
+			if err := archiveRows(s.cfg.ArchiveDir, "sessions", sessions); err != nil {
+				return err
+			}
+
+			deletedSessions, deletedTokens, err := s.janitorRepo.DeleteSessions(ctx, sessions)
+			if err != nil {
+				return err
+			}
+			report.Sessions += deletedSessions
+			report.SessionRefreshTokens += deletedTokens
+		}
+
+		if len(sessions) < s.cfg.BatchSize {
+			return nil
+		}
+		if err := pause(ctx, s.cfg.BatchPause); err != nil {
+			return err
+		}
+	}
+}
+
+// archiveRows 追加写到 <dir>/<table>-YYYYMMDD.jsonl，dir 为空时不归档
+func archiveRows[T any](dir string, table string, rows []T) error {
+	if dir == "" {
+		return nil
+	}
+
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return err
+	}
+
+	name := filepath.Join(dir, table+"-"+time.Now().Format("20060102")+".jsonl")
+	f, err := os.OpenFile(name, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	enc := json.NewEncoder(f)
+	for i := range rows {
+		if err := enc.Encode(&rows[i]); err != nil {
+			return err
+		}
+	}
+
+	return f.Sync()
+}
+
+func pause(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return ctx.Err()
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,55 @@
+package service
+
+import (
+	"context"
+	"lesson10/internal/config"
+	"lesson10/internal/model"
+	"lesson10/internal/repository"
+	"testing"
+	"time"
+)
+
+// TestJanitorKeepsRevokedTokensOfLiveSessions “记住我”的会话比 refresh token 有效期活得久，
+// 轮换下来的旧 token 要一直留着，会话还在时被重放才能识别出来
+func TestJanitorKeepsRevokedTokensOfLiveSessions(t *testing.T) {
+	db := newTestDB(t)
+	ctx := context.Background()
+	now := time.Now()
+
+	session := model.Session{SessionID: "live", UserID: 1, Status: "active", RememberMe: true, LastSeenAt: now, CreatedAt: now.Add(-60 * 24 * time.Hour)}
+	if err := db.Create(&session).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	tokens := map[string]*model.RefreshToken{
+		// 超过了 7 天的 refresh token 有效期，但会话还在线
+		"rotated": {SessionID: "live", UserID: 1, TokenHash: "rotated", Status: "used", ExpiresAt: now.Add(20 * 24 * time.Hour), UpdatedAt: now.Add(-10 * 24 * time.Hour)},
+		// 会话最长也活不了这么久
+		"ancient": {SessionID: "live", UserID: 1, TokenHash: "ancient", Status: "revoked", ExpiresAt: now.Add(-95 * 24 * time.Hour), UpdatedAt: now.Add(-100 * 24 * time.Hour)},
+		"current": {SessionID: "live", UserID: 1, TokenHash: "current", Status: "active", ExpiresAt: now.Add(30 * 24 * time.Hour)},
+	}
+	for _, token := range tokens {
+		if err := db.Create(token).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	cfg := config.Default()
+	if got := cfg.RefreshRetention(); got != cfg.Session.RememberMaxLifetime {
+		t.Fatalf("refresh retention = %v, want %v", got, cfg.Session.RememberMaxLifetime)
+	}
+	janitor := NewJanitorService(repository.NewJanitorRepo(db), cfg.Janitor, cfg.RefreshRetention())
+
+	report := janitor.RunOnce(ctx, false)
+	if report.Error != "" || report.RefreshTokens != 1 || report.Sessions != 0 {
+		t.Fatalf("report = %+v", report)
+	}
+
+	var left []string
+	if err := db.Model(&model.RefreshToken{}).Order("token_hash").Pluck("token_hash", &left).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(left) != 2 || left[0] != "current" || left[1] != "rotated" {
+		t.Fatalf("tokens left = %v", left)
+	}
+}
@@ -0,0 +1,221 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"lesson10/internal/config"
+	"lesson10/internal/model"
+	"lesson10/internal/pkg/errcode"
+	"lesson10/internal/pkg/metrics"
+	"lesson10/internal/repository"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+const loginBackoffBase = time.Second
+
+// 用户名不存在时也跑一次 bcrypt，让响应时间和密码错误一致
+var dummyPasswordHash, _ = bcrypt.GenerateFromPassword([]byte("lesson10-dummy-password"), bcrypt.DefaultCost)
+
+// loginPolicy 前 FreeAttempts 次失败不限制，之后每次失败等待时间翻倍（封顶 MaxBackoff）；
+// 账号连续失败达到 LockThreshold 次直接锁定 LockDuration。超过 Window 没有新的失败则计数清零
+type loginPolicy struct {
+	FreeAttempts  int
+	MaxBackoff    time.Duration
+	LockThreshold int
+	LockDuration  time.Duration
+	Window        time.Duration
+}
+
+func accountLoginPolicy(cfg config.LoginConfig) loginPolicy {
+	return loginPolicy{
+		FreeAttempts:  cfg.AccountFreeAttempts,
+		MaxBackoff:    cfg.MaxBackoff,
+		LockThreshold: cfg.LockThreshold,
+		LockDuration:  cfg.LockDuration,
+		Window:        cfg.FailureWindow,
+	}
+}
+
+// ipLoginPolicy 一个 IP 后面可能有很多人（NAT、公司出口），阈值放宽，也不做整段锁定
+func ipLoginPolicy(cfg config.LoginConfig) loginPolicy {
+	return loginPolicy{
+		FreeAttempts: cfg.IPFreeAttempts,
+		MaxBackoff:   cfg.MaxBackoff,
+		Window:       cfg.FailureWindow,
+	}
+}
+
+// locked 失败次数达到阈值且锁定还没到期；阈值以下的等待期不算锁定
+func (p loginPolicy) locked(throttle *model.LoginThrottle, now time.Time) bool {
+	return p.LockThreshold > 0 && throttle.Failures >= p.LockThreshold &&
+		throttle.LockedUntil != nil && now.Before(*throttle.LockedUntil)
+}
+
+func (p loginPolicy) backoff(failures int) time.Duration {
+	over := failures - p.FreeAttempts
+	if over <= 0 {
+		return 0
+	}
+	if over > 20 {
+		return p.MaxBackoff
+	}
+
+	d := loginBackoffBase << (over - 1)
+	if d > p.MaxBackoff {
+		return p.MaxBackoff
+	}
+	return d
+}
+
+// loginAccountKey 按用户名而不是用户 ID 计数，不存在的用户名也会被同样限制，避免通过锁定行为探测用户是否存在
+func loginAccountKey(username string) string {
+	return strings.ToLower(strings.TrimSpace(username))
+}
+
+// guardLogin 密码登录和第三方登录都要先过这一关，锁定的账号换一种登录方式也进不来
+func (s *AuthService) guardLogin(ctx context.Context, accountKey string, ip string, now time.Time) error {
+	err := s.checkLoginThrottle(ctx, accountKey, ip, now)
+	if errors.Is(err, errcode.ErrLoginLocked) {
+		metrics.LoginFailures.WithLabelValues(metrics.LoginLocked).Inc()
+	}
+	return err
+}
+
+// checkLoginThrottle 账号或 IP 任一处于等待/锁定期内都拒绝，不校验密码
+func (s *AuthService) checkLoginThrottle(ctx context.Context, accountKey string, ip string, now time.Time) error {
+	if s.throttleRepo == nil {
+		return nil
+	}
+
+	for _, target := range [][2]string{{model.LoginThrottleAccount, accountKey}, {model.LoginThrottleIP, ip}} {
+		if target[1] == "" {
+			continue
+		}
+
+		throttle, err := s.throttleRepo.Get(ctx, target[0], target[1])
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				continue
+			}
+			return errcode.ErrInternal
+		}
+
+		if throttle.LockedUntil != nil && now.Before(*throttle.LockedUntil) {
+			return errcode.ErrLoginLocked
+		}
+	}
+
+	return nil
+}
+
+// recordLoginFailure 账号和 IP 计数各加一，写一条 eventType 事件（login_failed / 2fa_failed）；账号每次进入锁定时写 account_locked
+func (s *AuthService) recordLoginFailure(ctx context.Context, accountKey string, user *model.User, eventType string, ip string, userAgent string, now time.Time) error {
+	if s.throttleRepo == nil {
+		return nil
+	}
+
+	var userID int64
+	if user != nil {
+		userID = int64(user.ID)
+	}
+
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		repo := s.throttleRepo.WithTx(tx)
+		eventRepo := s.eventRepo.WithTx(tx)
+
+		account, locked, err := bumpThrottle(ctx, repo, model.LoginThrottleAccount, accountKey, s.accountLogin, now)
+		if err != nil {
+			return err
+		}
+
+		if ip != "" {
+			if _, _, err := bumpThrottle(ctx, repo, model.LoginThrottleIP, ip, s.ipLogin, now); err != nil {
+				return err
+			}
+		}
+
+		detail := fmt.Sprintf("username=%s failures=%d", accountKey, account.Failures)
+		if err := s.recordEventWithRepo(ctx, eventRepo, userID, "", eventType, ip, "", userAgent, detail); err != nil {
+			return err
+		}
+
+		// 锁定期间继续失败不再重复记录；锁定到期后在窗口内再失败会重新锁定，那一次要记
+		if userID != 0 && locked {
+			detail := fmt.Sprintf("locked until %s", account.LockedUntil.Format(time.RFC3339))
+			return s.recordEventWithRepo(ctx, eventRepo, userID, "", "account_locked", ip, "", userAgent, detail)
+		}
+
+		return nil
+	})
+}
+
+// bumpThrottle 失败次数加一并算出新的等待时间，locked 表示这次失败让它从未锁定变成锁定
+func bumpThrottle(ctx context.Context, repo repository.LoginThrottleRepository, scope string, key string, policy loginPolicy, now time.Time) (*model.LoginThrottle, bool, error) {
+	throttle, err := repo.GetOrCreateForUpdate(ctx, scope, key, now)
+	if err != nil {
+		return nil, false, err
+	}
+	wasLocked := policy.locked(throttle, now)
+
+	if now.Sub(throttle.LastFailedAt) > policy.Window {
+		throttle.Failures = 0
+	}
+
+	throttle.Failures++
+	throttle.LastFailedAt = now
+
+	wait := policy.backoff(throttle.Failures)
+	if policy.LockThreshold > 0 && throttle.Failures >= policy.LockThreshold && policy.LockDuration > wait {
+		wait = policy.LockDuration
+	}
+
+	if wait > 0 {
+		until := now.Add(wait)
+		throttle.LockedUntil = &until
+	} else {
+		throttle.LockedUntil = nil
+	}
+
+	if err := repo.Save(ctx, throttle); err != nil {
+		return nil, false, err
+	}
+
+	return throttle, !wasLocked && policy.locked(throttle, now), nil
+}
+
+// resetLoginFailures 登录成功只清账号计数；IP 计数靠时间窗口自然过期，防止攻击者用自己的账号刷新 IP 计数
+func (s *AuthService) resetLoginFailures(ctx context.Context, accountKey string) {
+	if s.throttleRepo == nil {
+		return
+	}
+
+	_ = s.throttleRepo.Delete(ctx, model.LoginThrottleAccount, accountKey)
+}
+
+// UnlockAccount 管理员手动解除账号锁定
+func (s *AuthService) UnlockAccount(ctx context.Context, adminID uint, userID uint, ip string, userAgent string) error {
+	user, err := s.findUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if s.throttleRepo != nil {
+			if err := s.throttleRepo.WithTx(tx).Delete(ctx, model.LoginThrottleAccount, loginAccountKey(user.Username)); err != nil {
+				return errcode.ErrInternal
+			}
+		}
+
+		detail := fmt.Sprintf("unlocked by admin %d", adminID)
+		if err := s.recordEventWithRepo(ctx, s.eventRepo.WithTx(tx), int64(userID), "", "account_unlocked", ip, "", userAgent, detail); err != nil {
+			return errcode.ErrInternal
+		}
+
+		return nil
+	})
+}
@@ -0,0 +1,121 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"lesson10/internal/model"
+	"lesson10/internal/pkg/errcode"
+	"testing"
+	"time"
+)
+
+func newTestUser(t *testing.T, authSvc *AuthService, username string) *model.User {
+	t.Helper()
+
+	user := &model.User{Username: username, PasswordHash: string(dummyPasswordHash)}
+	if err := authSvc.db.Create(user).Error; err != nil {
+		t.Fatal(err)
+	}
+	return user
+}
+
+func countEvents(t *testing.T, authSvc *AuthService, userID uint, eventType string) int64 {
+	t.Helper()
+
+	var n int64
+	if err := authSvc.db.Model(&model.SecurityEvent{}).Where("user_id = ? AND event_type = ?", userID, eventType).Count(&n).Error; err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func TestAccountLockedRecordedOncePerLock(t *testing.T) {
+	authSvc := newTestAuthService(t, newTestDB(t))
+	user := newTestUser(t, authSvc, "dave")
+	ctx := context.Background()
+	now := time.Now()
+
+	threshold := authSvc.accountLogin.LockThreshold
+	for i := 0; i < threshold+3; i++ {
+		if err := authSvc.recordLoginFailure(ctx, loginAccountKey(user.Username), user, "login_failed", "10.0.0.1", "test", now); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if n := countEvents(t, authSvc, user.ID, "login_failed"); n != int64(threshold+3) {
+		t.Fatalf("login_failed events = %d, want %d", n, threshold+3)
+	}
+	if n := countEvents(t, authSvc, user.ID, "account_locked"); n != 1 {
+		t.Fatalf("account_locked events = %d, want 1", n)
+	}
+	if err := authSvc.guardLogin(ctx, loginAccountKey(user.Username), "", now); !errors.Is(err, errcode.ErrLoginLocked) {
+		t.Fatalf("err = %v, want ErrLoginLocked", err)
+	}
+
+	if err := authSvc.UnlockAccount(ctx, 1, user.ID, "10.0.0.2", "test"); err != nil {
+		t.Fatal(err)
+	}
+	if err := authSvc.guardLogin(ctx, loginAccountKey(user.Username), "", now); err != nil {
+		t.Fatalf("after unlock: err = %v", err)
+	}
+}
+
+// TestAccountLockedRecordedOnRelock 锁定到期后计数还在窗口内，下一次失败重新锁定，也要记一条 account_locked
+func TestAccountLockedRecordedOnRelock(t *testing.T) {
+	authSvc := newTestAuthService(t, newTestDB(t))
+	user := newTestUser(t, authSvc, "quinn")
+	ctx := context.Background()
+	accountKey := loginAccountKey(user.Username)
+	policy := authSvc.accountLogin
+	now := time.Now()
+
+	fail := func(at time.Time) {
+		t.Helper()
+		if err := authSvc.recordLoginFailure(ctx, accountKey, user, "login_failed", "10.0.0.1", "test", at); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for i := 0; i < policy.LockThreshold; i++ {
+		fail(now)
+	}
+	if n := countEvents(t, authSvc, user.ID, "account_locked"); n != 1 {
+		t.Fatalf("account_locked events after first lock = %d, want 1", n)
+	}
+
+	// 锁定到期，但还在失败窗口内，计数没有清零
+	expired := now.Add(policy.LockDuration + time.Second)
+	if policy.LockDuration+time.Second >= policy.Window {
+		t.Fatalf("test assumes lock duration %s < window %s", policy.LockDuration, policy.Window)
+	}
+	if err := authSvc.guardLogin(ctx, accountKey, "", expired); err != nil {
+		t.Fatalf("after lock expired: err = %v", err)
+	}
+
+	fail(expired)
+	if n := countEvents(t, authSvc, user.ID, "account_locked"); n != 2 {
+		t.Fatalf("account_locked events after relock = %d, want 2", n)
+	}
+	if err := authSvc.guardLogin(ctx, accountKey, "", expired); !errors.Is(err, errcode.ErrLoginLocked) {
+		t.Fatalf("after relock: err = %v, want ErrLoginLocked", err)
+	}
+
+	// 重新锁定期间继续失败不重复记录
+	fail(expired)
+	if n := countEvents(t, authSvc, user.ID, "account_locked"); n != 2 {
+		t.Fatalf("account_locked events while relocked = %d, want 2", n)
+	}
+}
+
+func TestUnlockAccountWithoutThrottleRepo(t *testing.T) {
+	authSvc := newTestAuthService(t, newTestDB(t))
+	authSvc.throttleRepo = nil
+	user := newTestUser(t, authSvc, "erin")
+
+	if err := authSvc.UnlockAccount(context.Background(), 1, user.ID, "10.0.0.2", "test"); err != nil {
+		t.Fatal(err)
+	}
+	if n := countEvents(t, authSvc, user.ID, "account_unlocked"); n != 1 {
+		t.Fatalf("account_unlocked events = %d, want 1", n)
+	}
+}
@@ -0,0 +1,126 @@
+package service
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"lesson10/internal/config"
+	"lesson10/internal/model"
+	"lesson10/internal/pkg/token"
+	"lesson10/internal/repository"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// testTokens TestMain 里用临时生成的 Ed25519 密钥创建
+var testTokens *token.Manager
+
+// 服务层测试用内存 SQLite 跑真实的 repository，不依赖外部 MySQL/Redis
+func TestMain(m *testing.M) {
+	dir, err := os.MkdirTemp("", "lesson10-service-test")
+	if err != nil {
+		panic(err)
+	}
+	keyFile := filepath.Join(dir, "jwt.pem")
+
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		panic(err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		panic(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), 0o600); err != nil {
+		panic(err)
+	}
+	testTokens, err = token.New(token.Config{SigningKeyFile: keyFile})
+	if err != nil {
+		panic(err)
+	}
+
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
+func newTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+
+	// 用临时文件而不是内存库：事务里还有走另一个连接的查询，WAL 模式下读不会被写事务挡住
+	dsn := filepath.Join(t.TempDir(), "test.db") + "?_pragma=journal_mode(WAL)&_pragma=busy_timeout(5000)"
+	db, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { sqlDB.Close() })
+
+	err = db.AutoMigrate(
+		&model.User{},
+		&model.Session{},
+		&model.DailyActiveUser{},
+		&model.RefreshToken{},
+		&model.SecurityEvent{},
+		&model.UserIdentity{},
+		&model.OAuthState{},
+		&model.LoginThrottle{},
+		&model.UserTOTP{},
+		&model.TOTPRecoveryCode{},
+		&model.LoginChallenge{},
+		&model.Notification{},
+		&model.Post{},
+		&model.Comment{},
+		&model.PostImage{},
+		&model.PostImageRef{},
+		&model.ImageBlob{},
+		&model.AccountDeletion{},
+		&model.DataExport{},
+		&model.Favorite{},
+		&model.UserFollow{},
+		&model.QuestionFollow{},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+// testSessionConfig 普通会话空闲 1 小时、最长 1 天，“记住我”空闲 2 天、最长 3 天
+func testSessionConfig() config.SessionConfig {
+	return config.SessionConfig{
+		IdleTimeout:         time.Hour,
+		MaxLifetime:         24 * time.Hour,
+		RememberIdleTimeout: 48 * time.Hour,
+		RememberMaxLifetime: 72 * time.Hour,
+		MaxActive:           10,
+		LimitMode:           "evict",
+	}
+}
+
+func newTestAuthService(t *testing.T, db *gorm.DB) *AuthService {
+	t.Helper()
+
+	authSvc := NewAuthService(
+		repository.NewUserRepo(db),
+		repository.NewSessionRepo(db),
+		repository.NewRefreshTokenRepo(db),
+		repository.NewSecurityEventRepo(db),
+		db,
+		testTokens,
+		testSessionConfig(),
+		config.Default().Login,
+	)
+	authSvc.SetLoginThrottleRepo(repository.NewLoginThrottleRepo(db))
+	return authSvc
+}
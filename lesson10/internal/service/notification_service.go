@@ -6,7 +6,7 @@ import (
 	"lesson10/internal/model"
 	"lesson10/internal/pkg/errcode"
 	"lesson10/internal/repository"
-	"log"
+	"log/slog"
 )
 
 type NotificationService struct {
@@ -52,7 +52,7 @@ func (r *NotificationService) GetNotifications(ctx context.Context, uid uint, pa
 
 	actorMap, err := r.userRepo.BatchGetUsernames(ctx, actorIDs)
 	if err != nil {
-		log.Printf("batch get usernames failed: %v", err)
+		slog.ErrorContext(ctx, "batch get usernames failed", "error", err)
 	}
 
 	for _, actorID := range actorIDs {
@@ -0,0 +1,371 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"lesson10/internal/dto"
+	"lesson10/internal/model"
+	"lesson10/internal/pkg/errcode"
+	"lesson10/internal/pkg/oidc"
+	"lesson10/internal/pkg/utils"
+	"lesson10/internal/repository"
+	"log/slog"
+	"sort"
+	"strings"
+	"time"
+
+	"golang.org/x/oauth2"
+	"gorm.io/gorm"
+)
+
+const oauthStateTTL = 10 * time.Minute
+
+type OAuthService struct {
+	providers    map[string]*oidc.Provider
+	identityRepo repository.IdentityRepository
+	userRepo     repository.UserRepository
+	eventRepo    repository.SecurityEventRepository
+	authSvc      *AuthService
+	db           *gorm.DB
+}
+
+func NewOAuthService(
+	providers map[string]*oidc.Provider,
+	identityRepo repository.IdentityRepository,
+	userRepo repository.UserRepository,
+	eventRepo repository.SecurityEventRepository,
+	authSvc *AuthService,
+	db *gorm.DB,
+) *OAuthService {
+	return &OAuthService{
+		providers:    providers,
+		identityRepo: identityRepo,
+		userRepo:     userRepo,
+		eventRepo:    eventRepo,
+		authSvc:      authSvc,
+		db:           db,
+	}
+}
+
+func (s *OAuthService) Providers() []string {
+	names := make([]string, 0, len(s.providers))
+	for name := range s.providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Begin 生成 state/nonce/PKCE verifier 存到服务端，返回 provider 的授权地址
+func (s *OAuthService) Begin(ctx context.Context, providerName string, purpose string, userID uint, deviceID string, deviceName string, rememberMe bool) (*dto.OAuthAuthorize, error) {
+	provider, ok := s.providers[providerName]
+	if !ok {
+		return nil, errcode.ErrNotFound
+	}
+
+	state, err := utils.NewToken(32)
+	if err != nil {
+		return nil, errcode.ErrInternal
+	}
+	nonce, err := utils.NewToken(16)
+	if err != nil {
+		return nil, errcode.ErrInternal
+	}
+	verifier := oauth2.GenerateVerifier()
+
+	authorizeURL, err := provider.AuthCodeURL(ctx, state, nonce, verifier)
+	if err != nil {
+		slog.ErrorContext(ctx, "oauth provider failed", "provider", providerName, "error", err)
+		return nil, errcode.ErrProviderUnavailable
+	}
+
+	record := &model.OAuthState{
+		StateHash:    utils.HashToken(state),
+		Provider:     providerName,
+		Purpose:      purpose,
+		UserID:       int64(userID),
+		Nonce:        nonce,
+		CodeVerifier: verifier,
+		DeviceID:     strings.TrimSpace(deviceID),
+		DeviceName:   strings.TrimSpace(deviceName),
+		RememberMe:   rememberMe,
+		ExpiresAt:    time.Now().Add(oauthStateTTL),
+	}
+	if err := s.identityRepo.CreateState(ctx, record); err != nil {
+		return nil, errcode.ErrInternal
+	}
+
+	return &dto.OAuthAuthorize{
+		AuthorizeURL: authorizeURL,
+		State:        state,
+		ExpiresAt:    record.ExpiresAt.Unix(),
+	}, nil
+}
+
+// LoginCallback 已绑定的外部账号直接登录；没绑定过的自动注册一个新用户。账号或 IP 被锁定时和密码登录一样拒绝
+func (s *OAuthService) LoginCallback(ctx context.Context, providerName string, req dto.OAuthCallbackRequest, ip string, userAgent string) (*LoginResult, error) {
+	state, claims, err := s.finish(ctx, providerName, req, model.OAuthPurposeLogin)
+	if err != nil {
+		return nil, err
+	}
+
+	// 和密码登录走同一套锁定：账号被锁时绑定的第三方账号也登录不了，新注册的只看 IP
+	now := time.Now()
+	identity, err := s.identityRepo.GetByProviderSubject(ctx, providerName, claims.Subject)
+	var user *model.User
+	switch {
+	case err == nil:
+		user, err = s.authSvc.findUser(ctx, uint(identity.UserID))
+		if err != nil {
+			return nil, err
+		}
+		if err := s.authSvc.guardLogin(ctx, loginAccountKey(user.Username), ip, now); err != nil {
+			return nil, err
+		}
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		if err := s.authSvc.guardLogin(ctx, "", ip, now); err != nil {
+			return nil, err
+		}
+		user, err = s.registerFromClaims(ctx, providerName, claims)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		return nil, errcode.ErrInternal
+	}
+
+	_ = s.authSvc.recordEventWithRepo(ctx, s.eventRepo, int64(user.ID), "", "oauth_login", ip, state.DeviceID, userAgent, "provider="+providerName)
+
+	return s.authSvc.completeLogin(ctx, user, state.DeviceID, state.DeviceName, state.RememberMe, ip, userAgent)
+}
+
+// LinkCallback 把外部账号绑定到当前登录用户，state 必须是这个用户发起的
+func (s *OAuthService) LinkCallback(ctx context.Context, userID uint, providerName string, req dto.OAuthCallbackRequest, ip string, userAgent string) (*dto.IdentityInfo, error) {
+	state, claims, err := s.finish(ctx, providerName, req, model.OAuthPurposeLink)
+	if err != nil {
+		return nil, err
+	}
+	if state.UserID != int64(userID) {
+		return nil, errcode.ErrOAuthStateInvalid
+	}
+
+	existing, err := s.identityRepo.GetByProviderSubject(ctx, providerName, claims.Subject)
+	if err == nil {
+		if existing.UserID == int64(userID) {
+			info := toIdentityInfo(existing)
+			return &info, nil
+		}
+		// 这个外部账号已经绑在别的用户上
+		return nil, errcode.ErrConflict
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, errcode.ErrInternal
+	}
+
+	identity := &model.UserIdentity{
+		UserID:   int64(userID),
+		Provider: providerName,
+		Subject:  claims.Subject,
+		Email:    claims.Email,
+	}
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := s.identityRepo.WithTx(tx).Create(ctx, identity); err != nil {
+			// 同一 provider 已经绑过另一个外部账号（唯一索引冲突）
+			return errcode.ErrConflict
+		}
+
+		return s.authSvc.recordEventWithRepo(ctx, s.eventRepo.WithTx(tx), int64(userID), "", "identity_linked", ip, "", userAgent, "provider="+providerName)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	info := toIdentityInfo(identity)
+	return &info, nil
+}
+
+func (s *OAuthService) ListIdentities(ctx context.Context, userID uint) ([]dto.IdentityInfo, error) {
+	identities, err := s.identityRepo.ListByUserID(ctx, int64(userID))
+	if err != nil {
+		return nil, errcode.ErrInternal
+	}
+
+	result := make([]dto.IdentityInfo, 0, len(identities))
+	for i := range identities {
+		result = append(result, toIdentityInfo(&identities[i]))
+	}
+
+	return result, nil
+}
+
+// Unlink 解绑后用户必须还有别的登录方式（密码或其它第三方账号）
+func (s *OAuthService) Unlink(ctx context.Context, userID uint, providerName string, ip string, userAgent string) error {
+	user, err := s.authSvc.findUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	identities, err := s.identityRepo.ListByUserID(ctx, int64(userID))
+	if err != nil {
+		return errcode.ErrInternal
+	}
+	if user.PasswordHash == "" && len(identities) <= 1 {
+		return errcode.ErrConflict
+	}
+
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		deleted, err := s.identityRepo.WithTx(tx).DeleteByUserProvider(ctx, int64(userID), providerName)
+		if err != nil {
+			return errcode.ErrInternal
+		}
+		if !deleted {
+			return errcode.ErrNotFound
+		}
+
+		return s.authSvc.recordEventWithRepo(ctx, s.eventRepo.WithTx(tx), int64(userID), "", "identity_unlinked", ip, "", userAgent, "provider="+providerName)
+	})
+}
+
+// finish 消费 state（一次性、未过期、provider 和用途一致），再用 code + verifier 换 token 并校验 nonce
+func (s *OAuthService) finish(ctx context.Context, providerName string, req dto.OAuthCallbackRequest, purpose string) (*model.OAuthState, *oidc.Claims, error) {
+	provider, ok := s.providers[providerName]
+	if !ok {
+		return nil, nil, errcode.ErrNotFound
+	}
+
+	now := time.Now()
+	var state *model.OAuthState
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		repo := s.identityRepo.WithTx(tx)
+
+		var err error
+		state, err = repo.GetStateByHashForUpdate(ctx, utils.HashToken(strings.TrimSpace(req.State)))
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errcode.ErrOAuthStateInvalid
+			}
+			return errcode.ErrInternal
+		}
+
+		if state.UsedAt != nil || now.After(state.ExpiresAt) || state.Provider != providerName || state.Purpose != purpose {
+			return errcode.ErrOAuthStateInvalid
+		}
+
+		state.UsedAt = &now
+		if err := repo.UpdateState(ctx, state); err != nil {
+			return errcode.ErrInternal
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	claims, err := provider.Exchange(ctx, strings.TrimSpace(req.Code), state.CodeVerifier, state.Nonce)
+	if err != nil {
+		slog.ErrorContext(ctx, "oauth provider failed", "provider", providerName, "error", err)
+		return nil, nil, errcode.ErrOAuthStateInvalid
+	}
+
+	return state, claims, nil
+}
+
+// registerFromClaims 自动注册的用户没有密码，provider 给了已验证邮箱的可以走找回密码设置一个
+func (s *OAuthService) registerFromClaims(ctx context.Context, providerName string, claims *oidc.Claims) (*model.User, error) {
+	username, err := s.pickUsername(ctx, providerName, claims)
+	if err != nil {
+		return nil, err
+	}
+
+	user := &model.User{Username: username}
+
+	email := NormalizeEmail(claims.Email)
+	if email != "" && claims.EmailVerified {
+		exists, err := s.userRepo.ExistsByEmail(ctx, email)
+		if err != nil {
+			return nil, errcode.ErrInternal
+		}
+		// 邮箱已属于本地账号时不自动合并，避免接管别人的账号，需要登录后手动绑定
+		if exists {
+			return nil, errcode.ErrIdentityNotLinked
+		}
+
+		now := time.Now()
+		user.Email = &email
+		user.EmailVerifiedAt = &now
+	}
+
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := s.userRepo.CreateUserTx(ctx, tx, user); err != nil {
+			return err
+		}
+
+		return s.identityRepo.WithTx(tx).Create(ctx, &model.UserIdentity{
+			UserID:   int64(user.ID),
+			Provider: providerName,
+			Subject:  claims.Subject,
+			Email:    claims.Email,
+		})
+	})
+	if err != nil {
+		return nil, errcode.ErrInternal
+	}
+
+	return user, nil
+}
+
+func (s *OAuthService) pickUsername(ctx context.Context, providerName string, claims *oidc.Claims) (string, error) {
+	base := sanitizeUsername(claims.PreferredUsername)
+	if base == "" {
+		base = sanitizeUsername(strings.SplitN(claims.Email, "@", 2)[0])
+	}
+	if base == "" {
+		base = providerName + "_user"
+	}
+
+	candidate := base
+	for i := 0; i < 5; i++ {
+		exists, err := s.userRepo.ExistsByUsername(ctx, candidate)
+		if err != nil {
+			return "", errcode.ErrInternal
+		}
+		if !exists {
+			return candidate, nil
+		}
+
+		suffix, err := utils.NewToken(3)
+		if err != nil {
+			return "", errcode.ErrInternal
+		}
+		candidate = fmt.Sprintf("%s_%s", base, suffix)
+	}
+
+	return "", errcode.ErrConflict
+}
+
+func sanitizeUsername(raw string) string {
+	name := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_', r == '-', r == '.':
+			return r
+		default:
+			return -1
+		}
+	}, raw)
+
+	if len(name) > 48 {
+		name = name[:48]
+	}
+	return name
+}
+
+func toIdentityInfo(identity *model.UserIdentity) dto.IdentityInfo {
+	return dto.IdentityInfo{
+		Provider:  identity.Provider,
+		Subject:   identity.Subject,
+		Email:     identity.Email,
+		CreatedAt: identity.CreatedAt.Unix(),
+	}
+}
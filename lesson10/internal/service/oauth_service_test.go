@@ -0,0 +1,183 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"lesson10/internal/dto"
+	"lesson10/internal/model"
+	"lesson10/internal/pkg/errcode"
+	"lesson10/internal/pkg/oidc"
+	"lesson10/internal/pkg/oidc/mockoidc"
+	"lesson10/internal/repository"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func newTestOAuthService(t *testing.T) (*OAuthService, *AuthService) {
+	t.Helper()
+
+	srv := httptest.NewUnstartedServer(nil)
+	mock, err := mockoidc.New(mockoidc.Config{Issuer: "http://" + srv.Listener.Addr().String(), ClientID: "lesson10", ClientSecret: "secret"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv.Config.Handler = mock.Handler()
+	srv.Start()
+	t.Cleanup(srv.Close)
+
+	providers := map[string]*oidc.Provider{
+		"mock": oidc.NewProvider(oidc.Config{
+			Name:         "mock",
+			Issuer:       srv.URL,
+			ClientID:     "lesson10",
+			ClientSecret: "secret",
+			RedirectURL:  "http://localhost:3000/oauth/mock/callback",
+		}),
+	}
+
+	db := newTestDB(t)
+	authSvc := newTestAuthService(t, db)
+	oauthSvc := NewOAuthService(providers, repository.NewIdentityRepo(db), repository.NewUserRepo(db), repository.NewSecurityEventRepo(db), authSvc, db)
+	return oauthSvc, authSvc
+}
+
+// oauthCallback 走一遍 Begin → provider 授权页，返回前端会带回来的 code 和 state
+func oauthCallback(t *testing.T, oauthSvc *OAuthService, subject string) dto.OAuthCallbackRequest {
+	t.Helper()
+
+	begin, err := oauthSvc.Begin(context.Background(), "mock", model.OAuthPurposeLogin, 0, "device-1", "test", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	u, err := url.Parse(begin.AuthorizeURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := u.Query()
+	q.Set("user", subject)
+	q.Set("email", subject+"@example.com")
+	u.RawQuery = q.Encode()
+
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+	resp, err := client.Get(u.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	callback, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if callback.Query().Get("state") != begin.State {
+		t.Fatalf("provider returned state %q, want %q", callback.Query().Get("state"), begin.State)
+	}
+	return dto.OAuthCallbackRequest{Code: callback.Query().Get("code"), State: begin.State}
+}
+
+func TestOAuthLoginCallbackStateIsSingleUse(t *testing.T) {
+	oauthSvc, _ := newTestOAuthService(t)
+	ctx := context.Background()
+
+	req := oauthCallback(t, oauthSvc, "alice")
+	result, err := oauthSvc.LoginCallback(ctx, "mock", req, "10.0.0.1", "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Pair == nil || result.User == nil {
+		t.Fatalf("login did not issue tokens: %+v", result)
+	}
+
+	if _, err := oauthSvc.LoginCallback(ctx, "mock", req, "10.0.0.1", "test"); !errors.Is(err, errcode.ErrOAuthStateInvalid) {
+		t.Fatalf("replayed state: err = %v, want ErrOAuthStateInvalid", err)
+	}
+
+	// 同一个外部账号再登录一次，还是原来的用户
+	again, err := oauthSvc.LoginCallback(ctx, "mock", oauthCallback(t, oauthSvc, "alice"), "10.0.0.1", "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.User.ID != result.User.ID {
+		t.Fatalf("second login got user %d, want %d", again.User.ID, result.User.ID)
+	}
+}
+
+func TestOAuthLoginCallbackRespectsAccountLock(t *testing.T) {
+	oauthSvc, authSvc := newTestOAuthService(t)
+	ctx := context.Background()
+
+	result, err := oauthSvc.LoginCallback(ctx, "mock", oauthCallback(t, oauthSvc, "bob"), "10.0.0.1", "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lockedUntil := time.Now().Add(time.Hour)
+	err = authSvc.db.Create(&model.LoginThrottle{
+		Scope:        model.LoginThrottleAccount,
+		Key:          loginAccountKey(result.User.Username),
+		Failures:     10,
+		LastFailedAt: time.Now(),
+		LockedUntil:  &lockedUntil,
+	}).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := oauthSvc.LoginCallback(ctx, "mock", oauthCallback(t, oauthSvc, "bob"), "10.0.0.2", "test"); !errors.Is(err, errcode.ErrLoginLocked) {
+		t.Fatalf("locked account: err = %v, want ErrLoginLocked", err)
+	}
+}
+
+func TestOAuthLoginCallbackRespectsIPLock(t *testing.T) {
+	oauthSvc, authSvc := newTestOAuthService(t)
+	ctx := context.Background()
+
+	lockedUntil := time.Now().Add(time.Hour)
+	err := authSvc.db.Create(&model.LoginThrottle{
+		Scope:        model.LoginThrottleIP,
+		Key:          "10.0.0.9",
+		Failures:     50,
+		LastFailedAt: time.Now(),
+		LockedUntil:  &lockedUntil,
+	}).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := oauthSvc.LoginCallback(ctx, "mock", oauthCallback(t, oauthSvc, "carol"), "10.0.0.9", "test"); !errors.Is(err, errcode.ErrLoginLocked) {
+		t.Fatalf("locked ip: err = %v, want ErrLoginLocked", err)
+	}
+}
+
+// TestPasswordlessAccountActions 自动注册的账号没有密码，需要确认密码的操作不能因此用不了；设置密码后恢复校验
+func TestPasswordlessAccountActions(t *testing.T) {
+	oauthSvc, authSvc := newTestOAuthService(t)
+	ctx := context.Background()
+
+	result, err := oauthSvc.LoginCallback(ctx, "mock", oauthCallback(t, oauthSvc, "nina"), "10.0.0.1", "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	userID := result.User.ID
+
+	if err := authSvc.RevokeSession(ctx, userID, result.Pair.SessionId, ""); err != nil {
+		t.Fatalf("revoke session without password: %v", err)
+	}
+
+	userSvc := NewUserService(repository.NewUserRepo(authSvc.db), nil, nil, authSvc.db)
+	userSvc.SetAuthService(authSvc)
+	if err := userSvc.ChangePassService(ctx, dto.ChangePassRequest{NewPass: "new-password"}, userID); err != nil {
+		t.Fatalf("set first password: %v", err)
+	}
+
+	if err := authSvc.LogoutAll(ctx, userID, ""); !errors.Is(err, errcode.ErrPasswordIncorrect) {
+		t.Fatalf("logout all without password after setting one: err = %v", err)
+	}
+	if err := authSvc.LogoutAll(ctx, userID, "new-password"); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,71 @@
+package service
+
+import (
+	"context"
+	"lesson10/internal/model"
+	"lesson10/internal/pkg/markdown"
+	"log/slog"
+)
+
+const postRenderBatchSize = 200
+
+func renderPostContent(content string) (string, string, error) {
+	contentHTML, err := markdown.Render(content)
+	if err != nil {
+		return "", "", err
+	}
+
+	return contentHTML, markdown.Excerpt(contentHTML, markdown.ExcerptLength), nil
+}
+
+// ensureRendered 缓存是旧版本渲染的（或者还没渲染过）就当场重新渲染并写回
+func (r *PostService) ensureRendered(ctx context.Context, p *model.Post) error {
+	if p.RenderVersion == markdown.Version {
+		return nil
+	}
+
+	contentHTML, excerpt, err := renderPostContent(p.Content)
+	if err != nil {
+		return err
+	}
+	p.ContentHTML, p.Excerpt, p.RenderVersion = contentHTML, excerpt, markdown.Version
+
+	if err := r.postRepo.SaveRendered(ctx, p.ID, contentHTML, excerpt, markdown.Version); err != nil {
+		slog.ErrorContext(ctx, "save rendered post failed", "post_id", p.ID, "error", err)
+	}
+
+	return nil
+}
+
+// RenderStalePosts 启动时把老帖子和渲染规则升级前的帖子补渲染一遍，列表页的摘要依赖它
+func (r *PostService) RenderStalePosts(ctx context.Context) {
+	var afterID uint
+	rendered := 0
+	for {
+		posts, err := r.postRepo.ListStaleRendered(ctx, markdown.Version, afterID, postRenderBatchSize)
+		if err != nil {
+			slog.ErrorContext(ctx, "render stale posts failed", "error", err)
+			return
+		}
+		if len(posts) == 0 {
+			break
+		}
+
+		for i := range posts {
+			if err := r.ensureRendered(ctx, &posts[i]); err != nil {
+				slog.ErrorContext(ctx, "render post failed", "post_id", posts[i].ID, "error", err)
+				continue
+			}
+			rendered++
+		}
+		afterID = posts[len(posts)-1].ID
+
+		if len(posts) < postRenderBatchSize {
+			break
+		}
+	}
+
+	if rendered > 0 {
+		slog.InfoContext(ctx, "rendered stale posts", "count", rendered, "markdown_version", markdown.Version)
+	}
+}
@@ -3,10 +3,15 @@ package service
 import (
 	"context"
 	"errors"
+	"lesson10/internal/config"
 	"lesson10/internal/dto"
 	"lesson10/internal/model"
+	"lesson10/internal/pkg/cache"
 	"lesson10/internal/pkg/errcode"
+	"lesson10/internal/pkg/markdown"
+	"lesson10/internal/pkg/tracing"
 	"lesson10/internal/repository"
+	"log/slog"
 	"strings"
 	"time"
 
@@ -17,6 +22,10 @@ type PostService struct {
 	userRepo     repository.UserRepository
 	postRepo     repository.PostRepository
 	favoriteRepo repository.FavoriteRepository
+	uploadSvc    *UploadService
+	searchSvc    *SearchService
+	cache        *cache.Cache
+	cacheTTL     config.CacheTTL
 }
 
 func NewPostService(userRepo repository.UserRepository, postRepo repository.PostRepository, favoriteRepo repository.FavoriteRepository) *PostService {
@@ -27,7 +36,33 @@ func NewPostService(userRepo repository.UserRepository, postRepo repository.Post
 	}
 }
 
+func (r *PostService) SetUploadService(uploadSvc *UploadService) {
+	r.uploadSvc = uploadSvc
+}
+
+func (r *PostService) SetCache(c *cache.Cache, ttl config.CacheTTL) {
+	r.cache = c
+	r.cacheTTL = ttl
+}
+
+func (r *PostService) SetSearchService(searchSvc *SearchService) {
+	r.searchSvc = searchSvc
+}
+
+// syncImages 失败只记日志：帖子已经保存了，清理任务删图片之前会按正文补上漏掉的引用
+func (r *PostService) syncImages(ctx context.Context, postID uint, content string) {
+	if r.uploadSvc == nil {
+		return
+	}
+	if err := r.uploadSvc.SyncPostImages(ctx, postID, content); err != nil {
+		slog.ErrorContext(ctx, "sync post images failed", "post_id", postID, "error", err)
+	}
+}
+
 func (r *PostService) CreatePostService(ctx context.Context, req *dto.CreatePostRequest, authorID uint) (*model.Post, error) {
+	ctx, span := tracing.Start(ctx, "PostService.CreatePost")
+	defer span.End()
+
 	title := strings.TrimSpace(req.Title)
 	if title == "" {
 		return nil, errcode.ErrBadRequest
@@ -37,22 +72,38 @@ func (r *PostService) CreatePostService(ctx context.Context, req *dto.CreatePost
 		return nil, errcode.ErrBadRequest
 	}
 
+	contentHTML, excerpt, err := renderPostContent(req.Content)
+	if err != nil {
+		return nil, errcode.ErrInternal
+	}
+
 	p := &model.Post{
-		Type:     model.PostType(req.Type),
-		AuthorID: authorID,
-		Title:    title,
-		Content:  req.Content,
-		Status:   req.Status,
+		Type:          model.PostType(req.Type),
+		AuthorID:      authorID,
+		Title:         title,
+		Content:       req.Content,
+		Status:        req.Status,
+		ContentHTML:   contentHTML,
+		Excerpt:       excerpt,
+		RenderVersion: markdown.Version,
 	}
 
 	if err := r.postRepo.CreatePost(ctx, p); err != nil {
 		return nil, errcode.ErrInternal
 	}
+	r.syncImages(ctx, p.ID, p.Content)
+	invalidatePost(ctx, r.cache, p.ID, p.AuthorID)
+	if r.searchSvc != nil {
+		r.searchSvc.IndexPost(ctx, p)
+	}
 
 	return p, nil
 }
 
 func (r *PostService) ListPostsService(ctx context.Context, q dto.ListPostsQuery) ([]dto.PostListItem, int64, error) {
+	ctx, span := tracing.Start(ctx, "PostService.ListPosts")
+	defer span.End()
+
 	if q.Page <= 0 {
 		q.Page = 1
 	}
@@ -63,6 +114,18 @@ func (r *PostService) ListPostsService(ctx context.Context, q dto.ListPostsQuery
 		q.PageSize = 100
 	}
 
+	// 没有关键词的首页是访问最多的，单独缓存
+	if q.Page == 1 && q.PageSize == listCachePageSize && q.Type <= uint8(model.PostQuestion) && strings.TrimSpace(q.Keyword) == "" {
+		list, err := cache.Fetch(ctx, r.cache, postListKey(q.Type), r.cacheTTL.List, func(ctx context.Context) (cachedPostList, error) {
+			items, total, err := r.postRepo.ListPosts(ctx, q)
+			return cachedPostList{Items: items, Total: total}, err
+		})
+		if err != nil {
+			return nil, 0, errcode.ErrInternal
+		}
+		return list.Items, list.Total, nil
+	}
+
 	items, total, err := r.postRepo.ListPosts(ctx, q)
 	if err != nil {
 		return nil, 0, errcode.ErrInternal
@@ -72,6 +135,27 @@ func (r *PostService) ListPostsService(ctx context.Context, q dto.ListPostsQuery
 }
 
 func (r *PostService) GetPostService(ctx context.Context, currentID, id uint) (*dto.PostDetailResp, error) {
+	ctx, span := tracing.Start(ctx, "PostService.GetPost")
+	defer span.End()
+
+	resp, err := cache.Fetch(ctx, r.cache, postDetailKey(id), r.cacheTTL.Post, func(ctx context.Context) (*dto.PostDetailResp, error) {
+		return r.loadPostDetail(ctx, id)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.Status == 1 {
+		if resp.AuthorID != currentID {
+			return nil, errcode.ErrUnauthorized
+		}
+	}
+
+	return resp, nil
+}
+
+// loadPostDetail 草稿也照样缓存，是否有权查看由调用方判断
+func (r *PostService) loadPostDetail(ctx context.Context, id uint) (*dto.PostDetailResp, error) {
 	var p model.Post
 	if err := r.postRepo.FindPostByID(ctx, id, &p); err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
@@ -80,10 +164,8 @@ func (r *PostService) GetPostService(ctx context.Context, currentID, id uint) (*
 		return nil, errcode.ErrInternal
 	}
 
-	if p.Status == 1 {
-		if p.AuthorID != currentID {
-			return nil, errcode.ErrUnauthorized
-		}
+	if err := r.ensureRendered(ctx, &p); err != nil {
+		return nil, errcode.ErrInternal
 	}
 
 	return &dto.PostDetailResp{
@@ -94,6 +176,7 @@ func (r *PostService) GetPostService(ctx context.Context, currentID, id uint) (*
 		AuthorAvatarURL: p.Author.AvatarURL,
 		Title:           p.Title,
 		Content:         p.Content,
+		ContentHTML:     p.ContentHTML,
 		Status:          p.Status,
 		LikeCount:       p.LikeCount,
 		CreatedAt:       p.CreatedAt,
@@ -102,6 +185,9 @@ func (r *PostService) GetPostService(ctx context.Context, currentID, id uint) (*
 }
 
 func (r *PostService) UpdatePostService(ctx context.Context, PostID uint64, id uint, req *dto.UpdatePostRequest) error {
+	ctx, span := tracing.Start(ctx, "PostService.UpdatePost")
+	defer span.End()
+
 	var post model.Post
 	err := r.postRepo.FindPostByID(ctx, uint(PostID), &post)
 	if errors.Is(err, gorm.ErrRecordNotFound) {
@@ -120,7 +206,14 @@ func (r *PostService) UpdatePostService(ctx context.Context, PostID uint64, id u
 		updates["title"] = strings.TrimSpace(req.Title)
 	}
 	if req.Content != "" {
+		contentHTML, excerpt, err := renderPostContent(req.Content)
+		if err != nil {
+			return errcode.ErrInternal
+		}
 		updates["content"] = req.Content
+		updates["content_html"] = contentHTML
+		updates["excerpt"] = excerpt
+		updates["render_version"] = markdown.Version
 	}
 	if req.Status != nil {
 		updates["status"] = *req.Status
@@ -128,10 +221,30 @@ func (r *PostService) UpdatePostService(ctx context.Context, PostID uint64, id u
 
 	updates["updated_at"] = time.Now()
 
-	return r.postRepo.UpdatePost(ctx, updates, post)
+	if err := r.postRepo.UpdatePost(ctx, updates, post); err != nil {
+		return err
+	}
+	if req.Content != "" {
+		r.syncImages(ctx, post.ID, req.Content)
+	}
+	invalidatePost(ctx, r.cache, post.ID, post.AuthorID)
+	// 标题、正文、草稿状态都可能变了，重新读一遍整条写进索引
+	if r.searchSvc != nil {
+		var updated model.Post
+		if err := r.postRepo.FindPostByID(ctx, post.ID, &updated); err != nil {
+			slog.ErrorContext(ctx, "reload post for search index failed", "post_id", post.ID, "error", err)
+		} else {
+			r.searchSvc.IndexPost(ctx, &updated)
+		}
+	}
+
+	return nil
 }
 
 func (r *PostService) DeletePostService(ctx context.Context, postID, uid uint, role uint) error {
+	ctx, span := tracing.Start(ctx, "PostService.DeletePost")
+	defer span.End()
+
 	var post model.Post
 	err := r.postRepo.FindPostByID(ctx, postID, &post)
 	if errors.Is(err, gorm.ErrRecordNotFound) {
@@ -145,7 +258,15 @@ func (r *PostService) DeletePostService(ctx context.Context, postID, uid uint, r
 		return errcode.ErrUnauthorized
 	}
 
-	return r.postRepo.DeletePost(ctx, post)
+	if err := r.postRepo.DeletePost(ctx, post); err != nil {
+		return err
+	}
+	invalidatePost(ctx, r.cache, post.ID, post.AuthorID)
+	if r.searchSvc != nil {
+		r.searchSvc.RemovePost(ctx, post.ID)
+	}
+
+	return nil
 
 }
 
@@ -223,6 +344,7 @@ func (r *PostService) GetDraftService(ctx context.Context, uid uint, page, size
 			AuthorName:      p.Author.Username,
 			AuthorAvatarURL: p.Author.AvatarURL,
 			Title:           p.Title,
+			Excerpt:         p.Excerpt,
 			CreatedAt:       p.CreatedAt,
 		}
 	}
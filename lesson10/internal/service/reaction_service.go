@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"lesson10/internal/model"
+	"lesson10/internal/pkg/cache"
 	"lesson10/internal/pkg/errcode"
 	"lesson10/internal/repository"
 
@@ -16,6 +17,7 @@ type ReactionService struct {
 	commentRepo      repository.CommentRepository
 	notificationRepo repository.NotificationRepository
 	db               *gorm.DB
+	cache            *cache.Cache
 }
 
 func NewReactionService(reactionRepo repository.ReactionRepository, postRepo repository.PostRepository, commentRepo repository.CommentRepository, notificationRepo repository.NotificationRepository, db *gorm.DB) *ReactionService {
@@ -28,6 +30,10 @@ func NewReactionService(reactionRepo repository.ReactionRepository, postRepo rep
 	}
 }
 
+func (r *ReactionService) SetCache(c *cache.Cache) {
+	r.cache = c
+}
+
 // ToggleReactionService 切换点赞状态，返回操作后的“是否已点赞”
 func (r *ReactionService) ToggleReactionService(ctx context.Context, uid uint, targetType uint8, targetID uint) (*bool, error) {
 	   // 事务保证点赞状态和 like_count 一致性
@@ -130,5 +136,9 @@ func (r *ReactionService) ToggleReactionService(ctx context.Context, uid uint, t
 	if err != nil {
 		return nil, err
 	}
+	// 帖子详情里有 like_count
+	if targetType == 1 || targetType == 2 {
+		r.cache.Delete(ctx, postDetailKey(targetID))
+	}
 	return isLiked, nil
 }
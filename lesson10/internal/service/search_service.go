@@ -0,0 +1,350 @@
+package service
+
+import (
+	"context"
+	"lesson10/internal/config"
+	"lesson10/internal/dto"
+	"lesson10/internal/model"
+	"lesson10/internal/pkg/errcode"
+	"lesson10/internal/pkg/markdown"
+	"lesson10/internal/pkg/search"
+	"lesson10/internal/pkg/tracing"
+	"lesson10/internal/repository"
+	"log/slog"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	searchQueryMaxRunes  = 100
+	searchMaxWindow      = 1000 // page*size 的上限，翻得再深没有意义
+	searchRebuildBatch   = 500
+	searchDefaultPageLen = 20
+	searchVisibleRetries = 1 // 结果里有失效的文档时，清掉之后重查的次数
+)
+
+// SearchService 帖子/评论/用户写入后同步索引。同步失败只记日志：数据已经写进数据库，
+// 没删掉的文档在搜索时回表过滤，漏掉的在重建时补上
+type SearchService struct {
+	index      repository.SearchIndex
+	searchRepo repository.SearchRepository
+	userRepo   repository.UserRepository
+	cfg        config.SearchConfig
+}
+
+func NewSearchService(index repository.SearchIndex, searchRepo repository.SearchRepository, userRepo repository.UserRepository, cfg config.SearchConfig) *SearchService {
+	return &SearchService{
+		index:      index,
+		searchRepo: searchRepo,
+		userRepo:   userRepo,
+		cfg:        cfg,
+	}
+}
+
+func postSearchDoc(p *model.Post) search.Doc {
+	contentHTML := p.ContentHTML
+	if p.RenderVersion != markdown.Version || contentHTML == "" {
+		if rendered, _, err := renderPostContent(p.Content); err == nil {
+			contentHTML = rendered
+		}
+	}
+
+	return search.Doc{
+		Type:      search.TypePost,
+		ID:        p.ID,
+		Title:     p.Title,
+		Body:      markdown.PlainText(contentHTML),
+		AuthorID:  p.AuthorID,
+		CreatedAt: p.CreatedAt,
+	}
+}
+
+func commentSearchDoc(c *model.Comment) search.Doc {
+	return search.Doc{
+		Type:       search.TypeComment,
+		ID:         c.ID,
+		Body:       c.Content,
+		AuthorID:   c.AuthorID,
+		TargetType: uint8(c.TargetType),
+		TargetID:   c.TargetID,
+		CreatedAt:  c.CreatedAt,
+	}
+}
+
+func userSearchDoc(u *model.User) search.Doc {
+	return search.Doc{
+		Type:      search.TypeUser,
+		ID:        u.ID,
+		Title:     u.Username,
+		Body:      u.Profile,
+		AuthorID:  u.ID,
+		CreatedAt: u.CreatedAt,
+	}
+}
+
+// IndexPost 草稿和已删除的帖子从索引里拿掉
+func (s *SearchService) IndexPost(ctx context.Context, p *model.Post) {
+	if p.IsDeleted != 0 || p.Status != 0 {
+		s.remove(ctx, search.TypePost, p.ID)
+		return
+	}
+	if err := s.index.Index(ctx, postSearchDoc(p)); err != nil {
+		slog.ErrorContext(ctx, "index post failed", "post_id", p.ID, "error", err)
+	}
+}
+
+func (s *SearchService) RemovePost(ctx context.Context, postID uint) {
+	s.remove(ctx, search.TypePost, postID)
+}
+
+func (s *SearchService) IndexComment(ctx context.Context, c *model.Comment) {
+	if err := s.index.Index(ctx, commentSearchDoc(c)); err != nil {
+		slog.ErrorContext(ctx, "index comment failed", "comment_id", c.ID, "error", err)
+	}
+}
+
+func (s *SearchService) RemoveComment(ctx context.Context, commentID uint) {
+	s.remove(ctx, search.TypeComment, commentID)
+}
+
+func (s *SearchService) IndexUser(ctx context.Context, u *model.User) {
+	if err := s.index.Index(ctx, userSearchDoc(u)); err != nil {
+		slog.ErrorContext(ctx, "index user failed", "target_user_id", u.ID, "error", err)
+	}
+}
+
+func (s *SearchService) RemoveUser(ctx context.Context, userID uint) {
+	s.remove(ctx, search.TypeUser, userID)
+}
+
+func (s *SearchService) remove(ctx context.Context, docType string, id uint) {
+	if err := s.index.Delete(ctx, docType, id); err != nil {
+		slog.ErrorContext(ctx, "remove from search index failed", "doc_type", docType, "doc_id", id, "error", err)
+	}
+}
+
+func (s *SearchService) Search(ctx context.Context, req dto.SearchQuery) (*dto.SearchResp, error) {
+	ctx, span := tracing.Start(ctx, "SearchService.Search")
+	defer span.End()
+
+	text := strings.TrimSpace(req.Q)
+	if text == "" || utf8.RuneCountInString(text) > searchQueryMaxRunes {
+		return nil, errcode.ErrBadRequest
+	}
+
+	q := search.Query{Text: text, Sort: search.SortRelevance}
+	if req.Type != "" {
+		if !search.ValidType(req.Type) {
+			return nil, errcode.ErrBadRequest
+		}
+		q.Types = []string{req.Type}
+	}
+	switch req.Sort {
+	case "", search.SortRelevance:
+	case search.SortNewest:
+		q.Sort = search.SortNewest
+	default:
+		return nil, errcode.ErrBadRequest
+	}
+
+	if req.Page <= 0 {
+		req.Page = 1
+	}
+	if req.Size <= 0 || req.Size > 50 {
+		req.Size = searchDefaultPageLen
+	}
+	if req.Page*req.Size > searchMaxWindow {
+		return nil, errcode.ErrBadRequest
+	}
+	// 从第一条取到当前页末尾，回表过滤之后再分页，前面几页里失效的结果不会让这一页错位
+	offset := (req.Page - 1) * req.Size
+	q.Offset, q.Limit = 0, offset+req.Size
+
+	result, err := s.visibleResult(ctx, q)
+	if err != nil {
+		return nil, errcode.ErrInternal
+	}
+	hits := result.Hits[min(offset, len(result.Hits)):]
+	s.fillAuthors(ctx, hits)
+
+	return &dto.SearchResp{
+		Hits:   hits,
+		Total:  result.Total,
+		Facets: result.Facets,
+		Page:   req.Page,
+		Size:   req.Size,
+	}, nil
+}
+
+// visibleResult 查出来的结果里有失效的（已删除、转成草稿、挂在已删除的帖子下面）就从索引里清掉再查一次，
+// 内嵌索引第二次查时 Total 和 Facets 已经不含这些文档；再查还有的，直接从结果里去掉并扣掉计数
+func (s *SearchService) visibleResult(ctx context.Context, q search.Query) (*search.Result, error) {
+	for attempt := 0; ; attempt++ {
+		result, err := s.index.Search(ctx, q)
+		if err != nil {
+			slog.ErrorContext(ctx, "search failed", "query", q.Text, "error", err)
+			return nil, err
+		}
+
+		hits, err := s.visibleHits(ctx, result.Hits)
+		if err != nil {
+			return nil, err
+		}
+		if len(hits) == len(result.Hits) {
+			return result, nil
+		}
+		if attempt < searchVisibleRetries {
+			continue
+		}
+
+		for _, hit := range result.Hits {
+			result.Facets[hit.Type]--
+		}
+		for _, hit := range hits {
+			result.Facets[hit.Type]++
+		}
+		result.Total -= int64(len(result.Hits) - len(hits))
+		result.Hits = hits
+		return result, nil
+	}
+}
+
+// visibleHits 回表去掉已经删除、转成草稿的结果（比如挂在已删除帖子下面的评论），顺手从索引里清掉
+func (s *SearchService) visibleHits(ctx context.Context, hits []search.Hit) ([]search.Hit, error) {
+	ids := map[string][]uint{}
+	for _, hit := range hits {
+		ids[hit.Type] = append(ids[hit.Type], hit.ID)
+	}
+
+	visible := map[string]map[uint]bool{}
+	for docType, typeIDs := range ids {
+		found, err := s.searchRepo.VisibleIDs(ctx, docType, typeIDs)
+		if err != nil {
+			slog.ErrorContext(ctx, "check visible search hits failed", "doc_type", docType, "error", err)
+			return nil, err
+		}
+		visible[docType] = found
+	}
+
+	kept := make([]search.Hit, 0, len(hits))
+	for _, hit := range hits {
+		if !visible[hit.Type][hit.ID] {
+			s.remove(ctx, hit.Type, hit.ID)
+			continue
+		}
+		kept = append(kept, hit)
+	}
+
+	return kept, nil
+}
+
+func (s *SearchService) fillAuthors(ctx context.Context, hits []search.Hit) {
+	authorIDs := make([]uint, 0, len(hits))
+	for _, hit := range hits {
+		authorIDs = append(authorIDs, hit.AuthorID)
+	}
+
+	authors, err := s.userRepo.BatchGetUserBasicInfo(ctx, authorIDs)
+	if err != nil {
+		slog.ErrorContext(ctx, "batch get search hit authors failed", "error", err)
+		return
+	}
+	for i := range hits {
+		hits[i].AuthorName = authors[hits[i].AuthorID].Username
+	}
+}
+
+// Rebuild 把已发布的帖子、未删除的评论和所有用户重新写进索引
+func (s *SearchService) Rebuild(ctx context.Context) (int, error) {
+	indexed := 0
+
+	var afterID uint
+	for {
+		posts, err := s.searchRepo.ListPosts(ctx, afterID, searchRebuildBatch)
+		if err != nil {
+			return indexed, err
+		}
+		for i := range posts {
+			s.IndexPost(ctx, &posts[i])
+			afterID = posts[i].ID
+		}
+		indexed += len(posts)
+		if len(posts) < searchRebuildBatch {
+			break
+		}
+	}
+
+	afterID = 0
+	for {
+		comments, err := s.searchRepo.ListComments(ctx, afterID, searchRebuildBatch)
+		if err != nil {
+			return indexed, err
+		}
+		for i := range comments {
+			s.IndexComment(ctx, &comments[i])
+			afterID = comments[i].ID
+		}
+		indexed += len(comments)
+		if len(comments) < searchRebuildBatch {
+			break
+		}
+	}
+
+	afterID = 0
+	for {
+		users, err := s.searchRepo.ListUsers(ctx, afterID, searchRebuildBatch)
+		if err != nil {
+			return indexed, err
+		}
+		for i := range users {
+			s.IndexUser(ctx, &users[i])
+			afterID = users[i].ID
+		}
+		indexed += len(users)
+		if len(users) < searchRebuildBatch {
+			break
+		}
+	}
+
+	return indexed, nil
+}
+
+// RunWorker 只有内嵌索引需要：为空（第一次启动、快照格式升级）或者配置了 rebuild_on_start 时从数据库重建，
+// 之后每隔 flush_interval 把改动落盘
+func (s *SearchService) RunWorker(ctx context.Context) {
+	local, ok := s.index.(repository.LocalSearchIndex)
+	if !ok {
+		return
+	}
+
+	if local.Len() == 0 || s.cfg.RebuildOnStart {
+		started := time.Now()
+		indexed, err := s.Rebuild(ctx)
+		if err != nil {
+			slog.ErrorContext(ctx, "rebuild search index failed", "error", err)
+		} else {
+			slog.InfoContext(ctx, "rebuilt search index", "documents", indexed, "duration_ms", time.Since(started).Milliseconds())
+		}
+	}
+
+	flush := func() {
+		if err := local.Flush(); err != nil {
+			slog.ErrorContext(ctx, "flush search index failed", "error", err)
+		}
+	}
+	flush()
+
+	ticker := time.NewTicker(s.cfg.FlushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			flush()
+			return
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
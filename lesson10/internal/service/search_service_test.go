@@ -0,0 +1,132 @@
+package service
+
+import (
+	"context"
+	"lesson10/internal/config"
+	"lesson10/internal/dto"
+	"lesson10/internal/model"
+	"lesson10/internal/pkg/search"
+	"lesson10/internal/repository"
+	"reflect"
+	"testing"
+
+	"gorm.io/gorm"
+)
+
+func newTestSearchService(t *testing.T, db *gorm.DB) (*SearchService, *search.Engine) {
+	t.Helper()
+
+	engine, err := search.Open("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewSearchService(engine, repository.NewSearchRepo(db), repository.NewUserRepo(db), config.SearchConfig{}), engine
+}
+
+func createSearchUser(t *testing.T, db *gorm.DB) *model.User {
+	t.Helper()
+
+	user := &model.User{Username: "searcher", PasswordHash: "x"}
+	if err := db.Create(user).Error; err != nil {
+		t.Fatal(err)
+	}
+	return user
+}
+
+func searchIDs(resp *dto.SearchResp) []uint {
+	ids := make([]uint, 0, len(resp.Hits))
+	for _, hit := range resp.Hits {
+		ids = append(ids, hit.ID)
+	}
+	return ids
+}
+
+// TestSearchFiltersBeforePaging 索引里还留着已经删掉的帖子时，分页和计数都按过滤后的结果算
+func TestSearchFiltersBeforePaging(t *testing.T) {
+	db := newTestDB(t)
+	svc, engine := newTestSearchService(t, db)
+	ctx := context.Background()
+	user := createSearchUser(t, db)
+
+	posts := make([]model.Post, 5)
+	for i := range posts {
+		posts[i] = model.Post{AuthorID: user.ID, Title: "golang", Content: "golang tips"}
+		if err := db.Create(&posts[i]).Error; err != nil {
+			t.Fatal(err)
+		}
+		svc.IndexPost(ctx, &posts[i])
+	}
+
+	// 直接改库，索引里还是 5 篇：最早的一篇被删，最新的一篇转成草稿
+	if err := db.Model(&posts[0]).Update("is_deleted", 1).Error; err != nil {
+		t.Fatal(err)
+	}
+	if err := db.Model(&posts[4]).Update("status", 1).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	resp, err := svc.Search(ctx, dto.SearchQuery{Q: "golang", Sort: search.SortNewest, Page: 2, Size: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []uint{posts[1].ID}; !reflect.DeepEqual(searchIDs(resp), want) {
+		t.Fatalf("page 2 = %v, want %v", searchIDs(resp), want)
+	}
+	if resp.Total != 3 || resp.Facets[search.TypePost] != 3 {
+		t.Fatalf("total = %d, facets = %v", resp.Total, resp.Facets)
+	}
+	if resp.Hits[0].AuthorName != user.Username {
+		t.Fatalf("author name = %q", resp.Hits[0].AuthorName)
+	}
+	if engine.Len() != 3 {
+		t.Fatalf("index still has %d docs", engine.Len())
+	}
+
+	resp, err = svc.Search(ctx, dto.SearchQuery{Q: "golang", Sort: search.SortNewest, Page: 1, Size: 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []uint{posts[3].ID, posts[2].ID}; !reflect.DeepEqual(searchIDs(resp), want) {
+		t.Fatalf("page 1 = %v, want %v", searchIDs(resp), want)
+	}
+}
+
+func TestDeleteCommentRemovesRepliesFromIndex(t *testing.T) {
+	db := newTestDB(t)
+	svc, engine := newTestSearchService(t, db)
+	ctx := context.Background()
+	user := createSearchUser(t, db)
+
+	post := model.Post{AuthorID: user.ID, Title: "t", Content: "c"}
+	if err := db.Create(&post).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	commentSvc := NewCommentService(nil, nil, repository.NewCommentRepo(db), nil, nil)
+	commentSvc.SetSearchService(svc)
+
+	// 一级评论 -> 回复 -> 回复的回复
+	comments := make([]model.Comment, 3)
+	for i := range comments {
+		comments[i] = model.Comment{TargetType: model.CommentOnPost, TargetID: post.ID, AuthorID: user.ID, Content: "golang", Depth: uint8(i + 1)}
+		if i > 0 {
+			comments[i].TargetType, comments[i].TargetID = model.CommentOnComment, comments[i-1].ID
+		}
+		if err := db.Create(&comments[i]).Error; err != nil {
+			t.Fatal(err)
+		}
+		svc.IndexComment(ctx, &comments[i])
+	}
+
+	// 删掉中间那条，它和下面的回复一起从索引里消失，一级评论还在
+	if err := commentSvc.DeleteComment(ctx, comments[1].ID, user.ID, 0); err != nil {
+		t.Fatal(err)
+	}
+	result, err := engine.Search(ctx, search.Query{Text: "golang", Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Hits) != 1 || result.Hits[0].ID != comments[0].ID {
+		t.Fatalf("hits = %+v", result.Hits)
+	}
+}
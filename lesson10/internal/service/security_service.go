@@ -0,0 +1,282 @@
+package service
+
+import (
+	"context"
+	"lesson10/internal/config"
+	"lesson10/internal/dto"
+	"lesson10/internal/model"
+	"lesson10/internal/pkg/errcode"
+	"lesson10/internal/pkg/geo"
+	"lesson10/internal/repository"
+	"log/slog"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	securityRuleNotify    = "notify"
+	securityRuleRevokeAll = "revoke_all"
+
+	securityRuleTriggeredEvent = "security_rule_triggered"
+
+	securityWorkerInterval = 30 * time.Second
+	securityWorkerBatch    = 200
+	// securityWorkerLag created_at 在事务提交前就定了，晚提交的事件 ID 可能比已处理的还小；
+	// 只处理创建超过这么久的事件，按 (created_at, id) 推进游标，就不会跳过这些事件
+	securityWorkerLag = time.Minute
+)
+
+// securityRule 同一用户在 Window 内出现 Threshold 次 EventType 时触发 Action，Window 内只触发一次
+type securityRule struct {
+	Name      string
+	EventType string
+	Threshold int64
+	Window    time.Duration
+	Action    string
+	Message   string
+}
+
+func defaultSecurityRules() []securityRule {
+	return []securityRule{
+		{
+			Name: "ip_changed_burst", EventType: "ip_changed", Threshold: 3, Window: time.Hour, Action: securityRuleNotify,
+			Message: "你的登录会话在一小时内多次从新的 IP 地址刷新。如果不是你本人操作，请修改密码并下线不认识的设备。",
+		},
+		{
+			Name: "browser_changed_burst", EventType: "browser_version_changed", Threshold: 3, Window: time.Hour, Action: securityRuleNotify,
+			Message: "你的登录会话在一小时内多次出现浏览器版本变化。如果不是你本人操作，请检查登录设备。",
+		},
+		{
+			Name: "login_failed_burst", EventType: "login_failed", Threshold: 5, Window: time.Hour, Action: securityRuleNotify,
+			Message: "你的账号在一小时内出现多次密码错误的登录尝试。如果不是你本人操作，建议修改密码并开启两步验证。",
+		},
+		{
+			Name: "device_mismatch_repeat", EventType: "device_mismatch", Threshold: 2, Window: 24 * time.Hour, Action: securityRuleRevokeAll,
+			Message: "检测到你的登录凭证多次在其他设备上被使用，已下线全部设备，请重新登录并修改密码。",
+		},
+		{
+			Name: "browser_mismatch_repeat", EventType: "browser_mismatch", Threshold: 2, Window: 24 * time.Hour, Action: securityRuleRevokeAll,
+			Message: "检测到你的登录凭证多次在其他浏览器中被使用，已下线全部设备，请重新登录并修改密码。",
+		},
+		{
+			Name: "refresh_token_reuse", EventType: "refresh_token_reuse", Threshold: 1, Window: 24 * time.Hour, Action: securityRuleNotify,
+			Message: "检测到已失效的登录凭证被再次使用，为保护账号安全已下线全部设备，请重新登录并修改密码。",
+		},
+	}
+}
+
+// loadSecurityRules 按 security.rules 覆盖默认阈值或关闭规则，格式启动时 config.Validate 已经检查过
+func loadSecurityRules(cfg config.SecurityConfig) []securityRule {
+	overrides, _ := cfg.RuleOverrides()
+	rules := defaultSecurityRules()
+	result := make([]securityRule, 0, len(rules))
+	for _, rule := range rules {
+		override, ok := overrides[rule.Name]
+		delete(overrides, rule.Name)
+		switch {
+		case !ok:
+		case override.Off:
+			continue
+		default:
+			rule.Threshold = int64(override.Threshold)
+			rule.Window = override.Window
+		}
+
+		result = append(result, rule)
+	}
+	for name := range overrides {
+		slog.Warn("ignore unknown security rule", "rule", name)
+	}
+
+	return result
+}
+
+type SecurityService struct {
+	eventRepo        repository.SecurityEventRepository
+	notificationRepo repository.NotificationRepository
+	authSvc          *AuthService
+	geoLocator       geo.Locator
+	rules            []securityRule
+}
+
+func NewSecurityService(
+	eventRepo repository.SecurityEventRepository,
+	notificationRepo repository.NotificationRepository,
+	authSvc *AuthService,
+	cfg config.SecurityConfig,
+) *SecurityService {
+	return &SecurityService{
+		eventRepo:        eventRepo,
+		notificationRepo: notificationRepo,
+		authSvc:          authSvc,
+		geoLocator:       geo.Noop{},
+		rules:            loadSecurityRules(cfg),
+	}
+}
+
+func (s *SecurityService) SetGeoLocator(locator geo.Locator) {
+	s.geoLocator = locator
+}
+
+// ListUserEvents 用户只能看自己的事件，忽略 user_id 参数
+func (s *SecurityService) ListUserEvents(ctx context.Context, userID uint, q dto.SecurityEventQuery) (*dto.SecurityEventList, error) {
+	q.UserID = int64(userID)
+	return s.ListEvents(ctx, q)
+}
+
+func (s *SecurityService) ListEvents(ctx context.Context, q dto.SecurityEventQuery) (*dto.SecurityEventList, error) {
+	if q.Page <= 0 {
+		q.Page = 1
+	}
+	if q.PageSize <= 0 {
+		q.PageSize = 20
+	}
+
+	filter := repository.SecurityEventFilter{
+		UserID:    q.UserID,
+		EventType: strings.TrimSpace(q.Type),
+	}
+	if raw := strings.TrimSpace(q.From); raw != "" {
+		from, err := time.ParseInLocation(statsDayLayout, raw, time.Local)
+		if err != nil {
+			return nil, errcode.ErrBadRequest
+		}
+		filter.From = from
+	}
+	if raw := strings.TrimSpace(q.To); raw != "" {
+		to, err := time.ParseInLocation(statsDayLayout, raw, time.Local)
+		if err != nil {
+			return nil, errcode.ErrBadRequest
+		}
+		filter.To = to.AddDate(0, 0, 1)
+	}
+
+	events, total, err := s.eventRepo.List(ctx, filter, (q.Page-1)*q.PageSize, q.PageSize)
+	if err != nil {
+		return nil, errcode.ErrInternal
+	}
+
+	locations := make(map[string]string)
+	items := make([]dto.SecurityEventItem, 0, len(events))
+	for _, event := range events {
+		location, ok := locations[event.IP]
+		if !ok {
+			location = geo.Describe(s.geoLocator, event.IP)
+			locations[event.IP] = location
+		}
+
+		items = append(items, dto.SecurityEventItem{
+			ID:        event.ID,
+			UserID:    event.UserID,
+			SessionID: event.SessionID,
+			EventType: event.EventType,
+			IP:        event.IP,
+			Location:  location,
+			DeviceID:  event.DeviceID,
+			UserAgent: event.UserAgent,
+			Detail:    event.Detail,
+			CreatedAt: event.CreatedAt.Unix(),
+		})
+	}
+
+	return &dto.SecurityEventList{Events: items, Total: total, Page: q.Page, Size: q.PageSize}, nil
+}
+
+// RunWorker 轮询新写入的安全事件并执行规则；只处理启动之后的事件，不回放历史
+func (s *SecurityService) RunWorker(ctx context.Context) {
+	if len(s.rules) == 0 {
+		return
+	}
+
+	eventTypes := make([]string, 0, len(s.rules))
+	for _, rule := range s.rules {
+		eventTypes = append(eventTypes, rule.EventType)
+	}
+
+	cursor := repository.SecurityEventCursor{CreatedAt: time.Now()}
+	ticker := time.NewTicker(securityWorkerInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		cursor = s.processEvents(ctx, cursor, eventTypes, time.Now())
+	}
+}
+
+func (s *SecurityService) processEvents(ctx context.Context, cursor repository.SecurityEventCursor, eventTypes []string, now time.Time) repository.SecurityEventCursor {
+	before := now.Add(-securityWorkerLag)
+	for {
+		events, err := s.eventRepo.ListAfter(ctx, cursor, before, eventTypes, securityWorkerBatch)
+		if err != nil {
+			slog.ErrorContext(ctx, "security worker: list events failed", "error", err)
+			return cursor
+		}
+
+		// 同一批里同一用户同一规则只评估一次
+		evaluated := make(map[string]bool)
+		for i := range events {
+			event := &events[i]
+			cursor = repository.SecurityEventCursor{CreatedAt: event.CreatedAt, ID: event.ID}
+
+			for _, rule := range s.rules {
+				key := rule.Name + ":" + strconv.FormatInt(event.UserID, 10)
+				if rule.EventType != event.EventType || evaluated[key] {
+					continue
+				}
+				evaluated[key] = true
+
+				if err := s.evaluate(ctx, rule, event); err != nil {
+					slog.ErrorContext(ctx, "security worker: rule failed", "rule", rule.Name, "target_user_id", event.UserID, "error", err)
+				}
+			}
+		}
+
+		if len(events) < securityWorkerBatch {
+			return cursor
+		}
+	}
+}
+
+func (s *SecurityService) evaluate(ctx context.Context, rule securityRule, event *model.SecurityEvent) error {
+	if event.UserID <= 0 {
+		return nil
+	}
+
+	since := time.Now().Add(-rule.Window)
+	count, err := s.eventRepo.Count(ctx, repository.SecurityEventFilter{UserID: event.UserID, EventType: rule.EventType, From: since})
+	if err != nil || count < rule.Threshold {
+		return err
+	}
+
+	triggered, err := s.eventRepo.Count(ctx, repository.SecurityEventFilter{
+		UserID:    event.UserID,
+		EventType: securityRuleTriggeredEvent,
+		Detail:    rule.Name,
+		From:      since,
+	})
+	if err != nil || triggered > 0 {
+		return err
+	}
+
+	if rule.Action == securityRuleRevokeAll {
+		if err := s.authSvc.RevokeAllUserSessions(ctx, uint(event.UserID), "security_rule:"+rule.Name); err != nil {
+			return err
+		}
+	}
+
+	if err := s.authSvc.recordEventWithRepo(ctx, s.eventRepo, event.UserID, event.SessionID, securityRuleTriggeredEvent, event.IP, event.DeviceID, event.UserAgent, rule.Name); err != nil {
+		return err
+	}
+
+	return s.notificationRepo.CreateNotification(ctx, &model.Notification{
+		UserID:  uint(event.UserID),
+		Type:    3, // 系统通知
+		Content: rule.Message,
+	})
+}
@@ -0,0 +1,195 @@
+package service
+
+import (
+	"context"
+	"lesson10/internal/config"
+	"lesson10/internal/model"
+	"lesson10/internal/repository"
+	"testing"
+	"time"
+)
+
+func newTestSecurityService(t *testing.T, rules map[string]string) (*SecurityService, *AuthService) {
+	t.Helper()
+
+	db := newTestDB(t)
+	authSvc := newTestAuthService(t, db)
+	securitySvc := NewSecurityService(repository.NewSecurityEventRepo(db), repository.NewNotificationRepo(db), authSvc, config.SecurityConfig{Rules: rules})
+	return securitySvc, authSvc
+}
+
+func findSecurityRule(t *testing.T, rules []securityRule, name string) securityRule {
+	t.Helper()
+
+	for _, rule := range rules {
+		if rule.Name == name {
+			return rule
+		}
+	}
+	t.Fatalf("rule %s not found", name)
+	return securityRule{}
+}
+
+// addSecurityEvent 直接写库，CreatedAt 非零时 GORM 不会覆盖
+func addSecurityEvent(t *testing.T, authSvc *AuthService, event model.SecurityEvent) *model.SecurityEvent {
+	t.Helper()
+
+	if err := authSvc.db.Create(&event).Error; err != nil {
+		t.Fatal(err)
+	}
+	return &event
+}
+
+func countNotifications(t *testing.T, authSvc *AuthService, userID uint) int64 {
+	t.Helper()
+
+	var n int64
+	if err := authSvc.db.Model(&model.Notification{}).Where("user_id = ?", userID).Count(&n).Error; err != nil {
+		t.Fatal(err)
+	}
+	return n
+}
+
+func TestLoadSecurityRules(t *testing.T) {
+	defaults := defaultSecurityRules()
+
+	tests := []struct {
+		name      string
+		rules     map[string]string
+		count     int
+		rule      string
+		threshold int64
+		window    time.Duration
+	}{
+		{name: "defaults", count: len(defaults), rule: "login_failed_burst", threshold: 5, window: time.Hour},
+		{name: "override", rules: map[string]string{"login_failed_burst": "8/30m"}, count: len(defaults), rule: "login_failed_burst", threshold: 8, window: 30 * time.Minute},
+		{name: "name case and spaces", rules: map[string]string{" Login_Failed_Burst ": " 2 / 10m "}, count: len(defaults), rule: "login_failed_burst", threshold: 2, window: 10 * time.Minute},
+		{name: "off", rules: map[string]string{"ip_changed_burst": "OFF"}, count: len(defaults) - 1, rule: "login_failed_burst", threshold: 5, window: time.Hour},
+		{name: "unknown rule ignored", rules: map[string]string{"no_such_rule": "1/1h"}, count: len(defaults), rule: "ip_changed_burst", threshold: 3, window: time.Hour},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rules := loadSecurityRules(config.SecurityConfig{Rules: tt.rules})
+			if len(rules) != tt.count {
+				t.Fatalf("len(rules) = %d, want %d", len(rules), tt.count)
+			}
+			rule := findSecurityRule(t, rules, tt.rule)
+			if rule.Threshold != tt.threshold || rule.Window != tt.window {
+				t.Fatalf("%s = %d/%s, want %d/%s", tt.rule, rule.Threshold, rule.Window, tt.threshold, tt.window)
+			}
+		})
+	}
+
+	for _, rule := range loadSecurityRules(config.SecurityConfig{Rules: map[string]string{"ip_changed_burst": "off"}}) {
+		if rule.Name == "ip_changed_burst" {
+			t.Fatal("ip_changed_burst not turned off")
+		}
+	}
+}
+
+// TestSecurityRuleThreshold 窗口外的事件不计数，达到阈值后窗口内只通知一次
+func TestSecurityRuleThreshold(t *testing.T) {
+	securitySvc, authSvc := newTestSecurityService(t, map[string]string{"login_failed_burst": "3/1h"})
+	user := newTestUser(t, authSvc, "ivy")
+	rule := findSecurityRule(t, securitySvc.rules, "login_failed_burst")
+	ctx := context.Background()
+	now := time.Now()
+
+	addSecurityEvent(t, authSvc, model.SecurityEvent{UserID: int64(user.ID), EventType: "login_failed", CreatedAt: now.Add(-2 * time.Hour)})
+	var last *model.SecurityEvent
+	for i := 0; i < 3; i++ {
+		if last != nil {
+			if err := securitySvc.evaluate(ctx, rule, last); err != nil {
+				t.Fatal(err)
+			}
+			if n := countNotifications(t, authSvc, user.ID); n != 0 {
+				t.Fatalf("notified after %d events in window", i)
+			}
+		}
+		last = addSecurityEvent(t, authSvc, model.SecurityEvent{UserID: int64(user.ID), EventType: "login_failed", CreatedAt: now.Add(-time.Duration(i) * time.Minute)})
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := securitySvc.evaluate(ctx, rule, last); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := countNotifications(t, authSvc, user.ID); n != 1 {
+		t.Fatalf("notifications = %d, want 1", n)
+	}
+	if n := countEvents(t, authSvc, user.ID, securityRuleTriggeredEvent); n != 1 {
+		t.Fatalf("%s events = %d, want 1", securityRuleTriggeredEvent, n)
+	}
+}
+
+func TestSecurityRuleRevokeAll(t *testing.T) {
+	securitySvc, authSvc := newTestSecurityService(t, nil)
+	user := newTestUser(t, authSvc, "jack")
+	other := newTestUser(t, authSvc, "kate")
+	rule := findSecurityRule(t, securitySvc.rules, "device_mismatch_repeat")
+	ctx := context.Background()
+
+	sessionID, err := login(t, authSvc, user, "d1", desktopUA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherSessionID, err := login(t, authSvc, other, "d2", desktopUA)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var last *model.SecurityEvent
+	for i := 0; i < 2; i++ {
+		last = addSecurityEvent(t, authSvc, model.SecurityEvent{UserID: int64(user.ID), SessionID: sessionID, EventType: "device_mismatch"})
+	}
+	if err := securitySvc.evaluate(ctx, rule, last); err != nil {
+		t.Fatal(err)
+	}
+
+	if status, reason := sessionState(t, authSvc, sessionID); status != "revoked" || reason != "security_rule:device_mismatch_repeat" {
+		t.Fatalf("session = %s/%s", status, reason)
+	}
+	if status, _ := sessionState(t, authSvc, otherSessionID); status != "active" {
+		t.Fatalf("other user's session = %s", status)
+	}
+	if n := countNotifications(t, authSvc, user.ID); n != 1 {
+		t.Fatalf("notifications = %d, want 1", n)
+	}
+}
+
+// TestProcessEventsLateCommit ID 小的事件比 ID 大的晚提交时也不能被跳过
+func TestProcessEventsLateCommit(t *testing.T) {
+	securitySvc, authSvc := newTestSecurityService(t, nil)
+	early := newTestUser(t, authSvc, "leo")
+	late := newTestUser(t, authSvc, "mia")
+	ctx := context.Background()
+	now := time.Now()
+	eventTypes := []string{"refresh_token_reuse"}
+
+	cursor := repository.SecurityEventCursor{CreatedAt: now.Add(-time.Hour)}
+	addSecurityEvent(t, authSvc, model.SecurityEvent{ID: 20, UserID: int64(early.ID), EventType: "refresh_token_reuse", CreatedAt: now.Add(-5 * time.Second)})
+
+	// 还没过延迟时间，先不处理
+	if next := securitySvc.processEvents(ctx, cursor, eventTypes, now); next != cursor {
+		t.Fatalf("cursor moved to %+v before lag", next)
+	}
+
+	// ID 更小、创建更早的事务这时才提交
+	addSecurityEvent(t, authSvc, model.SecurityEvent{ID: 10, UserID: int64(late.ID), EventType: "refresh_token_reuse", CreatedAt: now.Add(-10 * time.Second)})
+
+	cursor = securitySvc.processEvents(ctx, cursor, eventTypes, now.Add(securityWorkerLag))
+	if cursor.ID != 20 {
+		t.Fatalf("cursor = %+v, want id 20", cursor)
+	}
+	for _, user := range []*model.User{early, late} {
+		if n := countEvents(t, authSvc, user.ID, securityRuleTriggeredEvent); n != 1 {
+			t.Fatalf("user %s %s events = %d, want 1", user.Username, securityRuleTriggeredEvent, n)
+		}
+	}
+
+	// 游标之后没有新事件，重复处理不会再次触发
+	if next := securitySvc.processEvents(ctx, cursor, eventTypes, now.Add(2*securityWorkerLag)); next != cursor {
+		t.Fatalf("cursor = %+v, want %+v", next, cursor)
+	}
+}
@@ -0,0 +1,139 @@
+package service
+
+import (
+	"context"
+	"lesson10/internal/config"
+	"lesson10/internal/model"
+	"lesson10/internal/pkg/errcode"
+	"lesson10/internal/repository"
+	"time"
+)
+
+const (
+	sessionLimitEvict  = "evict"
+	sessionLimitReject = "reject"
+
+	sessionRevokeReplaced = "replaced"
+	sessionRevokeLimit    = "session_limit"
+)
+
+// sessionLimitPolicy MaxActive 限制一个用户同时在线的会话数，PerDeviceType 再按设备类型（mobile/tablet/desktop）分别限制；
+// 超出时 Mode=evict 下线最早登录的会话，Mode=reject 拒绝这次登录
+type sessionLimitPolicy struct {
+	MaxActive     int
+	PerDeviceType map[string]int
+	Mode          string
+}
+
+// newSessionLimitPolicy 启动时 config.Validate 已经检查过配置，这里不再报错
+func newSessionLimitPolicy(cfg config.SessionConfig) sessionLimitPolicy {
+	perDeviceType, _ := cfg.DeviceTypeLimits()
+	policy := sessionLimitPolicy{
+		MaxActive:     cfg.MaxActive,
+		PerDeviceType: perDeviceType,
+		Mode:          sessionLimitEvict,
+	}
+	if cfg.LimitMode == sessionLimitReject {
+		policy.Mode = sessionLimitReject
+	}
+
+	return policy
+}
+
+// markOldest sessions 按登录时间从早到晚排列，标记最早的若干个，使得加上新会话后不超过 limit
+func markOldest(sessions []*model.Session, limit int, marked map[string]string, reason string) {
+	kept := 0
+	for _, session := range sessions {
+		if _, ok := marked[session.SessionID]; !ok {
+			kept++
+		}
+	}
+
+	over := kept - (limit - 1)
+	for _, session := range sessions {
+		if over <= 0 {
+			return
+		}
+		if _, ok := marked[session.SessionID]; ok {
+			continue
+		}
+		marked[session.SessionID] = reason
+		over--
+	}
+}
+
+// enforceSessionLimitWithRepo 新会话写入前调用：已经空闲超时或超过绝对寿命的会话先下线，同一 DeviceID 的旧会话直接被替换，都不占名额；
+// 然后先按设备类型、再按总数检查上限
+func (s *AuthService) enforceSessionLimitWithRepo(
+	ctx context.Context,
+	sessionRepo repository.SessionRepository,
+	refreshRepo repository.RefreshTokenRepository,
+	eventRepo repository.SecurityEventRepository,
+	newSession *model.Session,
+	clientDeviceID string,
+	ip string,
+	userAgent string,
+	now time.Time,
+) error {
+	active, err := sessionRepo.ListActiveByUserIDForUpdate(ctx, newSession.UserID)
+	if err != nil {
+		return err
+	}
+
+	remaining := make([]*model.Session, 0, len(active))
+	sameType := make([]*model.Session, 0, len(active))
+	for i := range active {
+		session := &active[i]
+		// 过期的是别的设备上的旧会话，不记这次登录的 IP 和 UA
+		expired, err := s.expireSessionWithRepo(ctx, sessionRepo, refreshRepo, eventRepo, session, "", "", now)
+		if err != nil {
+			return err
+		}
+		if expired {
+			continue
+		}
+
+		if clientDeviceID != "" && session.DeviceID == clientDeviceID {
+			if err := s.revokeSessionWithRepo(ctx, sessionRepo, refreshRepo, session, sessionRevokeReplaced, now); err != nil {
+				return err
+			}
+			if err := s.recordEventWithRepo(ctx, eventRepo, session.UserID, session.SessionID, "session_replaced", ip, session.DeviceID, userAgent, "replaced by "+newSession.SessionID); err != nil {
+				return err
+			}
+			continue
+		}
+
+		remaining = append(remaining, session)
+		if session.DeviceType == newSession.DeviceType {
+			sameType = append(sameType, session)
+		}
+	}
+
+	policy := s.sessionLimit
+	evict := make(map[string]string)
+	if limit, ok := policy.PerDeviceType[newSession.DeviceType]; ok {
+		markOldest(sameType, limit, evict, "device_type:"+newSession.DeviceType)
+	}
+	markOldest(remaining, policy.MaxActive, evict, "max_active")
+	if len(evict) == 0 {
+		return nil
+	}
+	if policy.Mode == sessionLimitReject {
+		return errcode.ErrSessionLimit
+	}
+
+	for _, session := range remaining {
+		detail, ok := evict[session.SessionID]
+		if !ok {
+			continue
+		}
+		if err := s.revokeSessionWithRepo(ctx, sessionRepo, refreshRepo, session, sessionRevokeLimit, now); err != nil {
+			return err
+		}
+		if err := s.recordEventWithRepo(ctx, eventRepo, session.UserID, session.SessionID, "session_evicted", ip, session.DeviceID, userAgent, detail); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
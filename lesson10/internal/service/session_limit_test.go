@@ -0,0 +1,152 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"lesson10/internal/model"
+	"lesson10/internal/pkg/errcode"
+	"testing"
+	"time"
+)
+
+const (
+	desktopUA = "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/126.0 Safari/537.36"
+	mobileUA  = "Mozilla/5.0 (iPhone; CPU iPhone OS 17_5 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.5 Mobile/15E148 Safari/604.1"
+)
+
+func newLimitedAuthService(t *testing.T, maxActive int, perDeviceType []string, mode string) *AuthService {
+	t.Helper()
+
+	cfg := testSessionConfig()
+	cfg.MaxActive = maxActive
+	cfg.MaxActivePerDeviceType = perDeviceType
+	cfg.LimitMode = mode
+
+	authSvc := newTestAuthService(t, newTestDB(t))
+	authSvc.sessions = newSessionPolicies(cfg)
+	authSvc.sessionLimit = newSessionLimitPolicy(cfg)
+	return authSvc
+}
+
+// login 每次用不同的 device_id 登录，登录时间依次往后错开，保证“最早”是确定的
+func login(t *testing.T, authSvc *AuthService, user *model.User, deviceID string, userAgent string) (string, error) {
+	t.Helper()
+
+	result, err := authSvc.issueSession(context.Background(), user, deviceID, deviceID, false, "10.0.0.1", userAgent)
+	if err != nil {
+		return "", err
+	}
+	time.Sleep(10 * time.Millisecond)
+	return result.Pair.SessionId, nil
+}
+
+func sessionState(t *testing.T, authSvc *AuthService, sessionID string) (string, string) {
+	t.Helper()
+
+	var session model.Session
+	if err := authSvc.db.Where("session_id = ?", sessionID).First(&session).Error; err != nil {
+		t.Fatal(err)
+	}
+	return session.Status, session.RevokeReason
+}
+
+func TestSessionLimitEvictsOldest(t *testing.T) {
+	authSvc := newLimitedAuthService(t, 2, nil, "evict")
+	user := newTestUser(t, authSvc, "henry")
+
+	first, err := login(t, authSvc, user, "d1", desktopUA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := login(t, authSvc, user, "d2", desktopUA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	third, err := login(t, authSvc, user, "d3", desktopUA)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if status, reason := sessionState(t, authSvc, first); status != sessionStatusRevoked || reason != sessionRevokeLimit {
+		t.Fatalf("oldest session: %s/%s, want revoked/%s", status, reason, sessionRevokeLimit)
+	}
+	for _, id := range []string{second, third} {
+		if status, _ := sessionState(t, authSvc, id); status != sessionStatusActive {
+			t.Fatalf("session %s is %s, want active", id, status)
+		}
+	}
+	if n := countEvents(t, authSvc, user.ID, "session_evicted"); n != 1 {
+		t.Fatalf("session_evicted events = %d, want 1", n)
+	}
+}
+
+func TestSessionLimitRejectsNewLogin(t *testing.T) {
+	authSvc := newLimitedAuthService(t, 2, nil, "reject")
+	user := newTestUser(t, authSvc, "iris")
+
+	for _, device := range []string{"d1", "d2"} {
+		if _, err := login(t, authSvc, user, device, desktopUA); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := login(t, authSvc, user, "d3", desktopUA); !errors.Is(err, errcode.ErrSessionLimit) {
+		t.Fatalf("err = %v, want ErrSessionLimit", err)
+	}
+
+	// 同一设备重新登录是替换，不受上限影响
+	if _, err := login(t, authSvc, user, "d1", desktopUA); err != nil {
+		t.Fatalf("re-login on the same device: %v", err)
+	}
+
+	var active int64
+	authSvc.db.Model(&model.Session{}).Where("user_id = ? AND status = ?", user.ID, sessionStatusActive).Count(&active)
+	if active != 2 {
+		t.Fatalf("active sessions = %d, want 2", active)
+	}
+}
+
+func TestSessionLimitIgnoresExpiredSessions(t *testing.T) {
+	authSvc := newLimitedAuthService(t, 2, nil, "reject")
+	user := newTestUser(t, authSvc, "jack")
+
+	stale, err := login(t, authSvc, user, "d1", desktopUA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := login(t, authSvc, user, "d2", desktopUA); err != nil {
+		t.Fatal(err)
+	}
+	// d1 已经空闲超时，只是还没有请求来把它下线
+	ageSession(t, authSvc, stale, 2*time.Hour, 2*time.Hour)
+
+	if _, err := login(t, authSvc, user, "d3", desktopUA); err != nil {
+		t.Fatalf("expired session still counted: %v", err)
+	}
+	if status, reason := sessionState(t, authSvc, stale); status != sessionStatusRevoked || reason != sessionRevokeIdleTimeout {
+		t.Fatalf("stale session: %s/%s, want revoked/%s", status, reason, sessionRevokeIdleTimeout)
+	}
+}
+
+func TestSessionLimitPerDeviceType(t *testing.T) {
+	authSvc := newLimitedAuthService(t, 10, []string{"mobile=1"}, "evict")
+	user := newTestUser(t, authSvc, "kate")
+
+	phone, err := login(t, authSvc, user, "phone-1", mobileUA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	desktop, err := login(t, authSvc, user, "pc-1", desktopUA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := login(t, authSvc, user, "phone-2", mobileUA); err != nil {
+		t.Fatal(err)
+	}
+
+	if status, reason := sessionState(t, authSvc, phone); status != sessionStatusRevoked || reason != sessionRevokeLimit {
+		t.Fatalf("first phone session: %s/%s, want revoked/%s", status, reason, sessionRevokeLimit)
+	}
+	if status, _ := sessionState(t, authSvc, desktop); status != sessionStatusActive {
+		t.Fatalf("desktop session is %s, want active", status)
+	}
+}
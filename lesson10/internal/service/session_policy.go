@@ -0,0 +1,88 @@
+package service
+
+import (
+	"context"
+	"lesson10/internal/config"
+	"lesson10/internal/model"
+	"lesson10/internal/repository"
+	"time"
+)
+
+const (
+	sessionRevokeIdleTimeout = "idle_timeout"
+	sessionRevokeMaxLifetime = "max_lifetime"
+)
+
+// sessionPolicy 超过 IdleTimeout 没有刷新（LastSeenAt）或者登录超过 MaxLifetime（CreatedAt）的会话失效，
+// 刷新 refresh token 只能顺延空闲窗口，不能突破绝对寿命
+type sessionPolicy struct {
+	IdleTimeout time.Duration
+	MaxLifetime time.Duration
+}
+
+// sessionPolicies 启动时按配置建好，普通会话和勾选“记住我”的会话各一组
+type sessionPolicies struct {
+	normal   sessionPolicy
+	remember sessionPolicy
+}
+
+func newSessionPolicies(cfg config.SessionConfig) sessionPolicies {
+	return sessionPolicies{
+		normal:   sessionPolicy{IdleTimeout: cfg.IdleTimeout, MaxLifetime: cfg.MaxLifetime},
+		remember: sessionPolicy{IdleTimeout: cfg.RememberIdleTimeout, MaxLifetime: cfg.RememberMaxLifetime},
+	}
+}
+
+func (p sessionPolicies) of(rememberMe bool) sessionPolicy {
+	if rememberMe {
+		return p.remember
+	}
+
+	return p.normal
+}
+
+// expiresAt 空闲截止和绝对截止取较早的一个，refresh token 的过期时间也用它
+func (p sessionPolicy) expiresAt(session *model.Session) time.Time {
+	idle := session.LastSeenAt.Add(p.IdleTimeout)
+	lifetime := session.CreatedAt.Add(p.MaxLifetime)
+	if lifetime.Before(idle) {
+		return lifetime
+	}
+
+	return idle
+}
+
+// expiredReason 会话仍然有效时返回空串
+func (p sessionPolicy) expiredReason(session *model.Session, now time.Time) string {
+	if !now.Before(session.CreatedAt.Add(p.MaxLifetime)) {
+		return sessionRevokeMaxLifetime
+	}
+	if !now.Before(session.LastSeenAt.Add(p.IdleTimeout)) {
+		return sessionRevokeIdleTimeout
+	}
+
+	return ""
+}
+
+// expireSessionWithRepo 按策略检查在线会话，过期的吊销并记录 session_expired 事件，返回会话是否已过期
+func (s *AuthService) expireSessionWithRepo(
+	ctx context.Context,
+	sessionRepo repository.SessionRepository,
+	refreshRepo repository.RefreshTokenRepository,
+	eventRepo repository.SecurityEventRepository,
+	session *model.Session,
+	ip string,
+	userAgent string,
+	now time.Time,
+) (bool, error) {
+	reason := s.sessions.of(session.RememberMe).expiredReason(session, now)
+	if reason == "" {
+		return false, nil
+	}
+
+	if err := s.revokeSessionWithRepo(ctx, sessionRepo, refreshRepo, session, reason, now); err != nil {
+		return true, err
+	}
+
+	return true, s.recordEventWithRepo(ctx, eventRepo, session.UserID, session.SessionID, "session_expired", ip, session.DeviceID, userAgent, reason)
+}
@@ -0,0 +1,173 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"lesson10/internal/dto"
+	"lesson10/internal/model"
+	"lesson10/internal/pkg/errcode"
+	"testing"
+	"time"
+)
+
+// ageSession 把会话的登录时间和最后活跃时间往前挪，模拟过了一段时间
+func ageSession(t *testing.T, authSvc *AuthService, sessionID string, createdAgo time.Duration, lastSeenAgo time.Duration) {
+	t.Helper()
+
+	now := time.Now()
+	err := authSvc.db.Model(&model.Session{}).Where("session_id = ?", sessionID).Updates(map[string]any{
+		"created_at":   now.Add(-createdAgo),
+		"last_seen_at": now.Add(-lastSeenAgo),
+	}).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func assertExpired(t *testing.T, authSvc *AuthService, sessionID string, reason string) {
+	t.Helper()
+
+	var session model.Session
+	if err := authSvc.db.Where("session_id = ?", sessionID).First(&session).Error; err != nil {
+		t.Fatal(err)
+	}
+	if session.Status != sessionStatusRevoked || session.RevokeReason != reason {
+		t.Fatalf("session status=%s reason=%s, want revoked/%s", session.Status, session.RevokeReason, reason)
+	}
+
+	var events int64
+	authSvc.db.Model(&model.SecurityEvent{}).Where("session_id = ? AND event_type = ?", sessionID, "session_expired").Count(&events)
+	if events != 1 {
+		t.Fatalf("session_expired events = %d, want 1", events)
+	}
+}
+
+func TestSessionExpiry(t *testing.T) {
+	cases := []struct {
+		name        string
+		rememberMe  bool
+		createdAgo  time.Duration
+		lastSeenAgo time.Duration
+		reason      string
+	}{
+		{"active", false, 30 * time.Minute, 10 * time.Minute, ""},
+		{"idle", false, 2 * time.Hour, 2 * time.Hour, sessionRevokeIdleTimeout},
+		{"max lifetime", false, 25 * time.Hour, time.Minute, sessionRevokeMaxLifetime},
+		{"remember me survives normal idle timeout", true, 30 * time.Hour, 30 * time.Hour, ""},
+		{"remember me idle", true, 50 * time.Hour, 49 * time.Hour, sessionRevokeIdleTimeout},
+		{"remember me max lifetime", true, 73 * time.Hour, time.Minute, sessionRevokeMaxLifetime},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			for _, via := range []string{"validate", "refresh"} {
+				t.Run(via, func(t *testing.T) {
+					authSvc := newTestAuthService(t, newTestDB(t))
+					user := newTestUser(t, authSvc, "frank")
+					ctx := context.Background()
+
+					result, err := authSvc.issueSession(ctx, user, "device-1", "test", tc.rememberMe, "10.0.0.1", "test")
+					if err != nil {
+						t.Fatal(err)
+					}
+					ageSession(t, authSvc, result.Pair.SessionId, tc.createdAgo, tc.lastSeenAgo)
+
+					if via == "validate" {
+						_, err = authSvc.ValidateAccessToken(ctx, result.Pair.AccessToken)
+					} else {
+						_, err = authSvc.Refresh(ctx, dto.RefreshRequest{RefreshToken: result.Pair.RefreshToken, DeviceID: "device-1"}, "10.0.0.1", "test")
+					}
+
+					if tc.reason == "" {
+						if err != nil {
+							t.Fatalf("live session rejected: %v", err)
+						}
+						return
+					}
+					if !errors.Is(err, errcode.ErrSessionExpired) {
+						t.Fatalf("err = %v, want ErrSessionExpired", err)
+					}
+					assertExpired(t, authSvc, result.Pair.SessionId, tc.reason)
+
+					// 过期后再拿 refresh token 来刷新仍然是“会话过期”，不当作重放
+					if _, err := authSvc.Refresh(ctx, dto.RefreshRequest{RefreshToken: result.Pair.RefreshToken, DeviceID: "device-1"}, "10.0.0.1", "test"); !errors.Is(err, errcode.ErrSessionExpired) {
+						t.Fatalf("refresh after expiry: err = %v, want ErrSessionExpired", err)
+					}
+				})
+			}
+		})
+	}
+}
+
+func TestRefreshExtendsIdleWindowButNotLifetime(t *testing.T) {
+	authSvc := newTestAuthService(t, newTestDB(t))
+	user := newTestUser(t, authSvc, "grace")
+	ctx := context.Background()
+
+	result, err := authSvc.issueSession(ctx, user, "device-1", "test", false, "10.0.0.1", "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 登录 23.5 小时，离绝对寿命只剩半小时
+	ageSession(t, authSvc, result.Pair.SessionId, 23*time.Hour+30*time.Minute, 30*time.Minute)
+
+	pair, err := authSvc.Refresh(ctx, dto.RefreshRequest{RefreshToken: result.Pair.RefreshToken, DeviceID: "device-1"}, "10.0.0.1", "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 新 refresh token 的过期时间被绝对寿命截住，而不是再顺延一整个空闲窗口
+	remaining := time.Until(time.Unix(pair.RefreshExpiresAt, 0))
+	if remaining > 31*time.Minute || remaining < 29*time.Minute {
+		t.Fatalf("refresh token expires in %s, want about 30m", remaining)
+	}
+}
+
+func TestRenameSession(t *testing.T) {
+	authSvc := newTestAuthService(t, newTestDB(t))
+	owner := newTestUser(t, authSvc, "olga")
+	other := newTestUser(t, authSvc, "paul")
+	ctx := context.Background()
+
+	sessionID, err := login(t, authSvc, owner, "d1", desktopUA)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	label := func() string {
+		t.Helper()
+
+		var session model.Session
+		if err := authSvc.db.Where("session_id = ?", sessionID).First(&session).Error; err != nil {
+			t.Fatal(err)
+		}
+		return session.Label
+	}
+
+	tests := []struct {
+		name      string
+		userID    uint
+		sessionID string
+		label     string
+		wantErr   error
+		want      string
+	}{
+		{name: "trimmed", userID: owner.ID, sessionID: " " + sessionID + " ", label: "  办公室电脑 ", want: "办公室电脑"},
+		{name: "same label again", userID: owner.ID, sessionID: sessionID, label: "办公室电脑", want: "办公室电脑"},
+		{name: "other user's session", userID: other.ID, sessionID: sessionID, label: "hijacked", wantErr: errcode.ErrNotFound, want: "办公室电脑"},
+		{name: "unknown session", userID: owner.ID, sessionID: "no-such-session", label: "x", wantErr: errcode.ErrNotFound, want: "办公室电脑"},
+		{name: "clear", userID: owner.ID, sessionID: sessionID, label: "   ", want: ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := authSvc.RenameSession(ctx, tt.userID, tt.sessionID, tt.label)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if got := label(); got != tt.want {
+				t.Fatalf("label = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
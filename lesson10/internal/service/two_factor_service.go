@@ -0,0 +1,346 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"lesson10/internal/config"
+	"lesson10/internal/dto"
+	"lesson10/internal/model"
+	"lesson10/internal/pkg/errcode"
+	"lesson10/internal/pkg/totp"
+	"lesson10/internal/pkg/utils"
+	"lesson10/internal/repository"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	recoveryCodeCount = 10
+	// 允许前后各一个步长（±30 秒）的时钟误差
+	totpSkew = 1
+
+	twoFactorMethodTOTP     = "totp"
+	twoFactorMethodRecovery = "recovery_code"
+)
+
+type TwoFactorService struct {
+	twoFactorRepo repository.TwoFactorRepository
+	eventRepo     repository.SecurityEventRepository
+	authSvc       *AuthService
+	db            *gorm.DB
+	issuer        string
+}
+
+func NewTwoFactorService(
+	twoFactorRepo repository.TwoFactorRepository,
+	eventRepo repository.SecurityEventRepository,
+	authSvc *AuthService,
+	db *gorm.DB,
+	cfg config.TwoFactorConfig,
+) *TwoFactorService {
+	return &TwoFactorService{
+		twoFactorRepo: twoFactorRepo,
+		eventRepo:     eventRepo,
+		authSvc:       authSvc,
+		db:            db,
+		issuer:        cfg.Issuer,
+	}
+}
+
+func (s *TwoFactorService) IsEnabled(ctx context.Context, userID uint) (bool, error) {
+	record, err := s.twoFactorRepo.GetTOTPByUserID(ctx, int64(userID))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	return record.EnabledAt != nil, nil
+}
+
+func (s *TwoFactorService) Status(ctx context.Context, userID uint) (*dto.TwoFactorStatus, error) {
+	record, err := s.twoFactorRepo.GetTOTPByUserID(ctx, int64(userID))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return &dto.TwoFactorStatus{}, nil
+		}
+		return nil, errcode.ErrInternal
+	}
+	if record.EnabledAt == nil {
+		return &dto.TwoFactorStatus{}, nil
+	}
+
+	left, err := s.twoFactorRepo.CountUnusedRecoveryCodes(ctx, int64(userID))
+	if err != nil {
+		return nil, errcode.ErrInternal
+	}
+
+	return &dto.TwoFactorStatus{
+		Enabled:           true,
+		EnabledAt:         record.EnabledAt.Unix(),
+		RecoveryCodesLeft: left,
+	}, nil
+}
+
+// Setup 生成新密钥，此时还未生效，需要用验证码调用 Enable 确认
+func (s *TwoFactorService) Setup(ctx context.Context, userID uint, password string) (*dto.TwoFactorSetup, error) {
+	user, err := s.authSvc.findUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if !checkPassword(user, password) {
+		return nil, errcode.ErrPasswordIncorrect
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return nil, errcode.ErrInternal
+	}
+
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		repo := s.twoFactorRepo.WithTx(tx)
+
+		record, err := repo.GetTOTPByUserIDForUpdate(ctx, int64(userID))
+		if err != nil {
+			if !errors.Is(err, gorm.ErrRecordNotFound) {
+				return errcode.ErrInternal
+			}
+			record = &model.UserTOTP{UserID: int64(userID)}
+		}
+		if record.EnabledAt != nil {
+			return errcode.ErrConflict
+		}
+
+		record.Secret = secret
+		record.LastUsedStep = 0
+		if err := repo.SaveTOTP(ctx, record); err != nil {
+			return errcode.ErrInternal
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &dto.TwoFactorSetup{
+		Secret:     secret,
+		OtpauthURI: totp.URI(s.issuer, user.Username, secret),
+	}, nil
+}
+
+// Enable 用 App 上的验证码确认绑定，返回一次性恢复码（只展示这一次）
+func (s *TwoFactorService) Enable(ctx context.Context, userID uint, code string, ip string, userAgent string) ([]string, error) {
+	now := time.Now()
+	codes, records, err := newRecoveryCodes(int64(userID))
+	if err != nil {
+		return nil, errcode.ErrInternal
+	}
+
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		repo := s.twoFactorRepo.WithTx(tx)
+
+		record, err := repo.GetTOTPByUserIDForUpdate(ctx, int64(userID))
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return errcode.ErrBadRequest
+			}
+			return errcode.ErrInternal
+		}
+		if record.EnabledAt != nil {
+			return errcode.ErrConflict
+		}
+
+		step, ok := totp.Validate(record.Secret, code, now, totpSkew)
+		if !ok {
+			return errcode.ErrTwoFactorCodeIncorrect
+		}
+
+		record.EnabledAt = &now
+		record.LastUsedStep = step
+		if err := repo.SaveTOTP(ctx, record); err != nil {
+			return errcode.ErrInternal
+		}
+
+		if err := repo.ReplaceRecoveryCodes(ctx, int64(userID), records); err != nil {
+			return errcode.ErrInternal
+		}
+
+		return s.authSvc.recordEventWithRepo(ctx, s.eventRepo.WithTx(tx), int64(userID), "", "2fa_enabled", ip, "", userAgent, "")
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+// Disable 需要密码和当前验证码同时正确，恢复码不能用来关闭
+func (s *TwoFactorService) Disable(ctx context.Context, userID uint, password string, code string, ip string, userAgent string) error {
+	return s.reauthenticate(ctx, userID, password, code, ip, userAgent, func(tx *gorm.DB) error {
+		repo := s.twoFactorRepo.WithTx(tx)
+
+		if err := repo.DeleteTOTP(ctx, int64(userID)); err != nil {
+			return errcode.ErrInternal
+		}
+		if err := repo.DeleteRecoveryCodes(ctx, int64(userID)); err != nil {
+			return errcode.ErrInternal
+		}
+
+		return s.authSvc.recordEventWithRepo(ctx, s.eventRepo.WithTx(tx), int64(userID), "", "2fa_disabled", ip, "", userAgent, "")
+	})
+}
+
+// RegenerateRecoveryCodes 作废旧恢复码并生成新的一批
+func (s *TwoFactorService) RegenerateRecoveryCodes(ctx context.Context, userID uint, password string, code string, ip string, userAgent string) ([]string, error) {
+	codes, records, err := newRecoveryCodes(int64(userID))
+	if err != nil {
+		return nil, errcode.ErrInternal
+	}
+
+	err = s.reauthenticate(ctx, userID, password, code, ip, userAgent, func(tx *gorm.DB) error {
+		if err := s.twoFactorRepo.WithTx(tx).ReplaceRecoveryCodes(ctx, int64(userID), records); err != nil {
+			return errcode.ErrInternal
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+// reauthenticate 确认密码和当前验证码后在同一个事务里执行 action。
+// 失败和登录共用账号/IP 计数：拿到了密码的人在这里同样不能无限次猜验证码，锁定后也进不来
+func (s *TwoFactorService) reauthenticate(ctx context.Context, userID uint, password string, code string, ip string, userAgent string, action func(tx *gorm.DB) error) error {
+	user, err := s.authSvc.findUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	accountKey := loginAccountKey(user.Username)
+	if err := s.authSvc.guardLogin(ctx, accountKey, ip, now); err != nil {
+		return err
+	}
+
+	if !checkPassword(user, password) {
+		if err := s.authSvc.recordLoginFailure(ctx, accountKey, user, "login_failed", ip, userAgent, now); err != nil {
+			return errcode.ErrInternal
+		}
+		return errcode.ErrPasswordIncorrect
+	}
+
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if _, err := s.verifyWithRepo(ctx, s.twoFactorRepo.WithTx(tx), int64(userID), code, false, now); err != nil {
+			return err
+		}
+		return action(tx)
+	})
+	// 失败计数在事务外记，不能随事务回滚
+	if errors.Is(err, errcode.ErrTwoFactorCodeIncorrect) {
+		if err := s.authSvc.recordLoginFailure(ctx, accountKey, user, "2fa_failed", ip, userAgent, now); err != nil {
+			return errcode.ErrInternal
+		}
+		return errcode.ErrTwoFactorCodeIncorrect
+	}
+	if err != nil {
+		return err
+	}
+
+	s.authSvc.resetLoginFailures(ctx, accountKey)
+	return nil
+}
+
+// verifyWithRepo 校验 TOTP 或恢复码，需要在事务里调用；通过后记录时间步或把恢复码标记为已用
+func (s *TwoFactorService) verifyWithRepo(
+	ctx context.Context,
+	repo repository.TwoFactorRepository,
+	userID int64,
+	code string,
+	allowRecovery bool,
+	now time.Time,
+) (string, error) {
+	record, err := repo.GetTOTPByUserIDForUpdate(ctx, userID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return "", errcode.ErrBadRequest
+		}
+		return "", errcode.ErrInternal
+	}
+	if record.EnabledAt == nil {
+		return "", errcode.ErrBadRequest
+	}
+
+	code = strings.TrimSpace(code)
+	if len(code) == totp.Digits {
+		step, ok := totp.Validate(record.Secret, code, now, totpSkew)
+		// 同一个时间步内的码只能用一次
+		if !ok || step <= record.LastUsedStep {
+			return "", errcode.ErrTwoFactorCodeIncorrect
+		}
+
+		record.LastUsedStep = step
+		if err := repo.SaveTOTP(ctx, record); err != nil {
+			return "", errcode.ErrInternal
+		}
+
+		return twoFactorMethodTOTP, nil
+	}
+
+	if !allowRecovery {
+		return "", errcode.ErrTwoFactorCodeIncorrect
+	}
+
+	recovery, err := repo.GetRecoveryCodeForUpdate(ctx, userID, utils.HashToken(normalizeRecoveryCode(code)))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return "", errcode.ErrTwoFactorCodeIncorrect
+		}
+		return "", errcode.ErrInternal
+	}
+	if recovery.UsedAt != nil {
+		return "", errcode.ErrTwoFactorCodeIncorrect
+	}
+
+	if err := repo.MarkRecoveryCodeUsed(ctx, recovery.ID, now); err != nil {
+		return "", errcode.ErrInternal
+	}
+
+	return twoFactorMethodRecovery, nil
+}
+
+var recoveryEncoding = base32.NewEncoding("abcdefghijkmnpqrstuvwxyz23456789").WithPadding(base32.NoPadding)
+
+// newRecoveryCodes 生成 xxxxx-xxxxx 形式的恢复码，库里只存哈希
+func newRecoveryCodes(userID int64) ([]string, []model.TOTPRecoveryCode, error) {
+	codes := make([]string, 0, recoveryCodeCount)
+	records := make([]model.TOTPRecoveryCode, 0, recoveryCodeCount)
+
+	for i := 0; i < recoveryCodeCount; i++ {
+		buf := make([]byte, 7)
+		if _, err := rand.Read(buf); err != nil {
+			return nil, nil, err
+		}
+
+		raw := recoveryEncoding.EncodeToString(buf)[:10]
+		codes = append(codes, raw[:5]+"-"+raw[5:])
+		records = append(records, model.TOTPRecoveryCode{
+			UserID:   userID,
+			CodeHash: utils.HashToken(raw),
+		})
+	}
+
+	return codes, records, nil
+}
+
+func normalizeRecoveryCode(code string) string {
+	return strings.NewReplacer("-", "", " ", "").Replace(strings.ToLower(code))
+}
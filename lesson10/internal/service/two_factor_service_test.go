@@ -0,0 +1,219 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"lesson10/internal/config"
+	"lesson10/internal/dto"
+	"lesson10/internal/model"
+	"lesson10/internal/pkg/errcode"
+	"lesson10/internal/pkg/totp"
+	"lesson10/internal/repository"
+	"testing"
+	"time"
+)
+
+// testPassword newTestUser 建的用户都用 dummyPasswordHash
+const testPassword = "lesson10-dummy-password"
+
+type twoFactorFixture struct {
+	authSvc       *AuthService
+	twoFactorSvc  *TwoFactorService
+	user          *model.User
+	secret        string
+	recoveryCodes []string
+}
+
+// newTwoFactorFixture 建一个已经开启两步验证的用户，开启时用掉了当前时间步的验证码
+func newTwoFactorFixture(t *testing.T) *twoFactorFixture {
+	t.Helper()
+
+	db := newTestDB(t)
+	authSvc := newTestAuthService(t, db)
+	twoFactorSvc := NewTwoFactorService(repository.NewTwoFactorRepo(db), repository.NewSecurityEventRepo(db), authSvc, db, config.TwoFactorConfig{Issuer: "lesson10"})
+	authSvc.SetTwoFactorService(twoFactorSvc)
+
+	user := newTestUser(t, authSvc, "tina")
+	ctx := context.Background()
+
+	setup, err := twoFactorSvc.Setup(ctx, user.ID, testPassword)
+	if err != nil {
+		t.Fatal(err)
+	}
+	codes, err := twoFactorSvc.Enable(ctx, user.ID, codeAt(t, setup.Secret, 0), "10.0.0.1", "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(codes) != recoveryCodeCount {
+		t.Fatalf("recovery codes = %v", codes)
+	}
+
+	return &twoFactorFixture{authSvc: authSvc, twoFactorSvc: twoFactorSvc, user: user, secret: setup.Secret, recoveryCodes: codes}
+}
+
+// codeAt 当前时间步加 offset 的验证码；offset 为 ±1 时还在允许的时钟误差内
+func codeAt(t *testing.T, secret string, offset int64) string {
+	t.Helper()
+
+	code, err := totp.Code(secret, totp.Step(time.Now())+offset)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return code
+}
+
+func (f *twoFactorFixture) challenge(t *testing.T) string {
+	t.Helper()
+
+	result, err := f.authSvc.Login(context.Background(), dto.LoginRequest{Username: f.user.Username, Password: testPassword}, "10.0.0.1", "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Challenge == nil || result.Pair != nil {
+		t.Fatalf("login with 2fa enabled = %+v", result)
+	}
+	return result.Challenge.ChallengeToken
+}
+
+func (f *twoFactorFixture) completeLogin(challenge string, code string) error {
+	_, err := f.authSvc.CompleteTwoFactorLogin(context.Background(), dto.TwoFactorLoginRequest{ChallengeToken: challenge, Code: code}, "10.0.0.1", "test")
+	return err
+}
+
+func TestChallengeAttemptLimit(t *testing.T) {
+	f := newTwoFactorFixture(t)
+	challenge := f.challenge(t)
+	wrong := codeAt(t, f.secret, 5)
+
+	for i := 0; i < loginChallengeMaxAttempts; i++ {
+		if err := f.completeLogin(challenge, wrong); !errors.Is(err, errcode.ErrTwoFactorCodeIncorrect) {
+			t.Fatalf("attempt %d err = %v", i, err)
+		}
+	}
+	// 次数用完后正确的码也不行，要重新输密码拿新的 challenge
+	if err := f.completeLogin(challenge, codeAt(t, f.secret, 1)); !errors.Is(err, errcode.ErrChallengeExpired) {
+		t.Fatalf("after max attempts err = %v", err)
+	}
+	if n := countEvents(t, f.authSvc, f.user.ID, "2fa_failed"); n != loginChallengeMaxAttempts {
+		t.Fatalf("2fa_failed events = %d", n)
+	}
+
+	if err := f.completeLogin(f.challenge(t), codeAt(t, f.secret, 1)); err != nil {
+		t.Fatalf("new challenge err = %v", err)
+	}
+}
+
+// TestTOTPStepReplay 同一个时间步的码只能用一次，开启时用过的码也不能再拿来登录
+func TestTOTPStepReplay(t *testing.T) {
+	f := newTwoFactorFixture(t)
+
+	if err := f.completeLogin(f.challenge(t), codeAt(t, f.secret, 0)); !errors.Is(err, errcode.ErrTwoFactorCodeIncorrect) {
+		t.Fatalf("code used by enable err = %v", err)
+	}
+
+	next := codeAt(t, f.secret, 1)
+	if err := f.completeLogin(f.challenge(t), next); err != nil {
+		t.Fatalf("next step err = %v", err)
+	}
+	if err := f.completeLogin(f.challenge(t), next); !errors.Is(err, errcode.ErrTwoFactorCodeIncorrect) {
+		t.Fatalf("replayed code err = %v", err)
+	}
+	// 已经用到下一个时间步，更早的码也不再接受
+	if err := f.completeLogin(f.challenge(t), codeAt(t, f.secret, -1)); !errors.Is(err, errcode.ErrTwoFactorCodeIncorrect) {
+		t.Fatalf("older step err = %v", err)
+	}
+}
+
+func TestRecoveryCodeSingleUse(t *testing.T) {
+	f := newTwoFactorFixture(t)
+	ctx := context.Background()
+	code := f.recoveryCodes[0]
+
+	// 大小写和空格不影响
+	if err := f.completeLogin(f.challenge(t), " "+code[:5]+" "+code[6:]+" "); err != nil {
+		t.Fatalf("recovery code err = %v", err)
+	}
+	if err := f.completeLogin(f.challenge(t), code); !errors.Is(err, errcode.ErrTwoFactorCodeIncorrect) {
+		t.Fatalf("reused recovery code err = %v", err)
+	}
+
+	status, err := f.twoFactorSvc.Status(ctx, f.user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.RecoveryCodesLeft != recoveryCodeCount-1 {
+		t.Fatalf("recovery codes left = %d", status.RecoveryCodesLeft)
+	}
+	if n := countEvents(t, f.authSvc, f.user.ID, "2fa_recovery_code_used"); n != 1 {
+		t.Fatalf("2fa_recovery_code_used events = %d", n)
+	}
+
+	// 重新生成后旧码全部作废
+	codes, err := f.twoFactorSvc.RegenerateRecoveryCodes(ctx, f.user.ID, testPassword, codeAt(t, f.secret, 1), "10.0.0.1", "test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := f.completeLogin(f.challenge(t), f.recoveryCodes[1]); !errors.Is(err, errcode.ErrTwoFactorCodeIncorrect) {
+		t.Fatalf("old recovery code err = %v", err)
+	}
+	if err := f.completeLogin(f.challenge(t), codes[0]); err != nil {
+		t.Fatalf("new recovery code err = %v", err)
+	}
+}
+
+func TestDisableRequiresPasswordAndCode(t *testing.T) {
+	f := newTwoFactorFixture(t)
+	ctx := context.Background()
+	disable := func(password string, code string) error {
+		return f.twoFactorSvc.Disable(ctx, f.user.ID, password, code, "10.0.0.1", "test")
+	}
+
+	if err := disable("wrong", codeAt(t, f.secret, 1)); !errors.Is(err, errcode.ErrPasswordIncorrect) {
+		t.Fatalf("wrong password err = %v", err)
+	}
+	if err := disable(testPassword, codeAt(t, f.secret, 5)); !errors.Is(err, errcode.ErrTwoFactorCodeIncorrect) {
+		t.Fatalf("wrong code err = %v", err)
+	}
+	if err := disable(testPassword, f.recoveryCodes[0]); !errors.Is(err, errcode.ErrTwoFactorCodeIncorrect) {
+		t.Fatalf("recovery code err = %v", err)
+	}
+	if enabled, _ := f.twoFactorSvc.IsEnabled(ctx, f.user.ID); !enabled {
+		t.Fatal("2fa disabled by a failed attempt")
+	}
+
+	if err := disable(testPassword, codeAt(t, f.secret, 1)); err != nil {
+		t.Fatal(err)
+	}
+	if enabled, _ := f.twoFactorSvc.IsEnabled(ctx, f.user.ID); enabled {
+		t.Fatal("2fa still enabled")
+	}
+	if n := countEvents(t, f.authSvc, f.user.ID, "2fa_disabled"); n != 1 {
+		t.Fatalf("2fa_disabled events = %d", n)
+	}
+}
+
+// TestReauthenticateFailuresAreThrottled 知道密码的人不能在关闭 / 重新生成恢复码的接口上无限次猜验证码
+func TestReauthenticateFailuresAreThrottled(t *testing.T) {
+	f := newTwoFactorFixture(t)
+	ctx := context.Background()
+	wrong := codeAt(t, f.secret, 5)
+
+	// 前 FreeAttempts 次不限制，再错一次就要等待
+	free := f.authSvc.accountLogin.FreeAttempts
+	for i := 0; i <= free; i++ {
+		if _, err := f.twoFactorSvc.RegenerateRecoveryCodes(ctx, f.user.ID, testPassword, wrong, "10.0.0.1", "test"); !errors.Is(err, errcode.ErrTwoFactorCodeIncorrect) {
+			t.Fatalf("attempt %d err = %v", i, err)
+		}
+	}
+	if n := countEvents(t, f.authSvc, f.user.ID, "2fa_failed"); n != int64(free+1) {
+		t.Fatalf("2fa_failed events = %d", n)
+	}
+
+	// 等待期间正确的码也进不来，密码登录也一样
+	if err := f.twoFactorSvc.Disable(ctx, f.user.ID, testPassword, codeAt(t, f.secret, 1), "10.0.0.1", "test"); !errors.Is(err, errcode.ErrLoginLocked) {
+		t.Fatalf("disable while locked err = %v", err)
+	}
+	if _, err := f.authSvc.Login(ctx, dto.LoginRequest{Username: f.user.Username, Password: testPassword}, "10.0.0.2", "test"); !errors.Is(err, errcode.ErrLoginLocked) {
+		t.Fatalf("login while locked err = %v", err)
+	}
+}
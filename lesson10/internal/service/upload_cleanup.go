@@ -0,0 +1,155 @@
+package service
+
+import (
+	"context"
+	"lesson10/internal/dto"
+	"lesson10/internal/model"
+	"log/slog"
+	"regexp"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const uploadCleanupBatchSize = 200
+
+var (
+	// ![alt](url "title") 和 ![alt](<url>)
+	markdownImagePattern = regexp.MustCompile(`!\[[^\]]*\]\(\s*<?([^\s)>]+)>?`)
+	// [label]: url，配合 ![alt][label] 使用
+	markdownRefPattern = regexp.MustCompile(`(?m)^\s{0,3}\[[^\]]+\]:\s*<?([^\s>]+)>?`)
+	// markdown 里可以直接写 <img src="...">
+	htmlImagePattern = regexp.MustCompile(`(?i)<img\s[^>]*src\s*=\s*["']?([^"'\s>]+)`)
+	// 文章图片的地址里带着 images/<前两位>/<sha256>/，换了存储后端、走签名地址都一样
+	uploadHashPattern = regexp.MustCompile(`images/[0-9a-f]{2}/([0-9a-f]{64})/`)
+)
+
+// referencedImageHashes 从正文的 markdown 图片链接里找出引用了哪些上传的文章图片
+func referencedImageHashes(content string) []string {
+	seen := map[string]bool{}
+	hashes := make([]string, 0)
+
+	for _, pattern := range []*regexp.Regexp{markdownImagePattern, markdownRefPattern, htmlImagePattern} {
+		for _, match := range pattern.FindAllStringSubmatch(content, -1) {
+			hash := uploadHashPattern.FindStringSubmatch(match[1])
+			if hash == nil || seen[hash[1]] {
+				continue
+			}
+			seen[hash[1]] = true
+			hashes = append(hashes, hash[1])
+		}
+	}
+
+	return hashes
+}
+
+// SyncPostImages 帖子保存后调用，按正文重建它引用的图片
+func (s *UploadService) SyncPostImages(ctx context.Context, postID uint, content string) error {
+	return s.imageRepo.ReplacePostRefs(ctx, postID, referencedImageHashes(content))
+}
+
+// RunWorker 定期清理孤儿图片。引用表不全（老帖子、同步失败）也没关系，删之前会按正文再确认一遍
+func (s *UploadService) RunWorker(ctx context.Context) {
+	ticker := time.NewTicker(s.cfg.CleanupInterval)
+	defer ticker.Stop()
+
+	for {
+		report := s.CleanupOnce(ctx, false)
+		if report.Error != "" {
+			slog.ErrorContext(ctx, "upload cleanup failed", "error", report.Error)
+		} else if report.Images > 0 {
+			slog.InfoContext(ctx, "upload cleanup done", "images", report.Images, "files", report.Files, "bytes", report.Bytes, "duration_ms", report.DurationMs)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// CleanupOnce 删除过了宽限期的孤儿图片记录；同一哈希没有其它记录时才删文件。dryRun 时只统计
+func (s *UploadService) CleanupOnce(ctx context.Context, dryRun bool) dto.UploadCleanupReport {
+	started := time.Now()
+	report := dto.UploadCleanupReport{DryRun: dryRun, StartedAt: started.Unix()}
+
+	if err := s.cleanupOrphans(ctx, started.Add(-s.cfg.OrphanGrace), dryRun, &report); err != nil {
+		report.Error = err.Error()
+	}
+	report.DurationMs = time.Since(started).Milliseconds()
+
+	return report
+}
+
+func (s *UploadService) cleanupOrphans(ctx context.Context, cutoff time.Time, dryRun bool, report *dto.UploadCleanupReport) error {
+	var afterID uint
+	for {
+		images, err := s.imageRepo.ListOrphans(ctx, cutoff, afterID, uploadCleanupBatchSize)
+		if err != nil || len(images) == 0 {
+			return err
+		}
+		afterID = images[len(images)-1].ID
+		report.Batches++
+
+		for i := range images {
+			image := &images[i]
+			if dryRun {
+				report.Images++
+				continue
+			}
+
+			deleted, err := s.deleteOrphan(ctx, image, report)
+			if err != nil {
+				return err
+			}
+			if deleted {
+				report.Images++
+			}
+		}
+
+		if len(images) < uploadCleanupBatchSize {
+			return nil
+		}
+	}
+}
+
+// deleteOrphan 锁住这组文件后先按正文补引用，确实没人用才删记录；同一哈希没有其它记录时连文件一起删。
+// 上传复用文件也要先拿同一把锁，不会复用到正在被删的文件
+func (s *UploadService) deleteOrphan(ctx context.Context, image *model.PostImage, report *dto.UploadCleanupReport) (bool, error) {
+	deleted := false
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		repo := s.imageRepo.WithTx(tx)
+		if err := repo.LockHash(ctx, image.Kind, image.ContentHash); err != nil {
+			return err
+		}
+
+		if image.Kind == model.ImageKindArticle {
+			restored, err := repo.RestoreRefs(ctx, image)
+			if err != nil || restored > 0 {
+				return err
+			}
+		}
+
+		if err := repo.Delete(ctx, image.ID); err != nil {
+			return err
+		}
+		deleted = true
+
+		remaining, err := repo.CountByHash(ctx, image.Kind, image.ContentHash)
+		if err != nil || remaining > 0 {
+			return err
+		}
+
+		for _, key := range imageKeys(image) {
+			if err := s.files.Delete(ctx, key); err != nil {
+				return err
+			}
+			report.Files++
+		}
+		report.Bytes += image.Size
+		return nil
+	})
+
+	return deleted && err == nil, err
+}
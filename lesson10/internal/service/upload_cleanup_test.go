@@ -0,0 +1,194 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"lesson10/internal/config"
+	"lesson10/internal/model"
+	"lesson10/internal/pkg/storage"
+	"lesson10/internal/repository"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	hashA = "aa00000000000000000000000000000000000000000000000000000000000001"
+	hashB = "bb00000000000000000000000000000000000000000000000000000000000002"
+)
+
+func TestReferencedImageHashes(t *testing.T) {
+	cases := []struct {
+		name    string
+		content string
+		want    []string
+	}{
+		{"inline", "![a](/static/uploads/images/aa/" + hashA + "/medium.jpg)", []string{hashA}},
+		{"title and angle brackets", `![a](<https://cdn.example.com/images/aa/` + hashA + `/original.png> "t")`, []string{hashA}},
+		{"reference", "![a][1]\n\n  [1]: /static/uploads/images/bb/" + hashB + "/thumb.webp", []string{hashB}},
+		{"html img", `<p><IMG class="x" src='/static/uploads/images/aa/` + hashA + `/medium.jpg'></p>`, []string{hashA}},
+		{"signed s3 url", "![](https://s3.example.com/uploads/images/aa/" + hashA + "/medium.jpg?X-Amz-Signature=abc)", []string{hashA}},
+		{"deduplicated in order", "![](/images/bb/" + hashB + "/a.jpg) ![](/images/aa/" + hashA + "/b.jpg) ![](/images/bb/" + hashB + "/c.jpg)", []string{hashB, hashA}},
+		{"plain link is not an image", "[download](/static/uploads/images/aa/" + hashA + "/original.jpg)", []string{}},
+		{"avatar", "![](/static/uploads/avatars/aa/" + hashA + "/medium.jpg)", []string{}},
+		{"short hash", "![](/static/uploads/images/aa/abcdef/medium.jpg)", []string{}},
+		{"upper case hash", "![](/static/uploads/images/AA/" + strings.ToUpper(hashA) + "/medium.jpg)", []string{}},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := referencedImageHashes(tc.content); !reflect.DeepEqual(got, tc.want) {
+				t.Fatalf("got %v, want %v", got, tc.want)
+			}
+		})
+	}
+}
+
+func newTestUploadService(t *testing.T, db *gorm.DB) *UploadService {
+	t.Helper()
+
+	store, err := storage.NewLocal(storage.LocalConfig{Root: t.TempDir(), URLPrefix: "/static/uploads"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewUploadService(repository.NewImageRepo(db), repository.NewUserRepo(db), store, db, config.UploadConfig{
+		QuotaMB:     10,
+		VIPQuotaMB:  100,
+		OrphanGrace: time.Hour,
+	})
+}
+
+func testJPEG(t *testing.T, shade uint8) []byte {
+	t.Helper()
+
+	img := image.NewNRGBA(image.Rect(0, 0, 64, 64))
+	for i := 0; i < len(img.Pix); i += 4 {
+		img.Pix[i], img.Pix[i+1], img.Pix[i+2], img.Pix[i+3] = shade, 0, 0, 255
+	}
+	img.SetNRGBA(0, 0, color.NRGBA{B: 255, A: 255})
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, img, nil); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+// ageImages 把记录挪到宽限期之前，让清理任务能看到
+func ageImages(t *testing.T, db *gorm.DB, ids ...uint) {
+	t.Helper()
+
+	if err := db.Model(&model.PostImage{}).Where("id IN ?", ids).UpdateColumn("created_at", time.Now().Add(-2*time.Hour)).Error; err != nil {
+		t.Fatal(err)
+	}
+}
+
+func loadImage(t *testing.T, db *gorm.DB, id uint) *model.PostImage {
+	t.Helper()
+
+	var image model.PostImage
+	if err := db.First(&image, id).Error; err != nil {
+		t.Fatal(err)
+	}
+	return &image
+}
+
+func TestCleanupKeepsFilesStillUsedByAnotherUpload(t *testing.T) {
+	db := newTestDB(t)
+	authSvc := newTestAuthService(t, db)
+	uploadSvc := newTestUploadService(t, db)
+	alice, bob := newTestUser(t, authSvc, "alice"), newTestUser(t, authSvc, "bob")
+	ctx := context.Background()
+	raw := testJPEG(t, 200)
+
+	first, err := uploadSvc.UploadArticleImage(ctx, alice.ID, raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 同一张图别人再传一次，复用文件只补记录
+	second, err := uploadSvc.UploadArticleImage(ctx, bob.ID, raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second.ID == first.ID || second.URL != first.URL {
+		t.Fatalf("second upload = %+v, want a new record sharing %s", second, first.URL)
+	}
+	record := loadImage(t, db, first.ID)
+
+	ageImages(t, db, first.ID)
+	report := uploadSvc.CleanupOnce(ctx, false)
+	if report.Error != "" || report.Images != 1 || report.Files != 0 {
+		t.Fatalf("report = %+v, want 1 image and no files deleted", report)
+	}
+	if !uploadSvc.filesExist(ctx, record) {
+		t.Fatal("files deleted while another record still uses them")
+	}
+
+	ageImages(t, db, second.ID)
+	report = uploadSvc.CleanupOnce(ctx, false)
+	if report.Error != "" || report.Images != 1 || report.Files == 0 {
+		t.Fatalf("report = %+v, want last record and its files deleted", report)
+	}
+	if uploadSvc.filesExist(ctx, record) {
+		t.Fatal("files kept after the last record was deleted")
+	}
+
+	// 文件清理掉之后再上传同一张图要重新处理，而不是复用不存在的文件
+	again, err := uploadSvc.UploadArticleImage(ctx, alice.ID, raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !uploadSvc.filesExist(ctx, loadImage(t, db, again.ID)) {
+		t.Fatal("re-upload after cleanup did not write the files again")
+	}
+}
+
+func TestCleanupRestoresMissingRefs(t *testing.T) {
+	db := newTestDB(t)
+	authSvc := newTestAuthService(t, db)
+	uploadSvc := newTestUploadService(t, db)
+	alice := newTestUser(t, authSvc, "alice")
+	ctx := context.Background()
+
+	used, err := uploadSvc.UploadArticleImage(ctx, alice.ID, testJPEG(t, 100))
+	if err != nil {
+		t.Fatal(err)
+	}
+	unused, err := uploadSvc.UploadArticleImage(ctx, alice.ID, testJPEG(t, 50))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 帖子引用了图片，但同步引用失败了，引用表里没有记录
+	post := model.Post{AuthorID: alice.ID, Title: "t", Content: "![](" + used.URL + ")"}
+	if err := db.Create(&post).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	ageImages(t, db, used.ID, unused.ID)
+	report := uploadSvc.CleanupOnce(ctx, false)
+	if report.Error != "" || report.Images != 1 {
+		t.Fatalf("report = %+v, want only the unused image deleted", report)
+	}
+
+	var refs []model.PostImageRef
+	if err := db.Find(&refs).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(refs) != 1 || refs[0].PostID != post.ID || refs[0].ImageID != used.ID {
+		t.Fatalf("refs = %+v, want post %d -> image %d", refs, post.ID, used.ID)
+	}
+	if !uploadSvc.filesExist(ctx, loadImage(t, db, used.ID)) {
+		t.Fatal("image referenced by a post was deleted")
+	}
+
+	// 补上引用之后下一轮直接跳过
+	if report := uploadSvc.CleanupOnce(ctx, false); report.Error != "" || report.Images != 0 {
+		t.Fatalf("second run report = %+v", report)
+	}
+}
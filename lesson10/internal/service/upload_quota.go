@@ -0,0 +1,33 @@
+package service
+
+import (
+	"context"
+	"lesson10/internal/dto"
+	"lesson10/internal/model"
+	"lesson10/internal/pkg/errcode"
+	"time"
+)
+
+// quotaFor 每个用户可用的上传空间，VIP 有效期内用 upload.vip_quota_mb
+func (s *UploadService) quotaFor(user *model.User, now time.Time) (int64, bool) {
+	if user.VIPExpiresAt != nil && now.Before(*user.VIPExpiresAt) {
+		return int64(s.cfg.VIPQuotaMB) << 20, true
+	}
+
+	return int64(s.cfg.QuotaMB) << 20, false
+}
+
+func (s *UploadService) Quota(ctx context.Context, userID uint) (*dto.UploadQuota, error) {
+	var user model.User
+	if err := s.userRepo.FindUserByID(ctx, userID, &user); err != nil {
+		return nil, errcode.ErrInternal
+	}
+
+	used, err := s.imageRepo.SumSizeByUploader(ctx, userID)
+	if err != nil {
+		return nil, errcode.ErrInternal
+	}
+
+	limit, vip := s.quotaFor(&user, time.Now())
+	return &dto.UploadQuota{Used: used, Limit: limit, IsVIP: vip}, nil
+}
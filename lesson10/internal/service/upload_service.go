@@ -0,0 +1,357 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"lesson10/internal/config"
+	"lesson10/internal/dto"
+	"lesson10/internal/model"
+	"lesson10/internal/pkg/cache"
+	"lesson10/internal/pkg/errcode"
+	"lesson10/internal/pkg/imaging"
+	"lesson10/internal/pkg/storage"
+	"lesson10/internal/pkg/tracing"
+	"lesson10/internal/repository"
+	"log/slog"
+	"path"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var articleImageSpec = imaging.Spec{
+	MinSide:   16,
+	MaxSide:   8000,
+	MaxPixels: 40_000_000,
+	Variants: []imaging.VariantSpec{
+		{Name: "thumb", MaxSide: 320},
+		{Name: "medium", MaxSide: 1280},
+		{Name: "original", MaxSide: 2560},
+	},
+}
+
+var avatarImageSpec = imaging.Spec{
+	MinSide:   64,
+	MaxSide:   6000,
+	MaxPixels: 36_000_000,
+	Variants: []imaging.VariantSpec{
+		{Name: "thumb", MaxSide: 96, Square: true},
+		{Name: "medium", MaxSide: 256, Square: true},
+		{Name: "original", MaxSide: 1024, Square: true},
+	},
+}
+
+// UploadService 上传的图片统一走 imaging 处理后按内容哈希存进 storage：<dir>/<hash 前两位>/<hash>/<档位>.<扩展名>
+type UploadService struct {
+	imageRepo repository.ImageRepository
+	userRepo  repository.UserRepository
+	files     storage.Storage
+	db        *gorm.DB
+	cfg       config.UploadConfig
+	cache     *cache.Cache
+}
+
+func NewUploadService(imageRepo repository.ImageRepository, userRepo repository.UserRepository, store storage.Storage, db *gorm.DB, cfg config.UploadConfig) *UploadService {
+	return &UploadService{imageRepo: imageRepo, userRepo: userRepo, files: store, db: db, cfg: cfg}
+}
+
+func (s *UploadService) SetCache(c *cache.Cache) {
+	s.cache = c
+}
+
+// UploadAvatar 头像裁成正方形，用户资料里的 avatar_url 指向 medium 档
+func (s *UploadService) UploadAvatar(ctx context.Context, userID uint, raw []byte) (*dto.UploadedImage, error) {
+	image, err := s.store(ctx, userID, model.ImageKindAvatar, avatarImageSpec, raw)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.userRepo.UpdateUserAvatar(ctx, userID, variantURL(image.Variants, "medium", image.URL)); err != nil {
+		return nil, errcode.ErrInternal
+	}
+	invalidateUser(ctx, s.cache, userID)
+
+	return image, nil
+}
+
+func (s *UploadService) UploadArticleImage(ctx context.Context, userID uint, raw []byte) (*dto.UploadedImage, error) {
+	return s.store(ctx, userID, model.ImageKindArticle, articleImageSpec, raw)
+}
+
+// store 去重复用文件和清理孤儿文件都要先锁住这组文件（ImageBlob），锁里再确认文件还在；
+// 图片处理比较慢，放在锁外面先做
+func (s *UploadService) store(ctx context.Context, userID uint, kind string, spec imaging.Spec, raw []byte) (*dto.UploadedImage, error) {
+	ctx, span := tracing.Start(ctx, "UploadService.store")
+	defer span.End()
+
+	hash := imaging.Hash(raw)
+	existing, err := s.imageRepo.FindByHash(ctx, kind, hash)
+	if err != nil {
+		return nil, errcode.ErrInternal
+	}
+
+	// 同样的内容已经处理过：同一个人重复上传直接返回原记录，不重复计入配额
+	for i := range existing {
+		if existing[i].UploaderID == userID {
+			return toUploadedImage(&existing[i]), nil
+		}
+	}
+
+	// 配额是软限制：并发上传可能略微超出，不加锁
+	quota, err := s.Quota(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if quota.Used >= quota.Limit {
+		return nil, errcode.ErrUploadQuota
+	}
+
+	var result *imaging.Result
+	if len(existing) == 0 || !s.filesExist(ctx, &existing[0]) {
+		if result, err = s.process(ctx, kind, spec, raw); err != nil {
+			return nil, err
+		}
+	}
+
+	var record *model.PostImage
+	err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		repo := s.imageRepo.WithTx(tx)
+		if err := repo.LockHash(ctx, kind, hash); err != nil {
+			return errcode.ErrInternal
+		}
+
+		// 等锁的时候文件可能刚被清理掉，也可能同一个人的另一个请求已经传完了，拿到锁之后重新查
+		existing, err := repo.FindByHash(ctx, kind, hash)
+		if err != nil {
+			return errcode.ErrInternal
+		}
+		for i := range existing {
+			if existing[i].UploaderID == userID {
+				record = &existing[i]
+				return nil
+			}
+		}
+
+		// 别人上传过的复用文件、只补一条记录；文件已经被清理掉了就重新处理
+		if len(existing) > 0 && s.filesExist(ctx, &existing[0]) {
+			source := existing[0]
+			if quota.Used+source.Size > quota.Limit {
+				return errcode.ErrUploadQuota
+			}
+
+			record = &model.PostImage{
+				UploaderID:  userID,
+				Kind:        kind,
+				ContentHash: hash,
+				URL:         source.URL,
+				Width:       source.Width,
+				Height:      source.Height,
+				Size:        source.Size,
+				Variants:    source.Variants,
+			}
+			if err := repo.Create(ctx, record); err != nil {
+				return errcode.ErrInternal
+			}
+			return nil
+		}
+
+		if result == nil {
+			if result, err = s.process(ctx, kind, spec, raw); err != nil {
+				return err
+			}
+		}
+		if quota.Used+result.Size() > quota.Limit {
+			return errcode.ErrUploadQuota
+		}
+
+		record, err = s.save(ctx, userID, kind, hash, result)
+		if err != nil {
+			return err
+		}
+		if err := repo.Create(ctx, record); err != nil {
+			return errcode.ErrInternal
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return toUploadedImage(record), nil
+}
+
+func (s *UploadService) process(ctx context.Context, kind string, spec imaging.Spec, raw []byte) (*imaging.Result, error) {
+	result, err := imaging.Process(raw, spec)
+	if err != nil {
+		if errors.Is(err, errcode.ErrUnsupportedImage) || errors.Is(err, errcode.ErrImageDimensions) {
+			return nil, err
+		}
+		slog.ErrorContext(ctx, "process image failed", "kind", kind, "error", err)
+		return nil, errcode.ErrInternal
+	}
+
+	return result, nil
+}
+
+// save 把各个档位写进 storage，返回还没入库的记录
+func (s *UploadService) save(ctx context.Context, userID uint, kind string, hash string, result *imaging.Result) (*model.PostImage, error) {
+	record := &model.PostImage{
+		UploaderID:  userID,
+		Kind:        kind,
+		ContentHash: hash,
+		Width:       result.Width,
+		Height:      result.Height,
+		Variants:    make([]model.ImageVariant, 0, len(result.Variants)),
+	}
+
+	key := path.Join(uploadDir(kind), hash[:2], hash)
+	for _, variant := range result.Variants {
+		item := model.ImageVariant{Name: variant.Name, Width: variant.Width, Height: variant.Height}
+
+		var err error
+		item.URL, err = s.put(ctx, key, variant.Name, variant.Primary)
+		if err != nil {
+			slog.ErrorContext(ctx, "save image failed", "kind", kind, "error", err)
+			return nil, errcode.ErrInternal
+		}
+		record.Size += int64(len(variant.Primary.Data))
+
+		if variant.WebP != nil {
+			item.WebPURL, err = s.put(ctx, key, variant.Name, *variant.WebP)
+			if err != nil {
+				slog.ErrorContext(ctx, "save image failed", "kind", kind, "error", err)
+				return nil, errcode.ErrInternal
+			}
+			record.Size += int64(len(variant.WebP.Data))
+		}
+
+		record.Variants = append(record.Variants, item)
+	}
+	record.URL = variantURL(record.Variants, "original", "")
+
+	return record, nil
+}
+
+// SignedURL 给自己上传的图片生成临时下载地址，有效期 upload.signed_url_ttl（默认 15 分钟）
+func (s *UploadService) SignedURL(ctx context.Context, userID uint, imageID uint, variantName string) (*dto.SignedImageURL, error) {
+	image, err := s.imageRepo.FindByID(ctx, imageID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errcode.ErrNotFound
+		}
+		return nil, errcode.ErrInternal
+	}
+	if image.UploaderID != userID {
+		return nil, errcode.ErrNotFound
+	}
+
+	if variantName == "" {
+		variantName = "original"
+	}
+	key, ok := variantKey(image, variantName, false)
+	if !ok {
+		return nil, errcode.ErrNotFound
+	}
+
+	ttl := s.cfg.SignedURLTTL
+	signed, err := s.files.SignedURL(ctx, key, ttl)
+	if err != nil {
+		slog.ErrorContext(ctx, "sign image url failed", "key", key, "error", err)
+		return nil, errcode.ErrInternal
+	}
+
+	return &dto.SignedImageURL{URL: signed, ExpiresAt: time.Now().Add(ttl).Unix()}, nil
+}
+
+// filesExist 只看 original 档，清理任务是整组删的
+func (s *UploadService) filesExist(ctx context.Context, image *model.PostImage) bool {
+	key, ok := variantKey(image, "original", false)
+	if !ok {
+		return false
+	}
+
+	exists, err := s.files.Exists(ctx, key)
+	if err != nil {
+		slog.ErrorContext(ctx, "check stored file failed", "key", key, "error", err)
+		return false
+	}
+
+	return exists
+}
+
+// imageKeys 一条记录对应的全部文件 key（各档位的 JPEG/PNG 和 WebP）
+func imageKeys(image *model.PostImage) []string {
+	keys := make([]string, 0, len(image.Variants)*2)
+	for _, variant := range image.Variants {
+		if key, ok := variantKey(image, variant.Name, false); ok {
+			keys = append(keys, key)
+		}
+		if key, ok := variantKey(image, variant.Name, true); ok {
+			keys = append(keys, key)
+		}
+	}
+
+	return keys
+}
+
+// variantKey 由哈希和档位名拼出文件 key，扩展名取自已存的地址，存储后端换过也能对上
+func variantKey(image *model.PostImage, name string, webp bool) (string, bool) {
+	if len(image.ContentHash) < 2 {
+		return "", false
+	}
+
+	for _, variant := range image.Variants {
+		if variant.Name != name {
+			continue
+		}
+		fileURL := variant.URL
+		if webp {
+			fileURL = variant.WebPURL
+		}
+		if fileURL == "" {
+			return "", false
+		}
+		return path.Join(uploadDir(image.Kind), image.ContentHash[:2], image.ContentHash, name+path.Ext(fileURL)), true
+	}
+
+	return "", false
+}
+
+func uploadDir(kind string) string {
+	if kind == model.ImageKindAvatar {
+		return "avatars"
+	}
+
+	return "images"
+}
+
+func (s *UploadService) put(ctx context.Context, key string, name string, file imaging.File) (string, error) {
+	objectKey := path.Join(key, name+file.Ext)
+	if err := s.files.Put(ctx, objectKey, file.Data, file.ContentType); err != nil {
+		return "", err
+	}
+
+	return s.files.URL(objectKey), nil
+}
+
+func variantURL(variants []model.ImageVariant, name string, fallback string) string {
+	for _, variant := range variants {
+		if variant.Name == name {
+			return variant.URL
+		}
+	}
+
+	return fallback
+}
+
+func toUploadedImage(image *model.PostImage) *dto.UploadedImage {
+	return &dto.UploadedImage{
+		ID:       image.ID,
+		Kind:     image.Kind,
+		URL:      image.URL,
+		Hash:     image.ContentHash,
+		Width:    image.Width,
+		Height:   image.Height,
+		Variants: image.Variants,
+	}
+}
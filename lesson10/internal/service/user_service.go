@@ -3,10 +3,14 @@ package service
 import (
 	"context"
 	"errors"
+	"lesson10/internal/config"
 	"lesson10/internal/dto"
 	"lesson10/internal/model"
+	"lesson10/internal/pkg/cache"
 	"lesson10/internal/pkg/errcode"
+	"lesson10/internal/pkg/tracing"
 	"lesson10/internal/repository"
+	"log/slog"
 	"strings"
 	"time"
 
@@ -20,6 +24,10 @@ type UserService struct {
 	followRepo repository.FollowRepository
 	db         *gorm.DB
 	authSvc    *AuthService
+	emailSvc   *EmailService
+	searchSvc  *SearchService
+	cache      *cache.Cache
+	cacheTTL   config.CacheTTL
 }
 
 func NewUserService(userRepo repository.UserRepository, followRepo repository.FollowRepository, postRepo repository.PostRepository, db *gorm.DB) *UserService {
@@ -35,7 +43,23 @@ func (r *UserService) SetAuthService(authSvc *AuthService) {
 	r.authSvc = authSvc
 }
 
+func (r *UserService) SetEmailService(emailSvc *EmailService) {
+	r.emailSvc = emailSvc
+}
+
+func (r *UserService) SetCache(c *cache.Cache, ttl config.CacheTTL) {
+	r.cache = c
+	r.cacheTTL = ttl
+}
+
+func (r *UserService) SetSearchService(searchSvc *SearchService) {
+	r.searchSvc = searchSvc
+}
+
 func (r *UserService) RegisterService(ctx context.Context, req dto.RegisterRequest) (*model.User, error) {
+	ctx, span := tracing.Start(ctx, "UserService.Register")
+	defer span.End()
+
 	exists, err := r.userRepo.ExistsByUsername(ctx, req.Username)
 	if err != nil {
 		return nil, errcode.ErrInternal
@@ -44,6 +68,18 @@ func (r *UserService) RegisterService(ctx context.Context, req dto.RegisterReque
 		return nil, errcode.ErrConflict
 	}
 
+	var email *string
+	if normalized := NormalizeEmail(req.Email); normalized != "" {
+		exists, err := r.userRepo.ExistsByEmail(ctx, normalized)
+		if err != nil {
+			return nil, errcode.ErrInternal
+		}
+		if exists {
+			return nil, errcode.ErrConflict
+		}
+		email = &normalized
+	}
+
 	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
 	if err != nil {
 		return nil, errcode.ErrInternal
@@ -52,11 +88,22 @@ func (r *UserService) RegisterService(ctx context.Context, req dto.RegisterReque
 	user := &model.User{
 		Username:     req.Username,
 		PasswordHash: string(hashedPassword),
+		Email:        email,
 	}
 
 	if err := r.userRepo.CreateUser(ctx, user); err != nil {
 		return nil, errcode.ErrInternal
 	}
+	if r.searchSvc != nil {
+		r.searchSvc.IndexUser(ctx, user)
+	}
+
+	// 验证邮件发送失败不影响注册，用户可以稍后重发
+	if email != nil && r.emailSvc != nil {
+		if err := r.emailSvc.SendVerification(ctx, user); err != nil {
+			slog.ErrorContext(ctx, "send verification email failed", "target_user_id", user.ID, "error", err)
+		}
+	}
 
 	return user, nil
 }
@@ -71,12 +118,15 @@ func (r *UserService) LoginService(
 		return "", "", nil, errcode.ErrInternal
 	}
 
-	pair, user, _, err := r.authSvc.Login(ctx, req, ip, ua)
+	result, err := r.authSvc.Login(ctx, req, ip, ua)
 	if err != nil {
 		return "", "", nil, err
 	}
+	if result.Challenge != nil {
+		return "", "", nil, errcode.ErrTwoFactorRequired
+	}
 
-	return pair.AccessToken, pair.RefreshToken, user, nil
+	return result.Pair.AccessToken, result.Pair.RefreshToken, result.User, nil
 }
 
 func (r *UserService) ChangePassService(ctx context.Context, req dto.ChangePassRequest, id uint) error {
@@ -88,7 +138,8 @@ func (r *UserService) ChangePassService(ctx context.Context, req dto.ChangePassR
 		return errcode.ErrInternal
 	}
 
-	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(req.OldPass)); err != nil {
+	// 没有密码的账号（OIDC 自动注册）可以直接设置一个
+	if !checkPassword(&user, req.OldPass) {
 		return errcode.ErrForbidden
 	}
 
@@ -132,41 +183,76 @@ func (r *UserService) UpdateProfileService(ctx context.Context, req dto.UpdatePr
 	if res.RowsAffected == 0 {
 		return errcode.ErrNotFound
 	}
-
-	return nil
-}
-
-func (r *UserService) UpdateAvatarService(ctx context.Context, userID uint, avatarURL string) error {
-	if err := r.userRepo.UpdateUserAvatar(ctx, userID, avatarURL); err != nil {
-		return errcode.ErrInternal
+	invalidateUser(ctx, r.cache, id)
+
+	if r.searchSvc != nil {
+		var user model.User
+		if err := r.userRepo.FindUserByID(ctx, id, &user); err != nil {
+			slog.ErrorContext(ctx, "reload user for search index failed", "target_user_id", id, "error", err)
+		} else {
+			r.searchSvc.IndexUser(ctx, &user)
+		}
 	}
 
 	return nil
 }
 
 func (r *UserService) GetUserInfoService(ctx context.Context, currentID, id uint, page int) (*dto.UserPublicInfo, error) {
-	var user model.User
-	err := r.userRepo.FindUserByID(ctx, id, &user)
-	if errors.Is(err, gorm.ErrRecordNotFound) {
-		return nil, errcode.ErrNotFound
-	}
+	ctx, span := tracing.Start(ctx, "UserService.GetUserInfo")
+	defer span.End()
+
+	user, err := cache.Fetch(ctx, r.cache, userInfoKey(id), r.cacheTTL.User, func(ctx context.Context) (cachedUserInfo, error) {
+		var user model.User
+		err := r.userRepo.FindUserByID(ctx, id, &user)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return cachedUserInfo{}, errcode.ErrNotFound
+		}
+		if err != nil {
+			return cachedUserInfo{}, errcode.ErrInternal
+		}
+		return cachedUserInfo{
+			ID:           user.ID,
+			Username:     user.Username,
+			Profile:      user.Profile,
+			AvatarURL:    user.AvatarURL,
+			Role:         user.Role,
+			VIPExpiresAt: user.VIPExpiresAt,
+		}, nil
+	})
 	if err != nil {
-		return nil, errcode.ErrInternal
+		return nil, err
 	}
 
 	const size = 5
 	offset := (page - 1) * size
 
-	posts, err := r.postRepo.ListUserPublicPosts(ctx, id, offset, size)
-	if err != nil {
-		return nil, errcode.ErrInternal
+	loadPosts := func(ctx context.Context) (cachedUserPosts, error) {
+		posts, err := r.postRepo.ListUserPublicPosts(ctx, id, offset, size)
+		if err != nil {
+			return cachedUserPosts{}, errcode.ErrInternal
+		}
+
+		total, err := r.postRepo.CountUserPublicPosts(ctx, id)
+		if err != nil {
+			return cachedUserPosts{}, errcode.ErrInternal
+		}
+
+		return cachedUserPosts{Posts: postSummaries(posts), Total: total}, nil
 	}
 
-	total, err := r.postRepo.CountUserPublicPosts(ctx, id)
+	var public cachedUserPosts
+	if page == 1 {
+		public, err = cache.Fetch(ctx, r.cache, userPostsKey(id), r.cacheTTL.User, loadPosts)
+	} else {
+		public, err = loadPosts(ctx)
+	}
 	if err != nil {
-		return nil, errcode.ErrInternal
+		return nil, err
 	}
 
+	summaries := append([]dto.PostSummary{}, public.Posts...)
+	total := public.Total
+
 	if currentID == id {
 		draftPosts, err := r.postRepo.ListUserDraftPost(ctx, id, offset, size)
 		if err != nil {
@@ -178,30 +264,27 @@ func (r *UserService) GetUserInfoService(ctx context.Context, currentID, id uint
 			return nil, errcode.ErrInternal
 		}
 
-		posts = append(posts, draftPosts...)
+		summaries = append(summaries, postSummaries(draftPosts)...)
 		total += draftTotal
 	}
 
-	postSummaries := make([]dto.PostSummary, len(posts))
-	for i, p := range posts {
-		postSummaries[i] = dto.PostSummary{
-			ID:        p.ID,
-			Title:     p.Title,
-			CreatedAt: p.CreatedAt,
-			Status:    p.Status,
-		}
-	}
-
 	isVIP := user.VIPExpiresAt != nil && time.Now().Before(*user.VIPExpiresAt)
 
-	followingCount, err := r.followRepo.CountFollowing(ctx, id)
-	if err != nil {
-		return nil, errcode.ErrInternal
-	}
+	counts, err := cache.Fetch(ctx, r.cache, followCountsKey(id), r.cacheTTL.User, func(ctx context.Context) (cachedFollowCounts, error) {
+		followingCount, err := r.followRepo.CountFollowing(ctx, id)
+		if err != nil {
+			return cachedFollowCounts{}, errcode.ErrInternal
+		}
 
-	followersCount, err := r.followRepo.CountFollowers(ctx, id)
+		followersCount, err := r.followRepo.CountFollowers(ctx, id)
+		if err != nil {
+			return cachedFollowCounts{}, errcode.ErrInternal
+		}
+
+		return cachedFollowCounts{Following: followingCount, Followers: followersCount}, nil
+	})
 	if err != nil {
-		return nil, errcode.ErrInternal
+		return nil, err
 	}
 
 	isFollowed := false
@@ -220,16 +303,30 @@ func (r *UserService) GetUserInfoService(ctx context.Context, currentID, id uint
 		Role:           user.Role,
 		IsVIP:          isVIP,
 		VIPExpiresAt:   user.VIPExpiresAt,
-		Posts:          postSummaries,
+		Posts:          summaries,
 		PostTotal:      total,
-		FollowingCount: followingCount,
-		FollowersCount: followersCount,
+		FollowingCount: counts.Following,
+		FollowersCount: counts.Followers,
 		IsFollowed:     isFollowed,
 		Page:           page,
 		Size:           size,
 	}, nil
 }
 
+func postSummaries(posts []model.Post) []dto.PostSummary {
+	summaries := make([]dto.PostSummary, len(posts))
+	for i, p := range posts {
+		summaries[i] = dto.PostSummary{
+			ID:        p.ID,
+			Title:     p.Title,
+			CreatedAt: p.CreatedAt,
+			Status:    p.Status,
+		}
+	}
+
+	return summaries
+}
+
 func (r *UserService) RefreshWithWhitelist(
 	ctx context.Context,
 	rawRefresh string,
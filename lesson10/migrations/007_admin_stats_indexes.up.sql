@@ -0,0 +1,7 @@
+-- 管理后台统计按时间范围聚合，补齐时间列索引
+ALTER TABLE users ADD INDEX idx_users_created_at (created_at);
+ALTER TABLE posts ADD INDEX idx_posts_created_at (created_at);
+ALTER TABLE comments ADD INDEX idx_comments_created_at (created_at);
+ALTER TABLE reactions ADD INDEX idx_reactions_created_at (created_at);
+ALTER TABLE sessions ADD INDEX idx_sessions_last_seen_at (last_seen_at);
+ALTER TABLE security_events ADD INDEX idx_security_events_created_at (created_at);
@@ -0,0 +1,35 @@
+CREATE TABLE data_exports (
+                              id BIGINT NOT NULL AUTO_INCREMENT,
+                              user_id BIGINT NOT NULL,
+                              status VARCHAR(32) NOT NULL,             -- pending/processing/ready/failed/expired
+                              token_hash VARCHAR(64) NOT NULL,         -- 下载链接 token 的 SHA-256
+                              file_path VARCHAR(255) NULL,
+                              file_size BIGINT NOT NULL DEFAULT 0,
+                              error VARCHAR(255) NULL,
+                              expires_at DATETIME(3) NULL,
+                              completed_at DATETIME(3) NULL,
+                              created_at DATETIME(3) NULL,
+                              updated_at DATETIME(3) NULL,
+
+                              PRIMARY KEY (id),
+                              UNIQUE KEY idx_data_exports_token_hash (token_hash),
+                              KEY idx_data_exports_user_id (user_id),
+                              KEY idx_data_exports_status (status)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE account_deletions (
+                                   id BIGINT NOT NULL AUTO_INCREMENT,
+                                   user_id BIGINT NOT NULL,
+                                   status VARCHAR(32) NOT NULL,         -- pending/cancelled/completed
+                                   policy VARCHAR(32) NOT NULL,         -- anonymize/remove
+                                   scheduled_at DATETIME(3) NULL,       -- 宽限期结束时间
+                                   cancelled_at DATETIME(3) NULL,
+                                   completed_at DATETIME(3) NULL,
+                                   created_at DATETIME(3) NULL,
+                                   updated_at DATETIME(3) NULL,
+
+                                   PRIMARY KEY (id),
+                                   KEY idx_account_deletions_user_id (user_id),
+                                   KEY idx_account_deletions_status (status),
+                                   KEY idx_account_deletions_scheduled_at (scheduled_at)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
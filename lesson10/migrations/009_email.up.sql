@@ -0,0 +1,19 @@
+ALTER TABLE users
+    ADD COLUMN email VARCHAR(191) NULL,
+    ADD COLUMN email_verified_at DATETIME(3) NULL,
+    ADD UNIQUE KEY idx_users_email (email);
+
+CREATE TABLE email_tokens (
+                              id BIGINT NOT NULL AUTO_INCREMENT,
+                              user_id BIGINT NOT NULL,
+                              purpose VARCHAR(32) NOT NULL,            -- verify_email/password_reset
+                              email VARCHAR(191) NOT NULL,             -- 发出链接时的邮箱，换绑后旧链接失效
+                              token_hash VARCHAR(64) NOT NULL,         -- 链接 token 的 SHA-256
+                              expires_at DATETIME(3) NOT NULL,
+                              used_at DATETIME(3) NULL,
+                              created_at DATETIME(3) NULL,
+
+                              PRIMARY KEY (id),
+                              UNIQUE KEY idx_email_tokens_token_hash (token_hash),
+                              KEY idx_email_tokens_user_purpose (user_id, purpose)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
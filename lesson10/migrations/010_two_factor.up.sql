@@ -0,0 +1,42 @@
+CREATE TABLE user_totps (
+                            id BIGINT NOT NULL AUTO_INCREMENT,
+                            user_id BIGINT NOT NULL,
+                            secret VARCHAR(64) NOT NULL,              -- base32 密钥
+                            enabled_at DATETIME(3) NULL,              -- 为空表示还没用验证码确认
+                            last_used_step BIGINT NOT NULL DEFAULT 0, -- 防重放
+                            created_at DATETIME(3) NULL,
+                            updated_at DATETIME(3) NULL,
+
+                            PRIMARY KEY (id),
+                            UNIQUE KEY idx_user_totps_user_id (user_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE totp_recovery_codes (
+                                     id BIGINT NOT NULL AUTO_INCREMENT,
+                                     user_id BIGINT NOT NULL,
+                                     code_hash VARCHAR(64) NOT NULL,  -- 恢复码的 SHA-256
+                                     used_at DATETIME(3) NULL,
+                                     created_at DATETIME(3) NULL,
+
+                                     PRIMARY KEY (id),
+                                     UNIQUE KEY idx_totp_recovery_codes_code_hash (code_hash),
+                                     KEY idx_totp_recovery_codes_user_id (user_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE login_challenges (
+                                  id BIGINT NOT NULL AUTO_INCREMENT,
+                                  user_id BIGINT NOT NULL,
+                                  token_hash VARCHAR(64) NOT NULL,
+                                  device_id VARCHAR(128) NULL,
+                                  device_name VARCHAR(128) NULL,
+                                  ip VARCHAR(64) NULL,
+                                  user_agent VARCHAR(512) NULL,
+                                  attempts BIGINT NOT NULL DEFAULT 0,
+                                  expires_at DATETIME(3) NULL,
+                                  used_at DATETIME(3) NULL,
+                                  created_at DATETIME(3) NULL,
+
+                                  PRIMARY KEY (id),
+                                  UNIQUE KEY idx_login_challenges_token_hash (token_hash),
+                                  KEY idx_login_challenges_user_id (user_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
@@ -0,0 +1,32 @@
+CREATE TABLE user_identities (
+                                 id BIGINT NOT NULL AUTO_INCREMENT,
+                                 user_id BIGINT NOT NULL,
+                                 provider VARCHAR(32) NOT NULL,
+                                 subject VARCHAR(191) NOT NULL,       -- id_token 里的 sub
+                                 email VARCHAR(191) NULL,
+                                 created_at DATETIME(3) NULL,
+                                 updated_at DATETIME(3) NULL,
+
+                                 PRIMARY KEY (id),
+                                 UNIQUE KEY idx_user_identities_provider_subject (provider, subject),
+                                 UNIQUE KEY idx_user_identities_user_provider (user_id, provider)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE oauth_states (
+                              id BIGINT NOT NULL AUTO_INCREMENT,
+                              state_hash VARCHAR(64) NOT NULL,
+                              provider VARCHAR(32) NOT NULL,
+                              purpose VARCHAR(16) NOT NULL,            -- login/link
+                              user_id BIGINT NULL,
+                              nonce VARCHAR(64) NOT NULL,
+                              code_verifier VARCHAR(128) NOT NULL,     -- PKCE
+                              device_id VARCHAR(128) NULL,
+                              device_name VARCHAR(128) NULL,
+                              expires_at DATETIME(3) NULL,
+                              used_at DATETIME(3) NULL,
+                              created_at DATETIME(3) NULL,
+
+                              PRIMARY KEY (id),
+                              UNIQUE KEY idx_oauth_states_state_hash (state_hash),
+                              KEY idx_oauth_states_user_id (user_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
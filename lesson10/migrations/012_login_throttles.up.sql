@@ -0,0 +1,13 @@
+CREATE TABLE login_throttles (
+                                 id BIGINT NOT NULL AUTO_INCREMENT,
+                                 scope VARCHAR(16) NOT NULL,          -- account/ip
+                                 `key` VARCHAR(191) NOT NULL,         -- 小写用户名或 IP
+                                 failures BIGINT NOT NULL DEFAULT 0,
+                                 last_failed_at DATETIME(3) NULL,
+                                 locked_until DATETIME(3) NULL,
+                                 updated_at DATETIME(3) NULL,
+
+                                 PRIMARY KEY (id),
+                                 UNIQUE KEY idx_login_throttles_scope_key (scope, `key`),
+                                 KEY idx_login_throttles_locked_until (locked_until)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
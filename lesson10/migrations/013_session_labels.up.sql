@@ -0,0 +1,3 @@
+ALTER TABLE sessions
+    ADD COLUMN label VARCHAR(64) NOT NULL DEFAULT '' AFTER device_name,
+    ADD INDEX idx_sessions_user_revoked_at (user_id, revoked_at);
@@ -0,0 +1 @@
+ALTER TABLE security_events ADD INDEX idx_security_events_user_type_created (user_id, event_type, created_at);
@@ -0,0 +1,5 @@
+ALTER TABLE refresh_tokens
+    ADD INDEX idx_refresh_tokens_status_updated_at (status, updated_at),
+    ADD INDEX idx_refresh_tokens_expires_at (expires_at);
+ALTER TABLE sessions
+    ADD INDEX idx_sessions_status_revoked_at (status, revoked_at);
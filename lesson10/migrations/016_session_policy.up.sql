@@ -0,0 +1,8 @@
+ALTER TABLE sessions
+    ADD COLUMN remember_me TINYINT(1) NOT NULL DEFAULT 0 AFTER last_seen_at;
+
+ALTER TABLE login_challenges
+    ADD COLUMN remember_me TINYINT(1) NOT NULL DEFAULT 0 AFTER device_name;
+
+ALTER TABLE oauth_states
+    ADD COLUMN remember_me TINYINT(1) NOT NULL DEFAULT 0 AFTER device_name;
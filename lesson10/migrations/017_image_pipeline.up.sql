@@ -0,0 +1,14 @@
+-- 文章图片上传时还没有帖子，post_id 先写 0，关联帖子后再更新。
+-- 外键不允许 0 这种不存在的帖子 id，所以去掉 fk_images_post；头像也存在这张表里，本来就没有帖子。
+-- 图片和帖子的对应关系改由 post_image_refs（018）维护，没人引用的图片由清理任务删掉
+ALTER TABLE post_images
+    DROP FOREIGN KEY fk_images_post;
+
+ALTER TABLE post_images
+    ADD COLUMN kind VARCHAR(16) NOT NULL DEFAULT 'article' AFTER uploader_id,
+    ADD COLUMN content_hash VARCHAR(64) NOT NULL DEFAULT '' AFTER kind,
+    ADD COLUMN width INT NOT NULL DEFAULT 0 AFTER url,
+    ADD COLUMN height INT NOT NULL DEFAULT 0 AFTER width,
+    ADD COLUMN size BIGINT NOT NULL DEFAULT 0 AFTER height,
+    ADD COLUMN variants TEXT NULL AFTER size,
+    ADD INDEX idx_post_images_kind_hash (kind, content_hash);
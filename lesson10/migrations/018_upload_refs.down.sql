@@ -0,0 +1,5 @@
+-- 回滚 018：引用记录删掉后清理任务会把所有没被头像引用的图片当成孤儿，回滚前先停掉 janitor
+ALTER TABLE post_images
+    DROP INDEX idx_post_images_created_at;
+
+DROP TABLE post_image_refs;
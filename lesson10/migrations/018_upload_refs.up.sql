@@ -0,0 +1,15 @@
+-- 帖子引用了哪些上传的图片，用于清理没人用的文件
+CREATE TABLE post_image_refs (
+                                 id BIGINT NOT NULL AUTO_INCREMENT,
+                                 post_id BIGINT NOT NULL,
+                                 image_id BIGINT NOT NULL,
+                                 created_at DATETIME(3) NULL,
+
+                                 PRIMARY KEY (id),
+                                 UNIQUE KEY uk_post_image_ref (post_id, image_id),
+                                 KEY idx_post_image_refs_image_id (image_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+-- 清理任务按上传时间扫描
+ALTER TABLE post_images
+    ADD INDEX idx_post_images_created_at (created_at);
@@ -0,0 +1,6 @@
+-- 回滚 019：渲染缓存可以随时重新生成，删掉不丢数据
+ALTER TABLE posts
+    DROP INDEX idx_posts_render_version,
+    DROP COLUMN render_version,
+    DROP COLUMN excerpt,
+    DROP COLUMN content_html;
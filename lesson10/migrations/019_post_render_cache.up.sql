@@ -0,0 +1,6 @@
+-- 帖子正文渲染后的 HTML 缓存和列表摘要；老帖子 render_version 为 0，启动后由后台补渲染
+ALTER TABLE posts
+    ADD COLUMN content_html LONGTEXT NULL AFTER content,
+    ADD COLUMN excerpt VARCHAR(512) NOT NULL DEFAULT '' AFTER content_html,
+    ADD COLUMN render_version INT NOT NULL DEFAULT 0 AFTER excerpt,
+    ADD INDEX idx_posts_render_version (render_version);
@@ -0,0 +1,2 @@
+-- 回滚 020：统计改回按 sessions.last_seen_at，记录下来的日活会丢掉
+DROP TABLE daily_active_users;
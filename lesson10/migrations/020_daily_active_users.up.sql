@@ -0,0 +1,14 @@
+-- 日活单独记录：每个用户每天一行。之前按 sessions.last_seen_at 统计，它只保留最后一次活跃时间，过去的天数会少算
+CREATE TABLE daily_active_users (
+    day DATE NOT NULL,
+    user_id BIGINT NOT NULL,
+
+    PRIMARY KEY (day, user_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+-- 历史数据只能用会话的登录时间和最后活跃时间尽量补
+INSERT IGNORE INTO daily_active_users (day, user_id)
+SELECT DISTINCT DATE(created_at), user_id FROM sessions;
+
+INSERT IGNORE INTO daily_active_users (day, user_id)
+SELECT DISTINCT DATE(last_seen_at), user_id FROM sessions;
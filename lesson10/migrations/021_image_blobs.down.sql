@@ -0,0 +1,2 @@
+-- 回滚 021：上传复用和孤儿清理之间又没有锁了
+DROP TABLE image_blobs;
@@ -0,0 +1,9 @@
+-- 每组去重后的图片文件一行，上传复用和孤儿清理都先 SELECT ... FOR UPDATE 锁住它再动文件；
+-- 行在第一次用到时插入，不需要回填
+CREATE TABLE image_blobs (
+    kind VARCHAR(16) NOT NULL,
+    content_hash VARCHAR(64) NOT NULL,
+    created_at DATETIME(3) NULL,
+
+    PRIMARY KEY (kind, content_hash)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
@@ -0,0 +1,2 @@
+-- 索引属于 007，回滚这一版不删
+DO 0;
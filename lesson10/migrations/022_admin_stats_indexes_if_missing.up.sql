@@ -0,0 +1,43 @@
+-- 补齐 007 的统计索引。用 `migrate baseline -version N` 接入的老库（AutoMigrate 或旧 compose 建的）
+-- 不会执行 007，这些索引有没有取决于当时的表结构；索引名和 gorm 标签生成的一致，已有的跳过
+SET @ddl = IF((SELECT COUNT(*) FROM information_schema.STATISTICS
+               WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'users' AND INDEX_NAME = 'idx_users_created_at') = 0,
+              'ALTER TABLE users ADD INDEX idx_users_created_at (created_at)', 'DO 0');
+PREPARE stmt FROM @ddl;
+EXECUTE stmt;
+DEALLOCATE PREPARE stmt;
+
+SET @ddl = IF((SELECT COUNT(*) FROM information_schema.STATISTICS
+               WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'posts' AND INDEX_NAME = 'idx_posts_created_at') = 0,
+              'ALTER TABLE posts ADD INDEX idx_posts_created_at (created_at)', 'DO 0');
+PREPARE stmt FROM @ddl;
+EXECUTE stmt;
+DEALLOCATE PREPARE stmt;
+
+SET @ddl = IF((SELECT COUNT(*) FROM information_schema.STATISTICS
+               WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'comments' AND INDEX_NAME = 'idx_comments_created_at') = 0,
+              'ALTER TABLE comments ADD INDEX idx_comments_created_at (created_at)', 'DO 0');
+PREPARE stmt FROM @ddl;
+EXECUTE stmt;
+DEALLOCATE PREPARE stmt;
+
+SET @ddl = IF((SELECT COUNT(*) FROM information_schema.STATISTICS
+               WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'reactions' AND INDEX_NAME = 'idx_reactions_created_at') = 0,
+              'ALTER TABLE reactions ADD INDEX idx_reactions_created_at (created_at)', 'DO 0');
+PREPARE stmt FROM @ddl;
+EXECUTE stmt;
+DEALLOCATE PREPARE stmt;
+
+SET @ddl = IF((SELECT COUNT(*) FROM information_schema.STATISTICS
+               WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'sessions' AND INDEX_NAME = 'idx_sessions_last_seen_at') = 0,
+              'ALTER TABLE sessions ADD INDEX idx_sessions_last_seen_at (last_seen_at)', 'DO 0');
+PREPARE stmt FROM @ddl;
+EXECUTE stmt;
+DEALLOCATE PREPARE stmt;
+
+SET @ddl = IF((SELECT COUNT(*) FROM information_schema.STATISTICS
+               WHERE TABLE_SCHEMA = DATABASE() AND TABLE_NAME = 'security_events' AND INDEX_NAME = 'idx_security_events_created_at') = 0,
+              'ALTER TABLE security_events ADD INDEX idx_security_events_created_at (created_at)', 'DO 0');
+PREPARE stmt FROM @ddl;
+EXECUTE stmt;
+DEALLOCATE PREPARE stmt;
@@ -0,0 +1,468 @@
+-- 截至 019 的完整表结构（和当时 AutoMigrate 加上 001~019 手工脚本的结果一致）。
+-- 空库执行 migrate up 时用它一次建好所有表，并把 001~019 记为已执行；已有数据的库不会用到它
+
+CREATE TABLE users (
+                              id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
+                              created_at DATETIME(3) NULL,
+                              updated_at DATETIME(3) NULL,
+                              deleted_at DATETIME(3) NULL,
+                              username VARCHAR(64) NOT NULL,
+                              password_hash VARCHAR(255) NOT NULL,
+                              token_version BIGINT NOT NULL DEFAULT 0,
+                              email VARCHAR(191),
+                              email_verified_at DATETIME(3) NULL,
+                              avatar_url VARCHAR(255),
+                              profile VARCHAR(255),
+                              role TINYINT UNSIGNED NOT NULL DEFAULT 0,
+                              vip_expires_at DATETIME(3) NULL,
+
+                              PRIMARY KEY (id),
+                              UNIQUE KEY idx_users_username (username),
+                              UNIQUE KEY idx_users_email (email),
+                              KEY idx_users_deleted_at (deleted_at),
+                              KEY idx_users_created_at (created_at)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE posts (
+                              id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
+                              created_at DATETIME(3) NULL,
+                              updated_at DATETIME(3) NULL,
+                              deleted_at DATETIME(3) NULL,
+                              type TINYINT UNSIGNED NOT NULL,
+                              author_id BIGINT UNSIGNED NOT NULL,
+                              title VARCHAR(200) NOT NULL,
+                              content LONGTEXT NOT NULL,
+                              is_deleted TINYINT UNSIGNED NOT NULL DEFAULT 0,
+                              status TINYINT UNSIGNED NOT NULL DEFAULT 0,
+                              content_html LONGTEXT,
+                              excerpt VARCHAR(512) NOT NULL DEFAULT '',
+                              render_version BIGINT NOT NULL DEFAULT 0,
+                              like_count BIGINT UNSIGNED DEFAULT 0,
+
+                              PRIMARY KEY (id),
+                              KEY idx_posts_deleted_at (deleted_at),
+                              KEY idx_posts_type (type),
+                              KEY idx_posts_author_id (author_id),
+                              KEY idx_posts_is_deleted (is_deleted),
+                              KEY idx_posts_status (status),
+                              KEY idx_posts_render_version (render_version),
+                              KEY idx_posts_created_at (created_at),
+                              FULLTEXT KEY ft_posts_title_content (title, content) WITH PARSER ngram,
+                              CONSTRAINT fk_users_posts FOREIGN KEY (author_id) REFERENCES users (id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE comments (
+                                 id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
+                                 created_at DATETIME(3) NULL,
+                                 updated_at DATETIME(3) NULL,
+                                 deleted_at DATETIME(3) NULL,
+                                 target_type TINYINT UNSIGNED NOT NULL,
+                                 target_id BIGINT UNSIGNED NOT NULL,
+                                 author_id BIGINT UNSIGNED NOT NULL,
+                                 content TEXT NOT NULL,
+                                 is_deleted TINYINT UNSIGNED NOT NULL DEFAULT 0,
+                                 depth TINYINT UNSIGNED NOT NULL DEFAULT 0,
+                                 like_count BIGINT UNSIGNED DEFAULT 0,
+
+                                 PRIMARY KEY (id),
+                                 KEY idx_comments_deleted_at (deleted_at),
+                                 KEY idx_target_created (target_type, target_id),
+                                 KEY idx_comments_author_id (author_id),
+                                 KEY idx_comments_is_deleted (is_deleted),
+                                 KEY idx_comments_created_at (created_at),
+                                 FULLTEXT KEY ft_comments_content (content) WITH PARSER ngram,
+                                 CONSTRAINT fk_users_comments FOREIGN KEY (author_id) REFERENCES users (id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE post_images (
+                                    id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
+                                    created_at DATETIME(3) NULL,
+                                    updated_at DATETIME(3) NULL,
+                                    deleted_at DATETIME(3) NULL,
+                                    post_id BIGINT UNSIGNED NOT NULL,
+                                    uploader_id BIGINT UNSIGNED NOT NULL,
+                                    kind VARCHAR(16) NOT NULL DEFAULT 'article',
+                                    content_hash VARCHAR(64) NOT NULL DEFAULT '',
+                                    url VARCHAR(512) NOT NULL,
+                                    width BIGINT NOT NULL DEFAULT 0,
+                                    height BIGINT NOT NULL DEFAULT 0,
+                                    size BIGINT NOT NULL DEFAULT 0,
+                                    variants TEXT,
+
+                                    PRIMARY KEY (id),
+                                    KEY idx_post_images_deleted_at (deleted_at),
+                                    KEY idx_post_images_post_id (post_id),
+                                    KEY idx_post_images_uploader_id (uploader_id),
+                                    KEY idx_post_images_kind_hash (kind, content_hash),
+                                    KEY idx_post_images_created_at (created_at)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE post_image_refs (
+                                        id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
+                                        post_id BIGINT UNSIGNED NOT NULL,
+                                        image_id BIGINT UNSIGNED NOT NULL,
+                                        created_at DATETIME(3) NULL,
+
+                                        PRIMARY KEY (id),
+                                        UNIQUE KEY uk_post_image_ref (post_id, image_id),
+                                        KEY idx_post_image_refs_image_id (image_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE user_follows (
+                                     id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
+                                     follower_id BIGINT UNSIGNED NOT NULL,
+                                     followee_id BIGINT UNSIGNED NOT NULL,
+                                     created_at DATETIME(3) NULL,
+                                     updated_at DATETIME(3) NULL,
+
+                                     PRIMARY KEY (id),
+                                     UNIQUE KEY uk_pair (follower_id, followee_id),
+                                     KEY idx_user_follows_followee_id (followee_id),
+                                     CONSTRAINT fk_users_followers FOREIGN KEY (followee_id) REFERENCES users (id),
+                                     CONSTRAINT fk_users_followees FOREIGN KEY (follower_id) REFERENCES users (id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE question_follows (
+                                         id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
+                                         created_at DATETIME(3) NULL,
+                                         updated_at DATETIME(3) NULL,
+                                         deleted_at DATETIME(3) NULL,
+                                         user_id BIGINT UNSIGNED NOT NULL,
+                                         question_id BIGINT UNSIGNED NOT NULL,
+
+                                         PRIMARY KEY (id),
+                                         UNIQUE KEY uk_qf (user_id, question_id),
+                                         KEY idx_question_follows_deleted_at (deleted_at),
+                                         KEY idx_question_follows_question_id (question_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE reactions (
+                                  id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
+                                  user_id BIGINT UNSIGNED,
+                                  target_type TINYINT UNSIGNED,
+                                  target_id BIGINT UNSIGNED,
+                                  created_at DATETIME(3) NULL,
+                                  updated_at DATETIME(3) NULL,
+
+                                  PRIMARY KEY (id),
+                                  KEY idx_reactions_user_id (user_id),
+                                  KEY idx_reactions_target_type (target_type),
+                                  KEY idx_reactions_target_id (target_id),
+                                  KEY idx_reactions_created_at (created_at),
+                                  CONSTRAINT fk_users_reactions FOREIGN KEY (user_id) REFERENCES users (id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE favorites (
+                                  id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
+                                  user_id BIGINT UNSIGNED,
+                                  target_type TINYINT UNSIGNED,
+                                  target_id BIGINT UNSIGNED,
+                                  created_at DATETIME(3) NULL,
+                                  updated_at DATETIME(3) NULL,
+
+                                  PRIMARY KEY (id),
+                                  KEY idx_favorites_user_id (user_id),
+                                  KEY idx_favorites_target_type (target_type),
+                                  KEY idx_favorites_target_id (target_id),
+                                  CONSTRAINT fk_users_favorites FOREIGN KEY (user_id) REFERENCES users (id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE activities (
+                                   id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
+                                   created_at DATETIME(3) NULL,
+                                   updated_at DATETIME(3) NULL,
+                                   deleted_at DATETIME(3) NULL,
+                                   actor_id BIGINT UNSIGNED NOT NULL,
+                                   action TINYINT UNSIGNED NOT NULL,
+                                   target_type TINYINT UNSIGNED NOT NULL,
+                                   target_id BIGINT UNSIGNED NOT NULL,
+
+                                   PRIMARY KEY (id),
+                                   KEY idx_activities_deleted_at (deleted_at),
+                                   KEY idx_activities_actor_id (actor_id),
+                                   CONSTRAINT fk_users_activities FOREIGN KEY (actor_id) REFERENCES users (id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE notifications (
+                                      id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
+                                      created_at DATETIME(3) NULL,
+                                      updated_at DATETIME(3) NULL,
+                                      deleted_at DATETIME(3) NULL,
+                                      user_id BIGINT UNSIGNED NOT NULL,
+                                      type TINYINT UNSIGNED NOT NULL,
+                                      actor_id BIGINT UNSIGNED,
+                                      target_type TINYINT UNSIGNED,
+                                      target_id BIGINT UNSIGNED,
+                                      content VARCHAR(255) NOT NULL,
+                                      is_read TINYINT UNSIGNED NOT NULL DEFAULT 0,
+
+                                      PRIMARY KEY (id),
+                                      KEY idx_notifications_deleted_at (deleted_at),
+                                      KEY idx_notifications_user_id (user_id),
+                                      KEY idx_notifications_is_read (is_read),
+                                      CONSTRAINT fk_users_notifications FOREIGN KEY (user_id) REFERENCES users (id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE conversations (
+                                      id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
+                                      created_at DATETIME(3) NULL,
+                                      updated_at DATETIME(3) NULL,
+                                      deleted_at DATETIME(3) NULL,
+
+                                      PRIMARY KEY (id),
+                                      KEY idx_conversations_deleted_at (deleted_at)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE conversation_members (
+                                             id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
+                                             created_at DATETIME(3) NULL,
+                                             updated_at DATETIME(3) NULL,
+                                             deleted_at DATETIME(3) NULL,
+                                             conversation_id BIGINT UNSIGNED NOT NULL,
+                                             user_id BIGINT UNSIGNED NOT NULL,
+
+                                             PRIMARY KEY (id),
+                                             UNIQUE KEY uk_cm (conversation_id, user_id),
+                                             KEY idx_conversation_members_deleted_at (deleted_at),
+                                             KEY idx_conversation_members_user_id (user_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE messages (
+                                 id BIGINT UNSIGNED NOT NULL AUTO_INCREMENT,
+                                 created_at DATETIME(3) NULL,
+                                 updated_at DATETIME(3) NULL,
+                                 deleted_at DATETIME(3) NULL,
+                                 conversation_id BIGINT UNSIGNED NOT NULL,
+                                 sender_id BIGINT UNSIGNED NOT NULL,
+                                 content TEXT NOT NULL,
+
+                                 PRIMARY KEY (id),
+                                 KEY idx_messages_deleted_at (deleted_at),
+                                 KEY idx_messages_conversation_id (conversation_id),
+                                 KEY idx_messages_sender_id (sender_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE sessions (
+                                 id BIGINT NOT NULL AUTO_INCREMENT,
+                                 session_id VARCHAR(64) NOT NULL,
+                                 user_id BIGINT NOT NULL,
+                                 status VARCHAR(32) NOT NULL,
+                                 device_id VARCHAR(128),
+                                 device_name VARCHAR(128),
+                                 label VARCHAR(64),
+                                 user_agent VARCHAR(512),
+                                 browser_name VARCHAR(64),
+                                 browser_version VARCHAR(64),
+                                 os_name VARCHAR(64),
+                                 device_type VARCHAR(32),
+                                 browser_key VARCHAR(191),
+                                 login_ip VARCHAR(64),
+                                 last_ip VARCHAR(64),
+                                 last_seen_at DATETIME(3) NULL,
+                                 remember_me TINYINT(1) NOT NULL DEFAULT 0,
+                                 current_access_jti VARCHAR(64),
+                                 current_access_expires DATETIME(3) NULL,
+                                 revoked_at DATETIME(3) NULL,
+                                 revoke_reason VARCHAR(128),
+                                 created_at DATETIME(3) NULL,
+                                 updated_at DATETIME(3) NULL,
+
+                                 PRIMARY KEY (id),
+                                 UNIQUE KEY idx_sessions_session_id (session_id),
+                                 KEY idx_sessions_user_id (user_id),
+                                 KEY idx_sessions_status (status),
+                                 KEY idx_sessions_status_revoked_at (status, revoked_at),
+                                 KEY idx_sessions_device_id (device_id),
+                                 KEY idx_sessions_browser_key (browser_key),
+                                 KEY idx_sessions_last_seen_at (last_seen_at),
+                                 KEY idx_sessions_current_access_jti (current_access_jti),
+                                 KEY idx_sessions_user_revoked_at (user_id, revoked_at)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE refresh_tokens (
+                                       id BIGINT NOT NULL AUTO_INCREMENT,
+                                       session_id VARCHAR(64) NOT NULL,
+                                       user_id BIGINT NOT NULL,
+                                       token_hash VARCHAR(64) NOT NULL,
+                                       status VARCHAR(32) NOT NULL,
+                                       expires_at DATETIME(3) NULL,
+                                       used_at DATETIME(3) NULL,
+                                       revoked_at DATETIME(3) NULL,
+                                       revoke_reason VARCHAR(128),
+                                       rotated_to VARCHAR(64),
+                                       last_used_ip VARCHAR(64),
+                                       last_used_user_agent VARCHAR(512),
+                                       created_at DATETIME(3) NULL,
+                                       updated_at DATETIME(3) NULL,
+
+                                       PRIMARY KEY (id),
+                                       UNIQUE KEY idx_refresh_tokens_token_hash (token_hash),
+                                       KEY idx_refresh_tokens_session_id (session_id),
+                                       KEY idx_refresh_tokens_user_id (user_id),
+                                       KEY idx_refresh_tokens_status (status),
+                                       KEY idx_refresh_tokens_status_updated_at (status, updated_at),
+                                       KEY idx_refresh_tokens_expires_at (expires_at)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE security_events (
+                                        id BIGINT NOT NULL AUTO_INCREMENT,
+                                        user_id BIGINT NOT NULL,
+                                        session_id VARCHAR(64),
+                                        event_type VARCHAR(64) NOT NULL,
+                                        ip VARCHAR(64),
+                                        device_id VARCHAR(128),
+                                        user_agent VARCHAR(512),
+                                        detail TEXT,
+                                        created_at DATETIME(3) NULL,
+
+                                        PRIMARY KEY (id),
+                                        KEY idx_security_events_user_id (user_id),
+                                        KEY idx_security_events_user_type_created (user_id, event_type, created_at),
+                                        KEY idx_security_events_session_id (session_id),
+                                        KEY idx_security_events_event_type (event_type),
+                                        KEY idx_security_events_created_at (created_at)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE data_exports (
+                                     id BIGINT NOT NULL AUTO_INCREMENT,
+                                     user_id BIGINT NOT NULL,
+                                     status VARCHAR(32) NOT NULL,
+                                     token_hash VARCHAR(64) NOT NULL,
+                                     file_path VARCHAR(255),
+                                     file_size BIGINT,
+                                     error VARCHAR(255),
+                                     expires_at DATETIME(3) NULL,
+                                     completed_at DATETIME(3) NULL,
+                                     created_at DATETIME(3) NULL,
+                                     updated_at DATETIME(3) NULL,
+
+                                     PRIMARY KEY (id),
+                                     UNIQUE KEY idx_data_exports_token_hash (token_hash),
+                                     KEY idx_data_exports_user_id (user_id),
+                                     KEY idx_data_exports_status (status)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE account_deletions (
+                                          id BIGINT NOT NULL AUTO_INCREMENT,
+                                          user_id BIGINT NOT NULL,
+                                          status VARCHAR(32) NOT NULL,
+                                          policy VARCHAR(32) NOT NULL,
+                                          scheduled_at DATETIME(3) NULL,
+                                          cancelled_at DATETIME(3) NULL,
+                                          completed_at DATETIME(3) NULL,
+                                          created_at DATETIME(3) NULL,
+                                          updated_at DATETIME(3) NULL,
+
+                                          PRIMARY KEY (id),
+                                          KEY idx_account_deletions_user_id (user_id),
+                                          KEY idx_account_deletions_status (status),
+                                          KEY idx_account_deletions_scheduled_at (scheduled_at)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE email_tokens (
+                                     id BIGINT NOT NULL AUTO_INCREMENT,
+                                     user_id BIGINT NOT NULL,
+                                     purpose VARCHAR(32) NOT NULL,
+                                     email VARCHAR(191) NOT NULL,
+                                     token_hash VARCHAR(64) NOT NULL,
+                                     expires_at DATETIME(3) NULL,
+                                     used_at DATETIME(3) NULL,
+                                     created_at DATETIME(3) NULL,
+
+                                     PRIMARY KEY (id),
+                                     UNIQUE KEY idx_email_tokens_token_hash (token_hash),
+                                     KEY idx_email_tokens_user_id (user_id),
+                                     KEY idx_email_tokens_purpose (purpose)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE user_totps (
+                                   id BIGINT NOT NULL AUTO_INCREMENT,
+                                   user_id BIGINT NOT NULL,
+                                   secret VARCHAR(64) NOT NULL,
+                                   enabled_at DATETIME(3) NULL,
+                                   last_used_step BIGINT NOT NULL DEFAULT 0,
+                                   created_at DATETIME(3) NULL,
+                                   updated_at DATETIME(3) NULL,
+
+                                   PRIMARY KEY (id),
+                                   UNIQUE KEY idx_user_totps_user_id (user_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE totp_recovery_codes (
+                                            id BIGINT NOT NULL AUTO_INCREMENT,
+                                            user_id BIGINT NOT NULL,
+                                            code_hash VARCHAR(64) NOT NULL,
+                                            used_at DATETIME(3) NULL,
+                                            created_at DATETIME(3) NULL,
+
+                                            PRIMARY KEY (id),
+                                            UNIQUE KEY idx_totp_recovery_codes_code_hash (code_hash),
+                                            KEY idx_totp_recovery_codes_user_id (user_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE login_challenges (
+                                         id BIGINT NOT NULL AUTO_INCREMENT,
+                                         user_id BIGINT NOT NULL,
+                                         token_hash VARCHAR(64) NOT NULL,
+                                         device_id VARCHAR(128),
+                                         device_name VARCHAR(128),
+                                         remember_me TINYINT(1) NOT NULL DEFAULT 0,
+                                         ip VARCHAR(64),
+                                         user_agent VARCHAR(512),
+                                         attempts BIGINT NOT NULL DEFAULT 0,
+                                         expires_at DATETIME(3) NULL,
+                                         used_at DATETIME(3) NULL,
+                                         created_at DATETIME(3) NULL,
+
+                                         PRIMARY KEY (id),
+                                         UNIQUE KEY idx_login_challenges_token_hash (token_hash),
+                                         KEY idx_login_challenges_user_id (user_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE user_identities (
+                                        id BIGINT NOT NULL AUTO_INCREMENT,
+                                        user_id BIGINT NOT NULL,
+                                        provider VARCHAR(32) NOT NULL,
+                                        subject VARCHAR(191) NOT NULL,
+                                        email VARCHAR(191),
+                                        created_at DATETIME(3) NULL,
+                                        updated_at DATETIME(3) NULL,
+
+                                        PRIMARY KEY (id),
+                                        UNIQUE KEY idx_user_identities_user_provider (user_id, provider),
+                                        UNIQUE KEY idx_user_identities_provider_subject (provider, subject)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE oauth_states (
+                                     id BIGINT NOT NULL AUTO_INCREMENT,
+                                     state_hash VARCHAR(64) NOT NULL,
+                                     provider VARCHAR(32) NOT NULL,
+                                     purpose VARCHAR(16) NOT NULL,
+                                     user_id BIGINT,
+                                     nonce VARCHAR(64) NOT NULL,
+                                     code_verifier VARCHAR(128) NOT NULL,
+                                     device_id VARCHAR(128),
+                                     device_name VARCHAR(128),
+                                     remember_me TINYINT(1) NOT NULL DEFAULT 0,
+                                     expires_at DATETIME(3) NULL,
+                                     used_at DATETIME(3) NULL,
+                                     created_at DATETIME(3) NULL,
+
+                                     PRIMARY KEY (id),
+                                     UNIQUE KEY idx_oauth_states_state_hash (state_hash),
+                                     KEY idx_oauth_states_user_id (user_id)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+CREATE TABLE login_throttles (
+                                        id BIGINT NOT NULL AUTO_INCREMENT,
+                                        scope VARCHAR(16) NOT NULL,
+                                        `key` VARCHAR(191) NOT NULL,
+                                        failures BIGINT NOT NULL DEFAULT 0,
+                                        last_failed_at DATETIME(3) NULL,
+                                        locked_until DATETIME(3) NULL,
+                                        updated_at DATETIME(3) NULL,
+
+                                        PRIMARY KEY (id),
+                                        UNIQUE KEY idx_login_throttles_scope_key (scope, `key`),
+                                        KEY idx_login_throttles_locked_until (locked_until)
+) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
@@ -0,0 +1,15 @@
+// Package migrations 把本目录下的 SQL 脚本编进二进制，部署时不用再带 migrations 目录。
+//
+// 文件命名：
+//
+//	NNN_name.up.sql     必须有，执行后不能再改，要改结构加下一个版本
+//	NNN_name.down.sql   回滚脚本，001~017 的老脚本没有，不能回滚
+//	baseline_NNN.sql    截至 NNN 的完整表结构，空库直接用它建表
+//
+// 001~019 原来叫 NNN_name.sql，改名只改了文件名，版本号、名字和校验和都没变，已经执行过的库不受影响
+package migrations
+
+import "embed"
+
+//go:embed *.sql
+var Files embed.FS
@@ -0,0 +1,18 @@
+#!/bin/sh
+# 下载 /docs 用到的 Swagger UI 静态资源，默认放到 static/swagger-ui，页面从本站加载，不走 CDN。
+# 版本写死，npm pack 会按 registry 记录的 integrity 校验压缩包，升级时改 VERSION 再跑一遍
+set -eu
+
+VERSION=5.17.14
+DEST=${1:-static/swagger-ui}
+
+tmp=$(mktemp -d)
+trap 'rm -rf "$tmp"' EXIT
+
+(cd "$tmp" && npm pack --silent "swagger-ui-dist@$VERSION" >/dev/null)
+tar -xzf "$tmp/swagger-ui-dist-$VERSION.tgz" -C "$tmp"
+
+mkdir -p "$DEST"
+cp "$tmp/package/swagger-ui.css" "$tmp/package/swagger-ui-bundle.js" "$tmp/package/LICENSE" "$DEST/"
+echo "$VERSION" >"$DEST/VERSION"
+echo "swagger-ui-dist $VERSION -> $DEST"
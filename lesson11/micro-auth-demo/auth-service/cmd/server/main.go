@@ -1,6 +1,7 @@
 package main
 
 import (
+	"encoding/json"
 	"log"
 	"net"
 	"net/http"
@@ -18,6 +19,7 @@ import (
 	"example.com/micro-auth-demo/auth-service/internal/rpc"
 	"example.com/micro-auth-demo/auth-service/kitex_gen/auth/authservice"
 	"github.com/cloudwego/kitex/server"
+	"jwtkeys"
 )
 
 func main() {
@@ -26,10 +28,15 @@ func main() {
 	mysqlDSN := getenv("MYSQL_DSN", "demo:demo@tcp(127.0.0.1:3306)/micro_auth_demo?charset=utf8mb4&parseTime=True&loc=Local")
 	redisAddr := getenv("REDIS_ADDR", "127.0.0.1:6379")
 	userServiceAddr := getenv("USER_SERVICE_ADDR", "127.0.0.1:9001")
-	jwtSecret := getenv("JWT_SECRET", "demo-secret")
 	kafkaBrokers := strings.Split(getenv("KAFKA_BROKERS", "kafka:9092"), ",")
 	geoDBPath := getenv("GEO_DB_PATH", "internal/data/ip2region_v4.xdb")
 
+	// 没有签名私钥直接拒绝启动；轮换时旧 key 放进 JWT_VERIFY_KEY_FILES
+	jwtKeys, err := jwtkeys.LoadKeySet(os.Getenv("JWT_SIGNING_KEY_FILE"), strings.Split(os.Getenv("JWT_VERIFY_KEY_FILES"), ","))
+	if err != nil {
+		log.Fatalf("load jwt keys failed: %v", err)
+	}
+
 	db, err := mysqlstore.Init(mysqlDSN)
 	if err != nil {
 		log.Fatal(err)
@@ -64,14 +71,14 @@ func main() {
 		repository.NewTxManager(db),
 		repository.NewAuthCache(redisClient),
 		userClient,
-		jwtSecret,
+		jwtKeys,
 		15*time.Minute,
 		7*24*time.Hour,
 		kafkaProducer,
 		geoLocator,
 	)
 
-	go serveHealth(healthAddr)
+	go serveHealth(healthAddr, jwtKeys)
 
 	addr, err := net.ResolveTCPAddr("tcp", rpcAddr)
 	if err != nil {
@@ -89,12 +96,17 @@ func main() {
 	}
 }
 
-func serveHealth(addr string) {
+func serveHealth(addr string, keys *jwtkeys.KeySet) {
 	mux := http.NewServeMux()
 	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
 		_, _ = w.Write([]byte("ok"))
 	})
+	mux.HandleFunc("GET /.well-known/jwks.json", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "public, max-age=300")
+		_ = json.NewEncoder(w).Encode(keys.JWKS())
+	})
 
 	log.Printf("auth-service health listening on %s", addr)
 	if err := http.ListenAndServe(addr, mux); err != nil {
@@ -9,6 +9,7 @@ require (
 	github.com/redis/go-redis/v9 v9.8.0
 	gorm.io/driver/mysql v1.6.0
 	gorm.io/gorm v1.31.0
+	jwtkeys v0.0.0
 )
 
 require (
@@ -70,3 +71,5 @@ require (
 	google.golang.org/protobuf v1.33.0 // indirect
 	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
+
+replace jwtkeys => ../../../jwtkeys
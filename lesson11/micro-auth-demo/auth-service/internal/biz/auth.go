@@ -7,6 +7,7 @@ import (
 	"example.com/micro-auth-demo/auth-service/internal/pkg/geo"
 	"example.com/micro-auth-demo/auth-service/internal/repository"
 	"example.com/micro-auth-demo/auth-service/internal/rpc"
+	"jwtkeys"
 )
 
 type TokenPair struct {
@@ -24,7 +25,7 @@ type AuthService struct {
 	TxManager     repository.TxManager
 	Cache         repository.AuthCache
 	UserClient    rpc.UserClient
-	Secret        string
+	Keys          *jwtkeys.KeySet
 	AccessTTL     time.Duration
 	RefreshTTL    time.Duration
 	KafkaProducer *kafka.Producer
@@ -38,7 +39,7 @@ func NewAuthService(
 	txManager repository.TxManager,
 	cache repository.AuthCache,
 	userClient rpc.UserClient,
-	secret string,
+	keys *jwtkeys.KeySet,
 	accessTTL time.Duration,
 	refreshTTL time.Duration,
 	kafkaProducer *kafka.Producer,
@@ -51,7 +52,7 @@ func NewAuthService(
 		TxManager:     txManager,
 		Cache:         cache,
 		UserClient:    userClient,
-		Secret:        secret,
+		Keys:          keys,
 		AccessTTL:     accessTTL,
 		RefreshTTL:    refreshTTL,
 		KafkaProducer: kafkaProducer,
@@ -10,7 +10,7 @@ import (
 )
 
 func (s *AuthService) Logout(ctx context.Context, accessToken string) error {
-	claims, err := jwt.Parse(accessToken, s.Secret)
+	claims, err := jwt.Parse(accessToken, s.Keys)
 	if err != nil {
 		return ErrInvalidAccessToken
 	}
@@ -12,7 +12,7 @@ import (
 )
 
 func (s *AuthService) ValidateToken(ctx context.Context, accessToken string) (*AuthIdentity, error) {
-	claims, err := jwt.Parse(accessToken, s.Secret)
+	claims, err := jwt.Parse(accessToken, s.Keys)
 	if err != nil {
 		return nil, ErrInvalidAccessToken
 	}
@@ -62,7 +62,7 @@ func (s *AuthService) issueAccessToken(userID int64, sessionID string, now time.
 		return "", "", time.Time{}, err
 	}
 	expiresAt := now.Add(s.AccessTTL)
-	tokenValue, err := jwt.Sign(jwt.NewClaims(userID, sessionID, tokenID, now, expiresAt), s.Secret)
+	tokenValue, err := jwt.Sign(jwt.NewClaims(userID, sessionID, tokenID, now, expiresAt), s.Keys)
 	if err != nil {
 		return "", "", time.Time{}, err
 	}
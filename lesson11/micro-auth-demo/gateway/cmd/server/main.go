@@ -4,6 +4,7 @@ import (
 	"log"
 	"os"
 
+	"example.com/micro-auth-demo/gateway/internal/handler"
 	"example.com/micro-auth-demo/gateway/internal/router"
 	"example.com/micro-auth-demo/gateway/internal/rpc"
 )
@@ -16,6 +17,8 @@ func main() {
 		log.Fatal(err)
 	}
 
+	handler.InitJWKS(getenv("AUTH_JWKS_URL", "http://127.0.0.1:19002/.well-known/jwks.json"))
+
 	addr := ":" + getenv("PORT", "8080")
 	log.Printf("gateway listening on %s", addr)
 
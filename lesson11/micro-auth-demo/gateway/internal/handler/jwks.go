@@ -0,0 +1,42 @@
+package handler
+
+import (
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+var (
+	jwksURL    string
+	jwksClient = &http.Client{Timeout: 3 * time.Second}
+)
+
+func InitJWKS(url string) {
+	jwksURL = url
+}
+
+// JWKS 把 auth-service 健康端口上的公钥原样转发出去，下游服务拿 kid 对应的公钥本地验签
+func JWKS(ctx *gin.Context) {
+	resp, err := jwksClient.Get(jwksURL)
+	if err != nil {
+		writeError(ctx, http.StatusBadGateway, "jwks unavailable")
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		writeError(ctx, http.StatusBadGateway, "jwks unavailable")
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		writeError(ctx, http.StatusBadGateway, "jwks unavailable")
+		return
+	}
+
+	ctx.Header("Cache-Control", "public, max-age=300")
+	ctx.Data(http.StatusOK, "application/json", body)
+}
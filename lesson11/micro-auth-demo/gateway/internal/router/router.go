@@ -15,6 +15,7 @@ func New() *gin.Engine {
 	engine.GET("/healthz", func(ctx *gin.Context) {
 		ctx.String(200, "ok")
 	})
+	engine.GET("/.well-known/jwks.json", handler.JWKS)
 
 	authGroup := engine.Group("/api/v1/auth")
 	authGroup.POST("/login", handler.Login)
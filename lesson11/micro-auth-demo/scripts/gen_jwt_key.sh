@@ -0,0 +1,25 @@
+#!/usr/bin/env bash
+
+set -euo pipefail
+
+ROOT_DIR="$(cd "$(dirname "${BASH_SOURCE[0]}")/.." && pwd)"
+OUT="${1:-${ROOT_DIR}/keys/jwt.pem}"
+ALG="${2:-ed25519}"
+
+mkdir -p "$(dirname "${OUT}")"
+
+case "${ALG}" in
+  ed25519)
+    openssl genpkey -algorithm ed25519 -out "${OUT}"
+    ;;
+  rsa)
+    openssl genpkey -algorithm RSA -pkeyopt rsa_keygen_bits:2048 -out "${OUT}"
+    ;;
+  *)
+    echo "usage: $0 [out.pem] [ed25519|rsa]" >&2
+    exit 1
+    ;;
+esac
+
+chmod 600 "${OUT}"
+echo "wrote ${OUT}"
@@ -3,6 +3,8 @@
 set -euo pipefail
 
 ROOT_DIR="$(cd "$(dirname "${BASH_SOURCE[0]}")/.." && pwd)"
+export JWT_SIGNING_KEY_FILE="${JWT_SIGNING_KEY_FILE:-${ROOT_DIR}/keys/jwt.pem}"
+
 cd "${ROOT_DIR}/auth-service"
 
 go run ./cmd/server